@@ -1,24 +1,43 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"interop/internal/argflags"
+	"interop/internal/cache"
 	"interop/internal/command"
 	"interop/internal/display"
 	"interop/internal/edit"
+	"interop/internal/execution"
+	"interop/internal/history"
+	"interop/internal/jobs"
 	"interop/internal/logging"
 	"interop/internal/mcp"
+	"interop/internal/output"
+	"interop/internal/profiling"
+	"interop/internal/progress"
 	projectPkg "interop/internal/project"
+	"interop/internal/query"
 	"interop/internal/remote"
+	"interop/internal/schema"
+	"interop/internal/secrets"
 	"interop/internal/settings"
+	"interop/internal/setup"
+	"interop/internal/shellenv"
+	"interop/internal/tracing"
 	"interop/internal/tui"
 	"interop/internal/validation"
-	"interop/internal/validation/project"
+	"io"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
 	"github.com/spf13/cobra"
 )
 
@@ -28,11 +47,73 @@ var (
 )
 
 func main() {
-	cfg, err := settings.Load()
+	started := time.Now()
+
+	// Hooks and commands calling interop run nested in this invocation
+	if err := settings.EnterInvocation(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Profiling starts first to cover slow configuration loads
+	stopProfile := func() {}
+	if spec := earlyFlag(os.Args[1:], "profile", ""); spec != "" {
+		var err error
+		if stopProfile, err = profiling.Start(spec); err != nil {
+			log.Fatalf("profile: %v", err)
+		}
+	}
+
+	// The config directory and sandbox must be in place before any
+	// configuration is read
+	if dir := earlyFlag(os.Args[1:], "config-dir", settings.ConfigDirEnvVar); dir != "" {
+		if err := settings.SetConfigDir(dir); err != nil {
+			log.Fatalf("config dir init: %v", err)
+		}
+	}
+	if dir := earlyFlag(os.Args[1:], "sandbox-config", settings.SandboxEnvVar); dir != "" {
+		if err := settings.EnableSandbox(dir); err != nil {
+			log.Fatalf("sandbox init: %v", err)
+		}
+	}
+
+	// Configuration left in ~/.config/interop by older versions moves to the
+	// platform config directory once; until then it's used where it is
+	if from, err := settings.MigrateAppDir(); err != nil {
+		logging.Warning("Failed to migrate the configuration, using the old location: %v", err)
+	} else if from != "" {
+		appDir, _ := settings.GetAppDir()
+		logging.Info("Migrated configuration from %s to %s", from, appDir)
+	}
+
+	// A broken configuration stops every command except the ones used to
+	// inspect and fix it, see allowsBrokenConfig
+	cfg, loadErr := settings.Load()
+	if loadErr == nil {
+		logging.Message("Config is loaded")
+	}
+
+	// Tracing is configured by the settings, so the config load spans are
+	// recorded after the fact. --timings collects the spans to print them.
+	var timings io.Writer
+	if earlyBoolFlag(os.Args[1:], "timings") {
+		timings = os.Stderr
+	}
+	shutdownTracing, err := tracing.Setup(cfg.Tracing, timings)
 	if err != nil {
-		log.Fatalf("settings init: %v", err)
+		logging.Warning("Tracing disabled: %v", err)
 	}
-	logging.Message("Config is loaded")
+	ctx, rootSpan := tracing.StartAt(context.Background(), "interop", started)
+	loadCtx, loadSpan := tracing.StartAt(ctx, "config.load", started)
+	for _, phase := range settings.LoadPhases() {
+		tracing.Record(loadCtx, phase.Name, phase.Start, phase.End)
+	}
+	loadSpan.End()
+	finishTracing := func() {
+		rootSpan.End()
+		shutdownTracing()
+		stopProfile()
+	}
+	logging.OnExit(finishTracing)
 
 	rootCmd := &cobra.Command{
 		Use:     "interop",
@@ -43,8 +124,55 @@ func main() {
 			cmd.Help()
 		},
 	}
+	// Parsed before cobra runs by earlyFlag; declared so cobra accepts them
+	rootCmd.PersistentFlags().String("config-dir", "", "Use this configuration directory instead of the default (also set by INTEROP_CONFIG_DIR)")
+	rootCmd.PersistentFlags().String("sandbox-config", "", "Load configuration only from this directory and keep all state in memory (for hermetic tests)")
+	rootCmd.PersistentFlags().Bool("timings", false, "Print how long loading the configuration, resolving and running the command took")
+	rootCmd.PersistentFlags().String("profile", "", "Write a Go profile: cpu, mem or trace, optionally =PATH")
+	rootCmd.PersistentFlags().MarkHidden("profile")
+
+	var outputFlag string
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", string(output.Table), "Format of the results of list and status commands: table, json or yaml")
+	outputFormat := func() output.Format {
+		format, err := output.ParseFormat(outputFlag)
+		if err != nil {
+			logging.ErrorAndExit("%v", err)
+		}
+		return format
+	}
+	// jsonAlias declares the --json flag commands had before --output, kept
+	// as a deprecated way to ask for json
+	jsonAlias := func(cmd *cobra.Command, value *bool) {
+		cmd.Flags().BoolVar(value, "json", false, "Same as --output json")
+		cmd.Flags().MarkDeprecated("json", "use --output json instead")
+	}
+	// listFormat returns the output format of a command with a --json alias
+	listFormat := func(jsonFlag bool) output.Format {
+		if jsonFlag {
+			return output.JSON
+		}
+		return outputFormat()
+	}
+
+	var noColor bool
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", os.Getenv("NO_COLOR") != "", "Disable colored output (also set by NO_COLOR)")
+	applyNoColor := func() {
+		if noColor {
+			logging.DisableColors()
+			progress.DisableColors()
+			// Nested interop invocations stay colorless too
+			os.Setenv("NO_COLOR", "1")
+		}
+	}
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		applyNoColor()
+		if loadErr != nil && !allowsBrokenConfig(cmd) {
+			logging.ErrorAndExit("Failed to load configuration:\n%v\n\nRun 'interop validate' to list the problems or 'interop config edit' to fix them.", loadErr)
+		}
+	}
 
 	// Projects command that shows all projects and their commands
+	var projectsOpts projectPkg.ListOptions
 	projectsCmd := &cobra.Command{
 		Use:     "projects",
 		Short:   "List all configured projects with their commands",
@@ -56,18 +184,24 @@ func main() {
 				logging.ErrorAndExit("Failed to reload configuration: %v", err)
 			}
 
-			projectPkg.ListWithCommands(freshCfg)
+			if format := outputFormat(); format.Structured() {
+				printStructured(format, projectPkg.Summaries(freshCfg, projectsOpts))
+				return
+			}
+			projectPkg.ListWithCommands(freshCfg, projectsOpts)
 		},
 	}
+	projectsCmd.Flags().BoolVar(&projectsOpts.OnlyEnabled, "only-enabled", false, "Hide disabled commands and references to missing ones")
+	projectsCmd.Flags().BoolVarP(&projectsOpts.Verbose, "verbose", "v", false, "Show what each command runs and where it's defined")
 	rootCmd.AddCommand(projectsCmd)
 
 	// Commands command that lists all commands
-	var useTUI bool
+	var useTUI, listPrompts, listAll bool
 	commandsCmd := &cobra.Command{
 		Use:     "commands",
 		Short:   "List all configured commands",
 		Aliases: []string{"c", "cmd", "cmds"},
-		Long:    "List all configured commands. Use --tui flag to open an interactive terminal interface.",
+		Long:    "List all configured commands. Use --prompts to also list the prompts, or --all for everything interop can run or serve. Use --tui flag to open an interactive terminal interface, the same as 'interop tui'.",
 		Run: func(cmd *cobra.Command, args []string) {
 			// Reload configuration fresh to ensure remote configs are included
 			freshCfg, err := settings.Load()
@@ -76,11 +210,7 @@ func main() {
 			}
 
 			if useTUI {
-				// Run TUI
-				model := tui.NewCommandsModel(freshCfg)
-				p := tea.NewProgram(model, tea.WithAltScreen())
-
-				if _, err := p.Run(); err != nil {
+				if err := tui.Run(freshCfg, getVersionInfo()); err != nil {
 					logging.ErrorAndExit("Error running TUI: %v", err)
 				}
 				return
@@ -94,6 +224,9 @@ func main() {
 					IsEnabled:    cmdCfg.IsEnabled,
 					Cmd:          cmdCfg.Cmd,
 					IsExecutable: cmdCfg.IsExecutable,
+					SourceFile:   cmdCfg.SourceFile,
+					SourceLine:   cmdCfg.SourceLine,
+					Shadowed:     cmdCfg.Shadowed,
 				}
 			}
 
@@ -110,31 +243,809 @@ func main() {
 				projectCommands[projectName] = aliases
 			}
 
+			if format := outputFormat(); format.Structured() {
+				result := struct {
+					Commands []command.Summary       `json:"commands"`
+					Prompts  []settings.PromptConfig `json:"prompts,omitempty"`
+				}{Commands: command.Summaries(commands, projectCommands)}
+				if listPrompts || listAll {
+					result.Prompts = display.PromptList(freshCfg.Prompts)
+				}
+				printStructured(format, result)
+				return
+			}
+
 			command.ListWithProjects(commands, projectCommands)
+
+			if listPrompts || listAll {
+				display.ListPrompts(freshCfg.Prompts)
+			}
 		},
 	}
 	commandsCmd.Flags().BoolVar(&useTUI, "tui", false, "Use interactive terminal interface")
+	commandsCmd.Flags().BoolVar(&listPrompts, "prompts", false, "Also list the prompts with their MCP servers and arguments")
+	commandsCmd.Flags().BoolVar(&listAll, "all", false, "List everything interop can run or serve: commands and prompts")
 	rootCmd.AddCommand(commandsCmd)
 
+	// TUI command opening the interactive terminal interface
+	tuiCmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Open the interactive terminal interface",
+		Long:  "Browse, search and run the configured commands and follow the history of detached jobs in an interactive terminal interface.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Reload configuration fresh to ensure remote configs are included
+			freshCfg, err := settings.Load()
+			if err != nil {
+				logging.ErrorAndExit("Failed to reload configuration: %v", err)
+			}
+			if err := tui.Run(freshCfg, getVersionInfo()); err != nil {
+				logging.ErrorAndExit("Error running TUI: %v", err)
+			}
+		},
+	}
+	rootCmd.AddCommand(tuiCmd)
+
+	// Init command writing a settings template and walking through a first
+	// project and command
+	var initMinimal, initFull, initTeam, initForce, initPrint bool
+	var initRemote, initRemoteURL string
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a first project and command interactively",
+		Long: `Ask for a project, defaulting to the current directory, and a command bound
+to it, then add both to settings.toml. Existing projects and commands are
+never redefined; the file is only written after confirming the definitions.
+
+With --minimal, --full or --team settings.toml is first replaced by a template:
+a few commented examples, every option with its documentation, or a starter
+for commands shared by a team through a remote, which is added as well.
+A settings.toml with changes of its own is only replaced with --force, which
+keeps a backup. --print writes the template to stdout instead.`,
+		Example: `  interop init
+  interop init --minimal
+  interop init --team --remote-url https://github.com/acme/interop-config.git
+  interop init --full --print`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := setup.Options{
+				Template:    settings.TemplateOptions{Remote: initRemote, RemoteURL: initRemoteURL},
+				Force:       initForce,
+				Interactive: isTerminal(os.Stdin),
+			}
+			switch {
+			case initMinimal:
+				opts.Template.Kind = settings.TemplateMinimal
+			case initFull:
+				opts.Template.Kind = settings.TemplateFull
+			case initTeam:
+				opts.Template.Kind = settings.TemplateTeam
+			}
+
+			if initPrint {
+				if opts.Template.Kind == "" {
+					logging.ErrorAndExit("--print needs --minimal, --full or --team")
+				}
+				content, err := settings.RenderTemplate(opts.Template)
+				if err != nil {
+					logging.ErrorAndExit("Failed to render the settings template: %v", err)
+				}
+				fmt.Print(content)
+				return
+			}
+
+			if err := setup.Init(cfg, opts, os.Stdin, os.Stdout); err != nil {
+				logging.ErrorAndExit("Init failed: %v", err)
+			}
+		},
+	}
+	initCmd.Flags().BoolVar(&initMinimal, "minimal", false, "Start from a minimal settings.toml")
+	initCmd.Flags().BoolVar(&initFull, "full", false, "Start from a settings.toml documenting every option")
+	initCmd.Flags().BoolVar(&initTeam, "team", false, "Start from a settings.toml for commands shared through a remote")
+	initCmd.MarkFlagsMutuallyExclusive("minimal", "full", "team")
+	initCmd.Flags().StringVar(&initRemote, "remote-name", "team", "Name of the team's remote, with --team")
+	initCmd.Flags().StringVar(&initRemoteURL, "remote-url", "", "Git URL of the team's configuration, asked for when missing, with --team")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Replace a settings.toml with changes of its own, keeping a backup")
+	initCmd.Flags().BoolVar(&initPrint, "print", false, "Print the template instead of writing it")
+	rootCmd.AddCommand(initCmd)
+
 	// New run command that supports both command names and aliases
+	var runEnv, runEnvFiles []string
+	var runCwd string
+	var runTimeout time.Duration
+	var runDetach, runWatchConfig, runRestart, runDryRun, runParallel bool
+	var runJobs int
+	var runWatch []string
 	runCmd := &cobra.Command{
 		Use:     "run [command-or-alias] [args...]",
 		Short:   "Execute a command by name or alias with optional arguments",
 		Aliases: []string{"r", "exec"},
 		Args:    cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 && !runParallel {
+				if strings.HasPrefix(toComplete, "-") {
+					return argflags.Completions(runArguments(cfg, args[0]), cmd.LocalNonPersistentFlags(), toComplete), cobra.ShellCompDirectiveNoFileComp
+				}
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return commandCompletions(cfg), cobra.ShellCompDirectiveNoFileComp
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			commandOrAlias := args[0]
-			commandArgs := args[1:]
+
+			// Flags after the command name give its arguments, or are run's own.
+			// With --parallel every word names a command, none takes arguments.
+			arguments := runArguments(cfg, commandOrAlias)
+			if runParallel {
+				arguments = nil
+			}
+			commandArgs, err := argflags.Parse(arguments, args[1:], cmd.Flags())
+			if err != nil {
+				logging.ErrorAndExit("Invalid arguments for '%s': %v", commandOrAlias, err)
+			}
+			if help, _ := cmd.Flags().GetBool("help"); help {
+				cmd.Help()
+				return
+			}
+			applyNoColor()
+
+			// Tag the run's messages, and pass the ID on to the command and
+			// to detached jobs, which keep the ID of the run starting them
+			correlationID := logging.InheritedCorrelationID()
+			os.Setenv(logging.CorrelationEnvVar, correlationID)
+			logging.SetCorrelationID(correlationID)
+			cmd.SetContext(logging.WithCorrelationID(cmd.Context(), correlationID))
+
+			runOpts, err := validation.NewRunOptions(runEnv, runEnvFiles, runCwd)
+			if err != nil {
+				logging.ErrorAndExit("Invalid run options: %v", err)
+			}
+			if runTimeout < 0 {
+				logging.ErrorAndExit("Invalid run options: --timeout can't be negative")
+			}
+			runOpts.Timeout = runTimeout
+			// Every run is recorded, each job of --parallel and each run of --watch too
+			runOpts.Record = historyRecorder(cfg, runEnv, runEnvFiles, runOpts.Dir, runTimeout)
+			if runRestart && len(runWatch) == 0 && !runWatchConfig {
+				logging.ErrorAndExit("--restart only applies with --watch or --watch-config")
+			}
+
+			if runParallel {
+				if runDetach || runDryRun || len(runWatch) > 0 || runWatchConfig {
+					logging.ErrorAndExit("--parallel can't be combined with --detach, --dry-run or --watch")
+				}
+				if runJobs < 0 {
+					logging.ErrorAndExit("Invalid run options: --jobs can't be negative")
+				}
+				names := append([]string{commandOrAlias}, commandArgs...)
+				results, err := validation.ExecuteCommandsInParallel(cmd.Context(), cfg, names, runOpts, runJobs, os.Stdout)
+				if err != nil {
+					logging.ErrorAndExit("Failed to run in parallel: %v", err)
+				}
+				display.PrintParallelSummary(results)
+				for _, result := range results {
+					if result.Err != nil {
+						os.Exit(1)
+					}
+				}
+				return
+			}
+
+			if runDryRun {
+				if runDetach || len(runWatch) > 0 || runWatchConfig {
+					logging.ErrorAndExit("--dry-run can't be combined with --detach or --watch")
+				}
+				plan, err := validation.DryRunCommand(cmd.Context(), cfg, commandOrAlias, commandArgs, runOpts)
+				if err != nil {
+					logging.ErrorAndExit("Failed to resolve '%s': %v", commandOrAlias, err)
+				}
+				display.PrintPlan(plan)
+				return
+			}
+
+			if len(runWatch) > 0 || runWatchConfig {
+				if runDetach {
+					logging.ErrorAndExit("--watch can't be combined with --detach")
+				}
+				err := validation.WatchCommandWithOptions(cmd.Context(), cfg, commandOrAlias, commandArgs, runOpts, runWatch, runRestart)
+				if err != nil {
+					logging.ErrorAndExit("Failed to watch '%s': %v", commandOrAlias, err)
+				}
+				return
+			}
+
+			if runDetach {
+				ref, err := validation.ResolveCommand(cfg, commandOrAlias)
+				if err != nil {
+					logging.ErrorAndExit("Failed to run '%s': %v", commandOrAlias, err)
+				}
+
+				// The job runs this same command in the foreground of a new session
+				argv := runArgv(runEnv, runEnvFiles, runOpts.Dir, runTimeout, commandOrAlias, commandArgs)
+				job, err := jobs.Start(commandOrAlias, ref.ProjectName, commandArgs, argv)
+				if err != nil {
+					logging.ErrorAndExit("Failed to start '%s' in the background: %v", commandOrAlias, err)
+				}
+				fmt.Printf("Started job %s (PID %d)\n", job.ID, job.PID)
+				fmt.Printf("Follow its output with: interop jobs logs %s --follow\n", job.ID)
+				return
+			}
+
+			// Detached jobs share the max_concurrent_executions slots with MCP tool calls
+			jobID := jobs.Current()
+			if jobID != "" {
+				slot, err := jobs.Acquire(cmd.Context(), cfg.MaxConcurrentExecutions, commandOrAlias)
+				if err != nil {
+					jobs.Finish(jobID, err)
+					logging.ErrorAndExit("Failed to run '%s': %v", commandOrAlias, err)
+				}
+				defer slot.Release()
+			}
+
+			// Record the run in the history, with a copy of its output when asked
+			output, finish := runOpts.Record(commandOrAlias, commandArgs)
+			runOpts.Output = output
 
 			// Validate configuration and run the command with arguments
-			err := validation.ExecuteCommandWithArgs(cfg, commandOrAlias, commandArgs)
+			err = validation.ExecuteCommandWithOptions(cmd.Context(), cfg, commandOrAlias, commandArgs, runOpts)
+			jobs.Finish(jobID, err)
+			finish(err)
 			if err != nil {
 				logging.ErrorAndExit("Failed to run '%s': %v", commandOrAlias, err)
 			}
 		},
 	}
+	runCmd.Flags().BoolVarP(&runDetach, "detach", "d", false, "Run the command in the background, writing its output to a job log")
+	runCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Print the resolved command line, directory and environment changes without running anything")
+	runCmd.Flags().StringArrayVar(&runWatch, "watch", nil, "Re-run the command when files matching this glob change (repeatable, '**' matches any directories)")
+	runCmd.Flags().BoolVar(&runWatchConfig, "watch-config", false, "Re-run the command when files matching its configured watch globs change")
+	runCmd.Flags().BoolVar(&runRestart, "restart", false, "With --watch, stop a run still going when files change and start it again, for servers and other long-running commands")
+	runCmd.Flags().StringArrayVar(&runEnv, "env", nil, "Set an environment variable for this run (KEY=VALUE, repeatable)")
+	runCmd.Flags().StringArrayVar(&runEnvFiles, "env-file", nil, "Load environment variables for this run from a dotenv file (repeatable)")
+	runCmd.Flags().StringVar(&runCwd, "cwd", "", "Run the command in this directory instead of its default")
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", 0, "Stop the command if it runs longer than this, like 90s or 2m, in place of its configured timeout")
+	runCmd.Flags().BoolVar(&runParallel, "parallel", false, "Run all the commands named, without arguments, at the same time, prefixing each output line with its command")
+	runCmd.Flags().IntVarP(&runJobs, "jobs", "j", 0, "Run at most this many commands at once with --parallel, 0 for all")
+	// Flags after the command name are parsed by argflags, as they can be its arguments
+	runCmd.Flags().SetInterspersed(false)
+	// Help lists the configured commands, or describes the one named
+	defaultHelp := runCmd.HelpFunc()
+	runCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		names := cmd.Flags().Args()
+		if len(names) == 0 {
+			defaultHelp(cmd, args)
+			fmt.Println()
+			display.PrintRunCatalog(cfg)
+			return
+		}
+		if err := display.PrintCommandHelp(cfg, names[0]); err != nil {
+			logging.ErrorAndExit("%v, list the commands with: interop run --help", err)
+		}
+		fmt.Println()
+		fmt.Println("Run Flags:")
+		fmt.Print(argflags.RunFlags(runArguments(cfg, names[0]), cmd.LocalFlags()).FlagUsages())
+	})
 	rootCmd.AddCommand(runCmd)
 
+	// Jobs command group for detached runs
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Manage commands running in the background",
+		Long:  "List, follow and stop commands started with 'interop run --detach'.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	var jobsJSON bool
+	jobsListCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List background jobs, the most recent first",
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			list, err := jobs.List()
+			if err != nil {
+				logging.ErrorAndExit("Failed to list jobs: %v", err)
+			}
+			if format := listFormat(jobsJSON); format.Structured() {
+				if list == nil {
+					list = []*jobs.Job{}
+				}
+				printStructured(format, list)
+				return
+			}
+			display.PrintJobs(list)
+		},
+	}
+	jsonAlias(jobsListCmd, &jobsJSON)
+	jobsCmd.AddCommand(jobsListCmd)
+
+	var followLogs bool
+	jobsLogsCmd := &cobra.Command{
+		Use:   "logs <id>",
+		Short: "Print the output of a background job",
+		Long:  "Print the output of a background job. The ID can be shortened to any unique prefix.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			job, err := jobs.Get(args[0])
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			if err := job.WriteLogs(cmd.Context(), os.Stdout, followLogs); err != nil {
+				logging.ErrorAndExit("Failed to read the logs of job %s: %v", job.ID, err)
+			}
+		},
+	}
+	jobsLogsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "Keep printing output until the job ends")
+	jobsCmd.AddCommand(jobsLogsCmd)
+
+	jobsKillCmd := &cobra.Command{
+		Use:   "kill <id>",
+		Short: "Stop a background job and the processes it started",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			job, err := jobs.Get(args[0])
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			if err := job.Kill(); err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			fmt.Printf("Killed job %s (%s)\n", job.ID, job.Command)
+		},
+	}
+	jobsCmd.AddCommand(jobsKillCmd)
+
+	jobsQueueCmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Show the executions holding a max_concurrent_executions slot and those waiting",
+		Run: func(cmd *cobra.Command, args []string) {
+			running, waiting, err := jobs.Queue()
+			if err != nil {
+				logging.ErrorAndExit("Failed to read the execution queue: %v", err)
+			}
+			if format := outputFormat(); format.Structured() {
+				if running == nil {
+					running = []jobs.Slot{}
+				}
+				if waiting == nil {
+					waiting = []jobs.QueueEntry{}
+				}
+				printStructured(format, struct {
+					MaxConcurrent int               `json:"max_concurrent_executions"`
+					Running       []jobs.Slot       `json:"running"`
+					Waiting       []jobs.QueueEntry `json:"waiting"`
+				}{cfg.MaxConcurrentExecutions, running, waiting})
+				return
+			}
+			display.PrintQueue(running, waiting, cfg.MaxConcurrentExecutions)
+		},
+	}
+	jobsCmd.AddCommand(jobsQueueCmd)
+	rootCmd.AddCommand(jobsCmd)
+
+	// History command group for recorded runs
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show and repeat earlier runs",
+		Long:  "List, inspect and re-run the commands run with 'interop run', recorded unless history.disabled is set.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	var historyJSON bool
+	var historyLimit int
+	historyListCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List recorded runs, the most recent first",
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			list, err := history.List()
+			if err != nil {
+				logging.ErrorAndExit("Failed to read the history: %v", err)
+			}
+			if historyLimit > 0 && len(list) > historyLimit {
+				list = list[:historyLimit]
+			}
+			if format := listFormat(historyJSON); format.Structured() {
+				if list == nil {
+					list = []*history.Entry{}
+				}
+				printStructured(format, list)
+				return
+			}
+			display.PrintHistory(list)
+		},
+	}
+	jsonAlias(historyListCmd, &historyJSON)
+	historyListCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Show at most this many runs, 0 for all")
+	historyCmd.AddCommand(historyListCmd)
+
+	historyShowCmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a recorded run and its captured output",
+		Long:  "Show a recorded run and its output, when capture_output is set. The ID can be shortened to any unique prefix.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entry, err := history.Get(args[0])
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			display.PrintHistoryEntry(entry)
+			if entry.OutputFile == "" {
+				return
+			}
+			fmt.Println()
+			fmt.Println("Output:")
+			if err := entry.WriteOutput(os.Stdout); err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+		},
+	}
+	historyCmd.AddCommand(historyShowCmd)
+
+	historyRerunCmd := &cobra.Command{
+		Use:   "rerun <id>",
+		Short: "Run a recorded run again with the same arguments and options",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entry, err := history.Get(args[0])
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			executable, err := os.Executable()
+			if err != nil {
+				logging.ErrorAndExit("Failed to get executable path: %v", err)
+			}
+			if err := entry.Rerun(cmd.Context(), executable); err != nil {
+				// A run that exited reported its own failure
+				if code := execution.ExitCode(err); code > 0 {
+					os.Exit(code)
+				}
+				logging.ErrorAndExit("Failed to re-run %s: %v", entry.ID, err)
+			}
+		},
+	}
+	historyCmd.AddCommand(historyRerunCmd)
+	rootCmd.AddCommand(historyCmd)
+
+	// Secrets command group for the keychain values of secret:// env references
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage secrets referenced from env values",
+		Long: `Store, read and list the secrets env values refer to with secret://keychain/<name>.
+Secrets are kept in the OS keychain and read when a command runs, so they
+don't have to be written to the settings files.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	secretsSetCmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Store a secret in the keychain",
+		Long:  "Store a secret in the keychain, replacing the current value. The value is read from standard input, without echo on a terminal.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := secrets.ValidateName(args[0]); err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+
+			var value []byte
+			var err error
+			if isTerminal(os.Stdin) {
+				fmt.Fprintf(os.Stderr, "Value of %s: ", args[0])
+				value, err = term.ReadPassword(os.Stdin.Fd())
+				fmt.Fprintln(os.Stderr)
+			} else {
+				value, err = io.ReadAll(os.Stdin)
+			}
+			if err != nil {
+				logging.ErrorAndExit("Failed to read the secret: %v", err)
+			}
+			// A value piped from echo or a file ends with its line break
+			secret := strings.TrimSuffix(strings.TrimSuffix(string(value), "\n"), "\r")
+			if secret == "" {
+				logging.ErrorAndExit("The secret is empty, nothing was stored")
+			}
+
+			if err := secrets.Set(args[0], secret); err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			fmt.Printf("Stored secret '%s', refer to it with secret://keychain/%s\n", args[0], args[0])
+		},
+	}
+	secretsCmd.AddCommand(secretsSetCmd)
+
+	secretsGetCmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "Print a secret stored in the keychain",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			value, err := secrets.Get(args[0])
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			fmt.Println(value)
+		},
+	}
+	secretsCmd.AddCommand(secretsGetCmd)
+
+	secretsListCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the secret references of the settings and whether they're stored",
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := settings.Load()
+			if err != nil {
+				logging.ErrorAndExit("Failed to load settings: %v", err)
+			}
+
+			statuses := []display.SecretStatus{}
+			for _, use := range settings.SecretReferences(cfg) {
+				status := display.SecretStatus{SecretUse: use}
+				if ref, err := secrets.ParseReference(use.Reference); err != nil {
+					status.Error = err.Error()
+				} else if _, err := secrets.Get(ref.Name); err == nil {
+					status.Stored = true
+				} else if !errors.Is(err, secrets.ErrNotFound) {
+					status.Error = err.Error()
+				}
+				statuses = append(statuses, status)
+			}
+
+			if format := outputFormat(); format.Structured() {
+				printStructured(format, statuses)
+				return
+			}
+			display.PrintSecrets(statuses)
+		},
+	}
+	secretsCmd.AddCommand(secretsListCmd)
+
+	secretsDeleteCmd := &cobra.Command{
+		Use:     "delete <name>",
+		Short:   "Remove a secret from the keychain",
+		Aliases: []string{"rm"},
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := secrets.Delete(args[0]); err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			fmt.Printf("Deleted secret '%s'\n", args[0])
+		},
+	}
+	secretsCmd.AddCommand(secretsDeleteCmd)
+	rootCmd.AddCommand(secretsCmd)
+
+	// Cache command group for cached command results
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage cached command results",
+		Long:  "Manage the results MCP servers cache for commands with 'cache' or 'cache_ttl' set.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cacheClearCmd := &cobra.Command{
+		Use:   "clear [command]",
+		Short: "Remove the cached results of a command, or of all commands",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var command string
+			if len(args) > 0 {
+				command = args[0]
+			}
+			removed, err := cache.Clear(command)
+			if err != nil {
+				logging.ErrorAndExit("Failed to clear the cache: %v", err)
+			}
+			fmt.Printf("Removed %d cached result(s)\n", removed)
+		},
+	}
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+
+	// Export command group for describing the configuration to other tools
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the configuration for other tools",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	var schemaFormat string
+	exportSchemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Export the command catalog as an OpenAPI or JSON Schema document",
+		Long: `Describe every enabled command with the schema of its arguments.
+
+Formats:
+  openapi (default): An OpenAPI 3.1 document with a POST /commands/<name> operation per command
+  jsonschema: A JSON Schema document defining each command's arguments object under $defs`,
+		Example: `  interop export schema > interop.openapi.json
+  interop export schema --format jsonschema`,
+		Run: func(cmd *cobra.Command, args []string) {
+			document, err := schema.Export(cfg, schemaFormat, version)
+			if err != nil {
+				logging.ErrorAndExit("Failed to export the schema: %v", err)
+			}
+			fmt.Println(string(document))
+		},
+	}
+	exportSchemaCmd.Flags().StringVar(&schemaFormat, "format", schema.FormatOpenAPI, "Document format (openapi or jsonschema)")
+	exportCmd.AddCommand(exportSchemaCmd)
+	rootCmd.AddCommand(exportCmd)
+
+	// Alias command group for using interop aliases outside of interop run
+	aliasCmd := &cobra.Command{
+		Use:   "alias",
+		Short: "Use interop aliases as shell commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	var shellenvCommands []string
+	aliasShellenvCmd := &cobra.Command{
+		Use:   "shellenv",
+		Short: "Print shell functions running project aliases through interop",
+		Long: `Print bash and zsh functions for every project alias, and for the
+commands given with --command, each running 'interop run' with its name and
+arguments. Short commands keep working while env, hooks and the project of the
+working directory apply as in 'interop run'.
+
+Add the eval line below to .bashrc or .zshrc to load them in new shells.
+The functions take precedence over programs of the same name.`,
+		Example: `  eval "$(interop alias shellenv)"
+  eval "$(interop alias shellenv --command build --command test)"`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			script, err := shellenv.Generate(cfg, shellenvCommands)
+			if err != nil {
+				logging.ErrorAndExit("Failed to generate shell functions: %v", err)
+			}
+			fmt.Print(script)
+		},
+	}
+	aliasShellenvCmd.Flags().StringArrayVar(&shellenvCommands, "command", nil, "Also define a function for this command (repeatable)")
+	aliasShellenvCmd.RegisterFlagCompletionFunc("command", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return commandCompletions(cfg), cobra.ShellCompDirectiveNoFileComp
+	})
+	aliasCmd.AddCommand(aliasShellenvCmd)
+	rootCmd.AddCommand(aliasCmd)
+
+	var queryRaw bool
+	queryCmd := &cobra.Command{
+		Use:   "query EXPRESSION",
+		Short: "Query the loaded configuration with a JMESPath expression",
+		Long: `Evaluate a JMESPath expression against the loaded configuration and print
+the result as JSON, so scripts and CI can read it without parsing other output.
+
+The data uses the keys of settings.toml. commands, projects, project_templates,
+prompts and mcp_servers are lists sorted by name, each entry with its "name",
+and commands, projects and prompts with the "source" file and line they were
+defined at.
+
+The whole JMESPath language and its built-in functions are supported, see
+https://jmespath.org/specification.html.`,
+		Example: `  interop query "commands[?mcp=='build'].name"
+  interop query --raw 'projects[*].path'
+  interop query 'commands[?contains(tags || ` + "`[]`" + `, ` + "'go'" + `)].{name: name, source: source}'
+  interop query 'length(commands[?!is_enabled])'`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			expression, err := query.Compile(args[0])
+			if err != nil {
+				logging.ErrorAndExit("Invalid query: %v", err)
+			}
+			document, err := query.Document(cfg)
+			if err != nil {
+				logging.ErrorAndExit("Failed to read the configuration: %v", err)
+			}
+			result, err := expression.Search(document)
+			if err != nil {
+				logging.ErrorAndExit("Query failed: %v", err)
+			}
+			output, err := query.Format(result, queryRaw)
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+			if output != "" {
+				fmt.Println(output)
+			}
+		},
+	}
+	queryCmd.Flags().BoolVarP(&queryRaw, "raw", "r", false, "Print strings without quotes and lists of strings or numbers one per line")
+	rootCmd.AddCommand(queryCmd)
+
+	var applyFile string
+	var applyDryRun bool
+	applyCmd := &cobra.Command{
+		Use:   "apply -f FILE",
+		Short: "Apply a document of changes to the configuration",
+		Long: `Apply a TOML document of changes to settings.toml and the local command
+directories in one transaction, for automation that proposes config changes.
+
+The document can add or replace commands, remove them along with their
+project bindings, bind and unbind commands in projects, and set or unset env
+variables:
+
+  remove_commands = ["old-build"]
+  unset_env = ["DEBUG"]
+
+  [commands.lint]          # Same keys as in settings.toml
+  cmd = "golangci-lint run"
+
+  [[bind]]                 # Also [[unbind]], alias is optional
+  project = "api"
+  command = "lint"
+  alias = "l"
+
+  [env]                    # Global env variables to set
+  GOFLAGS = "-mod=mod"
+
+  [project_env.api]        # Env variables to set in a project
+  PORT = "8080"
+
+Nothing is written unless every change can be made, and the files are
+restored when the updated configuration fails to load or has validation
+errors it didn't have before. Commands from remotes can't be changed.`,
+		Example: `  interop apply -f changes.toml
+  bot-propose-changes | interop apply -f -
+  interop apply -f changes.toml --dry-run`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var data []byte
+			var err error
+			if applyFile == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(applyFile)
+			}
+			if err != nil {
+				logging.ErrorAndExit("Failed to read the changes: %v", err)
+			}
+			changes, err := settings.ParseChanges(string(data))
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+
+			before := validation.ValidateAll(cfg)
+			check := func(updated *settings.Settings) error {
+				introduced := validation.NewSevereErrors(before, validation.ValidateAll(updated))
+				if len(introduced) == 0 {
+					return nil
+				}
+				messages := make([]string, len(introduced))
+				for i, err := range introduced {
+					messages[i] = "  " + err.Message
+				}
+				return fmt.Errorf("the changes cause validation errors:\n%s", strings.Join(messages, "\n"))
+			}
+			result, err := settings.Apply(changes, check, applyDryRun)
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+
+			for _, change := range result.Changes {
+				fmt.Printf("  %s\n", change)
+			}
+			if applyDryRun {
+				fmt.Printf("Dry run, %d files would be written; validation runs when the changes are applied\n", len(result.Files))
+				return
+			}
+			fmt.Printf("Applied %d changes to %s\n", len(result.Changes), strings.Join(result.Files, ", "))
+		},
+	}
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", "", "File holding the changes, - for stdin")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Check the changes and print them without writing anything")
+	applyCmd.MarkFlagRequired("file")
+	rootCmd.AddCommand(applyCmd)
+
 	// Add Config command group
 	configCmd := &cobra.Command{
 		Use:     "config",
@@ -145,16 +1056,54 @@ func main() {
 		},
 	}
 
-	// Define flag variable for the editor
-	var editorName string
+	// Define flag variables for the editor and file creation
+	var editorName, editTemplate string
+	var editCreate bool
 
 	// Config edit command (moved from root level)
 	configEditCmd := &cobra.Command{
-		Use:     "edit",
-		Short:   "Edit the configuration folder with your default editor or specified editor",
-		Long:    "Open the entire interop configuration folder using the editor specified by --editor flag, $EDITOR environment variable, VS Code, or your OS file browser as fallback.",
-		Aliases: []string{"e"},
+		Use:   "edit [settings|FILE|command|project|prompt NAME]",
+		Short: "Edit the configuration folder with your default editor or specified editor",
+		Long: `Open the entire interop configuration folder using the editor specified by --editor flag, $EDITOR environment variable, VS Code, or your OS file browser as fallback.
+
+Given a command, project or prompt, open the file defining it at its line instead,
+falling back to the folder when the file isn't known.
+
+Given a file, open that file: "settings" for settings.toml or a TOML file relative
+to the configuration folder, like config.d/team.toml. With --create a missing file
+is first created from a commented template, the command one unless --template
+names another.`,
+		Example: `  interop config edit
+  interop config edit settings
+  interop config edit config.d/team.toml --create
+  interop config edit config.d/prompts.toml --create --template prompt
+  interop config edit command build
+  interop config edit project app --editor vim`,
+		Aliases:     []string{"e"},
+		Annotations: map[string]string{brokenConfigAnnotation: "true"},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 2 {
+				return fmt.Errorf("expected no arguments, a file, or an entity type and name, got %d arguments", len(args))
+			}
+			if editCreate && len(args) != 1 {
+				return fmt.Errorf("--create needs the file to create")
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			switch len(args) {
+			case 1:
+				if err := edit.OpenConfigFile(editorName, args[0], editCreate, editTemplate); err != nil {
+					logging.ErrorAndExit("Failed to open %s: %v", args[0], err)
+				}
+				return
+			case 2:
+				if err := edit.OpenEntity(cfg, editorName, args[0], args[1]); err != nil {
+					logging.ErrorAndExit("Failed to open %s '%s': %v", args[0], args[1], err)
+				}
+				return
+			}
+
 			err := edit.OpenConfigFolder(editorName)
 			if err != nil {
 				logging.ErrorAndExit("Failed to open config folder: %v", err)
@@ -164,7 +1113,9 @@ func main() {
 	}
 
 	// Add the --editor flag to the config edit command
-	configEditCmd.Flags().StringVar(&editorName, "editor", "", "Editor to use for opening the configuration folder (e.g., code, vim, nano)")
+	configEditCmd.Flags().StringVar(&editorName, "editor", "", "Editor to use for opening the configuration (e.g., code, vim, nano)")
+	configEditCmd.Flags().BoolVar(&editCreate, "create", false, "Create the file from a template when it doesn't exist")
+	configEditCmd.Flags().StringVar(&editTemplate, "template", "command", fmt.Sprintf("Template of a created file (%s)", strings.Join(edit.Templates, ", ")))
 	configCmd.AddCommand(configEditCmd)
 
 	// Add Remote command group under config
@@ -178,6 +1129,7 @@ func main() {
 	}
 
 	// Remote add command
+	var remoteTransport remote.TransportOptions
 	remoteAddCmd := &cobra.Command{
 		Use:     "add <n> <url>",
 		Short:   "Add a named remote repository",
@@ -196,13 +1148,16 @@ func main() {
 			}
 
 			remoteMgr := remote.NewManager()
-			if err := remoteMgr.Add(name, url); err != nil {
+			if err := remoteMgr.Add(name, url, remoteTransport); err != nil {
 				logging.ErrorAndExit("Failed to add remote '%s': %v", name, err)
 			}
 
 			logging.Info("Successfully added remote '%s' with URL: %s", name, url)
 		},
 	}
+	remoteAddCmd.Flags().StringVar(&remoteTransport.HTTPProxy, "http-proxy", "", "Proxy URL to use when cloning this remote over HTTP(S)")
+	remoteAddCmd.Flags().BoolVar(&remoteTransport.NoVerifyTLS, "no-verify-tls", false, "Skip TLS certificate verification for this remote")
+	remoteAddCmd.Flags().StringVar(&remoteTransport.CABundle, "ca-bundle", "", "PEM file with additional CA certificates trusted for this remote")
 	remoteCmd.AddCommand(remoteAddCmd)
 
 	// Remote remove command
@@ -244,6 +1199,7 @@ func main() {
 	remoteCmd.AddCommand(remoteShowCmd)
 
 	// Remote fetch command
+	var conflictStrategy string
 	remoteFetchCmd := &cobra.Command{
 		Use:     "fetch [name]",
 		Short:   "Fetch configuration from remote repositories",
@@ -256,8 +1212,13 @@ func main() {
 				remoteName = args[0]
 			}
 
+			strategy, err := remote.ParseConflictStrategy(conflictStrategy)
+			if err != nil {
+				logging.ErrorAndExit("Invalid --strategy: %v", err)
+			}
+
 			remoteMgr := remote.NewManager()
-			if err := remoteMgr.Fetch(remoteName); err != nil {
+			if err := remoteMgr.Fetch(remoteName, strategy); err != nil {
 				logging.ErrorAndExit("Failed to fetch from remote: %v", err)
 			}
 
@@ -268,6 +1229,7 @@ func main() {
 			}
 		},
 	}
+	remoteFetchCmd.Flags().StringVar(&conflictStrategy, "strategy", "", "Resolve conflicts with local commands without asking: local, remote, or rename")
 	remoteCmd.AddCommand(remoteFetchCmd)
 
 	// Remote clear command
@@ -286,6 +1248,38 @@ func main() {
 	}
 	remoteCmd.AddCommand(remoteClearCmd)
 
+	// Remote scaffold command
+	var scaffoldOpts remote.ScaffoldOptions
+	remoteScaffoldCmd := &cobra.Command{
+		Use:   "scaffold <dir>",
+		Short: "Create the skeleton of a repository to share commands from",
+		Long:  "Create config.d/ with an example command, executables/ with the script it runs, manifest.toml and a README in a directory, ready to be pushed and added as a remote by others. Existing files are never overwritten.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteMgr := remote.NewManager()
+			created, err := remoteMgr.Scaffold(args[0], scaffoldOpts)
+			if err != nil && len(created) == 0 {
+				logging.ErrorAndExit("Failed to scaffold a remote repository: %v", err)
+			}
+			for _, path := range created {
+				fmt.Printf("Created %s\n", path)
+			}
+			if err != nil {
+				logging.ErrorAndExit("Failed to initialize a git repository: %v", err)
+			}
+			if scaffoldOpts.GitInit {
+				fmt.Printf("Initialized a git repository in %s\n", args[0])
+			}
+			fmt.Println()
+			fmt.Println("Next steps:")
+			fmt.Println("  Edit config.d/example.toml and executables/hello.sh, then commit and push")
+			fmt.Println("  Teammates add it with: interop config remote add <name> <url>")
+		},
+	}
+	remoteScaffoldCmd.Flags().StringVar(&scaffoldOpts.Name, "name", "", "Name of the repository in manifest.toml and the README (default: the directory's name)")
+	remoteScaffoldCmd.Flags().BoolVar(&scaffoldOpts.GitInit, "git-init", false, "Run git init in the directory")
+	remoteCmd.AddCommand(remoteScaffoldCmd)
+
 	// Add remote command to config command
 	configCmd.AddCommand(remoteCmd)
 
@@ -309,6 +1303,9 @@ func main() {
 	var serverName string
 	var serverMode string
 	var remoteURL string
+	var mcpNoCache bool
+	var startOnlyUsed bool
+	var startRecord bool
 
 	// MCP start command
 	mcpStartCmd := &cobra.Command{
@@ -321,6 +1318,8 @@ SSE Mode (default):
   - Communicates via HTTP on configured ports
   - Supports multiple named servers
   - Use --all flag to start all configured servers
+  - --all skips servers with autostart = false, and with --only-used
+    servers without enabled commands or prompts
 
 Stdio Mode:
   - Runs in foreground and communicates via stdin/stdout
@@ -337,6 +1336,8 @@ Remote Mode:
   
 Examples:
   interop mcp start                    # Start all servers in SSE mode
+  interop mcp start --only-used        # Start the servers that have commands or prompts
+  interop mcp start myserver --record  # Record the session in ~/.config/interop/mcp/sessions
   interop mcp start --mode stdio       # Start default server in stdio mode
   interop mcp start myserver --mode stdio # Start named server in stdio mode
   interop mcp start myserver --mode sse # Start named server in SSE mode
@@ -365,12 +1366,20 @@ Examples:
 				os.Setenv("MCP_REMOTE_URL", remoteURL)
 			}
 
+			// Servers inherit the environment, so they all skip the cache
+			if mcpNoCache {
+				os.Setenv("MCP_NO_CACHE", "1")
+			}
+			if startRecord {
+				os.Setenv("MCP_RECORD_SESSION", "1")
+			}
+
 			// For SSE mode, default to all servers if no specific server is specified
 			if serverMode != "stdio" && !startAllServers && serverName == "" {
 				startAllServers = true
 			}
 
-			if err := mcp.StartServer(serverName, startAllServers); err != nil {
+			if err := mcp.StartServer(serverName, startAllServers, startOnlyUsed); err != nil {
 				logging.ErrorAndExit("Failed to start MCP server: %v", err)
 			}
 			logging.Info("MCP server(s) started.")
@@ -380,6 +1389,9 @@ Examples:
 	mcpStartCmd.Flags().StringVarP(&serverName, "server", "s", "", "Specific MCP server to start")
 	mcpStartCmd.Flags().StringVar(&serverMode, "mode", "sse", "Server mode (stdio or sse)")
 	mcpStartCmd.Flags().StringVar(&remoteURL, "remote", "", "Remote repository URL to fetch commands from dynamically")
+	mcpStartCmd.Flags().BoolVar(&mcpNoCache, "no-cache", false, "Always run commands, ignoring results cached by commands with cache enabled")
+	mcpStartCmd.Flags().BoolVar(&startOnlyUsed, "only-used", false, "When starting all servers, skip those without enabled commands or prompts")
+	mcpStartCmd.Flags().BoolVar(&startRecord, "record", false, "Record the servers' requests and responses for mcp replay, with secrets redacted")
 	mcpCmd.AddCommand(mcpStartCmd)
 
 	// MCP stop command
@@ -457,6 +1469,15 @@ Examples:
 				serverName = args[0]
 			}
 
+			if format := outputFormat(); format.Structured() {
+				statuses, err := mcp.GetStatuses(serverName)
+				if err != nil {
+					logging.ErrorAndExit("Failed to get MCP server status: %v", err)
+				}
+				printStructured(format, statuses)
+				return
+			}
+
 			status, err := mcp.GetStatus(serverName, statusAllServers)
 			if err != nil {
 				logging.ErrorAndExit("Failed to get MCP server status: %v", err)
@@ -473,6 +1494,15 @@ Examples:
 		Use:   "list",
 		Short: "List all configured MCP servers and their commands",
 		Run: func(cmd *cobra.Command, args []string) {
+			if format := outputFormat(); format.Structured() {
+				listings, err := mcp.ListMCPServerListings()
+				if err != nil {
+					logging.ErrorAndExit("Failed to list MCP servers: %v", err)
+				}
+				printStructured(format, listings)
+				return
+			}
+
 			result, err := mcp.ListMCPServers()
 			if err != nil {
 				logging.ErrorAndExit("Failed to list MCP servers: %v", err)
@@ -495,7 +1525,13 @@ Modes:
 Examples:
   interop mcp export                  # Export SSE configuration (HTTP URLs)
   interop mcp export --mode sse       # Export SSE configuration (HTTP URLs)  
-  interop mcp export --mode stdio     # Export stdio configuration (command lines)`,
+  interop mcp export --mode stdio     # Export stdio configuration (command lines)
+  interop mcp export --merge-into ~/Library/Application\ Support/Claude/claude_desktop_config.json
+  interop mcp export --merge-into .cursor/mcp.json --remove   # Remove interop entries
+
+With --merge-into, the servers are written into the file's "mcpServers" object instead of
+being printed. Other keys and servers are kept, interop entries for servers that no longer
+exist are removed, and the previous file is saved next to it with a .bak suffix.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Get the mode flag value, default to "sse"
 			mode, _ := cmd.Flags().GetString("mode")
@@ -503,6 +1539,20 @@ Examples:
 				mode = "sse"
 			}
 
+			mergeInto, _ := cmd.Flags().GetString("merge-into")
+			remove, _ := cmd.Flags().GetBool("remove")
+			if remove && mergeInto == "" {
+				logging.ErrorAndExit("--remove requires --merge-into")
+			}
+			if mergeInto != "" {
+				result, err := mcp.MergeMCPConfigInto(mergeInto, mode, remove)
+				if err != nil {
+					logging.ErrorAndExit("Failed to merge MCP configuration: %v", err)
+				}
+				fmt.Println(result)
+				return
+			}
+
 			var result string
 			var err error
 
@@ -520,9 +1570,12 @@ Examples:
 		},
 	}
 	mcpExportCmd.Flags().String("mode", "sse", "Export mode (stdio or sse)")
+	mcpExportCmd.Flags().String("merge-into", "", "Merge the servers into this client config file (e.g. Claude Desktop or .cursor/mcp.json)")
+	mcpExportCmd.Flags().Bool("remove", false, "With --merge-into, remove all interop servers from the file")
 	mcpCmd.AddCommand(mcpExportCmd)
 
 	// MCP prompts command
+	var promptsJSON bool
 	mcpPromptsCmd := &cobra.Command{
 		Use:   "prompts",
 		Short: "List all configured prompts",
@@ -533,8 +1586,35 @@ Examples:
 				logging.ErrorAndExit("Failed to load settings: %v", err)
 			}
 
+			if format := listFormat(promptsJSON); format.Structured() {
+				names := make([]string, 0, len(cfg.Prompts))
+				for name := range cfg.Prompts {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				type promptInfo struct {
+					settings.PromptConfig
+					Issues []string `json:"issues,omitempty"`
+				}
+				prompts := make([]promptInfo, 0, len(names))
+				for _, name := range names {
+					prompt := cfg.Prompts[name]
+					if prompt.Name == "" {
+						prompt.Name = name
+					}
+					info := promptInfo{PromptConfig: prompt}
+					for _, issue := range validation.PromptIssues(cfg, name, prompt) {
+						info.Issues = append(info.Issues, issue.Message)
+					}
+					prompts = append(prompts, info)
+				}
+				printStructured(format, prompts)
+				return
+			}
+
 			if len(cfg.Prompts) == 0 {
-				fmt.Println("No prompts configured.")
+				display.PrintNoItemsFound("prompts")
 				return
 			}
 
@@ -546,13 +1626,19 @@ Examples:
 				fmt.Printf("Name: %s\n", name)
 				fmt.Printf("Description: %s\n", prompt.Description)
 
-				if prompt.MCP != "" {
+				if len(prompt.MCP) > 0 {
 					fmt.Printf("MCP Server: %s\n", prompt.MCP)
 				} else {
 					fmt.Printf("MCP Server: default\n")
 				}
 
 				fmt.Printf("Content:\n%s\n", prompt.Content)
+				if len(prompt.Includes) > 0 {
+					fmt.Printf("Includes: %s\n", strings.Join(prompt.Includes, ", "))
+				}
+				if len(prompt.Suggested) > 0 {
+					fmt.Printf("Suggested Tools: %s\n", strings.Join(prompt.Suggested, ", "))
+				}
 
 				if len(prompt.Arguments) > 0 {
 					fmt.Printf("Arguments:\n")
@@ -577,10 +1663,72 @@ Examples:
 					}
 				}
 
+				if issues := validation.PromptIssues(cfg, name, prompt); len(issues) > 0 {
+					fmt.Printf("Issues:\n")
+					for _, issue := range issues {
+						severity := "Warning"
+						if issue.Severe {
+							severity = "Error"
+						}
+						fmt.Printf("  [%s] %s\n", severity, issue.Message)
+					}
+				}
+
 				fmt.Println()
 			}
 		},
 	}
+	jsonAlias(mcpPromptsCmd, &promptsJSON)
+
+	// MCP prompts render command
+	var renderArgs []string
+	var renderJSON bool
+	mcpPromptsRenderCmd := &cobra.Command{
+		Use:   "render <name>",
+		Short: "Render a prompt with its arguments substituted",
+		Long:  "Render a configured prompt the same way the MCP server does, so it can be piped into other tools. Arguments are given as --arg name=value.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := settings.Load()
+			if err != nil {
+				logging.ErrorAndExit("Failed to load settings: %v", err)
+			}
+
+			name := args[0]
+			prompt, exists := cfg.Prompts[name]
+			if !exists {
+				logging.ErrorAndExit("Prompt '%s' not found", name)
+			}
+
+			promptArgs := make(map[string]string)
+			for _, arg := range renderArgs {
+				key, value, ok := strings.Cut(arg, "=")
+				if !ok || key == "" {
+					logging.ErrorAndExit("Invalid argument '%s', expected name=value", arg)
+				}
+				promptArgs[key] = value
+			}
+
+			texts, err := settings.RenderPromptChain(cfg.Prompts, name, promptArgs)
+			if err != nil {
+				logging.ErrorAndExit("Failed to render prompt '%s': %v", name, err)
+			}
+			content := strings.Join(texts, "\n\n")
+
+			if format := listFormat(renderJSON); format.Structured() {
+				printStructured(format, map[string]string{
+					"name":        name,
+					"description": prompt.Description,
+					"content":     content,
+				})
+				return
+			}
+			fmt.Println(content)
+		},
+	}
+	mcpPromptsRenderCmd.Flags().StringArrayVar(&renderArgs, "arg", nil, "Prompt argument as name=value (repeatable)")
+	jsonAlias(mcpPromptsRenderCmd, &renderJSON)
+	mcpPromptsCmd.AddCommand(mcpPromptsRenderCmd)
 	mcpCmd.AddCommand(mcpPromptsCmd)
 
 	// Hidden daemon command for internal use
@@ -594,27 +1742,231 @@ Examples:
 			}
 		},
 	}
+	// The server runs from MCP_SERVER_NAME, these only identify the daemon for mcp ps
+	mcpDaemonCmd.Flags().String("server", "", "Server the daemon runs")
+	mcpDaemonCmd.Flags().String("app-dir", "", "Configuration directory of the daemon")
 	mcpCmd.AddCommand(mcpDaemonCmd)
 
+	// MCP ps command
+	var psKillOrphans, psJSON bool
+	mcpPsCmd := &cobra.Command{
+		Use:   "ps",
+		Short: "List running MCP daemons and reconcile them with the servers' PID files",
+		Long: `List the MCP daemons of this configuration running on this machine.
+
+PID files whose process is gone, or whose PID now belongs to another process, are removed.
+A daemon whose PID file was lost is tracked again when its server has no other daemon;
+the remaining orphans are listed, and terminated with --kill-orphans.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			entries, err := mcp.ReconcileProcesses(psKillOrphans)
+			if err != nil {
+				logging.ErrorAndExit("Failed to list MCP daemons: %v", err)
+			}
+			if format := listFormat(psJSON); format.Structured() {
+				if entries == nil {
+					entries = []mcp.ProcessEntry{}
+				}
+				printStructured(format, entries)
+				return
+			}
+			fmt.Println(mcp.FormatProcesses(entries))
+		},
+	}
+	mcpPsCmd.Flags().BoolVar(&psKillOrphans, "kill-orphans", false, "Terminate daemons that no server tracks")
+	jsonAlias(mcpPsCmd, &psJSON)
+	mcpCmd.AddCommand(mcpPsCmd)
+
 	// MCP events command
+	var eventsEndpoint string
+	var eventsRetries int
+	var eventsBackoff, eventsMaxBackoff time.Duration
+	var eventsQuietHeartbeat, eventsJSON bool
 	mcpToolsEventsCmd := &cobra.Command{
 		Use:   "events [server-name]",
 		Short: "Stream real-time events from an MCP server",
-		Long:  "Stream real-time events from the default MCP server or a specific named server",
+		Long: `Stream real-time events from the default MCP server or a specific named server.
+Dropped connections are reconnected with exponential backoff, resuming from the last
+event ID received. Defaults for the flags can be set in the [events] settings section.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// If server name is provided as an argument, override the flag
 			if len(args) > 0 {
 				serverName = args[0]
 			}
 
-			if err := mcp.StreamServerEvents(serverName); err != nil {
+			cfg, err := settings.Load()
+			if err != nil {
+				logging.ErrorAndExit("Failed to load settings: %v", err)
+			}
+			opts, err := mcp.EventStreamOptionsFromConfig(cfg.Events)
+			if err != nil {
+				logging.ErrorAndExit("%v", err)
+			}
+
+			// Flags override the configured defaults
+			flags := cmd.Flags()
+			if flags.Changed("endpoint") {
+				opts.Endpoint = eventsEndpoint
+			}
+			if flags.Changed("retries") {
+				opts.Retries = eventsRetries
+			}
+			if flags.Changed("backoff") {
+				opts.Backoff = eventsBackoff
+			}
+			if flags.Changed("max-backoff") {
+				opts.MaxBackoff = eventsMaxBackoff
+			}
+			if flags.Changed("quiet-heartbeat") {
+				opts.QuietHeartbeat = eventsQuietHeartbeat
+			}
+			opts.JSON = eventsJSON
+			if opts.Backoff <= 0 || opts.MaxBackoff <= 0 {
+				logging.ErrorAndExit("--backoff and --max-backoff must be positive")
+			}
+
+			if err := mcp.StreamServerEvents(serverName, opts); err != nil {
 				logging.ErrorAndExit("Failed to stream events: %v", err)
 			}
 		},
 	}
 	mcpToolsEventsCmd.Flags().StringVarP(&serverName, "server", "s", "", "Specific MCP server to stream events from")
+	mcpToolsEventsCmd.Flags().StringVar(&eventsEndpoint, "endpoint", "", "SSE endpoint path (default: try /mcp, /events and /sse)")
+	mcpToolsEventsCmd.Flags().IntVar(&eventsRetries, "retries", mcp.DefaultEventRetries, "Failed connection attempts in a row before giving up, -1 for no limit")
+	mcpToolsEventsCmd.Flags().DurationVar(&eventsBackoff, "backoff", mcp.DefaultEventBackoff, "Delay before the first retry, doubled after each failure")
+	mcpToolsEventsCmd.Flags().DurationVar(&eventsMaxBackoff, "max-backoff", mcp.DefaultEventMaxBackoff, "Longest delay between retries")
+	mcpToolsEventsCmd.Flags().BoolVar(&eventsQuietHeartbeat, "quiet-heartbeat", false, "Don't print heartbeat events")
+	mcpToolsEventsCmd.Flags().BoolVar(&eventsJSON, "json", false, "Print each event as a JSON line with time, id, event and data")
 	mcpCmd.AddCommand(mcpToolsEventsCmd)
 
+	// MCP tools command
+	var toolsOpts mcp.ClientOptions
+	mcpToolsCmd := &cobra.Command{
+		Use:   "tools [server-name]",
+		Short: "List the tools an MCP server serves",
+		Long:  "Connect to the default MCP server or a specific named server over MCP and list its tools with their arguments.",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 {
+				serverName = args[0]
+			}
+
+			result, err := mcp.ListServerTools(serverName, toolsOpts)
+			if err != nil {
+				logging.ErrorAndExit("Failed to list tools: %v", err)
+			}
+			fmt.Println(result)
+		},
+	}
+	mcpToolsCmd.Flags().StringVarP(&serverName, "server", "s", "", "Specific MCP server to list tools of")
+	mcpToolsCmd.Flags().BoolVar(&toolsOpts.JSON, "json", false, "Output tools with their input schemas as JSON")
+	mcpToolsCmd.Flags().BoolVar(&toolsOpts.Stdio, "stdio", false, "Spawn the server in stdio mode instead of connecting to the running one")
+	mcpCmd.AddCommand(mcpToolsCmd)
+
+	// MCP call command
+	var callOpts mcp.ClientOptions
+	mcpCallCmd := &cobra.Command{
+		Use:   "call <server-name> <tool> [args...]",
+		Short: "Call a tool on an MCP server",
+		Long: `Call a tool on a running MCP server, or on one spawned in stdio mode with --stdio,
+the way an MCP client does, to see exactly what the client gets. Use "default" as the
+server name for the default server. Arguments are given like to run: name=value, or
+values filling the command's arguments in order. Values are converted to the argument
+types the tool declares; arrays and objects are given as JSON.`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			output, isError, err := mcp.CallServerTool(args[0], args[1], args[2:], callOpts)
+			if err != nil {
+				logging.ErrorAndExit("Failed to call tool: %v", err)
+			}
+			fmt.Println(output)
+			if isError {
+				os.Exit(1)
+			}
+		},
+	}
+	mcpCallCmd.Flags().BoolVar(&callOpts.JSON, "json", false, "Output the full MCP result as JSON")
+	mcpCallCmd.Flags().BoolVar(&callOpts.Stdio, "stdio", false, "Spawn the server in stdio mode instead of connecting to the running one")
+	mcpCmd.AddCommand(mcpCallCmd)
+
+	var replayServer string
+	mcpReplayCmd := &cobra.Command{
+		Use:   "replay <session-file>",
+		Short: "Call the tools of a recorded session again and compare the responses",
+		Long: `Make the tool calls of a session recorded with mcp start --record again, on a
+server spawned in stdio mode with the current configuration, and report the calls
+whose responses differ from the recorded ones. Use it to check how a configuration
+change affects what real clients asked for. Exits with status 1 when any changed.
+
+Arguments recorded as [REDACTED] are replayed as such.`,
+		Example: `  interop mcp replay ~/.config/interop/mcp/sessions/default-20261016-091203.jsonl
+  interop mcp replay session.jsonl --server staging`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			report, unchanged, err := mcp.ReplaySession(args[0], replayServer)
+			if err != nil {
+				logging.ErrorAndExit("Failed to replay session: %v", err)
+			}
+			fmt.Println(report)
+			if !unchanged {
+				os.Exit(1)
+			}
+		},
+	}
+	mcpReplayCmd.Flags().StringVarP(&replayServer, "server", "s", "", "Server to replay on instead of the one the session was recorded on")
+	mcpCmd.AddCommand(mcpReplayCmd)
+
+	// MCP supervise command
+	var superviseInterval time.Duration
+	mcpSuperviseCmd := &cobra.Command{
+		Use:   "supervise",
+		Short: "Restart running MCP servers when their configuration changes",
+		Long: `Watch the configuration and restart running MCP servers whose commands, prompts or settings changed.
+Only servers with restart_on_config_change enabled are restarted, and restarts inside their
+quiet_hours window are deferred until it ends. Events are printed as JSON lines and appended to
+each server's log file.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := mcp.Supervise(superviseInterval); err != nil {
+				logging.ErrorAndExit("Failed to supervise MCP servers: %v", err)
+			}
+		},
+	}
+	mcpSuperviseCmd.Flags().DurationVar(&superviseInterval, "interval", mcp.DefaultSuperviseInterval, "How often to check for configuration changes")
+	mcpCmd.AddCommand(mcpSuperviseCmd)
+
+	// MCP clean command
+	var cleanDryRun bool
+	var cleanRetention settings.RetentionConfig
+	mcpCleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove old MCP logs and artifacts",
+		Long: `Apply the mcp_retention policy to the MCP server logs and the artifacts of command runs.
+Artifacts untouched for longer than max_age are removed and such logs cleared, then the
+oldest are removed, or trimmed to their last lines for logs, until everything fits in
+max_size. Servers apply the same policy when they start.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			retention := cfg.MCPRetention
+			if cleanRetention.MaxAge != "" {
+				retention.MaxAge = cleanRetention.MaxAge
+			}
+			if cleanRetention.MaxSize != "" {
+				retention.MaxSize = cleanRetention.MaxSize
+			}
+			result, err := mcp.Clean(retention, cleanDryRun)
+			if err != nil {
+				logging.ErrorAndExit("Failed to clean up MCP logs and artifacts: %v", err)
+			}
+			if cleanDryRun && result.Freed > 0 {
+				fmt.Print("Dry run, nothing was changed. ")
+			}
+			fmt.Println(result)
+		},
+	}
+	mcpCleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Show what would be removed without changing anything")
+	mcpCleanCmd.Flags().StringVar(&cleanRetention.MaxAge, "max-age", "", "Override mcp_retention.max_age, e.g. 168h")
+	mcpCleanCmd.Flags().StringVar(&cleanRetention.MaxSize, "max-size", "", "Override mcp_retention.max_size, e.g. 100MB")
+	mcpCmd.AddCommand(mcpCleanCmd)
+
 	// MCP port-check command
 	mcpPortCheckCmd := &cobra.Command{
 		Use:   "port-check",
@@ -636,45 +1988,32 @@ Examples:
 
 	// Add validate command to check configuration
 	validateCmd := &cobra.Command{
-		Use:     "validate",
-		Short:   "Validate the configuration file",
-		Aliases: []string{"v", "check"},
+		Use:         "validate",
+		Short:       "Validate the configuration file",
+		Aliases:     []string{"v", "check"},
+		Annotations: map[string]string{brokenConfigAnnotation: "true"},
 		Run: func(cmd *cobra.Command, args []string) {
-			// Reload configuration fresh to ensure remote configs are included
-			freshCfg, err := settings.Load()
-			if err != nil {
-				logging.ErrorAndExit("Failed to reload configuration: %v", err)
+			// Reload configuration fresh to ensure remote configs are included.
+			// Files that fail to parse are reported with the other issues.
+			freshCfg, loadErr := settings.Load()
+
+			if format := outputFormat(); format.Structured() {
+				report := validation.NewReport(append(validation.LoadErrors(loadErr), validation.ValidateAll(freshCfg)...))
+				printStructured(format, report)
+				if !report.Valid {
+					finishTracing()
+					os.Exit(1)
+				}
+				return
 			}
 
 			// Show command graph visualization first
 			display.PrintCommandGraph(freshCfg)
 
-			// Validate commands using existing functionality
-			cmdErrors := validation.ValidateCommands(freshCfg)
-
-			// Validate projects using the new project validator
-			projectValidator := project.NewValidator(freshCfg)
-			projectResult := projectValidator.ValidateAll()
-
-			// Combine errors from both validations
-			allErrors := cmdErrors
-			for _, err := range projectResult.Errors {
-				// Skip project errors that are already reported by command validation
-				isDuplicate := false
-				for _, cmdErr := range cmdErrors {
-					if cmdErr.Message == err.Error() {
-						isDuplicate = true
-						break
-					}
-				}
-
-				if !isDuplicate {
-					allErrors = append(allErrors, validation.ValidationError{
-						Message: err.Error(),
-						Severe:  err.Severe,
-					})
-				}
-			}
+			// Validate commands and projects
+			step := progress.Start("Validating %d commands and %d projects", len(freshCfg.Commands), len(freshCfg.Projects))
+			allErrors := append(validation.LoadErrors(loadErr), validation.ValidateAll(freshCfg)...)
+			step.Done("Validated %d commands and %d projects", len(freshCfg.Commands), len(freshCfg.Projects))
 
 			if len(allErrors) == 0 {
 				fmt.Println("\n✅ Configuration is valid!")
@@ -696,6 +2035,7 @@ Examples:
 			}
 
 			if severe {
+				finishTracing()
 				os.Exit(1)
 			}
 			logging.Info("Validation complete.")
@@ -704,12 +2044,163 @@ Examples:
 
 	rootCmd.AddCommand(validateCmd)
 
-	if err := rootCmd.Execute(); err != nil {
+	err = rootCmd.ExecuteContext(ctx)
+	finishTracing()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// earlyFlag returns the value of a root flag that has to be known before
+// cobra parses the command line, falling back to envVar
+func earlyFlag(args []string, name, envVar string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			return value
+		}
+		if arg == "--"+name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv(envVar)
+}
+
+// earlyBoolFlag reports whether a boolean root flag that has to be known
+// before cobra parses the command line is set
+func earlyBoolFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--"+name {
+			return true
+		}
+		if value, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			set, _ := strconv.ParseBool(value)
+			return set
+		}
+	}
+	return false
+}
+
+// brokenConfigAnnotation marks commands that run even when the configuration
+// fails to load, so it can be inspected and fixed
+const brokenConfigAnnotation = "interop/broken-config"
+
+// allowsBrokenConfig reports whether cmd runs when the configuration fails to
+// load: the bare root command, help, completion and annotated commands
+func allowsBrokenConfig(cmd *cobra.Command) bool {
+	if !cmd.HasParent() || cmd.Name() == "help" {
+		return true
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "completion" || c.Annotations[brokenConfigAnnotation] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminal reports whether f is a terminal that can answer prompts
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// runArgv returns the arguments of interop that run the command or alias
+// with args and the given run options again
+func runArgv(env, envFiles []string, dir string, timeout time.Duration, commandOrAlias string, args []string) []string {
+	argv := []string{"run"}
+	for _, assignment := range env {
+		argv = append(argv, "--env", assignment)
+	}
+	for _, envFile := range envFiles {
+		argv = append(argv, "--env-file", envFile)
+	}
+	if dir != "" {
+		argv = append(argv, "--cwd", dir)
+	}
+	if timeout > 0 {
+		argv = append(argv, "--timeout", timeout.String())
+	}
+	// The arguments are final, the second -- keeps them from being read as flags again
+	return append(append(argv, "--", commandOrAlias, "--"), args...)
+}
+
+// historyRecorder returns the validation.RunOptions.Record function recording
+// runs in the history, each repeated by the arguments of interop run built
+// from the flags given
+func historyRecorder(cfg *settings.Settings, env, envFiles []string, dir string, timeout time.Duration) func(string, []string) (io.Writer, func(error)) {
+	// Parallel runs finish at the same time, the history is written one at a time
+	var mu sync.Mutex
+	return func(commandOrAlias string, args []string) (io.Writer, func(error)) {
+		if cfg.History.Disabled {
+			return nil, func(error) {}
+		}
+		var project string
+		if ref, err := validation.ResolveCommand(cfg, commandOrAlias); err == nil {
+			project = ref.ProjectName
+		}
+		argv := runArgv(env, envFiles, dir, timeout, commandOrAlias, args)
+		entry, err := history.Start(commandOrAlias, project, args, argv, cfg.History.CaptureOutput)
+		if err != nil {
+			logging.Warning("This run isn't recorded in the history: %v", err)
+			return nil, func(error) {}
+		}
+		return entry.Output(), func(runErr error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := entry.Finish(runErr, history.Keep(cfg.History)); err != nil {
+				logging.Warning("Failed to record the run in the history: %v", err)
+			}
+		}
+	}
+}
+
+// runArguments returns the arguments of the command or alias interop run was
+// given, none when it isn't found
+func runArguments(cfg *settings.Settings, nameOrAlias string) []settings.CommandArgument {
+	ref, err := validation.ResolveCommand(cfg, nameOrAlias)
+	if err != nil {
+		return nil
+	}
+	return ref.Command.Arguments
+}
+
+// commandCompletions returns the enabled commands and project aliases of cfg
+// with their descriptions, in the form shell completion expects
+func commandCompletions(cfg *settings.Settings) []string {
+	var completions []string
+	for name, cmd := range cfg.Commands {
+		if cmd.IsEnabled {
+			completions = append(completions, name+"\t"+cmd.Description)
+		}
+	}
+	for projectName, project := range cfg.Projects {
+		for _, alias := range project.Commands {
+			if alias.Alias != "" {
+				completions = append(completions, fmt.Sprintf("%s\t%s in %s", alias.Alias, alias.CommandName, projectName))
+			}
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// printStructured writes v to stdout in the structured format of --output
+func printStructured(format output.Format, v interface{}) {
+	if err := output.Write(os.Stdout, format, v); err != nil {
+		logging.ErrorAndExit("%v", err)
+	}
+}
+
 func getVersionInfo() string {
 	versionInfo := version
 	if isSnapshot == "true" {
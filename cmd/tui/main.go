@@ -1,31 +1,54 @@
+// Command tui is the standalone TUI binary, kept while users move to
+// 'interop tui'. It runs 'interop tui' with its arguments, so hooks, reruns
+// and commands calling interop again use the interop binary rather than this
+// one.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"interop/internal/logging"
-	"interop/internal/settings"
-	"interop/internal/tui"
 	"os"
-
-	tea "github.com/charmbracelet/bubbletea"
+	"os/exec"
+	"path/filepath"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := settings.Load()
+	fmt.Fprintln(os.Stderr, "This binary is deprecated and will be removed, run 'interop tui' instead.")
+
+	interopPath, err := findInterop()
 	if err != nil {
-		logging.ErrorAndExit("Failed to load configuration: %v", err)
+		logging.ErrorAndExit("%v", err)
 	}
 
-	// Create the TUI model
-	model := tui.NewCommandsModel(cfg)
-
-	// Create the Bubble Tea program
-	p := tea.NewProgram(model, tea.WithAltScreen())
+	cmd := exec.Command(interopPath, append([]string{"tui"}, os.Args[1:]...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		logging.ErrorAndExit("Failed to run interop tui: %v", err)
+	}
+}
 
-	// Run the program
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error running TUI: %v\n", err)
-		os.Exit(1)
+// findInterop returns the interop binary installed next to this one, or the
+// one on PATH
+func findInterop() (string, error) {
+	if executable, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		sibling, err := exec.LookPath(filepath.Join(filepath.Dir(executable), "interop"))
+		if err == nil {
+			return sibling, nil
+		}
+	}
+	interopPath, err := exec.LookPath("interop")
+	if err != nil {
+		return "", fmt.Errorf("interop binary not found next to this one or on PATH: %w", err)
 	}
+	return interopPath, nil
 }
@@ -0,0 +1,68 @@
+package argfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathPrefix starts a from_file value naming a local file to read instead of
+// inline content, e.g. @./spec.yaml. A value starting with two of them is
+// inline content starting with one.
+const PathPrefix = "@"
+
+// unsafeNameChars are replaced when an argument name becomes a file name
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Files are the temporary files holding the from_file argument values of one
+// run, in a directory only the current user can read
+type Files struct {
+	dir string
+}
+
+// Write stores the value of the from_file argument called name in a new
+// temporary file and returns its path. A value starting with PathPrefix is
+// read from the file it names, keeping the file's extension.
+func (f *Files) Write(name, value string) (string, error) {
+	content := []byte(value)
+	ext := ""
+	switch {
+	case strings.HasPrefix(value, PathPrefix+PathPrefix):
+		content = []byte(value[len(PathPrefix):])
+	case strings.HasPrefix(value, PathPrefix):
+		source := value[len(PathPrefix):]
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the file for argument '%s': %w", name, err)
+		}
+		content = data
+		ext = filepath.Ext(source)
+	}
+
+	if f.dir == "" {
+		dir, err := os.MkdirTemp("", "interop-args-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create a directory for file arguments: %w", err)
+		}
+		f.dir = dir
+	}
+	file, err := os.CreateTemp(f.dir, unsafeNameChars.ReplaceAllString(name, "_")+"-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a file for argument '%s': %w", name, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(content); err != nil {
+		return "", fmt.Errorf("failed to write the file for argument '%s': %w", name, err)
+	}
+	return file.Name(), nil
+}
+
+// Remove deletes the files once the run is over
+func (f *Files) Remove() {
+	if f.dir != "" {
+		os.RemoveAll(f.dir)
+		f.dir = ""
+	}
+}
@@ -0,0 +1,52 @@
+package argfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "change.diff")
+	if err := os.WriteFile(source, []byte("--- a\n+++ b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var files Files
+	defer files.Remove()
+
+	tests := []struct {
+		value   string
+		content string
+		ext     string
+	}{
+		{"inline spec", "inline spec", ""},
+		{"@" + source, "--- a\n+++ b\n", ".diff"},
+		{"@@mention", "@mention", ""},
+	}
+	var dir string
+	for _, tt := range tests {
+		path, err := files.Write("spec file", tt.value)
+		if err != nil {
+			t.Fatalf("Write(%q) error = %v", tt.value, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != tt.content {
+			t.Errorf("Write(%q) wrote %q, %v; want %q", tt.value, data, err, tt.content)
+		}
+		if filepath.Ext(path) != tt.ext || !strings.HasPrefix(filepath.Base(path), "spec_file-") {
+			t.Errorf("Write(%q) path = %s, want spec_file-*%s", tt.value, path, tt.ext)
+		}
+		dir = filepath.Dir(path)
+	}
+
+	if _, err := files.Write("spec", "@"+filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	files.Remove()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Remove() left %s behind", dir)
+	}
+}
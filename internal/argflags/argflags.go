@@ -0,0 +1,87 @@
+// Package argflags lets interop run take a command's arguments as flags,
+// --name value, next to name=value pairs and bare values
+package argflags
+
+import (
+	"interop/internal/settings"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Parse separates the words following the command name in interop run into
+// the command's arguments and run's own flags. Flags named after one of the
+// command's arguments, --name value or --name=value, become name=value
+// arguments after the bare values, and bool arguments can be given as a bare
+// --name. Dashes and underscores in flag names are interchangeable. Other
+// flags are set in runFlags, except where an argument has the same name: the
+// argument's flag takes its place. Words after "--" are passed on as they are.
+func Parse(arguments []settings.CommandArgument, words []string, runFlags *pflag.FlagSet) ([]string, error) {
+	fs := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.SetNormalizeFunc(normalize)
+
+	values := make([]*string, len(arguments))
+	for i, arg := range arguments {
+		values[i] = fs.String(arg.Name, "", arg.Description)
+		if arg.Type == settings.ArgumentTypeBool {
+			fs.Lookup(arg.Name).NoOptDefVal = "true"
+		}
+	}
+	fs.AddFlagSet(runFlags)
+
+	if err := fs.Parse(words); err != nil {
+		return nil, err
+	}
+	args := fs.Args()
+	for i, arg := range arguments {
+		if fs.Changed(arg.Name) {
+			args = append(args, arg.Name+"="+*values[i])
+		}
+	}
+	return args, nil
+}
+
+// Completions returns the flags starting with prefix for shell completion
+// of interop run: the ones of the command's arguments with their
+// descriptions, followed by the ones of runFlags they don't replace
+func Completions(arguments []settings.CommandArgument, runFlags *pflag.FlagSet, prefix string) []string {
+	var completions []string
+	for _, arg := range arguments {
+		if strings.HasPrefix("--"+arg.Name, prefix) {
+			completions = append(completions, "--"+arg.Name+"\t"+arg.Description)
+		}
+	}
+
+	var others []string
+	RunFlags(arguments, runFlags).VisitAll(func(flag *pflag.Flag) {
+		if !flag.Hidden && strings.HasPrefix("--"+flag.Name, prefix) {
+			others = append(others, "--"+flag.Name+"\t"+flag.Usage)
+		}
+	})
+	sort.Strings(others)
+	return append(completions, others...)
+}
+
+// RunFlags returns the flags of runFlags that can be given after the command
+// name, the ones no argument of the command replaces
+func RunFlags(arguments []settings.CommandArgument, runFlags *pflag.FlagSet) *pflag.FlagSet {
+	taken := make(map[pflag.NormalizedName]bool, len(arguments))
+	for _, arg := range arguments {
+		taken[normalize(nil, arg.Name)] = true
+	}
+	fs := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	runFlags.VisitAll(func(flag *pflag.Flag) {
+		if !taken[normalize(nil, flag.Name)] {
+			fs.AddFlag(flag)
+		}
+	})
+	return fs
+}
+
+// normalize makes --dry-run and --dry_run the same flag
+func normalize(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+	return pflag.NormalizedName(strings.ReplaceAll(name, "_", "-"))
+}
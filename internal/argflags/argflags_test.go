@@ -0,0 +1,112 @@
+package argflags
+
+import (
+	"interop/internal/settings"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var arguments = []settings.CommandArgument{
+	{Name: "env", Description: "Target environment"},
+	{Name: "force", Type: settings.ArgumentTypeBool, Description: "Skip checks"},
+	{Name: "dry_run", Type: settings.ArgumentTypeBool},
+	{Name: "replicas", Type: settings.ArgumentTypeNumber},
+}
+
+// runFlags returns flags like the ones of interop run
+func runFlags() (*pflag.FlagSet, *[]string, *time.Duration) {
+	fs := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	env := fs.StringArray("env", nil, "Set an environment variable")
+	timeout := fs.Duration("timeout", 0, "Stop the command after this long")
+	fs.BoolP("detach", "d", false, "Run in the background")
+	return fs, env, timeout
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		words       []string
+		want        []string
+		wantTimeout time.Duration
+	}{
+		{[]string{"env=prod"}, []string{"env=prod"}, 0},
+		{[]string{"--env", "prod"}, []string{"env=prod"}, 0},
+		{[]string{"--env=prod", "--force"}, []string{"env=prod", "force=true"}, 0},
+		{[]string{"--force=false", "--dry-run"}, []string{"force=false", "dry_run=true"}, 0},
+		{[]string{"--dry_run", "staging", "--replicas", "3"}, []string{"staging", "dry_run=true", "replicas=3"}, 0},
+		{[]string{"prod", "--timeout", "2m", "extra"}, []string{"prod", "extra"}, 2 * time.Minute},
+		{[]string{"--force", "--", "--env", "-5"}, []string{"--env", "-5", "force=true"}, 0},
+	}
+	for _, tt := range tests {
+		fs, env, timeout := runFlags()
+		got, err := Parse(arguments, tt.words, fs)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.words, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+		if len(*env) > 0 {
+			t.Errorf("Parse(%q) set run's --env to %q, want the argument's flag to replace it", tt.words, *env)
+		}
+		if *timeout != tt.wantTimeout {
+			t.Errorf("Parse(%q) timeout = %v, want %v", tt.words, *timeout, tt.wantTimeout)
+		}
+	}
+}
+
+func TestParseRunFlags(t *testing.T) {
+	fs, env, _ := runFlags()
+	got, err := Parse(nil, []string{"--env", "STAGE=prod", "-d", "value"}, fs)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"value"}) || !reflect.DeepEqual(*env, []string{"STAGE=prod"}) {
+		t.Errorf("Parse() = %q with --env %q, want the value and run's --env", got, *env)
+	}
+	if detach, _ := fs.GetBool("detach"); !detach {
+		t.Error("Parse() didn't set run's -d")
+	}
+
+	for _, words := range [][]string{{"--unknown"}, {"--env"}} {
+		fs, _, _ := runFlags()
+		if _, err := Parse(arguments, words, fs); err == nil {
+			t.Errorf("Parse(%q) expected an error", words)
+		}
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	fs, _, _ := runFlags()
+	got := Completions(arguments, fs, "--")
+	want := []string{
+		"--env\tTarget environment",
+		"--force\tSkip checks",
+		"--dry_run\t",
+		"--replicas\t",
+		"--detach\tRun in the background",
+		"--timeout\tStop the command after this long",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Completions() = %q, want %q", got, want)
+	}
+
+	if got := Completions(arguments, fs, "--d"); strings.Join(got, ",") != "--dry_run\t,--detach\tRun in the background" {
+		t.Errorf("Completions(--d) = %q", got)
+	}
+}
+
+func TestRunFlags(t *testing.T) {
+	fs, _, _ := runFlags()
+	var names []string
+	RunFlags(arguments, fs).VisitAll(func(flag *pflag.Flag) {
+		names = append(names, flag.Name)
+	})
+	if want := []string{"detach", "timeout"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("RunFlags() = %q, want %q without the replaced --env", names, want)
+	}
+}
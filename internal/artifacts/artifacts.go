@@ -0,0 +1,277 @@
+package artifacts
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvVar points a running command at its artifacts directory
+	EnvVar = "INTEROP_ARTIFACTS_DIR"
+	// RefPrefix starts a value referring to an artifact of an earlier run,
+	// e.g. artifact:<run-id>/analysis.md
+	RefPrefix = "artifact:"
+	// DefaultKeep is the number of runs retained when artifacts.keep is not set
+	DefaultKeep = 20
+	// dirName is the artifacts directory inside the app directory
+	dirName = "artifacts"
+	// idTimeFormat starts every run ID so IDs sort by creation time
+	idTimeFormat = "20060102-150405.000"
+)
+
+// unsafeIDChars are replaced when a command name becomes part of a run ID
+var unsafeIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Run is the artifacts directory of a single command execution
+type Run struct {
+	ID      string    `json:"id"`
+	Dir     string    `json:"dir"`
+	Created time.Time `json:"created"`
+	Files   []File    `json:"files,omitempty"`
+}
+
+// File is an artifact written by a run
+type File struct {
+	Path string `json:"path"` // Relative to the run directory, slash separated
+	Size int64  `json:"size"`
+	Ref  string `json:"ref"` // Reference later runs can pass instead of the path
+}
+
+// Root returns the directory holding the artifacts of all runs
+func Root() (string, error) {
+	return settings.StateDir(dirName)
+}
+
+// Keep returns the number of runs to retain for the given settings
+func Keep(cfg settings.ArtifactsConfig) int {
+	if cfg.Keep <= 0 {
+		return DefaultKeep
+	}
+	return cfg.Keep
+}
+
+// NewRun creates an empty artifacts directory for a run of command
+func NewRun(command string) (*Run, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate artifacts directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	created := time.Now()
+	name := strings.Trim(unsafeIDChars.ReplaceAllString(command, "_"), "_")
+	dir, err := os.MkdirTemp(root, created.Format(idTimeFormat)+"-"+name+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run artifacts directory: %w", err)
+	}
+
+	return &Run{ID: filepath.Base(dir), Dir: dir, Created: created}, nil
+}
+
+// Env returns the KEY=VALUE pair exposing the run directory to commands
+func (r *Run) Env() string {
+	return EnvVar + "=" + r.Dir
+}
+
+// Ref returns the reference to an artifact of this run
+func (r *Run) Ref(path string) string {
+	return RefPrefix + r.ID + "/" + path
+}
+
+// Collect lists the files written to the run directory
+func (r *Run) Collect() ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(r.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.Dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files = append(files, File{Path: rel, Size: info.Size(), Ref: r.Ref(rel)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect artifacts: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Finish collects the run's artifacts, removing the directory when nothing
+// was written, and prunes the oldest runs beyond keep
+func (r *Run) Finish(keep int) ([]File, error) {
+	files, err := r.Collect()
+	if err != nil {
+		return nil, err
+	}
+	r.Files = files
+
+	if len(files) == 0 {
+		if err := os.RemoveAll(r.Dir); err != nil {
+			return nil, fmt.Errorf("failed to remove empty artifacts directory: %w", err)
+		}
+		return nil, nil
+	}
+
+	return files, Prune(keep)
+}
+
+// Summary describes the run's artifacts for printing at the end of a run
+func (r *Run) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Artifacts (run %s) saved to %s:\n", r.ID, r.Dir)
+	for _, file := range r.Files {
+		fmt.Fprintf(&b, "  - %s (%s): %s\n", file.Path, FormatSize(file.Size), file.Ref)
+	}
+	return b.String()
+}
+
+// List returns the retained runs with their files, newest first
+func List() ([]Run, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifacts directory: %w", err)
+	}
+
+	var runs []Run
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].IsDir() {
+			continue
+		}
+		run, err := Get(entries[i].Name())
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, nil
+}
+
+// Get returns a retained run and its files
+func Get(id string) (*Run, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid run ID '%s'", id)
+	}
+
+	dir := filepath.Join(root, id)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("no artifacts found for run '%s'", id)
+	}
+
+	run := &Run{ID: id, Dir: dir, Created: info.ModTime()}
+	if created, err := time.ParseInLocation(idTimeFormat, id[:min(len(id), len(idTimeFormat))], time.Local); err == nil {
+		run.Created = created
+	}
+	if run.Files, err = run.Collect(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Path resolves an artifact of a run to its location on disk, rejecting
+// paths that escape the run directory
+func Path(id, file string) (string, error) {
+	run, err := Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(file))
+	if file == "" || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid artifact path '%s'", file)
+	}
+
+	path := filepath.Join(run.Dir, cleaned)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("artifact '%s' not found in run '%s'", file, id)
+	}
+	return path, nil
+}
+
+// ResolveRef turns an artifact reference into the artifact's path on disk.
+// Values that aren't references are returned unchanged.
+func ResolveRef(value string) (string, error) {
+	ref, ok := strings.CutPrefix(value, RefPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, file, ok := strings.Cut(ref, "/")
+	if !ok {
+		return "", fmt.Errorf("invalid artifact reference '%s', expected %s<run-id>/<path>", value, RefPrefix)
+	}
+	return Path(id, file)
+}
+
+// Prune removes the oldest runs so that at most keep remain
+func Prune(keep int) error {
+	root, err := Root()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read artifacts directory: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	// ReadDir sorts by name, and run IDs start with their creation time
+	for len(dirs) > keep {
+		if err := os.RemoveAll(filepath.Join(root, dirs[0])); err != nil {
+			return fmt.Errorf("failed to prune artifacts of run '%s': %w", dirs[0], err)
+		}
+		dirs = dirs[1:]
+	}
+	return nil
+}
+
+// FormatSize renders a byte count for humans
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,132 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRun creates a run in an isolated app directory
+func newTestRun(t *testing.T, command string) *Run {
+	t.Helper()
+	run, err := NewRun(command)
+	if err != nil {
+		t.Fatalf("NewRun failed: %v", err)
+	}
+	return run
+}
+
+func TestRunLifecycle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	run := newTestRun(t, "generate mr")
+	if !strings.Contains(run.ID, "-generate_mr-") {
+		t.Errorf("expected run ID to contain the command name, got %s", run.ID)
+	}
+	if run.Env() != EnvVar+"="+run.Dir {
+		t.Errorf("unexpected env assignment %s", run.Env())
+	}
+
+	if err := os.MkdirAll(filepath.Join(run.Dir, "reports"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(run.Dir, "reports", "summary.md"), []byte("# Summary\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := run.Finish(DefaultKeep)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "reports/summary.md" || files[0].Size != 10 {
+		t.Fatalf("unexpected files %+v", files)
+	}
+	if files[0].Ref != RefPrefix+run.ID+"/reports/summary.md" {
+		t.Errorf("unexpected artifact reference %s", files[0].Ref)
+	}
+	if !strings.Contains(run.Summary(), "reports/summary.md (10 B): "+files[0].Ref) {
+		t.Errorf("expected file in summary, got %q", run.Summary())
+	}
+
+	path, err := Path(run.ID, "reports/summary.md")
+	if err != nil {
+		t.Fatalf("Path failed: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "# Summary\n" {
+		t.Errorf("unexpected artifact content %q", content)
+	}
+	for _, bad := range []string{"", "../settings.toml", "/etc/passwd", "missing.txt"} {
+		if _, err := Path(run.ID, bad); err == nil {
+			t.Errorf("expected Path(%q) to fail", bad)
+		}
+	}
+	if _, err := Get("../" + run.ID); err == nil {
+		t.Errorf("expected invalid run ID to be rejected")
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	run := newTestRun(t, "analyze")
+	if err := os.WriteFile(filepath.Join(run.Dir, "analysis.md"), []byte("changes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := ResolveRef(run.Ref("analysis.md"))
+	if err != nil || path != filepath.Join(run.Dir, "analysis.md") {
+		t.Errorf("expected reference to resolve to the artifact, got %s (%v)", path, err)
+	}
+	if value, err := ResolveRef("main"); err != nil || value != "main" {
+		t.Errorf("expected plain values to pass through, got %s (%v)", value, err)
+	}
+	for _, bad := range []string{RefPrefix + run.ID, RefPrefix + run.ID + "/missing.md", RefPrefix + "unknown/analysis.md"} {
+		if _, err := ResolveRef(bad); err == nil {
+			t.Errorf("expected ResolveRef(%q) to fail", bad)
+		}
+	}
+}
+
+func TestFinishRemovesEmptyRunsAndPrunes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	empty := newTestRun(t, "lint")
+	if files, err := empty.Finish(DefaultKeep); err != nil || files != nil {
+		t.Fatalf("expected no files, got %v (%v)", files, err)
+	}
+	if _, err := os.Stat(empty.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected empty run directory to be removed")
+	}
+
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		run := newTestRun(t, name)
+		if err := os.WriteFile(filepath.Join(run.Dir, "out.txt"), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := run.Finish(2); err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+		ids = append(ids, run.ID)
+	}
+
+	runs, err := List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != ids[2] || runs[1].ID != ids[1] {
+		t.Errorf("expected the two newest runs, got %+v", runs)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, expected := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB"} {
+		if got := FormatSize(size); got != expected {
+			t.Errorf("FormatSize(%d) = %s, expected %s", size, got, expected)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"interop/internal/settings"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dirName is the cache directory inside the app directory
+const dirName = "cache"
+
+// Entry is the cached output of a command run
+type Entry struct {
+	Command string    `json:"command"`
+	Output  string    `json:"output"`
+	Commit  string    `json:"commit"` // HEAD of the project when the command ran
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// State identifies the contents of a git working tree: its HEAD commit and a
+// hash of its uncommitted changes
+type State struct {
+	Commit string
+	Dirty  string // Empty when the tree is clean
+}
+
+// Root returns the directory holding cached results
+func Root() (string, error) {
+	return settings.StateDir(dirName)
+}
+
+// GitState returns the state of the git working tree at dir. Uncommitted
+// changes are hashed from the diff against HEAD and the size and
+// modification time of untracked files.
+func GitState(dir string) (State, error) {
+	commit, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return State{}, err
+	}
+	diff, err := git(dir, "diff", "HEAD", "--binary")
+	if err != nil {
+		return State{}, err
+	}
+	untracked, err := git(dir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return State{}, err
+	}
+
+	state := State{Commit: strings.TrimSpace(commit)}
+	if diff == "" && untracked == "" {
+		return state, nil
+	}
+	hash := sha256.New()
+	hash.Write([]byte(diff))
+	for _, file := range strings.Split(untracked, "\x00") {
+		if file == "" {
+			continue
+		}
+		fmt.Fprintf(hash, "\x00%s", file)
+		if info, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			fmt.Fprintf(hash, "\x00%d\x00%d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	state.Dirty = hex.EncodeToString(hash.Sum(nil))
+	return state, nil
+}
+
+// git runs a git command in dir and returns its output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], message)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return string(output), nil
+}
+
+// Key returns the cache key of a run described by parts, such as the
+// command, its directory and the git state
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Get returns the unexpired entry stored under key. Expired entries are
+// removed.
+func Get(key string) (*Entry, bool) {
+	path, err := entryPath(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Now().After(entry.Expires) {
+		os.Remove(path)
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores entry under key
+func Put(key string, entry Entry) error {
+	path, err := entryPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	// Write to a temporary file first so concurrent readers never see half an entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Clear removes the cached results of command, or all of them when command
+// is empty, and returns how many were removed
+func Clear(command string) (int, error) {
+	root, err := Root()
+	if err != nil {
+		return 0, fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(root, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		if command != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			var entry Entry
+			if json.Unmarshal(data, &entry) == nil && entry.Command != command {
+				continue
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// entryPath returns the file of the entry stored under key
+func entryPath(key string) (string, error) {
+	root, err := Root()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(root, key+".json"), nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"interop/internal/testutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// run runs git in dir, failing the test on errors
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func TestGitState(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	run(t, dir, "init", "-q")
+	if err := os.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, dir, "add", ".")
+	run(t, dir, "commit", "-q", "-m", "initial")
+
+	clean, err := GitState(dir)
+	if err != nil {
+		t.Fatalf("GitState() error = %v", err)
+	}
+	if clean.Commit == "" || clean.Dirty != "" {
+		t.Errorf("GitState() = %+v, want a commit and no changes", clean)
+	}
+
+	if err := os.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified, err := GitState(dir)
+	if err != nil || modified.Dirty == "" || modified.Commit != clean.Commit {
+		t.Errorf("GitState() after a change = %+v, %v; want the same commit with changes", modified, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	untracked, err := GitState(dir)
+	if err != nil || untracked.Dirty == modified.Dirty {
+		t.Errorf("GitState() with an untracked file = %+v, %v; want different changes", untracked, err)
+	}
+
+	if _, err := GitState(t.TempDir()); err == nil {
+		t.Error("Expected an error outside a git repository")
+	}
+}
+
+func TestPutGetClear(t *testing.T) {
+	testutil.New(t)
+
+	lint := Key("lint", "/repo", "abc")
+	test := Key("test", "/repo", "abc")
+	if lint == test || lint != Key("lint", "/repo", "abc") {
+		t.Fatal("Key() should be stable and differ between runs")
+	}
+	if Key("ab", "c") == Key("a", "bc") {
+		t.Error("Key() should tell the parts apart")
+	}
+
+	now := time.Now()
+	if err := Put(lint, Entry{Command: "lint", Output: "ok", Created: now, Expires: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := Put(test, Entry{Command: "test", Output: "ok", Created: now, Expires: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if entry, ok := Get(lint); !ok || entry.Output != "ok" {
+		t.Errorf("Get() = %+v, %v; want the stored entry", entry, ok)
+	}
+
+	expired := Key("lint", "/repo", "old")
+	if err := Put(expired, Entry{Command: "lint", Created: now, Expires: now.Add(-time.Second)}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, ok := Get(expired); ok {
+		t.Error("Get() returned an expired entry")
+	}
+
+	if removed, err := Clear("lint"); err != nil || removed != 1 {
+		t.Errorf("Clear(lint) = %d, %v; want 1", removed, err)
+	}
+	if _, ok := Get(test); !ok {
+		t.Error("Clear(lint) removed another command's entry")
+	}
+	if removed, err := Clear(""); err != nil || removed != 1 {
+		t.Errorf("Clear() = %d, %v; want 1", removed, err)
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"interop/internal/display"
 	"interop/internal/execution"
+	"sort"
 )
 
 // Command defines a command that can be executed
 type Command struct {
-	Description  string `toml:"description,omitempty"`
-	IsEnabled    bool   `toml:"is_enabled"`
-	Cmd          string `toml:"cmd"`
-	IsExecutable bool   `toml:"is_executable"`
+	Description  string   `toml:"description,omitempty"`
+	IsEnabled    bool     `toml:"is_enabled"`
+	Cmd          string   `toml:"cmd"`
+	IsExecutable bool     `toml:"is_executable"`
+	SourceFile   string   `toml:"-"` // Settings file the command was loaded from
+	SourceLine   int      `toml:"-"` // Line of the command's table in SourceFile, 0 when unknown
+	Shadowed     []string `toml:"-"` // Locations of lower priority definitions this one took precedence over
 }
 
 // Alias represents a command alias in a project
@@ -68,7 +72,7 @@ func PrintCommandDetails(name string, cmd Command, projectAssociations map[strin
 	display.PrintCommandStatus(cmd.IsEnabled, execSource)
 
 	// Print source information
-	display.PrintCommandSource(name)
+	display.PrintCommandSource(cmd.SourceFile, cmd.SourceLine, cmd.Shadowed)
 
 	// Print project associations if any
 	projectNames, hasProjects := projectAssociations[name]
@@ -97,15 +101,21 @@ func List(commands map[string]Command) {
 	}
 }
 
-// ListWithProjects prints all commands with their project associations
-func ListWithProjects(commands map[string]Command, projectCommands map[string][]Alias) {
-	if len(commands) == 0 {
-		display.PrintNoItemsFound("commands")
-		return
-	}
-
-	display.PrintCommandHeader()
+// Summary is a command as interop commands lists it
+type Summary struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	Cmd         string   `json:"cmd"`
+	Executable  bool     `json:"executable"` // Cmd is looked up in the executable search paths
+	SourceFile  string   `json:"source_file,omitempty"`
+	SourceLine  int      `json:"source_line,omitempty"`
+	Shadowed    []string `json:"shadowed,omitempty"` // Lower priority definitions of the command
+	Projects    []string `json:"projects,omitempty"` // Projects binding the command, with their aliases
+}
 
+// Summaries returns the commands with the projects binding them, sorted by name
+func Summaries(commands map[string]Command, projectCommands map[string][]Alias) []Summary {
 	// Build a map of command name to associated projects
 	commandProjects := make(map[string][]string)
 
@@ -125,8 +135,43 @@ func ListWithProjects(commands map[string]Command, projectCommands map[string][]
 		}
 	}
 
-	for name, cmd := range commands {
-		PrintCommandDetails(name, cmd, commandProjects)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summaries := make([]Summary, 0, len(names))
+	for _, name := range names {
+		cmd := commands[name]
+		projects := commandProjects[name]
+		sort.Strings(projects)
+		summaries = append(summaries, Summary{
+			Name:        name,
+			Description: cmd.Description,
+			Enabled:     cmd.IsEnabled,
+			Cmd:         cmd.Cmd,
+			Executable:  cmd.IsExecutable,
+			SourceFile:  cmd.SourceFile,
+			SourceLine:  cmd.SourceLine,
+			Shadowed:    cmd.Shadowed,
+			Projects:    projects,
+		})
+	}
+	return summaries
+}
+
+// ListWithProjects prints all commands with their project associations
+func ListWithProjects(commands map[string]Command, projectCommands map[string][]Alias) {
+	if len(commands) == 0 {
+		display.PrintNoItemsFound("commands")
+		return
+	}
+
+	display.PrintCommandHeader()
+
+	for _, summary := range Summaries(commands, projectCommands) {
+		PrintCommandDetails(summary.Name, commands[summary.Name], map[string][]string{summary.Name: summary.Projects})
 	}
 }
 
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -164,3 +165,25 @@ func TestUnmarshalTOMLWithMap(t *testing.T) {
 		})
 	}
 }
+
+func TestSummaries(t *testing.T) {
+	cmds := map[string]Command{
+		"test":  {Description: "Run the tests", IsEnabled: true, Cmd: "go test ./..."},
+		"build": {IsEnabled: false, Cmd: "build.sh", IsExecutable: true},
+	}
+	projects := map[string][]Alias{
+		"web": {{CommandName: "test", Alias: "t"}},
+		"api": {{CommandName: "test"}},
+	}
+
+	summaries := Summaries(cmds, projects)
+	if len(summaries) != 2 || summaries[0].Name != "build" || summaries[1].Name != "test" {
+		t.Fatalf("Expected the commands sorted by name, got %+v", summaries)
+	}
+	if summaries[0].Enabled || !summaries[0].Executable || summaries[0].Projects != nil {
+		t.Errorf("Unexpected summary %+v", summaries[0])
+	}
+	if got := strings.Join(summaries[1].Projects, ", "); got != "api, web (alias: t)" {
+		t.Errorf("projects = %q, want the projects binding the command", got)
+	}
+}
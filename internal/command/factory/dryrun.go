@@ -0,0 +1,104 @@
+package factory
+
+import (
+	"context"
+	"interop/internal/execution"
+	"interop/internal/shell"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Plan describes what a run of a command would do, see DryRun
+type Plan struct {
+	Name         string
+	Line         string   // Command line of the main command, with its arguments filled in
+	Dir          string   // Working directory, empty for the current one
+	Env          []string // KEY=VALUE pairs the run adds to the current environment or changes, sorted
+	Script       string   // Script Line runs, for script commands
+	When         string   // Condition that must hold for the command to run
+	PreExec      []string // Commands of the pre-exec hooks, in order
+	PostExec     []string // Commands of the post-exec hooks, in order
+	Dependencies []*Plan  // Plans of the commands of depends_on, run first
+}
+
+// DryRun resolves a run of the command with args the way RunWithContext does,
+// without running the command, its hooks or its dependencies. The output of
+// pre-exec hooks isn't known, so ${hook:name} references are left as they are.
+func (c *Command) DryRun(args []string) (*Plan, error) {
+	args, err := resolveArtifactRefs(args)
+	if err != nil {
+		return nil, err
+	}
+	cmd, _, err := c.prepare(context.Background(), args, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Name:   c.Name,
+		Line:   c.commandLine(cmd),
+		Dir:    cmd.Dir,
+		Env:    envChanges(os.Environ(), cmd.Env),
+		Script: c.Script,
+		When:   c.When,
+	}
+	for _, hook := range c.PreExec {
+		plan.PreExec = append(plan.PreExec, hook.Cmd)
+	}
+	for _, hook := range c.PostExec {
+		plan.PostExec = append(plan.PostExec, hook.Cmd)
+	}
+	for _, dependency := range c.Dependencies {
+		dependency.EnvOverrides = c.EnvOverrides
+		dependencyPlan, err := dependency.DryRun(nil)
+		if err != nil {
+			return nil, err
+		}
+		plan.Dependencies = append(plan.Dependencies, dependencyPlan)
+	}
+	return plan, nil
+}
+
+// commandLine renders the main command of a run: the line a shell command
+// passes its shell, or the quoted arguments of other commands
+func (c *Command) commandLine(cmd *execution.Command) string {
+	if c.Type == ShellCommand && len(cmd.Args) >= 2 {
+		return cmd.Args[len(cmd.Args)-1]
+	}
+	words := make([]string, 0, len(cmd.Args)+1)
+	for _, word := range append([]string{cmd.Path}, cmd.Args...) {
+		if word == dryRunScript {
+			words = append(words, word)
+			continue
+		}
+		words = append(words, shell.Quote(word))
+	}
+	return strings.Join(words, " ")
+}
+
+// envChanges returns the variables of env, KEY=VALUE pairs where later ones
+// win, that environ lacks or has with another value, sorted by name
+func envChanges(environ, env []string) []string {
+	current := make(map[string]string, len(environ))
+	for _, variable := range environ {
+		if name, value, ok := strings.Cut(variable, "="); ok {
+			current[name] = value
+		}
+	}
+	final := make(map[string]string, len(env))
+	for _, variable := range env {
+		if name, value, ok := strings.Cut(variable, "="); ok {
+			final[name] = value
+		}
+	}
+
+	var changes []string
+	for name, value := range final {
+		if old, exists := current[name]; !exists || old != value {
+			changes = append(changes, name+"="+value)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
@@ -1,15 +1,27 @@
 package factory
 
 import (
+	"context"
 	"fmt"
+	"interop/internal/argfile"
+	"interop/internal/artifacts"
+	"interop/internal/condition"
 	"interop/internal/errors"
 	"interop/internal/execution"
 	"interop/internal/logging"
+	"interop/internal/notify"
 	"interop/internal/settings"
 	"interop/internal/shell"
+	"interop/internal/tracing"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CommandType identifies the type of command to create
@@ -20,6 +32,8 @@ const (
 	ShellCommand CommandType = "shell"
 	// ExecutableCommand represents a custom executable command
 	ExecutableCommand CommandType = "executable"
+	// ScriptCommand represents a multi-line script run by an interpreter
+	ScriptCommand CommandType = "script"
 )
 
 // Factory creates command instances based on configuration
@@ -55,14 +69,58 @@ type Command struct {
 	Dir         string
 	Type        CommandType
 	Enabled     bool
-	Env         []string // Environment variables
-	ProjectName string   // Project name for environment merging
-	PreExec     []string // Commands to run before the main command
-	PostExec    []string // Commands to run after the main command
+	Env         []string         // Environment variables
+	ProjectName string           // Project name for environment merging
+	PreExec     []settings.Hook  // Commands to run before the main command
+	PostExec    []settings.Hook  // Commands to run after the main command
+	When        string           // Condition that must hold for the command to run
+	Notify      bool             // Send a desktop notification when the main command finishes
+	NotifyAfter time.Duration    // Minimum run time for a notification
+	NotifyBell  bool             // Ring the terminal bell along with the notification
+	Limits      execution.Limits // Resource limits of the main command
+	Timeout     time.Duration    // Longest the main command may run, 0 for no limit
+	Script      string           // Script written to a temporary file and run, for script commands
+	Interpreter string           // Configured interpreter of the script, empty to use its shebang
+	ScriptDeps  []string         // Packages installed for the script's interpreter
+	// EnvOverrides are KEY=VALUE pairs given for a single run, applied above all configured env
+	EnvOverrides []string
+	// Dependencies are the commands of depends_on, run in order before this one
+	Dependencies []*Command
+	// Terminal replaces the terminal for the run's output when set, see execution.Command
+	Terminal io.Writer
+	// StopGrace is how long a canceled run gets to stop after SIGTERM, see execution.Command
+	StopGrace time.Duration
+	// Output receives a copy of the main command's stdout and stderr when set
+	Output io.Writer
+
+	artifacts *artifacts.Run // Artifacts directory of the current run
+	env       []string       // Environment of the settings for the current run, see mergedEnv
 }
 
-// Create creates a command instance from a command configuration
+// Create creates a command instance from a command configuration, along with
+// the commands it depends on, which run first in the same directory
 func (f *Factory) Create(cmdName string, projectPath string) (*Command, error) {
+	cmd, err := f.create(cmdName, projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := f.Config.DependencyOrder(cmdName)
+	if err != nil {
+		return nil, errors.NewCommandError(fmt.Sprintf("Invalid dependencies of command '%s'", cmdName), err, true)
+	}
+	for _, name := range order {
+		dependency, err := f.create(name, projectPath)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Dependencies = append(cmd.Dependencies, dependency)
+	}
+	return cmd, nil
+}
+
+// create creates a command instance without its dependencies
+func (f *Factory) create(cmdName string, projectPath string) (*Command, error) {
 	// Get command config
 	cmdConfig, exists := f.Config.Commands[cmdName]
 	if !exists {
@@ -75,6 +133,9 @@ func (f *Factory) Create(cmdName string, projectPath string) (*Command, error) {
 	}
 
 	// Create the appropriate command type
+	if cmdConfig.Script != "" {
+		return f.createScriptCommand(cmdName, cmdConfig, projectPath)
+	}
 	if cmdConfig.IsExecutable {
 		return f.createExecutableCommand(cmdName, cmdConfig, projectPath)
 	}
@@ -143,15 +204,53 @@ func (f *Factory) CreateFromAlias(projectName string, alias string) (*Command, e
 		return nil, err
 	}
 
-	// Set the project name for environment merging
-	cmd.ProjectName = projectName
+	// Set the project name for environment merging, and expand project
+	// references now that the project is resolved
+	for _, c := range append([]*Command{cmd}, cmd.Dependencies...) {
+		c.ProjectName = projectName
+		c.expandProjectVariables(projectPath)
+	}
 
 	return cmd, nil
 }
 
+// expandProjectVariables replaces ${PROJECT_PATH} and ${PROJECT_NAME} in the
+// command's arguments and hook commands
+func (c *Command) expandProjectVariables(projectPath string) {
+	expand := func(value string) string {
+		return settings.ExpandProjectVariables(value, c.ProjectName, projectPath)
+	}
+
+	for i, arg := range c.Args {
+		c.Args[i] = expand(arg)
+	}
+	c.Script = expand(c.Script)
+	c.PreExec = expandHookCommands(c.PreExec, expand)
+	c.PostExec = expandHookCommands(c.PostExec, expand)
+}
+
+// expandHookCommands applies expand to each hook's command in a new slice,
+// leaving the configured hooks untouched
+func expandHookCommands(hooks []settings.Hook, expand func(string) string) []settings.Hook {
+	if len(hooks) == 0 {
+		return hooks
+	}
+	expanded := make([]settings.Hook, len(hooks))
+	for i, hook := range hooks {
+		hook.Cmd = expand(hook.Cmd)
+		expanded[i] = hook
+	}
+	return expanded
+}
+
 // createShellCommand creates a shell command from configuration
 func (f *Factory) createShellCommand(name string, config settings.CommandConfig, workDir string) (*Command, error) {
-	return &Command{
+	limits, err := execution.LimitsFor(config)
+	if err != nil {
+		return nil, errors.NewCommandError(fmt.Sprintf("Command '%s' has invalid resource limits", name), err, true)
+	}
+
+	return (&Command{
 		Name:        name,
 		Description: config.Description,
 		Path:        f.ShellInfo.Path,
@@ -162,7 +261,37 @@ func (f *Factory) createShellCommand(name string, config settings.CommandConfig,
 		ProjectName: "", // Will be set later for project commands
 		PreExec:     config.PreExec,
 		PostExec:    config.PostExec,
-	}, nil
+		When:        config.When,
+		Limits:      limits,
+	}).withNotification(config).withTimeout(config), nil
+}
+
+// createScriptCommand creates a command running the configured script with
+// its interpreter
+func (f *Factory) createScriptCommand(name string, config settings.CommandConfig, workDir string) (*Command, error) {
+	limits, err := execution.LimitsFor(config)
+	if err != nil {
+		return nil, errors.NewCommandError(fmt.Sprintf("Command '%s' has invalid resource limits", name), err, true)
+	}
+
+	interpreter := execution.ScriptInterpreter(config.Script, config.Interpreter)
+	return (&Command{
+		Name:        name,
+		Description: config.Description,
+		Path:        interpreter[0],
+		Args:        interpreter[1:],
+		Dir:         workDir,
+		Type:        ScriptCommand,
+		Enabled:     config.IsEnabled,
+		ProjectName: "", // Will be set later for project commands
+		PreExec:     config.PreExec,
+		PostExec:    config.PostExec,
+		When:        config.When,
+		Limits:      limits,
+		Script:      config.Script,
+		Interpreter: config.Interpreter,
+		ScriptDeps:  config.Dependencies,
+	}).withNotification(config).withTimeout(config), nil
 }
 
 // createExecutableCommand creates an executable command from configuration
@@ -180,6 +309,11 @@ func (f *Factory) createExecutableCommand(name string, config settings.CommandCo
 	execName := cmdParts[0]
 	cmdArgs := cmdParts[1:]
 
+	limits, err := execution.LimitsFor(config)
+	if err != nil {
+		return nil, errors.NewCommandError(fmt.Sprintf("Command '%s' has invalid resource limits", name), err, true)
+	}
+
 	// Find the executable in search paths
 	var execPath string
 	for _, dir := range f.SearchDirs {
@@ -200,7 +334,7 @@ func (f *Factory) createExecutableCommand(name string, config settings.CommandCo
 		)
 	}
 
-	return &Command{
+	return (&Command{
 		Name:        name,
 		Description: config.Description,
 		Path:        execPath,
@@ -211,15 +345,192 @@ func (f *Factory) createExecutableCommand(name string, config settings.CommandCo
 		ProjectName: "", // Will be set later for project commands
 		PreExec:     config.PreExec,
 		PostExec:    config.PostExec,
-	}, nil
+		When:        config.When,
+		Limits:      limits,
+	}).withNotification(config).withTimeout(config), nil
+}
+
+// expandHookCaptures replaces ${hook:<name>} references with captured hook output.
+// References to names that were not captured are left untouched.
+func expandHookCaptures(value string, captures map[string]string) string {
+	return settings.HookCapturePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := settings.HookCapturePattern.FindStringSubmatch(ref)[1]
+		if captured, ok := captures[name]; ok {
+			return captured
+		}
+		logging.Warning("No pre-exec hook captured '%s'", name)
+		return ref
+	})
+}
+
+// expandHookCapturesInAll applies expandHookCaptures to every value in a new slice
+func expandHookCapturesInAll(values []string, captures map[string]string) []string {
+	expanded := make([]string, len(values))
+	for i, value := range values {
+		expanded[i] = expandHookCaptures(value, captures)
+	}
+	return expanded
+}
+
+// shouldRunHook evaluates the hook's condition, if any
+func shouldRunHook(hook settings.Hook) (bool, error) {
+	if hook.When == "" {
+		return true, nil
+	}
+	return condition.Evaluate(hook.When, condition.NewContext())
 }
 
-// executeHookCommand executes a single hook command
-func (c *Command) executeHookCommand(hookCmd string) error {
-	// Create a temporary execution.Command for the hook
+// executeWithPostExecHooks runs the main command and then its post-execution hooks
+// (regardless of main command success/failure), returning the main command's error.
+// Hooks receive the outcome through INTEROP_* environment variables.
+func (c *Command) executeWithPostExecHooks(ctx context.Context, cmd *execution.Command, captures map[string]string) error {
+	if len(c.PostExec) == 0 {
+		return c.executeMain(ctx, cmd)
+	}
+
+	// Keep a copy of the output for the hooks while still streaming it to the terminal
+	outputFile, err := os.CreateTemp("", "interop-output-*.log")
+	if err != nil {
+		logging.Warning("Failed to create output file for post-exec hooks: %v", err)
+	} else {
+		defer os.Remove(outputFile.Name())
+		if cmd.Output != nil {
+			cmd.Output = io.MultiWriter(cmd.Output, outputFile)
+		} else {
+			cmd.Output = outputFile
+		}
+	}
+
+	start := time.Now()
+	mainCmdErr := c.executeMain(ctx, cmd)
+	duration := time.Since(start)
+
+	hookEnv := []string{
+		fmt.Sprintf("INTEROP_EXIT_CODE=%d", execution.ExitCode(mainCmdErr)),
+		fmt.Sprintf("INTEROP_DURATION_MS=%d", duration.Milliseconds()),
+		fmt.Sprintf("INTEROP_COMMAND=%s", c.Name),
+		fmt.Sprintf("INTEROP_PROJECT=%s", c.ProjectName),
+	}
+	if outputFile != nil {
+		if err := outputFile.Close(); err != nil {
+			logging.Warning("Failed to close output file for post-exec hooks: %v", err)
+		}
+		hookEnv = append(hookEnv, fmt.Sprintf("INTEROP_OUTPUT_FILE=%s", outputFile.Name()))
+	}
+
+	c.runPostExecHooks(ctx, captures, hookEnv)
+
+	return mainCmdErr
+}
+
+// executeMain runs the main command inside a command.exec span
+func (c *Command) executeMain(ctx context.Context, cmd *execution.Command) error {
+	_, span := tracing.Start(ctx, "command.exec", attribute.String("interop.command", c.Name))
+	start := time.Now()
+	err := execution.WithTimeout(c.Timeout).ExecuteWithContext(ctx, cmd)
+	c.notify(execution.ExitCode(err), time.Since(start))
+	span.SetAttributes(attribute.Int("interop.exit_code", execution.ExitCode(err)))
+	tracing.End(span, err)
+	return err
+}
+
+// withNotification sets the command's notification settings from its
+// configuration. An invalid notify_after, which validation reports, disables
+// notifications.
+func (c *Command) withNotification(config settings.CommandConfig) *Command {
+	threshold, enabled, err := config.NotifyThreshold()
+	if err != nil {
+		logging.Warning("Notifications disabled for command '%s': %v", c.Name, err)
+		return c
+	}
+	c.Notify = enabled
+	c.NotifyAfter = threshold
+	c.NotifyBell = config.NotifyBell
+	return c
+}
+
+// withTimeout sets the command's timeout from its configuration. An invalid
+// timeout, which validation reports, is ignored.
+func (c *Command) withTimeout(config settings.CommandConfig) *Command {
+	timeout, err := config.RunTimeout()
+	if err != nil {
+		logging.Warning("Timeout ignored for command '%s': %v", c.Name, err)
+		return c
+	}
+	c.Timeout = timeout
+	return c
+}
+
+// notify sends the desktop notification for a finished run when the command
+// asks for one and the run lasted long enough
+func (c *Command) notify(exitCode int, duration time.Duration) {
+	if !c.Notify || duration < c.NotifyAfter {
+		return
+	}
+
+	if c.NotifyBell {
+		notify.Bell(os.Stderr)
+	}
+	result := notify.Result{Command: c.Name, Project: c.ProjectName, ExitCode: exitCode, Duration: duration}
+	if err := notify.Send(result); err != nil {
+		logging.Warning("Failed to send notification: %v", err)
+	}
+}
+
+// runHook runs a single hook inside a span named after its phase
+func (c *Command) runHook(ctx context.Context, phase string, index int, hook settings.Hook, extraEnv ...string) (string, error) {
+	_, span := tracing.Start(ctx, "hook."+phase,
+		attribute.String("interop.command", c.Name),
+		attribute.Int("interop.hook.index", index),
+		attribute.String("interop.hook.cmd", hook.Cmd),
+	)
+	output, err := c.executeHookCommand(hook, extraEnv...)
+	tracing.End(span, err)
+	return output, err
+}
+
+// runPostExecHooks executes post-execution hooks with the given extra environment,
+// continuing past failures
+func (c *Command) runPostExecHooks(ctx context.Context, captures map[string]string, env []string) {
+	if len(c.PostExec) == 0 {
+		return
+	}
+
+	logging.Message("Executing %d post-execution hook(s)", len(c.PostExec))
+	for i, hook := range c.PostExec {
+		if run, err := shouldRunHook(hook); err != nil {
+			logging.Error("Post-execution hook %d failed: %v", i+1, err)
+			continue
+		} else if !run {
+			logging.Message("Skipping post-exec hook %d: condition '%s' is false", i+1, hook.When)
+			continue
+		}
+		hook.Cmd = expandHookCaptures(hook.Cmd, captures)
+		logging.Message("Running post-exec hook %d: %s", i+1, hook)
+		if _, hookErr := c.runHook(ctx, "post_exec", i+1, hook, env...); hookErr != nil {
+			logging.Error("Post-execution hook %d failed: %v", i+1, hookErr)
+			// Continue with other post-exec hooks even if one fails
+		}
+	}
+	logging.Message("All post-execution hooks completed")
+}
+
+// executeHookCommand executes a single hook command with optional extra environment variables.
+// If the hook captures its output, the trimmed stdout is returned.
+func (c *Command) executeHookCommand(hook settings.Hook, extraEnv ...string) (string, error) {
+	hookCmd := hook.Cmd
+
+	// Use the same working directory and environment as the main command
+	env := c.Env
+	if cfg, err := settings.Load(); err != nil {
+		logging.Warning("Failed to load settings for the hook environment: %v", err)
+	} else if env, err = c.mergedEnv(cfg); err != nil {
+		return "", err
+	}
 	hookExecCmd := &execution.Command{
-		Dir: c.Dir, // Use the same working directory as the main command
-		Env: c.Env, // Use the same environment as the main command
+		Dir:      c.Dir,
+		Env:      slices.Concat(env, c.runEnv(), extraEnv),
+		Terminal: c.Terminal,
 	}
 
 	// Determine how to execute the hook command
@@ -229,7 +540,7 @@ func (c *Command) executeHookCommand(hookCmd string) error {
 
 		interopPath, err := os.Executable()
 		if err != nil {
-			return fmt.Errorf("failed to get current executable path: %w", err)
+			return "", fmt.Errorf("failed to get current executable path: %w", err)
 		}
 
 		hookExecCmd.Path = interopPath
@@ -238,7 +549,7 @@ func (c *Command) executeHookCommand(hookCmd string) error {
 		// Handle regular shell commands
 		shellInfo, err := shell.DetectShell()
 		if err != nil {
-			return fmt.Errorf("failed to detect shell for hook execution: %w", err)
+			return "", fmt.Errorf("failed to detect shell for hook execution: %w", err)
 		}
 
 		hookExecCmd.Path = shellInfo.Path
@@ -247,30 +558,218 @@ func (c *Command) executeHookCommand(hookCmd string) error {
 
 	// Execute the hook command
 	logging.Message("Executing hook command: %s", hookCmd)
-	return execution.NewExecutor().Execute(hookExecCmd)
+	if hook.Capture != "" {
+		return execution.NewExecutor().Output(hookExecCmd)
+	}
+	return "", execution.NewExecutor().Execute(hookExecCmd)
+}
+
+// mergedEnv returns the environment the settings give the command, with the
+// executables directories on PATH and secret references resolved. It's merged
+// once per run, hooks and the main command share it.
+func (c *Command) mergedEnv(cfg *settings.Settings) ([]string, error) {
+	if c.env == nil {
+		env, err := settings.MergeEnvironmentVariables(cfg, c.Name, c.ProjectName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up the environment of command '%s': %w", c.Name, err)
+		}
+		c.env = env
+	}
+	return c.env, nil
+}
+
+// runEnv returns the per-run environment: the artifacts directory followed by
+// the command line overrides, which win over it
+func (c *Command) runEnv() []string {
+	if c.artifacts == nil {
+		return c.EnvOverrides
+	}
+	return append([]string{c.artifacts.Env()}, c.EnvOverrides...)
+}
+
+// resolveArtifactRefs replaces artifact references given as positional or
+// name=value arguments with the artifacts' paths
+func resolveArtifactRefs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+
+	resolved := make([]string, len(args))
+	for i, arg := range args {
+		prefix, value := "", arg
+		if name, v, ok := strings.Cut(arg, "="); ok && !strings.HasPrefix(arg, artifacts.RefPrefix) {
+			prefix, value = name+"=", v
+		}
+		path, err := artifacts.ResolveRef(value)
+		if err != nil {
+			return nil, fmt.Errorf("argument '%s': %w", arg, err)
+		}
+		resolved[i] = prefix + path
+	}
+	return resolved, nil
+}
+
+// finishArtifacts lists the files the run left in its artifacts directory and
+// applies the retention policy
+func (c *Command) finishArtifacts() {
+	keep := artifacts.DefaultKeep
+	if cfg, err := settings.Load(); err == nil {
+		keep = artifacts.Keep(cfg.Artifacts)
+	}
+
+	files, err := c.artifacts.Finish(keep)
+	if err != nil {
+		logging.Warning("Failed to collect artifacts: %v", err)
+	}
+	if len(files) > 0 {
+		fmt.Fprint(os.Stderr, c.artifacts.Summary())
+	}
+	c.artifacts = nil
 }
 
 // RunWithArgs executes the command with additional arguments
 func (c *Command) RunWithArgs(args []string) error {
+	return c.RunWithContext(context.Background(), args)
+}
+
+// RunWithContext is RunWithArgs recording a command.run span, with hook and
+// main command spans as children, under the span in ctx
+func (c *Command) RunWithContext(ctx context.Context, args []string) (err error) {
+	ctx, span := tracing.Start(ctx, "command.run",
+		attribute.String("interop.command", c.Name),
+		attribute.String("interop.project", c.ProjectName),
+		attribute.String("interop.correlation_id", logging.CorrelationID(ctx)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	logging.Message("Running command: %s with args: %v in directory: %s", c.Name, args, c.Dir)
 
-	// Execute pre-execution hooks
+	if c.When != "" {
+		run, err := condition.Evaluate(c.When, condition.NewContext())
+		if err != nil {
+			return errors.NewCommandError(fmt.Sprintf("Invalid condition for command '%s'", c.Name), err, true)
+		}
+		if !run {
+			logging.Warning("Skipping command '%s': condition '%s' is false", c.Name, c.When)
+			return nil
+		}
+	}
+
+	// Run the commands this one depends on first, stopping at the first failure
+	for _, dependency := range c.Dependencies {
+		dependency.EnvOverrides = c.EnvOverrides
+		dependency.Terminal = c.Terminal
+		dependency.StopGrace = c.StopGrace
+		logging.Message("Running dependency '%s' of command '%s'", dependency.Name, c.Name)
+		if err := dependency.RunWithContext(ctx, nil); err != nil {
+			return errors.NewExecutionError(fmt.Sprintf("Dependency '%s' of command '%s' failed", dependency.Name, c.Name), err)
+		}
+	}
+
+	// Settings may have changed since the last run
+	c.env = nil
+
+	// Give the run an empty artifacts directory, listing what was written when it ends
+	if run, err := artifacts.NewRun(c.Name); err != nil {
+		logging.Warning("Artifacts are unavailable for this run: %v", err)
+	} else {
+		c.artifacts = run
+		defer c.finishArtifacts()
+	}
+
+	// Replace artifact references from earlier runs with the artifacts' paths
+	if args, err = resolveArtifactRefs(args); err != nil {
+		return err
+	}
+
+	// Execute pre-execution hooks, collecting captured output
+	captures := make(map[string]string)
 	if len(c.PreExec) > 0 {
 		logging.Message("Executing %d pre-execution hook(s)", len(c.PreExec))
-		for i, hookCmd := range c.PreExec {
-			logging.Message("Running pre-exec hook %d: %s", i+1, hookCmd)
-			if err := c.executeHookCommand(hookCmd); err != nil {
+		for i, hook := range c.PreExec {
+			if run, err := shouldRunHook(hook); err != nil {
 				return fmt.Errorf("pre-execution hook %d failed: %w", i+1, err)
+			} else if !run {
+				logging.Message("Skipping pre-exec hook %d: condition '%s' is false", i+1, hook.When)
+				continue
+			}
+			hook.Cmd = expandHookCaptures(hook.Cmd, captures)
+			logging.Message("Running pre-exec hook %d: %s", i+1, hook)
+			output, err := c.runHook(ctx, "pre_exec", i+1, hook)
+			if err != nil {
+				return fmt.Errorf("pre-execution hook %d failed: %w", i+1, err)
+			}
+			if hook.Capture != "" {
+				captures[hook.Capture] = output
+				logging.Message("Captured output of pre-exec hook %d as '%s'", i+1, hook.Capture)
 			}
 		}
 		logging.Message("All pre-execution hooks completed successfully")
 	}
 
+	cmd, cleanup, err := c.prepare(ctx, args, captures, false)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Run the main command
+	return c.executeWithPostExecHooks(ctx, cmd, captures)
+}
+
+// dryRunScript stands for the script file in the command line of a dry run
+const dryRunScript = "<script>"
+
+// prepare builds the main command of a run with args and the output captured
+// by pre-exec hooks. A dry run writes no files: the script and from_file
+// arguments are shown as placeholders, and hook references are kept as they
+// are. cleanup removes the files written for the run.
+func (c *Command) prepare(ctx context.Context, args []string, captures map[string]string, dryRun bool) (cmd *execution.Command, cleanup func(), err error) {
+	var files argfile.Files
+	var scriptPath string
+	remove := func() {
+		files.Remove()
+		if scriptPath != "" {
+			os.Remove(scriptPath)
+		}
+	}
+	defer func() {
+		if err != nil {
+			remove()
+		}
+	}()
+	expand := func(values []string) []string {
+		if dryRun {
+			return values
+		}
+		return expandHookCapturesInAll(values, captures)
+	}
+
 	// Set up command execution
-	cmd := &execution.Command{
-		Path: c.Path,
-		Args: c.Args,
-		Dir:  c.Dir,
+	cmd = &execution.Command{
+		Path:      c.Path,
+		Args:      expand(c.Args),
+		Dir:       c.Dir,
+		Env:       c.runEnv(),
+		Limits:    c.Limits,
+		Output:    c.Output,
+		Terminal:  c.Terminal,
+		StopGrace: c.StopGrace,
+	}
+
+	// Scripts run from a temporary file passed to their interpreter
+	if c.Script != "" && dryRun {
+		cmd.Args = append(cmd.Args, dryRunScript)
+	} else if c.Script != "" {
+		run, err := execution.PrepareScript(ctx, c.Script, c.Interpreter, c.ScriptDeps)
+		if err != nil {
+			return nil, nil, err
+		}
+		if scriptPath, err = run.Write(expandHookCaptures(c.Script, captures)); err != nil {
+			return nil, nil, err
+		}
+		argv := run.Command(scriptPath)
+		cmd.Path, cmd.Args = argv[0], argv[1:]
 	}
 
 	// Get the command configuration to check for prefixed arguments
@@ -279,12 +778,20 @@ func (c *Command) RunWithArgs(args []string) error {
 		logging.Warning("Failed to load settings for prefixed arguments: %v", err)
 		// Continue with normal argument handling
 	} else {
-		// Merge environment variables with proper precedence
-		cmd.Env = settings.MergeEnvironmentVariables(cfg, c.Name, c.ProjectName)
+		// Merge environment variables with proper precedence, command line
+		// overrides last. Dry runs print secret references, not their values.
+		var env []string
+		if dryRun {
+			env = settings.PreviewEnvironmentVariables(cfg, c.Name, c.ProjectName)
+		} else if env, err = c.mergedEnv(cfg); err != nil {
+			return nil, nil, err
+		}
+		cmd.Env = expand(env)
+		cmd.Env = append(cmd.Env, c.runEnv()...)
 
 		// Get the command config to check for prefixed arguments
 		cmdConfig, exists := cfg.Commands[c.Name]
-		if exists && len(cmdConfig.Arguments) > 0 && len(args) > 0 {
+		if exists && len(cmdConfig.Arguments) > 0 {
 			// Parse args into a map
 			argsMap := make(map[string]string)
 			positionalIndex := 0
@@ -319,10 +826,39 @@ func (c *Command) RunWithArgs(args []string) error {
 				}
 			}
 
+			// Check the values against their types and fill in defaults, the
+			// way tool calls of the MCP server do
+			provided := make(map[string]interface{}, len(argsMap))
+			for name, value := range argsMap {
+				provided[name] = value
+			}
+			values, err := cmdConfig.ArgumentValues(provided)
+			if err != nil {
+				return nil, nil, errors.NewCommandError(fmt.Sprintf("Invalid arguments for command '%s'", c.Name), err, true)
+			}
+			for name, value := range values {
+				argsMap[name] = value
+			}
+
+			// Hand from_file arguments to the command as paths of temporary files
+			for _, argDef := range cmdConfig.Arguments {
+				if value, ok := argsMap[argDef.Name]; ok && argDef.FromFile {
+					if dryRun {
+						argsMap[argDef.Name] = fmt.Sprintf("<file of %s>", argDef.Name)
+						continue
+					}
+					path, err := files.Write(argDef.Name, value)
+					if err != nil {
+						return nil, nil, err
+					}
+					argsMap[argDef.Name] = path
+				}
+			}
+
 			// If we have any arguments to process
 			if len(argsMap) > 0 {
-				// Handle executable commands with placeholder substitution
-				if c.Type == ExecutableCommand {
+				// Handle executable and script commands with placeholder substitution
+				if c.Type == ExecutableCommand || c.Type == ScriptCommand {
 					// For executable commands, we need to handle placeholder substitution in the Args
 					var processedArgs []string
 					var prefixedArgs []string
@@ -384,127 +920,110 @@ func (c *Command) RunWithArgs(args []string) error {
 
 					logging.Message("Executing command: %s %s", cmd.Path, strings.Join(cmd.Args, " "))
 
-					// We've handled the arguments, execute the main command
-					mainCmdErr := execution.NewExecutor().Execute(cmd)
-
-					// Execute post-execution hooks (regardless of main command success/failure)
-					if len(c.PostExec) > 0 {
-						logging.Message("Executing %d post-execution hook(s)", len(c.PostExec))
-						for i, hookCmd := range c.PostExec {
-							logging.Message("Running post-exec hook %d: %s", i+1, hookCmd)
-							if hookErr := c.executeHookCommand(hookCmd); hookErr != nil {
-								logging.Error("Post-execution hook %d failed: %v", i+1, hookErr)
-								// Continue with other post-exec hooks even if one fails
-							}
-						}
-						logging.Message("All post-execution hooks completed")
-					}
-
-					// Return the error from the main command (if any)
-					return mainCmdErr
+					// We've handled the arguments
+					return cmd, remove, nil
 				}
 
 				// For shell commands, we'll construct a new command string with prefixes
 				if c.Type == ShellCommand && len(cmd.Args) >= 2 {
-					baseCmd := cmd.Args[1]
-					var prefixedArgs []string
-					var positionalArgs []string
-
-					// Process each argument definition in order
-					for _, argDef := range cmdConfig.Arguments {
-						if value, ok := argsMap[argDef.Name]; ok {
-							if argDef.Prefix != "" {
-								// For arguments with prefixes
-								if argDef.Type == settings.ArgumentTypeBool {
-									if value == "true" {
-										prefixedArgs = append(prefixedArgs, argDef.Prefix)
-									}
-								} else {
-									// For other types, add both prefix and value
-									prefixedArgs = append(prefixedArgs, fmt.Sprintf("%s %s", argDef.Prefix, value))
-								}
-							} else {
-								// For arguments without prefixes (positional)
-								positionalArgs = append(positionalArgs, value)
-							}
-							// Remove from argsMap to track which ones we've processed
-							delete(argsMap, argDef.Name)
-						}
-					}
-
-					// Append any remaining arguments (undefined arguments)
-					var standardArgs []string
-					for name, value := range argsMap {
-						standardArgs = append(standardArgs, fmt.Sprintf("%s=%s", name, value))
-					}
-
-					// Combine the command parts: base command + positional args + prefixed args + remaining args
-					newCmd := baseCmd
-					if len(positionalArgs) > 0 {
-						newCmd = fmt.Sprintf("%s %s", newCmd, strings.Join(positionalArgs, " "))
-					}
-					if len(prefixedArgs) > 0 {
-						newCmd = fmt.Sprintf("%s %s", newCmd, strings.Join(prefixedArgs, " "))
-					}
-					if len(standardArgs) > 0 {
-						newCmd = fmt.Sprintf("%s %s", newCmd, strings.Join(standardArgs, " "))
-					}
-
+					newCmd := c.shellCommandWithArgs(cmd.Args[1], cmdConfig.Arguments, argsMap)
 					logging.Message("Command with prefixed args: %s", newCmd)
 					cmd.Args[1] = newCmd
 
-					// We've handled the arguments, execute the main command
-					mainCmdErr := execution.NewExecutor().Execute(cmd)
-
-					// Execute post-execution hooks (regardless of main command success/failure)
-					if len(c.PostExec) > 0 {
-						logging.Message("Executing %d post-execution hook(s)", len(c.PostExec))
-						for i, hookCmd := range c.PostExec {
-							logging.Message("Running post-exec hook %d: %s", i+1, hookCmd)
-							if hookErr := c.executeHookCommand(hookCmd); hookErr != nil {
-								logging.Error("Post-execution hook %d failed: %v", i+1, hookErr)
-								// Continue with other post-exec hooks even if one fails
-							}
-						}
-						logging.Message("All post-execution hooks completed")
-					}
-
-					// Return the error from the main command (if any)
-					return mainCmdErr
+					// We've handled the arguments
+					return cmd, remove, nil
 				}
 			}
 		}
 	}
 
 	// If we didn't handle prefixed arguments, fall back to standard behavior
-	if c.Type == ExecutableCommand && args != nil && len(args) > 0 {
-		// For executable commands, add arguments directly
+	if (c.Type == ExecutableCommand || c.Type == ScriptCommand) && args != nil && len(args) > 0 {
+		// For executable and script commands, add arguments directly
 		cmd.Args = append(cmd.Args, args...)
 	} else if c.Type == ShellCommand && args != nil && len(args) > 0 {
 		// For shell commands, the command is in Args[1]
 		if len(cmd.Args) >= 2 {
-			// Format the command with arguments
-			commandWithArgs := fmt.Sprintf("%s %s", cmd.Args[1], strings.Join(args, " "))
-			cmd.Args[1] = commandWithArgs
+			// Format the command with arguments, each quoted as one word
+			quoted := make([]string, len(args))
+			for i, arg := range args {
+				quoted[i] = c.quote(arg)
+			}
+			cmd.Args[1] = fmt.Sprintf("%s %s", cmd.Args[1], strings.Join(quoted, " "))
 		}
 	}
 
-	// Run the main command
-	mainCmdErr := execution.NewExecutor().Execute(cmd)
-
-	// Execute post-execution hooks (regardless of main command success/failure)
-	if len(c.PostExec) > 0 {
-		logging.Message("Executing %d post-execution hook(s)", len(c.PostExec))
-		for i, hookCmd := range c.PostExec {
-			logging.Message("Running post-exec hook %d: %s", i+1, hookCmd)
-			if hookErr := c.executeHookCommand(hookCmd); hookErr != nil {
-				logging.Error("Post-execution hook %d failed: %v", i+1, hookErr)
-				// Continue with other post-exec hooks even if one fails
+	return cmd, remove, nil
+}
+
+// shellCommandWithArgs adds argument values to a shell command line. Values
+// replace their ${name} placeholders, and the rest are appended: positional
+// values, then prefixed ones, then name=value pairs without a definition.
+// Every value is quoted so it reaches the command as literal text.
+func (c *Command) shellCommandWithArgs(baseCmd string, argDefs []settings.CommandArgument, argsMap map[string]string) string {
+	var prefixedArgs []string
+	var positionalArgs []string
+	placeholders := make(map[string]string)
+	remaining := make(map[string]string, len(argsMap))
+	for name, value := range argsMap {
+		remaining[name] = value
+	}
+
+	// Process each argument definition in order
+	for _, argDef := range argDefs {
+		if value, ok := remaining[argDef.Name]; ok {
+			if argDef.Prefix != "" {
+				// For arguments with prefixes
+				if argDef.Type == settings.ArgumentTypeBool {
+					if value == "true" {
+						prefixedArgs = append(prefixedArgs, argDef.Prefix)
+					}
+				} else {
+					// For other types, add both prefix and value
+					prefixedArgs = append(prefixedArgs, fmt.Sprintf("%s %s", argDef.Prefix, c.quote(value)))
+				}
+			} else if strings.Contains(baseCmd, "${"+argDef.Name+"}") {
+				placeholders[argDef.Name] = value
+			} else {
+				// For arguments without prefixes (positional)
+				positionalArgs = append(positionalArgs, c.quote(value))
 			}
+			// Remove from remaining to track which ones we've processed
+			delete(remaining, argDef.Name)
 		}
-		logging.Message("All post-execution hooks completed")
 	}
 
-	// Return the error from the main command (if any)
-	return mainCmdErr
+	// Append any remaining arguments (undefined arguments)
+	var names []string
+	for name := range remaining {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var standardArgs []string
+	for _, name := range names {
+		if strings.Contains(baseCmd, "${"+name+"}") {
+			placeholders[name] = remaining[name]
+			continue
+		}
+		standardArgs = append(standardArgs, c.quote(name+"="+remaining[name]))
+	}
+
+	// Combine the command parts: base command + positional args + prefixed args + remaining args
+	newCmd := c.shell().ExpandPlaceholders(baseCmd, placeholders)
+	for _, part := range [][]string{positionalArgs, prefixedArgs, standardArgs} {
+		if len(part) > 0 {
+			newCmd = fmt.Sprintf("%s %s", newCmd, strings.Join(part, " "))
+		}
+	}
+	return newCmd
+}
+
+// shell describes the shell running a shell command
+func (c *Command) shell() *shell.Info {
+	return &shell.Info{Path: c.Path, Name: filepath.Base(c.Path)}
+}
+
+// quote quotes an argument value for the shell running the command
+func (c *Command) quote(value string) string {
+	return c.shell().Quote(value)
 }
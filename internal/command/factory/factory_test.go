@@ -1,12 +1,18 @@
 package factory
 
 import (
+	"fmt"
+	"interop/internal/artifacts"
 	"interop/internal/execution"
 	"interop/internal/settings"
 	"interop/internal/shell"
+	"interop/internal/testutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFactory_Create(t *testing.T) {
@@ -96,16 +102,16 @@ func TestFactory_CreateWithHooks(t *testing.T) {
 				IsEnabled:    true,
 				Cmd:          "echo 'main command'",
 				IsExecutable: false,
-				PreExec:      []string{"echo 'pre-hook 1'", "echo 'pre-hook 2'"},
-				PostExec:     []string{"echo 'post-hook 1'", "echo 'post-hook 2'"},
+				PreExec:      []settings.Hook{{Cmd: "echo 'pre-hook 1'"}, {Cmd: "echo 'pre-hook 2'"}},
+				PostExec:     []settings.Hook{{Cmd: "echo 'post-hook 1'"}, {Cmd: "echo 'post-hook 2'"}},
 			},
 			"cmd-without-hooks": {
 				Description:  "Command without hooks",
 				IsEnabled:    true,
 				Cmd:          "echo 'no hooks'",
 				IsExecutable: false,
-				PreExec:      []string{},
-				PostExec:     []string{},
+				PreExec:      []settings.Hook{},
+				PostExec:     []settings.Hook{},
 			},
 		},
 		ExecutableSearchPaths: []string{},
@@ -131,11 +137,11 @@ func TestFactory_CreateWithHooks(t *testing.T) {
 		if len(cmd.PostExec) != 2 {
 			t.Errorf("Expected 2 post-exec hooks but got %d", len(cmd.PostExec))
 		}
-		if cmd.PreExec[0] != "echo 'pre-hook 1'" {
-			t.Errorf("Expected first pre-exec hook to be 'echo 'pre-hook 1'' but got %s", cmd.PreExec[0])
+		if cmd.PreExec[0].Cmd != "echo 'pre-hook 1'" {
+			t.Errorf("Expected first pre-exec hook to be 'echo 'pre-hook 1'' but got %s", cmd.PreExec[0].Cmd)
 		}
-		if cmd.PostExec[1] != "echo 'post-hook 2'" {
-			t.Errorf("Expected second post-exec hook to be 'echo 'post-hook 2'' but got %s", cmd.PostExec[1])
+		if cmd.PostExec[1].Cmd != "echo 'post-hook 2'" {
+			t.Errorf("Expected second post-exec hook to be 'echo 'post-hook 2'' but got %s", cmd.PostExec[1].Cmd)
 		}
 	}
 
@@ -243,3 +249,528 @@ func TestFactory_CreateFromAlias(t *testing.T) {
 		t.Errorf("Expected error when creating command for non-existent project but got none")
 	}
 }
+
+func TestExpandHookCaptures(t *testing.T) {
+	captures := map[string]string{"sha": "abc123"}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"docker tag app app:${hook:sha}", "docker tag app app:abc123"},
+		{"IMAGE_TAG=${hook:sha}-${hook:sha}", "IMAGE_TAG=abc123-abc123"},
+		{"echo ${hook:missing}", "echo ${hook:missing}"},
+		{"echo ${sha}", "echo ${sha}"},
+	}
+
+	for _, tt := range tests {
+		if got := expandHookCaptures(tt.input, captures); got != tt.expected {
+			t.Errorf("expandHookCaptures(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestResolveArtifactRefs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	run, err := artifacts.NewRun("analyze")
+	if err != nil {
+		t.Fatalf("NewRun failed: %v", err)
+	}
+	analysis := filepath.Join(run.Dir, "analysis.md")
+	if err := os.WriteFile(analysis, []byte("changes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ref := run.Ref("analysis.md")
+
+	resolved, err := resolveArtifactRefs([]string{ref, "input=" + ref, "branch=main", "plain"})
+	if err != nil {
+		t.Fatalf("resolveArtifactRefs failed: %v", err)
+	}
+	expected := []string{analysis, "input=" + analysis, "branch=main", "plain"}
+	for i := range expected {
+		if resolved[i] != expected[i] {
+			t.Errorf("argument %d: expected %s, got %s", i, expected[i], resolved[i])
+		}
+	}
+
+	if _, err := resolveArtifactRefs([]string{"input=" + artifacts.RefPrefix + "missing/analysis.md"}); err == nil {
+		t.Error("expected unknown artifact reference to fail")
+	}
+}
+
+func TestCreateFromAliasExpandsProjectVariables(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	shellInfo := &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"}
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"build": {
+				Cmd:       "go build -modfile=${PROJECT_PATH}/go.local.mod ./...",
+				IsEnabled: true,
+				PreExec:   []settings.Hook{{Cmd: "echo building ${PROJECT_NAME}"}},
+			},
+		},
+		Projects: map[string]settings.Project{
+			"api": {
+				Path:     "~/api",
+				Commands: []settings.Alias{{CommandName: "build"}},
+			},
+		},
+	}
+
+	f, err := NewFactory(cfg, execution.NewExecutor(), shellInfo)
+	if err != nil {
+		t.Fatalf("NewFactory failed: %v", err)
+	}
+	cmd, err := f.CreateFromAlias("api", "build")
+	if err != nil {
+		t.Fatalf("CreateFromAlias failed: %v", err)
+	}
+
+	projectPath := filepath.Join(homeDir, "api")
+	if expected := "go build -modfile=" + projectPath + "/go.local.mod ./..."; cmd.Args[1] != expected {
+		t.Errorf("Expected command %q, got %q", expected, cmd.Args[1])
+	}
+	if cmd.PreExec[0].Cmd != "echo building api" {
+		t.Errorf("Expected hook to reference the project name, got %q", cmd.PreExec[0].Cmd)
+	}
+	if cfg.Commands["build"].PreExec[0].Cmd != "echo building ${PROJECT_NAME}" {
+		t.Error("Expected configured hooks to be left untouched")
+	}
+}
+
+func TestShellCommandWithArgsQuotesValues(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	c := &Command{Path: "/bin/sh", Type: ShellCommand}
+	argDefs := []settings.CommandArgument{
+		{Name: "target", Type: settings.ArgumentTypeString},
+		{Name: "message", Type: settings.ArgumentTypeString},
+		{Name: "branch", Type: settings.ArgumentTypeString, Prefix: "--branch"},
+		{Name: "force", Type: settings.ArgumentTypeBool, Prefix: "--force"},
+	}
+	argsMap := map[string]string{
+		"target":  "it's \"here\"; touch pwned",
+		"message": "hi; touch pwned",
+		"branch":  "$(touch pwned)",
+		"force":   "true",
+		"extra":   "`touch pwned`",
+	}
+
+	command := c.shellCommandWithArgs(`printf '%s|' "${target}"`, argDefs, argsMap)
+
+	dir := t.TempDir()
+	run := exec.Command("sh", "-c", command)
+	run.Dir = dir
+	output, err := run.Output()
+	if err != nil {
+		t.Fatalf("sh -c %q failed: %v", command, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatalf("%q ran an injected command", command)
+	}
+	if expected := `it's "here"; touch pwned|` + "hi; touch pwned|--branch|$(touch pwned)|--force|extra=`touch pwned`|"; string(output) != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+	if len(argsMap) != 5 {
+		t.Error("Expected the arguments map to be left untouched")
+	}
+}
+
+func TestFactory_CreateWithNotification(t *testing.T) {
+	shellInfo := &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"}
+	testSettings := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"release": {IsEnabled: true, Cmd: "make release", NotifyAfter: "30s", NotifyBell: true},
+			"deploy":  {IsEnabled: true, Cmd: "make deploy", Notify: true},
+			"build":   {IsEnabled: true, Cmd: "make", NotifyAfter: "soon"},
+		},
+	}
+
+	factory, err := NewFactory(testSettings, execution.NewExecutor(), shellInfo)
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		notify bool
+		after  time.Duration
+		bell   bool
+	}{
+		{"release", true, 30 * time.Second, true},
+		{"deploy", true, 0, false},
+		{"build", false, 0, false},
+	}
+	for _, tt := range tests {
+		cmd, err := factory.Create(tt.name, "")
+		if err != nil {
+			t.Fatalf("Create(%s) error = %v", tt.name, err)
+		}
+		if cmd.Notify != tt.notify || cmd.NotifyAfter != tt.after || cmd.NotifyBell != tt.bell {
+			t.Errorf("Create(%s) notification = %v, %v, %v; want %v, %v, %v",
+				tt.name, cmd.Notify, cmd.NotifyAfter, cmd.NotifyBell, tt.notify, tt.after, tt.bell)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.hang]
+cmd = "exec sleep 5"
+timeout = "200ms"
+
+[commands.quick]
+cmd = "true"
+timeout = "soon"
+`)
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	factory, err := NewFactory(cfg, execution.NewExecutor(), &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"})
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+	t.Setenv("TMPDIR", env.Dir("tmp"))
+
+	cmd, err := factory.Create("hang", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	started := time.Now()
+	err = cmd.RunWithArgs(nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Errorf("RunWithArgs() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("Expected the command to stop after its timeout, it ran for %s", elapsed)
+	}
+
+	// An invalid timeout is ignored
+	cmd, err = factory.Create("quick", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if cmd.Timeout != 0 {
+		t.Errorf("Timeout = %s, want none for an invalid timeout", cmd.Timeout)
+	}
+	if err := cmd.RunWithArgs(nil); err != nil {
+		t.Errorf("RunWithArgs() error = %v", err)
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	env := testutil.New(t)
+	out := filepath.Join(env.Dir("out"), "args.txt")
+	env.WriteSettings(fmt.Sprintf(`
+[commands.report]
+interpreter = "sh -e"
+script = '''
+#!/bin/bash
+for arg in "$@"; do
+  echo "[$arg]" >> %q
+done
+'''
+arguments = [
+  { name = "title", type = "string" },
+  { name = "format", type = "string", prefix = "--format" },
+]
+`, out))
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	factory, err := NewFactory(cfg, execution.NewExecutor(), &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"})
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+	cmd, err := factory.Create("report", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if cmd.Type != ScriptCommand || cmd.Path != "sh" || strings.Join(cmd.Args, " ") != "-e" {
+		t.Errorf("Create() = %v %s %v, want a script run by sh -e", cmd.Type, cmd.Path, cmd.Args)
+	}
+
+	tmp := env.Dir("tmp")
+	t.Setenv("TMPDIR", tmp)
+	if err := cmd.RunWithArgs([]string{"weekly report", "format=md"}); err != nil {
+		t.Fatalf("RunWithArgs() error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[weekly report]\n[--format]\n[md]\n"; string(data) != want {
+		t.Errorf("script received %q, want %q", data, want)
+	}
+	if left, _ := filepath.Glob(filepath.Join(tmp, "interop-script-*")); len(left) > 0 {
+		t.Errorf("script files left behind: %v", left)
+	}
+}
+
+func TestRunArgumentValues(t *testing.T) {
+	env := testutil.New(t)
+	out := filepath.Join(env.Dir("out"), "args.txt")
+	env.WriteSettings(fmt.Sprintf(`
+[commands.deploy]
+cmd = 'echo "${env} ${replicas} ${dry_run}" > %s'
+arguments = [
+  { name = "env", type = "string", required = true },
+  { name = "replicas", type = "number", default = 2 },
+  { name = "dry_run", type = "bool", default = false },
+]
+`, out))
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	factory, err := NewFactory(cfg, execution.NewExecutor(), &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"})
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+	t.Setenv("TMPDIR", env.Dir("tmp"))
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{[]string{"prod"}, "prod 2 false\n", ""},
+		{[]string{"prod", "replicas=3", "dry_run=yes"}, "", "argument 'dry_run' must be true or false"},
+		{[]string{"env=staging", "dry_run=1"}, "staging 2 true\n", ""},
+		{nil, "", "required argument 'env' is missing"},
+		{[]string{"prod", "replicas=many"}, "", "argument 'replicas' must be a number"},
+	}
+	for _, tt := range tests {
+		os.Remove(out)
+		cmd, err := factory.Create("deploy", "")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		err = cmd.RunWithArgs(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RunWithArgs(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(out); statErr == nil {
+				t.Errorf("RunWithArgs(%q) ran the command despite invalid arguments", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("RunWithArgs(%q) error = %v", tt.args, err)
+			continue
+		}
+		if data, _ := os.ReadFile(out); string(data) != tt.want {
+			t.Errorf("RunWithArgs(%q) ran with %q, want %q", tt.args, data, tt.want)
+		}
+	}
+}
+
+func TestRunDependencies(t *testing.T) {
+	env := testutil.New(t)
+	out := filepath.Join(env.Dir("out"), "order.txt")
+	env.WriteSettings(fmt.Sprintf(`
+[commands.generate]
+cmd = 'echo generate >> %[1]s'
+
+[commands.build]
+cmd = 'echo build >> %[1]s'
+depends_on = ["generate"]
+
+[commands.lint]
+cmd = 'echo lint >> %[1]s'
+depends_on = ["generate"]
+
+[commands.release]
+cmd = 'echo release $1 >> %[1]s'
+depends_on = ["build", "lint"]
+arguments = [{ name = "version", type = "string" }]
+
+[commands.broken]
+cmd = 'echo broken >> %[1]s'
+depends_on = ["fail"]
+
+[commands.fail]
+cmd = 'exit 3'
+`, out))
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	factory, err := NewFactory(cfg, execution.NewExecutor(), &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"})
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+	t.Setenv("TMPDIR", env.Dir("tmp"))
+
+	// Shared dependencies run once, before everything depending on them
+	cmd, err := factory.Create("release", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := cmd.RunWithArgs([]string{"1.0"}); err != nil {
+		t.Fatalf("RunWithArgs() error = %v", err)
+	}
+	data, _ := os.ReadFile(out)
+	if want := "generate\nbuild\nlint\nrelease 1.0\n"; string(data) != want {
+		t.Errorf("commands ran as %q, want %q", data, want)
+	}
+
+	// A failing dependency stops the run
+	os.Remove(out)
+	cmd, err = factory.Create("broken", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := cmd.RunWithArgs(nil); err == nil || !strings.Contains(err.Error(), "Dependency 'fail' of command 'broken' failed") {
+		t.Errorf("RunWithArgs() error = %v, want the failed dependency", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("Expected the command not to run after its dependency failed")
+	}
+
+	// Cycles are refused when creating the command
+	cfg.Commands["generate"] = settings.CommandConfig{IsEnabled: true, Cmd: "true", DependsOn: []string{"release"}}
+	if _, err := factory.Create("release", ""); err == nil || !strings.Contains(err.Error(), "cyclic depends_on") {
+		t.Errorf("Create() with a cycle error = %v", err)
+	}
+}
+
+func TestHooksGetCommandEnv(t *testing.T) {
+	env := testutil.New(t)
+	out := filepath.Join(env.Dir("out"), "hooks.txt")
+	bin := env.Dir("bin")
+	if err := os.WriteFile(filepath.Join(bin, "myhelper"), []byte("#!/bin/sh\necho helper\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env.WriteSettings(fmt.Sprintf(`
+executable_search_paths = [%[2]q]
+
+[env]
+GLOBAL_VAR = "global"
+
+[commands.build]
+cmd = 'echo "main=$GLOBAL_VAR $COMMAND_VAR $(myhelper)" >> %[1]s'
+env = { COMMAND_VAR = "command" }
+pre_exec = ['echo "pre=$GLOBAL_VAR $COMMAND_VAR $(myhelper)" >> %[1]s']
+post_exec = ['echo "post=$GLOBAL_VAR $COMMAND_VAR $(myhelper)" >> %[1]s']
+`, out, bin))
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	factory, err := NewFactory(cfg, execution.NewExecutor(), &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"})
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+	t.Setenv("TMPDIR", env.Dir("tmp"))
+
+	cmd, err := factory.Create("build", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := cmd.RunWithArgs(nil); err != nil {
+		t.Fatalf("RunWithArgs() error = %v", err)
+	}
+	data, _ := os.ReadFile(out)
+	if want := "pre=global command helper\nmain=global command helper\npost=global command helper\n"; string(data) != want {
+		t.Errorf("hooks and command printed %q, want %q", data, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	env := testutil.New(t)
+	out := filepath.Join(env.Dir("out"), "ran.txt")
+	env.WriteSettings(fmt.Sprintf(`
+[env]
+REGION = "eu"
+
+[commands.setup]
+cmd = 'echo setup >> %[1]s'
+
+[commands.deploy]
+cmd = 'echo deploying ${service} >> %[1]s'
+depends_on = ["setup"]
+env = { STAGE = "prod" }
+pre_exec = [{ cmd = "git fetch", capture = "fetched" }]
+arguments = [
+  { name = "service", type = "string", required = true },
+  { name = "notes", type = "string", from_file = true },
+  { name = "force", type = "bool", prefix = "--force" },
+]
+
+[commands.report]
+script = '''
+#!/bin/sh
+echo report >> %[1]s
+'''
+`, out))
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	factory, err := NewFactory(cfg, execution.NewExecutor(), &shell.Info{Path: "/bin/sh", Option: "-c", Name: "sh"})
+	if err != nil {
+		t.Fatalf("Failed to create factory: %v", err)
+	}
+	t.Setenv("TMPDIR", env.Dir("tmp"))
+	t.Setenv("REGION", "us")
+
+	cmd, err := factory.Create("deploy", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	cmd.EnvOverrides = []string{"DEBUG=1"}
+	plan, err := cmd.DryRun([]string{"api server", "notes=long text", "force=true"})
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	if want := fmt.Sprintf("echo deploying 'api server' >> %s '<file of notes>' --force", out); plan.Line != want {
+		t.Errorf("Line = %q, want %q", plan.Line, want)
+	}
+	// PATH gains the executables directories too
+	var changed []string
+	for _, variable := range plan.Env {
+		if !strings.HasPrefix(variable, "PATH=") {
+			changed = append(changed, variable)
+		}
+	}
+	if got := strings.Join(changed, " "); got != "DEBUG=1 REGION=eu STAGE=prod" {
+		t.Errorf("Env = %q, want the variables the run sets", plan.Env)
+	}
+	if len(plan.PreExec) != 1 || plan.PreExec[0] != "git fetch" {
+		t.Errorf("PreExec = %q", plan.PreExec)
+	}
+	if len(plan.Dependencies) != 1 || plan.Dependencies[0].Name != "setup" || !strings.Contains(plan.Dependencies[0].Env[0], "DEBUG=1") {
+		t.Errorf("Dependencies = %+v, want setup with the run's env", plan.Dependencies)
+	}
+	if _, err := cmd.DryRun(nil); err == nil || !strings.Contains(err.Error(), "required argument 'service' is missing") {
+		t.Errorf("DryRun() without arguments error = %v", err)
+	}
+
+	cmd, err = factory.Create("report", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if plan, err = cmd.DryRun(nil); err != nil || plan.Line != "/bin/sh <script>" || !strings.Contains(plan.Script, "echo report") {
+		t.Errorf("DryRun() of a script = %+v, %v", plan, err)
+	}
+
+	// Nothing ran and no files were written
+	if _, err := os.Stat(out); err == nil {
+		t.Error("Expected a dry run not to run anything")
+	}
+	if left, _ := os.ReadDir(env.Dir("tmp")); len(left) > 0 {
+		t.Errorf("Expected a dry run not to write files, found %d", len(left))
+	}
+}
@@ -0,0 +1,300 @@
+package condition
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// Context holds the values an expression can reference
+type Context struct {
+	OS   string            // Value of the `os` identifier
+	Arch string            // Value of the `arch` identifier
+	Env  map[string]string // Values of `env.<NAME>` identifiers
+}
+
+// NewContext returns a context for the current platform and process environment
+func NewContext() Context {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return Context{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		Env:  env,
+	}
+}
+
+// Evaluate parses and evaluates a condition such as
+// `os == 'darwin' && env.CI != 'true'`.
+//
+// Supported syntax:
+//   - identifiers: os, arch, env.<NAME> (unset variables are empty strings)
+//   - literals: 'single' or "double" quoted strings, true, false
+//   - operators: == != ! && || and parentheses
+//
+// A string used where a boolean is expected is true when non-empty.
+func Evaluate(expr string, ctx Context) (bool, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return false, err
+	}
+	p.ctx = ctx
+
+	v, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+	if p.peek().kind != tokenEOF {
+		return false, fmt.Errorf("unexpected '%s' in condition %q", p.peek().text, expr)
+	}
+	return v.truthy(), nil
+}
+
+// Validate reports whether a condition is syntactically valid
+func Validate(expr string) error {
+	_, err := Evaluate(expr, Context{})
+	return err
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits an expression into tokens
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")"})
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in condition %q", expr)
+			}
+			tokens = append(tokens, token{tokenString, string(runes[i+1 : end])})
+			i = end + 1
+		case strings.ContainsRune("=!&|", r):
+			if i+1 < len(runes) {
+				pair := string(runes[i : i+2])
+				if pair == "==" || pair == "!=" || pair == "&&" || pair == "||" {
+					tokens = append(tokens, token{tokenOp, pair})
+					i += 2
+					continue
+				}
+			}
+			if r == '!' {
+				tokens = append(tokens, token{tokenOp, "!"})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected '%c' in condition %q", r, expr)
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_' || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[i:end])})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in condition %q", r, expr)
+		}
+	}
+	return append(tokens, token{tokenEOF, ""}), nil
+}
+
+// value is the result of evaluating a sub-expression
+type value struct {
+	str    string
+	isBool bool
+	b      bool
+}
+
+func (v value) truthy() bool {
+	if v.isBool {
+		return v.b
+	}
+	return v.str != ""
+}
+
+func (v value) String() string {
+	if v.isBool {
+		return fmt.Sprintf("%t", v.b)
+	}
+	return v.str
+}
+
+func boolValue(b bool) value {
+	return value{isBool: true, b: b}
+}
+
+// parser is a recursive descent parser that evaluates while parsing
+type parser struct {
+	expr   string
+	tokens []token
+	pos    int
+	ctx    Context
+}
+
+func newParser(expr string) (*parser, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("condition is empty")
+	}
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{expr: expr, tokens: tokens}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseOr handles `a || b`
+func (p *parser) parseOr() (value, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return value{}, err
+	}
+	for p.peek().kind == tokenOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return value{}, err
+		}
+		left = boolValue(left.truthy() || right.truthy())
+	}
+	return left, nil
+}
+
+// parseAnd handles `a && b`
+func (p *parser) parseAnd() (value, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return value{}, err
+	}
+	for p.peek().kind == tokenOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return value{}, err
+		}
+		left = boolValue(left.truthy() && right.truthy())
+	}
+	return left, nil
+}
+
+// parseComparison handles `a == b` and `a != b`
+func (p *parser) parseComparison() (value, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return value{}, err
+	}
+	if t := p.peek(); t.kind == tokenOp && (t.text == "==" || t.text == "!=") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return value{}, err
+		}
+		equal := left.String() == right.String()
+		if t.text == "==" {
+			return boolValue(equal), nil
+		}
+		return boolValue(!equal), nil
+	}
+	return left, nil
+}
+
+// parseUnary handles `!a`
+func (p *parser) parseUnary() (value, error) {
+	if t := p.peek(); t.kind == tokenOp && t.text == "!" {
+		p.next()
+		v, err := p.parseUnary()
+		if err != nil {
+			return value{}, err
+		}
+		return boolValue(!v.truthy()), nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary handles literals, identifiers and parenthesized expressions
+func (p *parser) parsePrimary() (value, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return value{str: t.text}, nil
+	case tokenLParen:
+		v, err := p.parseOr()
+		if err != nil {
+			return value{}, err
+		}
+		if p.next().kind != tokenRParen {
+			return value{}, fmt.Errorf("missing ')' in condition %q", p.expr)
+		}
+		return v, nil
+	case tokenIdent:
+		return p.resolve(t.text)
+	case tokenEOF:
+		return value{}, fmt.Errorf("unexpected end of condition %q", p.expr)
+	default:
+		return value{}, fmt.Errorf("unexpected '%s' in condition %q", t.text, p.expr)
+	}
+}
+
+// resolve returns the value of an identifier
+func (p *parser) resolve(name string) (value, error) {
+	switch {
+	case name == "true":
+		return boolValue(true), nil
+	case name == "false":
+		return boolValue(false), nil
+	case name == "os":
+		return value{str: p.ctx.OS}, nil
+	case name == "arch":
+		return value{str: p.ctx.Arch}, nil
+	case strings.HasPrefix(name, "env.") && len(name) > len("env."):
+		return value{str: p.ctx.Env[strings.TrimPrefix(name, "env.")]}, nil
+	default:
+		return value{}, fmt.Errorf("unknown identifier '%s' in condition %q", name, p.expr)
+	}
+}
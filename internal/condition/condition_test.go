@@ -0,0 +1,64 @@
+package condition
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	ctx := Context{
+		OS:   "darwin",
+		Arch: "arm64",
+		Env:  map[string]string{"CI": "true", "STAGE": "dev"},
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"os == 'darwin'", true},
+		{"os == \"linux\"", false},
+		{"os != 'linux'", true},
+		{"os == 'darwin' && env.CI != 'true'", false},
+		{"os == 'darwin' && env.CI == 'true'", true},
+		{"os == 'linux' || arch == 'arm64'", true},
+		{"!(os == 'linux')", true},
+		{"env.CI", true},
+		{"env.MISSING", false},
+		{"!env.MISSING", true},
+		{"env.MISSING == ''", true},
+		{"true && !false", true},
+		{"(env.STAGE == 'prod' || env.STAGE == 'dev') && os == 'darwin'", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) returned error: %v", tt.expr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []string{
+		"",
+		"os ==",
+		"os = 'darwin'",
+		"platform == 'darwin'",
+		"(os == 'darwin'",
+		"os == 'darwin",
+		"os == 'darwin' 'linux'",
+	}
+
+	for _, expr := range invalid {
+		if err := Validate(expr); err == nil {
+			t.Errorf("Validate(%q) expected an error", expr)
+		}
+	}
+
+	if err := Validate("os == 'darwin' && env.CI != 'true'"); err != nil {
+		t.Errorf("Validate returned error for valid condition: %v", err)
+	}
+}
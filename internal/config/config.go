@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"interop/internal/logging"
+	"interop/internal/path"
 	"os"
 	"path/filepath"
 
@@ -12,7 +13,7 @@ import (
 
 // PathConfig defines the directory structure for settings
 type PathConfig struct {
-	SettingsDir    string
+	SettingsDir    string // Legacy config root relative to $HOME, migrated into the platform config dir
 	AppDir         string
 	CfgFile        string
 	ExecutablesDir string
@@ -47,30 +48,28 @@ func WithCustomPath(pathConfig PathConfig) *Manager {
 	}
 }
 
+// GetAppDir returns the application configuration directory
+func (m *Manager) GetAppDir() (string, error) {
+	return path.AppConfigDir(m.PathConfig.SettingsDir, m.PathConfig.AppDir)
+}
+
 // GetConfigFilePath returns the path to the configuration file
 func (m *Manager) GetConfigFilePath() (string, error) {
-	root, err := os.UserHomeDir()
+	base, err := m.GetAppDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
 
-	config := filepath.Join(root, m.PathConfig.SettingsDir)
-	base := filepath.Join(config, m.PathConfig.AppDir)
-	path := filepath.Join(base, m.PathConfig.CfgFile)
-
-	return path, nil
+	return filepath.Join(base, m.PathConfig.CfgFile), nil
 }
 
 // EnsureConfigDirectories creates the necessary directories for the configuration
 func (m *Manager) EnsureConfigDirectories() error {
-	root, err := os.UserHomeDir()
+	base, err := m.GetAppDir()
 	if err != nil {
-		return fmt.Errorf("failed to get user home directory: %w", err)
+		return err
 	}
 
-	config := filepath.Join(root, m.PathConfig.SettingsDir)
-	base := filepath.Join(config, m.PathConfig.AppDir)
-
 	if err := os.MkdirAll(base, 0o755); err != nil {
 		return fmt.Errorf("failed to create settings directory: %w", err)
 	}
@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -39,9 +40,21 @@ func PrintCommandGraph(cfg *settings.Settings) {
 	// Track which commands are used with aliases
 	aliasedCommands := make(map[string]map[string]string) // command -> map[alias]projectName
 
+	// References to commands that aren't defined, as "project: command" lines
+	var missing []string
+
 	// Build the relationship maps
 	for projectName, project := range cfg.Projects {
 		for _, cmdAlias := range project.Commands {
+			if _, exists := cfg.Commands[cmdAlias.CommandName]; !exists {
+				ref := fmt.Sprintf("%s: %s", projectName, cmdAlias.CommandName)
+				if cmdAlias.Alias != "" {
+					ref += fmt.Sprintf(" (alias: %s)", cmdAlias.Alias)
+				}
+				missing = append(missing, ref)
+				continue
+			}
+
 			// Handle commands bound directly (no alias)
 			if cmdAlias.Alias == "" {
 				projectBoundCommands[cmdAlias.CommandName] = append(
@@ -63,6 +76,7 @@ func PrintCommandGraph(cfg *settings.Settings) {
 
 	// Print the command graph with source information
 	printCommands(cfg, projectBoundCommands, aliasedCommands)
+	printMissingReferences(missing)
 
 	// Print legend
 	printLegend()
@@ -73,14 +87,12 @@ func printConfigurationSources(cfg *settings.Settings) {
 	fmt.Println("\nConfiguration Sources:")
 	fmt.Println("---------------------")
 
-	homeDir, _ := os.UserHomeDir()
-	if homeDir == "" {
-		fmt.Printf("%s Unable to determine home directory\n", ConflictSymbol)
+	configDir, _ := settings.GetAppDir()
+	if configDir == "" {
+		fmt.Printf("%s Unable to determine config directory\n", ConflictSymbol)
 		return
 	}
 
-	configDir := filepath.Join(homeDir, ".config", "interop")
-
 	// Show main settings file
 	mainSettingsPath := filepath.Join(configDir, "settings.toml")
 	if _, err := os.Stat(mainSettingsPath); err == nil {
@@ -137,8 +149,12 @@ func printMCPServers(cfg *settings.Settings) {
 	fmt.Println("-----------")
 
 	// Default MCP server
-	fmt.Printf("%s Default MCP Server (Port: %d)\n", MCPServerSymbol, cfg.MCPPort)
-	fmt.Println("   └─ Commands: (commands with no MCP field)")
+	if cfg.DefaultMCPServerEnabled() {
+		fmt.Printf("%s Default MCP Server (Port: %d)\n", MCPServerSymbol, cfg.MCPPort)
+		fmt.Println("   └─ Commands: (commands with no MCP field)")
+	} else {
+		fmt.Printf("%s Default MCP Server disabled (default_mcp_enabled = false)\n", MCPServerSymbol)
+	}
 	fmt.Println()
 
 	// Named MCP servers
@@ -151,8 +167,8 @@ func printMCPServers(cfg *settings.Settings) {
 
 			// Count commands assigned to this server
 			cmdCount := 0
-			for _, cmd := range cfg.Commands {
-				if cmd.MCP == name {
+			for cmdName, cmd := range cfg.Commands {
+				if cfg.ServedBy(name, cmdName, cmd) {
 					cmdCount++
 				}
 			}
@@ -194,22 +210,25 @@ func printCommands(cfg *settings.Settings, projectBoundCommands map[string][]str
 			execType = ExecutableCommandLabel
 		}
 
-		// Determine source (this is where we'd need to track where commands come from)
-		sourceInfo := determineCommandSource(cmdName)
+		sourceInfo := commandSource(cmdConfig.SourceFile, cmdConfig.SourceLine, cmdConfig.Shadowed)
 
 		// Print the command details with source information
 		fmt.Printf("%s %s %s %s %s\n", typeSymbol, enabledSymbol, cmdName, execType, sourceInfo)
 
-		// Print MCP server assignment if available
-		if cmdConfig.MCP != "" {
-			// Get server details
-			if server, exists := cfg.MCPServers[cmdConfig.MCP]; exists {
-				fmt.Printf("   └─ %s Assigned to MCP server: %s (Port: %d)\n", MCPServerSymbol, cmdConfig.MCP, server.Port)
-			} else {
-				fmt.Printf("   └─ %s Warning: Assigned to undefined MCP server: %s\n", CommandDisabledSymbol, cmdConfig.MCP)
+		// Print MCP server assignments if available
+		if len(cmdConfig.MCP) > 0 {
+			for _, serverName := range cmdConfig.MCP {
+				// Get server details
+				if server, exists := cfg.MCPServers[serverName]; exists {
+					fmt.Printf("   └─ %s Assigned to MCP server: %s (Port: %d)\n", MCPServerSymbol, serverName, server.Port)
+				} else {
+					fmt.Printf("   └─ %s Warning: Assigned to undefined MCP server: %s\n", CommandDisabledSymbol, serverName)
+				}
 			}
-		} else {
+		} else if cfg.DefaultMCPServerEnabled() {
 			fmt.Printf("   └─ %s Default MCP server (Port: %d)\n", MCPServerSymbol, cfg.MCPPort)
+		} else {
+			fmt.Printf("   └─ %s Not on the default MCP server, which is disabled\n", CommandDisabledSymbol)
 		}
 
 		// Print project associations
@@ -229,82 +248,45 @@ func printCommands(cfg *settings.Settings, projectBoundCommands map[string][]str
 	}
 }
 
-// determineCommandSource attempts to determine where a command comes from
-func determineCommandSource(cmdName string) string {
-	homeDir, _ := os.UserHomeDir()
-	if homeDir == "" {
-		return ""
+// printMissingReferences warns about project commands whose referenced
+// command isn't defined
+func printMissingReferences(missing []string) {
+	if len(missing) == 0 {
+		return
 	}
+	sort.Strings(missing)
 
-	configDir := filepath.Join(homeDir, ".config", "interop")
-
-	remoteConfigDir := filepath.Join(configDir, "config.d.remote")
-	localConfigDir := filepath.Join(configDir, "config.d")
-
-	localHas := false
-	remoteHas := false
-
-	if _, err := os.Stat(localConfigDir); err == nil {
-		localHas = findCommandInDir(localConfigDir, cmdName)
-	}
-	if _, err := os.Stat(remoteConfigDir); err == nil {
-		remoteHas = findCommandInDir(remoteConfigDir, cmdName)
+	fmt.Println("Missing Command References:")
+	fmt.Println("--------------------------")
+	for _, ref := range missing {
+		fmt.Printf("%s %s (referenced command not found)\n", ConflictSymbol, ref)
 	}
+	fmt.Println()
+}
 
-	if localHas && remoteHas {
-		return "(☁️ Remote, but 🏠 Local override)"
-	}
-	if localHas {
-		return fmt.Sprintf("(%s Local)", LocalSymbol)
-	}
-	if remoteHas {
-		return fmt.Sprintf("(%s Remote)", RemoteSymbol)
+// commandSource describes where a command was loaded from, given its source
+// file and line and the locations of the definitions it took precedence over
+func commandSource(file string, line int, shadowed []string) string {
+	location := settings.SourceLocation(file, line)
+	if location == "" {
+		return fmt.Sprintf("(%s Unknown)", ConflictSymbol)
 	}
 
-	mainSettingsPath := filepath.Join(configDir, "settings.toml")
-	if found := findCommandInMainSettings(mainSettingsPath, cmdName); found {
-		return fmt.Sprintf("(%s Main Settings)", LocalSymbol)
+	configDir, _ := settings.GetAppDir()
+	remoteConfigDir := filepath.Join(configDir, "config.d.remote")
+	if configDir != "" && strings.HasPrefix(file, remoteConfigDir+string(filepath.Separator)) {
+		return fmt.Sprintf("(%s Remote, %s)", RemoteSymbol, location)
 	}
 
-	return fmt.Sprintf("(%s Unknown)", ConflictSymbol)
-}
-
-// findCommandInDir searches for a command in a directory of TOML files
-func findCommandInDir(dirPath, cmdName string) bool {
-	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || !strings.HasSuffix(path, ".toml") {
-			return nil
-		}
-
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-
-		// Simple check if command name appears in file
-		if strings.Contains(string(data), fmt.Sprintf(`[commands.%s]`, cmdName)) ||
-			strings.Contains(string(data), fmt.Sprintf(`"%s"`, cmdName)) {
-			return fmt.Errorf("found") // Use error to break out of walk
+	for _, other := range shadowed {
+		if strings.HasPrefix(other, "config.d.remote"+string(filepath.Separator)) {
+			return fmt.Sprintf("(%s Remote, but %s Local override, %s)", RemoteSymbol, LocalSymbol, location)
 		}
-
-		return nil
-	})
-
-	return err != nil && err.Error() == "found"
-}
-
-// findCommandInMainSettings checks if a command is defined in the main settings file
-func findCommandInMainSettings(filePath, cmdName string) bool {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return false
 	}
-
-	return strings.Contains(string(data), fmt.Sprintf(`[commands.%s]`, cmdName)) ||
-		strings.Contains(string(data), fmt.Sprintf(`"%s"`, cmdName))
+	if configDir != "" && file == filepath.Join(configDir, "settings.toml") {
+		return fmt.Sprintf("(%s Main Settings, %s)", LocalSymbol, location)
+	}
+	return fmt.Sprintf("(%s Local, %s)", LocalSymbol, location)
 }
 
 // printLegend shows the legend for all symbols used
@@ -30,8 +30,8 @@ func PrintProjectPath(path string) {
 }
 
 // PrintProjectStatus prints the project status
-func PrintProjectStatus(valid, inHomeDir string) {
-	fmt.Printf("   Status: Valid: %s  |  In $HOME: %s\n", valid, inHomeDir)
+func PrintProjectStatus(valid, allowed string) {
+	fmt.Printf("   Status: Valid: %s  |  In allowed roots: %s\n", valid, allowed)
 }
 
 // PrintProjectDescription prints a project description if present
@@ -73,38 +73,119 @@ func PrintCommandDescription(description string) {
 	}
 }
 
-// PrintCommandSource prints the source information for a command
-func PrintCommandSource(name string) {
-	sourceInfo := determineCommandSource(name)
-	if sourceInfo != "" {
-		fmt.Printf("   %s\n", sourceInfo)
-	}
+// PrintCommandSource prints where a command was loaded from, given its source
+// file and line and the locations of the definitions it took precedence over
+func PrintCommandSource(file string, line int, shadowed []string) {
+	fmt.Printf("   %s\n", commandSource(file, line, shadowed))
 }
 
 // PrintProjectCommands prints the commands for a project
-func PrintProjectCommands(commandName, alias, description string) {
+func PrintProjectCommands(commandName, alias, description string, isEnabled bool) {
+	icon, name := "⚡", commandName
 	if alias != "" {
-		fmt.Printf("      ⚡ %s (alias: %s)\n", commandName, alias)
-	} else {
-		fmt.Printf("      ⚡ %s\n", commandName)
+		name = fmt.Sprintf("%s (alias: %s)", commandName, alias)
+	}
+	if !isEnabled {
+		icon, name = "✗", name+" (disabled)"
 	}
+	fmt.Printf("      %s %s\n", icon, name)
 
 	if description != "" {
 		fmt.Printf("         %s\n", description)
 	}
 }
 
+// PrintProjectCommandDetails prints what a project command runs and where it
+// was loaded from
+func PrintProjectCommandDetails(cmd, file string, line int, shadowed []string) {
+	fmt.Printf("         Cmd: %s\n", cmd)
+	fmt.Printf("         %s\n", commandSource(file, line, shadowed))
+}
+
+// PrintHiddenCommands prints how many of a project's commands were left out
+func PrintHiddenCommands(count int) {
+	if count > 0 {
+		fmt.Printf("      (%d disabled or missing command(s) hidden)\n", count)
+	}
+}
+
 // PrintSeparator prints a blank line as a separator
 func PrintSeparator() {
 	fmt.Println()
 }
 
 // PrintUnresolvedCommand prints a warning about an unresolved command
-func PrintUnresolvedCommand(commandName string) {
+func PrintUnresolvedCommand(commandName, alias string) {
+	if alias != "" {
+		fmt.Printf("      ⚠️ %s (alias: %s, referenced command not found)\n", commandName, alias)
+		return
+	}
 	fmt.Printf("      ⚠️ %s (referenced command not found)\n", commandName)
 }
 
-// PrintNoItemsFound prints a message when no items are found
+// emptyState is what an empty listing suggests doing next
+type emptyState struct {
+	snippet string   // Example definition for settings.toml
+	next    []string // Commands to take the next step with
+}
+
+// emptyStates holds the next steps of the listings that can be empty, by the
+// item type passed to PrintNoItemsFound
+var emptyStates = map[string]emptyState{
+	"projects": {
+		snippet: `[projects.my-app]
+path = "~/projects/my-app"
+commands = [{ command_name = "build", alias = "b" }]`,
+		next: []string{"interop init", "interop config edit"},
+	},
+	"commands": {
+		snippet: `[commands.build]
+cmd = "make build"
+description = "Build the project"`,
+		next: []string{"interop init", "interop config edit", "interop config remote add <name> <git-url>"},
+	},
+	"prompts": {
+		snippet: `[prompts.review]
+description = "Review the staged changes"
+content = "Review the staged changes and point out bugs."`,
+		next: []string{"interop config edit"},
+	},
+	"jobs": {
+		next: []string{"interop run --detach <command>"},
+	},
+	"remote repositories": {
+		next: []string{"interop config remote add <name> <git-url>"},
+	},
+}
+
+// PrintNoItemsFound prints a message when no items are found, followed by an
+// example definition and the commands to add some when there are any
 func PrintNoItemsFound(itemType string) {
 	fmt.Printf("No %s found.\n", itemType)
+
+	state, ok := emptyStates[itemType]
+	if !ok {
+		return
+	}
+	if state.snippet != "" {
+		fmt.Println()
+		fmt.Println("Define them in settings.toml or a file in config.d, for example:")
+		fmt.Println()
+		for _, line := range strings.Split(state.snippet, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if len(state.next) > 0 {
+		fmt.Println()
+		fmt.Println("Next steps:")
+		for _, cmd := range state.next {
+			fmt.Printf("  %s\n", cmd)
+		}
+	}
+}
+
+// PrintProjectWithoutCommands prints a hint under a project that has no
+// commands bound
+func PrintProjectWithoutCommands() {
+	fmt.Println("      No commands bound, add them with commands = [{ command_name = \"build\" }]")
 }
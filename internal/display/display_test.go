@@ -2,10 +2,16 @@ package display
 
 import (
 	"bytes"
+	"fmt"
+	"interop/internal/command/factory"
+	"interop/internal/execution"
+	"interop/internal/history"
+	"interop/internal/settings"
 	"io"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func captureOutput(f func()) string {
@@ -63,3 +69,184 @@ func TestPrintNoItemsFound(t *testing.T) {
 		t.Errorf("Expected output to contain 'No projects found.', got %s", output)
 	}
 }
+
+func TestPrintNoItemsFoundHints(t *testing.T) {
+	output := captureOutput(func() {
+		PrintNoItemsFound("commands")
+	})
+	for _, want := range []string{"No commands found.", "[commands.build]", "Next steps:", "interop init"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %s", want, output)
+		}
+	}
+
+	output = captureOutput(func() {
+		PrintNoItemsFound("widgets")
+	})
+	if output != "No widgets found.\n" {
+		t.Errorf("Expected no hints for an unknown item type, got %s", output)
+	}
+}
+
+func TestPrintRunCatalog(t *testing.T) {
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"build":    {IsEnabled: true, Description: "Build the application\nWith all targets", Tags: []string{"go"}},
+			"test":     {IsEnabled: true, Tags: []string{"go", "slow"}},
+			"deploy":   {IsEnabled: true},
+			"disabled": {IsEnabled: false},
+		},
+		Projects: map[string]settings.Project{
+			"api": {Commands: []settings.Alias{{CommandName: "build", Alias: "b"}, {CommandName: "test"}}},
+		},
+	}
+	for i := 0; i < catalogGroupLimit+2; i++ {
+		cfg.Commands[fmt.Sprintf("task%02d", i)] = settings.CommandConfig{IsEnabled: true}
+	}
+
+	output := captureOutput(func() { PrintRunCatalog(cfg) })
+	for _, want := range []string{"  go:\n", "build  Build the application\n", "  other:\n", "... and 3 more", "interop commands", "api: b → build"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the catalog to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "disabled") {
+		t.Errorf("Expected disabled commands to be left out, got:\n%s", output)
+	}
+	if strings.Index(output, "go:") > strings.Index(output, "other:") {
+		t.Errorf("Expected untagged commands last, got:\n%s", output)
+	}
+}
+
+func TestPrintCommandHelp(t *testing.T) {
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"generate": {
+				IsEnabled:   true,
+				Description: "Generate a component",
+				Version:     "1.2.0",
+				MCP:         settings.ServerList{"tools"},
+				DependsOn:   []string{"setup", "lint"},
+				Arguments: []settings.CommandArgument{
+					{Name: "type", Required: true, Description: "Component type"},
+					{Name: "force", Type: settings.ArgumentTypeBool, Default: false, Prefix: "--force"},
+				},
+				Examples: []settings.CommandExample{{Description: "A button", Command: "interop run generate type=button"}},
+			},
+		},
+		Projects: map[string]settings.Project{
+			"web": {Commands: []settings.Alias{{CommandName: "generate", Alias: "gen"}}},
+		},
+	}
+
+	output := captureOutput(func() {
+		if err := PrintCommandHelp(cfg, "gen"); err != nil {
+			t.Errorf("PrintCommandHelp() error = %v", err)
+		}
+	})
+	for _, want := range []string{
+		"generate v1.2.0\n",
+		"Alias of 'generate' in project 'web'",
+		"interop run gen <type> [force]",
+		"type   string  yes                Component type",
+		"force  bool    no        false    (passed as --force)",
+		"# A button\n  interop run generate type=button",
+		"MCP server: tools  |  Depends on: setup, lint",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the help to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	if err := PrintCommandHelp(cfg, "missing"); err == nil {
+		t.Error("Expected an error for an unknown command")
+	}
+}
+
+func TestListPrompts(t *testing.T) {
+	prompts := map[string]settings.PromptConfig{
+		"review": {
+			Description: "Review a change",
+			MCP:         settings.ServerList{"build", "ci"},
+			Arguments: []settings.CommandArgument{
+				{Name: "focus", Required: true},
+				{Name: "depth", Type: settings.ArgumentTypeNumber, Default: 2},
+			},
+		},
+		"explain": {Description: "Explain the code"},
+	}
+
+	output := captureOutput(func() { ListPrompts(prompts) })
+	for _, want := range []string{"PROMPTS:", "MCP servers: build, ci\n", "MCP server: default\n", "Arguments: focus (required), depth (number, default 2)\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the listing to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Index(output, "explain") > strings.Index(output, "review") {
+		t.Errorf("Expected prompts sorted by name, got:\n%s", output)
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	plan := &factory.Plan{
+		Name:    "deploy",
+		Line:    "./deploy.sh api",
+		Dir:     "/srv/app",
+		Env:     []string{"STAGE=prod"},
+		PreExec: []string{"git fetch"},
+		Dependencies: []*factory.Plan{
+			{Name: "setup", Line: "make setup"},
+		},
+	}
+
+	output := captureOutput(func() { PrintPlan(plan) })
+	for _, want := range []string{
+		"Dependency 'setup':\n  Run:         make setup\n  Directory:   (current directory)\n  Environment: unchanged\n",
+		"Command 'deploy':\n  Pre-exec:    git fetch\n  Run:         ./deploy.sh api\n  Directory:   /srv/app\n  Environment:\n    STAGE=prod\n",
+		"Dry run, nothing was run.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the plan to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Index(output, "setup") > strings.Index(output, "deploy") {
+		t.Errorf("Expected dependencies first, got:\n%s", output)
+	}
+}
+
+func TestPrintParallelSummary(t *testing.T) {
+	output := captureOutput(func() {
+		PrintParallelSummary([]execution.JobResult{
+			{Name: "lint", Duration: 1500 * time.Millisecond},
+			{Name: "test", ExitCode: 2, Err: fmt.Errorf("exit status 2"), Duration: time.Second},
+		})
+	})
+
+	for _, want := range []string{"lint  ✅ ok", "test  ❌ failed (exit 2)", "1 of 2 commands failed"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the summary to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	started := time.Date(2025, 3, 1, 9, 30, 0, 0, time.Local)
+	entry := &history.Entry{
+		ID: "a1b2c3d4", Command: "deploy", Args: []string{"api"}, Project: "shop", ExitCode: 2,
+		Started: started, Finished: started.Add(1500 * time.Millisecond), Dir: "/srv/shop",
+	}
+
+	output := captureOutput(func() { PrintHistory([]*history.Entry{entry}) })
+	for _, want := range []string{"❌ failed (exit 2) a1b2c3d4  deploy api (project: shop)", "Started: 2025-03-01 09:30:00  |  Duration: 1.5s"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the history to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	output = captureOutput(func() { PrintHistoryEntry(entry) })
+	for _, want := range []string{"Run a1b2c3d4: deploy api (project: shop)", "Directory: /srv/shop", "Rerun:     interop history rerun a1b2c3d4", "not captured"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the run to contain %q, got:\n%s", want, output)
+		}
+	}
+}
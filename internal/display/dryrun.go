@@ -0,0 +1,55 @@
+package display
+
+import (
+	"fmt"
+	"interop/internal/command/factory"
+	"strings"
+)
+
+// PrintPlan prints what a run would do, for interop run --dry-run: the plans
+// of its dependencies in the order they run, then its own
+func PrintPlan(plan *factory.Plan) {
+	for _, dependency := range plan.Dependencies {
+		printPlanSteps("Dependency", dependency)
+		fmt.Println()
+	}
+	printPlanSteps("Command", plan)
+	fmt.Println()
+	fmt.Println("Dry run, nothing was run.")
+}
+
+func printPlanSteps(kind string, plan *factory.Plan) {
+	fmt.Printf("%s '%s':\n", kind, plan.Name)
+	if plan.When != "" {
+		fmt.Printf("  When:        %s\n", plan.When)
+	}
+	for _, hook := range plan.PreExec {
+		fmt.Printf("  Pre-exec:    %s\n", hook)
+	}
+	fmt.Printf("  Run:         %s\n", plan.Line)
+	for _, hook := range plan.PostExec {
+		fmt.Printf("  Post-exec:   %s\n", hook)
+	}
+
+	dir := plan.Dir
+	if dir == "" {
+		dir = "(current directory)"
+	}
+	fmt.Printf("  Directory:   %s\n", dir)
+
+	if len(plan.Env) == 0 {
+		fmt.Println("  Environment: unchanged")
+	} else {
+		fmt.Println("  Environment:")
+		for _, variable := range plan.Env {
+			fmt.Printf("    %s\n", variable)
+		}
+	}
+
+	if plan.Script != "" {
+		fmt.Println("  Script:")
+		for _, line := range strings.Split(strings.TrimRight(plan.Script, "\n"), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package display
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// catalogGroupLimit is how many commands PrintRunCatalog lists per group
+const catalogGroupLimit = 8
+
+// untaggedGroup names the group of commands without tags
+const untaggedGroup = "other"
+
+// PrintRunCatalog prints the enabled commands grouped by their first tag and
+// the aliases of each project, for interop run --help. Long groups are cut
+// short, interop commands lists them all.
+func PrintRunCatalog(cfg *settings.Settings) {
+	groups := make(map[string][]string)
+	for name, cmd := range cfg.Commands {
+		if !cmd.IsEnabled {
+			continue
+		}
+		group := untaggedGroup
+		if len(cmd.Tags) > 0 {
+			group = cmd.Tags[0]
+		}
+		groups[group] = append(groups[group], name)
+	}
+	if len(groups) == 0 {
+		PrintNoItemsFound("commands")
+		return
+	}
+
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		if group != untaggedGroup {
+			names = append(names, group)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[untaggedGroup]; ok {
+		names = append(names, untaggedGroup)
+	}
+
+	fmt.Println("Available Commands:")
+	truncated := false
+	for _, group := range names {
+		commands := groups[group]
+		sort.Strings(commands)
+		if len(names) > 1 {
+			fmt.Printf("  %s:\n", group)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, name := range commands {
+			if i == catalogGroupLimit {
+				fmt.Fprintf(w, "    ... and %d more\n", len(commands)-catalogGroupLimit)
+				truncated = true
+				break
+			}
+			fmt.Fprintf(w, "    %s\t%s\n", name, summary(cfg.Commands[name].Description))
+		}
+		w.Flush()
+	}
+	if truncated {
+		fmt.Println("  List them all with: interop commands")
+	}
+
+	projects := make([]string, 0, len(cfg.Projects))
+	for name := range cfg.Projects {
+		projects = append(projects, name)
+	}
+	sort.Strings(projects)
+	var aliasLines []string
+	for _, project := range projects {
+		var aliases []string
+		for _, alias := range cfg.Projects[project].Commands {
+			if alias.Alias != "" {
+				aliases = append(aliases, fmt.Sprintf("%s → %s", alias.Alias, alias.CommandName))
+			}
+		}
+		if len(aliases) > catalogGroupLimit {
+			aliases = append(aliases[:catalogGroupLimit], fmt.Sprintf("and %d more", len(aliases)-catalogGroupLimit))
+		}
+		if len(aliases) > 0 {
+			aliasLines = append(aliasLines, fmt.Sprintf("  %s: %s", project, strings.Join(aliases, ", ")))
+		}
+	}
+	if len(aliasLines) > 0 {
+		fmt.Println()
+		fmt.Println("Aliases:")
+		for _, line := range aliasLines {
+			fmt.Println(line)
+		}
+	}
+	fmt.Println()
+	fmt.Println("Show a command's arguments and examples with: interop run <command> --help")
+}
+
+// PrintCommandHelp prints the description, arguments and examples of the
+// command or alias called name, for interop run <command> --help
+func PrintCommandHelp(cfg *settings.Settings, name string) error {
+	commandName, via := name, ""
+	cmd, ok := cfg.Commands[name]
+	if !ok {
+		// An alias defined in several projects is described from the first one by name
+		projects := make([]string, 0, len(cfg.Projects))
+		for project := range cfg.Projects {
+			projects = append(projects, project)
+		}
+		sort.Strings(projects)
+		for _, project := range projects {
+			for _, alias := range cfg.Projects[project].Commands {
+				if alias.Alias == name && !ok {
+					commandName = alias.CommandName
+					via = fmt.Sprintf("Alias of '%s' in project '%s'", commandName, project)
+					cmd, ok = cfg.Commands[commandName]
+				}
+			}
+		}
+	}
+	if !ok {
+		return fmt.Errorf("command or alias '%s' not found", name)
+	}
+
+	title := commandName
+	if cmd.Version != "" {
+		title += " v" + cmd.Version
+	}
+	fmt.Println(title)
+	if via != "" {
+		fmt.Println(via)
+	}
+	if cmd.Description != "" {
+		fmt.Println()
+		fmt.Println(strings.TrimSpace(cmd.Description))
+	}
+	if !cmd.IsEnabled {
+		fmt.Println()
+		fmt.Println("This command is disabled.")
+	}
+
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Printf("  interop run %s%s\n", name, usageArguments(cmd.Arguments))
+
+	if len(cmd.Arguments) > 0 {
+		fmt.Println()
+		fmt.Println("Arguments:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+		for _, arg := range cmd.Arguments {
+			argType := arg.Type
+			if argType == "" {
+				argType = settings.ArgumentTypeString
+			}
+			required := "no"
+			if arg.Required {
+				required = "yes"
+			}
+			defaultValue := ""
+			if arg.Default != nil {
+				defaultValue = fmt.Sprint(arg.Default)
+			}
+			description := arg.Description
+			if arg.Prefix != "" {
+				description = strings.TrimSpace(fmt.Sprintf("%s (passed as %s)", description, arg.Prefix))
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", arg.Name, argType, required, defaultValue, description)
+		}
+		w.Flush()
+		fmt.Println("Give arguments as name=value, --name value (a bare --name for bools), or as bare values in the order above.")
+	}
+
+	if len(cmd.Examples) > 0 {
+		fmt.Println()
+		fmt.Println("Examples:")
+		for _, example := range cmd.Examples {
+			if example.Description != "" {
+				fmt.Printf("  # %s\n", example.Description)
+			}
+			fmt.Printf("  %s\n", example.Command)
+		}
+	}
+
+	var details []string
+	if len(cmd.MCP) == 1 {
+		details = append(details, "MCP server: "+cmd.MCP.String())
+	} else if len(cmd.MCP) > 1 {
+		details = append(details, "MCP servers: "+cmd.MCP.String())
+	}
+	if len(cmd.DependsOn) > 0 {
+		details = append(details, "Depends on: "+strings.Join(cmd.DependsOn, ", "))
+	}
+	if location := cmd.Location(); location != "" {
+		details = append(details, "Defined in: "+location)
+	}
+	if len(details) > 0 {
+		fmt.Println()
+		fmt.Println(strings.Join(details, "  |  "))
+	}
+	return nil
+}
+
+// usageArguments renders the arguments of a command for its usage line,
+// required ones in angle brackets and optional ones in square brackets
+func usageArguments(args []settings.CommandArgument) string {
+	var b strings.Builder
+	for _, arg := range args {
+		if arg.Required {
+			fmt.Fprintf(&b, " <%s>", arg.Name)
+		} else {
+			fmt.Fprintf(&b, " [%s]", arg.Name)
+		}
+	}
+	return b.String()
+}
+
+// summary returns the first line of a description, cut to fit a listing
+func summary(description string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(description), "\n")
+	if runes := []rune(line); len(runes) > 60 {
+		return string(runes[:57]) + "..."
+	}
+	return line
+}
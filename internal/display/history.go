@@ -0,0 +1,61 @@
+package display
+
+import (
+	"fmt"
+	"interop/internal/history"
+	"strings"
+	"time"
+)
+
+// PrintHistory prints recorded runs with their outcome and duration
+func PrintHistory(list []*history.Entry) {
+	if len(list) == 0 {
+		PrintNoItemsFound("runs")
+		return
+	}
+
+	fmt.Println("HISTORY:")
+	fmt.Println("========")
+	fmt.Println()
+
+	for _, entry := range list {
+		fmt.Printf("%s %s  %s\n", historyStatus(entry), entry.ID, historyName(entry))
+		fmt.Printf("   Started: %s  |  Duration: %s\n",
+			entry.Started.Format("2006-01-02 15:04:05"), entry.Duration().Round(time.Millisecond))
+		fmt.Println()
+	}
+}
+
+// PrintHistoryEntry prints the details of a recorded run
+func PrintHistoryEntry(entry *history.Entry) {
+	fmt.Printf("Run %s: %s\n", entry.ID, historyName(entry))
+	fmt.Printf("  Status:    %s\n", historyStatus(entry))
+	fmt.Printf("  Started:   %s\n", entry.Started.Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Finished:  %s\n", entry.Finished.Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Duration:  %s\n", entry.Duration().Round(time.Millisecond))
+	fmt.Printf("  Directory: %s\n", entry.Dir)
+	fmt.Printf("  Rerun:     interop history rerun %s\n", entry.ID)
+	if entry.OutputFile == "" {
+		fmt.Println("  Output:    not captured, set capture_output in [history]")
+	}
+}
+
+// historyName returns the command of a run with its arguments and project
+func historyName(entry *history.Entry) string {
+	name := entry.Command
+	if len(entry.Args) > 0 {
+		name += " " + strings.Join(entry.Args, " ")
+	}
+	if entry.Project != "" {
+		name += fmt.Sprintf(" (project: %s)", entry.Project)
+	}
+	return name
+}
+
+// historyStatus returns the icon and outcome of a run
+func historyStatus(entry *history.Entry) string {
+	if entry.ExitCode == 0 {
+		return "✅ exited"
+	}
+	return fmt.Sprintf("❌ failed (exit %d)", entry.ExitCode)
+}
@@ -0,0 +1,80 @@
+package display
+
+import (
+	"fmt"
+	"interop/internal/jobs"
+	"strings"
+	"time"
+)
+
+// PrintJobs prints background jobs with their status, duration and log file
+func PrintJobs(list []*jobs.Job) {
+	if len(list) == 0 {
+		PrintNoItemsFound("jobs")
+		return
+	}
+
+	fmt.Println("JOBS:")
+	fmt.Println("=====")
+	fmt.Println()
+
+	for _, job := range list {
+		name := job.Command
+		if len(job.Args) > 0 {
+			name += " " + strings.Join(job.Args, " ")
+		}
+		if job.Project != "" {
+			name += fmt.Sprintf(" (project: %s)", job.Project)
+		}
+		fmt.Printf("%s %s  %s\n", jobStatus(job), job.ID, name)
+		fmt.Printf("   Started: %s  |  Duration: %s  |  PID: %d\n",
+			job.Started.Format("2006-01-02 15:04:05"), job.Duration().Round(time.Second), job.PID)
+		fmt.Printf("   Log: %s\n", job.LogFile)
+		fmt.Println()
+	}
+}
+
+// jobStatus returns the icon and status of a job
+func jobStatus(job *jobs.Job) string {
+	switch status := job.Status(); status {
+	case jobs.StatusQueued:
+		return "⏳ queued"
+	case jobs.StatusRunning:
+		return "🔄 running"
+	case jobs.StatusExited:
+		return "✅ exited"
+	case jobs.StatusFailed:
+		return fmt.Sprintf("❌ failed (exit %d)", job.ExitCode)
+	case jobs.StatusKilled:
+		return "⏹️ killed"
+	default:
+		return "⚠️ " + string(status)
+	}
+}
+
+// PrintQueue prints the executions holding a slot and those waiting for one
+func PrintQueue(running []jobs.Slot, waiting []jobs.QueueEntry, max int) {
+	if max <= 0 {
+		fmt.Println("max_concurrent_executions is not set, executions never wait for a slot.")
+		fmt.Println()
+	}
+
+	fmt.Printf("RUNNING (%d of %s slots):\n", len(running), slotLimit(max))
+	for _, slot := range running {
+		fmt.Printf("   🔄 %s  PID %d, running for %s\n", slot.Name, slot.PID, time.Since(slot.Started).Round(time.Second))
+	}
+	fmt.Println()
+
+	fmt.Printf("WAITING (%d):\n", len(waiting))
+	for i, entry := range waiting {
+		fmt.Printf("   %d. ⏳ %s  PID %d, waiting for %s\n", i+1, entry.Name, entry.PID, time.Since(entry.Enqueued).Round(time.Second))
+	}
+}
+
+// slotLimit formats max_concurrent_executions
+func slotLimit(max int) string {
+	if max <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", max)
+}
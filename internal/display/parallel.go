@@ -0,0 +1,33 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"interop/internal/execution"
+	"time"
+)
+
+// PrintParallelSummary prints the outcome of each command of interop run
+// --parallel, in the order they were given, and how many failed
+func PrintParallelSummary(results []execution.JobResult) {
+	width := 0
+	for _, result := range results {
+		width = max(width, len(result.Name))
+	}
+
+	fmt.Println()
+	fmt.Println("SUMMARY:")
+	failed := 0
+	for _, result := range results {
+		status := "✅ ok"
+		if errors.Is(result.Err, execution.ErrTimedOut) {
+			failed++
+			status = "⏱️ timed out"
+		} else if result.Err != nil {
+			failed++
+			status = fmt.Sprintf("❌ failed (exit %d)", result.ExitCode)
+		}
+		fmt.Printf("  %-*s  %-20s %s\n", width, result.Name, status, result.Duration.Round(time.Millisecond))
+	}
+	fmt.Printf("%d of %d commands failed\n", failed, len(results))
+}
@@ -0,0 +1,91 @@
+package display
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"sort"
+	"strings"
+)
+
+// PrintPromptHeader prints the prompt header
+func PrintPromptHeader() {
+	fmt.Println("PROMPTS:")
+	fmt.Println("========")
+	fmt.Println()
+}
+
+// ListPrompts prints the prompts sorted by name, with the MCP servers serving
+// them and a summary of their arguments
+func ListPrompts(prompts map[string]settings.PromptConfig) {
+	if len(prompts) == 0 {
+		PrintNoItemsFound("prompts")
+		return
+	}
+
+	PrintPromptHeader()
+
+	for _, prompt := range PromptList(prompts) {
+		PrintPromptSummary(prompt.Name, prompts[prompt.Name])
+	}
+}
+
+// PromptList returns the prompts sorted by name, each with its name set
+func PromptList(prompts map[string]settings.PromptConfig) []settings.PromptConfig {
+	names := make([]string, 0, len(prompts))
+	for name := range prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]settings.PromptConfig, 0, len(names))
+	for _, name := range names {
+		prompt := prompts[name]
+		prompt.Name = name
+		list = append(list, prompt)
+	}
+	return list
+}
+
+// PrintPromptSummary prints a prompt with the MCP servers serving it and a
+// summary of its arguments
+func PrintPromptSummary(name string, prompt settings.PromptConfig) {
+	fmt.Printf("💬 Name: %s\n", name)
+	switch len(prompt.MCP) {
+	case 0:
+		fmt.Println("   MCP server: default")
+	case 1:
+		fmt.Printf("   MCP server: %s\n", prompt.MCP)
+	default:
+		fmt.Printf("   MCP servers: %s\n", prompt.MCP)
+	}
+	if len(prompt.Arguments) > 0 {
+		fmt.Printf("   Arguments: %s\n", ArgumentSummary(prompt.Arguments))
+	}
+	PrintCommandSource(prompt.SourceFile, prompt.SourceLine, nil)
+	PrintCommandDescription(prompt.Description)
+	PrintSeparator()
+}
+
+// ArgumentSummary lists arguments on one line, each with its type when it
+// isn't a string, whether it's required and its default, like
+// "focus (required), depth (number, default 2)"
+func ArgumentSummary(args []settings.CommandArgument) string {
+	summaries := make([]string, len(args))
+	for i, arg := range args {
+		var details []string
+		if arg.Type != "" && arg.Type != settings.ArgumentTypeString {
+			details = append(details, string(arg.Type))
+		}
+		if arg.Required {
+			details = append(details, "required")
+		}
+		if arg.Default != nil && arg.Default != "" {
+			details = append(details, fmt.Sprintf("default %v", arg.Default))
+		}
+		summaries[i] = arg.Name
+		if len(details) > 0 {
+			summaries[i] += " (" + strings.Join(details, ", ") + ")"
+		}
+	}
+	return strings.Join(summaries, ", ")
+}
@@ -0,0 +1,49 @@
+package display
+
+import (
+	"fmt"
+	"interop/internal/secrets"
+	"interop/internal/settings"
+)
+
+// SecretStatus is a secret reference of the settings and whether the secret
+// it refers to is stored
+type SecretStatus struct {
+	settings.SecretUse
+	Stored bool   `json:"stored"`
+	Error  string `json:"error,omitempty"` // Why the secret couldn't be read, other than not being stored
+}
+
+// PrintSecrets prints the secret references of the settings with the state
+// of their secrets
+func PrintSecrets(statuses []SecretStatus) {
+	if len(statuses) == 0 {
+		PrintNoItemsFound("secret references")
+		return
+	}
+
+	fmt.Println("SECRETS:")
+	fmt.Println("========")
+	fmt.Println()
+
+	for _, status := range statuses {
+		icon, state := secretState(status)
+		fmt.Printf("%s %s = %s\n", icon, status.Variable, status.Reference)
+		fmt.Printf("   Used by: %s  |  %s\n", status.Owner, state)
+		fmt.Println()
+	}
+}
+
+// secretState returns the icon and state of the secret of a reference
+func secretState(status SecretStatus) (string, string) {
+	switch {
+	case status.Stored:
+		return "✅", "Stored"
+	case status.Error != "":
+		return "⚠️", "Unreadable: " + status.Error
+	}
+	if ref, err := secrets.ParseReference(status.Reference); err == nil {
+		return "❌", "Missing, store it with 'interop secrets set " + ref.Name + "'"
+	}
+	return "❌", "Missing"
+}
@@ -1,24 +1,25 @@
 package edit
 
 import (
+	"embed"
+	"errors"
 	"fmt"
 	"interop/internal/logging"
 	"interop/internal/settings"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 // OpenConfigFolder opens the entire interop config folder using the best available editor or file browser
 func OpenConfigFolder(editorName string) error {
-	homeDir, err := os.UserHomeDir()
+	// Path to the interop config folder
+	configDir, err := settings.GetAppDir()
 	if err != nil {
-		logging.ErrorAndExit("failed to get user home directory: %w", err)
+		return fmt.Errorf("failed to resolve config directory: %w", err)
 	}
 
-	// Path to the interop config folder
-	configDir := filepath.Join(homeDir, settings.DefaultPathConfig.SettingsDir, settings.DefaultPathConfig.AppDir)
-
 	// Determine which editor or opener to use
 	var cmd *exec.Cmd
 
@@ -54,3 +55,188 @@ func OpenConfigFolder(editorName string) error {
 	logging.Message(fmt.Sprintf("Opening config folder: %s", configDir))
 	return cmd.Run()
 }
+
+// templates holds the commented starting points of new configuration files
+//
+//go:embed templates/*.toml
+var templates embed.FS
+
+// Templates lists the names of the templates new configuration files can be
+// created from
+var Templates = []string{"command", "prompt"}
+
+// ConfigFile resolves a configuration file named relative to the config
+// folder, like "config.d/team.toml". "settings" names settings.toml. Files
+// outside the folder and files other than TOML are rejected.
+func ConfigFile(name string) (string, error) {
+	configDir, err := settings.GetAppDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config directory: %w", err)
+	}
+	if name == "settings" {
+		name = settings.DefaultPathConfig.CfgFile
+	}
+	if filepath.Ext(name) != ".toml" {
+		return "", fmt.Errorf("'%s' is not a TOML file", name)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(configDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("'%s' is outside the config folder %s", name, configDir)
+	}
+	return path, nil
+}
+
+// CreateFromTemplate writes the template named template to path, creating its
+// parent directories. An existing file is left alone, created reports whether
+// the file was written.
+func CreateFromTemplate(path, template string) (created bool, err error) {
+	content, err := templates.ReadFile("templates/" + template + ".toml")
+	if err != nil {
+		return false, fmt.Errorf("unknown template '%s', expected one of %s", template, strings.Join(Templates, ", "))
+	}
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	if _, err := file.Write(content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// OpenConfigFile opens a configuration file named as ConfigFile takes it.
+// With create, a missing file is first created from template; otherwise it is
+// an error.
+func OpenConfigFile(editorName, name string, create bool, template string) error {
+	path, err := ConfigFile(name)
+	if err != nil {
+		return err
+	}
+
+	if create {
+		created, err := CreateFromTemplate(path, template)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		if created {
+			logging.Message(fmt.Sprintf("Created %s from the %s template", path, template))
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s doesn't exist, pass --create to create it from a template", path)
+	}
+
+	cmd := fileCommand(editorName, path, 0)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	logging.Message(fmt.Sprintf("Opening %s", path))
+	return cmd.Run()
+}
+
+// ErrUnknownKind is returned for entity types other than command, project and
+// prompt
+var ErrUnknownKind = errors.New("unknown entity type")
+
+// Locate returns the file and line where a command, project or prompt named
+// name is defined. The line is 0 when it isn't known.
+func Locate(cfg *settings.Settings, kind, name string) (string, int, error) {
+	switch kind {
+	case "command":
+		if cmd, ok := cfg.Commands[name]; ok {
+			return cmd.SourceFile, cmd.SourceLine, nil
+		}
+	case "project":
+		if project, ok := cfg.Projects[name]; ok {
+			return project.SourceFile, project.SourceLine, nil
+		}
+	case "prompt":
+		if prompt, ok := cfg.Prompts[name]; ok {
+			return prompt.SourceFile, prompt.SourceLine, nil
+		}
+	default:
+		return "", 0, fmt.Errorf("%w '%s', expected command, project or prompt", ErrUnknownKind, kind)
+	}
+	return "", 0, fmt.Errorf("%s '%s' not found", kind, name)
+}
+
+// OpenEntity opens the file defining a command, project or prompt at its
+// line. It falls back to the config folder when the entity or its file can't
+// be found, so a file that failed to load can still be fixed.
+func OpenEntity(cfg *settings.Settings, editorName, kind, name string) error {
+	file, line, err := Locate(cfg, kind, name)
+	if errors.Is(err, ErrUnknownKind) {
+		return err
+	}
+	if err != nil || file == "" {
+		logging.Warning("Can't find the file defining %s '%s', opening the config folder instead", kind, name)
+		return OpenConfigFolder(editorName)
+	}
+
+	cmd := fileCommand(editorName, file, line)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	logging.Message(fmt.Sprintf("Opening %s", settings.SourceLocation(file, line)))
+	return cmd.Run()
+}
+
+// fileCommand builds the command opening file at line. Terminal editors from
+// $EDITOR are preferred over VS Code here, unlike for the folder, as they
+// handle single files well.
+func fileCommand(editorName, file string, line int) *exec.Cmd {
+	editor := editorName
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		for _, candidate := range []string{"code", "open", "xdg-open", "nano"} {
+			if _, err := exec.LookPath(candidate); err == nil {
+				editor = candidate
+				break
+			}
+		}
+	}
+	if editor == "" {
+		editor = "nano"
+	}
+
+	// $EDITOR may carry arguments, like "code --wait"
+	fields := strings.Fields(editor)
+	args := append(fields[1:], lineArgs(fields[0], file, line)...)
+	return exec.Command(fields[0], args...)
+}
+
+// lineArgs returns the arguments opening file at line in editor, using the
+// syntax the editor understands. Editors without line support get the file.
+func lineArgs(editor, file string, line int) []string {
+	if line <= 0 {
+		return []string{file}
+	}
+
+	switch filepath.Base(editor) {
+	case "code", "code-insiders", "codium", "cursor":
+		return []string{"--goto", fmt.Sprintf("%s:%d", file, line)}
+	case "subl", "zed", "hx", "helix":
+		return []string{fmt.Sprintf("%s:%d", file, line)}
+	case "vi", "vim", "nvim", "nano", "emacs", "emacsclient", "micro", "kak", "mg", "joe":
+		return []string{fmt.Sprintf("+%d", line), file}
+	default:
+		return []string{file}
+	}
+}
@@ -1,8 +1,13 @@
 package edit
 
 import (
+	"errors"
+	"interop/internal/settings"
+	"interop/internal/testutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -42,9 +47,115 @@ exit 0
 		t.Skip("Skipping actual execution in unit tests")
 
 		// Pass empty string to use environment variable behavior (original behavior)
-		err := OpenSettings("")
+		err := OpenConfigFolder("")
 		if err != nil {
-			t.Errorf("OpenSettings() returned an error: %v", err)
+			t.Errorf("OpenConfigFolder() returned an error: %v", err)
 		}
 	})
 }
+
+func TestLocate(t *testing.T) {
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{"build": {SourceFile: "/cfg/settings.toml", SourceLine: 4}},
+		Projects: map[string]settings.Project{"app": {SourceFile: "/cfg/config.d/team.toml", SourceLine: 9}},
+		Prompts:  map[string]settings.PromptConfig{"review": {SourceFile: "/cfg/config.d/prompts.toml", SourceLine: 2}},
+	}
+
+	tests := []struct {
+		kind, name string
+		file       string
+		line       int
+	}{
+		{"command", "build", "/cfg/settings.toml", 4},
+		{"project", "app", "/cfg/config.d/team.toml", 9},
+		{"prompt", "review", "/cfg/config.d/prompts.toml", 2},
+	}
+	for _, tt := range tests {
+		file, line, err := Locate(cfg, tt.kind, tt.name)
+		if err != nil || file != tt.file || line != tt.line {
+			t.Errorf("Locate(%s, %s) = %q, %d, %v; want %q, %d", tt.kind, tt.name, file, line, err, tt.file, tt.line)
+		}
+	}
+
+	if _, _, err := Locate(cfg, "command", "missing"); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+	if _, _, err := Locate(cfg, "server", "build"); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("Locate() of an unknown type error = %v, want ErrUnknownKind", err)
+	}
+}
+
+func TestLineArgs(t *testing.T) {
+	tests := []struct {
+		editor string
+		line   int
+		want   []string
+	}{
+		{"code", 12, []string{"--goto", "team.toml:12"}},
+		{"/usr/bin/nvim", 12, []string{"+12", "team.toml"}},
+		{"subl", 12, []string{"team.toml:12"}},
+		{"xdg-open", 12, []string{"team.toml"}},
+		{"vim", 0, []string{"team.toml"}},
+	}
+	for _, tt := range tests {
+		if got := lineArgs(tt.editor, "team.toml", tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lineArgs(%q, %d) = %q, want %q", tt.editor, tt.line, got, tt.want)
+		}
+	}
+
+	cmd := fileCommand("code --wait", "team.toml", 3)
+	if want := []string{"code", "--wait", "--goto", "team.toml:3"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("fileCommand() args = %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestOpenConfigFile(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("")
+
+	path, err := ConfigFile("settings")
+	if err != nil || path != filepath.Join(env.ConfigDir, "settings.toml") {
+		t.Errorf("ConfigFile(settings) = %q, %v", path, err)
+	}
+	for _, name := range []string{"../other.toml", "config.d/notes.txt", "/etc/interop.toml"} {
+		if _, err := ConfigFile(name); err == nil {
+			t.Errorf("Expected ConfigFile(%q) to be rejected", name)
+		}
+	}
+
+	// "true" stands in for an editor
+	if err := OpenConfigFile("true", "config.d/team.toml", false, "command"); err == nil {
+		t.Error("Expected an error for a missing file without --create")
+	}
+	if err := OpenConfigFile("true", "config.d/team.toml", true, "widget"); err == nil {
+		t.Error("Expected an error for an unknown template")
+	}
+	for _, template := range Templates {
+		name := filepath.Join("config.d", template+".toml")
+		if err := OpenConfigFile("true", name, true, template); err != nil {
+			t.Fatalf("OpenConfigFile(%s) error = %v", name, err)
+		}
+		content, err := os.ReadFile(filepath.Join(env.ConfigDir, name))
+		if err != nil || !strings.HasPrefix(string(content), "#") {
+			t.Errorf("Expected %s to be created from the template, got %q, %v", name, content, err)
+		}
+	}
+
+	// Created files are commented out, so they load without adding anything
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(cfg.Commands) != 0 || len(cfg.Prompts) != 0 {
+		t.Errorf("Expected the templates to define nothing, got %d commands and %d prompts", len(cfg.Commands), len(cfg.Prompts))
+	}
+
+	// Existing files are opened as they are
+	file := env.WriteFile("config.d/mine.toml", "[commands.a]\ncmd = \"ls\"\n")
+	if err := OpenConfigFile("true", "config.d/mine.toml", true, "command"); err != nil {
+		t.Fatalf("OpenConfigFile() error = %v", err)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "[commands.a]\ncmd = \"ls\"\n" {
+		t.Errorf("Expected the existing file to be kept, got %q", content)
+	}
+}
@@ -1,11 +1,14 @@
 package execution
 
 import (
+	"bytes"
 	"context"
+	stderrors "errors"
 	"fmt"
 	"interop/internal/errors"
 	"interop/internal/logging"
 	"interop/internal/shell"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,10 +29,21 @@ type CommandInfo struct {
 
 // Command represents a command to be executed
 type Command struct {
-	Path string   // Path to the executable
-	Args []string // Command arguments
-	Dir  string   // Working directory
-	Env  []string // Environment variables
+	Path   string    // Path to the executable
+	Args   []string  // Command arguments
+	Dir    string    // Working directory
+	Env    []string  // Environment variables
+	Output io.Writer // Optional writer receiving a copy of stdout and stderr
+	Limits Limits    // Resource limits applied to the started process
+	Clean  bool      // Env is the whole environment rather than additions to the current one
+
+	// Terminal replaces the terminal when set: it receives stdout and stderr,
+	// and stdin is left unconnected, as for commands run in parallel
+	Terminal io.Writer
+	// StopGrace, when positive, makes a done context ask the processes of the
+	// command to stop with SIGTERM and kill them only if they're still running
+	// this long after
+	StopGrace time.Duration
 }
 
 // Executor handles command execution
@@ -130,7 +144,7 @@ func RunWithSearchPathsAndArgs(command CommandInfo, executableSearchPaths []stri
 		// Save current directory to return to after command execution
 		currentDir, err = os.Getwd()
 		if err != nil {
-			logging.Error("failed to get current working directory: %w", err)
+			logging.Error("failed to get current working directory: %v", err)
 		}
 
 		projectDir := projectPath[0]
@@ -142,7 +156,7 @@ func RunWithSearchPathsAndArgs(command CommandInfo, executableSearchPaths []stri
 		// Change to project directory
 		logging.Message("Changing to project directory: %s", projectDir)
 		if err := os.Chdir(projectDir); err != nil {
-			logging.Error("failed to change to project directory: %w", err)
+			logging.Error("failed to change to project directory: %v", err)
 		}
 
 		// Ensure we change back to original directory when done
@@ -294,15 +308,165 @@ func (e *Executor) Execute(cmd *Command) error {
 	return e.ExecuteWithContext(context.Background(), cmd)
 }
 
-// ExecuteWithContext runs the command with the provided context
+// ExecuteWithContext runs the command with the provided context. A command
+// stopped by the executor's timeout fails with an error saying so.
 func (e *Executor) ExecuteWithContext(ctx context.Context, cmd *Command) error {
+	// Create a context with timeout if specified
+	parent := ctx
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	execCmd, err := prepareCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	// Connect command to standard I/O
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	if cmd.Terminal != nil {
+		stdout, stderr = cmd.Terminal, cmd.Terminal
+	} else {
+		execCmd.Stdin = os.Stdin
+	}
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+	// A command that can be stopped runs as a job, so it's stopped with the
+	// processes it started
+	var j *job
+	if ctx.Done() != nil {
+		j = newJob(execCmd, cmd.Terminal == nil)
+		if cmd.StopGrace > 0 {
+			stopGracefully(execCmd, cmd.StopGrace)
+		}
+	}
+	if cmd.Output != nil {
+		execCmd.Stdout = io.MultiWriter(stdout, cmd.Output)
+		execCmd.Stderr = io.MultiWriter(stderr, cmd.Output)
+	}
+
+	// Run the command
+	if j == nil {
+		err = run(execCmd, cmd.Limits)
+	} else if err = start(execCmd, cmd.Limits); err == nil {
+		j.started(execCmd.Process.Pid)
+		err = execCmd.Wait()
+		j.finished(execCmd.ProcessState)
+	} else {
+		j.finished(nil)
+	}
+	if err != nil && e.Timeout > 0 && ctx.Err() != nil && parent.Err() == nil {
+		return errors.NewExecutionError(fmt.Sprintf("Command timed out after %s: %s", e.Timeout, strings.Join(cmd.Args, " ")), fmt.Errorf("%w, %w", ErrTimedOut, err))
+	}
+	if err != nil {
+		return errors.NewExecutionError(fmt.Sprintf("Command execution failed: %s", strings.Join(cmd.Args, " ")), err)
+	}
+
+	return nil
+}
+
+// Output runs the command and returns its stdout with surrounding whitespace trimmed.
+// Stdin and stderr stay connected to the terminal.
+func (e *Executor) Output(cmd *Command) (string, error) {
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	execCmd, err := prepareCommand(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	execCmd.Stdout = &output
+	execCmd.Stderr = os.Stderr
+	if cmd.Terminal != nil {
+		execCmd.Stderr = cmd.Terminal
+	} else {
+		execCmd.Stdin = os.Stdin
+	}
+
+	if err := run(execCmd, cmd.Limits); err != nil {
+		return "", errors.NewExecutionError(fmt.Sprintf("Command execution failed: %s", strings.Join(cmd.Args, " ")), err)
+	}
+
+	return strings.TrimSpace(output.String()), nil
+}
+
+// CombinedOutput runs the command without stdin and returns its stdout and
+// stderr interleaved, including when the command fails
+func (e *Executor) CombinedOutput(ctx context.Context, cmd *Command) ([]byte, error) {
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	execCmd, err := prepareCommand(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	execCmd.Stdout = &output
+	execCmd.Stderr = &output
+	// Without a terminal the command can't be interactive, so it can get a
+	// process group of its own to stop all of it
+	killGroupOnCancel(execCmd)
+
+	if err := run(execCmd, cmd.Limits); err != nil {
+		return output.Bytes(), errors.NewExecutionError(fmt.Sprintf("Command execution failed: %s", strings.Join(cmd.Args, " ")), err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// ErrTimedOut is wrapped by the errors of commands stopped by a timeout
+var ErrTimedOut = stderrors.New("timed out")
+
+// ExitCode returns the exit code reported by a command's error: 0 on success,
+// the process exit code if it ran, and -1 if it could not be started
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if stderrors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// run starts the command with its resource limits and waits for it to finish
+func run(execCmd *exec.Cmd, limits Limits) error {
+	if err := start(execCmd, limits); err != nil {
+		return err
+	}
+	return execCmd.Wait()
+}
+
+// start starts the command with its resource limits in place
+func start(execCmd *exec.Cmd, limits Limits) error {
+	if !limits.IsZero() {
+		applyLimits(execCmd, limits)
+	}
+	return execCmd.Start()
+}
+
+// prepareCommand creates an exec.Cmd for the command with its working directory and environment set
+func prepareCommand(ctx context.Context, cmd *Command) (*exec.Cmd, error) {
 	logging.Message("Executing command: %s %s", cmd.Path, strings.Join(cmd.Args, " "))
 
 	if cmd.Dir != "" {
 		logging.Message("Working directory: %s", cmd.Dir)
 		// Check if directory exists
 		if _, err := os.Stat(cmd.Dir); os.IsNotExist(err) {
-			return errors.NewExecutionError(fmt.Sprintf("Working directory does not exist: %s", cmd.Dir), err)
+			return nil, errors.NewExecutionError(fmt.Sprintf("Working directory does not exist: %s", cmd.Dir), err)
 		}
 	}
 
@@ -315,31 +479,15 @@ func (e *Executor) ExecuteWithContext(ctx context.Context, cmd *Command) error {
 	}
 
 	// Set environment variables if provided
-	if len(cmd.Env) > 0 {
+	if cmd.Clean {
+		execCmd.Env = cmd.Env
+	} else if len(cmd.Env) > 0 {
 		execCmd.Env = append(os.Environ(), cmd.Env...)
 	} else {
 		execCmd.Env = os.Environ()
 	}
 
-	// Connect command to standard I/O
-	execCmd.Stdin = os.Stdin
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-
-	// Create a context with timeout if specified
-	var cancel context.CancelFunc
-	if e.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
-		defer cancel()
-	}
-
-	// Run the command
-	err := execCmd.Run()
-	if err != nil {
-		return errors.NewExecutionError(fmt.Sprintf("Command execution failed: %s", strings.Join(cmd.Args, " ")), err)
-	}
-
-	return nil
+	return execCmd, nil
 }
 
 // RunInDirectory executes a command in the specified directory
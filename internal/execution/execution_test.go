@@ -1,9 +1,18 @@
 package execution
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestFindExecutable(t *testing.T) {
@@ -89,3 +98,290 @@ func TestRunCommand(t *testing.T) {
 		t.Errorf("Run() error = %v", err)
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	if code := ExitCode(nil); code != 0 {
+		t.Errorf("Expected exit code 0 for nil error, got %d", code)
+	}
+
+	err := NewExecutor().Execute(&Command{Path: "sh", Args: []string{"-c", "exit 3"}})
+	if code := ExitCode(err); code != 3 {
+		t.Errorf("Expected exit code 3, got %d", code)
+	}
+
+	err = NewExecutor().Execute(&Command{Path: "/nonexistent/interop-test-binary"})
+	if code := ExitCode(err); code != -1 {
+		t.Errorf("Expected exit code -1 for a command that could not start, got %d", code)
+	}
+}
+
+func TestStopGrace(t *testing.T) {
+	stopped := filepath.Join(t.TempDir(), "stopped")
+	run := func(script string, grace time.Duration) (time.Duration, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		started := time.Now()
+		err := NewExecutor().ExecuteWithContext(ctx, &Command{
+			Path:      "sh",
+			Args:      []string{"-c", script},
+			Env:       []string{"STOPPED=" + stopped},
+			Terminal:  io.Discard,
+			StopGrace: grace,
+		})
+		return time.Since(started), err
+	}
+
+	// The command gets SIGTERM and cleans up
+	if _, err := run(`trap 'echo done > "$STOPPED"; exit 0' TERM; while :; do sleep 0.05; done`, 5*time.Second); err == nil {
+		t.Error("Expected a canceled command to fail")
+	}
+	if data, err := os.ReadFile(stopped); err != nil || string(data) != "done\n" {
+		t.Errorf("Expected the command to handle SIGTERM, got %q, %v", data, err)
+	}
+
+	// A command ignoring SIGTERM is killed after the grace period
+	elapsed, err := run(`trap '' TERM; while :; do sleep 0.05; done`, 300*time.Millisecond)
+	if err == nil || elapsed > 3*time.Second {
+		t.Errorf("Expected the command to be killed after the grace period, got %v after %s", err, elapsed)
+	}
+}
+
+func TestStopGraceStopsProcessGroup(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	err := NewExecutor().ExecuteWithContext(ctx, &Command{
+		Path:      "sh",
+		Args:      []string{"-c", `(trap '' TERM; sleep 1; touch "$MARKER") & wait`},
+		Env:       []string{"MARKER=" + marker},
+		Terminal:  io.Discard,
+		StopGrace: 300 * time.Millisecond,
+	})
+	if err == nil {
+		t.Error("Expected a canceled command to fail")
+	}
+
+	// The subshell ignores SIGTERM, it's killed with the group after the grace
+	// period, so it never touches the marker
+	time.Sleep(1200 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the command's child process to be killed after the grace period")
+	}
+}
+
+func TestTimeoutStopsProcessGroup(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	var output bytes.Buffer
+	started := time.Now()
+	err := (&Executor{Timeout: 300 * time.Millisecond}).ExecuteWithContext(context.Background(), &Command{
+		Path:     "sh",
+		Args:     []string{"-c", `(sleep 1; touch "$MARKER"); echo after`},
+		Env:      []string{"MARKER=" + marker},
+		Terminal: &output,
+	})
+	if elapsed := time.Since(started); elapsed > 900*time.Millisecond {
+		t.Errorf("Expected the run to end at the timeout, it took %s", elapsed)
+	}
+	if !errors.Is(err, ErrTimedOut) {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+
+	// The subshell was killed with the shell, so it never touches the marker
+	time.Sleep(1200 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the command's child process to be killed at the timeout")
+	}
+	if strings.Contains(output.String(), "after") {
+		t.Errorf("Expected the shell to be killed, got output %q", output.String())
+	}
+}
+
+func TestCombinedOutputInDirectory(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"with space", `it's "quoted"`, "semi;colon && $(dollar)"} {
+		dir := filepath.Join(root, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		output, err := NewExecutor().CombinedOutput(context.Background(), &Command{Path: "sh", Args: []string{"-c", "pwd"}, Dir: dir})
+		if err != nil {
+			t.Fatalf("CombinedOutput in %q failed: %v", dir, err)
+		}
+		if got := strings.TrimSpace(string(output)); got != dir {
+			t.Errorf("Expected the command to run in %q, got %q", dir, got)
+		}
+	}
+
+	// A failing command still returns its output and exit code
+	output, err := NewExecutor().CombinedOutput(context.Background(), &Command{Path: "sh", Args: []string{"-c", "echo out; echo err >&2; exit 4"}, Dir: root})
+	if code := ExitCode(err); code != 4 {
+		t.Errorf("Expected exit code 4, got %d", code)
+	}
+	if string(output) != "out\nerr\n" {
+		t.Errorf("Expected stdout and stderr, got %q", output)
+	}
+
+	if _, err := NewExecutor().CombinedOutput(context.Background(), &Command{Path: "sh", Args: []string{"-c", "pwd"}, Dir: filepath.Join(root, "missing")}); err == nil {
+		t.Error("Expected a missing working directory to fail")
+	}
+}
+
+func TestLimitsFor(t *testing.T) {
+	limits, err := LimitsFor(settings.CommandConfig{Nice: 10, MaxMemory: "1.5GB", MaxCPUSeconds: 60})
+	if err != nil {
+		t.Fatalf("LimitsFor() error = %v", err)
+	}
+	if want := (Limits{Nice: 10, MaxMemory: 3 << 29, MaxCPUSeconds: 60}); limits != want {
+		t.Errorf("LimitsFor() = %+v, want %+v", limits, want)
+	}
+	if limits, _ := LimitsFor(settings.CommandConfig{}); !limits.IsZero() {
+		t.Errorf("LimitsFor() without options = %+v, want no limits", limits)
+	}
+
+	for _, config := range []settings.CommandConfig{{Nice: 25}, {MaxMemory: "lots"}, {MaxMemory: "2PB"}, {MaxCPUSeconds: -1}} {
+		if _, err := LimitsFor(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestScriptInterpreter(t *testing.T) {
+	tests := []struct {
+		script      string
+		interpreter string
+		want        string
+		runtime     string
+	}{
+		{"echo hi\n", "", "sh", ""},
+		{"#!/usr/bin/env python3\nprint('hi')\n", "", "/usr/bin/env python3", RuntimePython},
+		{"#!/bin/bash -eu\necho hi\n", "", "/bin/bash -eu", ""},
+		{"#!/bin/bash\necho hi\n", "bash -x", "bash -x", ""},
+		{"#!\necho hi\n", "", "sh", ""},
+		{"console.log(1)\n", "node", "node", RuntimeNode},
+		{"console.log(1)\n", "deno", "deno run -A", RuntimeDeno},
+		{"console.log(1)\n", "deno run --allow-read", "deno run --allow-read", RuntimeDeno},
+		{"#!/usr/bin/env -S deno\nconsole.log(1)\n", "", "/usr/bin/env -S deno run -A", RuntimeDeno},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(ScriptInterpreter(tt.script, tt.interpreter), " "); got != tt.want {
+			t.Errorf("ScriptInterpreter(%q, %q) = %q, want %q", tt.script, tt.interpreter, got, tt.want)
+		}
+		if got := ScriptRuntime(tt.script, tt.interpreter); got != tt.runtime {
+			t.Errorf("ScriptRuntime(%q, %q) = %q, want %q", tt.script, tt.interpreter, got, tt.runtime)
+		}
+	}
+}
+
+func TestPrepareScript(t *testing.T) {
+	testutil.New(t)
+	script := "#!/bin/sh\nfor arg in \"$@\"; do\n  echo \"got $arg\"\ndone\n"
+	run, err := PrepareScript(context.Background(), script, "", nil)
+	if err != nil {
+		t.Fatalf("PrepareScript() error = %v", err)
+	}
+	path, err := run.Write(script)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	defer os.Remove(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("script mode = %v, want it executable", info.Mode())
+	}
+
+	argv := append(run.Command(path), "a b", "c")
+	output, err := NewExecutor().CombinedOutput(context.Background(), &Command{Path: argv[0], Args: argv[1:]})
+	if err != nil {
+		t.Fatalf("running the script failed: %v\n%s", err, output)
+	}
+	if want := "got a b\ngot c\n"; string(output) != want {
+		t.Errorf("script output = %q, want %q", output, want)
+	}
+
+	if _, err := PrepareScript(context.Background(), script, "", []string{"jq"}); err == nil {
+		t.Error("Expected an error for dependencies of a shell script")
+	}
+	if run, err := PrepareScript(context.Background(), "print(1)", "python3 -u", nil); err != nil || run.Ext != ".py" || run.Dir != "" {
+		t.Errorf("PrepareScript(python3) = %+v, %v; want a .py file in the temp directory", run, err)
+	}
+}
+
+func TestScriptEnv(t *testing.T) {
+	testutil.New(t)
+	installs := 0
+	install := func(ctx context.Context, dir string, interpreter, dependencies []string) error {
+		installs++
+		return os.WriteFile(filepath.Join(dir, "deps.txt"), []byte(strings.Join(dependencies, "\n")), 0o644)
+	}
+
+	dir, err := scriptEnv(context.Background(), []string{"python3"}, []string{"rich", "requests"}, install)
+	if err != nil {
+		t.Fatalf("scriptEnv() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "deps.txt")); err != nil || string(data) != "requests\nrich" {
+		t.Errorf("environment holds %q, %v; want the sorted dependencies", data, err)
+	}
+
+	// The same dependencies in another order reuse the environment
+	again, err := scriptEnv(context.Background(), []string{"python3"}, []string{"requests", "rich"}, install)
+	if err != nil || again != dir || installs != 1 {
+		t.Errorf("scriptEnv() again = %s, %v after %d installs; want %s from one install", again, err, installs, dir)
+	}
+	if other, err := scriptEnv(context.Background(), []string{"python3.12"}, []string{"rich", "requests"}, install); err != nil || other == dir {
+		t.Errorf("scriptEnv() for another interpreter = %s, %v; want a new environment", other, err)
+	}
+
+	failing := func(ctx context.Context, dir string, interpreter, dependencies []string) error {
+		return os.ErrPermission
+	}
+	if _, err := scriptEnv(context.Background(), []string{"node"}, []string{"chalk"}, failing); err == nil {
+		t.Error("Expected the install error")
+	}
+	root, _ := ScriptEnvRoot()
+	if entries, _ := os.ReadDir(root); len(entries) != 2 {
+		t.Errorf("script environments = %d, want the 2 installed ones without leftovers", len(entries))
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	var running, peak int32
+	job := func(name, script string) Job {
+		return Job{Name: name, Run: func(ctx context.Context, terminal io.Writer) error {
+			peak = max(peak, atomic.AddInt32(&running, 1))
+			defer atomic.AddInt32(&running, -1)
+			return NewExecutor().ExecuteWithContext(ctx, &Command{Path: "sh", Args: []string{"-c", script}, Terminal: terminal})
+		}}
+	}
+	jobs := []Job{
+		job("a", "echo one; printf 'two'"),
+		job("long", "echo err >&2; exit 3"),
+		job("c", "sleep 0.1"),
+	}
+
+	var output bytes.Buffer
+	results := RunParallel(context.Background(), jobs, 1, &output)
+
+	if peak != 1 {
+		t.Errorf("Expected at most 1 job at a time, got %d", peak)
+	}
+	for _, line := range []string{"[a   ] one\n", "[a   ] two\n", "[long] err\n"} {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, output.String())
+		}
+	}
+	if len(results) != 3 || results[0].Name != "a" || results[1].Name != "long" || results[2].Name != "c" {
+		t.Fatalf("Expected results in the order of the jobs, got %+v", results)
+	}
+	if results[0].Err != nil || results[1].ExitCode != 3 || results[2].Err != nil {
+		t.Errorf("Unexpected results %+v", results)
+	}
+}
@@ -0,0 +1,31 @@
+package execution
+
+import (
+	"fmt"
+	"interop/internal/settings"
+)
+
+// Limits caps the resources of a command's process and the processes it
+// starts. Zero values leave a resource unlimited.
+type Limits struct {
+	Nice          int    // Scheduling priority adjustment, positive values lower it
+	MaxMemory     uint64 // Address space limit in bytes
+	MaxCPUSeconds uint64 // CPU time limit in seconds
+}
+
+// LimitsFor returns the resource limits configured for a command
+func LimitsFor(config settings.CommandConfig) (Limits, error) {
+	if err := config.ValidateLimits(); err != nil {
+		return Limits{}, err
+	}
+	maxMemory, err := settings.ParseMemorySize(config.MaxMemory)
+	if err != nil {
+		return Limits{}, fmt.Errorf("invalid max_memory '%s': %w", config.MaxMemory, err)
+	}
+	return Limits{Nice: config.Nice, MaxMemory: maxMemory, MaxCPUSeconds: uint64(config.MaxCPUSeconds)}, nil
+}
+
+// IsZero reports whether no limit is set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
@@ -0,0 +1,29 @@
+package execution
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLimitsApplied(t *testing.T) {
+	cmd := &Command{
+		Path: "sh",
+		// A child process started right away gets the limits too
+		Args:   []string{"-c", "sh -c 'ulimit -t; ulimit -v; nice'"},
+		Limits: Limits{Nice: 5, MaxMemory: 512 << 20, MaxCPUSeconds: 30},
+	}
+
+	output, err := NewExecutor().CombinedOutput(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("CombinedOutput() error = %v: %s", err, output)
+	}
+	// ulimit -v reports KiB
+	if got, want := strings.Fields(string(output)), []string{"30", "524288"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("limits = %q, want CPU %s and memory %s", got, want[0], want[1])
+	}
+	if nice, err := strconv.Atoi(strings.Fields(string(output))[2]); err != nil || nice < 5 {
+		t.Errorf("niceness = %d, %v; want at least 5", nice, err)
+	}
+}
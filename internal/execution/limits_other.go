@@ -0,0 +1,14 @@
+//go:build !unix
+
+package execution
+
+import (
+	"interop/internal/logging"
+	"os/exec"
+	"runtime"
+)
+
+// applyLimits only warns, resource limits are not supported on this platform
+func applyLimits(cmd *exec.Cmd, limits Limits) {
+	logging.Warning("Resource limits are not supported on %s, running without them", runtime.GOOS)
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package execution
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// applyLimits makes the command start through a shell that sets its priority
+// and resource limits and then runs it in its place, so they apply from its
+// first instruction and to every process it starts. A limit that can't be
+// set is reported on the command's stderr and the command still runs.
+func applyLimits(cmd *exec.Cmd, limits Limits) {
+	if cmd.Err != nil {
+		// Start reports the command that can't be found
+		return
+	}
+	var script strings.Builder
+	if limits.MaxMemory > 0 {
+		// ulimit -v takes KiB
+		fmt.Fprintf(&script, "ulimit -v %d 2>/dev/null || echo 'interop: failed to set max_memory, running without it' >&2\n", (limits.MaxMemory+1023)/1024)
+	}
+	if limits.MaxCPUSeconds > 0 {
+		fmt.Fprintf(&script, "ulimit -t %d 2>/dev/null || echo 'interop: failed to set max_cpu_seconds, running without it' >&2\n", limits.MaxCPUSeconds)
+	}
+	if limits.Nice != 0 {
+		fmt.Fprintf(&script, `exec nice -n %d "$0" "$@"`, limits.Nice)
+	} else {
+		script.WriteString(`exec "$0" "$@"`)
+	}
+
+	cmd.Args = append([]string{"sh", "-c", script.String(), cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+}
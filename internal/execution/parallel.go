@@ -0,0 +1,103 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Job is one of the commands RunParallel runs
+type Job struct {
+	Name string
+	// Run runs the command, writing its output to terminal in place of the
+	// terminal, see Command.Terminal
+	Run func(ctx context.Context, terminal io.Writer) error
+}
+
+// JobResult is the outcome of a job run by RunParallel
+type JobResult struct {
+	Name     string
+	ExitCode int // Exit code of the command, -1 when it failed without exiting
+	Err      error
+	Duration time.Duration
+}
+
+// RunParallel runs the jobs concurrently, at most limit at a time or all at
+// once when limit isn't positive, and returns their results in the order of
+// jobs. Their output is written to output line by line, each line prefixed
+// with the name of the job writing it, so lines of different jobs interleave
+// but never mix. A failing job doesn't stop the others.
+func RunParallel(ctx context.Context, jobs []Job, limit int, output io.Writer) []JobResult {
+	if limit <= 0 || limit > len(jobs) {
+		limit = len(jobs)
+	}
+
+	width := 0
+	for _, job := range jobs {
+		width = max(width, len(job.Name))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	results := make([]JobResult, len(jobs))
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			terminal := &prefixWriter{mu: &mu, output: output, prefix: fmt.Sprintf("[%-*s] ", width, job.Name)}
+			start := time.Now()
+			err := job.Run(ctx, terminal)
+			terminal.Flush()
+			results[i] = JobResult{Name: job.Name, ExitCode: ExitCode(err), Err: err, Duration: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// prefixWriter writes the complete lines written to it to output, each
+// prefixed, holding back a partial line until it ends or Flush is called.
+// Writers sharing mu write to output one line at a time.
+type prefixWriter struct {
+	mu      *sync.Mutex
+	output  io.Writer
+	prefix  string
+	partial []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(w.output, "%s%s", w.prefix, data[:i+1]); err != nil {
+			return 0, err
+		}
+		data = data[i+1:]
+	}
+	w.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// Flush writes a pending partial line, ending it
+func (w *prefixWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.partial) > 0 {
+		fmt.Fprintf(w.output, "%s%s\n", w.prefix, w.partial)
+		w.partial = nil
+	}
+}
@@ -0,0 +1,161 @@
+//go:build !windows
+
+package execution
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// killWaitDelay is how long Wait keeps reading the output of a killed
+// command, for processes that left its group but hold the output open
+const killWaitDelay = time.Second
+
+// killGroupOnCancel starts the command in its own process group and kills
+// the whole group when its context is done, so processes it started don't
+// keep running, or keep its output open, after a timeout
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = killWaitDelay
+}
+
+// stopGracefully makes a done context send the process group of a job
+// SIGTERM, and kill the group when it's still running after grace. It's used
+// after newJob, which puts the command in a group of its own.
+func stopGracefully(cmd *exec.Cmd, grace time.Duration) {
+	cmd.Cancel = func() error {
+		pid := cmd.Process.Pid
+		// Processes left in the group once the command is done are killed too
+		time.AfterFunc(grace, func() { syscall.Kill(-pid, syscall.SIGKILL) })
+		return syscall.Kill(-pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = grace
+}
+
+// forwardedSignals are passed on by a job to its process group
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// job runs a command in a process group of its own the way a shell runs a
+// job, so a timeout stops it with the processes it started. An interactive
+// job is given the terminal while it runs, so it can still read it and gets
+// the signals of the keyboard. Signals interop gets are passed on to the
+// group, and interop is stopped by them too once the command is done, as it
+// would be without a group of its own.
+type job struct {
+	tty     int // Terminal given to the job, -1 when none
+	pgrp    int // Process group of interop, taking the terminal back
+	signals chan os.Signal
+	stop    chan struct{}
+
+	mu       sync.Mutex
+	pid      int       // Process of the command, 0 before it started
+	received os.Signal // Signal interop got while the command ran
+}
+
+// newJob sets up cmd to run as a job. Interactive jobs take the terminal when
+// interop is its foreground process group.
+func newJob(cmd *exec.Cmd, interactive bool) *job {
+	killGroupOnCancel(cmd)
+
+	j := &job{tty: -1, signals: make(chan os.Signal, 1), stop: make(chan struct{})}
+	if interactive {
+		j.tty, j.pgrp = foregroundTerminal()
+		if j.tty >= 0 {
+			cmd.SysProcAttr.Foreground = true
+			cmd.SysProcAttr.Ctty = j.tty
+		}
+	}
+
+	signal.Notify(j.signals, forwardedSignals...)
+	go func() {
+		for {
+			select {
+			case sig := <-j.signals:
+				j.mu.Lock()
+				j.received = sig
+				if j.pid > 0 {
+					syscall.Kill(-j.pid, sig.(syscall.Signal))
+				}
+				j.mu.Unlock()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+	return j
+}
+
+// started records the process of the command, passing on a signal that came
+// before it
+func (j *job) started(pid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pid = pid
+	if j.received != nil {
+		syscall.Kill(-pid, j.received.(syscall.Signal))
+	}
+}
+
+// finished takes the terminal back and stops interop with the signal that
+// stopped the command, if interop got it or it came from the keyboard.
+// state is nil when the command didn't start.
+func (j *job) finished(state *os.ProcessState) {
+	signal.Stop(j.signals)
+	close(j.stop)
+
+	if j.tty >= 0 {
+		// interop is in the background now, where changing the foreground
+		// process group would stop it
+		signal.Ignore(syscall.SIGTTOU)
+		unix.IoctlSetPointerInt(j.tty, unix.TIOCSPGRP, j.pgrp)
+		signal.Reset(syscall.SIGTTOU)
+	}
+
+	j.mu.Lock()
+	sig := j.received
+	j.mu.Unlock()
+	if status, ok := exitStatus(state); sig == nil && j.tty >= 0 && ok && status.Signaled() {
+		if s := status.Signal(); s == syscall.SIGINT || s == syscall.SIGQUIT {
+			sig = s
+		}
+	}
+	if sig != nil {
+		syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+	}
+}
+
+// exitStatus returns the wait status of a finished process
+func exitStatus(state *os.ProcessState) (syscall.WaitStatus, bool) {
+	if state == nil {
+		return 0, false
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	return status, ok
+}
+
+// foregroundTerminal returns the terminal of the standard streams and the
+// process group of interop when interop is the terminal's foreground group,
+// and -1 otherwise
+func foregroundTerminal() (int, int) {
+	for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		fd := int(f.Fd())
+		foreground, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+		if err != nil {
+			continue
+		}
+		if pgrp := syscall.Getpgrp(); foreground == pgrp {
+			return fd, pgrp
+		}
+		return -1, 0
+	}
+	return -1, 0
+}
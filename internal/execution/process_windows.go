@@ -0,0 +1,25 @@
+package execution
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// killGroupOnCancel leaves the command as is, only the command itself is
+// killed when its context is done on Windows
+func killGroupOnCancel(cmd *exec.Cmd) {}
+
+// stopGracefully leaves the command as is, Windows has no SIGTERM to ask it
+// to stop, so it's killed when its context is done
+func stopGracefully(cmd *exec.Cmd, grace time.Duration) {}
+
+// job leaves the command as is on Windows, where it has no process group to
+// stop with it
+type job struct{}
+
+func newJob(cmd *exec.Cmd, interactive bool) *job { return &job{} }
+
+func (j *job) started(pid int) {}
+
+func (j *job) finished(state *os.ProcessState) {}
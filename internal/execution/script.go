@@ -0,0 +1,162 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultScriptInterpreter runs scripts that set neither an interpreter nor a
+// shebang line, like the system does for executable files without one
+const DefaultScriptInterpreter = "sh"
+
+// Script runtimes interop knows how to run and install dependencies for
+const (
+	RuntimePython = "python"
+	RuntimeNode   = "node"
+	RuntimeDeno   = "deno"
+)
+
+// scriptExtensions are the script file extensions runtimes expect
+var scriptExtensions = map[string]string{
+	RuntimePython: ".py",
+	RuntimeNode:   ".js",
+	RuntimeDeno:   ".ts",
+}
+
+// ScriptRun describes how a script runs: the interpreter command line its
+// file is appended to and where the file is written
+type ScriptRun struct {
+	Interpreter []string
+	Dir         string // Directory of the script file, empty for the system temp directory
+	Ext         string // Extension the runtime expects, like ".py"
+}
+
+// ScriptInterpreter returns the command line of the interpreter running a
+// script: interpreter when set, else the one named by the script's shebang
+// line, else DefaultScriptInterpreter. The shebang is read by interop rather
+// than the system, so scripts run the same from directories mounted noexec.
+// A bare deno is completed to "deno run -A", which deno needs to run a file.
+func ScriptInterpreter(script, interpreter string) []string {
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		if firstLine, _, _ := strings.Cut(script, "\n"); strings.HasPrefix(firstLine, "#!") {
+			fields = strings.Fields(strings.TrimPrefix(firstLine, "#!"))
+		}
+	}
+	if len(fields) == 0 {
+		return []string{DefaultScriptInterpreter}
+	}
+	if i := runtimeIndex(fields); i >= 0 && i == len(fields)-1 && scriptRuntime(fields[i]) == RuntimeDeno {
+		fields = append(fields, "run", "-A")
+	}
+	return fields
+}
+
+// ScriptRuntime returns the runtime of a script's interpreter, one of
+// RuntimePython, RuntimeNode and RuntimeDeno, or empty for other interpreters
+func ScriptRuntime(script, interpreter string) string {
+	fields := ScriptInterpreter(script, interpreter)
+	if i := runtimeIndex(fields); i >= 0 {
+		return scriptRuntime(fields[i])
+	}
+	return ""
+}
+
+// runtimeIndex returns the index of the program in an interpreter command
+// line, skipping an env wrapper, or -1 when it's not a known runtime
+func runtimeIndex(fields []string) int {
+	i := 0
+	if filepath.Base(fields[0]) == "env" {
+		i = 1
+		for i < len(fields) && strings.HasPrefix(fields[i], "-") {
+			i++
+		}
+	}
+	if i < len(fields) && scriptRuntime(fields[i]) != "" {
+		return i
+	}
+	return -1
+}
+
+// scriptRuntime returns the runtime of an interpreter program
+func scriptRuntime(program string) string {
+	name := strings.TrimSuffix(filepath.Base(program), ".exe")
+	switch {
+	case name == "python" || strings.HasPrefix(name, "python3"):
+		return RuntimePython
+	case name == "node" || name == "nodejs":
+		return RuntimeNode
+	case name == "deno":
+		return RuntimeDeno
+	}
+	return ""
+}
+
+// PrepareScript returns how to run script. Python and node scripts with
+// dependencies run in an environment holding them, which is installed on
+// first use and shared by scripts with the same interpreter and dependencies.
+func PrepareScript(ctx context.Context, script, interpreter string, dependencies []string) (*ScriptRun, error) {
+	fields := ScriptInterpreter(script, interpreter)
+	run := &ScriptRun{Interpreter: fields}
+	i := runtimeIndex(fields)
+	if i < 0 {
+		if len(dependencies) > 0 {
+			return nil, fmt.Errorf("dependencies need a python or node interpreter, not '%s'", strings.Join(fields, " "))
+		}
+		return run, nil
+	}
+
+	runtime := scriptRuntime(fields[i])
+	run.Ext = scriptExtensions[runtime]
+	if len(dependencies) == 0 {
+		return run, nil
+	}
+
+	switch runtime {
+	case RuntimePython:
+		dir, err := scriptEnv(ctx, fields[:i+1], dependencies, installPython)
+		if err != nil {
+			return nil, err
+		}
+		// The environment's python takes the place of the configured one
+		run.Interpreter = append([]string{venvPython(dir)}, fields[i+1:]...)
+	case RuntimeNode:
+		dir, err := scriptEnv(ctx, fields[:i+1], dependencies, installNode)
+		if err != nil {
+			return nil, err
+		}
+		// Node looks for packages in the node_modules next to the script
+		run.Dir = dir
+	default:
+		return nil, fmt.Errorf("%s resolves its dependencies from the script's imports, remove the dependencies option", runtime)
+	}
+	return run, nil
+}
+
+// Write writes script to a new executable file and returns its path. The
+// caller removes the file once the script has run.
+func (r *ScriptRun) Write(script string) (string, error) {
+	file, err := os.CreateTemp(r.Dir, "interop-script-*"+r.Ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create script file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(script); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write script file: %w", err)
+	}
+	if err := file.Chmod(0o700); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to make script file executable: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// Command returns the command line running the script file at path
+func (r *ScriptRun) Command(path string) []string {
+	return append(append([]string{}, r.Interpreter...), path)
+}
@@ -0,0 +1,119 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// scriptEnvDirName is the directory of script environments inside the app directory
+const scriptEnvDirName = "script-envs"
+
+// installFunc installs dependencies into the environment directory dir for
+// the interpreter command line
+type installFunc func(ctx context.Context, dir string, interpreter, dependencies []string) error
+
+// ScriptEnvRoot returns the directory holding the environments of scripts
+// with dependencies
+func ScriptEnvRoot() (string, error) {
+	return settings.StateDir(scriptEnvDirName)
+}
+
+// scriptEnv returns the environment holding dependencies for interpreter,
+// installing it when it doesn't exist yet. Environments are named after a
+// hash of the interpreter and dependencies, and are built in a temporary
+// directory that is renamed into place, so concurrent runs never use half an
+// environment.
+func scriptEnv(ctx context.Context, interpreter, dependencies []string, install installFunc) (string, error) {
+	root, err := ScriptEnvRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate script environments: %w", err)
+	}
+	sorted := append([]string{}, dependencies...)
+	sort.Strings(sorted)
+	hash := sha256.Sum256([]byte(strings.Join(interpreter, " ") + "\x00" + strings.Join(sorted, "\x00")))
+	dir := filepath.Join(root, hex.EncodeToString(hash[:8]))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create script environments directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(root, ".install-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create script environment: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	logging.Message("Installing %s for %s", strings.Join(sorted, ", "), strings.Join(interpreter, " "))
+	if err := install(ctx, tmp, interpreter, sorted); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another run finished installing the same environment first
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return dir, nil
+		}
+		return "", fmt.Errorf("failed to create script environment: %w", err)
+	}
+	return dir, nil
+}
+
+// installPython creates a virtual environment in dir with the dependencies,
+// using uv when it's installed and venv and pip otherwise
+func installPython(ctx context.Context, dir string, interpreter, dependencies []string) error {
+	python := venvPython(dir)
+	if _, err := exec.LookPath("uv"); err == nil {
+		if err := runInstall(ctx, "uv", "venv", "--quiet", "--python", interpreter[len(interpreter)-1], dir); err != nil {
+			return err
+		}
+		return runInstall(ctx, "uv", append([]string{"pip", "install", "--quiet", "--python", python}, dependencies...)...)
+	}
+
+	args := append(append([]string{}, interpreter[1:]...), "-m", "venv", dir)
+	if err := runInstall(ctx, interpreter[0], args...); err != nil {
+		return err
+	}
+	return runInstall(ctx, python, append([]string{"-m", "pip", "install", "--quiet", "--disable-pip-version-check"}, dependencies...)...)
+}
+
+// installNode installs the dependencies into the node_modules of dir
+func installNode(ctx context.Context, dir string, interpreter, dependencies []string) error {
+	return runInstall(ctx, "npm", append([]string{"install", "--prefix", dir, "--no-audit", "--no-fund", "--silent"}, dependencies...)...)
+}
+
+// venvPython returns the python of the virtual environment in dir
+func venvPython(dir string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(dir, "Scripts", "python.exe")
+	}
+	return filepath.Join(dir, "bin", "python")
+}
+
+// runInstall runs an install step, returning its output with the error when
+// it fails
+func runInstall(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(output.String())
+		if message == "" {
+			return fmt.Errorf("failed to install script dependencies: %s: %w", name, err)
+		}
+		return fmt.Errorf("failed to install script dependencies: %s: %w\n%s", name, err, message)
+	}
+	return nil
+}
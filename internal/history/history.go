@@ -0,0 +1,299 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"interop/internal/execution"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultKeep is the number of runs retained when history.keep is not set
+	DefaultKeep = 200
+	// dirName is the history directory inside the app directory
+	dirName = "history"
+	// fileName is the file in the history directory runs are recorded in, one
+	// JSON entry per line in the order they finished
+	fileName = "history.jsonl"
+)
+
+// Entry is a recorded run of `interop run`
+type Entry struct {
+	ID         string    `json:"id"`
+	Command    string    `json:"command"` // Command or alias as given
+	Args       []string  `json:"args,omitempty"`
+	Project    string    `json:"project,omitempty"` // Project the command ran for, if any
+	Dir        string    `json:"dir"`               // Directory interop was run in
+	Argv       []string  `json:"argv"`              // Arguments of interop repeating the run, see Rerun
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	ExitCode   int       `json:"exit_code"`
+	OutputFile string    `json:"output_file,omitempty"` // Copy of the run's output, with capture_output
+	// CorrelationID tags the log lines of the run, see logging.CorrelationEnvVar
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	output *os.File
+}
+
+// Root returns the directory holding the history
+func Root() (string, error) {
+	return settings.StateDir(dirName)
+}
+
+// Keep returns the number of runs to retain for the given settings
+func Keep(cfg settings.HistoryConfig) int {
+	if cfg.Keep <= 0 {
+		return DefaultKeep
+	}
+	return cfg.Keep
+}
+
+// Start begins the entry of a run of command with args, which argv repeats.
+// With capture, the run's output is kept in a file Output writes to.
+func Start(command, project string, args, argv []string, capture bool) (*Entry, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate history directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	entry := &Entry{
+		ID:            id,
+		Command:       command,
+		Args:          args,
+		Project:       project,
+		Dir:           dir,
+		Argv:          argv,
+		Started:       time.Now(),
+		CorrelationID: logging.InheritedCorrelationID(),
+	}
+
+	if capture {
+		// Arguments and output may hold secrets, so only the user can read them
+		entry.OutputFile = filepath.Join(root, id+".log")
+		if entry.output, err = os.OpenFile(entry.OutputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to create history output file: %w", err)
+		}
+	}
+	return entry, nil
+}
+
+// Output returns the writer the run's output is copied to, nil without
+// capture_output
+func (e *Entry) Output() io.Writer {
+	if e.output == nil {
+		return nil
+	}
+	return e.output
+}
+
+// Finish records how the run ended and removes the oldest entries so that at
+// most keep remain. Errors that aren't a command's exit status count as exit
+// code 1, like for jobs.
+func (e *Entry) Finish(runErr error, keep int) error {
+	e.Finished = time.Now()
+	e.ExitCode = execution.ExitCode(runErr)
+	if e.ExitCode < 0 {
+		e.ExitCode = 1
+	}
+	if e.output != nil {
+		e.output.Close()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	root, err := Root()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(root, fileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return prune(root, keep)
+}
+
+// List returns the recorded runs, the most recently finished first
+func List() ([]*Entry, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate history directory: %w", err)
+	}
+	entries, err := read(filepath.Join(root, fileName))
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Get returns the recorded run whose ID is id or starts with it
+func Get(id string) (*Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Entry
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+		if id != "" && strings.HasPrefix(entry.ID, id) {
+			matches = append(matches, entry)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("run '%s' not found in the history", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("run ID '%s' is ambiguous, it matches %d runs", id, len(matches))
+	}
+}
+
+// Duration returns how long the run took
+func (e *Entry) Duration() time.Duration {
+	return e.Finished.Sub(e.Started)
+}
+
+// WriteOutput copies the captured output of the run to w
+func (e *Entry) WriteOutput(w io.Writer) error {
+	if e.OutputFile == "" {
+		return fmt.Errorf("the output of run %s wasn't captured, set capture_output in [history]", e.ID)
+	}
+	file, err := os.Open(e.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open the output of run %s: %w", e.ID, err)
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Rerun runs executable, the interop binary, with the arguments of the run in
+// the directory it was run in, connected to the terminal. The new run is
+// recorded as an entry of its own.
+func (e *Entry) Rerun(ctx context.Context, executable string) error {
+	if len(e.Argv) == 0 {
+		return fmt.Errorf("run %s can't be re-run, it has no recorded arguments", e.ID)
+	}
+	cmd := exec.CommandContext(ctx, executable, e.Argv...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if info, err := os.Stat(e.Dir); err == nil && info.IsDir() {
+		cmd.Dir = e.Dir
+	} else {
+		logging.Warning("Directory %s of run %s is gone, re-running in the current directory", e.Dir, e.ID)
+	}
+	return cmd.Run()
+}
+
+// read returns the entries of the history file in the order they were
+// written, skipping lines that aren't entries
+func read(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logging.Warning("Skipping history entry %s:%d: %v", path, line, err)
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// prune rewrites the history file in root without its oldest entries when it
+// has more than keep, removing their captured output
+func prune(root string, keep int) error {
+	path := filepath.Join(root, fileName)
+	entries, err := read(path)
+	if err != nil || len(entries) <= keep {
+		return err
+	}
+
+	dropped, kept := entries[:len(entries)-keep], entries[len(entries)-keep:]
+	var data []byte
+	for _, entry := range kept {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	// Replace the file in one step so concurrent readers see either version
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to prune history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to prune history: %w", err)
+	}
+	for _, entry := range dropped {
+		if entry.OutputFile != "" {
+			os.Remove(entry.OutputFile)
+		}
+	}
+	return nil
+}
+
+// newID returns a short random run ID
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
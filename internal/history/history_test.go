@@ -0,0 +1,100 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"interop/internal/testutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// record runs through the entry of a run that writes output and ends with runErr
+func record(t *testing.T, command string, capture bool, output string, runErr error, keep int) *Entry {
+	t.Helper()
+	entry, err := Start(command, "api", []string{"--force"}, []string{"run", "--", command, "--", "--force"}, capture)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if w := entry.Output(); w != nil {
+		fmt.Fprint(w, output)
+	} else if capture {
+		t.Fatal("Expected an output writer with capture")
+	}
+	if err := entry.Finish(runErr, keep); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	return entry
+}
+
+func TestHistory(t *testing.T) {
+	testutil.New(t)
+
+	first := record(t, "build", true, "built\n", nil, DefaultKeep)
+	second := record(t, "deploy", false, "", errors.New("no such command"), DefaultKeep)
+
+	list, err := List()
+	if err != nil || len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Fatalf("List() = %v, %v; want the two runs, the latest first", list, err)
+	}
+	if list[1].Command != "build" || list[1].Project != "api" || list[1].ExitCode != 0 || list[1].Dir == "" {
+		t.Errorf("Unexpected entry %+v", list[1])
+	}
+	if list[0].ExitCode != 1 {
+		t.Errorf("exit code = %d, want 1 for an error without an exit status", list[0].ExitCode)
+	}
+
+	got, err := Get(first.ID[:4])
+	if err != nil || got.ID != first.ID {
+		t.Fatalf("Get() by prefix = %v, %v", got, err)
+	}
+	var output bytes.Buffer
+	if err := got.WriteOutput(&output); err != nil || output.String() != "built\n" {
+		t.Errorf("WriteOutput() = %q, %v; want the captured output", output.String(), err)
+	}
+	if err := list[0].WriteOutput(&output); err == nil {
+		t.Error("Expected an error for a run without captured output")
+	}
+	if _, err := Get("zzzz"); err == nil {
+		t.Error("Expected an error for an unknown run")
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	testutil.New(t)
+
+	oldest := record(t, "one", true, "1\n", nil, 2)
+	record(t, "two", false, "", nil, 2)
+	record(t, "three", false, "", nil, 2)
+
+	list, err := List()
+	if err != nil || len(list) != 2 || list[0].Command != "three" || list[1].Command != "two" {
+		t.Fatalf("List() = %v, %v; want the two latest runs", list, err)
+	}
+	if _, err := os.Stat(oldest.OutputFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the output of the pruned run to be removed, got %v", err)
+	}
+}
+
+func TestHistoryRerun(t *testing.T) {
+	env := testutil.New(t)
+
+	entry := record(t, "build", false, "", nil, DefaultKeep)
+	entry.Argv = []string{"-c", "pwd > out.txt"}
+	entry.Dir = env.Dir("work")
+	if err := entry.Rerun(context.Background(), "/bin/sh"); err != nil {
+		t.Fatalf("Rerun() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(env.Dir("work"), "out.txt"))
+	if err != nil || !strings.Contains(string(data), "work") {
+		t.Errorf("Expected the run to repeat in its directory, got %q, %v", data, err)
+	}
+
+	entry.Argv = nil
+	if err := entry.Rerun(context.Background(), "/bin/sh"); err == nil {
+		t.Error("Expected an error re-running a run without arguments")
+	}
+}
@@ -0,0 +1,344 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"interop/internal/execution"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvVar tells a detached run the ID of its job so it can record how it ended
+	EnvVar = "INTEROP_JOB_ID"
+	// dirName is the jobs directory inside the app directory
+	dirName = "jobs"
+)
+
+// Status is the state of a job
+type Status string
+
+const (
+	StatusQueued  Status = "queued" // Waiting for an execution slot
+	StatusRunning Status = "running"
+	StatusExited  Status = "exited"  // Finished with exit code 0
+	StatusFailed  Status = "failed"  // Finished with a non-zero exit code
+	StatusKilled  Status = "killed"  // Stopped with Kill
+	StatusUnknown Status = "unknown" // Gone without recording how it ended
+)
+
+// Job is a command run detached from the terminal that started it
+type Job struct {
+	ID         string     `json:"id"`
+	Command    string     `json:"command"`
+	Args       []string   `json:"args,omitempty"`
+	Project    string     `json:"project,omitempty"`    // Project the command ran for, if any
+	Executable string     `json:"executable,omitempty"` // Interop binary that ran the job, see Rerun
+	Argv       []string   `json:"argv,omitempty"`       // Arguments the binary ran with
+	PID        int        `json:"pid"`
+	LogFile    string     `json:"log_file"`
+	Started    time.Time  `json:"started"`
+	Finished   *time.Time `json:"finished,omitempty"` // From the job's exit file, or when it was killed
+	ExitCode   int        `json:"exit_code"`
+	Killed     bool       `json:"killed,omitempty"`
+	// CorrelationID tags the log lines of the run, see logging.CorrelationEnvVar
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// Root returns the directory holding job records and logs
+func Root() (string, error) {
+	return settings.StateDir(dirName)
+}
+
+// Start runs interop with argv in a new session, its output appended to the
+// job's log, and returns without waiting for it. command is the name shown
+// for the job and project the project it runs for, if any.
+func Start(command, project string, args []string, argv []string) (*Job, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	return start(executable, command, project, args, argv)
+}
+
+// Rerun starts the job's command again with the same arguments, options and
+// interop binary, as a new job
+func (j *Job) Rerun() (*Job, error) {
+	if j.Executable == "" || len(j.Argv) == 0 {
+		return nil, fmt.Errorf("job %s was started by an older version of interop and can't be re-run", j.ID)
+	}
+	return start(j.Executable, j.Command, j.Project, j.Args, j.Argv)
+}
+
+// start runs executable with argv as a new job, see Start
+func start(executable, command, project string, args []string, argv []string) (*Job, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate jobs directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:         id,
+		Command:    command,
+		Args:       args,
+		Project:    project,
+		Executable: executable,
+		Argv:       argv,
+		LogFile:    filepath.Join(root, id+".log"),
+		Started:    time.Now(),
+		// The job keeps the ID of the run starting it, if any
+		CorrelationID: logging.InheritedCorrelationID(),
+	}
+
+	logFile, err := os.OpenFile(job.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, argv...)
+	cmd.Env = append(os.Environ(), EnvVar+"="+id, logging.CorrelationEnvVar+"="+job.CorrelationID, "NO_COLOR=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start job: %w", err)
+	}
+	job.PID = cmd.Process.Pid
+	if err := job.save(); err != nil {
+		cmd.Process.Kill()
+		return nil, err
+	}
+	cmd.Process.Release()
+	return job, nil
+}
+
+// Current returns the ID of the job this process runs for, empty when it
+// isn't detached. The variable is cleared so commands the job runs don't
+// report as the job.
+func Current() string {
+	id := os.Getenv(EnvVar)
+	os.Unsetenv(EnvVar)
+	return id
+}
+
+// Finish records how the run of the job with id ended, doing nothing for an
+// empty id. Errors that aren't a command's exit status count as exit code 1.
+// The end is written to a file of its own so it can't race with the record
+// the starting process writes.
+func Finish(id string, runErr error) {
+	if id == "" {
+		return
+	}
+	exitCode := execution.ExitCode(runErr)
+	if exitCode < 0 {
+		exitCode = 1
+	}
+	root, err := Root()
+	if err == nil {
+		var data []byte
+		data, err = json.Marshal(exit{Finished: time.Now(), ExitCode: exitCode})
+		if err == nil {
+			err = os.WriteFile(filepath.Join(root, id+".exit"), data, 0o644)
+		}
+	}
+	if err != nil {
+		logging.Warning("Failed to record the end of job %s: %v", id, err)
+	}
+}
+
+// List returns all jobs, the most recently started first
+func List() ([]*Job, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate jobs directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(root, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(paths))
+	for _, path := range paths {
+		job, err := load(path)
+		if err != nil {
+			logging.Warning("Skipping job record %s: %v", path, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.After(jobs[j].Started) })
+	return jobs, nil
+}
+
+// Get returns the job whose ID is id or starts with it
+func Get(id string) (*Job, error) {
+	jobs, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Job
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, nil
+		}
+		if id != "" && strings.HasPrefix(job.ID, id) {
+			matches = append(matches, job)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("job '%s' not found", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("job ID '%s' is ambiguous, it matches %d jobs", id, len(matches))
+	}
+}
+
+// Status returns the state of the job, checking whether its process is alive
+// when it hasn't recorded its end
+func (j *Job) Status() Status {
+	switch {
+	case j.Killed:
+		return StatusKilled
+	case j.Finished != nil && j.ExitCode == 0:
+		return StatusExited
+	case j.Finished != nil:
+		return StatusFailed
+	case alive(j.PID) && queued(j.PID):
+		return StatusQueued
+	case alive(j.PID):
+		return StatusRunning
+	default:
+		return StatusUnknown
+	}
+}
+
+// Duration returns how long the job ran, or has been running so far
+func (j *Job) Duration() time.Duration {
+	if j.Finished != nil {
+		return j.Finished.Sub(j.Started)
+	}
+	return time.Since(j.Started)
+}
+
+// Kill terminates a running job along with the processes it started
+func (j *Job) Kill() error {
+	if status := j.Status(); status != StatusRunning && status != StatusQueued {
+		return fmt.Errorf("job %s is not running", j.ID)
+	}
+	if err := terminate(j.PID); err != nil {
+		return fmt.Errorf("failed to kill job %s: %w", j.ID, err)
+	}
+	finished := time.Now()
+	j.Killed = true
+	j.Finished = &finished
+	return j.save()
+}
+
+// followInterval is how often WriteLogs checks for new output when following
+const followInterval = 500 * time.Millisecond
+
+// WriteLogs copies the job's output to w. With follow it keeps copying new
+// output until the job stops running or ctx is done.
+func (j *Job) WriteLogs(ctx context.Context, w io.Writer, follow bool) error {
+	logFile, err := os.Open(j.LogFile)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	if _, err := io.Copy(w, logFile); err != nil {
+		return err
+	}
+	if !follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for status := j.Status(); status == StatusRunning || status == StatusQueued; status = j.Status() {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if _, err := io.Copy(w, logFile); err != nil {
+			return err
+		}
+	}
+	// Output written between the last copy and the end of the job
+	_, err = io.Copy(w, logFile)
+	return err
+}
+
+// save writes the job record next to its log
+func (j *Job) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job %s: %w", j.ID, err)
+	}
+	path := strings.TrimSuffix(j.LogFile, ".log") + ".json"
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write job %s: %w", j.ID, err)
+	}
+	return nil
+}
+
+// exit is how a job ended, written by the job itself
+type exit struct {
+	Finished time.Time `json:"finished"`
+	ExitCode int       `json:"exit_code"`
+}
+
+// load reads a job record along with how it ended, when it has
+func load(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+
+	data, err = os.ReadFile(strings.TrimSuffix(path, ".json") + ".exit")
+	if err == nil {
+		var end exit
+		if err := json.Unmarshal(data, &end); err != nil {
+			return nil, err
+		}
+		job.Finished = &end.Finished
+		job.ExitCode = end.ExitCode
+	}
+	return &job, nil
+}
+
+// newID returns a short random job ID
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"interop/internal/testutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helperEnv makes the test binary act as a detached interop run, see
+// TestHelperProcess
+const helperEnv = "INTEROP_JOBS_HELPER"
+
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperEnv)
+	if mode == "" {
+		return
+	}
+
+	id := Current()
+	fmt.Println("hello from", mode)
+	switch mode {
+	case "fail":
+		Finish(id, errors.New("command failed"))
+	case "sleep":
+		time.Sleep(30 * time.Second)
+	default:
+		Finish(id, nil)
+	}
+	os.Exit(0)
+}
+
+// startHelper starts the test binary as a job running TestHelperProcess
+func startHelper(t *testing.T, mode string) *Job {
+	t.Helper()
+	t.Setenv(helperEnv, mode)
+	job, err := Start("build", "api", []string{mode}, []string{"-test.run=^TestHelperProcess$"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	return job
+}
+
+// waitFor reloads the job until it leaves the running state
+func waitFor(t *testing.T, id string) *Job {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		job, err := Get(id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if job.Status() != StatusRunning {
+			return job
+		}
+	}
+	t.Fatalf("job %s is still running", id)
+	return nil
+}
+
+func TestJobLifecycle(t *testing.T) {
+	testutil.New(t)
+
+	done := startHelper(t, "done")
+	failed := startHelper(t, "fail")
+
+	job := waitFor(t, done.ID)
+	if job.Status() != StatusExited || job.Finished == nil {
+		t.Errorf("status = %s, want exited", job.Status())
+	}
+	var logs bytes.Buffer
+	if err := job.WriteLogs(context.Background(), &logs, true); err != nil {
+		t.Fatalf("WriteLogs() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "hello from done") {
+		t.Errorf("logs = %q, want the job's output", logs.String())
+	}
+
+	job = waitFor(t, failed.ID)
+	if job.Status() != StatusFailed || job.ExitCode != 1 {
+		t.Errorf("status = %s, exit code %d; want failed with 1", job.Status(), job.ExitCode)
+	}
+
+	list, err := List()
+	if err != nil || len(list) != 2 || list[0].ID != failed.ID {
+		t.Errorf("List() = %v, %v; want the two jobs, the latest first", list, err)
+	}
+	if got, err := Get(done.ID[:4]); err != nil || got.ID != done.ID {
+		t.Errorf("Get() by prefix = %v, %v", got, err)
+	}
+	if _, err := Get("zzzz"); err == nil {
+		t.Error("Expected an error for an unknown job")
+	}
+}
+
+func TestKill(t *testing.T) {
+	testutil.New(t)
+
+	job := startHelper(t, "sleep")
+	if job.Status() != StatusRunning {
+		t.Fatalf("status = %s, want running", job.Status())
+	}
+	if err := job.Kill(); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+
+	job = waitFor(t, job.ID)
+	if job.Status() != StatusKilled {
+		t.Errorf("status = %s, want killed", job.Status())
+	}
+	if err := job.Kill(); err == nil {
+		t.Error("Expected an error killing a job that isn't running")
+	}
+}
+
+func TestRerun(t *testing.T) {
+	testutil.New(t)
+
+	first := waitFor(t, startHelper(t, "done").ID)
+	second, err := first.Rerun()
+	if err != nil {
+		t.Fatalf("Rerun() error = %v", err)
+	}
+	if second.ID == first.ID || second.Command != first.Command || second.Project != "api" ||
+		strings.Join(second.Args, " ") != strings.Join(first.Args, " ") {
+		t.Errorf("Rerun() = %+v, want a new job for %+v", second, first)
+	}
+	if job := waitFor(t, second.ID); job.Status() != StatusExited {
+		t.Errorf("status = %s, want exited", job.Status())
+	}
+
+	first.Executable = ""
+	if _, err := first.Rerun(); err == nil {
+		t.Error("Expected an error re-running a job without its executable")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the command in its own session so it outlives the terminal
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// alive reports whether a process with pid exists
+func alive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
+
+// terminate sends SIGTERM to the process group the job leads
+func terminate(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGTERM)
+}
+
+// tryLock takes an exclusive lock on file without waiting, locked is false
+// when another holder has it
+func tryLock(file *os.File) (locked bool, err error) {
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlock releases a lock taken with tryLock
+func unlock(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,248 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"interop/internal/logging"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// slotsDir holds one lock file per execution slot
+	slotsDir = "slots"
+	// queueDir holds an entry per execution waiting for a slot
+	queueDir = "queue"
+	// pollInterval is how often a queued execution checks for a free slot
+	pollInterval = 250 * time.Millisecond
+)
+
+// Slot is one of the max_concurrent_executions slots, held by a running
+// command. Slots are file locks, so a process that dies frees its slot.
+type Slot struct {
+	Index   int       `json:"index"`
+	Name    string    `json:"name"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+
+	file *os.File
+}
+
+// QueueEntry is an execution waiting for a slot
+type QueueEntry struct {
+	Name     string    `json:"name"`
+	PID      int       `json:"pid"`
+	Enqueued time.Time `json:"enqueued"`
+
+	path string
+}
+
+// Acquire waits for a free slot out of max and holds it for the execution
+// called name, until Release. Executions get slots in the order they asked
+// for them. With max 0 or less there's no limit and the slot is nil.
+func Acquire(ctx context.Context, max int, name string) (*Slot, error) {
+	if max <= 0 {
+		return nil, nil
+	}
+	root, err := Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate jobs directory: %w", err)
+	}
+	for _, dir := range []string{slotsDir, queueDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create %s directory: %w", dir, err)
+		}
+	}
+
+	// Take a free slot right away unless others are already waiting
+	waiting, err := queue(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(waiting) == 0 {
+		if slot, err := tryAcquire(root, max, name); slot != nil || err != nil {
+			return slot, err
+		}
+	}
+
+	entry, err := enqueue(root, name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(entry.path)
+	logging.Warning("All %d execution slots are busy, '%s' is queued behind %d other execution(s)", max, name, len(waiting))
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		waiting, err := queue(root)
+		if err != nil {
+			return nil, err
+		}
+		if len(waiting) > 0 && waiting[0].path == entry.path {
+			if slot, err := tryAcquire(root, max, name); slot != nil || err != nil {
+				return slot, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for an execution slot: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Release frees the slot. It does nothing for a nil slot.
+func (s *Slot) Release() {
+	if s == nil || s.file == nil {
+		return
+	}
+	s.file.Truncate(0)
+	unlock(s.file)
+	s.file.Close()
+	s.file = nil
+}
+
+// Queue returns the slots in use and the executions waiting for one, the
+// next to run first
+func Queue() ([]Slot, []QueueEntry, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to locate jobs directory: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(root, slotsDir, "*.lock"))
+	if err != nil {
+		return nil, nil, err
+	}
+	var running []Slot
+	for _, path := range paths {
+		if slot, ok := heldSlot(path); ok {
+			running = append(running, slot)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Started.Before(running[j].Started) })
+
+	waiting, err := queue(root)
+	return running, waiting, err
+}
+
+// tryAcquire locks the first free slot, returning nil when all are held
+func tryAcquire(root string, max int, name string) (*Slot, error) {
+	for i := 0; i < max; i++ {
+		path := filepath.Join(root, slotsDir, fmt.Sprintf("%d.lock", i))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open execution slot: %w", err)
+		}
+		locked, err := tryLock(file)
+		if err != nil || !locked {
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to lock execution slot: %w", err)
+			}
+			continue
+		}
+
+		slot := &Slot{Index: i, Name: name, PID: os.Getpid(), Started: time.Now(), file: file}
+		if data, err := json.Marshal(slot); err == nil {
+			file.Truncate(0)
+			file.WriteAt(data, 0)
+		}
+		return slot, nil
+	}
+	return nil, nil
+}
+
+// heldSlot reads the holder of a slot, ok is false when the slot is free
+func heldSlot(path string) (Slot, bool) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return Slot{}, false
+	}
+	defer file.Close()
+	if locked, err := tryLock(file); err != nil || locked {
+		if locked {
+			unlock(file)
+		}
+		return Slot{}, false
+	}
+
+	var slot Slot
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &slot) != nil {
+		// Held, but the holder hasn't described itself yet
+		slot = Slot{Name: "unknown"}
+	}
+	return slot, true
+}
+
+// enqueue adds an entry for this process to the queue. Entry names start
+// with the time they were created, so they sort in queue order.
+func enqueue(root, name string) (*QueueEntry, error) {
+	entry := &QueueEntry{Name: name, PID: os.Getpid(), Enqueued: time.Now()}
+	file, err := os.CreateTemp(filepath.Join(root, queueDir), fmt.Sprintf("%020d-*.json", entry.Enqueued.UnixNano()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to join the execution queue: %w", err)
+	}
+	defer file.Close()
+
+	entry.path = file.Name()
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		os.Remove(entry.path)
+		return nil, fmt.Errorf("failed to join the execution queue: %w", err)
+	}
+	return entry, nil
+}
+
+// queue returns the waiting executions in order, removing the entries of
+// processes that are gone
+func queue(root string) ([]QueueEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(root, queueDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var entries []QueueEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var entry QueueEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			// Still being written
+			continue
+		}
+		if !alive(entry.PID) {
+			os.Remove(path)
+			continue
+		}
+		entry.path = path
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// queued reports whether the process with pid waits for a slot
+func queued(pid int) bool {
+	root, err := Root()
+	if err != nil {
+		return false
+	}
+	entries, err := queue(root)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.PID == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"interop/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	testutil.New(t)
+
+	slot, err := Acquire(context.Background(), 0, "unlimited")
+	if err != nil || slot != nil {
+		t.Fatalf("Acquire() without a limit = %v, %v, want nil, nil", slot, err)
+	}
+
+	first, err := Acquire(context.Background(), 1, "first")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	if _, err := Acquire(ctx, 1, "second"); err == nil {
+		t.Fatal("Acquire() succeeded while the only slot is held")
+	}
+
+	running, waiting, err := Queue()
+	if err != nil {
+		t.Fatalf("Queue() error = %v", err)
+	}
+	if len(running) != 1 || running[0].Name != "first" {
+		t.Errorf("Queue() running = %+v, want the first execution", running)
+	}
+	if len(waiting) != 0 {
+		t.Errorf("Queue() waiting = %+v, want none after giving up", waiting)
+	}
+
+	first.Release()
+	second, err := Acquire(context.Background(), 1, "second")
+	if err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+	second.Release()
+
+	if running, _, _ := Queue(); len(running) != 0 {
+		t.Errorf("Queue() running = %+v after Release(), want none", running)
+	}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// CorrelationEnvVar passes the correlation ID of a run to the processes it
+// starts, so a command, a detached job and their logs share it
+const CorrelationEnvVar = "INTEROP_CORRELATION_ID"
+
+// correlationKey is the context key of the correlation ID
+type correlationKey struct{}
+
+// NewCorrelationID returns a random ID to tag everything a single run or
+// tool call logs and records
+func NewCorrelationID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "000000000000"
+	}
+	return hex.EncodeToString(b)
+}
+
+// InheritedCorrelationID returns the correlation ID passed down by the
+// process that started this one, or a new one
+func InheritedCorrelationID() string {
+	if id := os.Getenv(CorrelationEnvVar); id != "" {
+		return id
+	}
+	return NewCorrelationID()
+}
+
+// WithCorrelationID returns a context carrying id
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, empty without one
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// SetCorrelationID tags the errors, warnings and messages of the logger with
+// id, an empty id removes the tag
+func (l *Logger) SetCorrelationID(id string) {
+	l.correlationID = id
+}
+
+// tagged prefixes format with the correlation ID, if any
+func (l *Logger) tagged(format string) string {
+	if l.correlationID == "" {
+		return format
+	}
+	return "[" + strings.ReplaceAll(l.correlationID, "%", "%%") + "] " + format
+}
+
+// SetCorrelationID tags the output of the default logger with id
+func SetCorrelationID(id string) {
+	DefaultLogger.SetCorrelationID(id)
+}
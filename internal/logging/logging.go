@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"interop/internal/termtext"
 	"os"
 	"strings"
 )
@@ -29,13 +30,23 @@ const (
 
 // Logger handles log operations with level filtering
 type Logger struct {
-	level     Level
-	useColors bool
+	level         Level
+	useColors     bool
+	correlationID string // Tags errors, warnings and messages, see SetCorrelationID
 }
 
 // DefaultLogger is used by global logging functions
 var DefaultLogger = NewLogger(LevelError)
 
+// exitHooks run before ErrorAndExit terminates the program
+var exitHooks []func()
+
+// OnExit registers a function to run before ErrorAndExit terminates the
+// program, e.g. to flush buffered telemetry
+func OnExit(fn func()) {
+	exitHooks = append(exitHooks, fn)
+}
+
 // NewLogger creates a new logger with the specified log level
 func NewLogger(level Level) *Logger {
 	return &Logger{
@@ -78,38 +89,44 @@ func (l *Logger) EnableColors() {
 
 // Error prints a red "Error: …" message to stderr
 func (l *Logger) Error(format string, args ...interface{}) {
+	format = l.tagged(format)
 	// Error messages are always printed regardless of log level
 	if l.useColors {
 		fmt.Fprintf(os.Stderr, colorRed+"Error: "+colorReset+format+"\n", args...)
 	} else {
-		fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", plain(format, args...))
 	}
 }
 
 // ErrorAndExit prints an error message and exits the program with status code 1
 func (l *Logger) ErrorAndExit(format string, args ...interface{}) {
 	l.Error(format, args...)
+	for _, fn := range exitHooks {
+		fn()
+	}
 	os.Exit(1)
 }
 
 // Warning prints a yellow "Warning: …" message to stderr if log level permits
 func (l *Logger) Warning(format string, args ...interface{}) {
+	format = l.tagged(format)
 	if l.level >= LevelWarning {
 		if l.useColors {
 			fmt.Fprintf(os.Stderr, colorYellow+"Warning: "+colorReset+format+"\n", args...)
 		} else {
-			fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", plain(format, args...))
 		}
 	}
 }
 
 // Message prints a green "Message: …" message to stderr if log level permits
 func (l *Logger) Message(format string, args ...interface{}) {
+	format = l.tagged(format)
 	if l.level >= LevelVerbose {
 		if l.useColors {
 			fmt.Fprintf(os.Stderr, colorGreen+"Message: "+colorReset+format+"\n", args...)
 		} else {
-			fmt.Fprintf(os.Stderr, "Message: "+format+"\n", args...)
+			fmt.Fprintf(os.Stderr, "Message: %s\n", plain(format, args...))
 		}
 	}
 }
@@ -119,7 +136,7 @@ func (l *Logger) Info(format string, args ...interface{}) {
 	if l.useColors {
 		fmt.Fprintf(os.Stdout, colorBlue+"Info: "+colorReset+format+"\n", args...)
 	} else {
-		fmt.Fprintf(os.Stdout, "Info: "+format+"\n", args...)
+		fmt.Fprintf(os.Stdout, "Info: %s\n", plain(format, args...))
 	}
 }
 
@@ -128,6 +145,12 @@ func (l *Logger) Infof(format string, args ...interface{}) {
 	l.Info(format, args...)
 }
 
+// plain formats a message for output without colors, removing the escape
+// sequences its arguments may carry, e.g. from command output
+func plain(format string, args ...interface{}) string {
+	return termtext.Strip(fmt.Sprintf(format, args...))
+}
+
 // Global functions that use the default logger
 
 // SetDefaultLevel updates the log level of the default logger
@@ -146,6 +146,18 @@ func TestLoggerError(t *testing.T) {
 	}
 }
 
+func TestLoggerWithoutColorsStripsEscapes(t *testing.T) {
+	logger := NewLogger(LevelError)
+	logger.DisableColors()
+	output := captureStderr(func() {
+		logger.Error("command failed: %s", "\x1b[31mred\x1b[0m\x1b]0;title\x07")
+	})
+
+	if output != "Error: command failed: red\n" {
+		t.Errorf("Expected escape sequences to be removed, got %q", output)
+	}
+}
+
 func TestDefaultLoggerFunctions(t *testing.T) {
 	// Test SetDefaultLevel
 	originalLevel := DefaultLogger.level
@@ -204,3 +216,25 @@ func levelToString(level Level) string {
 		return "Unknown"
 	}
 }
+
+func TestLoggerCorrelationID(t *testing.T) {
+	logger := NewLogger(LevelVerbose)
+	logger.DisableColors()
+	logger.SetCorrelationID("3f9a2b")
+	output := captureStderr(func() {
+		logger.Warning("disk at %d%%", 90)
+		logger.Error("failed")
+	})
+	if output != "Warning: [3f9a2b] disk at 90%\nError: [3f9a2b] failed\n" {
+		t.Errorf("Expected tagged lines, got %q", output)
+	}
+
+	t.Setenv(CorrelationEnvVar, "parent")
+	if id := InheritedCorrelationID(); id != "parent" {
+		t.Errorf("InheritedCorrelationID() = %q, want the ID of the parent", id)
+	}
+	t.Setenv(CorrelationEnvVar, "")
+	if id := InheritedCorrelationID(); len(id) != 12 {
+		t.Errorf("InheritedCorrelationID() = %q, want a new 12 character ID", id)
+	}
+}
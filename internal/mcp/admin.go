@@ -0,0 +1,192 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"interop/internal/remote"
+	"interop/internal/settings"
+	"interop/internal/validation"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// adminProject is the list_projects representation of a project
+type adminProject struct {
+	Name        string       `json:"name"`
+	Path        string       `json:"path"`
+	Description string       `json:"description,omitempty"`
+	Commands    []adminAlias `json:"commands,omitempty"`
+}
+
+// adminAlias is a command a project makes available, under an optional alias
+type adminAlias struct {
+	Command string `json:"command"`
+	Alias   string `json:"alias,omitempty"`
+}
+
+// registerAdminTools registers the tools managing interop itself. They are
+// only served by servers marked admin, which never serve commands.
+func (s *MCPLibServer) registerAdminTools() {
+	listProjectsTool := mcp.NewTool(
+		"list_projects",
+		mcp.WithDescription("List the configured projects with their paths and commands"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	s.mcpServer.AddTool(listProjectsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cfg, err := settings.Reload()
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to load settings: %v", err)), nil
+		}
+
+		projects := make([]adminProject, 0, len(cfg.Projects))
+		for name, project := range cfg.Projects {
+			entry := adminProject{Name: name, Path: project.Path, Description: project.Description}
+			for _, alias := range project.Commands {
+				entry.Commands = append(entry.Commands, adminAlias{Command: alias.CommandName, Alias: alias.Alias})
+			}
+			projects = append(projects, entry)
+		}
+		sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+
+		projectsJSON, _ := json.MarshalIndent(projects, "", "  ")
+		return mcp.NewToolResultText(formatToolOutput(string(projectsJSON), s.isToolOutputJson)), nil
+	})
+
+	validateConfigTool := mcp.NewTool(
+		"validate_config",
+		mcp.WithDescription("Validate the interop configuration and report errors and warnings"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	s.mcpServer.AddTool(validateConfigTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Files that fail to parse are reported along with the issues of the
+		// settings loaded from the others
+		cfg, err := settings.Reload()
+		issues := append(validation.LoadErrors(err), validation.ValidateAll(cfg)...)
+		if len(issues) == 0 {
+			return mcp.NewToolResultText(formatToolOutput("Configuration is valid", s.isToolOutputJson)), nil
+		}
+
+		var output strings.Builder
+		for _, issue := range issues {
+			severity := "Warning"
+			if issue.Severe {
+				severity = "Error"
+			}
+			fmt.Fprintf(&output, "[%s] %s\n", severity, issue.Message)
+		}
+		return mcp.NewToolResultText(formatToolOutput(output.String(), s.isToolOutputJson)), nil
+	})
+
+	fetchRemoteTool := mcp.NewTool(
+		"fetch_remote",
+		mcp.WithDescription("Fetch configuration from one or all remote repositories"),
+		mcp.WithString("remote", mcp.Description("Name of the remote to fetch, all remotes when empty")),
+		mcp.WithString("strategy", mcp.Description("How to resolve commands conflicting with local ones"), mcp.Enum("local", "remote", "rename")),
+		mcp.WithReadOnlyHintAnnotation(false),
+	)
+	s.mcpServer.AddTool(fetchRemoteTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		remoteName, _ := args["remote"].(string)
+		strategyValue, _ := args["strategy"].(string)
+
+		// Without a strategy, conflicts keep the local command since nobody can
+		// answer prompts
+		strategy, err := remote.ParseConflictStrategy(strategyValue)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := remote.NewManager().Fetch(remoteName, strategy); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to fetch: %v", err)), nil
+		}
+
+		result := "Fetched all remotes"
+		if remoteName != "" {
+			result = fmt.Sprintf("Fetched remote '%s'", remoteName)
+		}
+		return mcp.NewToolResultText(formatToolOutput(result+". Use reload_servers to serve the updated commands.", s.isToolOutputJson)), nil
+	})
+
+	enableCommandTool := mcp.NewTool(
+		"enable_command",
+		mcp.WithDescription("Enable or disable a locally defined command"),
+		mcp.WithString("name", mcp.Description("Name of the command"), mcp.Required()),
+		mcp.WithBoolean("enabled", mcp.Description("Whether the command is enabled (default: true)")),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+	)
+	s.mcpServer.AddTool(enableCommandTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		name, _ := args["name"].(string)
+		enabled := true
+		if value, ok := args["enabled"].(bool); ok {
+			enabled = value
+		}
+
+		path, err := settings.SetCommandEnabled(name, enabled)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		state := "enabled"
+		if !enabled {
+			state = "disabled"
+		}
+		result := fmt.Sprintf("Command '%s' %s in %s. Use reload_servers to apply the change to running servers.", name, state, path)
+		return mcp.NewToolResultText(formatToolOutput(result, s.isToolOutputJson)), nil
+	})
+
+	reloadServersTool := mcp.NewTool(
+		"reload_servers",
+		mcp.WithDescription("Restart running command MCP servers so they serve the current configuration"),
+		mcp.WithString("server", mcp.Description("Name of the server to restart (\"default\" for the default server), all running servers when empty")),
+		mcp.WithReadOnlyHintAnnotation(false),
+	)
+	s.mcpServer.AddTool(reloadServersTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		serverName, _ := args["server"].(string)
+
+		cfg, err := settings.Reload()
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to load settings: %v", err)), nil
+		}
+		manager, err := NewServerManager()
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if serverName != "" {
+			if _, exists := manager.Servers[serverName]; !exists {
+				return mcp.NewToolResultError(fmt.Sprintf("MCP server '%s' not found", serverName)), nil
+			}
+		}
+
+		var keys []string
+		for key := range manager.Servers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var output strings.Builder
+		for _, key := range keys {
+			server := manager.Servers[key]
+			// Admin servers are never restarted, that would end this call
+			if (serverName != "" && key != serverName) || cfg.MCPServers[server.Name].Admin || !server.IsRunning() {
+				continue
+			}
+			if err := server.Restart(); err != nil {
+				fmt.Fprintf(&output, "Failed to restart '%s': %v\n", key, err)
+				continue
+			}
+			fmt.Fprintf(&output, "Restarted '%s'\n", key)
+		}
+		if output.Len() == 0 {
+			output.WriteString("No running command servers to restart")
+		}
+		return mcp.NewToolResultText(formatToolOutput(output.String(), s.isToolOutputJson)), nil
+	})
+
+	s.logInfo("Registered MCP admin tools")
+}
@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"interop/internal/settings"
+	"sort"
+	"strings"
+)
+
+// maxGotLength is how much of a rejected value an ArgumentError repeats
+const maxGotLength = 100
+
+// ArgumentError reports a tool call argument that doesn't fit the tool's
+// schema. Tools return it to clients as a JSON tool error.
+type ArgumentError struct {
+	Argument string `json:"argument"`      // Name of the argument, empty for the arguments as a whole
+	Expected string `json:"expected"`      // JSON type the argument takes, like "number"
+	Got      string `json:"got,omitempty"` // JSON of the value given, shortened, empty when missing
+	Message  string `json:"message"`
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Message
+}
+
+// rejectArgument returns an ArgumentError for value given to the argument
+// name, which takes the JSON type expected, described as want in the message
+func rejectArgument(name, expected, want string, value interface{}) *ArgumentError {
+	got := gotValue(value)
+	label := fmt.Sprintf("argument '%s'", name)
+	if name == "" {
+		label = "arguments"
+	}
+	return &ArgumentError{
+		Argument: name,
+		Expected: expected,
+		Got:      got,
+		Message:  fmt.Sprintf("%s must be %s, got %s %s", label, want, jsonKind(value), got),
+	}
+}
+
+// gotValue returns the JSON of value, shortened to maxGotLength
+func gotValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if len(data) > maxGotLength {
+		return string(data[:maxGotLength]) + "..."
+	}
+	return string(data)
+}
+
+// jsonKind names the JSON type of a decoded value, with its article
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
+
+// isScalar reports whether value is a string, number or boolean
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, float64, int, int64:
+		return true
+	}
+	return false
+}
+
+// argumentsObject returns the arguments of a tool call, which must be an
+// object or absent
+func argumentsObject(raw interface{}) (map[string]interface{}, error) {
+	switch v := raw.(type) {
+	case nil:
+		return make(map[string]interface{}), nil
+	case map[string]interface{}:
+		return v, nil
+	}
+	return nil, rejectArgument("", "object", "an object", raw)
+}
+
+// decodeToolArguments checks the arguments of a call to the tool of cmdConfig
+// against the tool's schema and returns them without null values, which count
+// as not given. Besides the command's arguments, the tool takes project_path
+// when the command is global, no_cache when it's cached, timeout unless an
+// argument has that name, and the args object when it declares no arguments.
+// Values of declared arguments must fit their types; the values of args and
+// of the arguments of string type must be strings, numbers or booleans.
+// Unknown arguments are rejected.
+func decodeToolArguments(args map[string]interface{}, cmdConfig settings.CommandConfig, global bool) (map[string]interface{}, error) {
+	declared := make(map[string]settings.CommandArgument, len(cmdConfig.Arguments))
+	for _, arg := range cmdConfig.Arguments {
+		declared[arg.Name] = arg
+	}
+	parameters := make(map[string]string) // The tool's other parameters, with their JSON types
+	if global {
+		parameters["project_path"] = "string"
+	}
+	if _, cached, _ := cmdConfig.CacheDuration(); cached {
+		parameters["no_cache"] = "boolean"
+	}
+	if hasTimeoutParameter(cmdConfig) {
+		parameters["timeout"] = "string"
+	}
+	if len(cmdConfig.Arguments) == 0 {
+		parameters["args"] = "object"
+	}
+
+	decoded := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		if value == nil {
+			continue
+		}
+		if arg, ok := declared[name]; ok {
+			if err := checkArgumentValue(arg, value); err != nil {
+				return nil, err
+			}
+			decoded[name] = value
+			continue
+		}
+
+		switch parameters[name] {
+		case "string":
+			// Timeouts can also be given in seconds
+			if _, ok := value.(string); !ok && !(name == "timeout" && isNumber(value)) {
+				return nil, rejectArgument(name, "string", "a string", value)
+			}
+		case "boolean":
+			if _, ok := value.(bool); !ok {
+				return nil, rejectArgument(name, "boolean", "true or false", value)
+			}
+		case "object":
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, rejectArgument(name, "object", "an object", value)
+			}
+			for key, field := range object {
+				if field != nil && !isScalar(field) {
+					return nil, rejectArgument(name+"."+key, "string", "a string, number or boolean", field)
+				}
+			}
+		default:
+			return nil, unknownArgument(name, declared, parameters)
+		}
+		decoded[name] = value
+	}
+
+	for _, arg := range cmdConfig.Arguments {
+		if _, given := decoded[arg.Name]; !given && arg.Required && arg.Default == nil {
+			return nil, &ArgumentError{
+				Argument: arg.Name,
+				Expected: argumentJSONType(arg),
+				Message:  fmt.Sprintf("required argument '%s' is missing", arg.Name),
+			}
+		}
+	}
+	return decoded, nil
+}
+
+// checkArgumentValue checks that value fits the type of the declared argument
+func checkArgumentValue(arg settings.CommandArgument, value interface{}) error {
+	expected := argumentJSONType(arg)
+	if !isScalar(value) {
+		want := "a string"
+		switch expected {
+		case "number":
+			want = "a number"
+		case "boolean":
+			want = "true or false"
+		}
+		return rejectArgument(arg.Name, expected, want, value)
+	}
+	if _, err := arg.FormatValue(value); err != nil {
+		return &ArgumentError{Argument: arg.Name, Expected: expected, Got: gotValue(value), Message: err.Error()}
+	}
+	return nil
+}
+
+// argumentJSONType returns the JSON type of the values of a declared argument
+func argumentJSONType(arg settings.CommandArgument) string {
+	switch arg.Type {
+	case settings.ArgumentTypeNumber:
+		return "number"
+	case settings.ArgumentTypeBool:
+		return "boolean"
+	}
+	return "string"
+}
+
+// isNumber reports whether value is a decoded JSON number
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case float64, int, int64:
+		return true
+	}
+	return false
+}
+
+// unknownArgument returns the error of an argument the tool doesn't take,
+// listing the ones it does
+func unknownArgument(name string, declared map[string]settings.CommandArgument, parameters map[string]string) *ArgumentError {
+	names := make([]string, 0, len(declared)+len(parameters))
+	for known := range declared {
+		names = append(names, known)
+	}
+	for known := range parameters {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return &ArgumentError{
+		Argument: name,
+		Message:  fmt.Sprintf("unknown argument '%s', the tool takes %s", name, strings.Join(names, ", ")),
+	}
+}
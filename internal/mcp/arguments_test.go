@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"testing"
+)
+
+// deployCommand declares an argument of each type and caches its results
+var deployCommand = settings.CommandConfig{
+	IsEnabled: true,
+	Cache:     true,
+	Arguments: []settings.CommandArgument{
+		{Name: "env", Type: settings.ArgumentTypeString, Required: true},
+		{Name: "replicas", Type: settings.ArgumentTypeNumber},
+		{Name: "dry_run", Type: settings.ArgumentTypeBool},
+	},
+}
+
+func TestDecodeToolArguments(t *testing.T) {
+	legacy := settings.CommandConfig{IsEnabled: true}
+
+	tests := []struct {
+		name     string
+		cmd      settings.CommandConfig
+		args     string
+		argument string // Argument of the expected error, "-" for none
+		expected string
+		message  string
+	}{
+		{"valid", deployCommand, `{"env": "prod", "replicas": 3, "dry_run": "true", "no_cache": true, "project_path": "/tmp"}`, "-", "", ""},
+		{"null counts as not given", deployCommand, `{"env": "prod", "replicas": null}`, "-", "", ""},
+		{"object for a string", deployCommand, `{"env": {"name": "prod"}}`, "env", "string", `argument 'env' must be a string, got an object {"name":"prod"}`},
+		{"array for a number", deployCommand, `{"env": "prod", "replicas": [1, 2]}`, "replicas", "number", "argument 'replicas' must be a number, got an array [1,2]"},
+		{"text for a number", deployCommand, `{"env": "prod", "replicas": "many"}`, "replicas", "number", "argument 'replicas' must be a number, got 'many'"},
+		{"missing required", deployCommand, `{"replicas": 2}`, "env", "string", "required argument 'env' is missing"},
+		{"null required", deployCommand, `{"env": null}`, "env", "string", "required argument 'env' is missing"},
+		{"unknown", deployCommand, `{"env": "prod", "region": "eu"}`, "region", "", "unknown argument 'region', the tool takes dry_run, env, no_cache, project_path, replicas, timeout"},
+		{"no_cache as text", deployCommand, `{"env": "prod", "no_cache": "yes"}`, "no_cache", "boolean", `argument 'no_cache' must be true or false, got a string "yes"`},
+		{"project_path as number", deployCommand, `{"env": "prod", "project_path": 7}`, "project_path", "string", "argument 'project_path' must be a string, got a number 7"},
+		{"timeout in seconds", legacy, `{"timeout": 30}`, "-", "", ""},
+		{"args not an object", legacy, `{"args": "--fix"}`, "args", "object", `argument 'args' must be an object, got a string "--fix"`},
+		{"nested value in args", legacy, `{"args": {"files": ["a", "b"]}}`, "args.files", "string", `argument 'args.files' must be a string, number or boolean, got an array ["a","b"]`},
+		{"project_path of a bound command", legacy, `{"project_path": "/tmp"}`, "project_path", "", "unknown argument 'project_path', the tool takes args, timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+				t.Fatal(err)
+			}
+			global := tt.name != "project_path of a bound command"
+			decoded, err := decodeToolArguments(args, tt.cmd, global)
+			if tt.argument == "-" {
+				if err != nil {
+					t.Fatalf("decodeToolArguments() error = %v", err)
+				}
+				for name, value := range decoded {
+					if value == nil {
+						t.Errorf("decodeToolArguments() kept null argument %s", name)
+					}
+				}
+				return
+			}
+			var argErr *ArgumentError
+			if !errors.As(err, &argErr) {
+				t.Fatalf("decodeToolArguments() error = %v, want an ArgumentError", err)
+			}
+			if argErr.Argument != tt.argument || argErr.Expected != tt.expected || argErr.Message != tt.message {
+				t.Errorf("decodeToolArguments() error = %+v, want %s (%s): %s", argErr, tt.argument, tt.expected, tt.message)
+			}
+		})
+	}
+
+	if _, err := argumentsObject([]interface{}{"prod"}); err == nil || err.Error() != `arguments must be an object, got an array ["prod"]` {
+		t.Errorf("argumentsObject() error = %v", err)
+	}
+}
+
+func TestToolArgumentErrors(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.deploy]
+cmd = 'echo "deploying ${env}"'
+arguments = [{ name = "env", type = "string", required = true }]
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	result, err := serverCaller{s}.CallTool(context.Background(), "deploy", map[string]interface{}{"env": []interface{}{"prod"}})
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	var argErr ArgumentError
+	if !result.IsError || json.Unmarshal([]byte(resultText(result)), &argErr) != nil {
+		t.Fatalf("Expected a JSON tool error, got %s", resultText(result))
+	}
+	if argErr.Argument != "env" || argErr.Expected != "string" || argErr.Got != `["prod"]` {
+		t.Errorf("Unexpected argument error %+v", argErr)
+	}
+}
+
+// FuzzDecodeToolArguments checks that any JSON arguments are either decoded
+// into values the tool takes or rejected with an ArgumentError
+func FuzzDecodeToolArguments(f *testing.F) {
+	for _, seed := range []string{
+		`{"env": "prod", "replicas": 3, "dry_run": true}`,
+		`{"env": {"a": [1, {"b": null}]}, "replicas": "1e3"}`,
+		`{"args": {"x": 1, "y": [true]}, "timeout": "90s"}`,
+		`{"project_path": null, "no_cache": "false", "": ""}`,
+		`[1, 2]`,
+		`"text"`,
+		`null`,
+	} {
+		f.Add(seed)
+	}
+
+	legacy := settings.CommandConfig{IsEnabled: true}
+	f.Fuzz(func(t *testing.T, data string) {
+		var raw interface{}
+		if json.Unmarshal([]byte(data), &raw) != nil {
+			return
+		}
+		for _, cmd := range []settings.CommandConfig{deployCommand, legacy} {
+			args, err := argumentsObject(raw)
+			if err == nil {
+				var decoded map[string]interface{}
+				if decoded, err = decodeToolArguments(args, cmd, true); err == nil {
+					checkDecoded(t, cmd, decoded)
+					continue
+				}
+			}
+			var argErr *ArgumentError
+			if !errors.As(err, &argErr) || argErr.Message == "" {
+				t.Fatalf("Expected an ArgumentError for %s, got %v", data, err)
+			}
+		}
+	})
+}
+
+// checkDecoded checks that the decoded arguments of the tool of cmd have the
+// types its schema gives them
+func checkDecoded(t *testing.T, cmd settings.CommandConfig, decoded map[string]interface{}) {
+	t.Helper()
+	known := map[string]bool{"project_path": true, "no_cache": true, "timeout": true, "args": true}
+	for _, arg := range cmd.Arguments {
+		known[arg.Name] = true
+	}
+	for name, value := range decoded {
+		switch {
+		case !known[name]:
+			t.Fatalf("Unknown argument %s was kept", name)
+		case value == nil:
+			t.Fatalf("Null argument %s was kept", name)
+		case name == "args":
+			for key, field := range value.(map[string]interface{}) {
+				if field != nil && !isScalar(field) {
+					t.Fatalf("args.%s = %v is not a scalar", key, field)
+				}
+			}
+		case !isScalar(value):
+			t.Fatalf("Argument %s = %v is not a scalar", name, value)
+		}
+	}
+	for _, arg := range cmd.Arguments {
+		if value, ok := decoded[arg.Name]; ok {
+			if _, err := arg.FormatValue(value); err != nil {
+				t.Fatalf("Argument %s = %v doesn't fit its type: %v", arg.Name, value, err)
+			}
+		} else if arg.Required {
+			t.Fatalf("Required argument %s is missing", arg.Name)
+		}
+	}
+}
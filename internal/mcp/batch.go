@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxBatchItems bounds the number of commands one run-batch call can run
+	maxBatchItems = 20
+	// maxBatchParallel bounds how many commands run at once in parallel mode
+	maxBatchParallel = 4
+)
+
+// batchItem is one command of a run-batch call
+type batchItem struct {
+	Command string                 `json:"command"`
+	Args    map[string]interface{} `json:"args,omitempty"`
+}
+
+// batchResult is the outcome of one batch item
+type batchResult struct {
+	Command    string `json:"command"`
+	Status     string `json:"status"` // ok, failed or skipped
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// registerBatchTool registers the run-batch tool running several of this
+// server's command tools in one call
+func (s *MCPLibServer) registerBatchTool() {
+	batchTool := mcp.NewTool(
+		"run-batch",
+		mcp.WithDescription("Run several command tools of this server in one call and return a result per command"),
+		mcp.WithArray("items",
+			mcp.Description(fmt.Sprintf("Commands to run, at most %d. args takes the same arguments as calling the command's tool directly.", maxBatchItems)),
+			mcp.Required(),
+			mcp.Items(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"command": map[string]interface{}{"type": "string", "description": "Name of the command tool"},
+					"args":    map[string]interface{}{"type": "object", "description": "Arguments for the command tool"},
+				},
+				"required": []string{"command"},
+			}),
+		),
+		mcp.WithString("mode", mcp.Description("Run the commands one after another (default) or at the same time"), mcp.Enum("sequential", "parallel")),
+		mcp.WithBoolean("stop_on_error", mcp.Description("In sequential mode, skip the remaining commands after a failure")),
+	)
+
+	s.mcpServer.AddTool(batchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// The items of a batch share the correlation ID of the call
+		ctx = correlate(ctx)
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		mode, _ := args["mode"].(string)
+		stopOnError, _ := args["stop_on_error"].(bool)
+
+		var items []batchItem
+		raw, _ := json.Marshal(args["items"])
+		if err := json.Unmarshal(raw, &items); err != nil || len(items) == 0 {
+			return mcp.NewToolResultError("items must be a non-empty array of {command, args} objects"), nil
+		}
+		if len(items) > maxBatchItems {
+			return mcp.NewToolResultError(fmt.Sprintf("A batch can run at most %d commands", maxBatchItems)), nil
+		}
+		for _, item := range items {
+			if _, ok := s.toolCommands[item.Command]; !ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Unknown command tool '%s'", item.Command)), nil
+			}
+		}
+
+		results := make([]batchResult, len(items))
+		switch mode {
+		case "", "sequential":
+			failed := false
+			for i, item := range items {
+				if failed && stopOnError {
+					results[i] = batchResult{Command: item.Command, Status: "skipped"}
+					continue
+				}
+				results[i] = s.runBatchItem(ctx, item)
+				failed = failed || results[i].Status == "failed"
+			}
+		case "parallel":
+			var wg sync.WaitGroup
+			slots := make(chan struct{}, maxBatchParallel)
+			for i, item := range items {
+				wg.Add(1)
+				go func(i int, item batchItem) {
+					defer wg.Done()
+					slots <- struct{}{}
+					defer func() { <-slots }()
+					results[i] = s.runBatchItem(ctx, item)
+				}(i, item)
+			}
+			wg.Wait()
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid mode '%s', must be either 'sequential' or 'parallel'", mode)), nil
+		}
+
+		resultsJSON, _ := json.MarshalIndent(results, "", "  ")
+		return withCorrelationID(ctx, mcp.NewToolResultText(formatToolOutput(string(resultsJSON), s.isToolOutputJson))), nil
+	})
+
+	s.logInfo("Registered MCP batch tool")
+}
+
+// runBatchItem runs one batch item like a call of its command tool
+func (s *MCPLibServer) runBatchItem(ctx context.Context, item batchItem) batchResult {
+	args := item.Args
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+
+	started := time.Now()
+	output, err := s.runCommandTool(ctx, item.Command, s.commandConfig[s.toolCommands[item.Command]], args)
+	result := batchResult{
+		Command:    item.Command,
+		Status:     "ok",
+		Output:     sanitizeOutput(output),
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
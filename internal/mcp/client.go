@@ -0,0 +1,282 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"interop/internal/settings"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ClientOptions selects how mcp tools and mcp call reach a server
+type ClientOptions struct {
+	Stdio bool // Spawn the server in stdio mode instead of connecting to it running
+	JSON  bool // Output the MCP data as JSON
+}
+
+// configuredServer returns a configured server, the default one when
+// serverName is empty or "default", and a description of it for messages
+func configuredServer(serverName string) (*Server, string, error) {
+	manager, err := NewServerManager()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to initialize MCP server manager: %v", err)
+	}
+
+	serverDesc := "MCP server"
+	if serverName == "" || serverName == "default" {
+		serverName = "default"
+	} else {
+		serverDesc = fmt.Sprintf("MCP server '%s'", serverName)
+	}
+	server, exists := manager.Servers[serverName]
+	if !exists {
+		return nil, "", fmt.Errorf("MCP server '%s' not found", serverName)
+	}
+	return server, serverDesc, nil
+}
+
+// runningServer is configuredServer for a server that must be running
+func runningServer(serverName string) (*Server, string, error) {
+	server, serverDesc, err := configuredServer(serverName)
+	if err != nil {
+		return nil, "", err
+	}
+	if !server.IsRunning() {
+		return nil, "", fmt.Errorf("%s is not running", serverDesc)
+	}
+	return server, serverDesc, nil
+}
+
+// serverClient returns a client for a running server, or for a server it
+// spawns in stdio mode
+func serverClient(serverName string, stdio bool) (*ToolsClient, string, error) {
+	if !stdio {
+		server, serverDesc, err := runningServer(serverName)
+		if err != nil {
+			return nil, "", err
+		}
+		return NewServerToolsClient(server.Port), serverDesc, nil
+	}
+
+	server, serverDesc, err := configuredServer(serverName)
+	if err != nil {
+		return nil, "", err
+	}
+	return NewStdioToolsClient(server.Name), serverDesc, nil
+}
+
+// ListServerTools lists the tools an MCP server serves, as it reports them
+// over MCP
+func ListServerTools(serverName string, opts ClientOptions) (string, error) {
+	client, _, err := serverClient(serverName, opts.Stdio)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	if opts.JSON {
+		output, err := json.MarshalIndent(tools, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode tools: %w", err)
+		}
+		return string(output), nil
+	}
+
+	var b strings.Builder
+	for _, tool := range tools {
+		fmt.Fprintf(&b, "%s\n", tool.Name)
+		if tool.Description != "" {
+			fmt.Fprintf(&b, "  %s\n", tool.Description)
+		}
+
+		names := make([]string, 0, len(tool.InputSchema.Properties))
+		for name := range tool.InputSchema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, _ := tool.InputSchema.Properties[name].(map[string]interface{})
+			propertyType, _ := property["type"].(string)
+			description, _ := property["description"].(string)
+			required := ""
+			for _, requiredName := range tool.InputSchema.Required {
+				if requiredName == name {
+					required = ", required"
+				}
+			}
+			fmt.Fprintf(&b, "  - %s (%s%s): %s\n", name, propertyType, required, description)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// CallServerTool calls a tool on an MCP server with arguments given like to
+// interop run, and returns its output and whether the tool reported an error
+func CallServerTool(serverName, toolName string, values []string, opts ClientOptions) (string, bool, error) {
+	client, serverDesc, err := serverClient(serverName, opts.Stdio)
+	if err != nil {
+		return "", false, err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+
+	// The tool's schema tells how to convert argument values
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	var tool *mcp.Tool
+	for i := range tools {
+		if tools[i].Name == toolName {
+			tool = &tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return "", false, fmt.Errorf("%s has no tool '%s'", serverDesc, toolName)
+	}
+
+	args, err := toolArguments(*tool, positionalArguments(*tool), values)
+	if err != nil {
+		return "", false, err
+	}
+	result, err := client.CallTool(ctx, toolName, args)
+	if err != nil {
+		return "", false, err
+	}
+
+	if opts.JSON {
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return "", result.IsError, fmt.Errorf("failed to encode result: %w", err)
+		}
+		return string(output), result.IsError, nil
+	}
+
+	return resultText(result), result.IsError, nil
+}
+
+// resultText returns the text of a tool result, with content other than text
+// as JSON
+func resultText(result *mcp.CallToolResult) string {
+	parts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+			continue
+		}
+		encoded, _ := json.Marshal(content)
+		parts = append(parts, string(encoded))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// toolArguments converts command line values to tool arguments, typed by the
+// tool's input schema. Like interop run, name=value sets an argument and other
+// values fill the positional arguments in order. Values of arguments the schema
+// doesn't declare are read as JSON when valid, and as strings otherwise.
+func toolArguments(tool mcp.Tool, positional []string, values []string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	next := 0
+	for _, arg := range values {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			if next >= len(positional) {
+				return nil, fmt.Errorf("unexpected positional argument '%s', use name=value", arg)
+			}
+			name, value = positional[next], arg
+			next++
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid argument '%s', expected name=value", arg)
+		}
+
+		property, _ := tool.InputSchema.Properties[name].(map[string]interface{})
+		propertyType, _ := property["type"].(string)
+		parsed, err := parseToolArgument(propertyType, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for argument '%s': %v", name, err)
+		}
+		args[name] = parsed
+	}
+	return args, nil
+}
+
+// positionalArguments returns the arguments positional values fill: the
+// arguments without a prefix of the command behind the tool, in the order they
+// are configured, or the tool's required arguments for other tools
+func positionalArguments(tool mcp.Tool) []string {
+	var names []string
+	if cfg, err := settings.Load(); err == nil {
+		if cmd, ok := commandForTool(cfg, tool.Name); ok {
+			for _, arg := range cmd.Arguments {
+				if arg.Prefix == "" {
+					names = append(names, arg.Name)
+				}
+			}
+			return names
+		}
+	}
+	return append(names, tool.InputSchema.Required...)
+}
+
+// commandForTool returns the command a tool named after a command or a
+// project alias runs
+func commandForTool(cfg *settings.Settings, toolName string) (settings.CommandConfig, bool) {
+	if cmd, ok := cfg.Commands[toolName]; ok {
+		return cmd, true
+	}
+	for _, project := range cfg.Projects {
+		for _, alias := range project.Commands {
+			if alias.Alias == toolName {
+				cmd, ok := cfg.Commands[alias.CommandName]
+				return cmd, ok
+			}
+		}
+	}
+	return settings.CommandConfig{}, false
+}
+
+// parseToolArgument converts a command line value to the JSON schema type
+func parseToolArgument(schemaType, value string) (interface{}, error) {
+	switch schemaType {
+	case "string":
+		return value, nil
+	case "number", "integer":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", value)
+		}
+		return number, nil
+	case "boolean":
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a boolean", value)
+		}
+		return boolean, nil
+	case "array", "object":
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("expected JSON %s: %v", schemaType, err)
+		}
+		return parsed, nil
+	default:
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+			return parsed, nil
+		}
+		return value, nil
+	}
+}
@@ -1,18 +1,21 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"interop/internal/logging"
 	"interop/internal/settings"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
 
-// StartServer starts the MCP server daemon with support for multiple servers
-func StartServer(serverName string, all bool) error {
+// StartServer starts the MCP server daemon with support for multiple servers.
+// With all, onlyUsed also skips servers without commands or prompts to serve.
+func StartServer(serverName string, all, onlyUsed bool) error {
 	// Check if we're in stdio mode
 	serverMode := os.Getenv("MCP_SERVER_MODE")
 	if serverMode == "stdio" {
@@ -41,15 +44,14 @@ func StartServer(serverName string, all bool) error {
 		return mcpLibServer.Start()
 	}
 
-	// For SSE mode, use the server manager
+	// For SSE mode, use the server manager. Errors are returned for the caller
+	// to report, which decides whether to exit.
 	manager, err := NewServerManager()
 	if err != nil {
-		logging.Error("failed to initialize MCP server manager: %v", err)
-		return err
+		return fmt.Errorf("failed to initialize MCP server manager: %w", err)
 	}
 
-	if err := manager.StartServer(serverName, all); err != nil {
-		logging.ErrorAndExit("failed to start MCP server: %v", err)
+	if err := manager.StartServer(serverName, all, onlyUsed); err != nil {
 		return err
 	}
 
@@ -86,12 +88,10 @@ func StopServer(serverName string, all bool) error {
 	// For SSE mode, use the server manager
 	manager, err := NewServerManager()
 	if err != nil {
-		logging.Error("failed to initialize MCP server manager: %v", err)
-		return err
+		return fmt.Errorf("failed to initialize MCP server manager: %w", err)
 	}
 
 	if err := manager.StopServer(serverName, all); err != nil {
-		logging.ErrorAndExit("failed to stop MCP server: %v", err)
 		return err
 	}
 
@@ -134,12 +134,10 @@ func RestartServer(serverName string, all bool) error {
 	// For SSE mode, use the server manager
 	manager, err := NewServerManager()
 	if err != nil {
-		logging.Error("failed to initialize MCP server manager: %v", err)
-		return err
+		return fmt.Errorf("failed to initialize MCP server manager: %w", err)
 	}
 
 	if err := manager.RestartServer(serverName, all); err != nil {
-		logging.ErrorAndExit("failed to restart MCP server: %v", err)
 		return err
 	}
 
@@ -165,6 +163,28 @@ func GetStatus(serverName string, all bool) (string, error) {
 	return manager.GetStatus(serverName, all), nil
 }
 
+// GetStatuses returns the status of the named MCP server, or of all servers
+// when serverName is empty
+func GetStatuses(serverName string) ([]ServerStatus, error) {
+	manager, err := NewServerManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MCP server manager: %v", err)
+	}
+
+	return manager.Statuses(serverName)
+}
+
+// ReconcileProcesses reconciles the running MCP daemons with the servers'
+// PID files, terminating orphans when killOrphans is set
+func ReconcileProcesses(killOrphans bool) ([]ProcessEntry, error) {
+	manager, err := NewServerManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MCP server manager: %v", err)
+	}
+
+	return manager.Reconcile(killOrphans)
+}
+
 // ListMCPServers lists all configured MCP servers
 func ListMCPServers() (string, error) {
 	manager, err := NewServerManager()
@@ -175,6 +195,17 @@ func ListMCPServers() (string, error) {
 	return manager.ListMCPServers(), nil
 }
 
+// ListMCPServerListings returns the configured MCP servers with what they
+// serve, see ServerManager.Listings
+func ListMCPServerListings() ([]ServerListing, error) {
+	manager, err := NewServerManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MCP server manager: %v", err)
+	}
+
+	return manager.Listings()
+}
+
 // ExportMCPConfig exports the MCP configuration as JSON
 func ExportMCPConfig() (string, error) {
 	manager, err := NewServerManager()
@@ -196,111 +227,90 @@ func ExportMCPConfigWithMode(mode string) (string, error) {
 }
 
 // StreamServerEvents subscribes to and displays events from the MCP server
-func StreamServerEvents(serverName string) error {
-	// Get server info to check if it's running
-	manager, err := NewServerManager()
+func StreamServerEvents(serverName string, opts EventStreamOptions) error {
+	server, serverDesc, err := runningServer(serverName)
 	if err != nil {
-		err = fmt.Errorf("failed to initialize MCP server manager: %v", err)
 		logging.Error("%v", err)
 		return err
 	}
 
-	var server *Server
-	if serverName == "" {
-		// Use default server
-		server = manager.Servers["default"]
-	} else {
-		// Use named server
-		var exists bool
-		server, exists = manager.Servers[serverName]
-		if !exists {
-			err = fmt.Errorf("MCP server '%s' not found", serverName)
-			logging.Error("%v", err)
-			return err
-		}
-	}
+	// Status goes to stderr so JSON output can be piped
+	fmt.Fprintf(os.Stderr, "Starting event stream from %s. Press Ctrl+C to exit.\n", serverDesc)
 
-	if !server.IsRunning() {
-		serverDesc := "MCP server"
-		if serverName != "" {
-			serverDesc = fmt.Sprintf("MCP server '%s'", serverName)
-		}
-		err = fmt.Errorf("%s is not running", serverDesc)
-		logging.Error("%v", err)
-		return err
-	}
+	// Stop streaming on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	port := server.Port
-	serverDesc := "MCP server"
-	if serverName != "" {
-		serverDesc = fmt.Sprintf("MCP server '%s'", serverName)
+	// Open an MCP session so notifications sent to it are streamed too
+	client := NewServerToolsClient(server.Port)
+	defer client.Close()
+	if opts.Endpoint == "" || "/"+strings.TrimPrefix(opts.Endpoint, "/") == mcpEndpoint {
+		if err := client.Connect(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "MCP handshake failed, streaming without a session: %v\n", err)
+		}
 	}
 
-	fmt.Printf("Starting event stream from %s. Press Ctrl+C to exit.\n", serverDesc)
-	fmt.Println("─────────────────────────────────────────────────────────────")
-
-	// Set up signal handling for graceful exit
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Create done channel
-	doneChan := make(chan struct{})
-	errChan := make(chan error, 1)
-
-	// Start event streaming in a goroutine
-	go func() {
-		client := NewToolsClient()
-		client.SetPort(port) // Use the correct port
-
-		err := client.SubscribeToEvents(func(event string, data string) {
-			// Detect and ignore heartbeat events unless in verbose mode
-			if event == "heartbeat" {
-				fmt.Printf("❤ Heartbeat received at %s\n", time.Now().Format(time.RFC3339))
-				return
-			}
-
-			// Print a divider for each non-heartbeat event
-			fmt.Println("─────────────────────────────────────────────────────────────")
-
-			// For other events, pretty print the JSON
-			fmt.Printf("📌 EVENT: %s\n", event)
+	err = client.SubscribeToEvents(ctx, opts, func(event StreamEvent) {
+		if event.Event == "heartbeat" && opts.QuietHeartbeat {
+			return
+		}
+		if opts.JSON {
+			printEventJSON(event)
+			return
+		}
 
-			// Try to unmarshal and pretty print the data
-			var prettyData interface{}
-			if err := json.Unmarshal([]byte(data), &prettyData); err == nil {
-				// Successfully parsed JSON
-				prettyJSON, _ := json.MarshalIndent(prettyData, "", "  ")
-				fmt.Printf("%s\n", string(prettyJSON))
-			} else {
-				// Not valid JSON, print raw data
-				fmt.Printf("DATA: %s\n", data)
-			}
+		if event.Event == "heartbeat" {
+			fmt.Printf("❤ Heartbeat received at %s\n", time.Now().Format(time.RFC3339))
+			return
+		}
 
-			fmt.Println("─────────────────────────────────────────────────────────────")
-		})
+		// Print a divider for each non-heartbeat event
+		fmt.Println("─────────────────────────────────────────────────────────────")
+		fmt.Printf("📌 EVENT: %s\n", event.Event)
+		if event.ID != "" {
+			fmt.Printf("ID: %s\n", event.ID)
+		}
 
-		if err != nil {
-			errChan <- err
-			return
+		// Try to unmarshal and pretty print the data
+		var prettyData interface{}
+		if err := json.Unmarshal([]byte(event.Data), &prettyData); err == nil {
+			prettyJSON, _ := json.MarshalIndent(prettyData, "", "  ")
+			fmt.Printf("%s\n", string(prettyJSON))
+		} else {
+			// Not valid JSON, print raw data
+			fmt.Printf("DATA: %s\n", event.Data)
 		}
-		close(doneChan)
-	}()
-
-	// Wait for signal, error, or completion
-	select {
-	case <-sigChan:
-		fmt.Println("\nEvent streaming stopped by user.")
-	case err := <-errChan:
+	})
+	if err != nil {
 		logging.Error("Event streaming error: %v", err)
-		fmt.Printf("\nEvent streaming error: %v\n", err)
 		return err
-	case <-doneChan:
-		fmt.Println("\nEvent streaming completed.")
 	}
 
+	fmt.Fprintln(os.Stderr, "\nEvent streaming stopped.")
 	return nil
 }
 
+// printEventJSON prints an event as one JSON line. Data that is valid JSON is
+// embedded as is, anything else as a string.
+func printEventJSON(event StreamEvent) {
+	line := struct {
+		Time  string          `json:"time"`
+		ID    string          `json:"id,omitempty"`
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}{
+		Time:  time.Now().Format(time.RFC3339),
+		ID:    event.ID,
+		Event: event.Event,
+		Data:  json.RawMessage(event.Data),
+	}
+	if !json.Valid(line.Data) {
+		line.Data, _ = json.Marshal(event.Data)
+	}
+	output, _ := json.Marshal(line)
+	fmt.Println(string(output))
+}
+
 // RunHTTPServer runs the MCP HTTP server directly (not as a daemon)
 // This function is called by the daemon subprocess
 func RunHTTPServer() error {
@@ -364,14 +374,18 @@ func CheckPortAvailability() (string, error) {
 	result += "============================\n\n"
 
 	// Check default port
-	result += fmt.Sprintf("Default port %d: ", cfg.MCPPort)
-	if IsPortAvailable(cfg.MCPPort) {
-		result += "Available\n"
+	if !cfg.DefaultMCPServerEnabled() {
+		result += "Default server: disabled\n"
 	} else {
-		result += "In use\n"
-		// Add process info
-		processInfo := GetProcessUsingPort(cfg.MCPPort)
-		result += fmt.Sprintf("Process using this port:\n%s\n", processInfo)
+		result += fmt.Sprintf("Default port %d: ", cfg.MCPPort)
+		if IsPortAvailable(cfg.MCPPort) {
+			result += "Available\n"
+		} else {
+			result += "In use\n"
+			// Add process info
+			processInfo := GetProcessUsingPort(cfg.MCPPort)
+			result += fmt.Sprintf("Process using this port:\n%s\n", processInfo)
+		}
 	}
 
 	// Check configured server ports
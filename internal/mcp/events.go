@@ -0,0 +1,249 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"interop/internal/settings"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultEventRetries is the number of reconnection attempts in a row
+	// before mcp events gives up
+	DefaultEventRetries = 5
+	// DefaultEventBackoff is the delay before the first reconnection attempt
+	DefaultEventBackoff = time.Second
+	// DefaultEventMaxBackoff caps the delay between reconnection attempts
+	DefaultEventMaxBackoff = 30 * time.Second
+)
+
+// defaultSSEEndpoints are tried in order when no endpoint is configured. The
+// streamable HTTP endpoint comes first as it is what interop servers serve.
+var defaultSSEEndpoints = []string{mcpEndpoint, "/events", "/sse"}
+
+// StreamEvent is an event received from a server-sent event stream
+type StreamEvent struct {
+	ID    string // Last event ID of the stream when the event arrived
+	Event string
+	Data  string
+}
+
+// SSEHandler defines a function that handles SSE events
+type SSEHandler func(event StreamEvent)
+
+// EventStreamOptions configures how events are streamed and reconnected
+type EventStreamOptions struct {
+	Endpoint       string        // SSE path, the default endpoints are tried in turn when empty
+	Retries        int           // Failed attempts in a row before giving up, negative for no limit
+	Backoff        time.Duration // Delay before the first retry, doubled after each failure
+	MaxBackoff     time.Duration // Longest delay between retries
+	QuietHeartbeat bool          // Don't print heartbeat events
+	JSON           bool          // Print each event as a JSON line
+	Log            io.Writer     // Receives connection status messages (default: stderr)
+}
+
+// EventStreamOptionsFromConfig returns the options set in the [events]
+// section of the settings, with defaults for the rest
+func EventStreamOptionsFromConfig(cfg settings.EventsConfig) (EventStreamOptions, error) {
+	opts := EventStreamOptions{
+		Endpoint:       cfg.Endpoint,
+		Retries:        DefaultEventRetries,
+		Backoff:        DefaultEventBackoff,
+		MaxBackoff:     DefaultEventMaxBackoff,
+		QuietHeartbeat: cfg.QuietHeartbeat,
+	}
+	if cfg.Retries != 0 {
+		opts.Retries = cfg.Retries
+	}
+
+	var err error
+	if cfg.Backoff != "" {
+		if opts.Backoff, err = time.ParseDuration(cfg.Backoff); err != nil || opts.Backoff <= 0 {
+			return opts, fmt.Errorf("invalid events backoff '%s', expected a positive duration like 2s", cfg.Backoff)
+		}
+	}
+	if cfg.MaxBackoff != "" {
+		if opts.MaxBackoff, err = time.ParseDuration(cfg.MaxBackoff); err != nil || opts.MaxBackoff <= 0 {
+			return opts, fmt.Errorf("invalid events max_backoff '%s', expected a positive duration like 30s", cfg.MaxBackoff)
+		}
+	}
+	return opts, nil
+}
+
+// delay returns how long to wait before the attempt following failures
+// failed attempts in a row
+func (o EventStreamOptions) delay(failures int) time.Duration {
+	delay := o.Backoff
+	for i := 1; i < failures && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxBackoff {
+		delay = o.MaxBackoff
+	}
+	return delay
+}
+
+// eventStreamState is carried across reconnections
+type eventStreamState struct {
+	lastID string        // Sent as Last-Event-ID so the server can replay missed events
+	retry  time.Duration // Reconnection delay requested by the server
+}
+
+// SubscribeToEvents connects to the SSE endpoint and calls the handler for
+// each event, reconnecting with exponential backoff when the connection fails
+// or drops. Reconnections resume from the last event ID received. It returns
+// nil when ctx is canceled.
+func (c *ToolsClient) SubscribeToEvents(ctx context.Context, opts EventStreamOptions, handler SSEHandler) error {
+	if opts.Log == nil {
+		opts.Log = os.Stderr
+	}
+	endpoints := defaultSSEEndpoints
+	if opts.Endpoint != "" {
+		endpoints = []string{"/" + strings.TrimPrefix(opts.Endpoint, "/")}
+	}
+
+	state := &eventStreamState{}
+	failures := 0
+	for {
+		var lastErr error
+		for _, endpoint := range endpoints {
+			fmt.Fprintf(opts.Log, "Connecting to SSE endpoint: %s%s\n", c.BaseURL, endpoint)
+			connected, err := c.streamEvents(ctx, endpoint, state, opts.Log, handler)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if connected {
+				// Keep using the endpoint that worked
+				endpoints = []string{endpoint}
+				failures = 0
+				if err != nil {
+					fmt.Fprintf(opts.Log, "Stream error: %v\n", err)
+				} else {
+					fmt.Fprintln(opts.Log, "Stream closed by server")
+				}
+				lastErr = nil
+				break
+			}
+			fmt.Fprintf(opts.Log, "Connection to %s failed: %v\n", endpoint, err)
+			lastErr = err
+		}
+
+		if lastErr != nil {
+			failures++
+			if opts.Retries >= 0 && failures > opts.Retries {
+				return fmt.Errorf("giving up after %d failed connection attempts: %w", failures, lastErr)
+			}
+		}
+
+		delay := opts.delay(failures)
+		if state.retry > 0 && failures == 0 {
+			delay = state.retry
+		}
+		if state.lastID != "" {
+			fmt.Fprintf(opts.Log, "Reconnecting in %v from event %s...\n", delay, state.lastID)
+		} else {
+			fmt.Fprintf(opts.Log, "Reconnecting in %v...\n", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// streamEvents reads one connection to endpoint until it ends, and reports
+// whether the connection was established
+func (c *ToolsClient) streamEvents(ctx context.Context, endpoint string, state *eventStreamState, log io.Writer, handler SSEHandler) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if state.lastID != "" {
+		req.Header.Set("Last-Event-ID", state.lastID)
+	}
+	if c.SessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.SessionID)
+	}
+
+	// No overall timeout, the stream stays open until either side closes it
+	client := &http.Client{
+		Transport: &http.Transport{
+			ResponseHeaderTimeout: 60 * time.Second,
+			DisableCompression:    true,
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	// Streamable HTTP servers answer the stream request with 202 Accepted
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		return false, fmt.Errorf("invalid content type %s (expected text/event-stream)", contentType)
+	}
+
+	fmt.Fprintf(log, "Connected to event stream at %s. Waiting for events...\n", endpoint)
+	return true, readEvents(resp.Body, state, handler)
+}
+
+// readEvents parses a server-sent event stream, calling handler for each
+// event with data. Event IDs and retry delays are recorded in state.
+func readEvents(r io.Reader, state *eventStreamState, handler SSEHandler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// An empty line dispatches the event
+		if line == "" {
+			if len(data) > 0 {
+				if event == "" {
+					event = "message"
+				}
+				handler(StreamEvent{ID: state.lastID, Event: event, Data: strings.Join(data, "\n")})
+			}
+			event, data = "", nil
+			continue
+		}
+
+		// Lines starting with a colon are comments, often used as keep-alives
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				state.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				state.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	pathutil "interop/internal/path"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportKeySuffix marks the client config entries written by mcp export
+const exportKeySuffix = "-interopMCPServer"
+
+// clientServersKey holds the server entries in Claude Desktop and Cursor
+// style client config files
+const clientServersKey = "mcpServers"
+
+// MergeResult summarizes the changes made to a client config file
+type MergeResult struct {
+	Path    string
+	Backup  string // Copy of the file before the change, empty for new files
+	Added   []string
+	Updated []string
+	Removed []string
+}
+
+// String formats the result for the terminal
+func (r MergeResult) String() string {
+	if len(r.Added)+len(r.Updated)+len(r.Removed) == 0 {
+		return fmt.Sprintf("%s is up to date", r.Path)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Updated %s", r.Path)
+	if r.Backup != "" {
+		fmt.Fprintf(&b, " (backup: %s)", r.Backup)
+	}
+	for _, entry := range []struct {
+		label string
+		names []string
+	}{{"Added", r.Added}, {"Updated", r.Updated}, {"Removed", r.Removed}} {
+		if len(entry.names) > 0 {
+			fmt.Fprintf(&b, "\n%s: %s", entry.label, strings.Join(entry.names, ", "))
+		}
+	}
+	return b.String()
+}
+
+// MergeMCPConfigInto writes the exported servers into the mcpServers object of
+// a client config file. Unrelated keys and servers are kept, interop entries
+// for servers that no longer exist are removed, and the previous file is
+// backed up. With remove set, all interop entries are removed instead.
+func MergeMCPConfigInto(path, mode string, remove bool) (*MergeResult, error) {
+	servers := make(map[string]map[string]interface{})
+	if !remove {
+		var err error
+		if servers, err = exportServers(mode); err != nil {
+			return nil, err
+		}
+	}
+
+	target, err := clientConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &MergeResult{Path: target}
+
+	// Keep every top-level key and server entry as raw JSON so values interop
+	// doesn't know about are written back unchanged
+	document := make(map[string]json.RawMessage)
+	existing, err := os.ReadFile(target)
+	switch {
+	case err == nil:
+		if len(bytes.TrimSpace(existing)) > 0 {
+			if err := json.Unmarshal(existing, &document); err != nil {
+				return nil, fmt.Errorf("%s is not a JSON object: %w", target, err)
+			}
+		}
+	case os.IsNotExist(err):
+		existing = nil
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+
+	entries := make(map[string]json.RawMessage)
+	if raw, ok := document[clientServersKey]; ok {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("%s in %s is not a JSON object: %w", clientServersKey, target, err)
+		}
+	}
+
+	for key := range entries {
+		if _, exported := servers[key]; strings.HasSuffix(key, exportKeySuffix) && !exported {
+			delete(entries, key)
+			result.Removed = append(result.Removed, key)
+		}
+	}
+	for key, server := range servers {
+		raw, err := json.Marshal(server)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration: %v", err)
+		}
+		if current, ok := entries[key]; !ok {
+			result.Added = append(result.Added, key)
+		} else if !jsonEqual(current, raw) {
+			result.Updated = append(result.Updated, key)
+		}
+		entries[key] = raw
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Removed)
+
+	if existing != nil && len(result.Added)+len(result.Updated)+len(result.Removed) == 0 {
+		return result, nil
+	}
+
+	if document[clientServersKey], err = json.Marshal(entries); err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration: %v", err)
+	}
+	output, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration: %v", err)
+	}
+
+	if existing != nil {
+		result.Backup = backupPath(target, time.Now())
+		if err := os.WriteFile(result.Backup, existing, 0600); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", target, err)
+		}
+	}
+	if err := writeFileAtomic(target, append(output, '\n')); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// clientConfigPath expands a leading ~ and makes path absolute relative to the
+// working directory
+func clientConfigPath(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		return pathutil.Expand(path)
+	}
+	return filepath.Abs(path)
+}
+
+// backupPath returns an unused backup file name for path
+func backupPath(path string, now time.Time) string {
+	base := fmt.Sprintf("%s.%s", path, now.Format("20060102-150405"))
+	backup := base + ".bak"
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			return backup
+		}
+		backup = fmt.Sprintf("%s-%d.bak", base, i)
+	}
+}
+
+// jsonEqual reports whether two JSON documents hold the same value
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// writeFileAtomic replaces path through a temporary file in the same
+// directory, so clients never read a partially written config
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Health endpoints served next to the MCP endpoint by HTTP servers
+const (
+	HealthPath = "/healthz" // Answers while the server process is serving
+	ReadyPath  = "/readyz"  // Answers 200 once tools are registered, 503 while shutting down
+)
+
+// healthTimeout bounds a health check, a server that is up answers at once
+const healthTimeout = 2 * time.Second
+
+// Health is the body of the health endpoints
+type Health struct {
+	Status     string  `json:"status"`      // "ok" for /healthz, "ready" or "stopping" for /readyz
+	Server     string  `json:"server"`      // Name of the server, "default" for the default one
+	PID        int     `json:"pid"`         // Process serving the requests
+	ConfigHash string  `json:"config_hash"` // ServerConfigHash of the settings the server was started with
+	Started    string  `json:"started"`     // RFC 3339 start time
+	Uptime     float64 `json:"uptime_seconds"`
+	Tools      int     `json:"tools"` // Tools the server registered
+}
+
+// UptimeString renders the uptime for people
+func (h Health) UptimeString() string {
+	return (time.Duration(h.Uptime) * time.Second).String()
+}
+
+// health returns the current health of the server
+func (s *MCPLibServer) health(status string) Health {
+	name := s.name
+	if name == "" {
+		name = "default"
+	}
+	return Health{
+		Status:     status,
+		Server:     name,
+		PID:        os.Getpid(),
+		ConfigHash: s.configHash,
+		Started:    s.started.Format(time.RFC3339),
+		Uptime:     time.Since(s.started).Round(time.Second).Seconds(),
+		Tools:      s.toolCount(),
+	}
+}
+
+// toolCount returns the number of tools clients see in tools/list
+func (s *MCPLibServer) toolCount() int {
+	response := s.mcpServer.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return 0
+	}
+	var listing struct {
+		Result struct {
+			Tools []json.RawMessage `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &listing); err != nil {
+		return 0
+	}
+	return len(listing.Result.Tools)
+}
+
+// handleHealth serves HealthPath and ReadyPath on mux
+func (s *MCPLibServer) handleHealth(mux *http.ServeMux) {
+	write := func(w http.ResponseWriter, code int, health Health) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(health)
+	}
+
+	mux.HandleFunc(HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, s.health("ok"))
+	})
+	mux.HandleFunc(ReadyPath, func(w http.ResponseWriter, r *http.Request) {
+		if s.stopping.Load() {
+			write(w, http.StatusServiceUnavailable, s.health("stopping"))
+			return
+		}
+		write(w, http.StatusOK, s.health("ready"))
+	})
+}
+
+// Health asks a running server for its health over HTTP, which tells more
+// than its PID file: the process answers and serves the reported settings
+func (s *Server) Health() (Health, error) {
+	var health Health
+	client := http.Client{Timeout: healthTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d%s", s.Port, HealthPath))
+	if err != nil {
+		return health, fmt.Errorf("not responding: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("unhealthy: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return health, fmt.Errorf("unexpected health response: %w", err)
+	}
+	if name := s.key(); health.Server != name {
+		return health, fmt.Errorf("port %d is served by MCP server '%s'", s.Port, health.Server)
+	}
+	return health, nil
+}
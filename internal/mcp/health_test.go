@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"encoding/json"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.build]
+cmd = "make build"
+is_enabled = true
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	mux := http.NewServeMux()
+	s.handleHealth(mux)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	get := func(path string) (int, Health) {
+		t.Helper()
+		resp, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		var health Health
+		if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			t.Fatalf("GET %s returned an undecodable body: %v", path, err)
+		}
+		return resp.StatusCode, health
+	}
+
+	code, health := get(HealthPath)
+	if code != http.StatusOK || health.Status != "ok" {
+		t.Errorf("GET %s = %d %q, want 200 \"ok\"", HealthPath, code, health.Status)
+	}
+	if health.Server != "default" {
+		t.Errorf("Server = %q, want \"default\"", health.Server)
+	}
+	if want := ServerConfigHash(cfg, ""); health.ConfigHash != want {
+		t.Errorf("ConfigHash = %q, want %q", health.ConfigHash, want)
+	}
+	if health.Tools == 0 {
+		t.Errorf("Tools = 0, want the registered tools counted")
+	}
+
+	if code, health = get(ReadyPath); code != http.StatusOK || health.Status != "ready" {
+		t.Errorf("GET %s = %d %q, want 200 \"ready\"", ReadyPath, code, health.Status)
+	}
+
+	s.Stop()
+	if code, health = get(ReadyPath); code != http.StatusServiceUnavailable || health.Status != "stopping" {
+		t.Errorf("GET %s after Stop = %d %q, want 503 \"stopping\"", ReadyPath, code, health.Status)
+	}
+	if code, _ = get(HealthPath); code != http.StatusOK {
+		t.Errorf("GET %s after Stop = %d, want 200", HealthPath, code)
+	}
+}
@@ -2,20 +2,36 @@ package mcp
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"interop/internal/argfile"
+	"interop/internal/artifacts"
+	"interop/internal/condition"
+	"interop/internal/execution"
+	"interop/internal/jobs"
 	"interop/internal/logging"
+	pathutil "interop/internal/path"
+	"interop/internal/remote"
 	"interop/internal/settings"
+	"interop/internal/shell"
+	"interop/internal/termtext"
+	"interop/internal/tracing"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ToolOutput represents the JSON structure for tool outputs
@@ -56,14 +72,31 @@ type MCPLibServer struct {
 	commandAliases   map[string]string // Maps alias -> original command name
 	serverMode       string            // "stdio" or "sse"
 	isToolOutputJson bool              // Whether to output tool results in JSON format
+	restrictProject  bool              // Whether project_path must be inside a configured project
+	noCache          bool              // Whether cached command results are ignored, see MCP_NO_CACHE
+	fileSources      map[string]string // Source description of each settings file, see commandMetadata
+	toolCommands     map[string]string // Maps each command tool -> the command it runs
+	historyMu        sync.Mutex
+	history          []executionRecord // Recent command runs, oldest first, see recordExecution
+	name             string            // Server name, empty for the default server
+	configHash       string            // ServerConfigHash of the settings the server was built from
+	started          time.Time
+	stopping         atomic.Bool // Set once Stop begins, failing readiness checks
+	// maxTimeout is the longest timeout a tool call can ask for, see max_tool_timeout
+	maxTimeout time.Duration
+	// project is the project a project server runs its commands in, see
+	// settings.Project.MCPServer
+	project string
+	// recorder writes the server's traffic to a session file when
+	// MCP_RECORD_SESSION is set, nil otherwise
+	recorder *sessionRecorder
 }
 
-// sanitizeOutput ensures there are no ANSI color codes in the output
+// sanitizeOutput ensures there are no terminal escape sequences, like colors,
+// cursor movement or window titles, in the output
 // This helps prevent JSON parsing errors in the client
 func sanitizeOutput(output string) string {
-	// ANSI color code regex pattern
-	colorPattern := regexp.MustCompile("\x1b\\[[0-9;]*m")
-	return colorPattern.ReplaceAllString(output, "")
+	return termtext.Strip(output)
 }
 
 // NewMCPLibServer creates a new MCP server using the mark3labs/mcp-go library
@@ -72,15 +105,17 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 	// This is essential to prevent color codes from corrupting JSON output
 	logging.DisableColors()
 
-	homeDir, err := os.UserHomeDir()
+	appDir, err := settings.GetAppDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, fmt.Errorf("failed to get config directory: %w", err)
 	}
 
-	// Create configuration directory
-	configDir := filepath.Join(homeDir, ".config", "interop", "mcp")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	// Create configuration directory. Sandboxed servers keep no files.
+	configDir := filepath.Join(appDir, "mcp")
+	if !settings.Sandboxed() {
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create config directory: %w", err)
+		}
 	}
 
 	// Get server name, port and mode from environment variables if available
@@ -131,17 +166,21 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 		logFileName = fmt.Sprintf("mcp-lib-%s.log", serverName)
 	}
 
-	// Create log file
-	logFilePath := filepath.Join(configDir, logFileName)
-	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create log file: %w", err)
+	// Create log file. Sandboxed servers log to the terminal instead.
+	var logFile *os.File
+	if !settings.Sandboxed() {
+		logFilePath := filepath.Join(configDir, logFileName)
+		logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create log file: %w", err)
+		}
 	}
 
 	// Only redirect stdout to log file in SSE mode, not in stdio mode
 	// Save the original stdout for later restoration if needed
 	originalStdout := os.Stdout
-	if serverMode != "stdio" {
+	redirectStdout := serverMode != "stdio" && logFile != nil
+	if redirectStdout {
 		// Redirect standard output to log file for MCP server logging
 		// This is necessary because the MCP server logs to stdout
 		os.Stdout = logFile
@@ -149,10 +188,12 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 
 	// Make sure we restore stdout and close log file if there's an error
 	cleanup := func() {
-		if serverMode != "stdio" {
+		if redirectStdout {
 			os.Stdout = originalStdout
 		}
-		logFile.Close()
+		if logFile != nil {
+			logFile.Close()
+		}
 	}
 
 	// Load commands from settings
@@ -164,6 +205,10 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 
 	// Check if we should load commands from a remote repository
 	remoteURL := os.Getenv("MCP_REMOTE_URL")
+	if serverName == "" && remoteURL == "" && !cfg.DefaultMCPServerEnabled() {
+		cleanup()
+		return nil, errDefaultServerDisabled
+	}
 	var remoteCommands map[string]settings.CommandConfig
 	if remoteURL != "" {
 		logging.Message("Loading commands from remote repository: %s", remoteURL)
@@ -183,23 +228,51 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 	}
 
 	// Get server configuration if available
-	var isToolOutputJson bool
+	var isToolOutputJson, batchTool, restrictProjectPath bool
 	if serverName != "" {
 		if serverCfg, exists := cfg.MCPServers[serverName]; exists {
 			isToolOutputJson = serverCfg.IsToolOutputJson
+			batchTool = serverCfg.BatchTool
+			restrictProjectPath = serverCfg.RestrictProjectPath
 		}
 	} else {
 		// For default server, use the global setting
 		isToolOutputJson = cfg.IsToolOutputJson
+		batchTool = cfg.BatchTool
+		restrictProjectPath = cfg.RestrictProjectPath
+	}
+	maxTimeout, err := cfg.ToolTimeoutLimit()
+	if err != nil {
+		cleanup()
+		return nil, err
 	}
 
-	mcpServer := server.NewMCPServer(
-		serverTitle,
-		"1.0.0",
+	options := []server.ServerOption{
 		server.WithToolCapabilities(true),
 		server.WithPromptCapabilities(true),
 		server.WithLogging(),
-	)
+	}
+
+	// Record the session for mcp replay when asked to
+	var recorder *sessionRecorder
+	if os.Getenv("MCP_RECORD_SESSION") != "" {
+		if settings.Sandboxed() {
+			logging.Warning("Sessions aren't recorded in sandbox mode")
+		} else {
+			serverKey := serverName
+			if serverKey == "" {
+				serverKey = "default"
+			}
+			recorder, err = newSessionRecorder(filepath.Join(configDir, sessionsDirName), serverKey)
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			options = append(options, server.WithHooks(recorder.hooks()))
+		}
+	}
+
+	mcpServer := server.NewMCPServer(serverTitle, "1.0.0", options...)
 
 	// Merge local and remote commands
 	commandConfig := make(map[string]settings.CommandConfig)
@@ -230,20 +303,52 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 		commandAliases:   make(map[string]string),
 		serverMode:       serverMode,
 		isToolOutputJson: isToolOutputJson,
+		restrictProject:  restrictProjectPath,
+		noCache:          os.Getenv("MCP_NO_CACHE") != "",
+		fileSources:      make(map[string]string),
+		name:             serverName,
+		configHash:       ServerConfigHash(cfg, serverName),
+		started:          time.Now(),
+		maxTimeout:       maxTimeout,
+		project:          cfg.MCPServers[serverName].Project,
+		recorder:         recorder,
 	}
+	if recorder != nil {
+		s.logInfo("Recording session to %s", recorder.Path())
+	}
+
+	if serverName != "" && cfg.MCPServers[serverName].Admin {
+		// Admin servers only manage interop and never run commands
+		s.registerAdminTools()
+	} else {
+		// Register tools based on available commands for this server
+		s.registerCommandTools(serverName)
+
+		// Register the tool running several commands in one call if enabled
+		if batchTool {
+			s.registerBatchTool()
+		}
 
-	// Register tools based on available commands for this server
-	s.registerCommandTools(serverName)
+		// Register tools reading artifacts written by command runs
+		s.registerArtifactTools()
 
-	// Register prompts based on configuration for this server
-	s.registerPrompts(serverName)
+		// Register the tool describing projects and their recent runs
+		s.registerProjectInfoTool()
+
+		// Register prompts based on configuration for this server
+		s.registerPrompts(serverName)
+	}
 
 	// Create the appropriate server based on mode
 	if serverMode == "stdio" {
 		// No need to create HTTP server for stdio mode
 	} else {
-		// Create HTTP server for SSE mode
-		s.httpServer = server.NewStreamableHTTPServer(mcpServer)
+		// Create HTTP server for SSE mode, serving the health endpoints next
+		// to the MCP endpoint
+		mux := http.NewServeMux()
+		s.httpServer = server.NewStreamableHTTPServer(mcpServer, server.WithStreamableHTTPServer(&http.Server{Handler: mux}))
+		mux.Handle("/mcp", s.httpServer)
+		s.handleHealth(mux)
 	}
 
 	// Write initial log message to file only, not stdout
@@ -256,85 +361,72 @@ func NewMCPLibServer() (*MCPLibServer, error) {
 	return s, nil
 }
 
-// registerCommandTools converts the available commands to MCP tools
-func (s *MCPLibServer) registerCommandTools(serverName string) {
-	// Map to track registered commands to avoid duplicates
-	registeredTools := make(map[string]bool)
-
-	// First, register all commands for this server
-	for name, cmd := range s.commandConfig {
-		if !cmd.IsEnabled {
+// commandTools returns the tools a server registers for its commands, mapping
+// each tool name to the command it runs. Namespaced commands and project
+// aliases of the server's commands become tools of their own unless they clash
+// with a command name. The default server, with an empty name, serves the
+// commands without an mcp assignment, and the server of a project also serves
+// the commands bound to the project, see settings.ServedBy.
+func commandTools(cfg *settings.Settings, commands map[string]settings.CommandConfig, serverName string) map[string]string {
+	tools := make(map[string]string)
+	for name, cmd := range commands {
+		if cmd.IsEnabled && cfg.ServedBy(serverName, name, cmd) && toolName(name) == name {
+			tools[name] = name
+		}
+	}
+	for name, cmd := range commands {
+		if !cmd.IsEnabled || !cfg.ServedBy(serverName, name, cmd) || toolName(name) == name {
 			continue
 		}
+		if _, exists := tools[toolName(name)]; !exists {
+			tools[toolName(name)] = name
+		}
+	}
 
-		// If we have a specific server name, only add commands belonging to this server
-		if serverName != "" {
-			// Only add commands explicitly assigned to this server
-			if cmd.MCP != serverName {
-				// Skip commands not assigned to this server (including those with no MCP field)
+	// A project server only takes the aliases of its own project
+	servedProject := cfg.MCPServers[serverName].Project
+	for projectName, project := range cfg.Projects {
+		if servedProject != "" && projectName != servedProject {
+			continue
+		}
+		for _, cmdAlias := range project.Commands {
+			if cmdAlias.Alias == "" {
 				continue
 			}
-		} else {
-			// For default server, only add commands with no MCP field
-			if cmd.MCP != "" {
-				// Skip commands assigned to a specific server
+			cmd, exists := commands[cmdAlias.CommandName]
+			if !exists || !cmd.IsEnabled || !cfg.ServedBy(serverName, cmdAlias.CommandName, cmd) {
 				continue
 			}
+			if _, exists := tools[cmdAlias.Alias]; exists {
+				continue
+			}
+			tools[cmdAlias.Alias] = cmdAlias.CommandName
 		}
-
-		// Register the main command
-		s.registerSingleCommandTool(name, cmd)
-		registeredTools[name] = true
 	}
+	return tools
+}
 
-	// Now register aliases from projects
-	cfg, err := settings.Load()
-	if err == nil {
-		for _, project := range cfg.Projects {
-			for _, cmdAlias := range project.Commands {
-				// Skip if command doesn't have an alias
-				if cmdAlias.Alias == "" {
-					continue
-				}
-
-				// Find the original command
-				cmd, exists := s.commandConfig[cmdAlias.CommandName]
-				if !exists || !cmd.IsEnabled {
-					s.logInfo("Skipping alias %s for command %s (command not found or disabled)",
-						cmdAlias.Alias, cmdAlias.CommandName)
-					continue
-				}
-
-				// Filter by server name
-				if serverName != "" {
-					// For a named server, only include commands explicitly assigned to this server
-					if cmd.MCP != serverName {
-						// Skip commands not assigned to this server (including those with no MCP field)
-						continue
-					}
-				} else {
-					// For default server, only include commands with no MCP field
-					if cmd.MCP != "" {
-						// Skip commands assigned to a specific server
-						continue
-					}
-				}
-
-				// Skip if this alias is already a registered command name
-				if _, exists := registeredTools[cmdAlias.Alias]; exists {
-					s.logInfo("Skipping alias %s for command %s (conflicts with existing command)",
-						cmdAlias.Alias, cmdAlias.CommandName)
-					continue
-				}
+// toolName returns the name of the MCP tool running a command. Clients only
+// accept letters, digits, '_' and '-' in tool names, so the ':' of namespaced
+// commands like "team:deploy" becomes '_'.
+func toolName(command string) string {
+	return strings.ReplaceAll(command, settings.NamespaceSeparator, "_")
+}
 
-				// Register the alias as a tool that points to the same command
-				s.registerSingleCommandTool(cmdAlias.Alias, cmd)
-				s.logInfo("Registered alias %s for command %s", cmdAlias.Alias, cmdAlias.CommandName)
-				registeredTools[cmdAlias.Alias] = true
+// registerCommandTools converts the available commands to MCP tools
+func (s *MCPLibServer) registerCommandTools(serverName string) {
+	cfg, err := settings.Load()
+	if err != nil {
+		cfg = &settings.Settings{}
+	}
 
-				// Store the alias mapping
-				s.commandAliases[cmdAlias.Alias] = cmdAlias.CommandName
-			}
+	s.toolCommands = commandTools(cfg, s.commandConfig, serverName)
+	for toolName, cmdName := range s.toolCommands {
+		s.registerSingleCommandTool(toolName, s.commandConfig[cmdName])
+		if toolName != cmdName {
+			// Store the alias mapping
+			s.commandAliases[toolName] = cmdName
+			s.logInfo("Registered alias %s for command %s", toolName, cmdName)
 		}
 	}
 
@@ -350,25 +442,24 @@ func (s *MCPLibServer) registerCommandTools(serverName string) {
 		// Show only commands for this server
 		for name, cmd := range s.commandConfig {
 			if cmd.IsEnabled {
-				// Filter by server name
-				if serverName != "" {
-					// For a named server, only include commands explicitly assigned to this server
-					if cmd.MCP != serverName {
-						// Skip commands not assigned to this server (including those with no MCP field)
-						continue
-					}
-				} else {
-					// For default server, only include commands with no MCP field
-					if cmd.MCP != "" {
-						// Skip commands assigned to a specific server
-						continue
-					}
+				// Filter by server name, a named server includes the commands
+				// assigned to it and the default one those with no MCP field
+				if !cfg.ServedBy(serverName, name, cmd) {
+					continue
 				}
 
-				commands[name] = map[string]interface{}{
+				metadata := s.commandMetadata(cmd)
+				info := map[string]interface{}{
 					"description": cmd.Description,
 					"cmd":         cmd.Cmd,
+					"version":     metadata.Version,
+					"source":      metadata.Source,
+					"modified":    metadata.Modified,
+				}
+				if cmd.Script != "" {
+					info["script"] = cmd.Script
 				}
+				commands[name] = info
 			}
 		}
 
@@ -380,33 +471,132 @@ func (s *MCPLibServer) registerCommandTools(serverName string) {
 	s.logInfo("Registered MCP commands tool")
 }
 
+// maxArtifactReadSize limits how much of an artifact read_artifact returns
+const maxArtifactReadSize = 1 << 20
+
+// registerArtifactTools registers tools listing and reading the artifacts that
+// command runs wrote to INTEROP_ARTIFACTS_DIR
+func (s *MCPLibServer) registerArtifactTools() {
+	listArtifactsTool := mcp.NewTool(
+		"list_artifacts",
+		mcp.WithDescription("List files that recent command runs wrote to their artifacts directory, newest run first"),
+		mcp.WithString("run_id", mcp.Description("Only list the artifacts of this run")),
+	)
+
+	s.mcpServer.AddTool(listArtifactsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		runID, _ := args["run_id"].(string)
+
+		var runs []artifacts.Run
+		if runID != "" {
+			run, err := artifacts.Get(runID)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			runs = append(runs, *run)
+		} else {
+			var err error
+			if runs, err = artifacts.List(); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to list artifacts: %v", err)), nil
+			}
+		}
+
+		runsJSON, _ := json.MarshalIndent(runs, "", "  ")
+		return mcp.NewToolResultText(formatToolOutput(string(runsJSON), s.isToolOutputJson)), nil
+	})
+
+	readArtifactTool := mcp.NewTool(
+		"read_artifact",
+		mcp.WithDescription("Read a file a command run wrote to its artifacts directory"),
+		mcp.WithString("ref", mcp.Description("Artifact reference (artifact:<run-id>/<path>) reported with the command output or by list_artifacts"), mcp.Required()),
+	)
+
+	s.mcpServer.AddTool(readArtifactTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		ref, _ := args["ref"].(string)
+		if !strings.HasPrefix(ref, artifacts.RefPrefix) {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid artifact reference '%s'", ref)), nil
+		}
+
+		path, err := artifacts.ResolveRef(ref)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if info, err := os.Stat(path); err == nil && info.Size() > maxArtifactReadSize {
+			return mcp.NewToolResultError(fmt.Sprintf("Artifact is %s, larger than the %s read limit", artifacts.FormatSize(info.Size()), artifacts.FormatSize(maxArtifactReadSize))), nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read artifact: %v", err)), nil
+		}
+		if !utf8.Valid(content) {
+			return mcp.NewToolResultText(formatToolOutput("base64:"+base64.StdEncoding.EncodeToString(content), s.isToolOutputJson)), nil
+		}
+
+		return mcp.NewToolResultText(formatToolOutput(string(content), s.isToolOutputJson)), nil
+	})
+
+	s.logInfo("Registered MCP artifact tools")
+}
+
+// promptDescription returns the description of a prompt, naming the tools
+// its workflow uses and the prompts it includes
+func promptDescription(prompt settings.PromptConfig) string {
+	var notes []string
+	if len(prompt.Suggested) > 0 {
+		notes = append(notes, "Suggested tools: "+strings.Join(prompt.Suggested, ", "))
+	}
+	if len(prompt.Includes) > 0 {
+		notes = append(notes, "Includes prompts: "+strings.Join(prompt.Includes, ", "))
+	}
+	if len(notes) == 0 {
+		return prompt.Description
+	}
+	return fmt.Sprintf("%s (%s)", prompt.Description, strings.Join(notes, "; "))
+}
+
+// promptMeta returns the _meta of a rendered prompt, listing its suggested
+// tools and included prompts, or nil when it has neither
+func promptMeta(prompt settings.PromptConfig) map[string]any {
+	meta := make(map[string]any)
+	if len(prompt.Suggested) > 0 {
+		meta["suggested_tools"] = prompt.Suggested
+	}
+	if len(prompt.Includes) > 0 {
+		meta["includes"] = prompt.Includes
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
 // registerPrompts registers prompts from configuration as MCP prompts
 func (s *MCPLibServer) registerPrompts(serverName string) {
 	// Register prompts for this server
 	for name, promptConfig := range s.promptConfig {
-		// Filter by server name similar to commands
-		if serverName != "" {
-			// For a named server, only add prompts explicitly assigned to this server
-			if promptConfig.MCP != serverName {
-				// Skip prompts not assigned to this server (including those with no MCP field)
-				continue
-			}
-		} else {
-			// For default server, only add prompts with no MCP field
-			if promptConfig.MCP != "" {
-				// Skip prompts assigned to a specific server
-				continue
-			}
+		// Filter by server name similar to commands: a named server only adds
+		// prompts assigned to it, the default server those with no MCP field
+		if !promptConfig.MCP.Includes(serverName) {
+			continue
 		}
 
 		// Create prompt options starting with description
 		promptOptions := []mcp.PromptOption{
-			mcp.WithPromptDescription(promptConfig.Description),
+			mcp.WithPromptDescription(promptDescription(promptConfig)),
+		}
+
+		// Included prompts are rendered with the same arguments, so they are
+		// declared along with the prompt's own
+		arguments, err := settings.PromptChainArguments(s.promptConfig, name)
+		if err != nil {
+			s.logWarning("Prompt %s: %v", name, err)
+			arguments = promptConfig.Arguments
 		}
 
 		// Add arguments to the prompt if defined
-		if len(promptConfig.Arguments) > 0 {
-			for _, arg := range promptConfig.Arguments {
+		if len(arguments) > 0 {
+			for _, arg := range arguments {
 				description := arg.Description
 				if arg.Type != settings.ArgumentTypeString {
 					description = fmt.Sprintf("%s (type: %s)", description, arg.Type)
@@ -434,82 +624,22 @@ func (s *MCPLibServer) registerPrompts(serverName string) {
 
 		// Add the prompt handler
 		s.mcpServer.AddPrompt(prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-			// Process arguments if the prompt has them defined
-			var processedArgs map[string]interface{}
-			if len(promptConfig.Arguments) > 0 {
-				processedArgs = make(map[string]interface{})
-
-				// Validate and process each argument
-				for _, argDef := range promptConfig.Arguments {
-					var value interface{}
-
-					// Get the value from the request arguments
-					if request.Params.Arguments != nil {
-						if argValue, exists := request.Params.Arguments[argDef.Name]; exists {
-							// Arguments come as strings from the request, convert based on expected type
-							switch argDef.Type {
-							case settings.ArgumentTypeNumber:
-								// Convert string to number
-								if numVal, err := strconv.ParseFloat(argValue, 64); err == nil {
-									value = numVal
-								} else {
-									value = argValue
-								}
-							case settings.ArgumentTypeBool:
-								// Convert string to bool
-								if boolVal, err := strconv.ParseBool(argValue); err == nil {
-									value = boolVal
-								} else {
-									value = argValue
-								}
-							default:
-								value = argValue
-							}
-						}
-					}
-
-					// If no value was provided, check if it's required
-					if value == nil {
-						if argDef.Required {
-							if argDef.Default == nil {
-								return nil, fmt.Errorf("required argument '%s' is missing", argDef.Name)
-							}
-							value = argDef.Default
-						} else {
-							// Use default value if available
-							value = argDef.Default
-						}
-					}
-
-					// Store the processed value
-					if value != nil {
-						processedArgs[argDef.Name] = value
-					}
-				}
+			texts, err := settings.RenderPromptChain(s.promptConfig, name, request.Params.Arguments)
+			if err != nil {
+				return nil, err
 			}
 
-			// Create the prompt content based on configuration and arguments
-			promptText := promptConfig.Content
-
-			// Perform template substitution if arguments are provided
-			if len(processedArgs) > 0 {
-				// Replace argument placeholders in the content
-				for key, value := range processedArgs {
-					placeholder := "{" + key + "}"
-					replacement := fmt.Sprintf("%v", value)
-					promptText = strings.ReplaceAll(promptText, placeholder, replacement)
-				}
+			// One message per prompt, included prompts first
+			var messages []mcp.PromptMessage
+			for _, text := range texts {
+				messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)))
 			}
 
-			// Create the prompt result with the configured description and processed content
-			messages := []mcp.PromptMessage{
-				mcp.NewPromptMessage(
-					mcp.RoleUser,
-					mcp.NewTextContent(promptText),
-				),
+			result := mcp.NewGetPromptResult(promptConfig.Description, messages)
+			if meta := promptMeta(promptConfig); meta != nil {
+				result.Meta = meta
 			}
-
-			return mcp.NewGetPromptResult(promptConfig.Description, messages), nil
+			return result, nil
 		})
 
 		s.logInfo("Registered MCP prompt: %s", name)
@@ -527,29 +657,45 @@ func (s *MCPLibServer) registerSingleCommandTool(name string, cmdConfig settings
 	// Determine if this command is global (not bound to any project)
 	isGlobalCommand := s.isGlobalCommand(name)
 
+	// Note: mcp-go doesn't have a built-in version option, so the version and
+	// the definition's source are included in the description
+	metadata := s.commandMetadata(cmdConfig)
+	description := cmdConfig.Description
+	if details := metadata.String(); details != "" {
+		if description == "" {
+			description = details
+		} else {
+			description = fmt.Sprintf("%s (%s)", description, details)
+		}
+	}
+
 	// Create tool options
 	toolOptions := []mcp.ToolOption{
-		mcp.WithDescription(cmdConfig.Description),
+		mcp.WithDescription(description),
 	}
 
-	// Add version information if available
-	if cmdConfig.Version != "" {
-		// Note: mcp-go doesn't have a built-in version option, so we'll include it in the description
-		description := cmdConfig.Description
-		if description == "" {
-			description = fmt.Sprintf("Version: %s", cmdConfig.Version)
-		} else {
-			description = fmt.Sprintf("%s (Version: %s)", description, cmdConfig.Version)
-		}
-		toolOptions = []mcp.ToolOption{
-			mcp.WithDescription(description),
+	// Add project_path parameter for global commands, unless the command turns it off
+	if isGlobalCommand && cmdConfig.ProjectPathAllowed() {
+		pathDescription := "Path to project directory where the command should be executed. Required for global commands to provide project context."
+		if len(cmdConfig.AllowedProjects) > 0 {
+			pathDescription += fmt.Sprintf(" Must be inside one of the projects: %s.", strings.Join(cmdConfig.AllowedProjects, ", "))
 		}
+		toolOptions = append(toolOptions,
+			mcp.WithString("project_path", mcp.Description(pathDescription), mcp.Required()),
+		)
 	}
 
-	// Add project_path parameter for global commands
-	if isGlobalCommand {
+	// Let clients skip the cache of commands whose results are cached
+	if _, cached, _ := cmdConfig.CacheDuration(); cached {
+		toolOptions = append(toolOptions,
+			mcp.WithBoolean("no_cache", mcp.Description("Run the command even if a cached result for the project's current git state exists")),
+		)
+	}
+
+	// Let clients give a timeout, unless the command has an argument of that name
+	if hasTimeoutParameter(cmdConfig) {
 		toolOptions = append(toolOptions,
-			mcp.WithString("project_path", mcp.Description("Path to project directory where the command should be executed. Required for global commands to provide project context."), mcp.Required()),
+			mcp.WithString("timeout", mcp.Description(fmt.Sprintf("Stop the command if it runs longer than this, like 90s or 10m (default: %s, at most %s)", formatTimeout(s.defaultTimeout()), formatTimeout(s.timeoutLimit())))),
 		)
 	}
 
@@ -559,6 +705,9 @@ func (s *MCPLibServer) registerSingleCommandTool(name string, cmdConfig settings
 			if arg.Type != settings.ArgumentTypeString {
 				description = fmt.Sprintf("%s (type: %s)", description, arg.Type)
 			}
+			if arg.FromFile {
+				description += " (file content, or @path to read a file on the server's machine; the command receives the path of a temporary file holding it)"
+			}
 
 			toolOptions = append(toolOptions,
 				mcp.WithString(arg.Name, mcp.Description(description)),
@@ -576,93 +725,248 @@ func (s *MCPLibServer) registerSingleCommandTool(name string, cmdConfig settings
 
 	// Add the tool handler
 	s.mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// Convert request parameters to map
-		args, ok := request.Params.Arguments.(map[string]interface{})
-		if !ok {
-			return mcp.NewToolResultError("Invalid arguments format"), nil
+		ctx = correlate(ctx)
+		var result string
+		args, err := argumentsObject(request.Params.Arguments)
+		if err == nil {
+			result, err = s.runCommandTool(ctx, name, cmdConfig, args)
 		}
 
-		// For global commands, extract project_path separately (don't add to args)
-		var providedProjectPath string
-		if isGlobalCommand {
-			if pathValue, ok := args["project_path"]; ok {
-				if pathStr, ok := pathValue.(string); ok && pathStr != "" {
-					providedProjectPath = pathStr
-				}
+		// Let clients tell a rejected path or argument apart from a failed command
+		var pathErr *settings.ProjectPathError
+		if errors.As(err, &pathErr) {
+			errJSON, _ := json.Marshal(pathErr)
+			return withCorrelationID(ctx, mcp.NewToolResultError(string(errJSON))), nil
+		}
+		var argErr *ArgumentError
+		if errors.As(err, &argErr) {
+			errJSON, _ := json.Marshal(argErr)
+			return withCorrelationID(ctx, mcp.NewToolResultError(string(errJSON))), nil
+		}
+		if err != nil {
+			return withCorrelationID(ctx, mcp.NewToolResultError(fmt.Sprintf("Command execution failed: %v", err))), nil
+		}
+
+		// Return the sanitized result in JSON format
+		return withCorrelationID(ctx, mcp.NewToolResultText(formatToolOutput(sanitizeOutput(result), s.isToolOutputJson))), nil
+	})
+
+	s.logInfo("Registered MCP tool for command: %s", name)
+}
+
+// runCommandTool runs the command behind a tool with the arguments of a tool
+// call and returns its output. Calls without a correlation ID in ctx get one.
+func (s *MCPLibServer) runCommandTool(ctx context.Context, name string, cmdConfig settings.CommandConfig, args map[string]interface{}) (string, error) {
+	ctx = correlate(ctx)
+	isGlobalCommand := s.isGlobalCommand(name)
+	metadata := s.commandMetadata(cmdConfig)
+
+	// Check the arguments against the tool's schema before using any
+	args, err := decodeToolArguments(args, cmdConfig, isGlobalCommand)
+	if err != nil {
+		s.callInfo(ctx, "Rejected arguments for command %s: %v", name, err)
+		return "", err
+	}
+
+	// For global commands, extract project_path separately (don't add to args)
+	var providedProjectPath string
+	if isGlobalCommand {
+		if pathValue, ok := args["project_path"]; ok {
+			if pathStr, ok := pathValue.(string); ok && pathStr != "" {
+				providedProjectPath = pathStr
 			}
 		}
+	}
+	if providedProjectPath != "" {
+		cfg, err := settings.Load()
+		if err != nil {
+			return "", fmt.Errorf("failed to load settings: %w", err)
+		}
+		if providedProjectPath, err = cfg.CheckCommandProjectPath(providedProjectPath, cmdConfig, s.restrictProject); err != nil {
+			s.callInfo(ctx, "Rejected project_path for command %s: %v", name, err)
+			return "", err
+		}
+	}
 
-		// Handle arguments according to how they were defined
-		var processedArgs map[string]interface{}
-		if len(cmdConfig.Arguments) > 0 {
-			// For commands with defined arguments, extract each from the request
-			processedArgs = make(map[string]interface{})
-			for _, arg := range cmdConfig.Arguments {
-				if value, ok := args[arg.Name]; ok {
-					// Convert values based on the expected type
-					switch arg.Type {
-					case settings.ArgumentTypeNumber:
-						// Convert to number if needed
-						switch v := value.(type) {
-						case string:
-							if numVal, err := strconv.ParseFloat(v, 64); err == nil {
-								processedArgs[arg.Name] = numVal
-							} else {
-								processedArgs[arg.Name] = value
-							}
-						case float64:
-							processedArgs[arg.Name] = v
-						case int:
-							processedArgs[arg.Name] = float64(v)
-						default:
-							processedArgs[arg.Name] = value
-						}
-					case settings.ArgumentTypeBool:
-						// Convert to bool if needed
-						switch v := value.(type) {
-						case string:
-							if boolVal, err := strconv.ParseBool(v); err == nil {
-								processedArgs[arg.Name] = boolVal
-							} else {
-								processedArgs[arg.Name] = value
-							}
-						case bool:
-							processedArgs[arg.Name] = v
-						default:
-							processedArgs[arg.Name] = value
-						}
-					default:
-						processedArgs[arg.Name] = value
-					}
-				}
+	// Handle arguments according to how they were defined
+	var processedArgs map[string]interface{}
+	if len(cmdConfig.Arguments) > 0 {
+		// For commands with defined arguments, extract each from the request,
+		// their types are checked along with the defaults when the command runs
+		processedArgs = make(map[string]interface{})
+		for _, arg := range cmdConfig.Arguments {
+			if value, ok := args[arg.Name]; ok {
+				processedArgs[arg.Name] = value
 			}
-		} else {
-			// For legacy commands, use the 'args' object if provided
-			// But exclude project_path from it
-			if rawArgs, ok := args["args"]; ok {
-				if argsMap, ok := rawArgs.(map[string]interface{}); ok {
-					processedArgs = make(map[string]interface{})
-					for key, value := range argsMap {
-						// Don't include project_path in args - it's handled separately
-						if key != "project_path" {
-							processedArgs[key] = value
-						}
+		}
+	} else {
+		// For legacy commands, use the 'args' object if provided
+		// But exclude project_path from it
+		if rawArgs, ok := args["args"]; ok {
+			if argsMap, ok := rawArgs.(map[string]interface{}); ok {
+				processedArgs = make(map[string]interface{})
+				for key, value := range argsMap {
+					// Don't include project_path in args - it's handled separately
+					if key != "project_path" {
+						processedArgs[key] = value
 					}
 				}
 			}
 		}
+	}
 
-		// Execute the command - pass project_path separately
-		result, err := s.executeCommandWithPath(name, cmdConfig.Cmd, processedArgs, providedProjectPath)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Command execution failed: %v", err)), nil
+	// Execute the command - pass project_path separately
+	_, span := tracing.Start(ctx, "mcp.tool_call",
+		attribute.String("mcp.tool", name),
+		attribute.String("command.version", metadata.Version),
+		attribute.String("command.source", metadata.Source),
+		attribute.String("interop.correlation_id", logging.CorrelationID(ctx)),
+	)
+	noCache, _ := args["no_cache"].(bool)
+	var timeoutValue interface{}
+	if hasTimeoutParameter(cmdConfig) {
+		timeoutValue = args["timeout"]
+	}
+	timeout, err := s.toolTimeout(timeoutValue)
+	if err != nil {
+		tracing.End(span, err)
+		return "", err
+	}
+	// The command's own timeout caps the call's
+	if limit, err := cmdConfig.RunTimeout(); err == nil && limit > 0 {
+		timeout = min(timeout, limit)
+	}
+	result, err := s.executeCommandWithPath(ctx, name, cmdConfig.Cmd, processedArgs, providedProjectPath, noCache, timeout)
+	tracing.End(span, err)
+	return result, err
+}
+
+// hasTimeoutParameter reports whether the command's tool takes the timeout
+// parameter, which a command argument of the same name replaces
+func hasTimeoutParameter(cmdConfig settings.CommandConfig) bool {
+	for _, arg := range cmdConfig.Arguments {
+		if arg.Name == "timeout" {
+			return false
 		}
+	}
+	return true
+}
 
-		// Return the sanitized result in JSON format
-		return mcp.NewToolResultText(formatToolOutput(sanitizeOutput(result), s.isToolOutputJson)), nil
-	})
+// timeoutLimit returns the longest timeout a tool call can ask for
+func (s *MCPLibServer) timeoutLimit() time.Duration {
+	if s.maxTimeout > 0 {
+		return s.maxTimeout
+	}
+	return settings.DefaultMaxToolTimeout
+}
 
-	s.logInfo("Registered MCP tool for command: %s", name)
+// defaultTimeout returns the timeout of tool calls that don't give one
+func (s *MCPLibServer) defaultTimeout() time.Duration {
+	return min(settings.DefaultToolTimeout, s.timeoutLimit())
+}
+
+// toolTimeout returns the timeout a tool call asks for with its timeout
+// argument, a duration like "90s" or a number of seconds. Calls without one
+// get the default, and ones asking for more than the limit are rejected.
+func (s *MCPLibServer) toolTimeout(value interface{}) (time.Duration, error) {
+	var timeout time.Duration
+	switch v := value.(type) {
+	case nil:
+		return s.defaultTimeout(), nil
+	case float64:
+		timeout = time.Duration(v * float64(time.Second))
+	case string:
+		if v == "" {
+			return s.defaultTimeout(), nil
+		}
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			timeout = time.Duration(seconds * float64(time.Second))
+		} else if timeout, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("invalid timeout '%s': use a duration like 90s or 10m", v)
+		}
+	default:
+		return 0, fmt.Errorf("invalid timeout %v: use a duration like 90s or 10m", value)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %v: must be positive", value)
+	}
+	if limit := s.timeoutLimit(); timeout > limit {
+		return 0, fmt.Errorf("timeout %s is longer than the server's limit of %s", formatTimeout(timeout), formatTimeout(limit))
+	}
+	return timeout, nil
+}
+
+// formatTimeout formats a timeout without the zero units time.Duration adds,
+// 5m rather than 5m0s
+func formatTimeout(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// commandMetadata identifies the definition a tool runs, so clients and audits
+// can tell definitions apart when remotes update them under a running server
+type commandMetadata struct {
+	Version  string
+	Source   string // "main settings", "local <dir>" or "remote '<name>' @ <commit>"
+	Modified string // Last modification of the defining file, RFC 3339
+}
+
+// String formats the metadata for a tool description
+func (m commandMetadata) String() string {
+	var parts []string
+	if m.Version != "" {
+		parts = append(parts, "Version: "+m.Version)
+	}
+	if m.Source != "" {
+		parts = append(parts, "Source: "+m.Source)
+	}
+	if m.Modified != "" {
+		parts = append(parts, "Modified: "+m.Modified)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// commandMetadata returns the version, source and modification time of a
+// command's definition. Commands loaded from MCP_REMOTE_URL have no source file.
+func (s *MCPLibServer) commandMetadata(cmd settings.CommandConfig) commandMetadata {
+	metadata := commandMetadata{Version: cmd.Version}
+	if cmd.SourceFile == "" {
+		return metadata
+	}
+	if info, err := os.Stat(cmd.SourceFile); err == nil {
+		metadata.Modified = info.ModTime().UTC().Format(time.RFC3339)
+	}
+
+	if source, ok := s.fileSources[cmd.SourceFile]; ok {
+		metadata.Source = source
+		return metadata
+	}
+
+	dir := filepath.Dir(cmd.SourceFile)
+	appDir, _ := settings.GetAppDir()
+	switch {
+	case dir == filepath.Join(appDir, "config.d.remote"):
+		name, commit := remote.NewManager().RemoteForFile(cmd.SourceFile)
+		if len(commit) > 8 {
+			commit = commit[:8]
+		}
+		metadata.Source = "remote"
+		if name != "" {
+			metadata.Source = fmt.Sprintf("remote '%s' @ %s", name, commit)
+		}
+	case dir == appDir:
+		metadata.Source = "main settings"
+	default:
+		metadata.Source = "local " + dir
+	}
+	s.fileSources[cmd.SourceFile] = metadata.Source
+	return metadata
 }
 
 // isGlobalCommand checks if a command is global (not bound to any project)
@@ -686,13 +990,44 @@ func (s *MCPLibServer) isGlobalCommand(commandName string) bool {
 	return true // Command not found in any project without alias, so it's global
 }
 
-// executeCommandWithPath runs a command and returns its output, with project_path handled separately
-func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[string]interface{}, projectPath string) (string, error) {
+// executeCommandWithPath runs a command and returns its output, with project_path handled separately.
+// With noCache the command runs even if its result is cached. The command is
+// stopped once it runs for timeout. The commands of its depends_on run first,
+// like with interop run: without arguments, in the same project directory,
+// and each once. Their outputs come before the command's.
+func (s *MCPLibServer) executeCommandWithPath(ctx context.Context, name, cmdStr string, args map[string]interface{}, projectPath string, noCache bool, timeout time.Duration) (string, error) {
+	originalName := name
+	if aliasTarget, isAlias := s.commandAliases[name]; isAlias {
+		originalName = aliasTarget
+	}
+	order, err := (&settings.Settings{Commands: s.commandConfig}).DependencyOrder(originalName)
+	if err != nil {
+		return "", err
+	}
+
+	var outputs strings.Builder
+	for _, dependency := range order {
+		s.callInfo(ctx, "Running dependency %s of command %s", dependency, originalName)
+		output, err := s.executeSingleCommand(ctx, dependency, s.commandConfig[dependency].Cmd, map[string]interface{}{}, projectPath, noCache, timeout)
+		fmt.Fprintf(&outputs, "Dependency %s:\n%s\n", dependency, strings.TrimRight(output, "\n"))
+		if err != nil {
+			return outputs.String(), fmt.Errorf("dependency '%s' of command '%s' failed: %w", dependency, originalName, err)
+		}
+	}
+	if len(order) == 0 {
+		return s.executeSingleCommand(ctx, name, cmdStr, args, projectPath, noCache, timeout)
+	}
+	output, err := s.executeSingleCommand(ctx, name, cmdStr, args, projectPath, noCache, timeout)
+	return outputs.String() + output, err
+}
+
+// executeSingleCommand is executeCommandWithPath without the command's dependencies
+func (s *MCPLibServer) executeSingleCommand(ctx context.Context, name, cmdStr string, args map[string]interface{}, projectPath string, noCache bool, timeout time.Duration) (string, error) {
 	// Check if the command is an alias, and if so use the original command name
 	originalName := name
 	if aliasTarget, isAlias := s.commandAliases[name]; isAlias {
 		originalName = aliasTarget
-		s.logInfo("Command %s is an alias for %s", name, originalName)
+		s.callInfo(ctx, "Command %s is an alias for %s", name, originalName)
 	}
 
 	// Get the command from config using the original name
@@ -706,6 +1041,18 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		return "", fmt.Errorf("command '%s' is disabled", originalName)
 	}
 
+	// Skip the command when its condition does not hold
+	if cmdConfig.When != "" {
+		run, err := condition.Evaluate(cmdConfig.When, condition.NewContext())
+		if err != nil {
+			return "", fmt.Errorf("invalid condition for command '%s': %w", originalName, err)
+		}
+		if !run {
+			s.callInfo(ctx, "Skipping command %s: condition '%s' is false", originalName, cmdConfig.When)
+			return fmt.Sprintf("Command '%s' skipped: condition '%s' is false", originalName, cmdConfig.When), nil
+		}
+	}
+
 	// Validate arguments if defined
 	if len(cmdConfig.Arguments) > 0 {
 		if err := cmdConfig.ValidateArgs(args); err != nil {
@@ -713,6 +1060,17 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		}
 	}
 
+	// Replace artifact references from earlier runs with the artifacts' paths
+	for argName, value := range args {
+		if ref, ok := value.(string); ok {
+			path, err := artifacts.ResolveRef(ref)
+			if err != nil {
+				return "", fmt.Errorf("argument '%s': %w", argName, err)
+			}
+			args[argName] = path
+		}
+	}
+
 	// Create a copy of the command string for substitution
 	processedCmd := cmdStr
 
@@ -742,29 +1100,30 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		var execPath string
 		for _, dir := range executableSearchPaths {
 			path := filepath.Join(dir, execName)
-			s.logInfo("Checking path: %s", path)
+			s.callInfo(ctx, "Checking path: %s", path)
 			if _, err := os.Stat(path); err == nil {
 				execPath = path
 				break
 			}
 		}
-		s.logInfo("Executable path: %s", execPath)
+		s.callInfo(ctx, "Executable path: %s", execPath)
 
 		if execPath == "" {
 			return "", fmt.Errorf("executable '%s' not found in search paths", execName)
 		}
 
-		// Reconstruct the command with the full path
+		// Reconstruct the command with the full path, quoted in case the
+		// search path contains spaces
 		if len(cmdArgs) > 0 {
-			processedCmd = fmt.Sprintf("%s %s", execPath, strings.Join(cmdArgs, " "))
+			processedCmd = fmt.Sprintf("%s %s", shell.Quote(execPath), strings.Join(cmdArgs, " "))
 		} else {
-			processedCmd = execPath
+			processedCmd = shell.Quote(execPath)
 		}
-		s.logInfo("Resolved executable command: %s", processedCmd)
+		s.callInfo(ctx, "Resolved executable command: %s", processedCmd)
 	}
 
 	// Check if command has a project context
-	var projectPathUsed string
+	var projectPathUsed, projectNameUsed string
 
 	// If project_path is provided, use it
 	if projectPath != "" {
@@ -778,18 +1137,34 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		} else {
 			projectPathUsed = projectPath
 		}
-		s.logInfo("Using provided project path for command %s: %s", originalName, projectPathUsed)
+		s.callInfo(ctx, "Using provided project path for command %s: %s", originalName, projectPathUsed)
+
+		// Name the project when the path belongs to a configured one
+		if cfg, err := settings.Load(); err == nil {
+			for name, project := range cfg.Projects {
+				if expanded, err := pathutil.Expand(project.Path); err == nil && filepath.Clean(expanded) == filepath.Clean(projectPathUsed) {
+					projectNameUsed = name
+					break
+				}
+			}
+		}
 	} else {
 		// If no project_path is provided, try to find the associated project
 		cfg, err := settings.Load()
-		if err == nil {
+		if err == nil && s.project != "" {
+			// A project server runs its commands in its project
+			projectPathUsed = cfg.Projects[s.project].Path
+			projectNameUsed = s.project
+			s.callInfo(ctx, "Using project %s of the server for command %s: %s", s.project, originalName, projectPathUsed)
+		} else if err == nil {
 			// Look through all projects to find if this command is associated with one
-			for _, project := range cfg.Projects {
+			for name, project := range cfg.Projects {
 				for _, cmd := range project.Commands {
 					if cmd.CommandName == originalName || cmd.Alias == originalName {
 						// Found the project this command belongs to
 						projectPathUsed = project.Path
-						s.logInfo("Found project binding for command %s: %s", originalName, projectPathUsed)
+						projectNameUsed = name
+						s.callInfo(ctx, "Found project binding for command %s: %s", originalName, projectPathUsed)
 						break
 					}
 				}
@@ -800,38 +1175,80 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		}
 	}
 
+	// Expand project references now that the project is resolved
+	if projectPathUsed != "" {
+		expandedPath, err := pathutil.Expand(projectPathUsed)
+		if err != nil {
+			expandedPath = projectPathUsed
+		}
+		processedCmd = settings.ExpandProjectVariables(processedCmd, projectNameUsed, expandedPath)
+	}
+
+	// Scripts run from a temporary file passed to their interpreter, which
+	// takes the place of cmd
+	script, scriptPath := cmdConfig.Script, ""
+	if script != "" {
+		if projectPathUsed != "" {
+			expandedPath, err := pathutil.Expand(projectPathUsed)
+			if err != nil {
+				expandedPath = projectPathUsed
+			}
+			script = settings.ExpandProjectVariables(script, projectNameUsed, expandedPath)
+		}
+		run, err := execution.PrepareScript(ctx, script, cmdConfig.Interpreter, cmdConfig.Dependencies)
+		if err != nil {
+			return "", err
+		}
+		scriptPath, err = run.Write(script)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(scriptPath)
+
+		var quoted []string
+		for _, word := range run.Command(scriptPath) {
+			quoted = append(quoted, shell.Quote(word))
+		}
+		processedCmd = strings.Join(quoted, " ")
+	}
+
 	// Create a slice for arguments that use prefixes
 	var prefixedArgs []string
 	// Create a slice for positional arguments (no prefix)
 	var positionalArgs []string
+	// Values of the ${name} placeholders in the command, quoted when substituted
+	placeholders := make(map[string]string)
+
+	// Temporary files holding the values of from_file arguments
+	var files argfile.Files
+	defer files.Remove()
+	// The files and the values they hold, which identify the run in the
+	// result cache instead of the random paths
+	var fromFiles []fileArgument
+
+	// Check the values against their types, using defaults for the rest
+	values, err := cmdConfig.ArgumentValues(args)
+	if err != nil {
+		return "", fmt.Errorf("argument validation failed: %w", err)
+	}
 
 	// Process arguments in the order they are defined
 	for _, argDef := range cmdConfig.Arguments {
-		// Get the value (using default if not provided)
-		value, err := cmdConfig.GetArgumentValue(argDef.Name, args)
-		if err != nil {
-			return "", fmt.Errorf("error getting argument value: %w", err)
-		}
-
-		// If the value is nil (not provided and no default), skip
-		if value == nil {
+		// Skip arguments that weren't provided and have no default
+		valueStr, ok := values[argDef.Name]
+		if !ok {
 			continue
 		}
 		logging.Message("Processing argument: %s", argDef.Name)
 
-		// Convert value to string based on type
-		var valueStr string
-		switch argDef.Type {
-		case settings.ArgumentTypeBool:
-			if boolVal, ok := value.(bool); ok {
-				valueStr = fmt.Sprintf("%v", boolVal)
-			} else {
-				valueStr = fmt.Sprintf("%v", value)
+		// Hand the content over as the path of a file holding it
+		if argDef.FromFile {
+			path, err := files.Write(argDef.Name, valueStr)
+			if err != nil {
+				return "", err
 			}
-		case settings.ArgumentTypeNumber:
-			valueStr = fmt.Sprintf("%v", value)
-		default: // string or any other type
-			valueStr = fmt.Sprintf("%v", value)
+			fromFiles = append(fromFiles, fileArgument{path: path, value: valueStr})
+			valueStr = path
 		}
 
 		// Check if this argument has a prefix
@@ -845,18 +1262,18 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 				}
 			} else {
 				// For other types, add both prefix and value
-				prefixedArgs = append(prefixedArgs, fmt.Sprintf("%s %s", argDef.Prefix, valueStr))
+				prefixedArgs = append(prefixedArgs, fmt.Sprintf("%s %s", argDef.Prefix, shell.Quote(valueStr)))
 			}
 		} else {
 			// For non-prefixed arguments, first try placeholder replacement
 			placeholder := "${" + argDef.Name + "}"
 			if strings.Contains(processedCmd, placeholder) {
 				// If the command contains a placeholder, replace it
-				processedCmd = strings.ReplaceAll(processedCmd, placeholder, valueStr)
+				placeholders[argDef.Name] = valueStr
 				logging.Message("Replaced placeholder %s with value: %s", placeholder, valueStr)
 			} else {
 				// If no placeholder, treat as positional argument
-				positionalArgs = append(positionalArgs, valueStr)
+				positionalArgs = append(positionalArgs, shell.Quote(valueStr))
 				logging.Message("Added positional argument: %s", valueStr)
 			}
 		}
@@ -877,10 +1294,9 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		}
 
 		// Replace the placeholder with the value
-		placeholder := "${" + key + "}"
-		valueStr := fmt.Sprintf("%v", value)
-		processedCmd = strings.ReplaceAll(processedCmd, placeholder, valueStr)
+		placeholders[key] = fmt.Sprintf("%v", value)
 	}
+	processedCmd = shell.ExpandPlaceholders(processedCmd, placeholders)
 
 	// Combine command parts: base command + positional args + prefixed args
 	if len(positionalArgs) > 0 {
@@ -890,74 +1306,155 @@ func (s *MCPLibServer) executeCommandWithPath(name, cmdStr string, args map[stri
 		processedCmd = fmt.Sprintf("%s %s", processedCmd, strings.Join(prefixedArgs, " "))
 	}
 
-	s.logInfo("Executing command: %s (%s)", originalName, processedCmd)
+	s.callInfo(ctx, "Executing command: %s (%s)", originalName, processedCmd)
 
 	// Track execution time
 	startTime := time.Now()
 
-	// Create a temporary file for output
-	tmpDir, err := os.MkdirTemp(s.configDir, "cmd-output-*")
+	// Run the command through sh in the project directory, if any
+	cmd := &execution.Command{Path: "sh", Args: []string{"-c", processedCmd}}
+	limits, err := execution.LimitsFor(cmdConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+		return "", fmt.Errorf("command '%s' has invalid resource limits: %w", originalName, err)
+	}
+	cmd.Limits = limits
+	if projectPathUsed != "" {
+		dir, err := pathutil.Expand(projectPathUsed)
+		if err != nil {
+			dir = projectPathUsed
+		}
+		cmd.Dir = dir
+		s.callInfo(ctx, "Running command in project directory: %s", dir)
 	}
-	defer os.RemoveAll(tmpDir)
 
-	outputFile := filepath.Join(tmpDir, "output.txt")
-	outFile, err := os.Create(outputFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to create output file: %w", err)
+	// Run with the interop env over the part of the server's environment
+	// the env policy passes on. Scripts in the executables directories can
+	// be called by name. It's set up before a cached result is returned, so
+	// recorded sessions redact its secrets from cached results too.
+	if cfg, err := settings.Load(); err == nil {
+		policy, allowlist := cfg.EnvPolicyFor(s.name, cmdConfig)
+		if policy != settings.EnvPolicyInherit {
+			s.callInfo(ctx, "Running command %s with the %s env policy", originalName, policy)
+		}
+		if cmd.Env, err = settings.MergeEnvironmentWithPolicy(cfg, originalName, projectNameUsed, policy, allowlist); err != nil {
+			return "", fmt.Errorf("failed to set up the environment of command '%s': %w", originalName, err)
+		}
+		cmd.Clean = true
+		if s.recorder != nil {
+			s.recorder.addCommandEnv(cmd.Env, settings.StoredSecretVariables(cfg, originalName, projectNameUsed))
+		}
 	}
-	defer outFile.Close()
 
-	// Prepare the command based on project context
-	var executeCmd string
-	if projectPathUsed != "" {
-		// If project path is provided, add directory change before and after
-		executeCmd = fmt.Sprintf("cd %s && %s && cd -", projectPathUsed, processedCmd)
-		s.logInfo("Running command in project directory: %s", projectPathUsed)
-	} else {
-		executeCmd = processedCmd
+	// Reuse the result of an identical run while the project's git state is unchanged
+	cacheCmd := processedCmd
+	if scriptPath != "" {
+		// Identify the run by the script rather than its temporary file
+		cacheCmd = strings.ReplaceAll(processedCmd, shell.Quote(scriptPath), shell.Quote(script))
+	}
+	cached := s.resultCache(originalName, cacheCmd, cmd.Dir, fromFiles, cmd.Env, cmdConfig, noCache)
+	if entry, ok := cached.get(); ok {
+		s.callInfo(ctx, "Returning cached result of command %s from %s", originalName, entry.Created.Format(time.RFC3339))
+		s.recordExecution(executionRecord{
+			Tool:          name,
+			Command:       originalName,
+			Project:       projectNameUsed,
+			CorrelationID: logging.CorrelationID(ctx),
+			Started:       startTime,
+			Status:        "cached",
+		})
+		return entry.Output + cachedNote(entry), nil
 	}
 
-	// Execute command
-	cmd := exec.Command("sh", "-c", executeCmd)
-	cmd.Stdout = outFile
-	cmd.Stderr = outFile
+	// Commands can tag their own logs and outputs with the call's ID
+	cmd.Env = append(cmd.Env, logging.CorrelationEnvVar+"="+logging.CorrelationID(ctx))
 
-	// Add timeout context to prevent hanging commands
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+	// Give the run an artifacts directory, referenced by run ID in the result
+	run, runErr := artifacts.NewRun(originalName)
+	if runErr != nil {
+		s.callInfo(ctx, "Artifacts are unavailable for command %s: %v", originalName, runErr)
+	} else {
+		cmd.Env = append(cmd.Env, run.Env())
+	}
 
-	// Use CommandContext instead of Command for timeout support
-	cmd = exec.CommandContext(ctx, "sh", "-c", executeCmd)
-	cmd.Stdout = outFile
-	cmd.Stderr = outFile
+	// Wait for an execution slot when the number of concurrent runs is
+	// limited, giving up when the call is canceled or its timeout is over
+	if cfg, err := settings.Load(); err == nil && cfg.MaxConcurrentExecutions > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		slot, err := jobs.Acquire(waitCtx, cfg.MaxConcurrentExecutions, originalName)
+		cancel()
+		if err != nil {
+			return "", fmt.Errorf("failed to get an execution slot for command '%s': %w", originalName, err)
+		}
+		defer slot.Release()
+	}
 
-	err = cmd.Run()
+	// Time out to prevent hanging commands, and stop when the call is canceled
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	output, err := execution.NewExecutor().CombinedOutput(runCtx, cmd)
+	var exitErr *exec.ExitError
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		err = fmt.Errorf("timed out after %s", formatTimeout(timeout))
+	} else if ctx.Err() != nil {
+		err = fmt.Errorf("the call was canceled: %w", ctx.Err())
+	} else if errors.As(err, &exitErr) {
+		// Report the exit status alone, the command line is in the log
+		err = exitErr
+	}
 	executionTime := time.Since(startTime)
-
+	artifactSummary := s.finishArtifacts(ctx, run)
+
+	record := executionRecord{
+		Tool:          name,
+		Command:       originalName,
+		Project:       projectNameUsed,
+		CorrelationID: logging.CorrelationID(ctx),
+		Started:       startTime,
+		DurationMs:    executionTime.Milliseconds(),
+		Status:        "ok",
+	}
 	if err != nil {
-		// Still read output even if command failed
-		outFile.Seek(0, 0)
-		output, _ := os.ReadFile(outputFile)
-
-		s.logInfo("Command %s failed after %v: %v", originalName, executionTime, err)
-		// Make sure to sanitize the output to remove any ANSI color codes
-		return sanitizeOutput(fmt.Sprintf("Command failed: %v\nOutput:\n%s", err, string(output))), err
+		record.Status, record.Error = "failed", termtext.Truncate(termtext.Strip(err.Error()), maxHistoryErrorBytes)
 	}
+	s.recordExecution(record)
 
-	// Read command output
-	outFile.Seek(0, 0)
-	output, err := os.ReadFile(outputFile)
 	if err != nil {
-		s.logInfo("Command %s completed after %v but failed to read output: %v", originalName, executionTime, err)
-		return "", fmt.Errorf("failed to read command output: %w", err)
+		s.callInfo(ctx, "Command %s failed after %v: %v", originalName, executionTime, err)
+		// Make sure to sanitize the output to remove any ANSI color codes
+		return sanitizeOutput(fmt.Sprintf("Command failed: %v\nOutput:\n%s%s", err, string(output), artifactSummary)), err
 	}
 
-	s.logInfo("Command %s completed successfully after %v (output length: %d bytes)", originalName, executionTime, len(output))
+	s.callInfo(ctx, "Command %s completed successfully after %v (output length: %d bytes)", originalName, executionTime, len(output))
+
+	result := sanitizeOutput(string(output) + artifactSummary)
+	if err := cached.put(result); err != nil {
+		s.callWarning(ctx, "Failed to cache the result of command %s: %v", originalName, err)
+	}
 
 	// Return sanitized output
-	return sanitizeOutput(string(output)), nil
+	return result, nil
+}
+
+// finishArtifacts collects the artifacts of a tool run and returns a summary to
+// append to the tool output, empty when nothing was written
+func (s *MCPLibServer) finishArtifacts(ctx context.Context, run *artifacts.Run) string {
+	if run == nil {
+		return ""
+	}
+
+	keep := artifacts.DefaultKeep
+	if cfg, err := settings.Load(); err == nil {
+		keep = artifacts.Keep(cfg.Artifacts)
+	}
+	files, err := run.Finish(keep)
+	if err != nil {
+		s.callInfo(ctx, "Failed to collect artifacts of run %s: %v", run.ID, err)
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	s.callInfo(ctx, "Saved %d artifact(s) to run %s", len(files), run.ID)
+	return "\n\n" + run.Summary() + "Pass a reference as an argument to another command instead of the file's content or path, or read it with the read_artifact tool.\n"
 }
 
 // Start starts the MCP server in either stdio or SSE mode
@@ -985,9 +1482,10 @@ func (s *MCPLibServer) Start() error {
 // Stop stops the MCP server
 func (s *MCPLibServer) Stop() error {
 	s.logInfo("Stopping MCP server")
+	s.stopping.Store(true)
 
 	// Restore stdout before closing the log file (only if we redirected it)
-	if s.serverMode != "stdio" {
+	if s.serverMode != "stdio" && s.logFile != nil {
 		os.Stdout = os.Stderr
 	}
 
@@ -995,6 +1493,9 @@ func (s *MCPLibServer) Stop() error {
 	if s.logFile != nil {
 		s.logFile.Close()
 	}
+	if s.recorder != nil {
+		s.recorder.Close()
+	}
 
 	if s.serverMode == "sse" && s.httpServer != nil {
 		// Gracefully shutdown the HTTP server
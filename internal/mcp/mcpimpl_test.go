@@ -1,8 +1,20 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"interop/internal/jobs"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func TestFormatToolOutput(t *testing.T) {
@@ -55,7 +67,7 @@ func TestFormatToolOutput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatToolOutput(tt.input)
+			result := formatToolOutput(tt.input, true)
 
 			// Parse the result to verify it's valid JSON
 			var parsed ToolOutput
@@ -90,7 +102,7 @@ func TestFormatToolOutput_ValidJSON(t *testing.T) {
 	}
 
 	for _, input := range inputs {
-		result := formatToolOutput(input)
+		result := formatToolOutput(input, true)
 
 		// Verify it's valid JSON
 		var parsed interface{}
@@ -99,3 +111,513 @@ func TestFormatToolOutput_ValidJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestFileArguments(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.review]
+cmd = "echo $(dirname ${diff}) > $HOME/dir.txt; cat ${diff}"
+arguments = [{ name = "diff", type = "string", required = true, from_file = true }]
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	diff := "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package old; echo $(id)\n+package main\n"
+	output, err := s.runCommandTool(context.Background(), "review", cfg.Commands["review"], map[string]interface{}{"diff": diff})
+	if err != nil {
+		t.Fatalf("runCommandTool() error = %v", err)
+	}
+	if !strings.Contains(output, diff) {
+		t.Errorf("output = %q, want the diff read back from the file", output)
+	}
+
+	dir, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), "dir.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(dir))); !os.IsNotExist(err) {
+		t.Errorf("the file argument's directory %s was not removed", dir)
+	}
+}
+
+func TestScriptCommands(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.greet]
+script = '''
+#!/bin/sh
+name="$1"
+echo "hello, $name"
+'''
+arguments = [{ name = "name", type = "string", required = true }]
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	output, err := s.runCommandTool(context.Background(), "greet", cfg.Commands["greet"], map[string]interface{}{"name": "O'Brien; id"})
+	if err != nil {
+		t.Fatalf("runCommandTool() error = %v", err)
+	}
+	if !strings.Contains(output, "hello, O'Brien; id") {
+		t.Errorf("output = %q, want the greeting with the literal name", output)
+	}
+}
+
+func TestToolTimeout(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+max_tool_timeout = "10m"
+
+[commands.slow]
+cmd = "sleep 5"
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	tests := []struct {
+		value   interface{}
+		want    time.Duration
+		wantErr string
+	}{
+		{nil, 5 * time.Minute, ""},
+		{"90s", 90 * time.Second, ""},
+		{"2", 2 * time.Second, ""},
+		{float64(30), 30 * time.Second, ""},
+		{"10m", 10 * time.Minute, ""},
+		{"11m", 0, "longer than the server's limit of 10m"},
+		{"-1s", 0, "must be positive"},
+		{"soon", 0, "invalid timeout 'soon'"},
+	}
+	for _, tt := range tests {
+		got, err := s.toolTimeout(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("toolTimeout(%v) error = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("toolTimeout(%v) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	started := time.Now()
+	_, err = s.runCommandTool(context.Background(), "slow", cfg.Commands["slow"], map[string]interface{}{"timeout": "200ms"})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Errorf("runCommandTool() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > 4*time.Second {
+		t.Errorf("runCommandTool() took %v, want it stopped at the timeout", elapsed)
+	}
+
+	// A canceled call stops its command without waiting for the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	started = time.Now()
+	_, err = s.runCommandTool(ctx, "slow", cfg.Commands["slow"], map[string]interface{}{"timeout": "1m"})
+	if err == nil || !strings.Contains(err.Error(), "the call was canceled") {
+		t.Errorf("runCommandTool() error = %v, want a canceled call", err)
+	}
+	if elapsed := time.Since(started); elapsed > 4*time.Second {
+		t.Errorf("runCommandTool() took %v, want it stopped when the call was canceled", elapsed)
+	}
+}
+
+func TestQueuedToolCallGivesUp(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+max_concurrent_executions = 1
+
+[commands.quick]
+cmd = "echo done"
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	// Another execution holds the only slot
+	slot, err := jobs.Acquire(context.Background(), 1, "other")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer slot.Release()
+
+	// The wait ends with the call's timeout
+	started := time.Now()
+	_, err = s.runCommandTool(context.Background(), "quick", cfg.Commands["quick"], map[string]interface{}{"timeout": "300ms"})
+	if err == nil || !strings.Contains(err.Error(), "gave up waiting for an execution slot") {
+		t.Errorf("runCommandTool() error = %v, want it to give up waiting", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("runCommandTool() waited %v, want it to stop at the timeout", elapsed)
+	}
+
+	// and when the call is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	started = time.Now()
+	if _, err = s.runCommandTool(ctx, "quick", cfg.Commands["quick"], nil); err == nil || !strings.Contains(err.Error(), "gave up waiting") {
+		t.Errorf("runCommandTool() error = %v, want it to give up waiting", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("runCommandTool() waited %v, want it to stop when the call is canceled", elapsed)
+	}
+}
+
+func TestToolDependencies(t *testing.T) {
+	env := testutil.New(t)
+	out := filepath.Join(env.Dir("out"), "order.txt")
+	env.WriteSettings(fmt.Sprintf(`
+[commands.generate]
+cmd = 'echo generate >> %[1]s'
+
+[commands.build]
+cmd = 'echo build >> %[1]s'
+depends_on = ["generate"]
+
+[commands.release]
+cmd = 'echo release >> %[1]s && echo released'
+depends_on = ["build", "generate"]
+
+[commands.broken]
+cmd = 'echo broken >> %[1]s'
+depends_on = ["fail"]
+
+[commands.fail]
+cmd = 'exit 3'
+`, out))
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	// Dependencies run first and once, their output before the command's
+	result, err := s.runCommandTool(context.Background(), "release", cfg.Commands["release"], nil)
+	if err != nil {
+		t.Fatalf("runCommandTool() error = %v", err)
+	}
+	data, _ := os.ReadFile(out)
+	if want := "generate\nbuild\nrelease\n"; string(data) != want {
+		t.Errorf("commands ran as %q, want %q", data, want)
+	}
+	if !strings.Contains(result, "Dependency generate:") || !strings.HasSuffix(strings.TrimSpace(result), "released") {
+		t.Errorf("runCommandTool() = %q, want the dependencies' output before the command's", result)
+	}
+
+	// A failing dependency stops the call
+	os.Remove(out)
+	_, err = s.runCommandTool(context.Background(), "broken", cfg.Commands["broken"], nil)
+	if err == nil || !strings.Contains(err.Error(), "dependency 'fail' of command 'broken' failed") {
+		t.Errorf("runCommandTool() error = %v, want the failed dependency", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("Expected the command not to run after its dependency failed")
+	}
+}
+
+func TestToolArgumentValues(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.deploy]
+cmd = 'echo "${env} ${replicas} ${dry_run}"'
+arguments = [
+  { name = "env", type = "string", required = true },
+  { name = "replicas", type = "number", default = 2 },
+  { name = "dry_run", type = "bool", default = false },
+]
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	// The same cases as the CLI's, see factory.TestRunArgumentValues
+	tests := []struct {
+		args    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{map[string]interface{}{"env": "prod"}, "prod 2 false", ""},
+		{map[string]interface{}{"env": "prod", "replicas": "3", "dry_run": "yes"}, "", "argument 'dry_run' must be true or false"},
+		{map[string]interface{}{"env": "staging", "dry_run": "1"}, "staging 2 true", ""},
+		{map[string]interface{}{}, "", "required argument 'env' is missing"},
+		{map[string]interface{}{"env": "prod", "replicas": "many"}, "", "argument 'replicas' must be a number"},
+	}
+	for _, tt := range tests {
+		output, err := s.runCommandTool(context.Background(), "deploy", cfg.Commands["deploy"], tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runCommandTool(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("runCommandTool(%v) error = %v", tt.args, err)
+			continue
+		}
+		if !strings.Contains(output, tt.want) {
+			t.Errorf("runCommandTool(%v) = %q, want %q", tt.args, output, tt.want)
+		}
+	}
+}
+
+func TestCommandTools(t *testing.T) {
+	commands := map[string]settings.CommandConfig{
+		"build":        {IsEnabled: true},
+		"team:deploy":  {IsEnabled: true},
+		"team:lint":    {IsEnabled: true},
+		"team_lint":    {IsEnabled: true},
+		"team:release": {IsEnabled: false},
+	}
+	cfg := &settings.Settings{Projects: map[string]settings.Project{
+		"api": {Commands: []settings.Alias{{CommandName: "team:deploy", Alias: "api-deploy"}}},
+	}}
+
+	tools := commandTools(cfg, commands, "")
+	want := map[string]string{
+		"build":       "build",
+		"team_deploy": "team:deploy",
+		"team_lint":   "team_lint",
+		"api-deploy":  "team:deploy",
+	}
+	if len(tools) != len(want) {
+		t.Errorf("commandTools() = %v, want %v", tools, want)
+	}
+	for tool, command := range want {
+		if tools[tool] != command {
+			t.Errorf("Tool %q runs %q, want %q", tool, tools[tool], command)
+		}
+	}
+
+	// A project server serves the commands bound to its project
+	cfg.Projects["web"] = settings.Project{Commands: []settings.Alias{{CommandName: "build", Alias: "web-build"}}}
+	cfg.MCPServers = map[string]settings.MCPServer{"api": {Name: "api", Project: "api"}}
+	tools = commandTools(cfg, commands, "api")
+	want = map[string]string{"team_deploy": "team:deploy", "api-deploy": "team:deploy"}
+	if len(tools) != len(want) {
+		t.Errorf("commandTools(api) = %v, want %v", tools, want)
+	}
+	for tool, command := range want {
+		if tools[tool] != command {
+			t.Errorf("Tool %q of server api runs %q, want %q", tool, tools[tool], command)
+		}
+	}
+
+	// A command assigned to several servers is served by each of them
+	commands["ship"] = settings.CommandConfig{IsEnabled: true, MCP: settings.ServerList{"ci", "ops"}}
+	for _, server := range []string{"ci", "ops"} {
+		if tools := commandTools(cfg, commands, server); tools["ship"] != "ship" {
+			t.Errorf("commandTools(%s) = %v, want ship", server, tools)
+		}
+	}
+	if tools := commandTools(cfg, commands, ""); tools["ship"] != "" {
+		t.Errorf("commandTools() = %v, want no ship on the default server", tools)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.trace]
+cmd = 'echo "id=$INTEROP_CORRELATION_ID"'
+is_enabled = true
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	ctx := logging.WithCorrelationID(context.Background(), "c0ffee")
+	output, err := s.runCommandTool(ctx, "trace", cfg.Commands["trace"], map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("runCommandTool() error = %v", err)
+	}
+	if !strings.Contains(output, "id=c0ffee") {
+		t.Errorf("output = %q, want the command to see the correlation ID", output)
+	}
+	if records := s.history; len(records) != 1 || records[0].CorrelationID != "c0ffee" {
+		t.Errorf("history = %+v, want one run with the correlation ID", records)
+	}
+
+	log, err := os.ReadFile(filepath.Join(env.ConfigDir, "mcp", "mcp-lib.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "[c0ffee] Executing command: trace") {
+		t.Errorf("log = %q, want the call's lines tagged", log)
+	}
+
+	result := withCorrelationID(ctx, mcp.NewToolResultText(output))
+	if result.Meta["correlation_id"] != "c0ffee" {
+		t.Errorf("result metadata = %v, want the correlation ID", result.Meta)
+	}
+}
+
+func TestEnvPolicy(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+env_policy = "allowlist"
+env_allowlist = ["AWS_*"]
+
+[env]
+GREETING = "hello"
+
+[commands.show]
+cmd = 'echo "greeting=$GREETING secret=$SECRET_TOKEN region=$AWS_REGION home=$HOME"'
+is_enabled = true
+
+[commands.inherit]
+cmd = 'echo "secret=$SECRET_TOKEN"'
+is_enabled = true
+env_policy = "inherit"
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	t.Setenv("SECRET_TOKEN", "s3cret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	output, err := s.runCommandTool(context.Background(), "show", cfg.Commands["show"], map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("runCommandTool() error = %v", err)
+	}
+	want := "greeting=hello secret= region=eu-west-1 home=" + os.Getenv("HOME")
+	if strings.TrimSpace(output) != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+
+	output, err = s.runCommandTool(context.Background(), "inherit", cfg.Commands["inherit"], map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("runCommandTool() error = %v", err)
+	}
+	if strings.TrimSpace(output) != "secret=s3cret" {
+		t.Errorf("output = %q, want the command's inherit policy to pass the server's environment on", output)
+	}
+}
+
+func TestProjectPathScope(t *testing.T) {
+	env := testutil.New(t)
+	app := env.Dir("projects/app")
+	other := env.Dir("projects/other")
+	env.WriteSettings(`
+[projects.app]
+path = "~/projects/app"
+
+[commands.pinned]
+cmd = "pwd"
+allow_project_path = false
+
+[commands.scoped]
+cmd = "pwd"
+allowed_projects = ["app"]
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	tools := registeredTools(t, s)
+	if !strings.Contains(tools, "  pinned(args, timeout)\n") || !strings.Contains(tools, "  scoped(args, project_path, timeout)") {
+		t.Errorf("Expected project_path only on scoped, got:\n%s", tools)
+	}
+
+	ctx := context.Background()
+	caller := serverCaller{s}
+	tests := []struct {
+		tool string
+		path string
+		want string
+	}{
+		{"scoped", app, app},
+		{"scoped", other, `"reason":"not_allowed"`},
+		{"pinned", app, `"reason":"disabled"`},
+	}
+	for _, tt := range tests {
+		result, err := caller.CallTool(ctx, tt.tool, map[string]interface{}{"project_path": tt.path})
+		if err != nil {
+			t.Fatalf("CallTool(%s) error = %v", tt.tool, err)
+		}
+		if text := resultText(result); !strings.Contains(text, tt.want) {
+			t.Errorf("CallTool(%s, %s) = %s, want %s", tt.tool, tt.path, text, tt.want)
+		}
+	}
+}
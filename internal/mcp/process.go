@@ -0,0 +1,267 @@
+package mcp
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Daemon command line flags identifying a daemon in process listings, see
+// DaemonArgs
+const (
+	daemonServerFlag = "--server"
+	daemonAppDirFlag = "--app-dir"
+)
+
+// States of the daemons reported by Reconcile
+const (
+	ProcessRunning = "running" // Tracked by its server's PID file
+	ProcessAdopted = "adopted" // Was untracked, its server's PID file now points to it
+	ProcessOrphan  = "orphan"  // Untracked and its server is tracked by another daemon, unknown or gone
+	ProcessKilled  = "killed"  // An orphan terminated by Reconcile
+	ProcessStale   = "stale"   // A PID file whose process is gone or reused, now removed
+)
+
+// DaemonProcess is a running interop MCP daemon found in the process list
+type DaemonProcess struct {
+	PID     int
+	Started string // Start time of the process, telling it apart from a later one reusing its PID
+	Server  string // Server key from the command line, empty for daemons started by older versions
+	AppDir  string // Configuration directory from the command line, empty when unknown
+}
+
+// ProcessEntry is the reconciled state of a daemon or PID file
+type ProcessEntry struct {
+	Server  string `json:"server"`
+	PID     int    `json:"pid"`
+	Started string `json:"started,omitempty"`
+	State   string `json:"state"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// DaemonArgs returns the command line arguments starting the daemon of the
+// server with key. The server and configuration directory are recorded on the
+// command line so ListDaemons can tell which daemons belong to which state,
+// even when their PID files are gone.
+func DaemonArgs(key, appDir string) []string {
+	return []string{"mcp", "daemon", daemonServerFlag, key, daemonAppDirFlag, appDir}
+}
+
+// parseDaemonCommand returns the server and configuration directory of a
+// daemon command line, ok is false for other processes. Daemons are started
+// with the absolute path of the executable, which tells them apart from e.g.
+// a grep for "mcp daemon". The configuration directory comes last so it may
+// contain spaces.
+func parseDaemonCommand(args string) (server, appDir string, ok bool) {
+	index := strings.Index(args, " mcp daemon")
+	if index < 0 || !filepath.IsAbs(args[:index]) {
+		return "", "", false
+	}
+	rest := args[index+len(" mcp daemon"):]
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false
+	}
+	rest = strings.TrimSpace(rest)
+
+	if value, ok := strings.CutPrefix(rest, daemonServerFlag+" "); ok {
+		server, rest, _ = strings.Cut(value, " ")
+	}
+	if value, ok := strings.CutPrefix(rest, daemonAppDirFlag+" "); ok {
+		appDir = value
+	}
+	return server, appDir, true
+}
+
+// parseProcessLine parses a line of "ps -o pid=,lstart=,args=" output, where
+// the start time takes five fields like "Fri Oct 16 07:30:38 2026"
+func parseProcessLine(line string) (pid int, started, args string, ok bool) {
+	rest := strings.TrimSpace(line)
+	var fields []string
+	for len(fields) < 6 {
+		var field string
+		field, rest, _ = strings.Cut(rest, " ")
+		if field == "" {
+			return 0, "", "", false
+		}
+		fields = append(fields, field)
+		rest = strings.TrimLeft(rest, " ")
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", "", false
+	}
+	return pid, strings.Join(fields[1:], " "), rest, true
+}
+
+// ListDaemons returns the interop MCP daemons running on this machine
+func ListDaemons() ([]DaemonProcess, error) {
+	lines, err := processList()
+	if err != nil {
+		return nil, err
+	}
+
+	var daemons []DaemonProcess
+	for _, line := range lines {
+		pid, started, args, ok := parseProcessLine(line)
+		if !ok || pid == os.Getpid() {
+			continue
+		}
+		if server, appDir, ok := parseDaemonCommand(args); ok {
+			daemons = append(daemons, DaemonProcess{PID: pid, Started: started, Server: server, AppDir: appDir})
+		}
+	}
+	return daemons, nil
+}
+
+// Reconcile compares the running daemons of this configuration with the PID
+// files of its servers. PID files of gone or reused processes are removed,
+// an untracked daemon becomes tracked again when its server has no running
+// daemon, and the remaining orphans are terminated when killOrphans is set.
+// Daemons started by older versions don't record their configuration and are
+// reported as orphans of any configuration.
+func (m *ServerManager) Reconcile(killOrphans bool) ([]ProcessEntry, error) {
+	if settings.Sandboxed() {
+		return nil, fmt.Errorf("sandboxed servers run in the foreground and keep no PID files")
+	}
+	appDir, err := settings.GetAppDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config directory: %w", err)
+	}
+	daemons, err := ListDaemons()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ProcessEntry
+	tracked := make(map[int]bool)
+	keys := make([]string, 0, len(m.Servers))
+	for key := range m.Servers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		server := m.Servers[key]
+		pid, started, err := server.readPidFile()
+		if err != nil {
+			continue
+		}
+		entry := ProcessEntry{Server: key, PID: pid, Started: started, State: ProcessRunning}
+		if err := verifyProcess(pid, started); err != nil {
+			entry.State, entry.Detail = ProcessStale, err.Error()
+			os.Remove(server.PidFile)
+		} else {
+			tracked[pid] = true
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, daemon := range daemons {
+		if tracked[daemon.PID] || (daemon.AppDir != "" && daemon.AppDir != appDir) {
+			continue
+		}
+		entry := ProcessEntry{Server: daemon.Server, PID: daemon.PID, Started: daemon.Started, State: ProcessOrphan}
+		server, exists := m.Servers[daemon.Server]
+		switch {
+		case daemon.Server == "":
+			entry.Server, entry.Detail = "?", "started by an older version, its server is unknown"
+		case !exists:
+			entry.Detail = "the server is no longer configured"
+		case server.IsRunning():
+			entry.Detail = "the server is tracked by another daemon"
+		default:
+			if err := server.writePidFile(daemon.PID); err != nil {
+				entry.Detail = fmt.Sprintf("failed to track it again: %v", err)
+				break
+			}
+			entry.State = ProcessAdopted
+			tracked[daemon.PID] = true
+		}
+
+		if entry.State == ProcessOrphan && killOrphans {
+			if err := signalProcess(daemon.PID, daemon.Started, syscall.SIGTERM); err != nil {
+				entry.Detail = fmt.Sprintf("failed to terminate it: %v", err)
+			} else {
+				entry.State = ProcessKilled
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// verifyProcess checks that pid is alive and, when started is known, is still
+// the process that started then rather than a later one reusing its PID
+func verifyProcess(pid int, started string) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("process %d not found: %w", pid, err)
+	}
+	if err := process.Signal(syscall.Signal(0)); err != nil {
+		return fmt.Errorf("process %d is not running", pid)
+	}
+	if started == "" {
+		return nil
+	}
+	current, err := processStartTime(pid)
+	if err != nil {
+		return fmt.Errorf("failed to check process %d: %w", pid, err)
+	}
+	if current != "" && current != started {
+		return fmt.Errorf("PID %d now belongs to another process", pid)
+	}
+	return nil
+}
+
+// signalProcess sends sig to pid after verifying it's the process that
+// started at started
+func signalProcess(pid int, started string, sig os.Signal) error {
+	if err := verifyProcess(pid, started); err != nil {
+		return err
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
+
+// FormatProcesses returns the text listing of the entries of Reconcile
+func FormatProcesses(entries []ProcessEntry) string {
+	if len(entries) == 0 {
+		return "No MCP daemons found."
+	}
+
+	result := "MCP Daemons:\n"
+	result += "=====================\n"
+	for _, entry := range entries {
+		result += fmt.Sprintf("\n[%s] PID %d: %s\n", entry.Server, entry.PID, processStateText(entry.State))
+		if entry.Started != "" {
+			result += fmt.Sprintf("Started: %s\n", entry.Started)
+		}
+		if entry.Detail != "" {
+			result += entry.Detail + "\n"
+		}
+	}
+	return result
+}
+
+// processStateText describes the state of a reconciled daemon or PID file
+func processStateText(state string) string {
+	switch state {
+	case ProcessAdopted:
+		return "running, tracked again"
+	case ProcessOrphan:
+		return "orphan, not tracked by its server"
+	case ProcessKilled:
+		return "orphan, terminated"
+	case ProcessStale:
+		return "stale PID file, removed"
+	}
+	return state
+}
@@ -0,0 +1,142 @@
+//go:build !windows
+
+package mcp
+
+import (
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDaemonCommand(t *testing.T) {
+	tests := []struct {
+		args   string
+		server string
+		appDir string
+		ok     bool
+	}{
+		{"/usr/local/bin/interop mcp daemon --server api --app-dir /home/me/My Config/interop", "api", "/home/me/My Config/interop", true},
+		{"/usr/local/bin/interop mcp daemon", "", "", true},
+		{"/usr/local/bin/interop mcp daemonize", "", "", false},
+		{"grep mcp daemon", "", "", false},
+		{"/usr/local/bin/interop mcp start api", "", "", false},
+	}
+	for _, tt := range tests {
+		server, appDir, ok := parseDaemonCommand(tt.args)
+		if server != tt.server || appDir != tt.appDir || ok != tt.ok {
+			t.Errorf("parseDaemonCommand(%q) = %q, %q, %v; want %q, %q, %v", tt.args, server, appDir, ok, tt.server, tt.appDir, tt.ok)
+		}
+	}
+
+	pid, started, args, ok := parseProcessLine("  4242 Fri Oct 16 07:30:38 2026 /bin/interop mcp daemon")
+	if !ok || pid != 4242 || started != "Fri Oct 16 07:30:38 2026" || args != "/bin/interop mcp daemon" {
+		t.Errorf("parseProcessLine() = %d, %q, %q, %v", pid, started, args, ok)
+	}
+}
+
+func TestPidFileIdentity(t *testing.T) {
+	server := &Server{PidFile: filepath.Join(t.TempDir(), "default.pid")}
+	if err := server.writePidFile(os.Getpid()); err != nil {
+		t.Fatalf("writePidFile() error = %v", err)
+	}
+	if pid, err := server.getPid(); err != nil || pid != os.Getpid() {
+		t.Errorf("getPid() = %d, %v; want the test process", pid, err)
+	}
+
+	// A PID reused by another process isn't the server
+	reused := strconv.Itoa(os.Getpid()) + "\nMon Jan  1 00:00:00 2001\n"
+	if err := os.WriteFile(server.PidFile, []byte(reused), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.getPid(); err == nil || !strings.Contains(err.Error(), "belongs to another process") {
+		t.Errorf("getPid() with a reused PID error = %v", err)
+	}
+	if err := server.Stop(); err == nil {
+		t.Error("Stop() signaled a process reusing the server's PID")
+	}
+	if _, err := os.Stat(server.PidFile); !os.IsNotExist(err) {
+		t.Error("Stop() kept the stale PID file")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+mcp_port = 18092
+
+[mcp_servers.api]
+name = "api"
+description = "API tools"
+port = 18093
+`)
+	t.Setenv("MCP_SERVER_MODE", "")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	appDir, err := settings.GetAppDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := NewServerManager()
+	if err != nil {
+		t.Fatalf("NewServerManager() error = %v", err)
+	}
+
+	// Daemons whose PID files are lost, and a stale PID file of a gone process
+	script := filepath.Join(t.TempDir(), "interop")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nsleep 10 &\nwait\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	startDaemon := func() *exec.Cmd {
+		cmd := exec.Command(script, DaemonArgs("default", appDir)...)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+		return cmd
+	}
+	first, second := startDaemon(), startDaemon()
+	gone := exec.Command("true")
+	if err := gone.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manager.Servers["api"].PidFile, []byte(strconv.Itoa(gone.ProcessState.Pid())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	entries, err := manager.Reconcile(true)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	states := map[int]string{}
+	for _, entry := range entries {
+		states[entry.PID] = entry.Server + " " + entry.State
+	}
+	want := map[int]string{
+		gone.ProcessState.Pid(): "api " + ProcessStale,
+		first.Process.Pid:       "default " + ProcessAdopted,
+		second.Process.Pid:      "default " + ProcessKilled,
+	}
+	for pid, state := range want {
+		if states[pid] != state {
+			t.Errorf("PID %d state = %q, want %q (entries %+v)", pid, states[pid], state, entries)
+		}
+	}
+
+	if pid, err := manager.Servers["default"].getPid(); err != nil || pid != first.Process.Pid {
+		t.Errorf("default server PID = %d, %v; want the adopted daemon %d", pid, err, first.Process.Pid)
+	}
+	if _, err := os.Stat(manager.Servers["api"].PidFile); !os.IsNotExist(err) {
+		t.Error("Reconcile() kept the stale PID file")
+	}
+	if err := second.Wait(); err == nil {
+		t.Error("The orphan daemon exited cleanly, want it terminated")
+	}
+}
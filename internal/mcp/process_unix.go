@@ -0,0 +1,42 @@
+//go:build !windows
+
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// psCommand runs ps with a fixed locale, keeping start times comparable
+func psCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("ps", args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd
+}
+
+// processStartTime returns the start time of the process with pid, empty when
+// it's not running
+func processStartTime(pid int) (string, error) {
+	output, err := psCommand("-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		// ps exits with 1 when no process matches
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to run ps: %w", err)
+	}
+	return strings.Join(strings.Fields(string(output)), " "), nil
+}
+
+// processList returns the pid, start time and command line of every process,
+// one line each
+func processList() ([]string, error) {
+	output, err := psCommand("-axo", "pid=,lstart=,args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return strings.Split(strings.TrimSpace(string(output)), "\n"), nil
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package mcp
+
+import "fmt"
+
+// processStartTime returns an empty start time, processes are identified by
+// PID alone on Windows
+func processStartTime(pid int) (string, error) {
+	return "", nil
+}
+
+// processList is not available on Windows
+func processList() ([]string, error) {
+	return nil, fmt.Errorf("listing MCP daemons is not supported on Windows")
+}
@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	pathutil "interop/internal/path"
+	"interop/internal/settings"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxHistoryEntries bounds the command runs a server remembers
+	maxHistoryEntries = 100
+	// defaultHistoryLimit is the number of runs project-info returns by default
+	defaultHistoryLimit = 10
+	// maxHistoryErrorBytes bounds the error of a remembered run
+	maxHistoryErrorBytes = 1024
+)
+
+// executionRecord is a command run made by this server
+type executionRecord struct {
+	Tool          string    `json:"tool"`
+	Command       string    `json:"command"`
+	Project       string    `json:"-"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Tags the call's lines in the server log
+	Started       time.Time `json:"started"`
+	DurationMs    int64     `json:"duration_ms"`
+	Status        string    `json:"status"` // ok, failed or cached
+	Error         string    `json:"error,omitempty"`
+}
+
+// projectInfo is the project-info representation of a project
+type projectInfo struct {
+	Name        string            `json:"name"`
+	Path        string            `json:"path"`
+	Description string            `json:"description,omitempty"`
+	EnvKeys     []string          `json:"env_keys"`
+	Commands    []projectCommand  `json:"commands"`
+	History     []executionRecord `json:"recent_runs"`
+}
+
+// projectCommand is a command bound to a project
+type projectCommand struct {
+	Command     string                     `json:"command"`
+	Alias       string                     `json:"alias,omitempty"`
+	Tool        string                     `json:"tool,omitempty"` // Empty when this server doesn't serve the command
+	Description string                     `json:"description,omitempty"`
+	Enabled     bool                       `json:"enabled"`
+	Arguments   []settings.CommandArgument `json:"arguments,omitempty"`
+}
+
+// recordExecution remembers a command run for project-info, dropping the
+// oldest runs beyond maxHistoryEntries
+func (s *MCPLibServer) recordExecution(record executionRecord) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	s.history = append(s.history, record)
+	if len(s.history) > maxHistoryEntries {
+		s.history = s.history[len(s.history)-maxHistoryEntries:]
+	}
+}
+
+// projectHistory returns up to limit runs made in a project, newest first
+func (s *MCPLibServer) projectHistory(project string, limit int) []executionRecord {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	records := []executionRecord{}
+	for i := len(s.history) - 1; i >= 0 && len(records) < limit; i-- {
+		if s.history[i].Project == project {
+			records = append(records, s.history[i])
+		}
+	}
+	return records
+}
+
+// registerProjectInfoTool registers the project-info tool describing a project
+// so clients can orient themselves before calling its commands
+func (s *MCPLibServer) registerProjectInfoTool() {
+	projectInfoTool := mcp.NewTool(
+		"project-info",
+		mcp.WithDescription("Describe a configured project: its path, env keys, bound commands with their arguments and the runs this server made in it recently"),
+		mcp.WithString("project", mcp.Description("Name of the project, or a path inside it"), mcp.Required()),
+		mcp.WithNumber("history", mcp.Description(fmt.Sprintf("Number of recent runs to include (default: %d)", defaultHistoryLimit))),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	s.mcpServer.AddTool(projectInfoTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		ref, _ := args["project"].(string)
+		limit := defaultHistoryLimit
+		if value, ok := args["history"].(float64); ok && value >= 0 {
+			limit = int(value)
+		}
+
+		cfg, err := settings.Load()
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to load settings: %v", err)), nil
+		}
+		name, ok := findProject(cfg, ref)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("No project named '%s' or containing that path", ref)), nil
+		}
+		project := cfg.Projects[name]
+
+		info := projectInfo{
+			Name:        name,
+			Path:        project.Path,
+			Description: project.Description,
+			EnvKeys:     []string{},
+			Commands:    []projectCommand{},
+			History:     s.projectHistory(name, limit),
+		}
+		for key := range project.Env {
+			info.EnvKeys = append(info.EnvKeys, key)
+		}
+		sort.Strings(info.EnvKeys)
+
+		for _, alias := range project.Commands {
+			cmd, exists := cfg.Commands[alias.CommandName]
+			if !exists {
+				continue
+			}
+			entry := projectCommand{
+				Command:     alias.CommandName,
+				Alias:       alias.Alias,
+				Description: cmd.Description,
+				Enabled:     cmd.IsEnabled,
+				Arguments:   cmd.Arguments,
+			}
+			for _, tool := range []string{alias.Alias, alias.CommandName} {
+				if tool != "" && s.toolCommands[tool] == alias.CommandName {
+					entry.Tool = tool
+					break
+				}
+			}
+			info.Commands = append(info.Commands, entry)
+		}
+
+		infoJSON, _ := json.MarshalIndent(info, "", "  ")
+		return mcp.NewToolResultText(formatToolOutput(string(infoJSON), s.isToolOutputJson)), nil
+	})
+
+	s.logInfo("Registered MCP project-info tool")
+}
+
+// findProject resolves a project name, or a path inside a project's directory
+func findProject(cfg *settings.Settings, ref string) (string, bool) {
+	if _, ok := cfg.Projects[ref]; ok {
+		return ref, true
+	}
+	if ref == "" {
+		return "", false
+	}
+
+	path, err := pathutil.Expand(ref)
+	if err != nil {
+		return "", false
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return cfg.ProjectContaining(path)
+}
@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"interop/internal/output"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRegistrationFixture(t *testing.T) {
+	env := testutil.New(t)
+	env.CopyFixtures(filepath.Join("testdata", "servers"))
+	env.Dir("projects/app")
+	// Stdio mode keeps the server from redirecting stdout to its log
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	var listing strings.Builder
+	for _, name := range []string{"", "domain"} {
+		t.Setenv("MCP_SERVER_NAME", name)
+		s, err := NewMCPLibServer()
+		if err != nil {
+			t.Fatalf("NewMCPLibServer() for server %q error = %v", name, err)
+		}
+		defer s.Stop()
+
+		if name == "" {
+			name = "default"
+		}
+		fmt.Fprintf(&listing, "%s:\n%s\n", name, registeredTools(t, s))
+	}
+	env.AssertGolden("registered_tools", listing.String())
+
+	manager, err := NewServerManager()
+	if err != nil {
+		t.Fatalf("NewServerManager() error = %v", err)
+	}
+	env.AssertGolden("list_servers", manager.ListMCPServers())
+
+	listings, err := manager.Listings()
+	if err != nil {
+		t.Fatalf("Listings() error = %v", err)
+	}
+	var structured strings.Builder
+	if err := output.Write(&structured, output.YAML, listings); err != nil {
+		t.Fatalf("output.Write() error = %v", err)
+	}
+	env.AssertGolden("list_servers_yaml", structured.String())
+}
+
+// registeredTools lists the tools a server answers tools/list with, one
+// "name(arguments)" line per tool
+func registeredTools(t *testing.T, s *MCPLibServer) string {
+	t.Helper()
+
+	response := s.mcpServer.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	raw, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to encode tools/list response: %v", err)
+	}
+	var decoded struct {
+		Result mcp.ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Failed to decode tools/list response %s: %v", raw, err)
+	}
+
+	var lines []string
+	for _, tool := range decoded.Result.Tools {
+		var args []string
+		for arg := range tool.InputSchema.Properties {
+			args = append(args, arg)
+		}
+		sort.Strings(args)
+		lines = append(lines, fmt.Sprintf("  %s(%s)", tool.Name, strings.Join(args, ", ")))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -3,10 +3,10 @@ package mcp
 import (
 	"fmt"
 	"interop/internal/logging"
+	"interop/internal/remote"
 	"interop/internal/settings"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -15,13 +15,21 @@ import (
 )
 
 // RemoteCommandLoader handles loading commands from remote repositories
-type RemoteCommandLoader struct{}
+type RemoteCommandLoader struct {
+	git remote.GitClient // nil selects the client of the configured git backend
+}
 
 // NewRemoteCommandLoader creates a new remote command loader
 func NewRemoteCommandLoader() *RemoteCommandLoader {
 	return &RemoteCommandLoader{}
 }
 
+// SetGitClient makes the loader clone repositories through client, such as a
+// remote.FakeGitClient in tests
+func (r *RemoteCommandLoader) SetGitClient(client remote.GitClient) {
+	r.git = client
+}
+
 // LoadCommandsFromRemote fetches commands from a remote repository and returns them
 // without persisting to disk
 func (r *RemoteCommandLoader) LoadCommandsFromRemote(repoURL string) (map[string]settings.CommandConfig, error) {
@@ -128,10 +136,17 @@ func (r *RemoteCommandLoader) cloneRepository(repoURL string) (string, error) {
 		return "", fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 
+	git := r.git
+	if git == nil {
+		if git, err = remote.NewGitClient(remote.ConfiguredGitBackend()); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
+	}
+
 	logging.Message("Cloning repository %s to %s", repoURL, tmpDir)
 
-	_, err = r.runGitCommand("", "clone", repoURL, tmpDir)
-	if err != nil {
+	if err := git.Clone(repoURL, tmpDir, remote.TransportOptions{}); err != nil {
 		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("failed to clone repository: %w", err)
 	}
@@ -257,16 +272,3 @@ func (r *RemoteCommandLoader) updateExecutablePaths(commands map[string]settings
 
 	return nil
 }
-
-// runGitCommand runs a git command in the specified directory
-func (r *RemoteCommandLoader) runGitCommand(dir string, args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-	cmd.Dir = dir
-
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("git command failed: %w", err)
-	}
-
-	return strings.TrimSpace(string(output)), nil
-}
@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"fmt"
+	"interop/internal/cache"
+	"interop/internal/settings"
+	"slices"
+	"strings"
+	"time"
+)
+
+// resultCache is where the result of a cacheable run is looked up and stored.
+// A nil resultCache caches nothing.
+type resultCache struct {
+	key     string
+	ttl     time.Duration
+	command string
+	commit  string
+}
+
+// fileArgument is a from_file argument, the temporary file holding its value
+type fileArgument struct {
+	path  string
+	value string
+}
+
+// resultCache returns the cache of a run of a command with caching enabled,
+// keyed by the command line, its environment, its directory and the
+// directory's git state. fromFiles are the from_file arguments in the
+// command line, their values identify the run rather than the random paths of
+// their files. It returns nil when the result can't
+// be cached: caching is off for the command or the server, noCache is set, or
+// the run has no git working tree.
+func (s *MCPLibServer) resultCache(name, processedCmd, dir string, fromFiles []fileArgument, env []string, cmdConfig settings.CommandConfig, noCache bool) *resultCache {
+	ttl, enabled, err := cmdConfig.CacheDuration()
+	if !enabled || err != nil || noCache || s.noCache {
+		return nil
+	}
+	if dir == "" {
+		s.logInfo("Not caching command %s: it runs outside a project", name)
+		return nil
+	}
+	state, err := cache.GitState(dir)
+	if err != nil {
+		s.logInfo("Not caching command %s: %v", name, err)
+		return nil
+	}
+
+	// Replace the paths by markers and key on the values in their place
+	var values []string
+	for i, file := range fromFiles {
+		values = append(values, file.value)
+		processedCmd = strings.ReplaceAll(processedCmd, file.path, fmt.Sprintf("<from_file %d>", i))
+	}
+	environment := slices.Sorted(slices.Values(env))
+	parts := []string{name, processedCmd, dir, state.Commit, state.Dirty, strings.Join(environment, "\x00")}
+	return &resultCache{
+		key:     cache.Key(append(parts, values...)...),
+		ttl:     ttl,
+		command: name,
+		commit:  state.Commit,
+	}
+}
+
+// get returns the cached result, if any
+func (c *resultCache) get() (*cache.Entry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return cache.Get(c.key)
+}
+
+// put caches the result of a successful run
+func (c *resultCache) put(result string) error {
+	if c == nil {
+		return nil
+	}
+	now := time.Now()
+	return cache.Put(c.key, cache.Entry{
+		Command: c.command,
+		Output:  result,
+		Commit:  c.commit,
+		Created: now,
+		Expires: now.Add(c.ttl),
+	})
+}
+
+// cachedNote tells clients a result comes from the cache
+func cachedNote(entry *cache.Entry) string {
+	commit := entry.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return fmt.Sprintf("\n\n(Cached result from %s at commit %s, the project is unchanged since. Pass no_cache to run the command again.)",
+		entry.Created.Format(time.RFC3339), commit)
+}
@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCachedCommandResults(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	env := testutil.New(t)
+	project := env.Dir("projects/app")
+	for _, args := range [][]string{{"init", "-q"}, {"commit", "-q", "--allow-empty", "-m", "initial"}} {
+		cmd := exec.Command("git", append([]string{"-C", project, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	// The command counts its runs outside the project, leaving its git state alone
+	env.WriteSettings(fmt.Sprintf(`
+[projects.app]
+path = %q
+commands = [{ command_name = "count" }]
+
+[commands.count]
+cmd = "echo run >> $HOME/runs.log; wc -l < $HOME/runs.log"
+cache = true
+`, project))
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	run := func(args map[string]interface{}) string {
+		t.Helper()
+		output, err := s.runCommandTool(context.Background(), "count", cfg.Commands["count"], args)
+		if err != nil {
+			t.Fatalf("runCommandTool() error = %v", err)
+		}
+		return output
+	}
+	runs := func(output string) string {
+		return strings.TrimSpace(strings.SplitN(output, "\n", 2)[0])
+	}
+
+	if output := run(nil); runs(output) != "1" || strings.Contains(output, "Cached result") {
+		t.Errorf("first run = %q, want a fresh result", output)
+	}
+	if output := run(nil); runs(output) != "1" || !strings.Contains(output, "Cached result") {
+		t.Errorf("second run = %q, want the cached result", output)
+	}
+	if output := run(map[string]interface{}{"no_cache": true}); runs(output) != "2" {
+		t.Errorf("run with no_cache = %q, want a fresh result", output)
+	}
+
+	// Uncommitted changes invalidate the cache
+	if err := os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if output := run(nil); runs(output) != "3" {
+		t.Errorf("run after a change = %q, want a fresh result", output)
+	}
+
+	s.noCache = true
+	if output := run(nil); runs(output) != "4" {
+		t.Errorf("run with the cache disabled = %q, want a fresh result", output)
+	}
+}
+
+func TestCachedResultsOfFileArgumentsAndEnv(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	env := testutil.New(t)
+	project := env.Dir("projects/app")
+	for _, args := range [][]string{{"init", "-q"}, {"commit", "-q", "--allow-empty", "-m", "initial"}} {
+		cmd := exec.Command("git", append([]string{"-C", project, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	writeSettings := func(stage string) {
+		env.WriteSettings(fmt.Sprintf(`
+[projects.app]
+path = %q
+commands = [{ command_name = "review" }]
+
+[commands.review]
+cmd = "echo run >> $HOME/runs.log; echo $STAGE; cat"
+arguments = [{ name = "diff", type = "string", required = true, from_file = true }]
+env = { STAGE = %q }
+cache = true
+`, project, stage))
+	}
+	writeSettings("test")
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+
+	run := func(diff string) string {
+		t.Helper()
+		output, err := s.runCommandTool(context.Background(), "review", cfg.Commands["review"], map[string]interface{}{"diff": diff})
+		if err != nil {
+			t.Fatalf("runCommandTool() error = %v", err)
+		}
+		return output
+	}
+
+	if output := run("+a"); strings.Contains(output, "Cached result") {
+		t.Errorf("first run = %q, want a fresh result", output)
+	}
+	if output := run("+a"); !strings.Contains(output, "Cached result") {
+		t.Errorf("run with the same file argument = %q, want the cached result", output)
+	}
+	if output := run("+b"); strings.Contains(output, "Cached result") {
+		t.Errorf("run with another file argument = %q, want a fresh result", output)
+	}
+
+	// Changing the command's env invalidates the cache
+	writeSettings("prod")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if output := run("+a"); strings.Contains(output, "Cached result") || !strings.Contains(output, "prod") {
+		t.Errorf("run after an env change = %q, want a fresh result", output)
+	}
+}
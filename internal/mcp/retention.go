@@ -0,0 +1,208 @@
+package mcp
+
+import (
+	"bytes"
+	"fmt"
+	"interop/internal/artifacts"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// retained is a log file or artifacts run subject to the retention policy
+type retained struct {
+	path     string
+	size     int64
+	modified time.Time
+	log      bool // Logs may be written to by a running server, so they are cleared rather than removed
+}
+
+// CleanResult reports what Clean removed or trimmed
+type CleanResult struct {
+	Runs  []string // IDs of the artifacts runs removed
+	Logs  []string // Log files cleared or trimmed
+	Freed int64    // Bytes freed
+	Kept  int64    // Bytes left in logs and artifacts
+}
+
+// String summarizes the result for printing
+func (r CleanResult) String() string {
+	if len(r.Runs) == 0 && len(r.Logs) == 0 {
+		return fmt.Sprintf("Nothing to clean, logs and artifacts use %s", artifacts.FormatSize(r.Kept))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Freed %s, logs and artifacts now use %s\n", artifacts.FormatSize(r.Freed), artifacts.FormatSize(r.Kept))
+	for _, id := range r.Runs {
+		fmt.Fprintf(&b, "  removed artifacts of run %s\n", id)
+	}
+	for _, log := range r.Logs {
+		fmt.Fprintf(&b, "  trimmed %s\n", log)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Clean applies the retention policy of cfg to the MCP server logs and the
+// artifacts of runs: artifacts untouched for longer than the maximum age are
+// removed and such logs cleared, then the oldest are removed, or trimmed to
+// their most recent lines for logs, until the total fits the maximum size.
+// With dryRun nothing is changed and the result tells what would be.
+func Clean(cfg settings.RetentionConfig, dryRun bool) (CleanResult, error) {
+	return cleanAt(cfg, time.Now(), dryRun)
+}
+
+func cleanAt(cfg settings.RetentionConfig, now time.Time, dryRun bool) (CleanResult, error) {
+	var result CleanResult
+	maxAge, maxSize, err := cfg.Limits()
+	if err != nil {
+		return result, err
+	}
+
+	items, err := retainedItems()
+	if err != nil {
+		return result, err
+	}
+	// Oldest first, so the size limit trims those first
+	sort.Slice(items, func(i, j int) bool { return items[i].modified.Before(items[j].modified) })
+
+	var total int64
+	for _, item := range items {
+		total += item.size
+	}
+
+	for _, item := range items {
+		keep := item.size
+		if now.Sub(item.modified) > maxAge {
+			keep = 0
+		} else if over := total - int64(maxSize); over > 0 {
+			keep = max(0, item.size-over)
+			if !item.log {
+				// Runs are removed as a whole
+				keep = 0
+			}
+		}
+		if keep == item.size {
+			continue
+		}
+
+		if !dryRun {
+			if keep, err = shrink(item, keep); err != nil {
+				return result, err
+			}
+		}
+		result.Freed += item.size - keep
+		total -= item.size - keep
+		if item.log {
+			result.Logs = append(result.Logs, item.path)
+		} else {
+			result.Runs = append(result.Runs, filepath.Base(item.path))
+		}
+	}
+	result.Kept = total
+	return result, nil
+}
+
+// enforceRetention cleans up logs and artifacts with the configured policy
+// before a server starts. Failures are only logged, they don't keep the
+// server from starting.
+func enforceRetention() {
+	cfg, err := settings.Load()
+	if err != nil {
+		return
+	}
+	result, err := Clean(cfg.MCPRetention, false)
+	if err != nil {
+		logging.Warning("Failed to clean up MCP logs and artifacts: %v", err)
+		return
+	}
+	if result.Freed > 0 {
+		logging.Message("Cleaned up MCP logs and artifacts, freed %s", artifacts.FormatSize(result.Freed))
+	}
+}
+
+// retainedItems returns the MCP server logs and the artifacts runs
+func retainedItems() ([]retained, error) {
+	var items []retained
+
+	runs, err := artifacts.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		item := retained{path: run.Dir, modified: run.Created}
+		for _, file := range run.Files {
+			item.size += file.Size
+		}
+		items = append(items, item)
+	}
+
+	if settings.Sandboxed() {
+		// Sandboxed servers log to the terminal
+		return items, nil
+	}
+	appDir, err := settings.GetAppDir()
+	if err != nil {
+		return nil, err
+	}
+	logs, err := filepath.Glob(filepath.Join(appDir, "mcp", "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		info, err := os.Stat(log)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		items = append(items, retained{path: log, size: info.Size(), modified: info.ModTime(), log: true})
+	}
+	return items, nil
+}
+
+// shrink reduces item to at most keep bytes and returns its new size:
+// artifacts runs are removed as a whole, logs keep their last lines
+func shrink(item retained, keep int64) (int64, error) {
+	if !item.log {
+		if err := os.RemoveAll(item.path); err != nil {
+			return 0, fmt.Errorf("failed to remove artifacts %s: %w", item.path, err)
+		}
+		return 0, nil
+	}
+	if keep == 0 {
+		if err := os.Truncate(item.path, 0); err != nil {
+			return 0, fmt.Errorf("failed to clear log %s: %w", item.path, err)
+		}
+		return 0, nil
+	}
+
+	file, err := os.OpenFile(item.path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open log %s: %w", item.path, err)
+	}
+	defer file.Close()
+
+	// Read the byte before the kept part too, to tell whether it starts at a
+	// full line
+	tail := make([]byte, keep+1)
+	if _, err := file.ReadAt(tail, item.size-keep-1); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to read log %s: %w", item.path, err)
+	}
+	if newline := bytes.IndexByte(tail, '\n'); newline >= 0 {
+		tail = tail[newline+1:]
+	} else {
+		tail = nil
+	}
+	// Servers append to their logs, so their next lines still follow the
+	// kept ones after the file is rewritten from the start
+	if err := file.Truncate(0); err != nil {
+		return 0, fmt.Errorf("failed to trim log %s: %w", item.path, err)
+	}
+	if _, err := file.WriteAt(tail, 0); err != nil {
+		return 0, fmt.Errorf("failed to trim log %s: %w", item.path, err)
+	}
+	return int64(len(tail)), nil
+}
@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"interop/internal/artifacts"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClean(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	now := time.Now()
+	newRun := func(name string, size int, age time.Duration) *artifacts.Run {
+		run, err := artifacts.NewRun(name)
+		if err != nil {
+			t.Fatalf("NewRun() error = %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(run.Dir, "out.txt"), []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Run IDs start with their creation time, so the age comes from a rename
+		old := filepath.Join(filepath.Dir(run.Dir), now.Add(-age).Format("20060102-150405.000")+"-"+name)
+		if err := os.Rename(run.Dir, old); err != nil {
+			t.Fatal(err)
+		}
+		return &artifacts.Run{ID: filepath.Base(old), Dir: old}
+	}
+	stale := newRun("stale", 100, 48*time.Hour)
+	older := newRun("older", 300, 2*time.Hour)
+	recent := newRun("recent", 300, time.Hour)
+
+	log := env.WriteFile("mcp/default.log", strings.Repeat("line\n", 20))
+	staleLog := env.WriteFile("mcp/old.log", "old\n")
+	os.Chtimes(log, now.Add(-3*time.Hour), now.Add(-3*time.Hour))
+	os.Chtimes(staleLog, now.Add(-72*time.Hour), now.Add(-72*time.Hour))
+
+	if _, err := cleanAt(settings.RetentionConfig{MaxAge: "soon"}, now, false); err == nil {
+		t.Error("Expected an error for an invalid max_age")
+	}
+
+	// Past max_age goes first, then the oldest is trimmed until the rest fits:
+	// the log keeps its last 50 bytes, which start at a line
+	retention := settings.RetentionConfig{MaxAge: "24h", MaxSize: "650B"}
+	dry, err := cleanAt(retention, now, true)
+	if err != nil {
+		t.Fatalf("cleanAt() dry run error = %v", err)
+	}
+	if _, err := os.Stat(stale.Dir); err != nil {
+		t.Error("Expected a dry run to keep the stale run")
+	}
+
+	result, err := cleanAt(retention, now, false)
+	if err != nil {
+		t.Fatalf("cleanAt() error = %v", err)
+	}
+	if dry.Freed != result.Freed || len(dry.Runs) != len(result.Runs) || len(dry.Logs) != len(result.Logs) {
+		t.Errorf("Dry run %+v differs from the clean %+v", dry, result)
+	}
+	if strings.Join(result.Runs, ",") != stale.ID {
+		t.Errorf("Removed runs = %v, want %s", result.Runs, stale.ID)
+	}
+	if content, _ := os.ReadFile(staleLog); len(content) != 0 {
+		t.Errorf("Expected the stale log to be cleared, got %q", content)
+	}
+	if content, _ := os.ReadFile(log); string(content) != strings.Repeat("line\n", 10) {
+		t.Errorf("Expected the log to keep its last 10 lines, got %q", content)
+	}
+	if result.Kept != 650 {
+		t.Errorf("Kept %d bytes, want 650", result.Kept)
+	}
+
+	// Runs are removed as a whole, oldest first
+	result, err = cleanAt(settings.RetentionConfig{MaxSize: "400B"}, now, false)
+	if err != nil {
+		t.Fatalf("cleanAt() error = %v", err)
+	}
+	if strings.Join(result.Runs, ",") != older.ID {
+		t.Errorf("Removed runs = %v, want %s", result.Runs, older.ID)
+	}
+	if _, err := os.Stat(recent.Dir); err != nil {
+		t.Errorf("Expected the recent run to be kept: %v", err)
+	}
+	if result.Kept > 400 {
+		t.Errorf("Kept %d bytes, want at most 400", result.Kept)
+	}
+}
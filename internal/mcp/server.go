@@ -2,19 +2,26 @@ package mcp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"interop/internal/logging"
+	"interop/internal/progress"
 	"interop/internal/settings"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// serverReadyTimeout bounds how long start waits for a daemon to accept connections
+const serverReadyTimeout = 10 * time.Second
+
 // Server represents the MCP server
 type Server struct {
 	PidFile string
@@ -27,6 +34,7 @@ type Server struct {
 // ServerManager manages multiple MCP servers
 type ServerManager struct {
 	Servers map[string]*Server // Map of server name to server instance
+	cfg     *settings.Settings // Settings the servers were created from
 }
 
 // NewServerManager creates a new MCP server manager
@@ -38,14 +46,17 @@ func NewServerManager() (*ServerManager, error) {
 
 	manager := &ServerManager{
 		Servers: make(map[string]*Server),
+		cfg:     cfg,
 	}
 
-	// Create default server
-	defaultServer, err := NewServer("", cfg.MCPPort)
-	if err != nil {
-		return nil, err
+	// Create default server, unless default_mcp_enabled = false
+	if cfg.DefaultMCPServerEnabled() {
+		defaultServer, err := NewServer("", cfg.MCPPort)
+		if err != nil {
+			return nil, err
+		}
+		manager.Servers["default"] = defaultServer
 	}
-	manager.Servers["default"] = defaultServer
 
 	// Create servers for each configured MCP server
 	for name, mcpServer := range cfg.MCPServers {
@@ -59,17 +70,39 @@ func NewServerManager() (*ServerManager, error) {
 	return manager, nil
 }
 
+// errDefaultServerDisabled is returned for the default server when
+// default_mcp_enabled = false
+var errDefaultServerDisabled = errors.New("the default MCP server is disabled (default_mcp_enabled = false), name one of mcp_servers instead")
+
+// server returns the server named name, the default server when name is
+// empty or "default"
+func (m *ServerManager) server(name string) (*Server, error) {
+	if name == "" {
+		name = "default"
+	}
+	server, exists := m.Servers[name]
+	if !exists {
+		if name == "default" && !m.cfg.DefaultMCPServerEnabled() {
+			return nil, errDefaultServerDisabled
+		}
+		return nil, fmt.Errorf("MCP server '%s' not found", name)
+	}
+	return server, nil
+}
+
 // NewServer creates a new MCP server instance with the given name and port
 func NewServer(name string, port int) (*Server, error) {
-	homeDir, err := os.UserHomeDir()
+	appDir, err := settings.GetAppDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, fmt.Errorf("failed to get config directory: %w", err)
 	}
 
-	// Create MCP directory if it doesn't exist
-	mcpDir := filepath.Join(homeDir, ".config", "interop", "mcp")
-	if err := os.MkdirAll(mcpDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create MCP directory: %w", err)
+	// Create MCP directory if it doesn't exist. Sandboxed servers keep no files.
+	mcpDir := filepath.Join(appDir, "mcp")
+	if !settings.Sandboxed() {
+		if err := os.MkdirAll(mcpDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create MCP directory: %w", err)
+		}
 	}
 
 	// Use name as prefix for files if not empty, otherwise use "default"
@@ -98,38 +131,92 @@ func NewServer(name string, port int) (*Server, error) {
 	}, nil
 }
 
-// Start launches the MCP server as a daemon
+// sandboxServers holds the servers started in sandbox mode, keyed by PID file
+// path. Nothing is written to disk, so this state lives only as long as the
+// process that started them.
+var (
+	sandboxMu      sync.Mutex
+	sandboxServers = make(map[string]*exec.Cmd)
+)
+
+// startSandboxed starts the server attached to the current process, with its
+// output on the terminal and its PID kept in memory
+func (s *Server) startSandboxed(cmd *exec.Cmd) error {
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start MCP server: %w", err)
+	}
+
+	sandboxMu.Lock()
+	sandboxServers[s.PidFile] = cmd
+	sandboxMu.Unlock()
+
+	logging.Message("MCP server started in the foreground with PID %d (sandbox mode)", cmd.Process.Pid)
+	return nil
+}
+
+// waitForSandboxServers blocks until all servers started in sandbox mode exit
+func waitForSandboxServers() error {
+	sandboxMu.Lock()
+	cmds := make([]*exec.Cmd, 0, len(sandboxServers))
+	for _, cmd := range sandboxServers {
+		cmds = append(cmds, cmd)
+	}
+	sandboxMu.Unlock()
+
+	var firstErr error
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	sandboxMu.Lock()
+	sandboxServers = make(map[string]*exec.Cmd)
+	sandboxMu.Unlock()
+	return firstErr
+}
+
+// finishStart keeps sandboxed servers in the foreground until they exit
+func finishStart(err error) error {
+	if err != nil || !settings.Sandboxed() {
+		return err
+	}
+	return waitForSandboxServers()
+}
+
+// Start launches the MCP server as a daemon, or in the foreground when sandboxed
 func (s *Server) Start() error {
+	serverType := "MCP server"
+	if s.Name != "" {
+		serverType = fmt.Sprintf("MCP server '%s'", s.Name)
+	}
+
 	// Check if server is already running
 	if s.IsRunning() {
-		serverType := "MCP server"
-		if s.Name != "" {
-			serverType = fmt.Sprintf("MCP server '%s'", s.Name)
-		}
-		err := fmt.Errorf("%s is already running", serverType)
-		logging.Error("%v", err)
-		return err
+		return fmt.Errorf("%s is already running", serverType)
 	}
 
-	// Create log file
-	logFile, err := os.OpenFile(s.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	enforceRetention()
+
+	// Get the path to the current executable
+	executable, err := os.Executable()
 	if err != nil {
-		err = fmt.Errorf("failed to create log file: %w", err)
+		err = fmt.Errorf("failed to get executable path: %w", err)
 		logging.Error("%v", err)
 		return err
 	}
-	defer logFile.Close()
 
-	// Get the path to the current executable
-	executable, err := os.Executable()
+	appDir, err := settings.GetAppDir()
 	if err != nil {
-		err = fmt.Errorf("failed to get executable path: %w", err)
+		err = fmt.Errorf("failed to get config directory: %w", err)
 		logging.Error("%v", err)
 		return err
 	}
 
 	// Prepare command to run server in daemon mode with port and name
-	cmd := exec.Command(executable, "mcp", "daemon")
+	cmd := exec.Command(executable, DaemonArgs(s.key(), appDir)...)
 
 	// Add server name, port and mode as environment variables
 	cmd.Env = append(os.Environ(),
@@ -137,38 +224,98 @@ func (s *Server) Start() error {
 		fmt.Sprintf("MCP_SERVER_PORT=%d", s.Port),
 		fmt.Sprintf("MCP_SERVER_MODE=%s", s.Mode))
 
+	if settings.Sandboxed() {
+		if err := s.startSandboxed(cmd); err != nil {
+			logging.Error("%v", err)
+			return err
+		}
+		return nil
+	}
+
+	step := progress.Start("Starting %s", serverType)
+	fail := func(err error) error {
+		step.Fail("Failed to start %s", serverType)
+		logging.Error("%v", err)
+		return err
+	}
+
+	// Create log file
+	logFile, err := os.OpenFile(s.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create log file: %w", err))
+	}
+	defer logFile.Close()
+
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
 
 	// Start the process
 	if err := cmd.Start(); err != nil {
-		err = fmt.Errorf("failed to start MCP server: %w", err)
-		logging.Error("%v", err)
-		return err
+		return fail(fmt.Errorf("failed to start MCP server: %w", err))
 	}
 
 	// Write PID to file
 	pid := cmd.Process.Pid
-	if err := os.WriteFile(s.PidFile, []byte(strconv.Itoa(pid)), 0644); err != nil {
+	if err := s.writePidFile(pid); err != nil {
 		// Try to kill the process if we couldn't write the PID file
 		cmd.Process.Kill()
-		err = fmt.Errorf("failed to write PID file: %w", err)
-		logging.Error("%v", err)
-		return err
+		return fail(fmt.Errorf("failed to write PID file: %w", err))
 	}
 
-	serverType := "MCP server"
-	if s.Name != "" {
-		serverType = fmt.Sprintf("MCP server '%s'", s.Name)
+	logging.Message("%s started with PID %d in %s mode", serverType, pid, s.Mode)
+	if s.Mode != "sse" {
+		step.Done("%s started with PID %d", serverType, pid)
+		return nil
 	}
 
-	logging.Message("%s started with PID %d in %s mode", serverType, pid, s.Mode)
-	if s.Mode == "sse" {
-		logging.Message("HTTP server available at http://localhost:%d", s.Port)
+	step.Update("Waiting for %s on port %d", serverType, s.Port)
+	ready, err := waitForServer(cmd, s.Port, serverReadyTimeout)
+	if err != nil {
+		os.Remove(s.PidFile)
+		return fail(fmt.Errorf("%s exited during startup (%v), see %s", serverType, err, s.LogFile))
 	}
+	if !ready {
+		step.Warn("%s started with PID %d but is not accepting connections yet, see %s", serverType, pid, s.LogFile)
+		return nil
+	}
+
+	step.Done("%s listening on port %d (PID %d)", serverType, s.Port, pid)
+	logging.Message("HTTP server available at http://localhost:%d", s.Port)
 	return nil
 }
 
+// waitForServer polls the daemon's port until it accepts connections or the
+// timeout passes. An error means the process exited before becoming ready.
+func waitForServer(cmd *exec.Cmd, port int, timeout time.Duration) (bool, error) {
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		if err == nil {
+			err = fmt.Errorf("exit status 0")
+		}
+		exited <- err
+	}()
+
+	address := fmt.Sprintf("localhost:%d", port)
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if conn, err := net.DialTimeout("tcp", address, 100*time.Millisecond); err == nil {
+			conn.Close()
+			return true, nil
+		}
+
+		select {
+		case err := <-exited:
+			return false, err
+		case <-deadline:
+			return false, nil
+		case <-ticker.C:
+		}
+	}
+}
+
 // Stop terminates the MCP server
 func (s *Server) Stop() error {
 	pid, err := s.getPid()
@@ -177,6 +324,10 @@ func (s *Server) Stop() error {
 		if s.Name != "" {
 			serverType = fmt.Sprintf("MCP server '%s'", s.Name)
 		}
+		if _, _, readErr := s.readPidFile(); readErr == nil && !settings.Sandboxed() {
+			// The PID file outlived its process, never signal whatever reuses the PID
+			os.Remove(s.PidFile)
+		}
 		err = fmt.Errorf("%s is not running: %w", serverType, err)
 		logging.Error("%v", err)
 		return err
@@ -209,7 +360,11 @@ func (s *Server) Stop() error {
 	}
 
 	// Remove PID file
-	if err := os.Remove(s.PidFile); err != nil {
+	if settings.Sandboxed() {
+		sandboxMu.Lock()
+		delete(sandboxServers, s.PidFile)
+		sandboxMu.Unlock()
+	} else if err := os.Remove(s.PidFile); err != nil {
 		logging.Warning("Failed to remove PID file: %v", err)
 	}
 
@@ -244,19 +399,7 @@ func (s *Server) Restart() error {
 
 // IsRunning checks if the MCP server is running
 func (s *Server) IsRunning() bool {
-	pid, err := s.getPid()
-	if err != nil {
-		return false
-	}
-
-	// Check if process exists
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return false
-	}
-
-	// Send signal 0 to check if process exists
-	err = process.Signal(syscall.Signal(0))
+	_, err := s.getPid()
 	return err == nil
 }
 
@@ -289,76 +432,205 @@ func GetProcessUsingPort(port int) string {
 	return strings.TrimSpace(string(output))
 }
 
-// Status returns the current status of the MCP server
-func (s *Server) Status() string {
-	serverType := "MCP server"
-	if s.Name != "" {
-		serverType = fmt.Sprintf("MCP server '%s'", s.Name)
+// ServerStatus is the state of a server, as interop mcp status reports it
+type ServerStatus struct {
+	Server        string  `json:"server"` // Server name, "default" for the default server
+	Running       bool    `json:"running"`
+	PID           int     `json:"pid,omitempty"`
+	Port          int     `json:"port"`
+	URL           string  `json:"url,omitempty"` // Where a running server is available
+	PortAvailable bool    `json:"port_available"`
+	PortProcess   string  `json:"port_process,omitempty"` // Process holding the port, when it isn't the server
+	Health        *Health `json:"health,omitempty"`       // Health check response of a running server
+	HealthError   string  `json:"health_error,omitempty"` // Why a running server's health check failed
+}
+
+// StatusInfo returns the current status of the MCP server
+func (s *Server) StatusInfo() ServerStatus {
+	status := ServerStatus{Server: s.Name, Port: s.Port, PortAvailable: IsPortAvailable(s.Port)}
+	if status.Server == "" {
+		status.Server = "default"
 	}
 
 	if s.IsRunning() {
-		pid, _ := s.getPid()
-
-		portStatus := "Port available: Yes"
-		if !IsPortAvailable(s.Port) {
+		status.Running = true
+		status.PID, _ = s.getPid()
+		status.URL = fmt.Sprintf("http://localhost:%d", s.Port)
+		if !status.PortAvailable {
 			// Check if it's our process using the port
 			processInfo := GetProcessUsingPort(s.Port)
-			if strings.Contains(processInfo, fmt.Sprintf("%d", pid)) {
-				portStatus = "Port in use by this server"
-			} else {
-				portStatus = fmt.Sprintf("Port in use by another process:\n%s", processInfo)
+			if !strings.Contains(processInfo, fmt.Sprintf("%d", status.PID)) {
+				status.PortProcess = processInfo
 			}
 		}
+		if health, err := s.Health(); err != nil {
+			status.HealthError = err.Error()
+		} else {
+			status.Health = &health
+		}
+		return status
+	}
+
+	if !status.PortAvailable {
+		status.PortProcess = GetProcessUsingPort(s.Port)
+	}
+	return status
+}
+
+// Status returns the current status of the MCP server
+func (s *Server) Status() string {
+	return s.StatusInfo().render(s.Name)
+}
+
+// render describes the status for people. name is the server's name, empty
+// for the default server.
+func (st ServerStatus) render(name string) string {
+	serverType := "MCP server"
+	if name != "" {
+		serverType = fmt.Sprintf("MCP server '%s'", name)
+	}
+
+	if st.Running {
+		portStatus := "Port available: Yes"
+		if !st.PortAvailable && st.PortProcess == "" {
+			portStatus = "Port in use by this server"
+		} else if !st.PortAvailable {
+			portStatus = fmt.Sprintf("Port in use by another process:\n%s", st.PortProcess)
+		}
+
+		healthStatus := "Health: "
+		if st.Health == nil {
+			healthStatus += st.HealthError
+		} else {
+			healthStatus += fmt.Sprintf("%s, up %s, %d tools, config %.12s", st.Health.Status, st.Health.UptimeString(), st.Health.Tools, st.Health.ConfigHash)
+		}
 
-		return fmt.Sprintf("%s is running (PID: %d)\nHTTP server available at http://localhost:%d\n%s",
-			serverType, pid, s.Port, portStatus)
+		return fmt.Sprintf("%s is running (PID: %d)\nHTTP server available at %s\n%s\n%s",
+			serverType, st.PID, st.URL, portStatus, healthStatus)
 	}
 
 	portStatus := "Port available: Yes"
-	if !IsPortAvailable(s.Port) {
-		processInfo := GetProcessUsingPort(s.Port)
-		portStatus = fmt.Sprintf("Port available: No\nProcess using port %d:\n%s", s.Port, processInfo)
+	if !st.PortAvailable {
+		portStatus = fmt.Sprintf("Port available: No\nProcess using port %d:\n%s", st.Port, st.PortProcess)
 	}
 
 	return fmt.Sprintf("%s is not running\n%s", serverType, portStatus)
 }
 
-// getPid reads the PID from the PID file
+// getPid returns the PID of the running server, read from the PID file or
+// from memory when sandboxed. The process must still be the one that wrote
+// the PID file, not a later one reusing its PID.
 func (s *Server) getPid() (int, error) {
+	if settings.Sandboxed() {
+		sandboxMu.Lock()
+		defer sandboxMu.Unlock()
+		if cmd, exists := sandboxServers[s.PidFile]; exists {
+			if err := verifyProcess(cmd.Process.Pid, ""); err != nil {
+				return 0, err
+			}
+			return cmd.Process.Pid, nil
+		}
+		return 0, fmt.Errorf("server not started in this sandbox session")
+	}
+
+	pid, started, err := s.readPidFile()
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyProcess(pid, started); err != nil {
+		return 0, err
+	}
+	return pid, nil
+}
+
+// readPidFile returns the PID and start time recorded in the PID file. The
+// start time is empty for files written by older versions.
+func (s *Server) readPidFile() (int, string, error) {
 	if _, err := os.Stat(s.PidFile); os.IsNotExist(err) {
-		return 0, fmt.Errorf("PID file not found")
+		return 0, "", fmt.Errorf("PID file not found")
 	}
 
-	pidBytes, err := os.ReadFile(s.PidFile)
+	data, err := os.ReadFile(s.PidFile)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read PID file: %w", err)
+		return 0, "", fmt.Errorf("failed to read PID file: %w", err)
 	}
 
-	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	pidLine, started, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(pidLine))
 	if err != nil {
-		return 0, fmt.Errorf("invalid PID in file: %w", err)
+		return 0, "", fmt.Errorf("invalid PID in file: %w", err)
 	}
 
-	return pid, nil
+	return pid, strings.TrimSpace(started), nil
+}
+
+// writePidFile records pid and the start time of its process, which tells it
+// apart from a later process reusing the PID
+func (s *Server) writePidFile(pid int) error {
+	started, err := processStartTime(pid)
+	if err != nil {
+		logging.Warning("Failed to get the start time of PID %d: %v", pid, err)
+	}
+	return os.WriteFile(s.PidFile, []byte(fmt.Sprintf("%d\n%s\n", pid, started)), 0644)
+}
+
+// key returns the key of the server in ServerManager.Servers
+func (s *Server) key() string {
+	if s.Name == "" {
+		return "default"
+	}
+	return s.Name
 }
 
-// StartServer starts a specific MCP server or all servers
-func (m *ServerManager) StartServer(name string, all bool) error {
+// skipReason returns why `mcp start --all` leaves out a server, or "" when it
+// starts it. Servers with autostart = false are always left out, and with
+// onlyUsed so are servers without enabled commands or prompts to serve.
+func skipReason(cfg *settings.Settings, name string, onlyUsed bool) string {
+	mcpServer := cfg.MCPServers[name]
+	if name != "" && !mcpServer.Autostarts() {
+		return "autostart = false"
+	}
+	// Admin servers serve interop itself, and remote commands are only known
+	// once a server fetches them
+	if !onlyUsed || mcpServer.Admin || os.Getenv("MCP_REMOTE_URL") != "" {
+		return ""
+	}
+
+	if len(commandTools(cfg, cfg.Commands, name)) > 0 {
+		return ""
+	}
+	for _, prompt := range cfg.Prompts {
+		if prompt.MCP.Includes(name) {
+			return ""
+		}
+	}
+	return "no enabled commands or prompts"
+}
+
+// StartServer starts a specific MCP server or all servers. Starting all
+// servers skips those skipReason leaves out, onlyUsed being --only-used.
+func (m *ServerManager) StartServer(name string, all, onlyUsed bool) error {
 	if all {
 		// Start all servers
 		serversStarted := 0
-		var startErrors []string
+		var startErrors, skipped []string
 
 		// Get a sorted list of server names for consistent output
 		serverNames := make([]string, 0, len(m.Servers))
 		for serverName := range m.Servers {
 			serverNames = append(serverNames, serverName)
 		}
+		sort.Strings(serverNames)
 
 		// Process servers
 		for _, serverName := range serverNames {
 			server := m.Servers[serverName]
 
+			if reason := skipReason(m.cfg, server.Name, onlyUsed); reason != "" {
+				skipped = append(skipped, fmt.Sprintf("%s (%s)", serverName, reason))
+				continue
+			}
+
 			logging.Message("Starting MCP server: %s", serverName)
 			if server.IsRunning() {
 				logging.Warning("MCP server '%s' is already running", serverName)
@@ -374,36 +646,32 @@ func (m *ServerManager) StartServer(name string, all bool) error {
 			}
 		}
 
+		if len(skipped) > 0 {
+			logging.Message("Skipped %d MCP servers: %s", len(skipped), strings.Join(skipped, ", "))
+		}
+
 		// Check if any servers started
 		if serversStarted == 0 {
 			if len(startErrors) > 0 {
-				err := fmt.Errorf("failed to start any MCP servers: %s", strings.Join(startErrors, "; "))
-				logging.Error("%v", err)
-				return err
+				return fmt.Errorf("failed to start any MCP servers: %s", strings.Join(startErrors, "; "))
 			}
-			err := fmt.Errorf("no MCP servers started, possibly all already running")
-			logging.Warning("%v", err)
-			return err
+			if len(skipped) > 0 {
+				return fmt.Errorf("no MCP servers started, %d skipped and the rest already running", len(skipped))
+			}
+			return fmt.Errorf("no MCP servers started, possibly all already running")
 		}
 
 		logging.Message("Started %d MCP servers successfully", serversStarted)
-		return nil
-	}
-
-	// Start a specific server by name
-	if name == "" {
-		// Default server
-		return m.Servers["default"].Start()
+		return finishStart(nil)
 	}
 
-	server, exists := m.Servers[name]
-	if !exists {
-		err := fmt.Errorf("MCP server '%s' not found", name)
-		logging.Error("%v", err)
+	// Start a specific server by name, the default one when empty
+	server, err := m.server(name)
+	if err != nil {
 		return err
 	}
 
-	return server.Start()
+	return finishStart(server.Start())
 }
 
 // StopServer stops a specific MCP server or all servers
@@ -441,29 +709,18 @@ func (m *ServerManager) StopServer(name string, all bool) error {
 		// Check if any servers were stopped
 		if serversStopped == 0 {
 			if len(stopErrors) > 0 {
-				err := fmt.Errorf("failed to stop any MCP servers: %s", strings.Join(stopErrors, "; "))
-				logging.Error("%v", err)
-				return err
+				return fmt.Errorf("failed to stop any MCP servers: %s", strings.Join(stopErrors, "; "))
 			}
-			err := fmt.Errorf("no MCP servers stopped, possibly none were running")
-			logging.Warning("%v", err)
-			return err
+			return fmt.Errorf("no MCP servers stopped, possibly none were running")
 		}
 
 		logging.Message("Stopped %d MCP servers successfully", serversStopped)
 		return nil
 	}
 
-	// Stop a specific server by name
-	if name == "" {
-		// Default server
-		return m.Servers["default"].Stop()
-	}
-
-	server, exists := m.Servers[name]
-	if !exists {
-		err := fmt.Errorf("MCP server '%s' not found", name)
-		logging.Error("%v", err)
+	// Stop a specific server by name, the default one when empty
+	server, err := m.server(name)
+	if err != nil {
 		return err
 	}
 
@@ -502,42 +759,60 @@ func (m *ServerManager) RestartServer(name string, all bool) error {
 		// Check if any servers were restarted
 		if serversRestarted == 0 {
 			if len(restartErrors) > 0 {
-				err := fmt.Errorf("failed to restart any MCP servers: %s", strings.Join(restartErrors, "; "))
-				logging.Error("%v", err)
-				return err
+				return fmt.Errorf("failed to restart any MCP servers: %s", strings.Join(restartErrors, "; "))
 			}
-			err := fmt.Errorf("no MCP servers restarted")
-			logging.Warning("%v", err)
-			return err
+			return fmt.Errorf("no MCP servers restarted")
 		}
 
 		logging.Message("Restarted %d MCP servers successfully", serversRestarted)
-		return nil
+		return finishStart(nil)
 	}
 
-	// Restart a specific server by name
-	if name == "" {
-		// Default server
-		return m.Servers["default"].Restart()
+	// Restart a specific server by name, the default one when empty
+	server, err := m.server(name)
+	if err != nil {
+		return err
 	}
 
-	server, exists := m.Servers[name]
-	if !exists {
-		err := fmt.Errorf("MCP server '%s' not found", name)
-		logging.Error("%v", err)
-		return err
+	return finishStart(server.Restart())
+}
+
+// Statuses returns the status of the named server, or of all servers when
+// name is empty, the default one first and the others by name
+func (m *ServerManager) Statuses(name string) ([]ServerStatus, error) {
+	if name != "" {
+		server, err := m.server(name)
+		if err != nil {
+			return nil, err
+		}
+		return []ServerStatus{server.StatusInfo()}, nil
 	}
 
-	return server.Restart()
+	names := make([]string, 0, len(m.Servers))
+	for serverName := range m.Servers {
+		if serverName != "default" {
+			names = append(names, serverName)
+		}
+	}
+	sort.Strings(names)
+	if _, exists := m.Servers["default"]; exists {
+		names = append([]string{"default"}, names...)
+	}
+
+	statuses := make([]ServerStatus, 0, len(names))
+	for _, serverName := range names {
+		statuses = append(statuses, m.Servers[serverName].StatusInfo())
+	}
+	return statuses, nil
 }
 
 // GetStatus returns the status of a specific MCP server or all servers
 func (m *ServerManager) GetStatus(name string, all bool) string {
 	// If a specific server is requested, only show that one
 	if name != "" {
-		server, exists := m.Servers[name]
-		if !exists {
-			return fmt.Sprintf("MCP server '%s' not found", name)
+		server, err := m.server(name)
+		if err != nil {
+			return err.Error()
 		}
 		return server.Status()
 	}
@@ -547,7 +822,9 @@ func (m *ServerManager) GetStatus(name string, all bool) string {
 	status += "=====================\n"
 
 	// First show default server
-	status += fmt.Sprintf("\n[default]\n%s\n", m.Servers["default"].Status())
+	if server, exists := m.Servers["default"]; exists {
+		status += fmt.Sprintf("\n[default]\n%s\n", server.Status())
+	}
 
 	// Then show all other servers
 	for serverName, server := range m.Servers {
@@ -559,48 +836,187 @@ func (m *ServerManager) GetStatus(name string, all bool) string {
 	return status
 }
 
+// builtinToolCount is the number of tools every command server registers on
+// top of its commands: commands, list_artifacts, read_artifact and project-info
+const builtinToolCount = 4
+
+// adminToolCount is the number of tools an admin server registers
+const adminToolCount = 5
+
+// ServerListing is a configured MCP server with what it serves, as
+// interop mcp list reports it
+type ServerListing struct {
+	Server       string        `json:"server"` // Server name, "default" for the default server
+	Description  string        `json:"description,omitempty"`
+	Enabled      bool          `json:"enabled"` // False for the default server with default_mcp_enabled = false
+	Port         int           `json:"port,omitempty"`
+	Autostart    bool          `json:"autostart"`
+	Status       *ServerStatus `json:"status,omitempty"` // Nil when the server isn't initialized
+	Admin        bool          `json:"admin,omitempty"`  // Serves interop's management tools
+	Tools        int           `json:"tools"`
+	CommandTools int           `json:"command_tools"`
+	AliasTools   int           `json:"alias_tools"`
+	BuiltinTools int           `json:"builtin_tools"`
+	Prompts      int           `json:"prompts"`
+	Commands     []ListedTool  `json:"commands,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"`
+}
+
+// ListedTool is a command tool of a listed server
+type ListedTool struct {
+	Tool    string `json:"tool"`
+	Command string `json:"command"` // Command the tool runs, another one for an alias
+}
+
+// Listings returns the configured MCP servers, the default one first and
+// the others by name, with the tools and prompts they register
+func (m *ServerManager) Listings() ([]ServerListing, error) {
+	cfg, err := settings.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	var listings []ServerListing
+	var names []string
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if cfg.DefaultMCPServerEnabled() {
+		names = append([]string{""}, names...)
+	} else {
+		listings = append(listings, ServerListing{Server: "default"})
+	}
+
+	for _, name := range names {
+		key := name
+		if name == "" {
+			key = "default"
+		}
+		mcpServer := cfg.MCPServers[name]
+		listing := ServerListing{
+			Server:      key,
+			Description: mcpServer.Description,
+			Enabled:     true,
+			Port:        mcpServer.Port,
+			Autostart:   name == "" || mcpServer.Autostarts(),
+			Admin:       mcpServer.Admin,
+		}
+		if name == "" {
+			listing.Port = cfg.MCPPort
+		}
+
+		server, exists := m.Servers[key]
+		if exists {
+			status := server.StatusInfo()
+			listing.Status = &status
+		}
+
+		if mcpServer.Admin {
+			listing.Tools = adminToolCount
+			listings = append(listings, listing)
+			continue
+		}
+
+		// Count what the server registers, using the same filtering
+		tools := commandTools(cfg, cfg.Commands, name)
+		toolNames := make([]string, 0, len(tools))
+		for toolName, cmdName := range tools {
+			if toolName != cmdName {
+				listing.AliasTools++
+			}
+			toolNames = append(toolNames, toolName)
+		}
+		sort.Strings(toolNames)
+		for _, toolName := range toolNames {
+			listing.Commands = append(listing.Commands, ListedTool{Tool: toolName, Command: tools[toolName]})
+		}
+
+		for _, prompt := range cfg.Prompts {
+			if prompt.MCP.Includes(name) {
+				listing.Prompts++
+			}
+		}
+
+		listing.BuiltinTools = builtinToolCount
+		if (name == "" && cfg.BatchTool) || mcpServer.BatchTool {
+			listing.BuiltinTools++ // run-batch
+		}
+		listing.CommandTools = len(tools) - listing.AliasTools
+		listing.Tools = len(tools) + listing.BuiltinTools
+
+		if len(tools) == 0 {
+			listing.Warnings = append(listing.Warnings, "No command tools would register, only the built-in tools")
+		} else if exists && !listing.Status.Running {
+			listing.Warnings = append(listing.Warnings, "Commands are assigned but the server is not running")
+		}
+		listings = append(listings, listing)
+	}
+	return listings, nil
+}
+
 // ListMCPServers returns a list of configured MCP servers with their details
 func (m *ServerManager) ListMCPServers() string {
-	cfg, err := settings.Load()
+	listings, err := m.Listings()
 	if err != nil {
-		return fmt.Sprintf("Failed to load settings: %v", err)
+		return fmt.Sprintf("Failed to list MCP servers: %v", err)
 	}
 
 	result := "Configured MCP Servers:\n"
 	result += "=====================\n\n"
 
-	// First show default server
-	result += fmt.Sprintf("[default]\n")
-	result += fmt.Sprintf("Port: %d\n", cfg.MCPPort)
-	result += fmt.Sprintf("Status: %s\n\n", m.Servers["default"].Status())
+	for _, listing := range listings {
+		if !listing.Enabled {
+			result += fmt.Sprintf("[%s]\nDisabled (default_mcp_enabled = false)\n\n", listing.Server)
+			continue
+		}
+		result += fmt.Sprintf("[%s]\n", listing.Server)
 
-	// Then show all other servers
-	for name, mcpServer := range cfg.MCPServers {
-		result += fmt.Sprintf("[%s]\n", name)
-		result += fmt.Sprintf("Description: %s\n", mcpServer.Description)
-		result += fmt.Sprintf("Port: %d\n", mcpServer.Port)
+		name := listing.Server
+		if name == "default" {
+			name = ""
+		} else {
+			result += fmt.Sprintf("Description: %s\n", listing.Description)
+		}
+		result += fmt.Sprintf("Port: %d\n", listing.Port)
+		if name != "" && !listing.Autostart {
+			result += "Autostart: false\n"
+		}
 
-		if server, exists := m.Servers[name]; exists {
-			result += fmt.Sprintf("Status: %s\n", server.Status())
+		if listing.Status != nil {
+			result += fmt.Sprintf("Status: %s\n", listing.Status.render(name))
 		} else {
 			result += "Status: Not initialized\n"
 		}
 
-		// Get commands for this server
-		result += "\nCommands:\n"
-		hasCommands := false
+		if listing.Admin {
+			result += fmt.Sprintf("Tools: %d admin tools\n\n", listing.Tools)
+			continue
+		}
+
+		result += fmt.Sprintf("Tools: %d (%d commands, %d aliases, %d built-in)\n",
+			listing.Tools, listing.CommandTools, listing.AliasTools, listing.BuiltinTools)
+		result += fmt.Sprintf("Prompts: %d\n", listing.Prompts)
 
-		for cmdName, cmd := range cfg.Commands {
-			if cmd.MCP == name {
-				result += fmt.Sprintf("- %s\n", cmdName)
-				hasCommands = true
+		result += "\nCommands:\n"
+		for _, tool := range listing.Commands {
+			if tool.Command != tool.Tool {
+				result += fmt.Sprintf("- %s (alias of %s)\n", tool.Tool, tool.Command)
+			} else {
+				result += fmt.Sprintf("- %s\n", tool.Tool)
 			}
 		}
-
-		if !hasCommands {
+		if len(listing.Commands) == 0 {
 			result += "- No commands assigned\n"
 		}
 
+		if len(listing.Warnings) > 0 {
+			result += "\nWarnings:\n"
+			for _, warning := range listing.Warnings {
+				result += fmt.Sprintf("- %s\n", warning)
+			}
+		}
+
 		result += "\n"
 	}
 
@@ -614,40 +1030,55 @@ func (m *ServerManager) ExportMCPConfig() (string, error) {
 
 // ExportMCPConfigWithMode returns a JSON representation of the MCP configuration for the specified mode
 func (m *ServerManager) ExportMCPConfigWithMode(mode string) (string, error) {
+	servers, err := exportServers(mode)
+	if err != nil {
+		return "", err
+	}
+
+	// Marshal to JSON
+	jsonData, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal configuration: %v", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// exportServers returns the client configuration block of every server keyed
+// by its export name, for the specified mode
+func exportServers(mode string) (map[string]map[string]interface{}, error) {
 	cfg, err := settings.Load()
 	if err != nil {
-		return "", fmt.Errorf("failed to load settings: %v", err)
+		return nil, fmt.Errorf("failed to load settings: %v", err)
 	}
 
 	// Validate mode
 	if mode != "stdio" && mode != "sse" {
-		return "", fmt.Errorf("invalid mode: %s, must be either 'stdio' or 'sse'", mode)
+		return nil, fmt.Errorf("invalid mode: %s, must be either 'stdio' or 'sse'", mode)
 	}
 
 	// Create output format with the required naming convention
 	servers := make(map[string]map[string]interface{})
 
 	if mode == "stdio" {
-		// For stdio mode, provide command-line configurations
-		// Add default server
-		servers["default-interopMCPServer"] = map[string]interface{}{
-			"command": "interop",
-			"args":    []string{"mcp", "start", "--mode", "stdio"},
+		// For stdio mode, provide a separate profile per server that clients
+		// can spawn directly
+		if cfg.DefaultMCPServerEnabled() {
+			servers["default-interopMCPServer"] = stdioProfile("")
 		}
 
 		// Add all configured MCP servers
-		for name, _ := range cfg.MCPServers {
+		for name := range cfg.MCPServers {
 			serverKey := fmt.Sprintf("%s-interopMCPServer", name)
-			servers[serverKey] = map[string]interface{}{
-				"command": "interop",
-				"args":    []string{"mcp", "start", name, "--mode", "stdio"},
-			}
+			servers[serverKey] = stdioProfile(name)
 		}
 	} else {
 		// For SSE mode, provide HTTP URLs (existing behavior)
 		// Add default server
-		servers["default-interopMCPServer"] = map[string]interface{}{
-			"url": fmt.Sprintf("http://localhost:%d/mcp", cfg.MCPPort),
+		if cfg.DefaultMCPServerEnabled() {
+			servers["default-interopMCPServer"] = map[string]interface{}{
+				"url": fmt.Sprintf("http://localhost:%d/mcp", cfg.MCPPort),
+			}
 		}
 
 		// Add all configured MCP servers
@@ -659,11 +1090,42 @@ func (m *ServerManager) ExportMCPConfigWithMode(mode string) (string, error) {
 		}
 	}
 
-	// Marshal to JSON
-	jsonData, err := json.MarshalIndent(servers, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal configuration: %v", err)
+	return servers, nil
+}
+
+// stdioProfile returns the client configuration spawning a server in stdio
+// mode. serverName is empty for the default server.
+func stdioProfile(serverName string) map[string]interface{} {
+	binary, args, env := stdioCommand(serverName)
+	return map[string]interface{}{
+		"command": binary,
+		"args":    args,
+		"env":     env,
 	}
+}
 
-	return string(jsonData), nil
+// stdioCommand returns the command line and environment spawning a server in
+// stdio mode. Clients often run with a minimal PATH, so the absolute path of
+// the interop binary is used, and the config directory is passed on when one
+// was selected. serverName is empty for the default server.
+func stdioCommand(serverName string) (string, []string, map[string]string) {
+	binary := "interop"
+	if executable, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		binary = executable
+	}
+
+	args := []string{"mcp", "start"}
+	env := map[string]string{"MCP_SERVER_MODE": "stdio"}
+	if serverName != "" {
+		args = append(args, serverName)
+		env["MCP_SERVER_NAME"] = serverName
+	}
+	args = append(args, "--mode", "stdio")
+	if configDir := settings.ConfigDir(); configDir != "" {
+		args = append(args, "--config-dir", configDir)
+	}
+	return binary, args, env
 }
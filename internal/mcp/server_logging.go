@@ -1,15 +1,28 @@
 package mcp
 
 import (
+	"context"
 	"fmt"
+	"interop/internal/logging"
+	"interop/internal/termtext"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
-// logToFile logs a message to the log file with a timestamp
+// logToFile logs a message to the log file with a timestamp. Without a log
+// file (sandbox mode) messages go to stderr. Escape sequences in the message,
+// e.g. from command output, are removed to keep the log readable.
 func (s *MCPLibServer) logToFile(level, format string, args ...interface{}) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	message := fmt.Sprintf(format, args...)
-	fmt.Fprintf(s.logFile, "[%s] [%s] %s\n", timestamp, level, message)
+	message := termtext.Strip(fmt.Sprintf(format, args...))
+	out := s.logFile
+	if out == nil {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "[%s] [%s] %s\n", timestamp, level, message)
 }
 
 // logInfo logs an informational message to the log file
@@ -26,3 +39,44 @@ func (s *MCPLibServer) logWarning(format string, args ...interface{}) {
 func (s *MCPLibServer) logError(format string, args ...interface{}) {
 	s.logToFile("ERROR", format, args...)
 }
+
+// callInfo logs an informational message about the tool call in ctx, tagged
+// with the call's correlation ID
+func (s *MCPLibServer) callInfo(ctx context.Context, format string, args ...interface{}) {
+	s.logToFile("INFO", tagCall(ctx, format), args...)
+}
+
+// callWarning logs a warning about the tool call in ctx, tagged with the
+// call's correlation ID
+func (s *MCPLibServer) callWarning(ctx context.Context, format string, args ...interface{}) {
+	s.logToFile("WARNING", tagCall(ctx, format), args...)
+}
+
+// tagCall prefixes format with the correlation ID in ctx, if any
+func tagCall(ctx context.Context, format string) string {
+	id := logging.CorrelationID(ctx)
+	if id == "" {
+		return format
+	}
+	return "[" + strings.ReplaceAll(id, "%", "%%") + "] " + format
+}
+
+// correlate returns ctx carrying a new correlation ID unless it has one
+func correlate(ctx context.Context) context.Context {
+	if logging.CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return logging.WithCorrelationID(ctx, logging.NewCorrelationID())
+}
+
+// withCorrelationID adds the correlation ID of the call in ctx to the
+// metadata of its result, so clients can look the call up in the server log
+func withCorrelationID(ctx context.Context, result *mcp.CallToolResult) *mcp.CallToolResult {
+	if id := logging.CorrelationID(ctx); id != "" && result != nil {
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		result.Meta["correlation_id"] = id
+	}
+	return result
+}
@@ -1,14 +1,20 @@
 package mcp
 
 import (
+	"interop/internal/settings"
+	"interop/internal/testutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestServerInit(t *testing.T) {
-	server, err := NewServer()
+	testutil.New(t)
+	settings.Reload()
+
+	server, err := NewServer("", settings.GetMCPPort())
 	if err != nil {
 		t.Fatalf("Failed to create server: %v", err)
 	}
@@ -61,7 +67,7 @@ func TestServerMethods(t *testing.T) {
 
 	// Test status when server is not running
 	status := server.Status()
-	if status != "MCP server is not running" {
+	if !strings.HasPrefix(status, "MCP server is not running\n") {
 		t.Errorf("Unexpected status: %s", status)
 	}
 
@@ -72,6 +78,126 @@ func TestServerMethods(t *testing.T) {
 	}
 }
 
+func TestServerCommandsReturnErrors(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("mcp_port = 18091\n")
+	t.Setenv("MCP_SERVER_MODE", "")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	// Failures are returned rather than exiting the test binary
+	tests := []struct {
+		name string
+		run  func() error
+		want string
+	}{
+		{"start unknown server", func() error { return StartServer("missing", false, false) }, "MCP server 'missing' not found"},
+		{"stop unknown server", func() error { return StopServer("missing", false) }, "MCP server 'missing' not found"},
+		{"restart unknown server", func() error { return RestartServer("missing", false) }, "MCP server 'missing' not found"},
+		{"stop idle server", func() error { return StopServer("", false) }, "is not running"},
+		{"stop all idle servers", func() error { return StopServer("", true) }, "no MCP servers stopped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	if err := StartServer("", true, false); err == nil {
+		t.Error("StartServer() with --all in stdio mode expected an error")
+	}
+}
+
+func TestDefaultServerDisabled(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+default_mcp_enabled = false
+
+[mcp_servers.docs]
+name = "docs"
+description = "Docs"
+port = 18093
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	manager, err := NewServerManager()
+	if err != nil {
+		t.Fatalf("NewServerManager() error = %v", err)
+	}
+	if _, exists := manager.Servers["default"]; exists {
+		t.Error("Expected no default server")
+	}
+	if _, exists := manager.Servers["docs"]; !exists {
+		t.Error("Expected the docs server")
+	}
+	for _, name := range []string{"", "default"} {
+		if _, err := manager.server(name); err != errDefaultServerDisabled {
+			t.Errorf("server(%q) error = %v, want errDefaultServerDisabled", name, err)
+		}
+	}
+	if err := manager.StartServer("", false, false); err != errDefaultServerDisabled {
+		t.Errorf("StartServer() error = %v, want errDefaultServerDisabled", err)
+	}
+	if _, err := NewMCPLibServer(); err != errDefaultServerDisabled {
+		t.Errorf("NewMCPLibServer() error = %v, want errDefaultServerDisabled", err)
+	}
+
+	servers, err := exportServers("stdio")
+	if err != nil {
+		t.Fatalf("exportServers() error = %v", err)
+	}
+	if _, exists := servers["default-interopMCPServer"]; exists || len(servers) != 1 {
+		t.Errorf("Expected only the docs server exported, got %v", servers)
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	off := false
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"build": {IsEnabled: true, MCP: settings.ServerList{"work"}},
+			"lint":  {IsEnabled: false, MCP: settings.ServerList{"idle"}},
+		},
+		Prompts: map[string]settings.PromptConfig{"review": {MCP: settings.ServerList{"docs"}}},
+		MCPServers: map[string]settings.MCPServer{
+			"work":   {Name: "work"},
+			"idle":   {Name: "idle"},
+			"docs":   {Name: "docs"},
+			"admin":  {Name: "admin", Admin: true},
+			"manual": {Name: "manual", Autostart: &off},
+		},
+	}
+
+	tests := []struct {
+		server   string
+		onlyUsed bool
+		want     string
+	}{
+		{"work", true, ""},
+		{"docs", true, ""},
+		{"admin", true, ""},
+		{"idle", false, ""},
+		{"idle", true, "no enabled commands or prompts"},
+		{"", true, "no enabled commands or prompts"},
+		{"manual", false, "autostart = false"},
+	}
+	for _, tt := range tests {
+		if got := skipReason(cfg, tt.server, tt.onlyUsed); got != tt.want {
+			t.Errorf("skipReason(%q, %v) = %q, want %q", tt.server, tt.onlyUsed, got, tt.want)
+		}
+	}
+}
+
 // Only run this test manually as it involves starting an actual process
 func TestServerLifecycle(t *testing.T) {
 	if os.Getenv("RUN_MANUAL_TESTS") != "1" {
@@ -0,0 +1,367 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// sessionsDirName is the directory in the MCP directory sessions are recorded in
+const sessionsDirName = "sessions"
+
+// redactedValue replaces secrets in recorded sessions
+const redactedValue = "[REDACTED]"
+
+// secretName matches the names of arguments, fields and environment variables
+// holding secrets
+var secretName = regexp.MustCompile(`(?i)(token|secret|passw|api_?key|auth|credential|private_?key)`)
+
+// SessionEntry is a line of a recorded session file: a request a client sent
+// the server or the server's response to it
+type SessionEntry struct {
+	Time   time.Time       `json:"time"`
+	Server string          `json:"server"`           // Server name, "default" for the default server
+	Type   string          `json:"type"`             // "request" or "response"
+	ID     json.RawMessage `json:"id,omitempty"`     // JSON-RPC id pairing a response with its request
+	Method string          `json:"method"`           // JSON-RPC method, like "tools/call"
+	Params json.RawMessage `json:"params,omitempty"` // Params of a request
+	Result json.RawMessage `json:"result,omitempty"` // Result of a successful response
+	Error  string          `json:"error,omitempty"`  // Error of a failed response
+}
+
+// sessionRecorder writes the JSON-RPC traffic of a server to a session file,
+// with secrets redacted
+type sessionRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	server  string
+	secrets []string // Pairs of secret values of the environments and their replacement
+}
+
+// newSessionRecorder starts a session file for the server in dir, named after
+// the server and the time
+func newSessionRecorder(dir, serverKey string) (*sessionRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.jsonl", serverKey, time.Now().Format("20060102-150405")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session file: %w", err)
+	}
+	return &sessionRecorder{file: file, server: serverKey, secrets: secretPairs(os.Environ())}, nil
+}
+
+// Path returns the path of the session file
+func (r *sessionRecorder) Path() string {
+	return r.file.Name()
+}
+
+// Close closes the session file
+func (r *sessionRecorder) Close() error {
+	return r.file.Close()
+}
+
+// hooks returns the server hooks recording requests and responses
+func (r *sessionRecorder) hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddBeforeAny(func(ctx context.Context, id any, method mcp.MCPMethod, message any) {
+		var request struct {
+			Params json.RawMessage `json:"params"`
+		}
+		if data, err := json.Marshal(message); err == nil {
+			json.Unmarshal(data, &request)
+		}
+		r.write(id, SessionEntry{Type: "request", Method: string(method), Params: request.Params}, request.Params)
+	})
+	hooks.AddOnSuccess(func(ctx context.Context, id any, method mcp.MCPMethod, message any, result any) {
+		data, _ := json.Marshal(result)
+		params, _ := json.Marshal(message)
+		r.write(id, SessionEntry{Type: "response", Method: string(method), Result: data}, params)
+	})
+	hooks.AddOnError(func(ctx context.Context, id any, method mcp.MCPMethod, message any, err error) {
+		// Clients get the error the server's request error wraps
+		if inner := errors.Unwrap(err); inner != nil {
+			err = inner
+		}
+		params, _ := json.Marshal(message)
+		r.write(id, SessionEntry{Type: "response", Method: string(method), Error: err.Error()}, params)
+	})
+	return hooks
+}
+
+// addCommandEnv redacts the secrets of env, the environment a tool call runs
+// a command with, from the rest of the session: the values of the variables
+// with secret names and of those named in stored, read from a secret store,
+// see settings.StoredSecretVariables
+func (r *sessionRecorder) addCommandEnv(env, stored []string) {
+	pairs := secretPairs(env)
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		if slices.Contains(stored, name) && !secretName.MatchString(name) && len(value) >= 4 {
+			pairs = append(pairs, value, redactedValue)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < len(pairs); i += 2 {
+		if !slices.Contains(r.secrets, pairs[i]) {
+			r.secrets = append(r.secrets, pairs[i], redactedValue)
+		}
+	}
+}
+
+// write adds an entry to the session file. Besides the secrets of the
+// environments, the values of secret arguments in request, the JSON of the
+// request or its params, are redacted, as the response may repeat them.
+func (r *sessionRecorder) write(id any, entry SessionEntry, request json.RawMessage) {
+	r.mu.Lock()
+	environments := r.secrets
+	r.mu.Unlock()
+	secrets := strings.NewReplacer(append(secretArguments(request), environments...)...)
+	entry.Time = time.Now()
+	entry.Server = r.server
+	entry.ID, _ = json.Marshal(id)
+	entry.Params = redactJSON(entry.Params, secrets)
+	entry.Result = redactJSON(entry.Result, secrets)
+	entry.Error = secrets.Replace(entry.Error)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file.Write(append(data, '\n'))
+}
+
+// secretPairs returns the values of the environment variables with secret
+// names in environ, which commands may print, each followed by redactedValue
+func secretPairs(environ []string) []string {
+	var pairs []string
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		// Short values would redact unrelated text
+		if secretName.MatchString(name) && len(value) >= 4 {
+			pairs = append(pairs, value, redactedValue)
+		}
+	}
+	return pairs
+}
+
+// secretArguments returns the values of the arguments with secret names in
+// request, each followed by redactedValue
+func secretArguments(request json.RawMessage) []string {
+	var params struct {
+		Arguments map[string]interface{} `json:"arguments"`
+		Params    struct {
+			Arguments map[string]interface{} `json:"arguments"`
+		} `json:"params"`
+	}
+	if len(request) == 0 || json.Unmarshal(request, &params) != nil {
+		return nil
+	}
+
+	var pairs []string
+	for _, arguments := range []map[string]interface{}{params.Arguments, params.Params.Arguments} {
+		for name, value := range arguments {
+			if text, ok := value.(string); ok && secretName.MatchString(name) && len(text) >= 4 {
+				pairs = append(pairs, text, redactedValue)
+			}
+		}
+	}
+	return pairs
+}
+
+// redactJSON replaces the values of secret fields in data and the secrets
+// replaces in its strings
+func redactJSON(data json.RawMessage, secrets *strings.Replacer) json.RawMessage {
+	if len(data) == 0 {
+		return data
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return data
+	}
+	redacted, err := json.Marshal(redactValue(value, secrets))
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+func redactValue(value interface{}, secrets *strings.Replacer) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if secretName.MatchString(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field, secrets)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], secrets)
+		}
+	case string:
+		return secrets.Replace(v)
+	}
+	return value
+}
+
+// sessionCall is a tool call of a recorded session with the response it got
+type sessionCall struct {
+	Tool      string
+	Arguments map[string]interface{}
+	Result    json.RawMessage
+	Error     string
+}
+
+// readSession returns the tool calls of a session file, in the order they
+// were made, and the server they were made on
+func readSession(path string) ([]sessionCall, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open session: %w", err)
+	}
+	defer file.Close()
+
+	var calls []sessionCall
+	var serverKey string
+	pending := make(map[string]int) // Index in calls of the unanswered call of each id
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry SessionEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, "", fmt.Errorf("%s:%d: invalid session entry: %w", path, line, err)
+		}
+		if entry.Method != string(mcp.MethodToolsCall) {
+			continue
+		}
+		serverKey = entry.Server
+
+		id := string(entry.ID)
+		if entry.Type == "request" {
+			var params struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			}
+			if err := json.Unmarshal(entry.Params, &params); err != nil {
+				return nil, "", fmt.Errorf("%s:%d: invalid tool call: %w", path, line, err)
+			}
+			pending[id] = len(calls)
+			calls = append(calls, sessionCall{Tool: params.Name, Arguments: params.Arguments})
+		} else if i, exists := pending[id]; exists {
+			calls[i].Result, calls[i].Error = entry.Result, entry.Error
+			delete(pending, id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read session: %w", err)
+	}
+	return calls, serverKey, nil
+}
+
+// toolCaller calls tools on a server, like ToolsClient
+type toolCaller interface {
+	CallTool(ctx context.Context, name string, args map[string]interface{}) (*mcp.CallToolResult, error)
+}
+
+// replayCalls makes the calls again and reports which got a different
+// response than recorded, and whether all got the same. Responses are
+// compared by their text, with the secrets redacted like when recording.
+func replayCalls(ctx context.Context, caller toolCaller, calls []sessionCall, secrets *strings.Replacer) (string, bool) {
+	var b strings.Builder
+	changed := 0
+	for _, call := range calls {
+		recorded := "error: " + call.Error
+		if call.Error == "" {
+			result, err := mcp.ParseCallToolResult(&call.Result)
+			if err != nil {
+				recorded = "no response recorded"
+			} else {
+				recorded = toolOutcome(result)
+			}
+		}
+
+		var current string
+		result, err := caller.CallTool(ctx, call.Tool, call.Arguments)
+		if err != nil {
+			current = "error: " + err.Error()
+		} else {
+			current = secrets.Replace(toolOutcome(result))
+		}
+
+		// Clients wrap the server's errors in their own
+		if recorded == current || (call.Error != "" && err != nil && strings.Contains(current, call.Error)) {
+			fmt.Fprintf(&b, "✓ %s: unchanged\n", call.Tool)
+			continue
+		}
+		changed++
+		fmt.Fprintf(&b, "✗ %s: changed\n", call.Tool)
+		fmt.Fprintf(&b, "  recorded:\n%s\n", indentLines(recorded, "    "))
+		fmt.Fprintf(&b, "  current:\n%s\n", indentLines(current, "    "))
+	}
+	fmt.Fprintf(&b, "Replayed %d tool calls: %d unchanged, %d changed", len(calls), len(calls)-changed, changed)
+	return b.String(), changed == 0
+}
+
+// toolOutcome describes a tool result by its text, marking tool errors
+func toolOutcome(result *mcp.CallToolResult) string {
+	if result.IsError {
+		return "tool error: " + resultText(result)
+	}
+	return resultText(result)
+}
+
+// indentLines prefixes each line of text with indent
+func indentLines(text, indent string) string {
+	text = strings.TrimRight(text, "\n")
+	return indent + strings.ReplaceAll(text, "\n", "\n"+indent)
+}
+
+// ReplaySession makes the tool calls of a recorded session again, on a server
+// spawned in stdio mode with the current configuration, and reports the calls
+// whose responses changed. serverName selects the server, by default the one
+// the session was recorded on. The second result is false when any changed.
+func ReplaySession(path, serverName string) (string, bool, error) {
+	calls, recordedServer, err := readSession(path)
+	if err != nil {
+		return "", false, err
+	}
+	if len(calls) == 0 {
+		return "", false, fmt.Errorf("session %s has no tool calls", path)
+	}
+	if serverName == "" {
+		serverName = recordedServer
+	}
+
+	client, _, err := serverClient(serverName, true)
+	if err != nil {
+		return "", false, err
+	}
+	defer client.Close()
+	// Replays aren't recorded themselves
+	client.env = append(client.env, "MCP_RECORD_SESSION=")
+
+	report, unchanged := replayCalls(context.Background(), client, calls, strings.NewReplacer(secretPairs(os.Environ())...))
+	return report, unchanged, nil
+}
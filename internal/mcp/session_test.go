@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// serverCaller calls the tools of an in-process server the way a client does
+type serverCaller struct {
+	s *MCPLibServer
+}
+
+func (c serverCaller) CallTool(ctx context.Context, name string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	request, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": name, "arguments": args},
+	})
+	raw, _ := json.Marshal(c.s.mcpServer.HandleMessage(ctx, request))
+	var response struct {
+		Result *json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%s", response.Error.Message)
+	}
+	return mcp.ParseCallToolResult(response.Result)
+}
+
+func TestRecordSessionRedactsCommandEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("keychain secrets are not supported on Windows")
+	}
+	env := testutil.New(t)
+	env.WriteSettings(`
+[env]
+SERVICE_PASSWORD = "pa55word-value"
+
+[commands.show]
+cmd = 'echo "$DEPLOY_KEY $SERVICE_PASSWORD"'
+env = { DEPLOY_KEY = "secret://keychain/INTEROP_SESSION_TEST" }
+`)
+	// Stand in for the keychain tools, which print the stored secret
+	bin := t.TempDir()
+	for _, tool := range []string{"secret-tool", "security"} {
+		if err := os.WriteFile(filepath.Join(bin, tool), []byte("#!/bin/sh\necho k3ych41n-value\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	t.Setenv("MCP_RECORD_SESSION", "1")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	result, err := serverCaller{s}.CallTool(context.Background(), "show", nil)
+	if err != nil || !strings.Contains(resultText(result), "k3ych41n-value pa55word-value") {
+		t.Fatalf("CallTool() = %v, %v, want the secrets in the output", result, err)
+	}
+	path := s.recorder.Path()
+	s.Stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read session: %v", err)
+	}
+	if strings.Contains(string(data), "k3ych41n-value") || strings.Contains(string(data), "pa55word-value") {
+		t.Errorf("Expected the secrets of the command's env to be redacted, got:\n%s", data)
+	}
+}
+
+func TestRecordAndReplaySession(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.greet]
+cmd = 'echo "hello ${name} $DEPLOY_TOKEN"'
+arguments = [{ name = "name", type = "string" }, { name = "api_key", type = "string" }]
+`)
+	t.Setenv("MCP_SERVER_MODE", "stdio")
+	t.Setenv("MCP_SERVER_PORT", "")
+	t.Setenv("MCP_SERVER_NAME", "")
+	t.Setenv("MCP_RECORD_SESSION", "1")
+	t.Setenv("DEPLOY_TOKEN", "s3cr3t-value")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	s, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	ctx := context.Background()
+	caller := serverCaller{s}
+	caller.CallTool(ctx, "greet", map[string]interface{}{"name": "ada", "api_key": "k-123"})
+	caller.CallTool(ctx, "missing", nil)
+	path := s.recorder.Path()
+	s.Stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read session: %v", err)
+	}
+	if strings.Contains(string(data), "s3cr3t-value") || strings.Contains(string(data), "k-123") {
+		t.Errorf("Expected secrets to be redacted, got:\n%s", data)
+	}
+	if filepath.Base(filepath.Dir(path)) != sessionsDirName || !strings.HasPrefix(filepath.Base(path), "default-") {
+		t.Errorf("Unexpected session path %s", path)
+	}
+
+	calls, serverKey, err := readSession(path)
+	if err != nil {
+		t.Fatalf("readSession() error = %v", err)
+	}
+	if serverKey != "default" || len(calls) != 2 {
+		t.Fatalf("readSession() = %d calls on %q, want 2 on default", len(calls), serverKey)
+	}
+	if calls[0].Tool != "greet" || calls[0].Arguments["name"] != "ada" || calls[0].Arguments["api_key"] != redactedValue {
+		t.Errorf("Unexpected first call %+v", calls[0])
+	}
+	if calls[1].Error == "" {
+		t.Error("Expected the call of a missing tool to record its error")
+	}
+
+	// Replaying against the same configuration changes nothing
+	t.Setenv("MCP_RECORD_SESSION", "")
+	s, err = NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer s.Stop()
+	secrets := strings.NewReplacer(secretPairs(os.Environ())...)
+	report, unchanged := replayCalls(ctx, serverCaller{s}, calls, secrets)
+	if !unchanged {
+		t.Errorf("Expected an unchanged replay, got:\n%s", report)
+	}
+
+	// A changed command is reported
+	env.WriteSettings(`
+[commands.greet]
+cmd = 'echo "bye ${name}"'
+arguments = [{ name = "name", type = "string" }, { name = "api_key", type = "string" }]
+`)
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	changed, err := NewMCPLibServer()
+	if err != nil {
+		t.Fatalf("NewMCPLibServer() error = %v", err)
+	}
+	defer changed.Stop()
+	report, unchanged = replayCalls(ctx, serverCaller{changed}, calls, secrets)
+	if unchanged || !strings.Contains(report, "✗ greet: changed") || !strings.Contains(report, "1 unchanged, 1 changed") {
+		t.Errorf("Expected the greet call to change, got:\n%s", report)
+	}
+}
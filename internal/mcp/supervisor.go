@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultSuperviseInterval is how often the supervisor checks for config changes
+const DefaultSuperviseInterval = 30 * time.Second
+
+// SupervisorEvent is emitted by the supervisor as a JSON line
+type SupervisorEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"` // config_changed, restart_deferred, restarted, restart_failed
+	Server string    `json:"server"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// serverConfigView holds the settings sections that affect a single server
+type serverConfigView struct {
+	Server   settings.MCPServer
+	Commands map[string]settings.CommandConfig
+	Prompts  map[string]settings.PromptConfig
+	Projects map[string]settings.Project
+	Env      map[string]string
+	Port     int
+	JSON     bool
+	Batch    bool
+}
+
+// ServerConfigHash hashes the settings a server is built from: its own
+// section, the commands and prompts it serves, and the project aliases and
+// global env they use. serverName is empty for the default server.
+func ServerConfigHash(cfg *settings.Settings, serverName string) string {
+	view := serverConfigView{
+		Commands: make(map[string]settings.CommandConfig),
+		Prompts:  make(map[string]settings.PromptConfig),
+		Projects: cfg.Projects,
+		Env:      cfg.Env,
+	}
+	if serverName == "" {
+		view.Port, view.JSON, view.Batch = cfg.MCPPort, cfg.IsToolOutputJson, cfg.BatchTool
+	} else {
+		view.Server = cfg.MCPServers[serverName]
+	}
+	for name, cmd := range cfg.Commands {
+		if cfg.ServedBy(serverName, name, cmd) {
+			view.Commands[name] = cmd
+		}
+	}
+	for name, prompt := range cfg.Prompts {
+		if prompt.MCP.Includes(serverName) {
+			view.Prompts[name] = prompt
+		}
+	}
+
+	// Maps are encoded with sorted keys, so equal settings hash equally
+	data, _ := json.Marshal(view)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// supervisePolicy returns a server's restart settings. serverName is empty
+// for the default server.
+func supervisePolicy(cfg *settings.Settings, serverName string) (restart bool, quietHours string) {
+	if serverName == "" {
+		return cfg.RestartOnConfigChange, cfg.QuietHours
+	}
+	server := cfg.MCPServers[serverName]
+	return server.RestartOnConfigChange, server.QuietHours
+}
+
+// Supervisor restarts running MCP servers whose configuration changed
+type Supervisor struct {
+	hashes   map[string]string // Config hash each server was last started or checked with
+	deferred map[string]bool   // Servers with a restart waiting for quiet hours to end
+	out      io.Writer
+	now      func() time.Time
+	restart  func(server *Server) error
+}
+
+// NewSupervisor creates a supervisor writing events to out
+func NewSupervisor(out io.Writer) *Supervisor {
+	return &Supervisor{
+		hashes:   make(map[string]string),
+		deferred: make(map[string]bool),
+		out:      out,
+		now:      time.Now,
+		restart:  func(server *Server) error { return server.Restart() },
+	}
+}
+
+// Check compares each server's configuration with the previous check and
+// restarts the running servers that opted in, outside their quiet hours
+func (s *Supervisor) Check(cfg *settings.Settings, manager *ServerManager) {
+	for key, server := range manager.Servers {
+		hash := ServerConfigHash(cfg, server.Name)
+		previous, seen := s.hashes[key]
+		if !seen {
+			s.hashes[key] = hash
+			continue
+		}
+		if previous == hash && !s.deferred[key] {
+			continue
+		}
+
+		if !server.IsRunning() {
+			// Nothing to restart, a later start picks up the new config
+			s.hashes[key] = hash
+			delete(s.deferred, key)
+			continue
+		}
+
+		restart, quietHours := supervisePolicy(cfg, server.Name)
+		if previous != hash {
+			s.emit(key, server.LogFile, "config_changed", "")
+		}
+		if !restart {
+			s.hashes[key] = hash
+			continue
+		}
+		if settings.InQuietHours(quietHours, s.now()) {
+			if !s.deferred[key] {
+				s.emit(key, server.LogFile, "restart_deferred", "quiet hours "+quietHours)
+			}
+			s.deferred[key] = true
+			s.hashes[key] = hash
+			continue
+		}
+
+		delete(s.deferred, key)
+		s.hashes[key] = hash
+		if err := s.restart(server); err != nil {
+			s.emit(key, server.LogFile, "restart_failed", err.Error())
+			continue
+		}
+		s.emit(key, server.LogFile, "restarted", "")
+	}
+}
+
+// emit writes an event to the supervisor output and the server's log file
+func (s *Supervisor) emit(server, logFile, event, detail string) {
+	data, _ := json.Marshal(SupervisorEvent{Time: s.now(), Event: event, Server: server, Detail: detail})
+	fmt.Fprintln(s.out, string(data))
+
+	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logging.Warning("Supervisor: failed to open log file %s: %v", logFile, err)
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, string(data))
+}
+
+// Supervise reloads the settings every interval and restarts changed servers
+// until interrupted
+func Supervise(interval time.Duration) error {
+	if settings.Sandboxed() {
+		return fmt.Errorf("supervise is not available with --sandbox-config")
+	}
+	if interval <= 0 {
+		interval = DefaultSuperviseInterval
+	}
+
+	supervisor := NewSupervisor(os.Stdout)
+	check := func() {
+		cfg, err := settings.Reload()
+		if err != nil {
+			logging.Warning("Supervisor: failed to reload settings: %v", err)
+			return
+		}
+		manager, err := NewServerManager()
+		if err != nil {
+			logging.Warning("Supervisor: %v", err)
+			return
+		}
+		supervisor.Check(cfg, manager)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logging.Info("Supervising MCP servers, checking for config changes every %s. Press Ctrl+C to exit.", interval)
+	check()
+	for {
+		select {
+		case <-sigChan:
+			return nil
+		case <-ticker.C:
+			check()
+		}
+	}
+}
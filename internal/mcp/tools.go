@@ -1,23 +1,51 @@
 package mcp
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"interop/internal/settings"
 	"io"
-	"math"
 	"net/http"
+	"os"
+	"os/exec"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcpEndpoint is the path the streamable HTTP server handles MCP requests on
+const mcpEndpoint = "/mcp"
+
+const (
+	// serverOutputTail is how much of a spawned server's stderr is kept for errors
+	serverOutputTail = 4096
+	// handshakeTimeout bounds the initialize request, a spawned server that
+	// fails to start never answers it
+	handshakeTimeout = 30 * time.Second
+	// serverStopTimeout is how long a spawned server gets to exit once its
+	// stdin is closed
+	serverStopTimeout = 5 * time.Second
 )
 
-// ToolsClient represents a client for the MCP server's tools
+// ToolsClient is a client for an MCP server. It speaks MCP over streamable
+// HTTP to a running server, or over stdio to a server it spawns, and reads
+// the event stream of running servers.
 type ToolsClient struct {
-	BaseURL string
-	Client  *http.Client
+	BaseURL   string
+	Client    *http.Client
+	SessionID string // MCP session negotiated by Connect, empty before
+	mcpClient *client.Client
+
+	// Command line of the server to spawn in stdio mode, empty for HTTP
+	command      string
+	args         []string
+	env          []string
+	process      *exec.Cmd
+	serverOutput *tailWriter
 }
 
 // SetPort changes the port used by the client
@@ -25,326 +53,203 @@ func (c *ToolsClient) SetPort(port int) {
 	c.BaseURL = fmt.Sprintf("http://localhost:%d", port)
 }
 
-// SSEHandler defines a function that handles SSE events
-type SSEHandler func(event string, data string)
-
-// NewToolsClient creates a new client for the MCP server
+// NewToolsClient creates a new client for the default MCP server
 func NewToolsClient() *ToolsClient {
+	return NewServerToolsClient(settings.GetMCPPort())
+}
 
-	port := settings.GetMCPPort()
-
+// NewServerToolsClient creates a new client for the MCP server on port
+func NewServerToolsClient(port int) *ToolsClient {
+	// Tool calls run commands for up to max_tool_timeout
+	limit := settings.DefaultMaxToolTimeout
+	if cfg, err := settings.Load(); err == nil {
+		if configured, err := cfg.ToolTimeoutLimit(); err == nil {
+			limit = configured
+		}
+	}
 	return &ToolsClient{
 		BaseURL: fmt.Sprintf("http://localhost:%d", port),
 		Client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: limit + time.Minute,
 		},
 	}
 }
 
-// GetHealth checks if the MCP server is running
-func (c *ToolsClient) GetHealth() (ToolResponse, error) {
-	var response ToolResponse
+// NewStdioToolsClient creates a client that spawns the server in stdio mode
+// on Connect, the way an MCP client configured with mcp export --mode stdio
+// does. serverName is empty for the default server.
+func NewStdioToolsClient(serverName string) *ToolsClient {
+	command, args, env := stdioCommand(serverName)
+	c := &ToolsClient{command: command, args: args}
+	for key, value := range env {
+		c.env = append(c.env, key+"="+value)
+	}
+	return c
+}
 
-	// Make request to health endpoint
-	resp, err := c.Client.Get(c.BaseURL + "/health")
-	if err != nil {
-		return response, fmt.Errorf("failed to connect to MCP server: %w", err)
+// Connect performs the MCP initialize handshake. It is called by the tool
+// methods when needed, and only has to be called directly to get a SessionID.
+func (c *ToolsClient) Connect(ctx context.Context) error {
+	if c.mcpClient != nil {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	mcpClient, err := c.newMCPClient(ctx)
 	if err != nil {
-		return response, fmt.Errorf("failed to read response body: %w", err)
+		return err
+	}
+
+	handshakeCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	request := mcp.InitializeRequest{}
+	request.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	request.Params.ClientInfo = mcp.Implementation{Name: "interop", Version: "1.0.0"}
+	if _, err := mcpClient.Initialize(handshakeCtx, request); err != nil {
+		mcpClient.Close()
+		c.stopServer()
+		if c.command != "" {
+			if output := c.serverOutput.String(); output != "" {
+				return fmt.Errorf("failed to connect to spawned MCP server: %w\nServer output:\n%s", err, output)
+			}
+			return fmt.Errorf("failed to connect to spawned MCP server: %w", err)
+		}
+		return fmt.Errorf("failed to connect to MCP server at %s: %w", c.BaseURL, err)
 	}
 
-	// Parse response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return response, fmt.Errorf("failed to parse response: %w", err)
+	if httpTransport, ok := mcpClient.GetTransport().(*transport.StreamableHTTP); ok {
+		c.SessionID = httpTransport.GetSessionId()
 	}
-
-	return response, nil
+	c.mcpClient = mcpClient
+	return nil
 }
 
-// ListCommands gets all available commands
-func (c *ToolsClient) ListCommands() (ToolResponse, error) {
-	var response ToolResponse
+// newMCPClient starts the transport to the server, spawning it in stdio mode
+// when the client has a command
+func (c *ToolsClient) newMCPClient(ctx context.Context) (*client.Client, error) {
+	if c.command == "" {
+		mcpClient, err := client.NewStreamableHttpClient(c.BaseURL+mcpEndpoint, transport.WithHTTPTimeout(c.Client.Timeout))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create MCP client: %w", err)
+		}
+		if err := mcpClient.Start(ctx); err != nil {
+			return nil, fmt.Errorf("failed to start MCP client: %w", err)
+		}
+		return mcpClient, nil
+	}
 
-	// Make request to commands endpoint
-	resp, err := c.Client.Get(c.BaseURL + "/commands")
+	// The server's stdout is a pipe of our own, so it stays open for the
+	// transport until the server exits, and its logs are kept for errors
+	cmd := exec.Command(c.command, c.args...)
+	cmd.Env = append(os.Environ(), c.env...)
+	c.serverOutput = &tailWriter{}
+	cmd.Stderr = c.serverOutput
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return response, fmt.Errorf("failed to connect to MCP server: %w", err)
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	stdout, stdoutWriter, err := os.Pipe()
 	if err != nil {
-		return response, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
-
-	// Parse response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return response, fmt.Errorf("failed to parse response: %w", err)
+	cmd.Stdout = stdoutWriter
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+		return nil, fmt.Errorf("failed to spawn MCP server: %w", err)
 	}
+	stdoutWriter.Close()
+	c.process = cmd
 
-	return response, nil
-}
-
-// ExecuteCommand runs a command on the MCP server
-func (c *ToolsClient) ExecuteCommand(name string, args map[string]interface{}) (CommandResponse, error) {
-	var response CommandResponse
-
-	// Prepare request body
-	reqBody := CommandRequest{
-		Name: name,
-		Args: args,
+	mcpClient := client.NewClient(transport.NewIO(stdout, stdin, io.NopCloser(strings.NewReader(""))))
+	if err := mcpClient.Start(ctx); err != nil {
+		c.stopServer()
+		return nil, fmt.Errorf("failed to start MCP client: %w", err)
 	}
+	return mcpClient, nil
+}
 
-	// Convert to JSON
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return response, fmt.Errorf("failed to marshal request: %w", err)
+// stopServer waits for a spawned server to exit after its stdin was closed,
+// killing it when it doesn't
+func (c *ToolsClient) stopServer() error {
+	if c.process == nil {
+		return nil
 	}
+	process := c.process
+	c.process = nil
 
-	// Make request to execute endpoint
-	resp, err := c.Client.Post(c.BaseURL+"/commands/execute", "application/json", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return response, fmt.Errorf("failed to connect to MCP server: %w", err)
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(serverStopTimeout):
+		process.Process.Kill()
+		return <-exited
 	}
-	defer resp.Body.Close()
+}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return response, fmt.Errorf("failed to read response body: %w", err)
+// Close ends the MCP session, stopping a spawned server
+func (c *ToolsClient) Close() error {
+	if c.mcpClient == nil {
+		return nil
 	}
-
-	// Parse response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return response, fmt.Errorf("failed to parse response: %w", err)
+	err := c.mcpClient.Close()
+	if stopErr := c.stopServer(); err == nil {
+		err = stopErr
 	}
-
-	return response, nil
+	c.mcpClient = nil
+	c.SessionID = ""
+	return err
 }
 
-// ListTools gets all available tools
-func (c *ToolsClient) ListTools() (ToolResponse, error) {
-	var response ToolResponse
-
-	// Make request to tools endpoint
-	resp, err := c.Client.Get(c.BaseURL + "/tools/list")
-	if err != nil {
-		return response, fmt.Errorf("failed to connect to MCP server: %w", err)
+// ListTools returns the tools the server serves
+func (c *ToolsClient) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	result, err := c.mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
 	if err != nil {
-		return response, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to list tools: %w", err)
 	}
+	return result.Tools, nil
+}
 
-	// Parse response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return response, fmt.Errorf("failed to parse response: %w", err)
+// CallTool calls a tool on the server. Tool failures are reported in the
+// result's IsError, not as an error.
+func (c *ToolsClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := c.mcpClient.CallTool(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool '%s': %w", name, err)
+	}
+	return result, nil
 }
 
-// SubscribeToEvents connects to the SSE endpoint and calls the handler for each event
-func (c *ToolsClient) SubscribeToEvents(handler SSEHandler) error {
-	maxRetries := 5
-	retryCount := 0
-	var lastRetry time.Time
-
-	// Define possible SSE endpoints to try in order
-	sseEndpoints := []string{"/events", "/mcp", "/sse"}
-
-	for {
-		// Check if we've exceeded max retries
-		if retryCount >= maxRetries {
-			return fmt.Errorf("exceeded maximum retry attempts (%d)", maxRetries)
-		}
-
-		// Add exponential backoff for retries
-		if retryCount > 0 {
-			backoffTime := time.Duration(math.Pow(2, float64(retryCount-1))) * time.Second
-			if time.Since(lastRetry) < backoffTime {
-				sleepTime := backoffTime - time.Since(lastRetry)
-				fmt.Printf("Waiting %v before retry attempt %d/%d...\n", sleepTime, retryCount+1, maxRetries)
-				time.Sleep(sleepTime)
-			}
-			lastRetry = time.Now()
-		}
-
-		// Try each possible endpoint until one works
-		var lastErr error
-		var connected bool
-
-		for _, endpoint := range sseEndpoints {
-			// Log connection attempt
-			fmt.Printf("Connecting to SSE endpoint: %s%s (attempt %d/%d)\n", c.BaseURL, endpoint, retryCount+1, maxRetries)
-
-			// Create a longer-lived context for the initial connection
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-
-			// Create a transport that properly supports streaming with longer timeouts
-			transport := &http.Transport{
-				ResponseHeaderTimeout: 60 * time.Second,
-				IdleConnTimeout:       120 * time.Second,
-				DisableCompression:    true,
-			}
-
-			// Create a client without a timeout for the SSE connection itself
-			client := &http.Client{
-				Transport: transport,
-				Timeout:   0, // No timeout for the SSE connection
-			}
-
-			// Make request to events endpoint
-			req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+endpoint, nil)
-			if err != nil {
-				cancel()
-				lastErr = fmt.Errorf("failed to create request: %w", err)
-				continue // Try next endpoint
-			}
-
-			// Set headers for SSE
-			req.Header.Set("Accept", "text/event-stream")
-			req.Header.Set("Cache-Control", "no-cache")
-			req.Header.Set("Connection", "keep-alive")
-
-			// Send request
-			resp, err := client.Do(req)
-			if err != nil {
-				cancel()
-				fmt.Printf("Connection error for %s: %v\n", endpoint, err)
-				lastErr = err
-				continue // Try next endpoint
-			}
-
-			// Check response status
-			if resp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(resp.Body)
-				resp.Body.Close()
-				cancel()
-				fmt.Printf("Server returned error for %s: HTTP %d - %s\n", endpoint, resp.StatusCode, string(body))
-				lastErr = fmt.Errorf("SSE error: Non-200 status code (%d)", resp.StatusCode)
-				continue // Try next endpoint
-			}
-
-			// Verify content type
-			contentType := resp.Header.Get("Content-Type")
-			if !strings.HasPrefix(contentType, "text/event-stream") {
-				resp.Body.Close()
-				cancel()
-				fmt.Printf("Invalid content type for %s: %s (expected text/event-stream)\n", endpoint, contentType)
-				lastErr = fmt.Errorf("invalid content type: %s", contentType)
-				continue // Try next endpoint
-			}
-
-			// Connection successful
-			connected = true
-
-			// Reset retry count since we've established a connection
-			retryCount = 0
-			fmt.Printf("Successfully connected to event stream at %s. Waiting for events...\n", endpoint)
-
-			// Create a separate context for the event reading loop
-			readCtx, readCancel := context.WithCancel(context.Background())
-
-			// Start a goroutine to cancel the read context when the request context is done
-			go func() {
-				<-ctx.Done()
-				readCancel()
-			}()
-
-			// Process SSE stream
-			scanner := bufio.NewScanner(resp.Body)
-			var eventName string
-			var dataBuffer strings.Builder
-
-			// Use a done channel to signal when the scanner exits
-			done := make(chan struct{})
-
-			go func() {
-				defer close(done)
-
-				for scanner.Scan() {
-					select {
-					case <-readCtx.Done():
-						return
-					default:
-						line := scanner.Text()
-
-						// Empty line marks the end of an event
-						if line == "" {
-							if eventName != "" && dataBuffer.Len() > 0 {
-								// Process the complete event
-								handler(eventName, dataBuffer.String())
-
-								// Reset for next event
-								eventName = ""
-								dataBuffer.Reset()
-							}
-							continue
-						}
-
-						// Parse the line
-						if strings.HasPrefix(line, "event:") {
-							eventName = strings.TrimSpace(line[6:])
-						} else if strings.HasPrefix(line, "data:") {
-							// Append to data buffer (could be multiple data lines per event)
-							if dataBuffer.Len() > 0 {
-								dataBuffer.WriteString("\n")
-							}
-							dataBuffer.WriteString(strings.TrimSpace(line[5:]))
-						}
-					}
-				}
-			}()
-
-			// Wait for scanner to complete or context to be canceled
-			select {
-			case <-readCtx.Done():
-				resp.Body.Close()
-				cancel()
-				fmt.Println("Connection closed by client")
-				return nil
-			case <-done:
-				// Scanner exited
-				resp.Body.Close()
-				readCancel()
-
-				// Check for scanner error
-				if err := scanner.Err(); err != nil {
-					fmt.Printf("Stream error: %v\n", err)
-					// Don't treat EOF as an error that requires retry
-					if !strings.Contains(err.Error(), "EOF") {
-						retryCount++
-					}
-				} else {
-					fmt.Println("Stream closed by server")
-				}
-
-				cancel()
-			}
-
-			// Break out of the endpoint loop if we successfully connected
-			break
-		}
-
-		// If we didn't connect to any endpoint, increment retry counter
-		if !connected {
-			retryCount++
-			if lastErr != nil {
-				fmt.Printf("All SSE endpoints failed. Last error: %v. Retrying...\n", lastErr)
-			}
-		}
+// tailWriter keeps the last bytes written to it
+type tailWriter struct {
+	mu   sync.Mutex
+	data []byte
+}
 
-		// Wait a second before attempting to reconnect
-		time.Sleep(time.Second)
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.data = append(w.data, p...)
+	if len(w.data) > serverOutputTail {
+		w.data = w.data[len(w.data)-serverOutputTail:]
 	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(string(w.data))
 }
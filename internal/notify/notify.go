@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bell is the terminal bell character
+const bell = "\a"
+
+// Result describes a finished command run
+type Result struct {
+	Command  string        // Name of the command
+	Project  string        // Project the command ran for, empty for global commands
+	ExitCode int           // Exit code of the command, 0 on success
+	Duration time.Duration // How long the command ran
+}
+
+// Title returns the notification title, naming the command and its status
+func (r Result) Title() string {
+	status := "finished"
+	if r.ExitCode != 0 {
+		status = fmt.Sprintf("failed (exit %d)", r.ExitCode)
+	}
+	return fmt.Sprintf("interop: %s %s", r.Command, status)
+}
+
+// Message returns the notification body with the duration and project
+func (r Result) Message() string {
+	message := fmt.Sprintf("Took %s", r.Duration.Round(time.Second/10))
+	if r.Project != "" {
+		message += fmt.Sprintf(" in %s", r.Project)
+	}
+	return message
+}
+
+// Send shows a desktop notification for the result, using osascript on macOS
+// and notify-send elsewhere
+func Send(r Result) error {
+	name, args, err := command(runtime.GOOS, r.Title(), r.Message())
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s not found, desktop notifications are unavailable", name)
+	}
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Bell rings the terminal bell
+func Bell(w io.Writer) {
+	fmt.Fprint(w, bell)
+}
+
+// command returns the program and arguments showing a notification on goos
+func command(goos, title, message string) (string, []string, error) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(message), strconv.Quote(title))
+		return "osascript", []string{"-e", script}, nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return "notify-send", []string{"--app-name=interop", title, message}, nil
+	default:
+		return "", nil, fmt.Errorf("desktop notifications are not supported on %s", goos)
+	}
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		result  Result
+		title   string
+		message string
+	}{
+		{Result{Command: "build", Duration: 42 * time.Second}, "interop: build finished", "Took 42s"},
+		{Result{Command: "test", Project: "app", ExitCode: 2, Duration: 1500 * time.Millisecond}, "interop: test failed (exit 2)", "Took 1.5s in app"},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Title(); got != tt.title {
+			t.Errorf("Title() = %q, want %q", got, tt.title)
+		}
+		if got := tt.result.Message(); got != tt.message {
+			t.Errorf("Message() = %q, want %q", got, tt.message)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	name, args, err := command("darwin", "interop: build finished", `Took "42s"`)
+	if err != nil || name != "osascript" {
+		t.Fatalf("command(darwin) = %q, %v", name, err)
+	}
+	want := []string{"-e", `display notification "Took \"42s\"" with title "interop: build finished"`}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("osascript args = %q, want %q", args, want)
+	}
+
+	name, args, err = command("linux", "title", "message")
+	if err != nil || name != "notify-send" || !reflect.DeepEqual(args, []string{"--app-name=interop", "title", "message"}) {
+		t.Errorf("command(linux) = %q, %q, %v", name, args, err)
+	}
+
+	if _, _, err := command("plan9", "title", "message"); err == nil {
+		t.Error("Expected an error for an unsupported OS")
+	}
+}
+
+func TestBell(t *testing.T) {
+	var buf bytes.Buffer
+	Bell(&buf)
+	if buf.String() != "\a" {
+		t.Errorf("Bell() wrote %q", buf.String())
+	}
+}
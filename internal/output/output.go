@@ -0,0 +1,63 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is how list and status commands print their results, set with the
+// global --output flag
+type Format string
+
+const (
+	Table Format = "table" // Text for people, each command's own layout
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+)
+
+// ParseFormat returns the format named by value, table when it's empty
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case "":
+		return Table, nil
+	case Table, JSON, YAML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid output format '%s', expected table, json or yaml", value)
+	}
+}
+
+// Structured reports whether the format is meant for scripts rather than people
+func (f Format) Structured() bool {
+	return f == JSON || f == YAML
+}
+
+// Write writes v to w in a structured format: indented JSON, or YAML with
+// the same fields in the same order. Values are encoded with their JSON tags.
+func Write(w io.Writer, format Format, v interface{}) error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+
+	switch format {
+	case JSON:
+		_, err := w.Write(data.Bytes())
+		return err
+	case YAML:
+		text, err := jsonToYAML(data.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to encode yaml: %w", err)
+		}
+		_, err = io.WriteString(w, text)
+		return err
+	default:
+		return fmt.Errorf("output format '%s' isn't structured", format)
+	}
+}
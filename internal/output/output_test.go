@@ -0,0 +1,84 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for value, want := range map[string]Format{"": Table, "table": Table, "JSON": JSON, "yaml": YAML} {
+		if got, err := ParseFormat(value); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if Table.Structured() || !JSON.Structured() || !YAML.Structured() {
+		t.Error("Expected json and yaml, not table, to be structured")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	type command struct {
+		Name     string            `json:"name"`
+		Enabled  bool              `json:"enabled"`
+		Port     int               `json:"port"`
+		Cmd      string            `json:"cmd"`
+		Projects []string          `json:"projects"`
+		Env      map[string]string `json:"env,omitempty"`
+		Args     []map[string]any  `json:"args"`
+		Empty    []string          `json:"empty"`
+	}
+	value := map[string]interface{}{
+		"commands": []command{{
+			Name:     "build",
+			Enabled:  true,
+			Port:     8081,
+			Cmd:      "make build: all # fast",
+			Projects: []string{"api", "true", "1.5"},
+			Env:      map[string]string{"STAGE": "prod"},
+			Args:     []map[string]any{{"name": "target", "required": false}},
+			Empty:    []string{},
+		}},
+		"valid": false,
+		"note":  "line one\nline two",
+	}
+
+	var b bytes.Buffer
+	if err := Write(&b, YAML, value); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := `commands:
+  - name: build
+    enabled: true
+    port: 8081
+    cmd: 'make build: all # fast'
+    projects:
+      - api
+      - "true"
+      - "1.5"
+    env:
+      STAGE: prod
+    args:
+      - name: target
+        required: false
+    empty: []
+note: |-
+  line one
+  line two
+valid: false
+`
+	if b.String() != want {
+		t.Errorf("Write(yaml) =\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := Write(&b, JSON, []string{"a<b"}); err != nil || b.String() != "[\n  \"a<b\"\n]\n" {
+		t.Errorf("Write(json) = %q, %v", b.String(), err)
+	}
+	if err := Write(&b, Table, value); err == nil || !strings.Contains(err.Error(), "isn't structured") {
+		t.Errorf("Expected an error writing a table, got %v", err)
+	}
+}
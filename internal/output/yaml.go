@@ -0,0 +1,84 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonToYAML converts a JSON document to YAML, with objects keeping the
+// order of their fields
+func jsonToYAML(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := decodeNode(decoder)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// decodeNode decodes the next JSON value of decoder as a YAML node. Decoding
+// token by token rather than into maps keeps the fields of objects in order.
+func decodeNode(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if v == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		} else if v != '[' {
+			return nil, fmt.Errorf("unexpected %s", v)
+		}
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, scalarNode("!!str", fmt.Sprint(key)))
+			}
+			value, err := decodeNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		_, err = decoder.Token()
+		return node, err
+	case nil:
+		return scalarNode("!!null", "null"), nil
+	case bool:
+		return scalarNode("!!bool", fmt.Sprint(v)), nil
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return scalarNode("!!float", v.String()), nil
+		}
+		return scalarNode("!!int", v.String()), nil
+	default:
+		return scalarNode("!!str", fmt.Sprint(v)), nil
+	}
+}
+
+// scalarNode returns a YAML scalar with the given tag, quoted by the encoder
+// when it would read back as another type
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
@@ -24,6 +24,32 @@ func SetHomeDirFunc(fn HomeDirFunc) func() {
 	}
 }
 
+// ConfigDirFunc defines the function type for getting the base configuration directory
+type ConfigDirFunc func() (string, error)
+
+// configDirFunc is the function used to get the platform configuration directory
+// This can be overridden for testing
+var configDirFunc ConfigDirFunc = os.UserConfigDir
+
+// SetConfigDirFunc allows overriding the configuration directory function for testing
+func SetConfigDirFunc(fn ConfigDirFunc) func() {
+	old := configDirFunc
+	configDirFunc = fn
+	return func() {
+		configDirFunc = old
+	}
+}
+
+// appDirOverride replaces the directory returned by AppConfigDir when set
+var appDirOverride string
+
+// SetAppDirOverride makes AppConfigDir return dir without consulting the
+// platform config directory or legacy locations. An empty dir
+// restores the default behavior.
+func SetAppDirOverride(dir string) {
+	appDirOverride = dir
+}
+
 // Info contains information about a path
 type Info struct {
 	Original  string // Original path as specified by user
@@ -37,6 +63,87 @@ func HomeDir() (string, error) {
 	return homeDirFunc()
 }
 
+// ConfigHome returns the base directory for user configuration files.
+// An absolute XDG_CONFIG_HOME always takes precedence, otherwise the platform
+// default is used (~/.config on Linux, ~/Library/Application Support on macOS,
+// %AppData% on Windows).
+func ConfigHome() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+		return xdg, nil
+	}
+
+	dir, err := configDirFunc()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config directory: %w", err)
+	}
+	return dir, nil
+}
+
+// AppConfigDir returns the configuration directory for appName inside ConfigHome.
+// If that directory does not exist yet but the legacy location ~/<legacyDir>/<appName>
+// does, the legacy directory is returned so existing setups keep working until
+// MigrateAppConfigDir moves it.
+func AppConfigDir(legacyDir, appName string) (string, error) {
+	appDir, legacyAppDir, err := appConfigDirs(legacyDir, appName)
+	if err != nil || legacyAppDir == "" {
+		return appDir, err
+	}
+	return legacyAppDir, nil
+}
+
+// MigrateAppConfigDir moves the legacy directory AppConfigDir falls back to
+// into ConfigHome, returning the directory it moved, or "" when there was
+// nothing to move
+func MigrateAppConfigDir(legacyDir, appName string) (string, error) {
+	appDir, legacyAppDir, err := appConfigDirs(legacyDir, appName)
+	if err != nil || legacyAppDir == "" {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(appDir), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.Rename(legacyAppDir, appDir); err != nil {
+		return "", fmt.Errorf("failed to move %s to %s: %w", legacyAppDir, appDir, err)
+	}
+	return legacyAppDir, nil
+}
+
+// appConfigDirs returns the configuration directory for appName inside
+// ConfigHome, and the legacy directory in use instead of it, if any
+func appConfigDirs(legacyDir, appName string) (string, string, error) {
+	if appDirOverride != "" {
+		return appDirOverride, "", nil
+	}
+
+	base, err := ConfigHome()
+	if err != nil {
+		return "", "", err
+	}
+	appDir := filepath.Join(base, appName)
+
+	if legacyDir == "" {
+		return appDir, "", nil
+	}
+
+	homeDir, err := HomeDir()
+	if err != nil {
+		return appDir, "", nil
+	}
+	legacyAppDir := filepath.Join(homeDir, legacyDir, appName)
+	if legacyAppDir == appDir {
+		return appDir, "", nil
+	}
+
+	if _, err := os.Stat(appDir); err == nil {
+		return appDir, "", nil
+	}
+	if info, err := os.Stat(legacyAppDir); err != nil || !info.IsDir() {
+		return appDir, "", nil
+	}
+	return appDir, legacyAppDir, nil
+}
+
 // Expand expands a path with tilde expansion and converts to absolute path
 func Expand(path string) (string, error) {
 	// Get user home directory
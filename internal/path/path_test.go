@@ -142,3 +142,94 @@ func TestCreateDirectories(t *testing.T) {
 		t.Errorf("CreateDirectories() failed to create directory: %v", testPath)
 	}
 }
+
+func TestConfigHome(t *testing.T) {
+	t.Run("XDG_CONFIG_HOME takes precedence", func(t *testing.T) {
+		xdgDir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", xdgDir)
+
+		dir, err := ConfigHome()
+		if err != nil {
+			t.Fatalf("ConfigHome() error = %v", err)
+		}
+		if dir != xdgDir {
+			t.Errorf("ConfigHome() got = %v, want %v", dir, xdgDir)
+		}
+	})
+
+	t.Run("Relative XDG_CONFIG_HOME is ignored", func(t *testing.T) {
+		platformDir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", "relative/config")
+		restore := SetConfigDirFunc(func() (string, error) { return platformDir, nil })
+		defer restore()
+
+		dir, err := ConfigHome()
+		if err != nil {
+			t.Fatalf("ConfigHome() error = %v", err)
+		}
+		if dir != platformDir {
+			t.Errorf("ConfigHome() got = %v, want %v", dir, platformDir)
+		}
+	})
+}
+
+func TestAppConfigDirMigratesLegacyDirectory(t *testing.T) {
+	homeDir := t.TempDir()
+	xdgDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdgDir)
+	restore := SetHomeDirFunc(func() (string, error) { return homeDir, nil })
+	defer restore()
+
+	legacyDir := filepath.Join(homeDir, ".config", "interop")
+	if err := os.MkdirAll(legacyDir, 0o755); err != nil {
+		t.Fatalf("Failed to create legacy dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyDir, "settings.toml"), []byte("log_level = \"error\"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+
+	// The legacy directory is used where it is until it's migrated
+	dir, err := AppConfigDir(".config", "interop")
+	if err != nil {
+		t.Fatalf("AppConfigDir() error = %v", err)
+	}
+	if dir != legacyDir {
+		t.Errorf("AppConfigDir() before migrating = %v, want %v", dir, legacyDir)
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, "settings.toml")); err != nil {
+		t.Errorf("Expected AppConfigDir to leave the legacy directory in place: %v", err)
+	}
+
+	from, err := MigrateAppConfigDir(".config", "interop")
+	if err != nil || from != legacyDir {
+		t.Fatalf("MigrateAppConfigDir() = %q, %v; want %q", from, err, legacyDir)
+	}
+	want := filepath.Join(xdgDir, "interop")
+	if dir, err := AppConfigDir(".config", "interop"); err != nil || dir != want {
+		t.Errorf("AppConfigDir() after migrating = %v, %v; want %v", dir, err, want)
+	}
+	if _, err := os.Stat(filepath.Join(want, "settings.toml")); err != nil {
+		t.Errorf("Expected settings.toml to be migrated: %v", err)
+	}
+	if _, err := os.Stat(legacyDir); !os.IsNotExist(err) {
+		t.Errorf("Expected legacy directory to be moved, stat err = %v", err)
+	}
+
+	if from, err := MigrateAppConfigDir(".config", "interop"); err != nil || from != "" {
+		t.Errorf("MigrateAppConfigDir() again = %q, %v; want nothing to move", from, err)
+	}
+}
+
+func TestAppConfigDirOverride(t *testing.T) {
+	override := t.TempDir()
+	SetAppDirOverride(override)
+	defer SetAppDirOverride("")
+
+	dir, err := AppConfigDir(".config", "interop")
+	if err != nil {
+		t.Fatalf("AppConfigDir() error = %v", err)
+	}
+	if dir != override {
+		t.Errorf("AppConfigDir() = %s, want %s", dir, override)
+	}
+}
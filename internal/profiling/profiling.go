@@ -0,0 +1,82 @@
+// Package profiling writes Go runtime profiles of an interop invocation, see
+// the hidden --profile flag
+package profiling
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+)
+
+// defaultPaths are where each kind of profile is written unless a path is given
+var defaultPaths = map[string]string{
+	"cpu":   "interop-cpu.pprof",
+	"mem":   "interop-mem.pprof",
+	"trace": "interop.trace",
+}
+
+// Start starts the profile spec asks for: "cpu", "mem" or "trace", optionally
+// followed by =PATH to write it to. The returned function stops profiling and
+// writes the profile, and must be called before the program exits.
+func Start(spec string) (func(), error) {
+	kind, path, _ := strings.Cut(spec, "=")
+	if _, known := defaultPaths[kind]; !known {
+		return nil, fmt.Errorf("unknown profile '%s', expected cpu, mem or trace", kind)
+	}
+	if path == "" {
+		path = defaultPaths[kind]
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+
+	var stop func() error
+	switch kind {
+	case "cpu":
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		stop = func() error {
+			pprof.StopCPUProfile()
+			return nil
+		}
+	case "mem":
+		stop = func() error {
+			// Up to date statistics of what is still allocated
+			runtime.GC()
+			return pprof.WriteHeapProfile(file)
+		}
+	case "trace":
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to start trace: %w", err)
+		}
+		stop = func() error {
+			trace.Stop()
+			return nil
+		}
+	}
+
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		err := stop()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s profile: %v\n", kind, err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s profile to %s\n", kind, path)
+	}, nil
+}
@@ -0,0 +1,29 @@
+package profiling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	dir := t.TempDir()
+	for _, kind := range []string{"cpu", "mem", "trace"} {
+		path := filepath.Join(dir, kind+".out")
+		stop, err := Start(kind + "=" + path)
+		if err != nil {
+			t.Fatalf("Start(%q) error = %v", kind, err)
+		}
+		stop()
+		stop()
+
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 {
+			t.Errorf("Expected a %s profile at %s, got %v", kind, path, err)
+		}
+	}
+
+	if _, err := Start("heap"); err == nil {
+		t.Error("Start() with an unknown profile expected an error")
+	}
+}
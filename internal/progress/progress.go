@@ -0,0 +1,190 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Color codes for terminal output
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorGreen  = "\033[32m"
+	colorCyan   = "\033[36m"
+)
+
+// clearLine returns the cursor to the start of the line and erases it
+const clearLine = "\r\033[K"
+
+// frameInterval is how often the spinner advances
+const frameInterval = 100 * time.Millisecond
+
+// frames are the spinner animation frames
+var frames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+var (
+	output      io.Writer = os.Stderr
+	interactive           = isTerminal(os.Stderr)
+	useColors             = interactive && os.Getenv("NO_COLOR") == ""
+
+	// active is the step currently animating, at most one at a time
+	activeMu sync.Mutex
+	active   *Step
+)
+
+// isTerminal reports whether f is a terminal that can render the spinner
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// DisableColors turns off color in progress output
+func DisableColors() {
+	useColors = false
+}
+
+// colorize wraps text in a color code when colors are enabled
+func colorize(color, text string) string {
+	if !useColors {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// Pause stops the animation of the active step, if any, and clears its line so
+// other output such as prompts can be written. The next Update resumes it.
+func Pause() {
+	activeMu.Lock()
+	step := active
+	activeMu.Unlock()
+	if step != nil {
+		step.halt()
+	}
+}
+
+// Step reports the status of a long running operation on stderr. On a
+// terminal it animates a spinner next to the current status; otherwise only
+// the final outcome is printed so logs stay readable.
+type Step struct {
+	mu      sync.Mutex
+	message string
+	started time.Time
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Start begins a step with the given status message
+func Start(format string, args ...interface{}) *Step {
+	Pause()
+	s := &Step{message: fmt.Sprintf(format, args...), started: time.Now()}
+	s.animate()
+	return s
+}
+
+// Update changes the status message shown for the step
+func (s *Step) Update(format string, args ...interface{}) {
+	s.mu.Lock()
+	s.message = fmt.Sprintf(format, args...)
+	s.mu.Unlock()
+	s.animate()
+}
+
+// Done finishes the step successfully. An empty format keeps the last status.
+func (s *Step) Done(format string, args ...interface{}) {
+	s.finish(colorGreen, "✓", format, args...)
+}
+
+// Warn finishes the step with a warning. An empty format keeps the last status.
+func (s *Step) Warn(format string, args ...interface{}) {
+	s.finish(colorYellow, "!", format, args...)
+}
+
+// Fail finishes the step unsuccessfully. An empty format keeps the last status.
+func (s *Step) Fail(format string, args ...interface{}) {
+	s.finish(colorRed, "✗", format, args...)
+}
+
+// animate starts the spinner goroutine unless it is already running
+func (s *Step) animate() {
+	if !interactive {
+		return
+	}
+
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	s.stop, s.stopped = stop, stopped
+	s.mu.Unlock()
+
+	activeMu.Lock()
+	active = s
+	activeMu.Unlock()
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(frameInterval)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			s.mu.Lock()
+			message := s.message
+			s.mu.Unlock()
+			fmt.Fprintf(output, "%s%s %s", clearLine, colorize(colorCyan, frames[i%len(frames)]), message)
+
+			select {
+			case <-stop:
+				fmt.Fprint(output, clearLine)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// halt stops the spinner goroutine and waits for it to clear its line
+func (s *Step) halt() {
+	s.mu.Lock()
+	stop, stopped := s.stop, s.stopped
+	s.stop, s.stopped = nil, nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// finish stops the step and prints its outcome with the elapsed time
+func (s *Step) finish(color, symbol, format string, args ...interface{}) {
+	s.halt()
+
+	activeMu.Lock()
+	if active == s {
+		active = nil
+	}
+	activeMu.Unlock()
+
+	s.mu.Lock()
+	message := s.message
+	s.mu.Unlock()
+	if format != "" {
+		message = fmt.Sprintf(format, args...)
+	}
+
+	if elapsed := time.Since(s.started); elapsed >= time.Second {
+		message = fmt.Sprintf("%s (%s)", message, elapsed.Round(100*time.Millisecond))
+	}
+	fmt.Fprintf(output, "%s %s\n", colorize(color, symbol), message)
+}
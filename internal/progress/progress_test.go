@@ -0,0 +1,56 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture redirects progress output to a buffer for the duration of a test
+func capture(t *testing.T, tty bool) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	prevOutput, prevInteractive, prevColors := output, interactive, useColors
+	output, interactive, useColors = buf, tty, false
+	t.Cleanup(func() {
+		output, interactive, useColors = prevOutput, prevInteractive, prevColors
+	})
+	return buf
+}
+
+func TestStepNonInteractive(t *testing.T) {
+	buf := capture(t, false)
+
+	step := Start("Cloning %s", "repo")
+	step.Update("Syncing files")
+	step.Done("")
+	Start("Fetching").Fail("Fetch of '%s' failed", "origin")
+
+	expected := "✓ Syncing files\n✗ Fetch of 'origin' failed\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestStepInteractive(t *testing.T) {
+	buf := capture(t, true)
+
+	step := Start("Validating configuration")
+	time.Sleep(2 * frameInterval)
+	Pause()
+	paused := buf.Len()
+	time.Sleep(2 * frameInterval)
+	if buf.Len() != paused {
+		t.Errorf("expected no output while paused")
+	}
+	step.Warn("Validated with warnings")
+
+	out := buf.String()
+	if !strings.Contains(out, frames[0]+" Validating configuration") {
+		t.Errorf("expected spinner frame in output, got %q", out)
+	}
+	if !strings.HasSuffix(out, clearLine+"! Validated with warnings\n") {
+		t.Errorf("expected cleared line followed by the outcome, got %q", out)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"interop/internal/logging"
 	"interop/internal/path"
 	"interop/internal/settings"
+	"sort"
 )
 
 // List prints out all configured projects with their name, path, and validity
@@ -35,31 +36,64 @@ func List(cfg *settings.Settings) {
 			valid = "✗"
 		}
 
-		inHomeDir := "✓"
-		if !pathInfo.InHomeDir {
-			inHomeDir = "✗"
+		allowed := "✓"
+		if !cfg.IsProjectPathAllowed(pathInfo.Absolute) {
+			allowed = "✗"
 		}
 
 		// Print project details using display package
 		display.PrintProjectName(name)
 		display.PrintProjectPath(project.Path)
-		display.PrintProjectStatus(valid, inHomeDir)
+		display.PrintProjectStatus(valid, allowed)
 		display.PrintProjectDescription(project.Description)
 
 		display.PrintSeparator()
 	}
 }
 
-// ListWithCommands prints out all configured projects with their commands
-func ListWithCommands(cfg *settings.Settings) {
-	if len(cfg.Projects) == 0 {
-		display.PrintNoItemsFound("projects")
-		return
-	}
+// ListOptions controls which project commands ListWithCommands shows and
+// how much of them
+type ListOptions struct {
+	OnlyEnabled bool // Hide disabled commands and references to missing ones
+	Verbose     bool // Show what each command runs and where it's defined
+}
 
-	display.PrintProjectHeader()
+// Summary is a project as interop projects lists it
+type Summary struct {
+	Name        string           `json:"name"`
+	Path        string           `json:"path"`
+	Description string           `json:"description,omitempty"`
+	Exists      bool             `json:"exists"`           // The path exists
+	Allowed     bool             `json:"in_allowed_roots"` // The path is under one of allowed_project_roots
+	Commands    []CommandSummary `json:"commands"`
+	Hidden      int              `json:"hidden_commands,omitempty"` // Commands left out by OnlyEnabled
+}
 
-	for name, project := range cfg.Projects {
+// CommandSummary is a command bound to a project
+type CommandSummary struct {
+	Name        string   `json:"name"`
+	Alias       string   `json:"alias,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	Missing     bool     `json:"missing,omitempty"` // The referenced command isn't defined
+	Cmd         string   `json:"cmd,omitempty"`
+	SourceFile  string   `json:"source_file,omitempty"`
+	SourceLine  int      `json:"source_line,omitempty"`
+	Shadowed    []string `json:"shadowed,omitempty"` // Lower priority definitions of the command
+}
+
+// Summaries returns the configured projects with their commands, sorted by
+// name. Projects whose path can't be expanded are left out with a warning.
+func Summaries(cfg *settings.Settings, opts ListOptions) []Summary {
+	names := make([]string, 0, len(cfg.Projects))
+	for name := range cfg.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summaries := make([]Summary, 0, len(names))
+	for _, name := range names {
+		project := cfg.Projects[name]
 		// Use the path package to validate and expand the path
 		pathInfo, err := path.ExpandAndValidate(project.Path)
 		if err != nil {
@@ -67,42 +101,86 @@ func ListWithCommands(cfg *settings.Settings) {
 			continue
 		}
 
-		valid := "✓"
-		if !pathInfo.Exists {
-			valid = "✗"
+		summary := Summary{
+			Name:        name,
+			Path:        project.Path,
+			Description: project.Description,
+			Exists:      pathInfo.Exists,
+			Allowed:     cfg.IsProjectPathAllowed(pathInfo.Absolute),
+			Commands:    []CommandSummary{},
 		}
-
-		inHomeDir := "✓"
-		if !pathInfo.InHomeDir {
-			inHomeDir = "✗"
+		commands, _ := settings.ResolveProjectCommands(cfg, name)
+		for _, cmd := range commands {
+			if opts.OnlyEnabled && (cmd.Missing || !cmd.Config.IsEnabled) {
+				summary.Hidden++
+				continue
+			}
+			commandSummary := CommandSummary{Name: cmd.CommandName, Alias: cmd.Alias.Alias, Missing: cmd.Missing}
+			if !cmd.Missing {
+				commandSummary.Description = cmd.Config.Description
+				commandSummary.Enabled = cmd.Config.IsEnabled
+				commandSummary.Cmd = cmd.Config.Cmd
+				commandSummary.SourceFile = cmd.Config.SourceFile
+				commandSummary.SourceLine = cmd.Config.SourceLine
+				commandSummary.Shadowed = cmd.Config.Shadowed
+			}
+			summary.Commands = append(summary.Commands, commandSummary)
 		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
 
+// ListWithCommands prints out all configured projects with their commands.
+// References to commands that aren't defined are marked with a warning.
+func ListWithCommands(cfg *settings.Settings, opts ListOptions) {
+	if len(cfg.Projects) == 0 {
+		display.PrintNoItemsFound("projects")
+		return
+	}
+
+	display.PrintProjectHeader()
+
+	for _, summary := range Summaries(cfg, opts) {
 		// Print project details using display package
-		display.PrintProjectName(name)
-		display.PrintProjectPath(project.Path)
-		display.PrintProjectStatus(valid, inHomeDir)
-		display.PrintProjectDescription(project.Description)
+		display.PrintProjectName(summary.Name)
+		display.PrintProjectPath(summary.Path)
+		display.PrintProjectStatus(mark(summary.Exists), mark(summary.Allowed))
+		display.PrintProjectDescription(summary.Description)
 
 		// Display commands for this project
-		if len(project.Commands) > 0 {
+		if len(summary.Commands) > 0 || summary.Hidden > 0 {
 			// Print commands header
 			display.PrintCommandProjects([]string{})
 
-			for _, alias := range project.Commands {
-				cmd, exists := cfg.Commands[alias.CommandName]
-				if !exists {
-					display.PrintUnresolvedCommand(alias.CommandName)
+			for _, cmd := range summary.Commands {
+				if cmd.Missing {
+					display.PrintUnresolvedCommand(cmd.Name, cmd.Alias)
 					continue
 				}
 
-				display.PrintProjectCommands(alias.CommandName, alias.Alias, cmd.Description)
+				display.PrintProjectCommands(cmd.Name, cmd.Alias, cmd.Description, cmd.Enabled)
+				if opts.Verbose {
+					display.PrintProjectCommandDetails(cmd.Cmd, cmd.SourceFile, cmd.SourceLine, cmd.Shadowed)
+				}
 			}
+			display.PrintHiddenCommands(summary.Hidden)
+		} else {
+			display.PrintProjectWithoutCommands()
 		}
 
 		display.PrintSeparator()
 	}
 }
 
+// mark renders a check as ✓ or ✗
+func mark(ok bool) string {
+	if ok {
+		return "✓"
+	}
+	return "✗"
+}
+
 // ListWithCustomHomeDir is used for testing to allow overriding the home directory
 func ListWithCustomHomeDir(cfg *settings.Settings, homeDirFunc func() (string, error)) {
 	if len(cfg.Projects) == 0 {
@@ -129,15 +207,15 @@ func ListWithCustomHomeDir(cfg *settings.Settings, homeDirFunc func() (string, e
 			valid = "✗"
 		}
 
-		inHomeDirStr := "✓"
-		if !pathInfo.InHomeDir {
-			inHomeDirStr = "✗"
+		allowed := "✓"
+		if !cfg.IsProjectPathAllowed(pathInfo.Absolute) {
+			allowed = "✗"
 		}
 
 		// Print project details using display package
 		display.PrintProjectName(name)
 		display.PrintProjectPath(project.Path)
-		display.PrintProjectStatus(valid, inHomeDirStr)
+		display.PrintProjectStatus(valid, allowed)
 		display.PrintProjectDescription(project.Description)
 
 		display.PrintSeparator()
@@ -61,6 +61,7 @@ func TestListWithCustomHomeDir(t *testing.T) {
 		name           string
 		projects       map[string]settings.Project
 		homeDir        string
+		roots          []string // allowed_project_roots, the default $HOME when empty
 		expectedOutput []string
 		notExpected    []string
 	}{
@@ -83,7 +84,7 @@ func TestListWithCustomHomeDir(t *testing.T) {
 				"PROJECTS:",
 				"Name: valid",
 				"Valid: ✓",
-				"In $HOME: ✓",
+				"In allowed roots: ✓",
 				"Description: A valid project",
 			},
 		},
@@ -100,7 +101,7 @@ func TestListWithCustomHomeDir(t *testing.T) {
 				"PROJECTS:",
 				"Name: invalid",
 				"Valid: ✗",
-				"In $HOME: ✓",
+				"In allowed roots: ✓",
 				"Description: A non-existent project",
 			},
 		},
@@ -116,10 +117,25 @@ func TestListWithCustomHomeDir(t *testing.T) {
 			expectedOutput: []string{
 				"PROJECTS:",
 				"Name: outside",
-				"In $HOME: ✗",
+				"In allowed roots: ✗",
 				"Description: A project outside home",
 			},
 		},
+		{
+			name: "Project under another allowed root",
+			projects: map[string]settings.Project{
+				"outside": {
+					Path:        "/tmp/outside-home",
+					Description: "A project outside home",
+				},
+			},
+			homeDir: tempHomeDir,
+			roots:   []string{tempHomeDir, "/tmp"},
+			expectedOutput: []string{
+				"Name: outside",
+				"In allowed roots: ✓",
+			},
+		},
 		{
 			name: "Relative path project",
 			projects: map[string]settings.Project{
@@ -134,7 +150,7 @@ func TestListWithCustomHomeDir(t *testing.T) {
 				"Name: relative",
 				"Path: valid-project",
 				"Valid: ✓",
-				"In $HOME: ✓",
+				"In allowed roots: ✓",
 				"Description: A project with relative path",
 			},
 		},
@@ -144,8 +160,13 @@ func TestListWithCustomHomeDir(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create test settings
+			roots := tt.roots
+			if len(roots) == 0 {
+				roots = []string{tt.homeDir}
+			}
 			cfg := &settings.Settings{
-				Projects: tt.projects,
+				Projects:            tt.projects,
+				AllowedProjectRoots: roots,
 			}
 
 			// Create a mock home directory function
@@ -186,3 +207,96 @@ func TestListWithCustomHomeDir(t *testing.T) {
 		})
 	}
 }
+
+func TestListWithCommands(t *testing.T) {
+	cfg := &settings.Settings{
+		Projects: map[string]settings.Project{
+			"app": {
+				Path: t.TempDir(),
+				Commands: []settings.Alias{
+					{CommandName: "build"},
+					{CommandName: "deploy", Alias: "ship"},
+					{CommandName: "lint", Alias: "l"},
+				},
+			},
+		},
+		Commands: map[string]settings.CommandConfig{
+			"build":  {Cmd: "go build ./...", IsEnabled: true},
+			"deploy": {Cmd: "./deploy.sh", IsEnabled: false},
+		},
+	}
+
+	capture := func(opts ListOptions) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		ListWithCommands(cfg, opts)
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	output := capture(ListOptions{})
+	for _, want := range []string{"⚡ build", "✗ deploy (alias: ship) (disabled)", "⚠️ lint (alias: l, referenced command not found)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+	if strings.Contains(output, "Cmd:") {
+		t.Errorf("Expected no command details without verbose, got %q", output)
+	}
+
+	output = capture(ListOptions{OnlyEnabled: true, Verbose: true})
+	if strings.Contains(output, "deploy") || strings.Contains(output, "lint") {
+		t.Errorf("Expected only enabled commands, got %q", output)
+	}
+	for _, want := range []string{"Cmd: go build ./...", "(2 disabled or missing command(s) hidden)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	root := t.TempDir()
+	cfg := &settings.Settings{
+		AllowedProjectRoots: []string{root},
+		Projects: map[string]settings.Project{
+			"web": {Path: filepath.Join(root, "web")},
+			"app": {
+				Path: t.TempDir(),
+				Commands: []settings.Alias{
+					{CommandName: "build"},
+					{CommandName: "deploy", Alias: "ship"},
+					{CommandName: "lint"},
+				},
+			},
+		},
+		Commands: map[string]settings.CommandConfig{
+			"build":  {Cmd: "go build ./...", IsEnabled: true},
+			"deploy": {Cmd: "./deploy.sh", IsEnabled: false},
+		},
+	}
+
+	summaries := Summaries(cfg, ListOptions{})
+	if len(summaries) != 2 || summaries[0].Name != "app" || summaries[1].Name != "web" {
+		t.Fatalf("Summaries() = %+v, want app then web", summaries)
+	}
+	commands := summaries[0].Commands
+	if len(commands) != 3 || commands[0].Cmd != "go build ./..." || commands[1].Alias != "ship" || !commands[2].Missing {
+		t.Errorf("Summaries() commands = %+v", commands)
+	}
+	if summaries[0].Allowed || !summaries[1].Allowed {
+		t.Errorf("Summaries() allowed = %v, %v; want only web under the allowed root", summaries[0].Allowed, summaries[1].Allowed)
+	}
+	if summaries[1].Commands == nil {
+		t.Error("Expected an empty list of commands rather than nil")
+	}
+
+	summaries = Summaries(cfg, ListOptions{OnlyEnabled: true})
+	if len(summaries[0].Commands) != 1 || summaries[0].Hidden != 2 {
+		t.Errorf("Summaries(OnlyEnabled) = %+v, want build with 2 hidden", summaries[0])
+	}
+}
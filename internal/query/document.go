@@ -0,0 +1,78 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interop/internal/settings"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// namedTables are the tables of settings keyed by name. Queries see them as
+// lists of entries sorted by name, each with its name under "name".
+var namedTables = []string{"commands", "projects", "project_templates", "prompts", "mcp_servers"}
+
+// Document returns the data queries run against: the loaded settings, with
+// the keys used in settings.toml, where commands, projects, project_templates,
+// prompts and mcp_servers are lists. Commands, projects and prompts also tell
+// where they were defined under "source".
+func Document(cfg *settings.Settings) (map[string]interface{}, error) {
+	// Going through TOML keeps the keys people write in their settings
+	var encoded bytes.Buffer
+	if err := toml.NewEncoder(&encoded).Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode the settings: %w", err)
+	}
+	var decoded map[string]interface{}
+	if _, err := toml.Decode(encoded.String(), &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode the settings: %w", err)
+	}
+	// And through JSON turns the values into those expressions handle
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the settings: %w", err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode the settings: %w", err)
+	}
+
+	sources := map[string]map[string]string{
+		"commands": {},
+		"projects": {},
+		"prompts":  {},
+	}
+	for name, cmd := range cfg.Commands {
+		sources["commands"][name] = cmd.Location()
+	}
+	for name, project := range cfg.Projects {
+		sources["projects"][name] = project.Location()
+	}
+	for name, prompt := range cfg.Prompts {
+		sources["prompts"][name] = prompt.Location()
+	}
+
+	for _, table := range namedTables {
+		entries, _ := document[table].(map[string]interface{})
+		list := make([]interface{}, 0, len(entries))
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			entry, ok := entries[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entry["name"] = name
+			if source := sources[table][name]; source != "" {
+				entry["source"] = source
+			}
+			list = append(list, entry)
+		}
+		document[table] = list
+	}
+	return document, nil
+}
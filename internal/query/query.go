@@ -0,0 +1,99 @@
+// Package query evaluates JMESPath expressions over the loaded configuration
+// for interop query, so scripts can read it without parsing human output.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// Expression is a parsed query
+type Expression struct {
+	text     string
+	compiled *jmespath.JMESPath
+}
+
+// Compile parses expr
+func Compile(expr string) (*Expression, error) {
+	compiled, err := jmespath.Compile(expr)
+	if err != nil {
+		return nil, syntaxError(err)
+	}
+	return &Expression{text: expr, compiled: compiled}, nil
+}
+
+// String returns the expression as written
+func (e *Expression) String() string {
+	return e.text
+}
+
+// Search evaluates the expression against data, which holds the values
+// encoding/json decodes into an interface{}
+func (e *Expression) Search(data interface{}) (interface{}, error) {
+	return e.compiled.Search(data)
+}
+
+// Search compiles expr and evaluates it against data
+func Search(expr string, data interface{}) (interface{}, error) {
+	compiled, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Search(data)
+}
+
+// syntaxError returns err with the expression and a caret under the column
+// of a syntax error, which go-jmespath only gives by HighlightLocation
+func syntaxError(err error) error {
+	if syntax, ok := err.(jmespath.SyntaxError); ok {
+		return fmt.Errorf("%s\n%s", syntax.Error(), syntax.HighlightLocation())
+	}
+	return err
+}
+
+// Format renders a query result as indented JSON. With raw, strings are
+// printed without quotes and lists of strings and numbers one item per line,
+// for use in shell scripts; null prints nothing.
+func Format(value interface{}, raw bool) (string, error) {
+	if raw {
+		if text, ok := rawText(value); ok {
+			return text, nil
+		}
+		if list, ok := value.([]interface{}); ok {
+			lines := make([]string, 0, len(list))
+			for _, item := range list {
+				text, ok := rawText(item)
+				if !ok {
+					lines = nil
+					break
+				}
+				lines = append(lines, text)
+			}
+			if lines != nil || len(list) == 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+		}
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the result: %w", err)
+	}
+	return string(data), nil
+}
+
+// rawText returns scalars as printed by Format with raw
+func rawText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case float64, bool:
+		data, _ := json.Marshal(v)
+		return string(data), true
+	}
+	return "", false
+}
@@ -0,0 +1,135 @@
+package query
+
+import (
+	"encoding/json"
+	"interop/internal/settings"
+	"strings"
+	"testing"
+)
+
+func testSettings() *settings.Settings {
+	return &settings.Settings{
+		MCPPort: 8081,
+		Commands: map[string]settings.CommandConfig{
+			"build": {IsEnabled: true, Cmd: "make build", MCP: settings.ServerList{"ci"}, Tags: []string{"go"}},
+			"test":  {IsEnabled: true, Cmd: "make test", MCP: settings.ServerList{"ci"}, Tags: []string{"go", "slow"}},
+			"deploy": {
+				IsEnabled: false,
+				Cmd:       "deploy.sh",
+				Arguments: []settings.CommandArgument{{Name: "env", Required: true}},
+			},
+		},
+		Projects: map[string]settings.Project{
+			"api": {Path: "~/code/api", Commands: []settings.Alias{{CommandName: "build", Alias: "b"}}},
+		},
+		MCPServers: map[string]settings.MCPServer{
+			"ci": {Name: "ci", Port: 8082},
+		},
+	}
+}
+
+func TestSearch(t *testing.T) {
+	document, err := Document(testSettings())
+	if err != nil {
+		t.Fatalf("Document() error = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"mcp_port", `8081`},
+		{"commands[*].name", `["build","deploy","test"]`},
+		{"commands[?mcp==`\"ci\"`].name", `["build","test"]`},
+		{"commands[?mcp=='ci' && contains(tags, 'slow')].name", `["test"]`},
+		{"commands[?!is_enabled].name", `["deploy"]`},
+		{"commands[?arguments[?required]].name", `["deploy"]`},
+		{"commands[0].cmd", `"make build"`},
+		{"commands[-1].name", `"test"`},
+		{"commands[1:].name", `["deploy","test"]`},
+		{"commands[*].tags[]", `["go","go","slow"]`},
+		{"commands[*].[name, mcp]", `[["build","ci"],["deploy",null],["test","ci"]]`},
+		{"mcp_servers[*].{name: name, port: port}", `[{"name":"ci","port":8082}]`},
+		{"projects[0].commands[0].alias", `"b"`},
+		{"length(commands)", `3`},
+		{"commands[*].name | join(', ', @)", `"build, deploy, test"`},
+		{"commands[?mcp].mcp | [0]", `"ci"`},
+		{"sort(commands[*].cmd)", `["deploy.sh","make build","make test"]`},
+		{"mcp_servers[?port > `8081`].name", `["ci"]`},
+		{"commands[?starts_with(name, 'de')].name", `["deploy"]`},
+		{"commands[?contains(tags || `[]`, 'slow')].name", `["test"]`},
+		{"commands[::-1].name", `["test","deploy","build"]`},
+		{"sort_by(commands, &cmd)[*].name", `["deploy","build","test"]`},
+		{"max_by(mcp_servers, &port).name", `"ci"`},
+		{"to_number('8') > `7`", `true`},
+		{"commands[?name > 'c'].name", `[]`},
+		{"missing.field", `null`},
+		{"commands[?source].name", `[]`},
+		{`"mcp_port"`, `8081`},
+	}
+	for _, tt := range tests {
+		got, err := Search(tt.expr, document)
+		if err != nil {
+			t.Errorf("Search(%q) error = %v", tt.expr, err)
+			continue
+		}
+		data, _ := json.Marshal(got)
+		if string(data) != tt.want {
+			t.Errorf("Search(%q) = %s, want %s", tt.expr, data, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"commands[", "commands[\n         ^"},
+		{"commands.#", "Unknown char: '#'"},
+		{"commands]", "Unexpected token at the end of the expression"},
+		{"'open", "Unclosed delimiter: '"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+
+	// Functions are checked when the expression runs
+	for expr, want := range map[string]string{
+		"size(commands)":             "unknown function: size",
+		"length(commands, projects)": "incorrect number of args",
+		"contains(missing, 'go')":    "Invalid type",
+	} {
+		if _, err := Search(expr, map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Search(%q) error = %v, want %q", expr, err, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		raw   bool
+		want  string
+	}{
+		{"build", false, `"build"`},
+		{"build", true, "build"},
+		{[]interface{}{"build", float64(2), true}, true, "build\n2\ntrue"},
+		{[]interface{}{"build", []interface{}{"nested"}}, true, "[\n  \"build\",\n  [\n    \"nested\"\n  ]\n]"},
+		{nil, true, ""},
+		{nil, false, "null"},
+		{map[string]interface{}{"port": float64(8080)}, true, "{\n  \"port\": 8080\n}"},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.value, tt.raw)
+		if err != nil {
+			t.Fatalf("Format(%v, %v) error = %v", tt.value, tt.raw, err)
+		}
+		if got != tt.want {
+			t.Errorf("Format(%v, %v) = %q, want %q", tt.value, tt.raw, got, tt.want)
+		}
+	}
+}
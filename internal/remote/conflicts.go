@@ -0,0 +1,252 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"interop/internal/logging"
+	"interop/internal/progress"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConflictStrategy decides what happens when a fetched command has the same
+// name as a local one
+type ConflictStrategy string
+
+const (
+	// StrategyLocal keeps the local command and drops the remote one
+	StrategyLocal ConflictStrategy = "local"
+	// StrategyRemote replaces the local command with the remote one
+	StrategyRemote ConflictStrategy = "remote"
+	// StrategyRename keeps both, renaming the remote command to <remote>-<name>
+	StrategyRename ConflictStrategy = "rename"
+)
+
+// ParseConflictStrategy converts a --strategy value into a ConflictStrategy.
+// An empty value means decisions are asked for interactively.
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(strings.ToLower(strings.TrimSpace(value))); strategy {
+	case "", StrategyLocal, StrategyRemote, StrategyRename:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict strategy '%s' (expected local, remote, or rename)", value)
+	}
+}
+
+// renamedCommand returns the name a remote command gets with StrategyRename
+func renamedCommand(remoteName, cmdName string) string {
+	return remoteName + "-" + cmdName
+}
+
+// conflictResolver picks a strategy for each conflicting command
+type conflictResolver struct {
+	strategy ConflictStrategy // Strategy forced on the command line, if any
+	recorded map[string]string
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   bool
+}
+
+// resolve returns the strategy for cmdName and whether it should be recorded
+func (r *conflictResolver) resolve(remoteName, cmdName string) (ConflictStrategy, bool) {
+	if r.strategy != "" {
+		return r.strategy, true
+	}
+	if recorded, err := ParseConflictStrategy(r.recorded[cmdName]); err == nil && recorded != "" {
+		return recorded, false
+	}
+	// Clear the fetch spinner before writing warnings or prompts
+	progress.Pause()
+	if !r.prompt {
+		logging.Warning("Command '%s' from remote '%s' conflicts with a local command, keeping the local one (use --strategy to decide)", cmdName, remoteName)
+		return StrategyLocal, false
+	}
+
+	for {
+		fmt.Fprintf(r.out, "Command '%s' from remote '%s' conflicts with a local command.\n", cmdName, remoteName)
+		fmt.Fprintf(r.out, "  [l]ocal: keep the local command\n")
+		fmt.Fprintf(r.out, "  [r]emote: use the remote command\n")
+		fmt.Fprintf(r.out, "  re[n]ame: keep both, remote as '%s'\n", renamedCommand(remoteName, cmdName))
+		fmt.Fprintf(r.out, "Choice [l/r/n]: ")
+
+		answer, err := r.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "l", "local":
+			return StrategyLocal, true
+		case "r", "remote":
+			return StrategyRemote, true
+		case "n", "rename":
+			return StrategyRename, true
+		}
+		if err != nil {
+			// Input closed, fall back to precedence without recording
+			fmt.Fprintln(r.out)
+			return StrategyLocal, false
+		}
+	}
+}
+
+// isInteractive reports whether stdin is a terminal that can answer prompts
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// resolveConflicts applies conflict decisions to the config files synced from
+// srcConfigDir into dstConfigDir, recording new decisions in versionInfo
+func (m *Manager) resolveConflicts(remote RemoteEntry, srcConfigDir, dstConfigDir string, versionInfo *VersionInfo, strategy ConflictStrategy) error {
+	localCommands, err := settings.LocalCommandNames()
+	if err != nil {
+		return fmt.Errorf("failed to load local commands: %w", err)
+	}
+
+	resolver := &conflictResolver{
+		strategy: strategy,
+		recorded: versionInfo.Resolutions,
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		prompt:   isInteractive(),
+	}
+	return resolveConflictsInDir(remote.Name, srcConfigDir, dstConfigDir, localCommands, resolver, versionInfo)
+}
+
+// resolveConflictsInDir rewrites the top-level TOML files of dstConfigDir whose
+// commands conflict with localCommands, or refer to conflicting commands with
+// extends or depends_on. The settings loader only reads top-level files, so
+// nested ones can't conflict.
+func resolveConflictsInDir(remoteName, srcConfigDir, dstConfigDir string, localCommands map[string]bool, resolver *conflictResolver, versionInfo *VersionInfo) error {
+	files, err := filepath.Glob(filepath.Join(srcConfigDir, "*.toml"))
+	if err != nil {
+		return fmt.Errorf("failed to list TOML files in %s: %w", srcConfigDir, err)
+	}
+	sort.Strings(files)
+
+	contents := make(map[string]map[string]interface{})
+	changed := make(map[string]bool)
+	// New names of the renamed commands, and the commands dropped for local ones
+	renamed := make(map[string]string)
+	dropped := make(map[string]bool)
+	for _, file := range files {
+		var content map[string]interface{}
+		if _, err := toml.DecodeFile(file, &content); err != nil {
+			logging.Warning("Failed to parse config file %s: %v", file, err)
+			continue
+		}
+		contents[file] = content
+
+		commands, _ := content["commands"].(map[string]interface{})
+		var conflicting []string
+		for name := range commands {
+			if localCommands[name] {
+				conflicting = append(conflicting, name)
+			}
+		}
+		if len(conflicting) == 0 {
+			continue
+		}
+		sort.Strings(conflicting)
+		changed[file] = true
+
+		var overrides []string
+		for _, name := range conflicting {
+			strategy, record := resolver.resolve(remoteName, name)
+			if record {
+				if versionInfo.Resolutions == nil {
+					versionInfo.Resolutions = make(map[string]string)
+				}
+				versionInfo.Resolutions[name] = string(strategy)
+			}
+
+			switch strategy {
+			case StrategyLocal:
+				delete(commands, name)
+				dropped[name] = true
+				logging.Message("Keeping local command '%s' over remote '%s'", name, remoteName)
+			case StrategyRemote:
+				overrides = append(overrides, name)
+				logging.Message("Using command '%s' from remote '%s'", name, remoteName)
+			case StrategyRename:
+				newName := renamedCommand(remoteName, name)
+				commands[newName] = commands[name]
+				delete(commands, name)
+				renamed[name] = newName
+				logging.Message("Renamed command '%s' from remote '%s' to '%s'", name, remoteName, newName)
+			}
+		}
+
+		if len(overrides) > 0 {
+			content["overrides"] = overrides
+		}
+	}
+
+	// Keep references between the remote's commands on the renamed ones, and
+	// warn about those now resolving to local commands
+	if len(renamed) > 0 || len(dropped) > 0 {
+		for _, file := range files {
+			commands, _ := contents[file]["commands"].(map[string]interface{})
+			for name, command := range commands {
+				fields, _ := command.(map[string]interface{})
+				if rewriteReferences(remoteName, name, fields, renamed, dropped) {
+					changed[file] = true
+				}
+			}
+		}
+	}
+
+	for _, file := range files {
+		if !changed[file] {
+			continue
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "# Fetched from remote '%s' with command conflicts resolved by interop\n\n", remoteName)
+		if err := toml.NewEncoder(&buf).Encode(contents[file]); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file, err)
+		}
+		dstFile := filepath.Join(dstConfigDir, filepath.Base(file))
+		if err := os.WriteFile(dstFile, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dstFile, err)
+		}
+	}
+
+	return nil
+}
+
+// rewriteReferences points the extends and depends_on fields of the remote
+// command name at the new names of renamed commands, and warns about those
+// referring to dropped ones. It reports whether a field was rewritten.
+func rewriteReferences(remoteName, name string, fields map[string]interface{}, renamed map[string]string, dropped map[string]bool) bool {
+	rewritten := false
+	reference := func(target string) string {
+		if newName, ok := renamed[target]; ok {
+			logging.Message("Command '%s' from remote '%s' now refers to '%s' as '%s'", name, remoteName, target, newName)
+			rewritten = true
+			return newName
+		}
+		if dropped[target] {
+			logging.Warning("Command '%s' from remote '%s' refers to '%s', which is now the local command", name, remoteName, target)
+		}
+		return target
+	}
+
+	if extends, ok := fields["extends"].(string); ok {
+		fields["extends"] = reference(extends)
+	}
+	if dependsOn, ok := fields["depends_on"].([]interface{}); ok {
+		for i, dependency := range dependsOn {
+			if target, ok := dependency.(string); ok {
+				dependsOn[i] = reference(target)
+			}
+		}
+	}
+	return rewritten
+}
@@ -0,0 +1,147 @@
+package remote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FakeCommit is a snapshot of a fake repository. Files maps slash separated
+// paths to their content; files under executables/ are written executable.
+type FakeCommit struct {
+	Hash  string
+	Files map[string]string
+}
+
+// FakeGitClient is an in-memory GitClient for tests. Repositories are
+// registered by URL as a list of commits, the last one being the tip of the
+// default branch. Clone and Pull write the tip, Checkout writes any commit.
+type FakeGitClient struct {
+	Calls []string // Operations performed, like "clone <url>" or "checkout <rev>"
+
+	repos     map[string][]FakeCommit
+	checkouts map[string]fakeCheckout // Checked out commit per clone directory
+}
+
+// fakeCheckout is a clone made by FakeGitClient
+type fakeCheckout struct {
+	url   string
+	index int
+}
+
+// NewFakeGitClient creates a fake client without repositories
+func NewFakeGitClient() *FakeGitClient {
+	return &FakeGitClient{
+		repos:     make(map[string][]FakeCommit),
+		checkouts: make(map[string]fakeCheckout),
+	}
+}
+
+// AddCommit appends a commit to the repository at url, creating it if needed
+func (f *FakeGitClient) AddCommit(url, hash string, files map[string]string) {
+	f.repos[url] = append(f.repos[url], FakeCommit{Hash: hash, Files: files})
+}
+
+// Clone writes the tip of the repository into dir
+func (f *FakeGitClient) Clone(repoURL, dir string, opts TransportOptions) error {
+	f.Calls = append(f.Calls, "clone "+repoURL)
+
+	commits := f.repos[repoURL]
+	if len(commits) == 0 {
+		return fmt.Errorf("git clone failed: repository '%s' not found", repoURL)
+	}
+	return f.write(dir, fakeCheckout{url: repoURL, index: len(commits) - 1})
+}
+
+// RevParse resolves HEAD or a commit hash prefix of the checked out repository
+func (f *FakeGitClient) RevParse(repoPath, rev string) (string, error) {
+	f.Calls = append(f.Calls, "rev-parse "+rev)
+
+	checkout, ok := f.checkouts[repoPath]
+	if !ok {
+		return "", fmt.Errorf("not a git repository: %s", repoPath)
+	}
+	index, err := f.resolve(checkout.url, checkout.index, rev)
+	if err != nil {
+		return "", err
+	}
+	return f.repos[checkout.url][index].Hash, nil
+}
+
+// Pull moves the clone to the tip of its repository
+func (f *FakeGitClient) Pull(repoPath string, opts TransportOptions) error {
+	f.Calls = append(f.Calls, "pull")
+
+	checkout, ok := f.checkouts[repoPath]
+	if !ok {
+		return fmt.Errorf("not a git repository: %s", repoPath)
+	}
+	checkout.index = len(f.repos[checkout.url]) - 1
+	return f.write(repoPath, checkout)
+}
+
+// Checkout moves the clone to a commit of its repository
+func (f *FakeGitClient) Checkout(repoPath, rev string) error {
+	f.Calls = append(f.Calls, "checkout "+rev)
+
+	checkout, ok := f.checkouts[repoPath]
+	if !ok {
+		return fmt.Errorf("not a git repository: %s", repoPath)
+	}
+	index, err := f.resolve(checkout.url, checkout.index, rev)
+	if err != nil {
+		return err
+	}
+	checkout.index = index
+	return f.write(repoPath, checkout)
+}
+
+// Init records the call, the directory is left as is
+func (f *FakeGitClient) Init(dir string) error {
+	f.Calls = append(f.Calls, "init")
+	return nil
+}
+
+// resolve returns the index of the commit rev names, given the checked out one
+func (f *FakeGitClient) resolve(url string, head int, rev string) (int, error) {
+	if rev == "HEAD" {
+		return head, nil
+	}
+	for i, commit := range f.repos[url] {
+		if rev != "" && strings.HasPrefix(commit.Hash, rev) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown revision '%s'", rev)
+}
+
+// write replaces the files of dir with those of the checked out commit
+func (f *FakeGitClient) write(dir string, checkout fakeCheckout) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	for name, content := range f.repos[checkout.url][checkout.index].Files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if strings.HasPrefix(name, "executables/") {
+			mode = 0755
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			return err
+		}
+	}
+
+	f.checkouts[dir] = checkout
+	return nil
+}
@@ -0,0 +1,301 @@
+package remote
+
+import (
+	"fmt"
+	"interop/internal/logging"
+	"interop/internal/path"
+	"interop/internal/settings"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// GitBackend selects how remote repositories are cloned
+type GitBackend string
+
+const (
+	// GitBackendAuto uses the system git binary when installed and go-git otherwise
+	GitBackendAuto GitBackend = "auto"
+	// GitBackendSystem always shells out to the git binary
+	GitBackendSystem GitBackend = "system"
+	// GitBackendNative always uses the built-in go-git implementation
+	GitBackendNative GitBackend = "native"
+)
+
+// TransportOptions configures how a remote is reached over HTTP(S)
+type TransportOptions struct {
+	HTTPProxy   string // Proxy URL for HTTP(S) remotes
+	NoVerifyTLS bool   // Skip TLS certificate verification
+	CABundle    string // Path to a PEM file with additional trusted CAs
+}
+
+// gitConfigArgs returns the `git -c` arguments applying the options
+func (o TransportOptions) gitConfigArgs(caBundle string) []string {
+	var args []string
+	if o.HTTPProxy != "" {
+		args = append(args, "-c", "http.proxy="+o.HTTPProxy)
+	}
+	if o.NoVerifyTLS {
+		args = append(args, "-c", "http.sslVerify=false")
+	}
+	if caBundle != "" {
+		args = append(args, "-c", "http.sslCAInfo="+caBundle)
+	}
+	return args
+}
+
+// caBundlePath expands the CA bundle path and checks that it exists
+func (o TransportOptions) caBundlePath() (string, error) {
+	if o.CABundle == "" {
+		return "", nil
+	}
+	expanded, err := path.Expand(o.CABundle)
+	if err != nil {
+		return "", fmt.Errorf("invalid CA bundle path: %w", err)
+	}
+	if _, err := os.Stat(expanded); err != nil {
+		return "", fmt.Errorf("CA bundle not found: %w", err)
+	}
+	return expanded, nil
+}
+
+// ParseGitBackend converts a git_backend setting into a GitBackend.
+// An empty value selects GitBackendAuto.
+func ParseGitBackend(value string) (GitBackend, error) {
+	switch backend := GitBackend(strings.ToLower(strings.TrimSpace(value))); backend {
+	case "":
+		return GitBackendAuto, nil
+	case GitBackendAuto, GitBackendSystem, GitBackendNative:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("unknown git backend '%s' (expected auto, system, or native)", value)
+	}
+}
+
+// ConfiguredGitBackend returns the backend selected by the git_backend setting,
+// falling back to auto when settings can't be loaded or the value is invalid
+func ConfiguredGitBackend() GitBackend {
+	cfg, err := settings.Load()
+	if err != nil {
+		return GitBackendAuto
+	}
+
+	backend, err := ParseGitBackend(cfg.GitBackend)
+	if err != nil {
+		logging.Warning("%v, falling back to auto", err)
+		return GitBackendAuto
+	}
+	return backend
+}
+
+// SystemGitAvailable reports whether a git binary can be found on PATH
+func SystemGitAvailable() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// Resolve returns the concrete backend to use, resolving auto to system or
+// native depending on whether git is installed
+func (b GitBackend) Resolve() (GitBackend, error) {
+	switch b {
+	case GitBackendSystem:
+		if !SystemGitAvailable() {
+			return "", fmt.Errorf("git binary not found on PATH; install git or set git_backend = \"native\"")
+		}
+		return GitBackendSystem, nil
+	case GitBackendNative:
+		return GitBackendNative, nil
+	default:
+		if SystemGitAvailable() {
+			return GitBackendSystem, nil
+		}
+		logging.Message("git binary not found on PATH, using built-in git implementation")
+		return GitBackendNative, nil
+	}
+}
+
+// GitClient runs the git operations needed to fetch remote configurations.
+// Implementations exist for the system git binary and go-git, and
+// FakeGitClient serves in-memory repositories to tests.
+type GitClient interface {
+	// Clone clones repoURL into dir
+	Clone(repoURL, dir string, opts TransportOptions) error
+	// RevParse resolves a revision, such as HEAD or a commit prefix, to a commit ID
+	RevParse(repoPath, rev string) (string, error)
+	// Pull fast-forwards the checked out branch to its upstream
+	Pull(repoPath string, opts TransportOptions) error
+	// Checkout checks out a revision, detaching HEAD
+	Checkout(repoPath, rev string) error
+	// Init creates an empty repository in dir, leaving an existing one as is
+	Init(dir string) error
+}
+
+// NewGitClient returns the client for a backend, resolving auto to system or
+// native depending on whether git is installed
+func NewGitClient(backend GitBackend) (GitClient, error) {
+	resolved, err := backend.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if resolved == GitBackendNative {
+		return nativeGit{}, nil
+	}
+	return systemGit{}, nil
+}
+
+// prepare checks the CA bundle and warns when TLS verification is disabled,
+// returning the expanded CA bundle path
+func (o TransportOptions) prepare(repo string) (string, error) {
+	caBundle, err := o.caBundlePath()
+	if err != nil {
+		return "", err
+	}
+	if o.NoVerifyTLS {
+		logging.Warning("TLS certificate verification is disabled for %s", repo)
+	}
+	return caBundle, nil
+}
+
+// systemGit shells out to the git binary
+type systemGit struct{}
+
+func (systemGit) Clone(repoURL, dir string, opts TransportOptions) error {
+	caBundle, err := opts.prepare(repoURL)
+	if err != nil {
+		return err
+	}
+	args := append(opts.gitConfigArgs(caBundle), "clone", repoURL, dir)
+	_, err = runGit("", args...)
+	return err
+}
+
+func (systemGit) RevParse(repoPath, rev string) (string, error) {
+	return runGit(repoPath, "rev-parse", "--verify", rev+"^{commit}")
+}
+
+func (systemGit) Pull(repoPath string, opts TransportOptions) error {
+	caBundle, err := opts.prepare(repoPath)
+	if err != nil {
+		return err
+	}
+	args := append(opts.gitConfigArgs(caBundle), "pull", "--ff-only", "--quiet")
+	_, err = runGit(repoPath, args...)
+	return err
+}
+
+func (systemGit) Checkout(repoPath, rev string) error {
+	_, err := runGit(repoPath, "checkout", "--quiet", "--detach", rev)
+	return err
+}
+
+func (systemGit) Init(dir string) error {
+	_, err := runGit(dir, "init", "--quiet")
+	return err
+}
+
+// nativeGit uses the built-in go-git implementation
+type nativeGit struct{}
+
+func (nativeGit) Clone(repoURL, dir string, opts TransportOptions) error {
+	caBundle, err := opts.prepare(repoURL)
+	if err != nil {
+		return err
+	}
+	cloneOpts := &git.CloneOptions{
+		URL:             repoURL,
+		InsecureSkipTLS: opts.NoVerifyTLS,
+		ProxyOptions:    transport.ProxyOptions{URL: opts.HTTPProxy},
+	}
+	if caBundle != "" {
+		if cloneOpts.CABundle, err = os.ReadFile(caBundle); err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+	}
+	if _, err := git.PlainClone(dir, false, cloneOpts); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	return nil
+}
+
+func (nativeGit) RevParse(repoPath, rev string) (string, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+	return hash.String(), nil
+}
+
+func (nativeGit) Pull(repoPath string, opts TransportOptions) error {
+	caBundle, err := opts.prepare(repoPath)
+	if err != nil {
+		return err
+	}
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to open worktree: %w", err)
+	}
+	pullOpts := &git.PullOptions{
+		InsecureSkipTLS: opts.NoVerifyTLS,
+		ProxyOptions:    transport.ProxyOptions{URL: opts.HTTPProxy},
+	}
+	if caBundle != "" {
+		if pullOpts.CABundle, err = os.ReadFile(caBundle); err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+	}
+	if err := worktree.Pull(pullOpts); err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("git pull failed: %w", err)
+	}
+	return nil
+}
+
+func (nativeGit) Checkout(repoPath, rev string) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash}); err != nil {
+		return fmt.Errorf("git checkout failed: %w", err)
+	}
+	return nil
+}
+
+func (nativeGit) Init(dir string) error {
+	if _, err := git.PlainInit(dir, false); err != nil && err != git.ErrRepositoryAlreadyExists {
+		return fmt.Errorf("git init failed: %w", err)
+	}
+	return nil
+}
+
+// runGit runs a git command in the specified directory
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git command failed: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
@@ -4,11 +4,12 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"interop/internal/config"
+	"interop/internal/display"
 	"interop/internal/logging"
+	"interop/internal/progress"
 	"io"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -18,8 +19,20 @@ import (
 
 // RemoteEntry represents a single remote repository configuration
 type RemoteEntry struct {
-	Name string `toml:"name"`
-	URL  string `toml:"url"`
+	Name        string `toml:"name"`
+	URL         string `toml:"url"`
+	HTTPProxy   string `toml:"http_proxy,omitempty"`    // Proxy URL used when cloning over HTTP(S)
+	NoVerifyTLS bool   `toml:"no_verify_tls,omitempty"` // Skip TLS certificate verification
+	CABundle    string `toml:"ca_bundle,omitempty"`     // PEM file with additional trusted CAs
+}
+
+// TransportOptions returns the network options configured for the remote
+func (r RemoteEntry) TransportOptions() TransportOptions {
+	return TransportOptions{
+		HTTPProxy:   r.HTTPProxy,
+		NoVerifyTLS: r.NoVerifyTLS,
+		CABundle:    r.CABundle,
+	}
 }
 
 // RemoteConfig represents the remote configuration stored in remote.toml
@@ -32,11 +45,14 @@ type VersionInfo struct {
 	LastCommit string            `toml:"last-commit"`
 	FileSHAs   map[string]string `toml:"file-shas"`
 	RemoteName string            `toml:"remote-name"` // Track which remote this version info belongs to
+	// Resolutions records the conflict strategy chosen per command so later fetches don't ask again
+	Resolutions map[string]string `toml:"resolutions,omitempty"`
 }
 
 // Manager handles remote configuration operations
 type Manager struct {
 	configManager *config.Manager
+	git           GitClient // nil selects the client of the configured git backend
 }
 
 // NewManager creates a new remote configuration manager
@@ -46,6 +62,17 @@ func NewManager() *Manager {
 	}
 }
 
+// SetGitClient makes the manager run git operations through client, such as
+// a FakeGitClient in tests
+func (m *Manager) SetGitClient(client GitClient) {
+	m.git = client
+}
+
+// ValidateURL reports why url can't be added as a remote, nil when it can
+func (m *Manager) ValidateURL(url string) error {
+	return m.validateGitURL(url)
+}
+
 // validateGitURL validates if the provided URL is a valid Git repository URL
 func (m *Manager) validateGitURL(gitURL string) error {
 	if gitURL == "" {
@@ -110,13 +137,10 @@ func (m *Manager) validateGitURL(gitURL string) error {
 
 // GetRemoteConfigPath returns the path to the remote.toml file
 func (m *Manager) GetRemoteConfigPath() (string, error) {
-	root, err := os.UserHomeDir()
+	appDir, err := m.configManager.GetAppDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
-
-	settingsDir := filepath.Join(root, m.configManager.PathConfig.SettingsDir)
-	appDir := filepath.Join(settingsDir, m.configManager.PathConfig.AppDir)
 	remoteDir := filepath.Join(appDir, m.configManager.PathConfig.RemoteDir)
 
 	return filepath.Join(remoteDir, "remote.toml"), nil
@@ -200,7 +224,7 @@ func (m *Manager) findRemoteByName(config *RemoteConfig, name string) (*RemoteEn
 }
 
 // Add adds a named remote URL to the configuration
-func (m *Manager) Add(name, url string) error {
+func (m *Manager) Add(name, url string, opts TransportOptions) error {
 	if name == "" {
 		return fmt.Errorf("remote name cannot be empty")
 	}
@@ -231,8 +255,11 @@ func (m *Manager) Add(name, url string) error {
 
 	// Add new remote
 	config.Remotes = append(config.Remotes, RemoteEntry{
-		Name: name,
-		URL:  url,
+		Name:        name,
+		URL:         url,
+		HTTPProxy:   opts.HTTPProxy,
+		NoVerifyTLS: opts.NoVerifyTLS,
+		CABundle:    opts.CABundle,
 	})
 
 	if err := m.saveRemoteConfig(config); err != nil {
@@ -299,10 +326,7 @@ func (m *Manager) Show() error {
 	fmt.Println()
 
 	if len(config.Remotes) == 0 {
-		fmt.Println("No remote repositories configured.")
-		fmt.Println()
-		fmt.Println("Add a remote with:")
-		fmt.Println("  interop config remote add <name> <git-url>")
+		display.PrintNoItemsFound("remote repositories")
 		logging.Info("No remote repositories configured.")
 		return nil
 	}
@@ -310,6 +334,15 @@ func (m *Manager) Show() error {
 	for _, remote := range config.Remotes {
 		fmt.Printf("🔗 %s\n", remote.Name)
 		fmt.Printf("   URL: %s\n", remote.URL)
+		if remote.HTTPProxy != "" {
+			fmt.Printf("   Proxy: %s\n", remote.HTTPProxy)
+		}
+		if remote.CABundle != "" {
+			fmt.Printf("   CA bundle: %s\n", remote.CABundle)
+		}
+		if remote.NoVerifyTLS {
+			fmt.Printf("   TLS verification: ⚠️  disabled\n")
+		}
 
 		// Validate URL and show status
 		if err := m.validateGitURL(remote.URL); err != nil {
@@ -323,8 +356,10 @@ func (m *Manager) Show() error {
 	return nil
 }
 
-// Fetch fetches configurations from remotes (all or specific named remote)
-func (m *Manager) Fetch(remoteName string) error {
+// Fetch fetches configurations from remotes (all or specific named remote).
+// Conflicts with local commands are resolved with strategy when set, otherwise
+// by recorded decisions or by asking.
+func (m *Manager) Fetch(remoteName string, strategy ConflictStrategy) error {
 	// Ensure remote config exists
 	if err := m.EnsureRemoteConfig(); err != nil {
 		return err
@@ -353,12 +388,23 @@ func (m *Manager) Fetch(remoteName string) error {
 		remotesToFetch = config.Remotes
 	}
 
+	// Check git availability up front rather than failing on every remote
+	git := m.git
+	if git == nil {
+		if git, err = NewGitClient(ConfiguredGitBackend()); err != nil {
+			return err
+		}
+	}
+
 	for _, remote := range remotesToFetch {
 		logging.Message("Fetching from remote '%s' (%s)...", remote.Name, remote.URL)
-		if err := m.fetchFromRemote(remote); err != nil {
+		step := progress.Start("Fetching remote '%s'", remote.Name)
+		if err := m.fetchFromRemote(remote, git, strategy, step); err != nil {
+			step.Fail("Failed to fetch remote '%s'", remote.Name)
 			logging.Error("Failed to fetch from remote '%s': %v", remote.Name, err)
 			continue
 		}
+		step.Done("")
 		logging.Message("Successfully fetched from remote '%s'", remote.Name)
 	}
 
@@ -366,10 +412,11 @@ func (m *Manager) Fetch(remoteName string) error {
 	return nil
 }
 
-// fetchFromRemote fetches from a specific remote
-func (m *Manager) fetchFromRemote(remote RemoteEntry) error {
+// fetchFromRemote fetches from a specific remote, reporting its progress on step
+func (m *Manager) fetchFromRemote(remote RemoteEntry, git GitClient, strategy ConflictStrategy, step *progress.Step) error {
 	// Clone repository to temporary directory
-	tmpDir, err := m.cloneRepository(remote.URL)
+	step.Update("Cloning %s", remote.URL)
+	tmpDir, err := m.cloneRepository(remote.URL, git, remote.TransportOptions())
 	if err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
@@ -381,11 +428,10 @@ func (m *Manager) fetchFromRemote(remote RemoteEntry) error {
 	}
 
 	// Get current commit ID
-	currentCommit, err := m.runGitCommand(tmpDir, "rev-parse", "HEAD")
+	currentCommit, err := git.RevParse(tmpDir, "HEAD")
 	if err != nil {
 		return fmt.Errorf("failed to get current commit: %w", err)
 	}
-	currentCommit = strings.TrimSpace(currentCommit)
 
 	// Load existing version info for this remote
 	versionInfo, err := m.loadVersionInfoForRemote(remote.Name)
@@ -397,13 +443,25 @@ func (m *Manager) fetchFromRemote(remote RemoteEntry) error {
 		}
 	}
 
-	// Check if we need to update (commit changed or no previous version info)
-	if versionInfo.LastCommit == currentCommit && len(versionInfo.FileSHAs) > 0 {
+	// Check if we need to update (commit changed or no previous version info).
+	// An explicit strategy re-applies conflict resolution even without changes.
+	if versionInfo.LastCommit == currentCommit && len(versionInfo.FileSHAs) > 0 && strategy == "" {
 		logging.Message("Remote '%s' is already up to date (commit: %s)", remote.Name, currentCommit[:8])
+		step.Update("Remote '%s' is already up to date (commit: %s)", remote.Name, currentCommit[:8])
 		return nil
 	}
 
 	logging.Message("Updating from remote '%s' (commit: %s)", remote.Name, currentCommit[:8])
+	step.Update("Syncing files from remote '%s'", remote.Name)
+
+	// Files the remote provided until now, to remove the ones it dropped
+	previousFiles := make([]string, 0, len(versionInfo.FileSHAs))
+	for path := range versionInfo.FileSHAs {
+		previousFiles = append(previousFiles, filepath.ToSlash(path))
+	}
+	// Files the remote has now. The remote directories are shared with other
+	// remotes, so only these are recorded as coming from it.
+	sourceFiles := make(map[string]string)
 
 	// Get remote directories
 	remoteConfigDir, remoteExecutablesDir, err := m.getRemoteConfigDirs()
@@ -426,11 +484,21 @@ func (m *Manager) fetchFromRemote(remote RemoteEntry) error {
 			return fmt.Errorf("failed to sync config directory: %w", err)
 		}
 
+		if err := m.resolveConflicts(remote, srcConfigDir, remoteConfigDir, versionInfo, strategy); err != nil {
+			return fmt.Errorf("failed to resolve conflicts: %w", err)
+		}
+
+		if err := m.updateSHAsForDirectory(srcConfigDir, sourceFiles, "config.d"); err != nil {
+			return fmt.Errorf("failed to list remote config files: %w", err)
+		}
 		if err := m.updateSHAsForDirectory(remoteConfigDir, newSHAs, "config.d"); err != nil {
 			return fmt.Errorf("failed to update SHAs for config directory: %w", err)
 		}
 
 		for path, sha := range newSHAs {
+			if _, fromRemote := sourceFiles[path]; !fromRemote {
+				continue
+			}
 			versionInfo.FileSHAs[path] = sha
 			allCurrentSHAs[path] = sha
 		}
@@ -453,23 +521,33 @@ func (m *Manager) fetchFromRemote(remote RemoteEntry) error {
 			logging.Warning("Failed to make executables executable: %v", err)
 		}
 
+		if err := m.updateSHAsForDirectory(srcExecutablesDir, sourceFiles, "executables"); err != nil {
+			return fmt.Errorf("failed to list remote executables: %w", err)
+		}
 		if err := m.updateSHAsForDirectory(remoteExecutablesDir, newSHAs, "executables"); err != nil {
 			return fmt.Errorf("failed to update SHAs for executables directory: %w", err)
 		}
 
 		for path, sha := range newSHAs {
+			if _, fromRemote := sourceFiles[path]; !fromRemote {
+				continue
+			}
 			versionInfo.FileSHAs[path] = sha
 			allCurrentSHAs[path] = sha
 		}
 	}
 
 	// Clean up files that were removed from remote
-	if err := m.cleanupRemovedFiles(remoteConfigDir, allCurrentSHAs, "config.d"); err != nil {
-		logging.Warning("Failed to cleanup removed config files: %v", err)
-	}
-	if err := m.cleanupRemovedFiles(remoteExecutablesDir, allCurrentSHAs, "executables"); err != nil {
-		logging.Warning("Failed to cleanup removed executable files: %v", err)
+	var dropped []string
+	for _, path := range previousFiles {
+		if _, exists := sourceFiles[path]; !exists {
+			dropped = append(dropped, path)
+		}
 	}
+	m.removeDroppedFiles(remote.Name, dropped, map[string]string{
+		"config.d":    remoteConfigDir,
+		"executables": remoteExecutablesDir,
+	})
 
 	// Remove stale SHAs for files that no longer exist
 	for path := range versionInfo.FileSHAs {
@@ -484,34 +562,61 @@ func (m *Manager) fetchFromRemote(remote RemoteEntry) error {
 		return fmt.Errorf("failed to save version info: %w", err)
 	}
 
+	step.Update("Fetched remote '%s' (commit: %s)", remote.Name, currentCommit[:8])
 	return nil
 }
 
 // getRemoteConfigDirs returns the paths to remote configuration directories
 func (m *Manager) getRemoteConfigDirs() (string, string, error) {
-	root, err := os.UserHomeDir()
+	appDir, err := m.configManager.GetAppDir()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", "", err
 	}
 
-	settingsDir := filepath.Join(root, m.configManager.PathConfig.SettingsDir)
-	appDir := filepath.Join(settingsDir, m.configManager.PathConfig.AppDir)
-
 	remoteConfigsDir := filepath.Join(appDir, "config.d.remote")
 	remoteExecutablesDir := filepath.Join(appDir, "executables.remote")
 
 	return remoteConfigsDir, remoteExecutablesDir, nil
 }
 
+// RemoteForFile returns the remote that a file in the remote config directory
+// was fetched from and the commit it was fetched at. Remotes share the
+// directory, so the remote whose recorded SHA matches the file wins. An empty
+// name means no remote claims the file.
+func (m *Manager) RemoteForFile(path string) (name, commit string) {
+	remoteConfigDir, _, err := m.getRemoteConfigDirs()
+	if err != nil {
+		return "", ""
+	}
+	relPath, err := filepath.Rel(remoteConfigDir, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", ""
+	}
+	sha, err := m.calculateFileSHA(path)
+	if err != nil {
+		return "", ""
+	}
+
+	config, err := m.loadRemoteConfig()
+	if err != nil {
+		return "", ""
+	}
+	key := filepath.ToSlash(filepath.Join("config.d", relPath))
+	for _, remote := range config.Remotes {
+		versionInfo, err := m.loadVersionInfoForRemote(remote.Name)
+		if err == nil && versionInfo.FileSHAs[key] == sha {
+			return remote.Name, versionInfo.LastCommit
+		}
+	}
+	return "", ""
+}
+
 // getVersionsPath returns the path to the versions.toml file
 func (m *Manager) getVersionsPath() (string, error) {
-	root, err := os.UserHomeDir()
+	appDir, err := m.configManager.GetAppDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
-
-	settingsDir := filepath.Join(root, m.configManager.PathConfig.SettingsDir)
-	appDir := filepath.Join(settingsDir, m.configManager.PathConfig.AppDir)
 	remoteDir := filepath.Join(appDir, m.configManager.PathConfig.RemoteDir)
 
 	return filepath.Join(remoteDir, "versions.toml"), nil
@@ -533,21 +638,8 @@ func (m *Manager) calculateFileSHA(filePath string) (string, error) {
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
-// runGitCommand runs a git command in the specified directory
-func (m *Manager) runGitCommand(dir string, args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-	cmd.Dir = dir
-
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("git command failed: %w", err)
-	}
-
-	return strings.TrimSpace(string(output)), nil
-}
-
 // cloneRepository clones the git repository to a temporary directory
-func (m *Manager) cloneRepository(repoURL string) (string, error) {
+func (m *Manager) cloneRepository(repoURL string, git GitClient, opts TransportOptions) (string, error) {
 	tmpDir, err := os.MkdirTemp("", "interop-remote-*")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary directory: %w", err)
@@ -555,8 +647,7 @@ func (m *Manager) cloneRepository(repoURL string) (string, error) {
 
 	logging.Message("Cloning repository %s to %s", repoURL, tmpDir)
 
-	_, err = m.runGitCommand("", "clone", repoURL, tmpDir)
-	if err != nil {
+	if err := git.Clone(repoURL, tmpDir, opts); err != nil {
 		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("failed to clone repository: %w", err)
 	}
@@ -718,47 +809,57 @@ func (m *Manager) syncDirectory(srcDir, dstDir string, currentSHAs map[string]st
 	return nil
 }
 
-// cleanupRemovedFiles removes files that no longer exist in the source
-func (m *Manager) cleanupRemovedFiles(dstDir string, newSHAs map[string]string, relativePath string) error {
-	entries, err := os.ReadDir(dstDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Directory doesn't exist, nothing to clean
+// removeDroppedFiles removes files a remote no longer has, given by their
+// config.d/ or executables/ path, from the remote directories in dirs. The
+// directories are shared, so files another remote records are kept.
+func (m *Manager) removeDroppedFiles(remoteName string, dropped []string, dirs map[string]string) {
+	if len(dropped) == 0 {
+		return
+	}
+
+	claimed := make(map[string]bool)
+	if config, err := m.loadRemoteConfig(); err == nil {
+		for _, other := range config.Remotes {
+			if other.Name == remoteName {
+				continue
+			}
+			if info, err := m.loadVersionInfoForRemote(other.Name); err == nil {
+				for path := range info.FileSHAs {
+					claimed[filepath.ToSlash(path)] = true
+				}
+			}
 		}
-		return fmt.Errorf("failed to read directory %s: %w", dstDir, err)
 	}
 
-	for _, entry := range entries {
-		dstPath := filepath.Join(dstDir, entry.Name())
-		relativeFilePath := filepath.Join(relativePath, entry.Name())
+	for _, path := range dropped {
+		if claimed[path] {
+			logging.Message("Keeping file provided by another remote: %s", path)
+			continue
+		}
+		top, rest, _ := strings.Cut(path, "/")
+		root, ok := dirs[top]
+		if !ok || rest == "" {
+			continue
+		}
 
-		if entry.IsDir() {
-			// Recursively clean subdirectories
-			if err := m.cleanupRemovedFiles(dstPath, newSHAs, relativeFilePath); err != nil {
-				return err
-			}
+		file := filepath.Join(root, filepath.FromSlash(rest))
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			logging.Warning("Failed to remove file %s: %v", path, err)
+			continue
+		}
+		logging.Message("Removed file: %s", path)
 
-			// Remove directory if it's empty
-			if isEmpty, err := m.isDirEmpty(dstPath); err == nil && isEmpty {
-				if err := os.Remove(dstPath); err != nil {
-					logging.Warning("Failed to remove empty directory %s: %v", dstPath, err)
-				} else {
-					logging.Message("Removed empty directory: %s", relativeFilePath)
-				}
+		// Remove the directories left empty
+		for dir := filepath.Dir(file); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			if isEmpty, err := m.isDirEmpty(dir); err != nil || !isEmpty {
+				break
 			}
-		} else {
-			// Remove file if it doesn't exist in new SHAs
-			if _, exists := newSHAs[relativeFilePath]; !exists {
-				if err := os.Remove(dstPath); err != nil {
-					logging.Warning("Failed to remove file %s: %v", dstPath, err)
-				} else {
-					logging.Message("Removed file: %s", relativeFilePath)
-				}
+			if err := os.Remove(dir); err != nil {
+				logging.Warning("Failed to remove empty directory %s: %v", dir, err)
+				break
 			}
 		}
 	}
-
-	return nil
 }
 
 // isDirEmpty checks if a directory is empty
@@ -799,13 +900,10 @@ func (m *Manager) Clear() error {
 	}
 
 	// Remove all version tracking files for named remotes
-	root, err := os.UserHomeDir()
+	appDir, err := m.configManager.GetAppDir()
 	if err != nil {
-		return fmt.Errorf("failed to get user home directory: %w", err)
+		return err
 	}
-
-	settingsDir := filepath.Join(root, m.configManager.PathConfig.SettingsDir)
-	appDir := filepath.Join(settingsDir, m.configManager.PathConfig.AppDir)
 	remoteDir := filepath.Join(appDir, m.configManager.PathConfig.RemoteDir)
 
 	// Remove all versions-*.toml files
@@ -850,13 +948,10 @@ func (m *Manager) Clear() error {
 
 // getVersionsPathForRemote returns the path to the versions file for a specific remote
 func (m *Manager) getVersionsPathForRemote(remoteName string) (string, error) {
-	root, err := os.UserHomeDir()
+	appDir, err := m.configManager.GetAppDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
-
-	settingsDir := filepath.Join(root, m.configManager.PathConfig.SettingsDir)
-	appDir := filepath.Join(settingsDir, m.configManager.PathConfig.AppDir)
 	remoteDir := filepath.Join(appDir, m.configManager.PathConfig.RemoteDir)
 
 	return filepath.Join(remoteDir, fmt.Sprintf("versions-%s.toml", remoteName)), nil
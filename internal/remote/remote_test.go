@@ -1,9 +1,17 @@
 package remote
 
 import (
+	"bufio"
+	"bytes"
+	"interop/internal/settings"
+	"interop/internal/testutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/BurntSushi/toml"
 )
 
 func TestValidateGitURL(t *testing.T) {
@@ -153,3 +161,495 @@ func TestMakeExecutablesExecutable(t *testing.T) {
 		t.Error("Subdirectory should still be a directory")
 	}
 }
+
+func TestParseGitBackend(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    GitBackend
+		wantErr bool
+	}{
+		{"", GitBackendAuto, false},
+		{"auto", GitBackendAuto, false},
+		{"System", GitBackendSystem, false},
+		{" native ", GitBackendNative, false},
+		{"libgit2", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseGitBackend(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseGitBackend(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseGitBackend(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNativeGitClient(t *testing.T) {
+	// go-git clones local repositories itself, so the test doesn't need a git binary
+	origin := testutil.NewRemote(t)
+	first := origin.Commit("initial", map[string]string{
+		"config.d/commands.toml": "[commands.hello]\ncmd = \"echo hello\"\n",
+	})
+
+	client, err := NewGitClient(GitBackendNative)
+	if err != nil {
+		t.Fatalf("NewGitClient() error = %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	if err := client.Clone(origin.URL, dir, TransportOptions{}); err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.d", "commands.toml")); err != nil {
+		t.Errorf("Expected cloned file to exist: %v", err)
+	}
+	if head, err := client.RevParse(dir, "HEAD"); err != nil || head != first {
+		t.Errorf("RevParse(HEAD) = %s, %v; want %s", head, err, first)
+	}
+
+	// Pull brings in new commits, Checkout goes back to an earlier one
+	second := origin.Commit("add lint", map[string]string{
+		"config.d/lint.toml": "[commands.lint]\ncmd = \"echo lint\"\n",
+	})
+	if err := client.Pull(dir, TransportOptions{}); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if head, err := client.RevParse(dir, "HEAD"); err != nil || head != second {
+		t.Errorf("RevParse(HEAD) after Pull = %s, %v; want %s", head, err, second)
+	}
+
+	if err := client.Checkout(dir, first[:8]); err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if head, err := client.RevParse(dir, "HEAD"); err != nil || head != first {
+		t.Errorf("RevParse(HEAD) after Checkout = %s, %v; want %s", head, err, first)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.d", "lint.toml")); !os.IsNotExist(err) {
+		t.Errorf("Expected lint.toml to be gone after checking out the first commit, got %v", err)
+	}
+}
+
+func TestFakeGitClient(t *testing.T) {
+	client := NewFakeGitClient()
+	client.AddCommit("https://example.com/team.git", "aaaaaaaa11111111", map[string]string{
+		"config.d/team.toml": "[commands.team]\ncmd = \"echo team\"\n",
+		"executables/run.sh": "#!/bin/sh\n",
+	})
+	client.AddCommit("https://example.com/team.git", "bbbbbbbb22222222", map[string]string{
+		"config.d/team.toml": "[commands.team]\ncmd = \"echo team v2\"\n",
+	})
+
+	dir := t.TempDir()
+	if err := client.Clone("https://example.com/missing.git", dir, TransportOptions{}); err == nil {
+		t.Error("Clone() of an unknown repository expected an error")
+	}
+	if err := client.Clone("https://example.com/team.git", dir, TransportOptions{}); err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if head, _ := client.RevParse(dir, "HEAD"); head != "bbbbbbbb22222222" {
+		t.Errorf("RevParse(HEAD) after Clone = %s, want the tip", head)
+	}
+
+	if err := client.Checkout(dir, "aaaa"); err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "executables", "run.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected executables/run.sh to be checked out executable, got %v, %v", info, err)
+	}
+
+	if err := client.Pull(dir, TransportOptions{}); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "executables", "run.sh")); !os.IsNotExist(err) {
+		t.Errorf("Expected executables/run.sh to be removed by Pull, got %v", err)
+	}
+	if _, err := client.RevParse(dir, "cccc"); err == nil {
+		t.Error("RevParse() of an unknown revision expected an error")
+	}
+
+	want := "clone https://example.com/missing.git,clone https://example.com/team.git,rev-parse HEAD,checkout aaaa,pull,rev-parse cccc"
+	if got := strings.Join(client.Calls, ","); got != want {
+		t.Errorf("Calls = %s, want %s", got, want)
+	}
+}
+
+func TestFetchWithFakeGit(t *testing.T) {
+	env := testutil.New(t)
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	const url = "https://example.com/team.git"
+	client := NewFakeGitClient()
+	client.AddCommit(url, "aaaaaaaa11111111", map[string]string{
+		"config.d/team.toml":    "[commands.team]\ncmd = \"echo team\"\n",
+		"executables/deploy.sh": "#!/bin/sh\necho deploy\n",
+	})
+
+	manager := NewManager()
+	manager.SetGitClient(client)
+	if err := manager.EnsureRemoteConfig(); err != nil {
+		t.Fatalf("EnsureRemoteConfig() error = %v", err)
+	}
+	if err := manager.saveRemoteConfig(&RemoteConfig{Remotes: []RemoteEntry{{Name: "team", URL: url}}}); err != nil {
+		t.Fatalf("Failed to register remote: %v", err)
+	}
+
+	fetch := func(wantCommit string) {
+		t.Helper()
+		if err := manager.Fetch("team", ""); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		versionInfo, err := manager.loadVersionInfoForRemote("team")
+		if err != nil {
+			t.Fatalf("Failed to load version info: %v", err)
+		}
+		if versionInfo.LastCommit != wantCommit {
+			t.Errorf("LastCommit = %s, want %s", versionInfo.LastCommit, wantCommit)
+		}
+	}
+
+	fetch("aaaaaaaa11111111")
+	if got, want := env.Tree("executables.remote"), "deploy.sh -rwxr-xr-x"; got != want {
+		t.Errorf("executables.remote = %q, want %q", got, want)
+	}
+
+	// An unchanged remote is left alone, a new commit is synced
+	fetch("aaaaaaaa11111111")
+	client.AddCommit(url, "bbbbbbbb22222222", map[string]string{
+		"config.d/team.toml":     "[commands.team]\ncmd = \"echo team v2\"\n",
+		"executables/release.sh": "#!/bin/sh\necho release\n",
+	})
+	fetch("bbbbbbbb22222222")
+	if got, want := env.Tree("executables.remote"), "release.sh -rwxr-xr-x"; got != want {
+		t.Errorf("executables.remote = %q, want %q", got, want)
+	}
+	content, err := os.ReadFile(filepath.Join(env.ConfigDir, "config.d.remote", "team.toml"))
+	if err != nil || !strings.Contains(string(content), "team v2") {
+		t.Errorf("Expected the updated team.toml, got %q, %v", content, err)
+	}
+}
+
+func TestTransportOptionsGitConfigArgs(t *testing.T) {
+	opts := TransportOptions{
+		HTTPProxy:   "http://proxy.example.com:3128",
+		NoVerifyTLS: true,
+	}
+
+	got := opts.gitConfigArgs("/etc/ssl/corp.pem")
+	want := []string{
+		"-c", "http.proxy=http://proxy.example.com:3128",
+		"-c", "http.sslVerify=false",
+		"-c", "http.sslCAInfo=/etc/ssl/corp.pem",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("gitConfigArgs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("gitConfigArgs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if args := (TransportOptions{}).gitConfigArgs(""); len(args) != 0 {
+		t.Errorf("gitConfigArgs() with no options = %v, want none", args)
+	}
+
+	if _, err := (TransportOptions{CABundle: "/nonexistent/ca.pem"}).caBundlePath(); err == nil {
+		t.Error("caBundlePath() expected an error for a missing file")
+	}
+}
+
+func TestResolveConflictsInDir(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	content := `[commands.deploy]
+cmd = "echo remote deploy"
+
+[commands.lint]
+cmd = "echo remote lint"
+
+[commands.test]
+cmd = "echo remote test"
+
+[commands.docs]
+cmd = "echo docs"
+`
+	if err := os.WriteFile(filepath.Join(srcDir, "team.toml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	localCommands := map[string]bool{"deploy": true, "lint": true, "test": true}
+	resolver := &conflictResolver{
+		recorded: map[string]string{"deploy": "remote", "lint": "rename"},
+		prompt:   false,
+	}
+	versionInfo := &VersionInfo{}
+
+	if err := resolveConflictsInDir("team", srcDir, dstDir, localCommands, resolver, versionInfo); err != nil {
+		t.Fatalf("resolveConflictsInDir() error = %v", err)
+	}
+
+	var result struct {
+		Commands  map[string]map[string]interface{} `toml:"commands"`
+		Overrides []string                          `toml:"overrides"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(dstDir, "team.toml"), &result); err != nil {
+		t.Fatalf("Failed to decode resolved file: %v", err)
+	}
+
+	if _, ok := result.Commands["deploy"]; !ok || len(result.Overrides) != 1 || result.Overrides[0] != "deploy" {
+		t.Errorf("Expected 'deploy' to override the local command, got commands %v overrides %v", result.Commands, result.Overrides)
+	}
+	if _, ok := result.Commands["lint"]; ok {
+		t.Error("Expected 'lint' to be renamed")
+	}
+	if _, ok := result.Commands["team-lint"]; !ok {
+		t.Error("Expected renamed command 'team-lint'")
+	}
+	if _, ok := result.Commands["test"]; ok {
+		t.Error("Expected undecided conflict 'test' to keep the local command")
+	}
+	if _, ok := result.Commands["docs"]; !ok {
+		t.Error("Expected non-conflicting command 'docs' to be kept")
+	}
+	if len(versionInfo.Resolutions) != 0 {
+		t.Errorf("Expected no new decisions to be recorded, got %v", versionInfo.Resolutions)
+	}
+
+	// A forced strategy applies to every conflict and is recorded
+	resolver = &conflictResolver{strategy: StrategyRename, recorded: map[string]string{}}
+	if err := resolveConflictsInDir("team", srcDir, dstDir, localCommands, resolver, versionInfo); err != nil {
+		t.Fatalf("resolveConflictsInDir() error = %v", err)
+	}
+	for _, name := range []string{"deploy", "lint", "test"} {
+		if versionInfo.Resolutions[name] != string(StrategyRename) {
+			t.Errorf("Expected recorded decision 'rename' for %s, got %q", name, versionInfo.Resolutions[name])
+		}
+	}
+}
+
+func TestResolveConflictsKeepsReferences(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	files := map[string]string{
+		"base.toml": `[commands.lint]
+cmd = "echo remote lint"
+
+[commands.test]
+cmd = "echo remote test"
+`,
+		"ci.toml": `[commands.lint-strict]
+extends = "lint"
+args = "--strict"
+
+[commands.ci]
+cmd = "echo ci"
+depends_on = ["lint", "test"]
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+
+	localCommands := map[string]bool{"lint": true, "test": true}
+	resolver := &conflictResolver{recorded: map[string]string{"lint": "rename", "test": "local"}}
+	if err := resolveConflictsInDir("team", srcDir, dstDir, localCommands, resolver, &VersionInfo{}); err != nil {
+		t.Fatalf("resolveConflictsInDir() error = %v", err)
+	}
+
+	var result struct {
+		Commands map[string]struct {
+			Extends   string   `toml:"extends"`
+			DependsOn []string `toml:"depends_on"`
+		} `toml:"commands"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(dstDir, "ci.toml"), &result); err != nil {
+		t.Fatalf("Failed to decode resolved file: %v", err)
+	}
+	if extends := result.Commands["lint-strict"].Extends; extends != "team-lint" {
+		t.Errorf("Expected 'lint-strict' to extend the renamed 'team-lint', got %q", extends)
+	}
+	// The remote 'test' is dropped, so the local one is the only one left
+	if dependsOn := result.Commands["ci"].DependsOn; !reflect.DeepEqual(dependsOn, []string{"team-lint", "test"}) {
+		t.Errorf("Expected 'ci' to depend on [team-lint test], got %v", dependsOn)
+	}
+}
+
+func TestConflictResolverPrompt(t *testing.T) {
+	var out bytes.Buffer
+	resolver := &conflictResolver{
+		in:     bufio.NewReader(strings.NewReader("x\nn\n")),
+		out:    &out,
+		prompt: true,
+	}
+
+	strategy, record := resolver.resolve("team", "deploy")
+	if strategy != StrategyRename || !record {
+		t.Errorf("resolve() = %q, %v, want rename, true", strategy, record)
+	}
+	if !strings.Contains(out.String(), "team-deploy") {
+		t.Errorf("Expected prompt to mention the renamed command, got %q", out.String())
+	}
+}
+
+func TestFetchFromLocalRemote(t *testing.T) {
+	if !SystemGitAvailable() {
+		t.Skip("cloning a local repository needs a git binary")
+	}
+
+	env := testutil.New(t)
+	env.WriteSettings("[commands.local]\ncmd = \"echo local\"\n")
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	origin := testutil.NewRemote(t)
+	origin.Commit("initial", map[string]string{
+		"config.d/deploy.toml":  "[commands.deploy]\ncmd = \"deploy.sh\"\n",
+		"config.d/lint.toml":    "[commands.lint]\ncmd = \"echo lint\"\n",
+		"executables/deploy.sh": "#!/bin/sh\necho deploy\n",
+	})
+
+	manager := NewManager()
+	if err := manager.EnsureRemoteConfig(); err != nil {
+		t.Fatalf("EnsureRemoteConfig() error = %v", err)
+	}
+	if err := manager.saveRemoteConfig(&RemoteConfig{Remotes: []RemoteEntry{{Name: "team", URL: origin.URL}}}); err != nil {
+		t.Fatalf("Failed to register remote: %v", err)
+	}
+
+	fetched := func() string {
+		return "config.d.remote:\n" + env.Tree("config.d.remote") + "\nexecutables.remote:\n" + env.Tree("executables.remote")
+	}
+	if err := manager.Fetch("team", ""); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	env.AssertGolden("fetch_initial", fetched())
+
+	// Files removed from the remote are removed locally on the next fetch
+	head := origin.Remove("drop lint", "config.d/lint.toml")
+	if err := manager.Fetch("team", ""); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	env.AssertGolden("fetch_update", fetched())
+
+	name, commit := manager.RemoteForFile(filepath.Join(env.ConfigDir, "config.d.remote", "deploy.toml"))
+	if name != "team" || commit != head {
+		t.Errorf("RemoteForFile() = %q, %q; want \"team\", %q", name, commit, head)
+	}
+}
+
+func TestFetchKeepsFilesOfOtherRemotes(t *testing.T) {
+	if !SystemGitAvailable() {
+		t.Skip("cloning a local repository needs a git binary")
+	}
+
+	env := testutil.New(t)
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	// Both remotes ship the same lint configuration
+	team := testutil.NewRemote(t)
+	team.Commit("initial", map[string]string{
+		"config.d/lint.toml":  "[commands.lint]\ncmd = \"echo lint\"\n",
+		"config.d/team.toml":  "[commands.team]\ncmd = \"echo team\"\n",
+		"executables/team.sh": "#!/bin/sh\necho team\n",
+	})
+	shared := testutil.NewRemote(t)
+	shared.Commit("initial", map[string]string{
+		"config.d/lint.toml":    "[commands.lint]\ncmd = \"echo lint\"\n",
+		"config.d/shared.toml":  "[commands.shared]\ncmd = \"echo shared\"\n",
+		"executables/shared.sh": "#!/bin/sh\necho shared\n",
+	})
+
+	manager := NewManager()
+	if err := manager.EnsureRemoteConfig(); err != nil {
+		t.Fatalf("EnsureRemoteConfig() error = %v", err)
+	}
+	remotes := []RemoteEntry{{Name: "team", URL: team.URL}, {Name: "shared", URL: shared.URL}}
+	if err := manager.saveRemoteConfig(&RemoteConfig{Remotes: remotes}); err != nil {
+		t.Fatalf("Failed to register remotes: %v", err)
+	}
+	if err := manager.Fetch("", ""); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	team.Remove("drop lint", "config.d/lint.toml")
+	if err := manager.Fetch("team", ""); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	want := "lint.toml -rw-r--r--\nshared.toml -rw-r--r--\nteam.toml -rw-r--r--"
+	if got := env.Tree("config.d.remote"); got != want {
+		t.Errorf("config.d.remote after fetch = %q, want %q", got, want)
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	testutil.New(t)
+	if _, err := settings.Reload(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "team-commands")
+	client := NewFakeGitClient()
+	manager := NewManager()
+	manager.SetGitClient(client)
+
+	created, err := manager.Scaffold(dir, ScaffoldOptions{GitInit: true})
+	if err != nil {
+		t.Fatalf("Scaffold() error = %v", err)
+	}
+	if err := manager.validateRepoStructure(dir); err != nil {
+		t.Errorf("The scaffolded repository is invalid: %v", err)
+	}
+	if len(client.Calls) != 1 || client.Calls[0] != "init" {
+		t.Errorf("git calls = %v, want init", client.Calls)
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, "manifest.toml"))
+	if err != nil || !strings.Contains(string(manifest), `name = "team-commands"`) {
+		t.Errorf("manifest.toml = %q, %v, want the directory's name", manifest, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "executables", "hello.sh")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("executables/hello.sh = %v, %v, want an executable file", info, err)
+	}
+
+	// The skeleton is fetched like any repository, defining its example command
+	const url = "https://example.com/team-commands.git"
+	files := make(map[string]string)
+	for _, path := range created {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(content)
+	}
+	client.AddCommit(url, "aaaaaaaa11111111", files)
+	if err := manager.Add("team", url, TransportOptions{}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := manager.Fetch("team", ""); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if cmd, ok := cfg.Commands["hello"]; !ok || !cmd.IsExecutable {
+		t.Errorf("Commands[hello] = %+v, want the example command", cmd)
+	}
+
+	if _, err := manager.Scaffold(dir, ScaffoldOptions{}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Scaffold() into a scaffolded directory error = %v, want an existing file reported", err)
+	}
+}
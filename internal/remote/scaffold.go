@@ -0,0 +1,95 @@
+package remote
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// scaffoldFiles is the skeleton of a command repository. Files ending in
+// .tmpl are rendered with the ScaffoldOptions and written without the suffix.
+//
+//go:embed scaffold
+var scaffoldFiles embed.FS
+
+// ScaffoldOptions configure the repository created by Scaffold
+type ScaffoldOptions struct {
+	Name    string // Shown in the manifest and README, the directory's name when empty
+	GitInit bool   // Run git init in the directory
+}
+
+// Scaffold creates the skeleton of a repository teams can publish commands
+// from in dir: config.d/ with an example command, executables/ with the
+// script it runs, manifest.toml and a README. Nothing is written when one of
+// the files exists already. It returns the paths of the files created.
+func (m *Manager) Scaffold(dir string, opts ScaffoldOptions) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(dir)
+	}
+
+	files := make(map[string][]byte)
+	var paths []string
+	err = fs.WalkDir(scaffoldFiles, "scaffold", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := scaffoldFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(name, "scaffold/")
+		if strings.HasSuffix(rel, ".tmpl") {
+			rel = strings.TrimSuffix(rel, ".tmpl")
+			var out bytes.Buffer
+			if err := template.Must(template.New(rel).Parse(string(content))).Execute(&out, opts); err != nil {
+				return fmt.Errorf("failed to render %s: %w", rel, err)
+			}
+			content = out.Bytes()
+		}
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+		files[path] = content
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(0o644)
+		if filepath.Base(filepath.Dir(path)) == "executables" {
+			mode = 0o755
+		}
+		if err := os.WriteFile(path, files[path], mode); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.GitInit {
+		git := m.git
+		if git == nil {
+			if git, err = NewGitClient(ConfiguredGitBackend()); err != nil {
+				return paths, err
+			}
+		}
+		if err := git.Init(dir); err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,3 @@
+#!/bin/sh
+# Run by the hello command in config.d/example.toml
+echo "Hello, ${1:-world}!"
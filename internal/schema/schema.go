@@ -0,0 +1,200 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"interop/internal/settings"
+	"sort"
+	"strings"
+)
+
+// Formats of the exported command catalog
+const (
+	FormatOpenAPI    = "openapi"
+	FormatJSONSchema = "jsonschema"
+)
+
+// jsonSchemaDialect is the JSON Schema version of the exported schemas, the
+// one OpenAPI 3.1 uses too
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Export describes the enabled commands of cfg in format, as an indented JSON
+// document. version is the version of interop, recorded in OpenAPI documents.
+func Export(cfg *settings.Settings, format, version string) ([]byte, error) {
+	var document map[string]interface{}
+	switch format {
+	case FormatOpenAPI:
+		document = OpenAPI(cfg, version)
+	case FormatJSONSchema:
+		document = JSONSchema(cfg)
+	default:
+		return nil, fmt.Errorf("unknown format '%s', must be %s or %s", format, FormatOpenAPI, FormatJSONSchema)
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// JSONSchema returns a JSON Schema document defining the arguments object of
+// each enabled command under $defs, keyed by command name
+func JSONSchema(cfg *settings.Settings) map[string]interface{} {
+	defs := map[string]interface{}{}
+	for _, name := range commandNames(cfg) {
+		defs[name] = CommandSchema(name, cfg.Commands[name])
+	}
+	return map[string]interface{}{
+		"$schema":     jsonSchemaDialect,
+		"title":       "interop commands",
+		"description": "Arguments of the commands configured in interop",
+		"$defs":       defs,
+	}
+}
+
+// OpenAPI returns an OpenAPI 3.1 document with a POST /commands/<name>
+// operation per enabled command, taking the command's arguments as a JSON
+// object and returning its output as text
+func OpenAPI(cfg *settings.Settings, version string) map[string]interface{} {
+	paths := map[string]interface{}{}
+	schemas := map[string]interface{}{}
+	for _, name := range commandNames(cfg) {
+		cmd := cfg.Commands[name]
+		schemas[name] = CommandSchema(name, cmd)
+		summary := operationSummary(name, cmd)
+
+		operation := map[string]interface{}{
+			"operationId": name,
+			"summary":     summary,
+			"requestBody": map[string]interface{}{
+				"required": hasRequired(cmd.Arguments),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/" + name},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": textResponse("Output of the command"),
+				"400": textResponse("The arguments are invalid"),
+				"500": textResponse("The command failed, with its output"),
+			},
+		}
+		if cmd.Description != "" && cmd.Description != summary {
+			operation["description"] = cmd.Description
+		}
+		if len(cmd.MCP) > 0 {
+			operation["tags"] = []string(cmd.MCP)
+		}
+		if cmd.Version != "" {
+			operation["x-interop-version"] = cmd.Version
+		}
+		paths["/commands/"+name] = map[string]interface{}{"post": operation}
+	}
+
+	return map[string]interface{}{
+		"openapi":           "3.1.0",
+		"jsonSchemaDialect": jsonSchemaDialect,
+		"info": map[string]interface{}{
+			"title":       "interop commands",
+			"description": "Commands configured in interop, each run with its arguments as a JSON object",
+			"version":     version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// CommandSchema returns the JSON Schema of the arguments object of a command
+func CommandSchema(name string, cmd settings.CommandConfig) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, arg := range cmd.Arguments {
+		properties[arg.Name] = ArgumentSchema(arg)
+		if arg.Required {
+			required = append(required, arg.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"title":                name,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if cmd.Description != "" {
+		schema["description"] = cmd.Description
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// ArgumentSchema returns the JSON Schema of a command argument
+func ArgumentSchema(arg settings.CommandArgument) map[string]interface{} {
+	schema := map[string]interface{}{"type": jsonType(arg.Type)}
+	description := arg.Description
+	if arg.FromFile {
+		if description != "" {
+			description += " "
+		}
+		description += "(file content, or @path to read a file; the command receives the path of a temporary file holding it)"
+		schema["contentMediaType"] = "text/plain"
+	}
+	if description != "" {
+		schema["description"] = description
+	}
+	if arg.Default != nil {
+		schema["default"] = arg.Default
+	}
+	return schema
+}
+
+// jsonType returns the JSON Schema type of an argument type
+func jsonType(argType settings.ArgumentType) string {
+	switch argType {
+	case settings.ArgumentTypeNumber:
+		return "number"
+	case settings.ArgumentTypeBool:
+		return "boolean"
+	}
+	return "string"
+}
+
+// commandNames returns the names of the enabled commands, sorted
+func commandNames(cfg *settings.Settings) []string {
+	var names []string
+	for name, cmd := range cfg.Commands {
+		if cmd.IsEnabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// operationSummary returns the one line summary of a command's operation:
+// the first line of its description
+func operationSummary(name string, cmd settings.CommandConfig) string {
+	if firstLine, _, _ := strings.Cut(strings.TrimSpace(cmd.Description), "\n"); firstLine != "" {
+		return firstLine
+	}
+	return "Run " + name
+}
+
+// hasRequired reports whether any of the arguments is required
+func hasRequired(args []settings.CommandArgument) bool {
+	for _, arg := range args {
+		if arg.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// textResponse returns an OpenAPI response with a plain text body
+func textResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		},
+	}
+}
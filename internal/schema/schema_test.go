@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"encoding/json"
+	"interop/internal/settings"
+	"reflect"
+	"testing"
+)
+
+func testSettings() *settings.Settings {
+	return &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"deploy": {
+				IsEnabled:   true,
+				Description: "Deploy the service\nRuns the release pipeline.",
+				MCP:         settings.ServerList{"ops"},
+				Version:     "1.2.0",
+				Arguments: []settings.CommandArgument{
+					{Name: "env", Type: settings.ArgumentTypeString, Description: "Target environment", Required: true},
+					{Name: "replicas", Type: settings.ArgumentTypeNumber, Default: int64(2)},
+					{Name: "dry_run", Type: settings.ArgumentTypeBool},
+					{Name: "manifest", Type: settings.ArgumentTypeString, FromFile: true},
+				},
+			},
+			"status":   {IsEnabled: true},
+			"disabled": {IsEnabled: false},
+		},
+	}
+}
+
+// decode round-trips an exported document through JSON
+func decode(t *testing.T, format string) map[string]interface{} {
+	t.Helper()
+	data, err := Export(testSettings(), format, "1.0.0")
+	if err != nil {
+		t.Fatalf("Export(%s) error = %v", format, err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("Export(%s) is not valid JSON: %v", format, err)
+	}
+	return document
+}
+
+func TestCommandSchema(t *testing.T) {
+	got := CommandSchema("deploy", testSettings().Commands["deploy"])
+
+	if !reflect.DeepEqual(got["required"], []string{"env"}) {
+		t.Errorf("required = %v, want [env]", got["required"])
+	}
+	properties := got["properties"].(map[string]interface{})
+	types := map[string]string{"env": "string", "replicas": "number", "dry_run": "boolean", "manifest": "string"}
+	for name, want := range types {
+		property := properties[name].(map[string]interface{})
+		if property["type"] != want {
+			t.Errorf("%s type = %v, want %s", name, property["type"], want)
+		}
+	}
+	if replicas := properties["replicas"].(map[string]interface{}); replicas["default"] != int64(2) {
+		t.Errorf("replicas default = %v, want 2", replicas["default"])
+	}
+	if manifest := properties["manifest"].(map[string]interface{}); manifest["contentMediaType"] != "text/plain" {
+		t.Errorf("manifest = %v, want a from_file description", manifest)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	document := decode(t, FormatOpenAPI)
+
+	if document["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v, want 3.1.0", document["openapi"])
+	}
+	paths := document["paths"].(map[string]interface{})
+	if len(paths) != 2 || paths["/commands/disabled"] != nil {
+		t.Errorf("paths = %v, want the enabled commands", paths)
+	}
+
+	deploy := paths["/commands/deploy"].(map[string]interface{})["post"].(map[string]interface{})
+	if deploy["summary"] != "Deploy the service" || deploy["x-interop-version"] != "1.2.0" {
+		t.Errorf("deploy operation = %v", deploy)
+	}
+	body := deploy["requestBody"].(map[string]interface{})
+	ref := body["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})["$ref"]
+	if ref != "#/components/schemas/deploy" || body["required"] != true {
+		t.Errorf("deploy request body = %v", body)
+	}
+
+	status := paths["/commands/status"].(map[string]interface{})["post"].(map[string]interface{})
+	if status["summary"] != "Run status" || status["description"] != nil {
+		t.Errorf("status operation = %v", status)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	document := decode(t, FormatJSONSchema)
+
+	defs := document["$defs"].(map[string]interface{})
+	if len(defs) != 2 || defs["deploy"] == nil || defs["status"] == nil {
+		t.Errorf("$defs = %v, want the enabled commands", defs)
+	}
+
+	if _, err := Export(testSettings(), "yaml", "1.0.0"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
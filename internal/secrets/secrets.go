@@ -0,0 +1,205 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"interop/internal/shell"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	// Scheme starts env values that are read from a secret store when a
+	// command runs, like secret://keychain/GITHUB_TOKEN
+	Scheme = "secret://"
+	// Keychain is the backend storing secrets in the OS keychain: the macOS
+	// keychain, or the Secret Service through secret-tool elsewhere
+	Keychain = "keychain"
+	// service is the keychain service interop's secrets are stored under
+	service = "interop"
+)
+
+// ErrNotFound is returned by Get when no secret is stored under the name
+var ErrNotFound = errors.New("secret not found")
+
+// validName matches the names secrets can be stored under
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Reference is a parsed secret:// value
+type Reference struct {
+	Backend string // Store the secret is kept in, only keychain for now
+	Name    string // Name the secret is stored under
+}
+
+// String returns the reference as written in the settings
+func (r Reference) String() string {
+	return Scheme + r.Backend + "/" + r.Name
+}
+
+// IsReference reports whether an env value refers to a secret
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+// ParseReference parses a secret://<backend>/<name> value
+func ParseReference(value string) (Reference, error) {
+	rest, ok := strings.CutPrefix(value, Scheme)
+	if !ok {
+		return Reference{}, fmt.Errorf("'%s' isn't a secret reference, expected %s%s/<name>", value, Scheme, Keychain)
+	}
+	backend, name, _ := strings.Cut(rest, "/")
+	if backend != Keychain {
+		return Reference{}, fmt.Errorf("secret reference '%s' has an unknown backend '%s', expected %s", value, backend, Keychain)
+	}
+	if err := ValidateName(name); err != nil {
+		return Reference{}, fmt.Errorf("secret reference '%s': %w", value, err)
+	}
+	return Reference{Backend: backend, Name: name}, nil
+}
+
+// ValidateName checks that a secret can be stored under name
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name '%s': use letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
+
+// resolved caches the secrets read by Resolve, so a run reading the same
+// secret for several commands asks the keychain once
+var (
+	resolvedMu sync.Mutex
+	resolved   = make(map[string]string)
+)
+
+// Resolve returns the secret a secret:// value refers to
+func Resolve(value string) (string, error) {
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", err
+	}
+
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+	if secret, ok := resolved[ref.Name]; ok {
+		return secret, nil
+	}
+	secret, err := Get(ref.Name)
+	if err != nil {
+		return "", err
+	}
+	resolved[ref.Name] = secret
+	return secret, nil
+}
+
+// Get reads the secret stored under name from the keychain
+func Get(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	output, err := run("get", name, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret '%s' from the keychain: %w", name, err)
+	}
+	return strings.TrimSuffix(output, "\n"), nil
+}
+
+// Set stores value under name in the keychain, replacing the current value.
+// Values can't span lines, since the keychain tools read them as a line.
+func Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("secret '%s' can't contain line breaks", name)
+	}
+	if _, err := run("set", name, value); err != nil {
+		return fmt.Errorf("failed to store secret '%s' in the keychain: %w", name, err)
+	}
+	return nil
+}
+
+// Delete removes the secret stored under name from the keychain
+func Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if _, err := run("delete", name, ""); err != nil {
+		return fmt.Errorf("failed to delete secret '%s' from the keychain: %w", name, err)
+	}
+	return nil
+}
+
+// run performs action on the secret name with the keychain tool of this OS,
+// returning its output
+func run(action, name, value string) (string, error) {
+	program, args, stdin, err := command(runtime.GOOS, action, name, value)
+	if err != nil {
+		return "", err
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		return "", fmt.Errorf("%s not found, keychain secrets are unavailable", program)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(program, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if action == "get" && errors.As(err, &exitErr) && missing(runtime.GOOS, exitErr.ExitCode(), stderr.String()) {
+			return "", ErrNotFound
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", program, err, message)
+		}
+		return "", fmt.Errorf("%s failed: %w", program, err)
+	}
+	return stdout.String(), nil
+}
+
+// missing reports whether a failed lookup on goos means the secret isn't
+// stored: security exits with errSecItemNotFound, secret-tool with 1 and no
+// message
+func missing(goos string, exitCode int, stderr string) bool {
+	if goos == "darwin" {
+		return exitCode == 44
+	}
+	return exitCode == 1 && strings.TrimSpace(stderr) == ""
+}
+
+// command returns the program, arguments and standard input performing action
+// (get, set or delete) on the secret name in the keychain of goos. Values are
+// passed on standard input so they don't show up in the process list.
+func command(goos, action, name, value string) (string, []string, string, error) {
+	switch goos {
+	case "darwin":
+		switch action {
+		case "get":
+			return "security", []string{"find-generic-password", "-s", service, "-a", name, "-w"}, "", nil
+		case "set":
+			line := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", shell.Quote(service), shell.Quote(name), shell.Quote(value))
+			return "security", []string{"-i"}, line, nil
+		case "delete":
+			return "security", []string{"delete-generic-password", "-s", service, "-a", name}, "", nil
+		}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		attributes := []string{"service", service, "name", name}
+		switch action {
+		case "get":
+			return "secret-tool", append([]string{"lookup"}, attributes...), "", nil
+		case "set":
+			return "secret-tool", append([]string{"store", "--label", "interop: " + name}, attributes...), value, nil
+		case "delete":
+			return "secret-tool", append([]string{"clear"}, attributes...), "", nil
+		}
+	default:
+		return "", nil, "", fmt.Errorf("keychain secrets are not supported on %s", goos)
+	}
+	return "", nil, "", fmt.Errorf("unknown keychain action '%s'", action)
+}
@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	ref, err := ParseReference("secret://keychain/GITHUB_TOKEN")
+	if err != nil {
+		t.Fatalf("ParseReference() error = %v", err)
+	}
+	if want := (Reference{Backend: "keychain", Name: "GITHUB_TOKEN"}); ref != want {
+		t.Errorf("ParseReference() = %+v, want %+v", ref, want)
+	}
+	if ref.String() != "secret://keychain/GITHUB_TOKEN" {
+		t.Errorf("String() = %q", ref.String())
+	}
+
+	for value, message := range map[string]string{
+		"keychain/TOKEN":             "isn't a secret reference",
+		"secret://vault/TOKEN":       "unknown backend 'vault'",
+		"secret://keychain/":         "invalid secret name",
+		"secret://keychain/a/b":      "invalid secret name 'a/b'",
+		"secret://keychain/MY TOKEN": "invalid secret name",
+	} {
+		if _, err := ParseReference(value); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("ParseReference(%q) error = %v, want one containing %q", value, err, message)
+		}
+	}
+
+	if !IsReference("secret://keychain/TOKEN") || IsReference("TOKEN") {
+		t.Error("IsReference() should only match the secret:// scheme")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		goos, action string
+		program      string
+		args         []string
+		stdin        string
+	}{
+		{"darwin", "get", "security", []string{"find-generic-password", "-s", "interop", "-a", "TOKEN", "-w"}, ""},
+		{"darwin", "set", "security", []string{"-i"}, "add-generic-password -U -s interop -a TOKEN -w 'it'\\''s secret'\n"},
+		{"darwin", "delete", "security", []string{"delete-generic-password", "-s", "interop", "-a", "TOKEN"}, ""},
+		{"linux", "get", "secret-tool", []string{"lookup", "service", "interop", "name", "TOKEN"}, ""},
+		{"linux", "set", "secret-tool", []string{"store", "--label", "interop: TOKEN", "service", "interop", "name", "TOKEN"}, "it's secret"},
+		{"linux", "delete", "secret-tool", []string{"clear", "service", "interop", "name", "TOKEN"}, ""},
+	}
+	for _, tt := range tests {
+		value := ""
+		if tt.action == "set" {
+			value = "it's secret"
+		}
+		program, args, stdin, err := command(tt.goos, tt.action, "TOKEN", value)
+		if err != nil {
+			t.Fatalf("command(%s, %s) error = %v", tt.goos, tt.action, err)
+		}
+		if program != tt.program || !reflect.DeepEqual(args, tt.args) || stdin != tt.stdin {
+			t.Errorf("command(%s, %s) = %s %q %q, want %s %q %q", tt.goos, tt.action, program, args, stdin, tt.program, tt.args, tt.stdin)
+		}
+	}
+
+	if _, _, _, err := command("windows", "get", "TOKEN", ""); err == nil {
+		t.Error("Expected an error on an unsupported OS")
+	}
+	// A line break would end the security -i command and start another
+	for _, value := range []string{"it\nadd-generic-password -s other", "it\r"} {
+		if err := Set("TOKEN", value); err == nil || !strings.Contains(err.Error(), "line breaks") {
+			t.Errorf("Set(%q) error = %v, want one about line breaks", value, err)
+		}
+	}
+	if missing("darwin", 1, "") || !missing("darwin", 44, "not found") || !missing("linux", 1, "") || missing("linux", 1, "locked") {
+		t.Error("missing() misreads a failed lookup")
+	}
+}
@@ -0,0 +1,514 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Changes is a document of changes to the local settings, applied in one go
+// by interop apply:
+//
+//	remove_commands = ["old-build"]
+//	unset_env = ["DEBUG"]
+//
+//	[commands.lint]    # Added, or replacing the local definition
+//	cmd = "golangci-lint run"
+//
+//	[[bind]]           # Bind a command to a project, with an optional alias
+//	project = "api"
+//	command = "lint"
+//	alias = "l"
+//
+//	[[unbind]]         # Remove a command from a project
+//	project = "api"
+//	command = "old-lint"
+//
+//	[env]              # Global env variables to set
+//	GOFLAGS = "-mod=mod"
+//
+//	[project_env.api]  # Env variables to set in a project
+//	PORT = "8080"
+type Changes struct {
+	Commands       map[string]CommandConfig     `toml:"commands"`        // Commands to add, or replace where they are defined locally
+	RemoveCommands []string                     `toml:"remove_commands"` // Commands to remove, along with their project bindings in settings.toml
+	Bind           []Binding                    `toml:"bind"`
+	Unbind         []Binding                    `toml:"unbind"`
+	Env            map[string]string            `toml:"env"`
+	UnsetEnv       []string                     `toml:"unset_env"`
+	ProjectEnv     map[string]map[string]string `toml:"project_env"`
+
+	commands map[string]interface{} // Keys of each command as written, to write them back as is
+}
+
+// Binding binds a command to a project, Alias is optional
+type Binding struct {
+	Project string `toml:"project"`
+	Command string `toml:"command"`
+	Alias   string `toml:"alias,omitempty"`
+}
+
+// ParseChanges parses a change document
+func ParseChanges(data string) (*Changes, error) {
+	var changes Changes
+	md, err := toml.Decode(data, &changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the changes: %w", err)
+	}
+	for _, key := range md.Undecoded() {
+		if key[0] != "commands" {
+			return nil, fmt.Errorf("unknown key '%s' in the changes", key)
+		}
+	}
+
+	var raw struct {
+		Commands map[string]interface{} `toml:"commands"`
+	}
+	if _, err := toml.Decode(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse the changes: %w", err)
+	}
+	changes.commands = raw.Commands
+
+	if changes.empty() {
+		return nil, fmt.Errorf("the document has no changes")
+	}
+	for name := range changes.Commands {
+		if _, ok := changes.commands[name].(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("command '%s' must be a table", name)
+		}
+	}
+	for _, binding := range append(append([]Binding{}, changes.Bind...), changes.Unbind...) {
+		if binding.Project == "" || binding.Command == "" {
+			return nil, fmt.Errorf("bind and unbind entries need a project and a command")
+		}
+	}
+	for _, key := range changes.UnsetEnv {
+		if _, ok := changes.Env[key]; ok {
+			return nil, fmt.Errorf("env variable '%s' is both set and unset", key)
+		}
+	}
+	return &changes, nil
+}
+
+func (c *Changes) empty() bool {
+	return len(c.Commands) == 0 && len(c.RemoveCommands) == 0 && len(c.Bind) == 0 && len(c.Unbind) == 0 &&
+		len(c.Env) == 0 && len(c.UnsetEnv) == 0 && len(c.ProjectEnv) == 0
+}
+
+// ApplyResult tells what Apply changed
+type ApplyResult struct {
+	Changes []string // What was changed, in the order the changes were made
+	Files   []string // Files written, or that would be with a dry run
+}
+
+// Apply makes changes to settings.toml and the local command directories as
+// one transaction: nothing is written unless every change can be made, and
+// the files are restored when the settings no longer load or check rejects
+// them. Commands from remotes can't be changed, a fetch would undo it. With
+// dryRun nothing is written and check isn't called.
+func Apply(changes *Changes, check func(*Settings) error, dryRun bool) (ApplyResult, error) {
+	var result ApplyResult
+	appDir, err := GetAppDir()
+	if err != nil {
+		return result, err
+	}
+	cfgPath := filepath.Join(appDir, pathConfig.CfgFile)
+
+	var mainSettings Settings
+	if _, err := toml.DecodeFile(cfgPath, &mainSettings); err != nil {
+		return result, fmt.Errorf("failed to decode settings file: %w", err)
+	}
+	local, err := LocalCommandNames()
+	if err != nil {
+		return result, err
+	}
+	loaded, _ := Load()
+
+	originals := make(map[string][]byte)
+	documents := make(map[string]*tomlDocument)
+	var order []string
+	document := func(path string) (*tomlDocument, error) {
+		if doc, ok := documents[path]; ok {
+			return doc, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		originals[path] = content
+		documents[path] = parseTOMLDocument(string(content))
+		order = append(order, path)
+		return documents[path], nil
+	}
+	changed := func(format string, args ...interface{}) {
+		result.Changes = append(result.Changes, fmt.Sprintf(format, args...))
+	}
+
+	// Bindings of the projects in settings.toml, rewritten once at the end
+	bindings := make(map[string][]Alias)
+	projectBindings := func(project string) ([]Alias, error) {
+		if aliases, ok := bindings[project]; ok {
+			return aliases, nil
+		}
+		p, ok := mainSettings.Projects[project]
+		if !ok {
+			return nil, fmt.Errorf("project '%s' isn't defined in %s", project, cfgPath)
+		}
+		return append([]Alias{}, p.Commands...), nil
+	}
+
+	for _, name := range changes.RemoveCommands {
+		path, table, err := commandDefinitionFile(name)
+		if err != nil {
+			return result, err
+		}
+		doc, err := document(path)
+		if err != nil {
+			return result, err
+		}
+		if removed, _ := doc.removeTables([]string{"commands", table}); removed == 0 && !doc.removeKey([]string{"commands"}, table) {
+			return result, fmt.Errorf("no [commands.%s] table found in %s", table, path)
+		}
+		changed("removed command '%s' from %s", name, path)
+
+		for _, project := range sortedKeys(mainSettings.Projects) {
+			aliases, _ := projectBindings(project)
+			kept := aliases[:0]
+			for _, alias := range aliases {
+				if alias.CommandName != name {
+					kept = append(kept, alias)
+				}
+			}
+			if len(kept) != len(aliases) {
+				bindings[project] = kept
+				changed("unbound '%s' from project '%s'", name, project)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(changes.Commands) {
+		path, table := cfgPath, name
+		verb := "added"
+		if local[name] {
+			if path, table, err = commandDefinitionFile(name); err != nil {
+				return result, err
+			}
+			verb = "replaced"
+		} else if loaded != nil {
+			if _, ok := loaded.Commands[name]; ok {
+				return result, fmt.Errorf("command '%s' comes from a remote and can't be changed, a fetch would undo it", name)
+			}
+		}
+		text, err := commandTable(table, changes.commands[name])
+		if err != nil {
+			return result, err
+		}
+		doc, err := document(path)
+		if err != nil {
+			return result, err
+		}
+		// A replaced table keeps its place in the file
+		if removed, at := doc.removeTables([]string{"commands", table}); removed > 0 {
+			doc.insertTable(at, text)
+		} else {
+			doc.removeKey([]string{"commands"}, table)
+			doc.appendTable(text)
+		}
+		changed("%s command '%s' in %s", verb, name, path)
+	}
+
+	for _, binding := range changes.Unbind {
+		aliases, err := projectBindings(binding.Project)
+		if err != nil {
+			return result, err
+		}
+		kept := aliases[:0]
+		for _, alias := range aliases {
+			if alias.CommandName != binding.Command || (binding.Alias != "" && alias.Alias != binding.Alias) {
+				kept = append(kept, alias)
+			}
+		}
+		if len(kept) == len(aliases) {
+			return result, fmt.Errorf("command '%s' isn't bound to project '%s'", binding.Command, binding.Project)
+		}
+		bindings[binding.Project] = kept
+		changed("unbound '%s' from project '%s'", binding.Command, binding.Project)
+	}
+
+	for _, binding := range changes.Bind {
+		aliases, err := projectBindings(binding.Project)
+		if err != nil {
+			return result, err
+		}
+		bound := false
+		for i := range aliases {
+			if aliases[i].CommandName == binding.Command {
+				aliases[i].Alias = binding.Alias
+				bound = true
+			}
+		}
+		if !bound {
+			aliases = append(aliases, Alias{CommandName: binding.Command, Alias: binding.Alias})
+		}
+		bindings[binding.Project] = aliases
+		if binding.Alias != "" {
+			changed("bound '%s' to project '%s' as '%s'", binding.Command, binding.Project, binding.Alias)
+		} else {
+			changed("bound '%s' to project '%s'", binding.Command, binding.Project)
+		}
+	}
+
+	if len(bindings) > 0 {
+		doc, err := document(cfgPath)
+		if err != nil {
+			return result, err
+		}
+		for _, project := range sortedKeys(bindings) {
+			path := []string{"projects", project}
+			if doc.hasTable(append(path, "commands")) {
+				return result, fmt.Errorf("the commands of project '%s' are an array of tables, edit them in %s", project, cfgPath)
+			}
+			if len(bindings[project]) == 0 {
+				doc.removeKey(path, "commands")
+			} else {
+				doc.setKey(path, "commands", aliasArray(bindings[project]))
+			}
+		}
+	}
+
+	if len(changes.Env) > 0 || len(changes.UnsetEnv) > 0 {
+		doc, err := document(cfgPath)
+		if err != nil {
+			return result, err
+		}
+		setEnv(doc, nil, mainSettings.Env, changes.Env, changes.UnsetEnv)
+		for _, key := range sortedKeys(changes.Env) {
+			changed("set %s in the global env", key)
+		}
+		for _, key := range changes.UnsetEnv {
+			if _, ok := mainSettings.Env[key]; ok {
+				changed("unset %s in the global env", key)
+			}
+		}
+	}
+
+	for _, project := range sortedKeys(changes.ProjectEnv) {
+		p, ok := mainSettings.Projects[project]
+		if !ok {
+			return result, fmt.Errorf("project '%s' isn't defined in %s", project, cfgPath)
+		}
+		doc, err := document(cfgPath)
+		if err != nil {
+			return result, err
+		}
+		setEnv(doc, []string{"projects", project}, p.Env, changes.ProjectEnv[project], nil)
+		for _, key := range sortedKeys(changes.ProjectEnv[project]) {
+			changed("set %s in the env of project '%s'", key, project)
+		}
+	}
+
+	// Refuse to write files the loader could no longer parse
+	updated := make(map[string]string)
+	for _, path := range order {
+		content := documents[path].String()
+		var check map[string]interface{}
+		if _, err := toml.Decode(content, &check); err != nil {
+			return result, fmt.Errorf("the changes would leave %s unreadable: %w", path, err)
+		}
+		if content != string(originals[path]) {
+			updated[path] = content
+			result.Files = append(result.Files, path)
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	// Files are written and restored with the mode they had
+	modes := make(map[string]os.FileMode)
+	for _, path := range result.Files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return result, err
+		}
+		modes[path] = info.Mode().Perm()
+	}
+	var written []string
+	// rollBack restores the files written so far and reports err, along with
+	// the files that couldn't be restored
+	rollBack := func(err error) error {
+		var failed []error
+		for _, path := range written {
+			if restoreErr := os.WriteFile(path, originals[path], modes[path]); restoreErr != nil {
+				failed = append(failed, restoreErr)
+			}
+		}
+		Reload()
+		if len(failed) > 0 {
+			return fmt.Errorf("%w, and rolling back the changes failed: %w", err, errors.Join(failed...))
+		}
+		return fmt.Errorf("the changes were rolled back: %w", err)
+	}
+	for _, path := range result.Files {
+		// A failed write can leave the file truncated, it's restored too
+		written = append(written, path)
+		if err := os.WriteFile(path, []byte(updated[path]), modes[path]); err != nil {
+			return result, rollBack(fmt.Errorf("failed to write %s: %w", path, err))
+		}
+	}
+
+	cfg, err := Reload()
+	if err == nil && check != nil {
+		err = check(cfg)
+	}
+	if err != nil {
+		return result, rollBack(err)
+	}
+	return result, nil
+}
+
+// commandKeyOrder lists the keys written first in a command's table, the
+// others follow by name
+var commandKeyOrder = []string{"description", "cmd", "script", "is_enabled", "is_executable"}
+
+// commandTable renders the [commands.<table>] table holding keys. Nested
+// tables and arrays of tables are written inline, which is how the loader
+// reads arguments, examples and hooks.
+func commandTable(table string, keys interface{}) (string, error) {
+	values, ok := keys.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("command '%s' must be a table", table)
+	}
+	order := make([]string, 0, len(values))
+	for _, key := range commandKeyOrder {
+		if _, ok := values[key]; ok {
+			order = append(order, key)
+		}
+	}
+	for _, key := range sortedKeys(values) {
+		if !slices.Contains(commandKeyOrder, key) {
+			order = append(order, key)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", tomlPath([]string{"commands", table}))
+	for _, key := range order {
+		value, err := tomlValue(values[key])
+		if err != nil {
+			return "", fmt.Errorf("failed to encode %s of command '%s': %w", key, table, err)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", tomlKey(key), value)
+	}
+	return b.String(), nil
+}
+
+// tomlValue renders value as TOML, with tables inline and arrays of tables
+// one table per line
+func tomlValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		entries := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			entry, err := tomlValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, tomlKey(key)+" = "+entry)
+		}
+		if len(entries) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(entries, ", ") + " }", nil
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return tomlValue(items)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		tables := false
+		for _, item := range v {
+			rendered, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			_, table := item.(map[string]interface{})
+			tables = tables || table
+			items = append(items, rendered)
+		}
+		if tables {
+			return "[\n  " + strings.Join(items, ",\n  ") + ",\n]", nil
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+
+	var encoded bytes.Buffer
+	if err := toml.NewEncoder(&encoded).Encode(map[string]interface{}{"v": value}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(encoded.String(), "v = "), "\n"), nil
+}
+
+// setEnv sets and unsets variables in the env of the table at owner, in its
+// env table when it has one and in an inline table otherwise
+func setEnv(doc *tomlDocument, owner []string, current, set map[string]string, unset []string) {
+	table := append(append([]string{}, owner...), "env")
+	if _, _, ok := doc.table(table); ok {
+		for _, key := range sortedKeys(set) {
+			doc.setKey(table, key, fmt.Sprintf("%q", set[key]))
+		}
+		for _, key := range unset {
+			doc.removeKey(table, key)
+		}
+		return
+	}
+
+	merged := make(map[string]string)
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range set {
+		merged[key] = value
+	}
+	for _, key := range unset {
+		delete(merged, key)
+	}
+	if len(merged) == 0 {
+		doc.removeKey(owner, "env")
+		return
+	}
+	doc.setKey(owner, "env", inlineTable(merged))
+}
+
+// aliasArray renders project bindings as a TOML array
+func aliasArray(aliases []Alias) string {
+	var b strings.Builder
+	b.WriteString("[\n")
+	for _, alias := range aliases {
+		if alias.Alias != "" {
+			fmt.Fprintf(&b, "  { command_name = %q, alias = %q },\n", alias.CommandName, alias.Alias)
+		} else {
+			fmt.Fprintf(&b, "  { command_name = %q },\n", alias.CommandName)
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamespaceSeparator joins the prefix of a command directory and the name of
+// a command loaded from it, like "team:deploy"
+const NamespaceSeparator = ":"
+
+// CommandDir is an entry of command_dirs. In TOML it's either the path of the
+// directory or a table also naming a prefix for the directory's commands:
+//
+//	command_dirs = ["~/cmds", { path = "~/team/cmds", prefix = "team" }]
+type CommandDir struct {
+	Path   string `toml:"path"`
+	Prefix string `toml:"prefix,omitempty"` // Namespace of the directory's commands, empty to keep their names
+}
+
+// UnmarshalTOML accepts a path or a table with path and prefix
+func (d *CommandDir) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case string:
+		d.Path = v
+		d.Prefix = ""
+	case map[string]interface{}:
+		path, _ := v["path"].(string)
+		if path == "" {
+			return fmt.Errorf("command_dirs entries need a path")
+		}
+		prefix, _ := v["prefix"].(string)
+		if strings.Contains(prefix, NamespaceSeparator) {
+			return fmt.Errorf("command_dirs prefix '%s' can't contain '%s'", prefix, NamespaceSeparator)
+		}
+		d.Path = path
+		d.Prefix = prefix
+	default:
+		return fmt.Errorf("command_dirs entries must be paths or tables with a path, got %T", data)
+	}
+	return nil
+}
+
+// String returns the path of the directory, followed by its prefix if it has one
+func (d CommandDir) String() string {
+	if d.Prefix == "" {
+		return d.Path
+	}
+	return fmt.Sprintf("%s (prefix '%s')", d.Path, d.Prefix)
+}
+
+// Namespaced returns the name a command defined as name in the directory is
+// loaded as
+func (d CommandDir) Namespaced(name string) string {
+	if d.Prefix == "" {
+		return name
+	}
+	return d.Prefix + NamespaceSeparator + name
+}
+
+// namespace renames the commands of the directory with prefix, along with the
+// references to them made inside the directory: extends, depends_on, project
+// and template command lists and overrides. References to commands defined elsewhere keep
+// their names.
+func (c *ConfigFromDirectory) namespace(dir CommandDir) {
+	if dir.Prefix == "" {
+		return
+	}
+	rename := func(name string) string {
+		if _, ok := c.Commands[name]; ok {
+			return dir.Namespaced(name)
+		}
+		return name
+	}
+	renameAliases := func(aliases []Alias) []Alias {
+		renamed := make([]Alias, len(aliases))
+		for i, alias := range aliases {
+			alias.CommandName = rename(alias.CommandName)
+			renamed[i] = alias
+		}
+		return renamed
+	}
+
+	for name, project := range c.Projects {
+		project.Commands = renameAliases(project.Commands)
+		c.Projects[name] = project
+	}
+	for name, template := range c.ProjectTemplates {
+		template.Commands = renameAliases(template.Commands)
+		c.ProjectTemplates[name] = template
+	}
+	for i, name := range c.Overrides {
+		c.Overrides[i] = rename(name)
+	}
+
+	commands := make(map[string]CommandConfig, len(c.Commands))
+	sources := make(map[string]string, len(c.Commands))
+	for name, cmd := range c.Commands {
+		cmd.Extends = rename(cmd.Extends)
+		if len(cmd.DependsOn) > 0 {
+			dependsOn := make([]string, len(cmd.DependsOn))
+			for i, dependency := range cmd.DependsOn {
+				dependsOn[i] = rename(dependency)
+			}
+			cmd.DependsOn = dependsOn
+		}
+		commands[dir.Namespaced(name)] = cmd
+
+		key := sourceKey("command", name)
+		if source, ok := c.Sources[key]; ok {
+			sources[sourceKey("command", dir.Namespaced(name))] = source
+			delete(c.Sources, key)
+		}
+	}
+	for key, source := range sources {
+		c.Sources[key] = source
+	}
+	c.Commands = commands
+}
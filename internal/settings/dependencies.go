@@ -0,0 +1,56 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyOrder returns the commands the command depends on through
+// depends_on, directly or through other dependencies, in the order they run:
+// each after the commands it depends on, in the order depends_on lists them,
+// and each only once. The command itself isn't included. An undefined
+// dependency or a cycle is an error.
+func (s *Settings) DependencyOrder(name string) ([]string, error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int)
+	var order []string
+
+	var visit func(path []string) error
+	visit = func(path []string) error {
+		current := path[len(path)-1]
+		switch state[current] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("cyclic depends_on: %s", strings.Join(path, " -> "))
+		}
+
+		cmd, exists := s.Commands[current]
+		if !exists {
+			if len(path) == 1 {
+				return fmt.Errorf("command '%s' not found", current)
+			}
+			return fmt.Errorf("'%s' depends on undefined command '%s'", path[len(path)-2], current)
+		}
+
+		state[current] = visiting
+		for _, dependency := range cmd.DependsOn {
+			if err := visit(append(path[:len(path):len(path)], dependency)); err != nil {
+				return err
+			}
+		}
+		state[current] = visited
+		if len(path) > 1 {
+			order = append(order, current)
+		}
+		return nil
+	}
+
+	if err := visit([]string{name}); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
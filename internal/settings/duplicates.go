@@ -0,0 +1,78 @@
+package settings
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// duplicateKeyPattern matches the toml package's message for a table or key
+// defined twice in the same file
+var duplicateKeyPattern = regexp.MustCompile(`^Key '(.+)' has already been defined\.?$`)
+
+// describeDuplicate rewrites a duplicate key message to name the line of the
+// first definition, found by scanning the file. ok is false for other messages.
+func describeDuplicate(data []byte, message string, line int) (string, bool) {
+	match := duplicateKeyPattern.FindStringSubmatch(message)
+	if match == nil {
+		return "", false
+	}
+
+	key := match[1]
+	if first := firstDefinition(data, key, line); first > 0 {
+		return fmt.Sprintf("duplicate key '%s', first defined on line %d", key, first), true
+	}
+	return fmt.Sprintf("duplicate key '%s'", key), true
+}
+
+// firstDefinition returns the line, counted from 1, of the first table header
+// or key assignment before line that defines key or a key below it, 0 when
+// there's none. It's a line based scan: headers and assignments inside
+// multi-line strings can confuse it, which only costs the hint.
+func firstDefinition(data []byte, key string, line int) int {
+	var table string
+	for i, text := range strings.Split(string(data), "\n") {
+		if i+1 >= line {
+			break
+		}
+
+		text = strings.TrimSpace(text)
+		var defined string
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "[["):
+			// Array tables may repeat, so they never define a key first
+			table = normalizeKey(strings.Trim(text, "[] "))
+			continue
+		case strings.HasPrefix(text, "["):
+			if end := strings.Index(text, "]"); end > 0 {
+				table = normalizeKey(text[1:end])
+				defined = table
+			}
+		default:
+			eq := strings.Index(text, "=")
+			if eq <= 0 {
+				continue
+			}
+			defined = normalizeKey(text[:eq])
+			if table != "" {
+				defined = table + "." + defined
+			}
+		}
+
+		if defined == key || strings.HasPrefix(defined, key+".") {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// normalizeKey removes quotes and the whitespace around the dots of a key
+func normalizeKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
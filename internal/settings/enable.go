@@ -0,0 +1,133 @@
+package settings
+
+import (
+	"fmt"
+	pathutil "interop/internal/path"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// isEnabledLine matches an is_enabled assignment inside a command table
+var isEnabledLine = regexp.MustCompile(`^\s*is_enabled\s*=`)
+
+// SetCommandEnabled sets is_enabled for a command in the local file that
+// defines it, settings.toml or one of the command directories, and returns
+// the file's path. Commands fetched from remotes are rejected because a fetch
+// would overwrite the change.
+func SetCommandEnabled(name string, enabled bool) (string, error) {
+	path, table, err := commandDefinitionFile(name)
+	if err != nil {
+		return "", err
+	}
+	if err := setCommandEnabledInFile(path, table, enabled); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// commandDefinitionFile returns the local file defining a command and the
+// name of its table there, which lacks the prefix of its command directory,
+// following the precedence used when loading settings
+func commandDefinitionFile(name string) (string, string, error) {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	var mainSettings Settings
+	cfgPath := filepath.Join(appDir, pathConfig.CfgFile)
+	if _, err := toml.DecodeFile(cfgPath, &mainSettings); err != nil {
+		return "", "", fmt.Errorf("failed to decode settings file: %w", err)
+	}
+	if _, ok := mainSettings.Commands[name]; ok {
+		return cfgPath, name, nil
+	}
+
+	for _, commandDir := range localCommandDirs(&mainSettings) {
+		table := name
+		if commandDir.Prefix != "" {
+			var ok bool
+			if table, ok = strings.CutPrefix(name, commandDir.Prefix+NamespaceSeparator); !ok {
+				continue
+			}
+		}
+		dir := commandDir.Path
+		if expanded, err := pathutil.Expand(dir); err == nil {
+			dir = expanded
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+		if err != nil {
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			var fileConfig ConfigFromDirectory
+			if _, err := toml.DecodeFile(file, &fileConfig); err != nil {
+				continue
+			}
+			if _, ok := fileConfig.Commands[table]; ok {
+				return file, table, nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("command '%s' is not defined locally; commands from remotes can't be changed", name)
+}
+
+// setCommandEnabledInFile rewrites the is_enabled line of the [commands.<name>]
+// table in path, adding one when missing. The rest of the file, including
+// comments, is kept as is.
+func setCommandEnabledInFile(path, name string, enabled bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	headers := map[string]bool{
+		fmt.Sprintf("[commands.%s]", name):   true,
+		fmt.Sprintf("[commands.%q]", name):   true,
+		fmt.Sprintf("[commands.'%s']", name): true,
+	}
+	lines := strings.Split(string(content), "\n")
+	header := -1
+	for i, line := range lines {
+		if headers[strings.TrimSpace(line)] {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return fmt.Errorf("no [commands.%s] table found in %s", name, path)
+	}
+
+	setting := fmt.Sprintf("is_enabled = %t", enabled)
+	updated := false
+	for i := header + 1; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "["); i++ {
+		if isEnabledLine.MatchString(lines[i]) {
+			lines[i] = setting
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		lines = append(lines[:header+1], append([]string{setting}, lines[header+1:]...)...)
+	}
+
+	// Refuse to write a file the loader could no longer parse
+	result := strings.Join(lines, "\n")
+	var check map[string]interface{}
+	if _, err := toml.Decode(result, &check); err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(result), info.Mode().Perm())
+}
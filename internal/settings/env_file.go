@@ -0,0 +1,86 @@
+package settings
+
+import (
+	"bufio"
+	"fmt"
+	"interop/internal/logging"
+	pathutil "interop/internal/path"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseEnvAssignment validates a KEY=VALUE pair and returns it normalized
+func ParseEnvAssignment(assignment string) (string, error) {
+	key, value, ok := strings.Cut(assignment, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", fmt.Errorf("invalid environment assignment '%s', expected KEY=VALUE", assignment)
+	}
+	return key + "=" + value, nil
+}
+
+// ParseEnvFile reads KEY=VALUE pairs from a dotenv style file. Blank lines and
+// lines starting with # are skipped, an optional `export ` prefix is allowed,
+// and matching surrounding quotes are removed from values.
+func ParseEnvFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		assignment, err := ParseEnvAssignment(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		key, value, _ := strings.Cut(assignment, "=")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env = append(env, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	return env, nil
+}
+
+// EnvFilePath returns the path of an env_file setting, with ~ expanded and a
+// relative path taken from dir
+func EnvFilePath(envFile, dir string) string {
+	if strings.HasPrefix(envFile, "~/") {
+		if expanded, err := pathutil.Expand(envFile); err == nil {
+			return expanded
+		}
+	}
+	if filepath.IsAbs(envFile) {
+		return envFile
+	}
+	return filepath.Join(dir, envFile)
+}
+
+// loadEnvFile reads the env_file of a settings level, relative to dir. A file
+// that can't be read is skipped with a warning, so the command still runs.
+func loadEnvFile(envFile, dir string) []string {
+	if envFile == "" {
+		return nil
+	}
+	env, err := ParseEnvFile(EnvFilePath(envFile, dir))
+	if err != nil {
+		logging.Warning("Skipping env_file '%s': %v", envFile, err)
+		return nil
+	}
+	return env
+}
@@ -0,0 +1,79 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// EnvPolicy selects the environment commands run with when MCP tool calls
+// start them
+type EnvPolicy string
+
+const (
+	EnvPolicyInherit   EnvPolicy = "inherit"   // The server's whole environment; the default
+	EnvPolicyClean     EnvPolicy = "clean"     // Only the essential variables, see EssentialEnvVars
+	EnvPolicyAllowlist EnvPolicy = "allowlist" // The essential variables and those matching env_allowlist
+)
+
+// EssentialEnvVars are kept by the clean and allowlist policies, commands
+// need them to find programs and their home directory
+var EssentialEnvVars = []string{"PATH", "HOME", "USER", "LOGNAME", "LANG", "TMPDIR"}
+
+// Validate checks that the policy is known, an empty policy is inherited
+func (p EnvPolicy) Validate() error {
+	switch p {
+	case "", EnvPolicyInherit, EnvPolicyClean, EnvPolicyAllowlist:
+		return nil
+	}
+	return fmt.Errorf("invalid env_policy '%s', expected inherit, clean or allowlist", p)
+}
+
+// EnvPolicyFor returns the policy and allowlist a command runs with on the
+// MCP server named server, empty for the default one. The command's policy
+// takes precedence over the server's, and their allowlists add up.
+func (cfg *Settings) EnvPolicyFor(server string, cmd CommandConfig) (EnvPolicy, []string) {
+	policy, allowlist := cfg.EnvPolicy, cfg.EnvAllowlist
+	if server != "" {
+		serverCfg := cfg.MCPServers[server]
+		policy, allowlist = serverCfg.EnvPolicy, serverCfg.EnvAllowlist
+	}
+	if cmd.EnvPolicy != "" {
+		policy = cmd.EnvPolicy
+	}
+	if policy == "" {
+		policy = EnvPolicyInherit
+	}
+	return policy, append(append([]string{}, allowlist...), cmd.EnvAllowlist...)
+}
+
+// FilterEnvironment returns the KEY=VALUE entries of environ the policy
+// passes on. Allowlist entries are names or patterns like "AWS_*".
+func FilterEnvironment(environ []string, policy EnvPolicy, allowlist []string) []string {
+	if policy == "" || policy == EnvPolicyInherit {
+		return environ
+	}
+
+	patterns := EssentialEnvVars
+	if policy == EnvPolicyAllowlist {
+		patterns = append(append([]string{}, EssentialEnvVars...), allowlist...)
+	}
+	var kept []string
+	for _, entry := range environ {
+		key, _, _ := strings.Cut(entry, "=")
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, key); matched {
+				kept = append(kept, entry)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// MergeEnvironmentWithPolicy is MergeEnvironmentVariables starting from the
+// part of the current environment the policy passes on
+func MergeEnvironmentWithPolicy(cfg *Settings, commandName, projectName string, policy EnvPolicy, allowlist []string) ([]string, error) {
+	return mergeEnvironment(FilterEnvironment(os.Environ(), policy, allowlist), cfg, commandName, projectName, true)
+}
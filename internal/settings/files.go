@@ -0,0 +1,66 @@
+package settings
+
+import (
+	"fmt"
+	pathutil "interop/internal/path"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigFiles returns the files s is loaded from: settings.toml and the TOML
+// files of the command directories, including fetched remote configuration
+func ConfigFiles(s *Settings) []string {
+	dirs := ConfigDirs(s)
+	if len(dirs) == 0 {
+		return nil
+	}
+	files := []string{filepath.Join(dirs[0], pathConfig.CfgFile)}
+
+	for _, dir := range dirs[1:] {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+		if err != nil {
+			continue
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files
+}
+
+// ConfigDirs returns the directories holding the ConfigFiles of s: the
+// configuration directory first, then the command directories, including the
+// one of fetched remote configuration
+func ConfigDirs(s *Settings) []string {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return nil
+	}
+	dirs := []string{appDir}
+
+	commandDirs := localCommandDirs(s)
+	commandDirs = append(commandDirs, CommandDir{Path: filepath.Join(appDir, "config.d.remote")})
+	for _, commandDir := range commandDirs {
+		dir := commandDir.Path
+		if expanded, err := pathutil.Expand(dir); err == nil {
+			dir = expanded
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// ConfigFingerprint summarizes the names, sizes and modification times of
+// the ConfigFiles of s. It changes when one of them is saved, added or removed.
+func ConfigFingerprint(s *Settings) string {
+	var fingerprint strings.Builder
+	for _, file := range ConfigFiles(s) {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&fingerprint, "%s:%d:%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return fingerprint.String()
+}
@@ -0,0 +1,50 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DepthEnvVar counts how many interop invocations a process runs inside.
+// Hooks and commands calling interop again increase it, see EnterInvocation.
+const DepthEnvVar = "INTEROP_DEPTH"
+
+// BinEnvVar holds the absolute path of the running interop binary, so hooks,
+// commands and scripts can call back into the same version of interop
+const BinEnvVar = "INTEROP_BIN"
+
+// MaxDepth is how deeply interop invocations may be nested before a hook or
+// command calling interop is taken for a loop
+const MaxDepth = 8
+
+// Depth returns the nesting depth of this interop invocation, 0 when it
+// wasn't started by another one
+func Depth() int {
+	depth, err := strconv.Atoi(os.Getenv(DepthEnvVar))
+	if err != nil || depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// EnterInvocation records this interop invocation in the environment child
+// processes inherit: the nesting depth goes up by one and INTEROP_BIN points
+// to this binary. It fails when the invocation is nested MaxDepth deep, which
+// stops a hook or command calling itself through interop from recursing forever.
+func EnterInvocation() error {
+	depth := Depth()
+	if depth >= MaxDepth {
+		return fmt.Errorf("interop is nested %d invocations deep, a hook or command probably calls itself through interop", depth)
+	}
+	os.Setenv(DepthEnvVar, strconv.Itoa(depth+1))
+
+	if executable, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		os.Setenv(BinEnvVar, executable)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package settings
+
+import (
+	"fmt"
+	"interop/internal/logging"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Settings are resolved with the following precedence (highest to lowest):
+// 1. INTEROP_* environment variable overrides
+// 2. The main settings.toml
+// 3. Files in command_dirs and remote config directories
+// 4. Built-in defaults
+//
+// Overrides are applied to the merged configuration before command
+// inheritance and project templates are resolved, so overriding a base
+// command also affects the commands extending it. Overriding a field of a
+// command extending another keeps the override.
+
+const (
+	// envOverridePrefix starts a generic override whose remaining name is a
+	// settings key path separated by "__", e.g.
+	// INTEROP_SETTINGS__commands__build__is_enabled=false
+	envOverridePrefix = "INTEROP_SETTINGS__"
+	// envOverrideSeparator separates the segments of a settings key path
+	envOverrideSeparator = "__"
+)
+
+// envOverrideShortcuts maps dedicated environment variables to settings keys
+var envOverrideShortcuts = map[string]string{
+	"INTEROP_LOG_LEVEL": "log_level",
+	"INTEROP_MCP_PORT":  "mcp_port",
+}
+
+// applyEnvOverrides applies INTEROP_* environment variable overrides to the
+// settings. Invalid overrides are logged and skipped.
+func (s *Settings) applyEnvOverrides(environ []string) {
+	overrides := make(map[string]string)
+	shortcuts := make(map[string]string)
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := parts[0], parts[1]
+
+		if key, ok := envOverrideShortcuts[name]; ok {
+			shortcuts[key] = value
+		} else if strings.HasPrefix(name, envOverridePrefix) {
+			overrides[strings.TrimPrefix(name, envOverridePrefix)] = value
+		}
+	}
+
+	// Dedicated variables win over generic overrides of the same key
+	for key, value := range shortcuts {
+		overrides[key] = value
+	}
+
+	// Apply in a stable order so repeated keys behave predictably
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := strings.Split(key, envOverrideSeparator)
+		if err := setSettingsValue(reflect.ValueOf(s).Elem(), path, overrides[key]); err != nil {
+			logging.Warning("Ignoring environment override for '%s': %v", strings.Join(path, "."), err)
+			continue
+		}
+		logging.Message("Applied environment override for '%s'", strings.Join(path, "."))
+	}
+}
+
+// setSettingsValue walks a settings key path through structs (matched by toml
+// tag) and maps, and sets the final value parsed from its string form
+func setSettingsValue(target reflect.Value, path []string, raw string) error {
+	if len(path) == 0 || path[0] == "" {
+		return fmt.Errorf("empty settings key")
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		field, name, ok := fieldByTOMLKey(target, path[0])
+		if !ok {
+			return fmt.Errorf("unknown key '%s'", path[0])
+		}
+		var err error
+		if len(path) == 1 {
+			err = setScalarValue(field, raw)
+		} else {
+			err = setSettingsValue(field, path[1:], raw)
+		}
+		if err != nil {
+			return err
+		}
+		// An overridden command field counts as set, so extends doesn't
+		// replace it with the base command's value
+		if !target.CanAddr() {
+			return nil
+		}
+		if command, ok := target.Addr().Interface().(*CommandConfig); ok && command.defined != nil {
+			command.defined[name] = true
+		}
+		return nil
+
+	case reflect.Map:
+		if target.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type for '%s'", path[0])
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+
+		key := mapKey(target, path[0])
+		// Map values aren't addressable, so update a copy and store it back
+		elem := reflect.New(target.Type().Elem()).Elem()
+		if existing := target.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		} else if elem.Kind() == reflect.Struct {
+			// Partially defined entries such as commands would be unusable
+			return fmt.Errorf("'%s' is not defined", path[0])
+		}
+		var err error
+		if len(path) == 1 {
+			err = setScalarValue(elem, raw)
+		} else {
+			err = setSettingsValue(elem, path[1:], raw)
+		}
+		if err != nil {
+			return err
+		}
+		target.SetMapIndex(key, elem)
+		return nil
+
+	default:
+		return fmt.Errorf("cannot descend into '%s'", path[0])
+	}
+}
+
+// fieldByTOMLKey finds a struct field and its toml tag by the tag, ignoring
+// case since environment variable names are often upper-cased
+func fieldByTOMLKey(target reflect.Value, key string) (reflect.Value, string, bool) {
+	t := target.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if strings.EqualFold(name, key) {
+			return target.Field(i), name, true
+		}
+	}
+	return reflect.Value{}, "", false
+}
+
+// mapKey returns the existing map key matching name, preferring an exact match
+// over a case-insensitive one, or name itself for a new entry
+func mapKey(target reflect.Value, name string) reflect.Value {
+	exact := reflect.ValueOf(name).Convert(target.Type().Key())
+	if target.MapIndex(exact).IsValid() {
+		return exact
+	}
+	for _, key := range target.MapKeys() {
+		if strings.EqualFold(key.String(), name) {
+			return key
+		}
+	}
+	return exact
+}
+
+// setScalarValue parses raw into a string, bool, integer, or string slice value.
+// String slices are given as comma separated lists.
+func setScalarValue(target reflect.Value, raw string) error {
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean '%s'", raw)
+		}
+		target.SetBool(b)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number '%s'", raw)
+		}
+		target.SetInt(n)
+	case reflect.Slice:
+		if target.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("only string lists can be overridden")
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		target.Set(reflect.ValueOf(items).Convert(target.Type()))
+	default:
+		return fmt.Errorf("unsupported value type %s", target.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigParseError reports a configuration file that isn't valid TOML or
+// whose values don't match the expected types
+type ConfigParseError struct {
+	File    string // Path of the configuration file
+	Line    int    // Line of the error, 0 when unknown
+	Column  int    // Column of the error, 0 when unknown
+	Message string // Description of the problem without the position
+	Err     error  // Underlying decode error
+}
+
+func (e *ConfigParseError) Error() string {
+	if e.Line > 0 && e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Message)
+}
+
+// Unwrap returns the underlying decode error
+func (e *ConfigParseError) Unwrap() error {
+	return e.Err
+}
+
+// typeErrorPattern matches the decode errors the toml package reports without
+// a position, such as values of the wrong type
+var typeErrorPattern = regexp.MustCompile(`^toml: line (\d+)(?: \(last key "([^"]*)"\))?: (.*)$`)
+
+// decodeFile decodes a TOML file into v and returns its content, reporting
+// decode failures as a ConfigParseError with the position of the problem when
+// it's known. Errors reading the file are returned as they are.
+func decodeFile(path string, v interface{}) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = toml.Decode(string(data), v); err == nil {
+		return data, nil
+	}
+
+	parseErr := &ConfigParseError{File: path, Message: err.Error(), Err: err}
+	var tomlErr toml.ParseError
+	if errors.As(err, &tomlErr) {
+		parseErr.Line = tomlErr.Position.Line
+		parseErr.Column = tomlErr.Position.Col
+		parseErr.Message = tomlErr.Message
+		if message, ok := describeDuplicate(data, tomlErr.Message, parseErr.Line); ok {
+			parseErr.Message = message
+		} else if tomlErr.LastKey != "" {
+			parseErr.Message = fmt.Sprintf("%s (key %q)", tomlErr.Message, tomlErr.LastKey)
+		}
+	} else if match := typeErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		// Type mismatches only carry the line in their message
+		parseErr.Line, _ = strconv.Atoi(match[1])
+		parseErr.Message = match[3]
+		if match[2] != "" {
+			parseErr.Message = fmt.Sprintf("%s (key %q)", match[3], match[2])
+		}
+	}
+	return data, parseErr
+}
@@ -0,0 +1,29 @@
+package settings
+
+import "time"
+
+// LoadPhase is a step of Load with the time it began and ended
+type LoadPhase struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// loadPhases are the steps the last Load took, see LoadPhases
+var loadPhases []LoadPhase
+
+// LoadPhases returns the steps the last Load took: reading settings.toml,
+// merging command directories, resolving inheritance, templates and globs,
+// and validating the result. Tracing records them after the fact, as Load
+// runs before it is set up.
+func LoadPhases() []LoadPhase {
+	return loadPhases
+}
+
+// endPhase records the step of Load that began at start and returns its end,
+// when the next step begins
+func endPhase(name string, start time.Time) time.Time {
+	end := time.Now()
+	loadPhases = append(loadPhases, LoadPhase{Name: name, Start: start, End: end})
+	return end
+}
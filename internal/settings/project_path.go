@@ -0,0 +1,160 @@
+package settings
+
+import (
+	"fmt"
+	pathutil "interop/internal/path"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reasons a project_path argument is rejected
+const (
+	ProjectPathRelative     = "relative"
+	ProjectPathNotFound     = "not_found"
+	ProjectPathNotDirectory = "not_directory"
+	ProjectPathOutsideRoots = "outside_allowed_roots"
+	ProjectPathUnregistered = "unregistered"
+	ProjectPathDisabled     = "disabled"
+	ProjectPathNotAllowed   = "not_allowed"
+)
+
+// ProjectPathError reports a project_path argument that failed CheckProjectPath
+type ProjectPathError struct {
+	Path    string `json:"path"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *ProjectPathError) Error() string {
+	return e.Message
+}
+
+// CheckProjectPath validates a project_path given to a global command and
+// returns it absolute with symlinks resolved. The path must be an existing
+// directory inside allowed_project_roots and, with registeredOnly, inside a
+// configured project.
+func (s *Settings) CheckProjectPath(path string, registeredOnly bool) (string, error) {
+	reject := func(reason, format string, args ...interface{}) (string, error) {
+		return "", &ProjectPathError{Path: path, Reason: reason, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if !strings.HasPrefix(path, "~/") && !filepath.IsAbs(path) {
+		return reject(ProjectPathRelative, "project_path must be absolute or start with ~/: %s", path)
+	}
+	expanded, err := pathutil.Expand(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve symlinks so a link can't lead outside the allowed roots
+	resolved, err := filepath.EvalSymlinks(expanded)
+	if err != nil {
+		return reject(ProjectPathNotFound, "project_path does not exist: %s", path)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return reject(ProjectPathNotFound, "project_path does not exist: %s", path)
+	}
+	if !info.IsDir() {
+		return reject(ProjectPathNotDirectory, "project_path is not a directory: %s", path)
+	}
+
+	if !underRoots(resolved, s.resolvedProjectRoots()) {
+		return reject(ProjectPathOutsideRoots, "project_path must be inside allowed_project_roots: %s", path)
+	}
+	if registeredOnly {
+		if _, ok := s.ProjectContaining(resolved); !ok {
+			return reject(ProjectPathUnregistered, "project_path must be inside a configured project: %s", path)
+		}
+	}
+
+	return resolved, nil
+}
+
+// ProjectPathAllowed reports whether the command's MCP tool takes a
+// project_path, unless allow_project_path = false
+func (c CommandConfig) ProjectPathAllowed() bool {
+	return c.AllowProjectPath == nil || *c.AllowProjectPath
+}
+
+// CheckCommandProjectPath validates a project_path given to the global command
+// cmd like CheckProjectPath, also rejecting it when the command doesn't take
+// one or it isn't inside one of the command's allowed_projects
+func (s *Settings) CheckCommandProjectPath(path string, cmd CommandConfig, registeredOnly bool) (string, error) {
+	if !cmd.ProjectPathAllowed() {
+		return "", &ProjectPathError{Path: path, Reason: ProjectPathDisabled, Message: "project_path is disabled for this command"}
+	}
+	resolved, err := s.CheckProjectPath(path, registeredOnly)
+	if err != nil || len(cmd.AllowedProjects) == 0 {
+		return resolved, err
+	}
+	for _, name := range cmd.AllowedProjects {
+		if s.projectContains(name, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", &ProjectPathError{
+		Path:    path,
+		Reason:  ProjectPathNotAllowed,
+		Message: fmt.Sprintf("project_path must be inside one of the projects %s: %s", strings.Join(cmd.AllowedProjects, ", "), path),
+	}
+}
+
+// projectContains reports whether the directory of the project name is or
+// contains path, an absolute path with symlinks resolved
+func (s *Settings) projectContains(name, path string) bool {
+	project, exists := s.Projects[name]
+	if !exists {
+		return false
+	}
+	projectPath, ok := resolvedProjectPath(project)
+	return ok && (path == projectPath || strings.HasPrefix(path, projectPath+string(filepath.Separator)))
+}
+
+// resolvedProjectPath returns the project's directory, with symlinks resolved
+// when it exists
+func resolvedProjectPath(project Project) (string, bool) {
+	projectPath, err := pathutil.Expand(project.Path)
+	if err != nil {
+		return "", false
+	}
+	if resolved, err := filepath.EvalSymlinks(projectPath); err == nil {
+		projectPath = resolved
+	}
+	return filepath.Clean(projectPath), true
+}
+
+// resolvedProjectRoots returns ProjectRoots with symlinks resolved when they
+// exist, to compare them with paths that have theirs resolved
+func (s *Settings) resolvedProjectRoots() []string {
+	roots := s.ProjectRoots()
+	for i, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			roots[i] = resolved
+		}
+	}
+	return roots
+}
+
+// ProjectContaining returns the project whose directory is or contains path,
+// preferring the deepest one. Project paths are compared with symlinks
+// resolved when they exist.
+func (s *Settings) ProjectContaining(path string) (string, bool) {
+	path = filepath.Clean(path)
+
+	var match, matchPath string
+	for name, project := range s.Projects {
+		projectPath, ok := resolvedProjectPath(project)
+		if !ok {
+			continue
+		}
+		if path != projectPath && !strings.HasPrefix(path, projectPath+string(filepath.Separator)) {
+			continue
+		}
+		if len(projectPath) > len(matchPath) || (len(projectPath) == len(matchPath) && name < match) {
+			match, matchPath = name, projectPath
+		}
+	}
+	return match, match != ""
+}
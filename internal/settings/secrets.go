@@ -0,0 +1,49 @@
+package settings
+
+import (
+	"interop/internal/secrets"
+	"strings"
+)
+
+// SecretUse is an env variable of the settings whose value is a secret://
+// reference
+type SecretUse struct {
+	Variable  string `json:"variable"`
+	Owner     string `json:"owner"` // "global", "project 'api'" or "command 'deploy'"
+	Reference string `json:"reference"`
+}
+
+// SecretReferences returns the secret references of the global, project and
+// command env tables, in that order. References in env files aren't listed.
+func SecretReferences(cfg *Settings) []SecretUse {
+	var uses []SecretUse
+	collect := func(owner string, env map[string]string) {
+		for _, variable := range sortedKeys(env) {
+			if value := env[variable]; secrets.IsReference(value) {
+				uses = append(uses, SecretUse{Variable: variable, Owner: owner, Reference: value})
+			}
+		}
+	}
+
+	collect("global", cfg.Env)
+	for _, name := range sortedKeys(cfg.Projects) {
+		collect("project '"+name+"'", cfg.Projects[name].Env)
+	}
+	for _, name := range sortedKeys(cfg.Commands) {
+		collect("command '"+name+"'", cfg.Commands[name].Env)
+	}
+	return uses
+}
+
+// StoredSecretVariables returns the variables of the environment
+// MergeEnvironmentVariables sets up for a command whose values are read from
+// a secret store, env files included
+func StoredSecretVariables(cfg *Settings, commandName, projectName string) []string {
+	var names []string
+	for _, variable := range PreviewEnvironmentVariables(cfg, commandName, projectName) {
+		if name, value, _ := strings.Cut(variable, "="); secrets.IsReference(value) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,78 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ServerList names the MCP servers serving a command or prompt, given in TOML
+// as one name, mcp = "ci", or a list, mcp = ["build", "ci"]. An empty list
+// leaves it to the default server.
+type ServerList []string
+
+// Includes reports whether the list names serverName, or is empty when
+// serverName is empty, for the default server
+func (l ServerList) Includes(serverName string) bool {
+	if serverName == "" {
+		return len(l) == 0
+	}
+	for _, name := range l {
+		if name == serverName {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the servers of the list, the default one with an empty name
+// when it is empty
+func (l ServerList) Names() []string {
+	if len(l) == 0 {
+		return []string{""}
+	}
+	return l
+}
+
+// String returns the names separated by commas
+func (l ServerList) String() string {
+	return strings.Join(l, ", ")
+}
+
+// UnmarshalTOML reads a single name or a list of names
+func (l *ServerList) UnmarshalTOML(data interface{}) error {
+	switch data.(type) {
+	case string, []interface{}:
+		*l = ParseStringSlice(data)
+		return nil
+	}
+	return fmt.Errorf("mcp must be a server name or a list of names, got %T", data)
+}
+
+// MarshalTOML writes a single name as a string, as it is usually given
+func (l ServerList) MarshalTOML() ([]byte, error) {
+	return l.MarshalJSON()
+}
+
+// UnmarshalJSON reads a single name or a list of names
+func (l *ServerList) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*l = ServerList{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("mcp must be a server name or a list of names")
+	}
+	*l = names
+	return nil
+}
+
+// MarshalJSON writes a single name as a string, as it is usually given
+func (l ServerList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
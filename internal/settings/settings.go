@@ -2,14 +2,22 @@ package settings
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"interop/internal/logging"
+	pathutil "interop/internal/path"
+	"interop/internal/secrets"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -21,10 +29,32 @@ type Alias struct {
 
 // MCPServer represents a configured MCP server with a name, description, and port
 type MCPServer struct {
-	Name             string `toml:"name"`
-	Description      string `toml:"description"`
-	Port             int    `toml:"port"`
-	IsToolOutputJson bool   `toml:"is_tool_output_json,omitempty"`
+	Name                  string `toml:"name"`
+	Description           string `toml:"description"`
+	Port                  int    `toml:"port"`
+	IsToolOutputJson      bool   `toml:"is_tool_output_json,omitempty"`
+	RestartOnConfigChange bool   `toml:"restart_on_config_change,omitempty"` // Let `mcp supervise` restart the server when its config changes
+	QuietHours            string `toml:"quiet_hours,omitempty"`              // HH:MM-HH:MM window in which restarts are deferred
+	Admin                 bool   `toml:"admin,omitempty"`                    // Serve interop's management tools instead of commands
+	BatchTool             bool   `toml:"batch_tool,omitempty"`               // Register the run-batch tool running several commands in one call
+	RestrictProjectPath   bool   `toml:"restrict_project_path,omitempty"`    // Only accept project_path values inside configured projects
+
+	// Environment tool calls run commands with, see EnvPolicyFor
+	EnvPolicy    EnvPolicy `toml:"env_policy,omitempty"`
+	EnvAllowlist []string  `toml:"env_allowlist,omitempty"` // Variables, or patterns like "AWS_*", the allowlist policy passes on
+
+	// Project whose commands the server serves, set for projects with
+	// mcp_server, see projectMCPServers
+	Project string `toml:"-"`
+
+	// Autostart false leaves the server out of `mcp start --all`, see Autostarts
+	Autostart *bool `toml:"autostart,omitempty"`
+}
+
+// Autostarts reports whether `mcp start --all` starts the server, true unless
+// autostart = false
+func (s MCPServer) Autostarts() bool {
+	return s.Autostart == nil || *s.Autostart
 }
 
 type Project struct {
@@ -32,6 +62,28 @@ type Project struct {
 	Description string            `toml:"description,omitempty"`
 	Commands    []Alias           `toml:"commands,omitempty"`
 	Env         map[string]string `toml:"env,omitempty"`
+	EnvFile     string            `toml:"env_file,omitempty"` // Dotenv file loaded below env, relative to the project path
+	Extends     string            `toml:"extends,omitempty"`  // Name of the project template to inherit defaults from
+	Group       string            `toml:"-"`                  // Name of the glob project this sub-project was expanded from
+	SourceFile  string            `toml:"-"`                  // Settings file the project was loaded from
+	SourceLine  int               `toml:"-"`                  // Line of the project's table in SourceFile, 0 when unknown
+
+	// MCPServer generates an MCP server named after the project, serving its
+	// commands, see projectMCPServers
+	MCPServer bool `toml:"mcp_server,omitempty"`
+	MCPPort   int  `toml:"mcp_port,omitempty"` // Port of the generated server, picked after mcp_port when 0
+}
+
+// ProjectTemplate provides default commands and env for projects that extend it
+type ProjectTemplate struct {
+	Description string            `toml:"description,omitempty"`
+	Commands    []Alias           `toml:"commands,omitempty"`
+	Env         map[string]string `toml:"env,omitempty"`
+}
+
+// IsGlobPath reports whether a project path contains glob patterns
+func IsGlobPath(path string) bool {
+	return strings.ContainsAny(path, "*?[")
 }
 
 // ArgumentType defines the type of a command argument
@@ -48,12 +100,13 @@ const (
 
 // CommandArgument represents an argument definition for a command
 type CommandArgument struct {
-	Name        string       `toml:"name"`                  // Argument name
-	Type        ArgumentType `toml:"type,omitempty"`        // Argument type (string, number, bool)
-	Description string       `toml:"description,omitempty"` // Description of the argument
-	Required    bool         `toml:"required,omitempty"`    // Whether the argument is required
-	Default     interface{}  `toml:"default,omitempty"`     // Default value if not provided
-	Prefix      string       `toml:"prefix,omitempty"`      // Prefix to use for the argument (e.g. "--keys")
+	Name        string       `toml:"name" json:"name"`                                   // Argument name
+	Type        ArgumentType `toml:"type,omitempty" json:"type,omitempty"`               // Argument type (string, number, bool)
+	Description string       `toml:"description,omitempty" json:"description,omitempty"` // Description of the argument
+	Required    bool         `toml:"required,omitempty" json:"required"`                 // Whether the argument is required
+	Default     interface{}  `toml:"default,omitempty" json:"default,omitempty"`         // Default value if not provided
+	Prefix      string       `toml:"prefix,omitempty" json:"prefix,omitempty"`           // Prefix to use for the argument (e.g. "--keys")
+	FromFile    bool         `toml:"from_file,omitempty" json:"from_file,omitempty"`     // Pass the value to the command as the path of a temporary file holding it
 }
 
 // CommandExample represents an example of how to use a command
@@ -62,19 +115,99 @@ type CommandExample struct {
 	Command     string `toml:"command"`     // Example command invocation
 }
 
+// Hook is a command run before or after a command's main cmd
+type Hook struct {
+	Cmd     string `toml:"cmd"`
+	Capture string `toml:"capture,omitempty"` // Variable receiving the hook's stdout, usable as ${hook:<name>}
+	When    string `toml:"when,omitempty"`    // Condition that must hold for the hook to run
+}
+
+// HookCapturePattern matches ${hook:<name>} references to captured hook output
+var HookCapturePattern = regexp.MustCompile(`\$\{hook:([A-Za-z0-9_-]+)\}`)
+
+// String returns the hook command, noting the capture variable if any
+func (h Hook) String() string {
+	if h.Capture != "" {
+		return fmt.Sprintf("%s (capture: %s)", h.Cmd, h.Capture)
+	}
+	return h.Cmd
+}
+
+// parseHooks parses hook entries given either as plain strings or as
+// { cmd = "...", capture = "..." } tables
+func parseHooks(value interface{}) []Hook {
+	hooks := []Hook{}
+	entries, ok := value.([]interface{})
+	if !ok {
+		return hooks
+	}
+	for _, entry := range entries {
+		switch h := entry.(type) {
+		case string:
+			hooks = append(hooks, Hook{Cmd: h})
+		case map[string]interface{}:
+			hook := Hook{}
+			if cmd, ok := h["cmd"].(string); ok {
+				hook.Cmd = cmd
+			}
+			if capture, ok := h["capture"].(string); ok {
+				hook.Capture = capture
+			}
+			if when, ok := h["when"].(string); ok {
+				hook.When = when
+			}
+			if hook.Cmd != "" {
+				hooks = append(hooks, hook)
+			}
+		}
+	}
+	return hooks
+}
+
 // CommandConfig represents a command that can be executed
 type CommandConfig struct {
-	Description  string            `toml:"description,omitempty"`
-	IsEnabled    bool              `toml:"is_enabled"`
-	Cmd          string            `toml:"cmd"`
-	IsExecutable bool              `toml:"is_executable"`
-	PreExec      []string          `toml:"pre_exec,omitempty"`  // Commands to run before the main command
-	PostExec     []string          `toml:"post_exec,omitempty"` // Commands to run after the main command
-	Arguments    []CommandArgument `toml:"arguments,omitempty"` // Argument definitions for the command
-	MCP          string            `toml:"mcp,omitempty"`       // Optional MCP server name this command belongs to
-	Version      string            `toml:"version,omitempty"`   // Version of the command
-	Examples     []CommandExample  `toml:"examples,omitempty"`  // Usage examples for the command
-	Env          map[string]string `toml:"env,omitempty"`       // Environment variables for the command
+	Description   string            `toml:"description,omitempty"`
+	IsEnabled     bool              `toml:"is_enabled"`
+	Cmd           string            `toml:"cmd"`
+	Script        string            `toml:"script,omitempty"`       // Multi-line script run from a temporary file instead of cmd
+	Interpreter   string            `toml:"interpreter,omitempty"`  // Command line running the script, like "python3", instead of its shebang
+	Dependencies  []string          `toml:"dependencies,omitempty"` // Packages installed for a python or node script
+	IsExecutable  bool              `toml:"is_executable"`
+	PreExec       []Hook            `toml:"pre_exec,omitempty"`        // Commands to run before the main command
+	PostExec      []Hook            `toml:"post_exec,omitempty"`       // Commands to run after the main command
+	Arguments     []CommandArgument `toml:"arguments,omitempty"`       // Argument definitions for the command
+	MCP           ServerList        `toml:"mcp,omitempty"`             // Optional MCP servers serving the command, the default one when empty
+	Version       string            `toml:"version,omitempty"`         // Version of the command
+	Examples      []CommandExample  `toml:"examples,omitempty"`        // Usage examples for the command
+	Env           map[string]string `toml:"env,omitempty"`             // Environment variables for the command
+	EnvFile       string            `toml:"env_file,omitempty"`        // Dotenv file loaded below env, relative to the project path or the settings file
+	Extends       string            `toml:"extends,omitempty"`         // Name of a command to inherit unset fields from
+	When          string            `toml:"when,omitempty"`            // Condition that must hold for the command to run
+	Notify        bool              `toml:"notify,omitempty"`          // Send a desktop notification when the command finishes
+	NotifyAfter   string            `toml:"notify_after,omitempty"`    // Only notify for runs lasting at least this duration, like "30s"
+	NotifyBell    bool              `toml:"notify_bell,omitempty"`     // Also ring the terminal bell when notifying
+	Nice          int               `toml:"nice,omitempty"`            // Scheduling priority adjustment, 1 to 19 lowers it
+	MaxMemory     string            `toml:"max_memory,omitempty"`      // Address space limit, like "2GB"
+	MaxCPUSeconds int               `toml:"max_cpu_seconds,omitempty"` // CPU time limit in seconds
+	Timeout       string            `toml:"timeout,omitempty"`         // Longest a run of the command may take, like "30s" or "5m"
+	Watch         []string          `toml:"watch,omitempty"`           // Globs of the files whose changes re-run the command with run --watch
+	Cache         bool              `toml:"cache,omitempty"`           // Reuse MCP results while the project's git state is unchanged
+	CacheTTL      string            `toml:"cache_ttl,omitempty"`       // How long cached results stay valid, like "30m"
+	Tags          []string          `toml:"tags,omitempty"`            // Labels grouping the command in the TUI
+	EnvPolicy     EnvPolicy         `toml:"env_policy,omitempty"`      // Environment MCP tool calls run the command with, overriding the server's
+	EnvAllowlist  []string          `toml:"env_allowlist,omitempty"`   // Variables the allowlist policy passes on, besides the server's
+	SourceFile    string            `toml:"-"`                         // Settings file the command was loaded from
+	SourceLine    int               `toml:"-"`                         // Line of the command's table in SourceFile, 0 when unknown
+	Shadowed      []string          `toml:"-"`                         // Locations of lower priority definitions this one took precedence over
+
+	// Where MCP tool calls may run a global command with project_path
+	AllowProjectPath *bool    `toml:"allow_project_path,omitempty"` // false removes the project_path parameter
+	AllowedProjects  []string `toml:"allowed_projects,omitempty"`   // Projects project_path must be inside, any when empty
+
+	// Commands run before this one, in dependency order, see DependencyOrder
+	DependsOn []string `toml:"depends_on,omitempty"`
+
+	defined map[string]bool // Keys explicitly set in TOML, used to resolve extends
 }
 
 // NewCommandConfig creates a new CommandConfig with default values
@@ -82,10 +215,10 @@ func NewCommandConfig() CommandConfig {
 	return CommandConfig{
 		IsEnabled:    true,
 		IsExecutable: false,
-		PreExec:      []string{},
-		PostExec:     []string{},
+		PreExec:      []Hook{},
+		PostExec:     []Hook{},
 		Arguments:    []CommandArgument{},
-		MCP:          "",
+		MCP:          nil,
 		Version:      "",
 		Examples:     []CommandExample{},
 		Env:          make(map[string]string),
@@ -99,53 +232,114 @@ func (c *CommandConfig) UnmarshalTOML(data interface{}) error {
 	c.IsEnabled = true
 	c.IsExecutable = false
 	c.Description = ""
-	c.PreExec = []string{}
-	c.PostExec = []string{}
+	c.PreExec = []Hook{}
+	c.PostExec = []Hook{}
 	c.Arguments = []CommandArgument{}
-	c.MCP = ""
+	c.MCP = nil
 	c.Version = ""
 	c.Examples = []CommandExample{}
 	c.Env = make(map[string]string)
+	c.EnvFile = ""
+	c.Extends = ""
+	c.When = ""
+	c.Notify = false
+	c.NotifyAfter = ""
+	c.NotifyBell = false
+	c.Nice = 0
+	c.MaxMemory = ""
+	c.MaxCPUSeconds = 0
+	c.Timeout = ""
+	c.defined = make(map[string]bool)
 
 	// Handle different input cases
 	switch v := data.(type) {
 	case string:
 		// If the command is specified as just a string, use it as cmd
 		c.Cmd = v
+		c.defined["cmd"] = true
 	case map[string]interface{}:
+		for key := range v {
+			c.defined[key] = true
+		}
+		if extends, ok := v["extends"].(string); ok {
+			c.Extends = extends
+		}
+		if envFile, ok := v["env_file"].(string); ok {
+			c.EnvFile = envFile
+		}
+		if when, ok := v["when"].(string); ok {
+			c.When = when
+		}
+		c.Notify = getBoolWithDefault(v, "notify", false)
+		if notifyAfter, ok := v["notify_after"].(string); ok {
+			c.NotifyAfter = notifyAfter
+		}
+		c.NotifyBell = getBoolWithDefault(v, "notify_bell", false)
+		if nice, ok := v["nice"].(int64); ok {
+			c.Nice = int(nice)
+		}
+		if maxMemory, ok := v["max_memory"].(string); ok {
+			c.MaxMemory = maxMemory
+		}
+		if maxCPUSeconds, ok := v["max_cpu_seconds"].(int64); ok {
+			c.MaxCPUSeconds = int(maxCPUSeconds)
+		}
+		if timeout, ok := v["timeout"].(string); ok {
+			c.Timeout = timeout
+		}
+		if watch, ok := v["watch"]; ok {
+			c.Watch = ParseStringSlice(watch)
+		}
+		c.Cache = getBoolWithDefault(v, "cache", false)
+		if cacheTTL, ok := v["cache_ttl"].(string); ok {
+			c.CacheTTL = cacheTTL
+		}
+		if tags, ok := v["tags"]; ok {
+			c.Tags = ParseStringSlice(tags)
+		}
+		if envPolicy, ok := v["env_policy"].(string); ok {
+			c.EnvPolicy = EnvPolicy(envPolicy)
+		}
+		if envAllowlist, ok := v["env_allowlist"]; ok {
+			c.EnvAllowlist = ParseStringSlice(envAllowlist)
+		}
+		if allow, ok := v["allow_project_path"].(bool); ok {
+			c.AllowProjectPath = &allow
+		}
+		if allowedProjects, ok := v["allowed_projects"]; ok {
+			c.AllowedProjects = ParseStringSlice(allowedProjects)
+		}
+		if dependsOn, ok := v["depends_on"]; ok {
+			c.DependsOn = ParseStringSlice(dependsOn)
+		}
 		// If a field is present, use its value
 		if cmd, ok := v["cmd"].(string); ok {
 			c.Cmd = cmd
 		}
+		if script, ok := v["script"].(string); ok {
+			c.Script = script
+		}
+		if interpreter, ok := v["interpreter"].(string); ok {
+			c.Interpreter = interpreter
+		}
+		if dependencies, ok := v["dependencies"]; ok {
+			c.Dependencies = ParseStringSlice(dependencies)
+		}
 		if desc, ok := v["description"].(string); ok {
 			c.Description = desc
 		}
 		c.IsEnabled = getBoolWithDefault(v, "is_enabled", true)
 		c.IsExecutable = getBoolWithDefault(v, "is_executable", false)
-		if mcp, ok := v["mcp"].(string); ok {
-			c.MCP = mcp
+		if mcp, ok := v["mcp"]; ok {
+			c.MCP = ServerList(ParseStringSlice(mcp))
 		}
 		if version, ok := v["version"].(string); ok {
 			c.Version = version
 		}
 
-		// Parse pre_exec commands if present
-		if preExec, ok := v["pre_exec"].([]interface{}); ok {
-			for _, cmd := range preExec {
-				if cmdStr, ok := cmd.(string); ok {
-					c.PreExec = append(c.PreExec, cmdStr)
-				}
-			}
-		}
-
-		// Parse post_exec commands if present
-		if postExec, ok := v["post_exec"].([]interface{}); ok {
-			for _, cmd := range postExec {
-				if cmdStr, ok := cmd.(string); ok {
-					c.PostExec = append(c.PostExec, cmdStr)
-				}
-			}
-		}
+		// Parse pre_exec and post_exec hooks if present
+		c.PreExec = parseHooks(v["pre_exec"])
+		c.PostExec = parseHooks(v["post_exec"])
 
 		// Parse arguments if present
 		if args, ok := v["arguments"].([]interface{}); ok {
@@ -183,6 +377,7 @@ func (c *CommandConfig) UnmarshalTOML(data interface{}) error {
 					if prefix, ok := argMap["prefix"].(string); ok {
 						argument.Prefix = prefix
 					}
+					argument.FromFile = getBoolWithDefault(argMap, "from_file", false)
 
 					c.Arguments = append(c.Arguments, argument)
 				}
@@ -222,39 +417,63 @@ func (c *CommandConfig) UnmarshalTOML(data interface{}) error {
 	return nil
 }
 
-// GetArgumentValue retrieves the value for an argument from the provided arguments map
-// It will use the default value if the argument is not provided and has a default
-// Returns an error if a required argument is missing
-func (c *CommandConfig) GetArgumentValue(argName string, providedArgs map[string]interface{}) (interface{}, error) {
-	// Look for the argument definition
-	var argDef *CommandArgument
+// ArgumentValues returns the values of the command's arguments as they are
+// passed to it: provided values checked against their argument's type, and
+// defaults for the ones not provided. Arguments without either are left out.
+// It returns an error if a required argument is missing or a value doesn't
+// fit its type. Values of undefined arguments are ignored.
+func (c *CommandConfig) ArgumentValues(provided map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(c.Arguments))
 	for _, arg := range c.Arguments {
-		if arg.Name == argName {
-			argDef = &arg
-			break
+		value, exists := provided[arg.Name]
+		if !exists || value == nil {
+			if arg.Default == nil {
+				if arg.Required {
+					return nil, fmt.Errorf("required argument '%s' is missing", arg.Name)
+				}
+				continue
+			}
+			value = arg.Default
 		}
-	}
-
-	// If no definition found, just return the provided value or nil
-	if argDef == nil {
-		if value, exists := providedArgs[argName]; exists {
-			return value, nil
+		formatted, err := arg.FormatValue(value)
+		if err != nil {
+			return nil, err
 		}
-		return nil, nil
-	}
-
-	// Check if the argument is provided
-	if value, exists := providedArgs[argName]; exists {
-		return value, nil
+		values[arg.Name] = formatted
 	}
+	return values, nil
+}
 
-	// If not provided, check if it's required
-	if argDef.Required {
-		return nil, fmt.Errorf("required argument '%s' is missing", argName)
+// FormatValue checks that value fits the argument's type and returns it as
+// the text the command receives. Numbers and bools given as text are parsed,
+// and bools are passed as true or false.
+func (a CommandArgument) FormatValue(value interface{}) (string, error) {
+	switch a.Type {
+	case ArgumentTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int, int64:
+			return fmt.Sprint(v), nil
+		case string:
+			if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return strings.TrimSpace(v), nil
+			}
+		}
+		return "", fmt.Errorf("argument '%s' must be a number, got '%v'", a.Name, value)
+	case ArgumentTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			if parsed, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return strconv.FormatBool(parsed), nil
+			}
+		}
+		return "", fmt.Errorf("argument '%s' must be true or false, got '%v'", a.Name, value)
+	default:
+		return fmt.Sprint(value), nil
 	}
-
-	// If not required, return the default value
-	return argDef.Default, nil
 }
 
 // ValidateArgs checks if all required arguments are provided and all provided arguments are defined
@@ -298,31 +517,539 @@ func getBoolWithDefault(m map[string]interface{}, key string, defaultValue bool)
 	return defaultValue
 }
 
+// NotifyThreshold returns how long a run must last to send a notification.
+// enabled is false when the command doesn't notify; notify_after alone enables
+// notifications.
+func (c CommandConfig) NotifyThreshold() (threshold time.Duration, enabled bool, err error) {
+	if c.NotifyAfter == "" {
+		return 0, c.Notify, nil
+	}
+	threshold, err = time.ParseDuration(c.NotifyAfter)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid notify_after '%s': %w", c.NotifyAfter, err)
+	}
+	if threshold < 0 {
+		return 0, false, fmt.Errorf("invalid notify_after '%s': duration can't be negative", c.NotifyAfter)
+	}
+	return threshold, true, nil
+}
+
+// RunTimeout returns the longest a run of the command may take, 0 when it has
+// no timeout
+func (c CommandConfig) RunTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout '%s': use a duration like 30s or 5m", c.Timeout)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout '%s': duration must be positive", c.Timeout)
+	}
+	return timeout, nil
+}
+
+// DefaultCacheTTL is how long cached results stay valid without cache_ttl
+const DefaultCacheTTL = 10 * time.Minute
+
+// CacheDuration returns how long the command's results can be reused.
+// enabled is false when results aren't cached; cache_ttl alone enables caching.
+func (c CommandConfig) CacheDuration() (ttl time.Duration, enabled bool, err error) {
+	if c.CacheTTL == "" {
+		return DefaultCacheTTL, c.Cache, nil
+	}
+	ttl, err = time.ParseDuration(c.CacheTTL)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid cache_ttl '%s': %w", c.CacheTTL, err)
+	}
+	if ttl <= 0 {
+		return 0, false, fmt.Errorf("invalid cache_ttl '%s': duration must be positive", c.CacheTTL)
+	}
+	return ttl, true, nil
+}
+
+// ValidateLimits checks the nice, max_memory and max_cpu_seconds options
+func (c CommandConfig) ValidateLimits() error {
+	if c.Nice < -20 || c.Nice > 19 {
+		return fmt.Errorf("invalid nice %d: must be between -20 and 19", c.Nice)
+	}
+	if _, err := ParseMemorySize(c.MaxMemory); err != nil {
+		return fmt.Errorf("invalid max_memory '%s': %w", c.MaxMemory, err)
+	}
+	if c.MaxCPUSeconds < 0 {
+		return fmt.Errorf("invalid max_cpu_seconds %d: can't be negative", c.MaxCPUSeconds)
+	}
+	return nil
+}
+
+// memoryUnits are the multipliers of the max_memory suffixes
+var memoryUnits = map[string]uint64{
+	"": 1, "B": 1,
+	"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	"T": 1 << 40, "TB": 1 << 40, "TIB": 1 << 40,
+}
+
+// ParseMemorySize parses a size such as "512MB" or "1.5G" into bytes. Units
+// are binary, so 1KB is 1024 bytes. An empty value is 0, meaning no limit.
+func ParseMemorySize(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	split := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := value, ""
+	if split >= 0 {
+		number, unit = value[:split], strings.TrimSpace(value[split:])
+	}
+
+	multiplier, ok := memoryUnits[strings.ToUpper(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit '%s'", unit)
+	}
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("expected a positive size like 512MB or 2GB")
+	}
+	return uint64(size * float64(multiplier)), nil
+}
+
+// inherits reports whether a field should be taken from the extended command.
+// Fields decoded from TOML inherit when absent; for commands built in code a
+// zero value counts as unset.
+func (c *CommandConfig) inherits(key string, isZero bool) bool {
+	if c.defined != nil {
+		return !c.defined[key]
+	}
+	return isZero
+}
+
+// inheritFrom returns the command with unset fields filled in from base.
+// Arguments are merged by name and env is merged with the command's own values winning.
+func (c CommandConfig) inheritFrom(base CommandConfig) CommandConfig {
+	if c.inherits("description", c.Description == "") {
+		c.Description = base.Description
+	}
+	if c.inherits("is_enabled", !c.IsEnabled) {
+		c.IsEnabled = base.IsEnabled
+	}
+	// cmd and script are alternatives, a command defining either inherits neither
+	if c.inherits("cmd", c.Cmd == "") && c.inherits("script", c.Script == "") {
+		c.Cmd = base.Cmd
+		c.Script = base.Script
+	}
+	if c.inherits("interpreter", c.Interpreter == "") {
+		c.Interpreter = base.Interpreter
+	}
+	if c.inherits("dependencies", len(c.Dependencies) == 0) {
+		c.Dependencies = base.Dependencies
+	}
+	if c.inherits("is_executable", !c.IsExecutable) {
+		c.IsExecutable = base.IsExecutable
+	}
+	if c.inherits("pre_exec", len(c.PreExec) == 0) {
+		c.PreExec = base.PreExec
+	}
+	if c.inherits("post_exec", len(c.PostExec) == 0) {
+		c.PostExec = base.PostExec
+	}
+	if c.inherits("mcp", len(c.MCP) == 0) {
+		c.MCP = base.MCP
+	}
+	if c.inherits("version", c.Version == "") {
+		c.Version = base.Version
+	}
+	if c.inherits("examples", len(c.Examples) == 0) {
+		c.Examples = base.Examples
+	}
+	if c.inherits("env_file", c.EnvFile == "") {
+		c.EnvFile = base.EnvFile
+	}
+	if c.inherits("when", c.When == "") {
+		c.When = base.When
+	}
+	if c.inherits("notify", !c.Notify) {
+		c.Notify = base.Notify
+	}
+	if c.inherits("notify_after", c.NotifyAfter == "") {
+		c.NotifyAfter = base.NotifyAfter
+	}
+	if c.inherits("notify_bell", !c.NotifyBell) {
+		c.NotifyBell = base.NotifyBell
+	}
+	if c.inherits("nice", c.Nice == 0) {
+		c.Nice = base.Nice
+	}
+	if c.inherits("max_memory", c.MaxMemory == "") {
+		c.MaxMemory = base.MaxMemory
+	}
+	if c.inherits("max_cpu_seconds", c.MaxCPUSeconds == 0) {
+		c.MaxCPUSeconds = base.MaxCPUSeconds
+	}
+	if c.inherits("timeout", c.Timeout == "") {
+		c.Timeout = base.Timeout
+	}
+	if c.inherits("watch", len(c.Watch) == 0) {
+		c.Watch = base.Watch
+	}
+	if c.inherits("cache", !c.Cache) {
+		c.Cache = base.Cache
+	}
+	if c.inherits("cache_ttl", c.CacheTTL == "") {
+		c.CacheTTL = base.CacheTTL
+	}
+	if c.inherits("tags", len(c.Tags) == 0) {
+		c.Tags = base.Tags
+	}
+	if c.inherits("env_policy", c.EnvPolicy == "") {
+		c.EnvPolicy = base.EnvPolicy
+	}
+	if c.inherits("env_allowlist", len(c.EnvAllowlist) == 0) {
+		c.EnvAllowlist = base.EnvAllowlist
+	}
+	if c.inherits("allow_project_path", c.AllowProjectPath == nil) {
+		c.AllowProjectPath = base.AllowProjectPath
+	}
+	if c.inherits("allowed_projects", len(c.AllowedProjects) == 0) {
+		c.AllowedProjects = base.AllowedProjects
+	}
+	if c.inherits("depends_on", len(c.DependsOn) == 0) {
+		c.DependsOn = base.DependsOn
+	}
+
+	overridden := make(map[string]CommandArgument, len(c.Arguments))
+	for _, arg := range c.Arguments {
+		overridden[arg.Name] = arg
+	}
+	arguments := make([]CommandArgument, 0, len(base.Arguments)+len(c.Arguments))
+	for _, arg := range base.Arguments {
+		if override, ok := overridden[arg.Name]; ok {
+			arguments = append(arguments, override)
+			delete(overridden, arg.Name)
+			continue
+		}
+		arguments = append(arguments, arg)
+	}
+	for _, arg := range c.Arguments {
+		if _, ok := overridden[arg.Name]; ok {
+			arguments = append(arguments, arg)
+		}
+	}
+	c.Arguments = arguments
+
+	env := make(map[string]string, len(base.Env)+len(c.Env))
+	for key, value := range base.Env {
+		env[key] = value
+	}
+	for key, value := range c.Env {
+		env[key] = value
+	}
+	c.Env = env
+
+	return c
+}
+
 // PromptConfig represents a configured prompt that can be exposed via MCP
 type PromptConfig struct {
-	Name        string            `toml:"name"`                // Name of the prompt
-	Description string            `toml:"description"`         // Description of what the prompt does
-	Content     string            `toml:"content"`             // The actual prompt content/template
-	MCP         string            `toml:"mcp,omitempty"`       // Optional MCP server name this prompt belongs to
-	Arguments   []CommandArgument `toml:"arguments,omitempty"` // Argument definitions for the prompt
+	Name        string            `toml:"name" json:"name"`                                           // Name of the prompt
+	Description string            `toml:"description" json:"description"`                             // Description of what the prompt does
+	Content     string            `toml:"content" json:"content"`                                     // The actual prompt content/template
+	MCP         ServerList        `toml:"mcp,omitempty" json:"mcp,omitempty"`                         // Optional MCP servers serving the prompt, the default one when empty
+	Arguments   []CommandArgument `toml:"arguments,omitempty" json:"arguments,omitempty"`             // Argument definitions for the prompt
+	Suggested   []string          `toml:"suggested_tools,omitempty" json:"suggested_tools,omitempty"` // Tools the prompt's workflow uses
+	Includes    []string          `toml:"includes,omitempty" json:"includes,omitempty"`               // Prompts rendered before this one
+	SourceFile  string            `toml:"-" json:"-"`                                                 // Settings file the prompt was loaded from
+	SourceLine  int               `toml:"-" json:"-"`                                                 // Line of the prompt's table in SourceFile, 0 when unknown
+}
+
+// PromptPlaceholderPattern matches the {name} placeholders of prompt content.
+// Braces around anything but a name, like JSON or code, are not placeholders.
+var PromptPlaceholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_-]*)\}`)
+
+// Placeholders returns the names of the {name} placeholders in the prompt
+// content, in the order they first appear
+func (p PromptConfig) Placeholders() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range PromptPlaceholderPattern.FindAllStringSubmatch(p.Content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render returns the prompt content with its {name} placeholders replaced by
+// the given argument values. Values are converted to the argument's declared
+// type and missing arguments fall back to their defaults.
+func (p PromptConfig) Render(args map[string]string) (string, error) {
+	content := p.Content
+	for _, argDef := range p.Arguments {
+		var value interface{}
+		if argValue, exists := args[argDef.Name]; exists {
+			// Arguments come as strings, convert based on the expected type
+			switch argDef.Type {
+			case ArgumentTypeNumber:
+				if numVal, err := strconv.ParseFloat(argValue, 64); err == nil {
+					value = numVal
+				} else {
+					value = argValue
+				}
+			case ArgumentTypeBool:
+				if boolVal, err := strconv.ParseBool(argValue); err == nil {
+					value = boolVal
+				} else {
+					value = argValue
+				}
+			default:
+				value = argValue
+			}
+		}
+
+		if value == nil {
+			if argDef.Required && argDef.Default == nil {
+				return "", fmt.Errorf("required argument '%s' is missing", argDef.Name)
+			}
+			value = argDef.Default
+		}
+
+		if value != nil {
+			content = strings.ReplaceAll(content, "{"+argDef.Name+"}", fmt.Sprintf("%v", value))
+		}
+	}
+	return content, nil
+}
+
+// PromptChain returns the prompts rendered for the prompt called name: the
+// prompts it includes, recursively and in order, followed by the prompt
+// itself. A prompt included more than once is rendered the first time only.
+func PromptChain(prompts map[string]PromptConfig, name string) ([]PromptConfig, error) {
+	var chain []PromptConfig
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if visiting[name] {
+			return fmt.Errorf("prompt includes form a cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		if done[name] {
+			return nil
+		}
+		prompt, exists := prompts[name]
+		if !exists {
+			if len(path) == 0 {
+				return fmt.Errorf("prompt '%s' not found", name)
+			}
+			return fmt.Errorf("prompt '%s' includes non-existent prompt '%s'", path[len(path)-1], name)
+		}
+
+		visiting[name] = true
+		for _, include := range prompt.Includes {
+			if err := visit(include, append(path, name)); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		chain = append(chain, prompt)
+		return nil
+	}
+
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// RenderPromptChain renders the prompt called name and the prompts it
+// includes with the same arguments, returning one text per prompt in the
+// order of PromptChain
+func RenderPromptChain(prompts map[string]PromptConfig, name string, args map[string]string) ([]string, error) {
+	chain, err := PromptChain(prompts, name)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, 0, len(chain))
+	for _, prompt := range chain {
+		text, err := prompt.Render(args)
+		if err != nil {
+			if prompt.Name != name {
+				return nil, fmt.Errorf("included prompt '%s': %w", prompt.Name, err)
+			}
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+	return texts, nil
+}
+
+// PromptChainArguments returns the arguments of the prompt called name
+// followed by those of the prompts it includes, each name once with the
+// first definition winning
+func PromptChainArguments(prompts map[string]PromptConfig, name string) ([]CommandArgument, error) {
+	chain, err := PromptChain(prompts, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []CommandArgument
+	seen := make(map[string]bool)
+	// The prompt itself ends the chain, its own definitions come first
+	ordered := append([]PromptConfig{chain[len(chain)-1]}, chain[:len(chain)-1]...)
+	for _, prompt := range ordered {
+		for _, arg := range prompt.Arguments {
+			if !seen[arg.Name] {
+				seen[arg.Name] = true
+				args = append(args, arg)
+			}
+		}
+	}
+	return args, nil
 }
 
 type Settings struct {
-	LogLevel              string                   `toml:"log_level"`
-	Env                   map[string]string        `toml:"env,omitempty"`
-	Projects              map[string]Project       `toml:"projects"`
-	Commands              map[string]CommandConfig `toml:"commands"`
-	Prompts               map[string]PromptConfig  `toml:"prompts"` // Add prompts configuration
-	ExecutableSearchPaths []string                 `toml:"executable_search_paths"`
-	CommandDirs           []string                 `toml:"command_dirs"` // Directories to load additional command files from
-	MCPPort               int                      `toml:"mcp_port"`
-	MCPServers            map[string]MCPServer     `toml:"mcp_servers"`
-	IsToolOutputJson      bool                     `toml:"is_tool_output_json,omitempty"` // Whether default MCP server outputs JSON format
+	LogLevel                string                     `toml:"log_level"`
+	Env                     map[string]string          `toml:"env,omitempty"`
+	EnvFile                 string                     `toml:"env_file,omitempty"` // Dotenv file loaded below env, relative to the config directory
+	Projects                map[string]Project         `toml:"projects"`
+	ProjectTemplates        map[string]ProjectTemplate `toml:"project_templates,omitempty"` // Shared defaults referenced by a project's extends
+	Commands                map[string]CommandConfig   `toml:"commands"`
+	Prompts                 map[string]PromptConfig    `toml:"prompts"` // Add prompts configuration
+	ExecutableSearchPaths   []string                   `toml:"executable_search_paths"`
+	CommandDirs             []CommandDir               `toml:"command_dirs"`                    // Directories to load additional command files from
+	AllowedProjectRoots     []string                   `toml:"allowed_project_roots,omitempty"` // Directories projects may live under (default: $HOME)
+	MCPPort                 int                        `toml:"mcp_port"`
+	MCPServers              map[string]MCPServer       `toml:"mcp_servers"`
+	IsToolOutputJson        bool                       `toml:"is_tool_output_json,omitempty"`       // Whether default MCP server outputs JSON format
+	RestartOnConfigChange   bool                       `toml:"restart_on_config_change,omitempty"`  // Let `mcp supervise` restart the default MCP server on config changes
+	QuietHours              string                     `toml:"quiet_hours,omitempty"`               // HH:MM-HH:MM window in which the default MCP server is not restarted
+	BatchTool               bool                       `toml:"batch_tool,omitempty"`                // Register the run-batch tool on the default MCP server
+	RestrictProjectPath     bool                       `toml:"restrict_project_path,omitempty"`     // Only accept project_path values inside configured projects on the default MCP server
+	EnvPolicy               EnvPolicy                  `toml:"env_policy,omitempty"`                // Environment tool calls of the default MCP server run commands with
+	EnvAllowlist            []string                   `toml:"env_allowlist,omitempty"`             // Variables the allowlist policy of the default MCP server passes on
+	GitBackend              string                     `toml:"git_backend,omitempty"`               // How remotes are cloned: auto, system, or native
+	MaxConcurrentExecutions int                        `toml:"max_concurrent_executions,omitempty"` // Detached jobs and MCP tool calls allowed to run at once, 0 for no limit
+	Tracing                 TracingConfig              `toml:"tracing,omitempty"`                   // OpenTelemetry trace export
+	Artifacts               ArtifactsConfig            `toml:"artifacts,omitempty"`                 // Retention of files commands write to INTEROP_ARTIFACTS_DIR
+	History                 HistoryConfig              `toml:"history,omitempty"`                   // Record of `interop run` invocations, see `interop history`
+	Events                  EventsConfig               `toml:"events,omitempty"`                    // Defaults for streaming server events with `mcp events`
+	MCPRetention            RetentionConfig            `toml:"mcp_retention,omitempty"`             // Age and size limits of MCP logs and tool run artifacts
+	// MaxToolTimeout is the longest timeout an MCP tool call can ask for (default: 30m)
+	MaxToolTimeout string `toml:"max_tool_timeout,omitempty"`
+
+	// DefaultMCPEnabled false leaves out the default MCP server, so only the
+	// servers of mcp_servers and projects exist, see DefaultMCPServerEnabled
+	DefaultMCPEnabled *bool `toml:"default_mcp_enabled,omitempty"`
+}
+
+// DefaultMCPPort is the port of the default MCP server when mcp_port isn't set
+const DefaultMCPPort = 8081
+
+// DefaultMCPServerEnabled reports whether the default MCP server exists, true
+// unless default_mcp_enabled = false
+func (s *Settings) DefaultMCPServerEnabled() bool {
+	return s.DefaultMCPEnabled == nil || *s.DefaultMCPEnabled
+}
+
+// Timeouts of MCP tool calls: the one applied when a call doesn't give its
+// own, and the limit of the ones calls can give without max_tool_timeout
+const (
+	DefaultToolTimeout    = 5 * time.Minute
+	DefaultMaxToolTimeout = 30 * time.Minute
+)
+
+// ToolTimeoutLimit returns the longest timeout an MCP tool call can ask for
+func (s *Settings) ToolTimeoutLimit() (time.Duration, error) {
+	if s.MaxToolTimeout == "" {
+		return DefaultMaxToolTimeout, nil
+	}
+	limit, err := time.ParseDuration(s.MaxToolTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_tool_timeout '%s': %w", s.MaxToolTimeout, err)
+	}
+	if limit <= 0 {
+		return 0, fmt.Errorf("invalid max_tool_timeout '%s': duration must be positive", s.MaxToolTimeout)
+	}
+	return limit, nil
+}
+
+// ArtifactsConfig controls how many runs' artifacts are retained
+type ArtifactsConfig struct {
+	Keep int `toml:"keep,omitempty"` // Runs with artifacts to keep (default: 20)
+}
+
+// HistoryConfig controls the record of `interop run` invocations
+type HistoryConfig struct {
+	Disabled      bool `toml:"disabled,omitempty"`       // Don't record runs
+	Keep          int  `toml:"keep,omitempty"`           // Runs to keep (default: 200)
+	CaptureOutput bool `toml:"capture_output,omitempty"` // Keep a copy of each run's output
+}
+
+// Defaults of the MCP retention policy, applied when mcp_retention leaves a
+// limit unset
+const (
+	DefaultRetentionMaxAge  = 30 * 24 * time.Hour
+	DefaultRetentionMaxSize = 512 << 20
+)
+
+// RetentionConfig limits the disk space used by MCP server logs and the
+// artifacts of tool runs, see `interop mcp clean`
+type RetentionConfig struct {
+	MaxAge  string `toml:"max_age,omitempty"`  // Remove artifacts and clear logs untouched for this long, e.g. "168h" (default: 720h)
+	MaxSize string `toml:"max_size,omitempty"` // Total size logs and artifacts are trimmed to, e.g. "200MB" (default: 512MB)
+}
+
+// Limits returns the maximum age and total size in bytes, with the defaults
+// filled in
+func (c RetentionConfig) Limits() (maxAge time.Duration, maxSize uint64, err error) {
+	maxAge = DefaultRetentionMaxAge
+	if c.MaxAge != "" {
+		if maxAge, err = time.ParseDuration(c.MaxAge); err != nil {
+			return 0, 0, fmt.Errorf("invalid mcp_retention max_age '%s': %w", c.MaxAge, err)
+		}
+		if maxAge <= 0 {
+			return 0, 0, fmt.Errorf("invalid mcp_retention max_age '%s': duration must be positive", c.MaxAge)
+		}
+	}
+
+	maxSize = DefaultRetentionMaxSize
+	if c.MaxSize != "" {
+		if maxSize, err = ParseMemorySize(c.MaxSize); err != nil {
+			return 0, 0, fmt.Errorf("invalid mcp_retention max_size '%s': %w", c.MaxSize, err)
+		}
+	}
+	return maxAge, maxSize, nil
+}
+
+// EventsConfig sets the defaults of `mcp events`, each overridable by a flag
+type EventsConfig struct {
+	Endpoint       string `toml:"endpoint,omitempty"`        // SSE path, e.g. /events (default: try /mcp, /events and /sse)
+	Retries        int    `toml:"retries,omitempty"`         // Failed connection attempts in a row before giving up (default: 5, -1 for no limit)
+	Backoff        string `toml:"backoff,omitempty"`         // Delay before the first retry, doubled after each failure (default: 1s)
+	MaxBackoff     string `toml:"max_backoff,omitempty"`     // Longest delay between retries (default: 30s)
+	QuietHeartbeat bool   `toml:"quiet_heartbeat,omitempty"` // Don't print heartbeat events
+}
+
+// TracingConfig configures exporting execution traces over OTLP/HTTP.
+// Tracing is disabled unless an endpoint is set here or through the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables.
+type TracingConfig struct {
+	Endpoint    string            `toml:"endpoint,omitempty"`     // Collector URL, e.g. http://localhost:4318
+	ServiceName string            `toml:"service_name,omitempty"` // Reported service name (default: interop)
+	Headers     map[string]string `toml:"headers,omitempty"`      // Extra HTTP headers sent to the collector
 }
 
 // PathConfig defines the directory structure for settings
 type PathConfig struct {
-	SettingsDir    string
+	SettingsDir    string // Legacy config root relative to $HOME, migrated into the platform config dir
 	AppDir         string
 	CfgFile        string
 	ExecutablesDir string
@@ -357,224 +1084,125 @@ func SetPathConfig(config PathConfig) {
 	err = nil
 }
 
-// defaultSettingsTemplate is the embedded template for the settings file.
-// This avoids issues with missing template files and makes the binary self-contained.
-var defaultSettingsTemplate = `# Interop Settings Template
-# This file documents all available configuration options for Interop.
-# Uncomment and edit the fields you wish to configure.
-
-# =====================
-# GLOBAL SETTINGS
-# =====================
-
-# log_level = "warning"         # Options: error, warning, verbose
-# executable_search_paths = [   # Additional directories to search for executables
-#   "~/.local/bin",
-#   "~/bin"
-# ]
-# command_dirs = [              # Directories to load additional configuration definitions from
-#   "~/.config/interop/config.d"  # Default: if not specified, this directory is automatically used
-#   "~/projects/shared/interop-configs"
-# ]
-# mcp_port = 8081               # Default port for the main MCP server
-# is_tool_output_json = false   # Whether default MCP server outputs JSON format (default: false)
-
-# =====================
-# MCP SERVER CONFIGURATION
-# =====================
-
-#[mcp_servers.example]
-#name = "example"               # Unique name for this MCP server (must match the key)
-#description = "Example domain-specific server"
-#port = 8082                    # Port for this MCP server
-
-# =====================
-# MCP PROMPTS
-# =====================
-# Define reusable prompts that MCP clients can access. Prompts are templates
-# that help LLMs interact with your server effectively.
-#
-# Each prompt can be assigned to a specific MCP server using the 'mcp' field.
-# If no 'mcp' field is specified, the prompt will be available on the default server.
-#
-# Prompts can also define arguments that allow customization when the prompt is used.
-
-#[prompts.create_merge_request]
-#name = "create_merge_request"
-#description = "Complete MR creation workflow: analyzes branch changes, generates MR description, and creates the merge request"
-#content = """
-#You are helping create a merge request. Follow this workflow:
-#
-#1. **Analyze Branch Changes**: First, run the generate-cursor-prompt-for-mr command with target branch: {target_branch}
-#2. **Review the Analysis**: Read the generated analysis and create an appropriate MR title: {mr_title}
-#3. **Generate MR Description**: Based on the analysis, create a detailed MR description
-#4. **Create the MR**: Run the create-mr command with the temp directory from step 1
-#
-#Include detailed changes: {include_detailed_changes}
-#
-#Make sure to:
-#- Use clear, descriptive titles
-#- Include context about what changed and why
-#- Reference any related issues or tickets
-#- Follow the team's MR guidelines
-#"""
-#arguments = [
-#  { name = "target_branch", type = "string", description = "The branch you want to merge into", required = true },
-#  { name = "mr_title", type = "string", description = "Title for the merge request", default = "" },
-#  { name = "include_detailed_changes", type = "bool", description = "Include detailed file changes in description", default = true }
-#]
-# This prompt orchestrates multiple MCP commands in a workflow
-
-#[prompts.code_review]
-#name = "code_review"           # Name of the prompt (must match the key)
-#description = "Code review assistance prompt"
-#content = "Please review the following {language} code, focusing on {focus_area}. Look for potential issues, improvements, and best practices."
-#mcp = "example"                # (Optional) Assign this prompt to a specific MCP server
-#arguments = [                  # (Optional) Arguments for prompt customization
-#  { name = "language", type = "string", description = "Programming language", required = true },
-#  { name = "focus_area", type = "string", description = "Area to focus on", default = "general" }
-#]
-
-#[prompts.documentation]
-#name = "documentation"         # Name of the prompt (must match the key)  
-#description = "Generate technical documentation"
-#content = """
-#Generate comprehensive technical documentation for {topic}.
-#
-#Include examples: {include_examples}
-#Detail level: {detail_level}/5
-#
-#Structure the documentation with:
-#1. Overview and purpose
-#2. Key concepts and terminology  
-#3. Implementation details
-#4. Usage examples (if requested)
-#5. Best practices and recommendations
-#"""
-#arguments = [                  # Example with different argument types
-#  { name = "topic", type = "string", description = "Documentation topic", required = true },
-#  { name = "include_examples", type = "bool", description = "Include code examples", default = true },
-#  { name = "detail_level", type = "number", description = "Detail level (1-5)", default = 3 }
-#]
-# No 'mcp' field means this prompt is available on the default server
-
-# =====================
-# MCP TOOLS & GLOBAL COMMANDS
-# =====================
-# Global commands automatically receive an optional "project_path" parameter when exposed as MCP tools.
-# This allows AI assistants to specify a working directory for the command.
-#
-# A command is considered global unless it's bound to a project WITHOUT an alias.
-# Commands with aliases remain global - only the alias becomes project-specific.
-#
-# Examples:
-# - Command "build" with alias "b" in a project: "build" stays global, "b" is project-specific
-# - Command "test" without alias in a project: "test" becomes project-specific
-# - Command "deploy" not in any project: "deploy" is global
-#
-# Global commands can be run in any project directory by providing the project_path parameter.
-
-# =====================
-# PROJECT DEFINITIONS
-# =====================
-
-#[projects.sample_project]
-#path = "~/projects/sample"     # Path to the project directory (must be inside $HOME)
-#description = "Sample project for demonstration"
-#commands = [                   # List of commands for this project (with optional aliases)
-#  { command_name = "build", alias = "b" },
-#  { command_name = "test" }
-#]
-
-# =====================
-# COMMAND DEFINITIONS
-# =====================
-# Commands can be defined in the main settings.toml file or in separate files
-# in directories specified by command_dirs. Configuration files in these directories
-# can contain [commands], [projects], [prompts], and other configuration sections.
-# Commands from main settings.toml take precedence over those in external directories.
-
-#[commands.build]
-#cmd = "go build ./..."         # The shell command or executable to run
-#description = "Build the project"
-#version = "1.0.0"              # (Optional) Version of the command
-#is_enabled = true              # Enable or disable this command
-#is_executable = false          # If true, run as an executable; if false, run in shell
-#mcp = "example"                # (Optional) Assign this command to a specific MCP server
-#arguments = [                  # (Optional) List of arguments for this command
-#  { name = "output_file", type = "string", description = "Output file name", required = true },
-#  { name = "package", type = "string", description = "Package to build", default = "./cmd/app" }
-#]
-#examples = [                   # (Optional) Usage examples for the command
-#  {
-#    description = "Build the main application",
-#    command = "interop run build output_file=my-app"
-#  },
-#  {
-#    description = "Build a specific package",
-#    command = "interop run build output_file=my-tool package=./cmd/tool"
-#  }
-#]
-
-#[commands.test]
-#cmd = "go test ./..."
-#description = "Run tests"
-#is_enabled = true
-#is_executable = false
-
-#[commands.deploy]
-#cmd = "deploy.sh"
-#description = "Deploy the project"
-#is_enabled = true
-#is_executable = true
-#mcp = "example"
-
-# Example command with prefixed arguments
-#[commands.script]
-#cmd = "python scripts/myscript.py"
-#description = "Run a Python script with prefixed arguments"
-#arguments = [
-#  { name = "keys", type = "string", description = "Keys to process", required = false, prefix = "--keys" },
-#  { name = "language", type = "string", description = "Language code", required = false, prefix = "--language" }
-#]
-
-# =====================
-# COMMAND ARGUMENT TYPES
-# =====================
-# type: string | number | bool
-# Example:
-# arguments = [
-#   { name = "type", type = "string", description = "Component type", required = true },
-#   { name = "force", type = "bool", description = "Overwrite if exists", default = false }
-# ]
-
-# =====================
-# PREFIX ARGUMENTS
-# =====================
-# Use the 'prefix' field to specify command-line prefixes for arguments.
-# For example:
-# arguments = [
-#   { name = "verbose", type = "bool", description = "Enable verbose output", prefix = "--verbose" },
-#   { name = "keys", type = "string", description = "Keys to process", prefix = "--keys" }
-# ]
-# This will generate commands like: my-command --verbose --keys value
-
-# =====================
-# END OF TEMPLATE
-# =====================
-`
-
-// validate() guarantees ~/.settings/interop/settings.toml exists and
+// Reload discards the cached settings and loads them again, for long running
+// processes that need to pick up configuration changes
+func Reload() (*Settings, error) {
+	once = sync.Once{}
+	cfg = nil
+	err = nil
+	return Load()
+}
+
+// ConfigDirEnvVar selects the configuration directory like --config-dir and
+// passes it on to child processes such as hooks and MCP servers
+const ConfigDirEnvVar = "INTEROP_CONFIG_DIR"
+
+// SetConfigDir uses dir as the configuration directory instead of the platform
+// default. Unlike the sandbox, files are created and state is kept as usual.
+func SetConfigDir(dir string) error {
+	absDir, e := filepath.Abs(dir)
+	if e != nil {
+		return fmt.Errorf("invalid config directory: %w", e)
+	}
+
+	pathutil.SetAppDirOverride(absDir)
+	os.Setenv(ConfigDirEnvVar, absDir)
+
+	// Reset singleton so settings are loaded from the new directory
+	once = sync.Once{}
+	cfg = nil
+	err = nil
+	return nil
+}
+
+// ConfigDir returns the configuration directory selected with SetConfigDir,
+// empty when the platform default is used
+func ConfigDir() string {
+	return os.Getenv(ConfigDirEnvVar)
+}
+
+// SandboxEnvVar passes the sandbox directory on to child processes such as
+// hooks and MCP servers
+const SandboxEnvVar = "INTEROP_SANDBOX_CONFIG"
+
+// sandboxDir is the only configuration source when set
+var sandboxDir string
+
+// EnableSandbox confines interop to dir for hermetic tests. The directory is
+// used as the config directory and as $HOME, nothing is created inside it, and
+// child processes inherit the sandbox through INTEROP_SANDBOX_CONFIG.
+func EnableSandbox(dir string) error {
+	absDir, e := filepath.Abs(dir)
+	if e != nil {
+		return fmt.Errorf("invalid sandbox directory: %w", e)
+	}
+	if info, e := os.Stat(absDir); e != nil || !info.IsDir() {
+		return fmt.Errorf("sandbox directory does not exist: %s", absDir)
+	}
+
+	sandboxDir = absDir
+	pathutil.SetAppDirOverride(absDir)
+	pathutil.SetHomeDirFunc(func() (string, error) { return absDir, nil })
+	os.Setenv("HOME", absDir)
+	os.Setenv(SandboxEnvVar, absDir)
+
+	// Reset singleton so settings are loaded from the sandbox
+	once = sync.Once{}
+	cfg = nil
+	err = nil
+	return nil
+}
+
+// Sandboxed reports whether interop is confined to a sandbox directory
+func Sandboxed() bool {
+	return sandboxDir != ""
+}
+
+// StateDir returns the directory named name inside the app directory, where
+// state such as history, jobs and cached results is kept. Sandboxed runs share
+// a temporary directory per sandbox instead, so the fixture config stays
+// untouched while the state still carries over between invocations.
+func StateDir(name string) (string, error) {
+	if Sandboxed() {
+		sum := sha256.Sum256([]byte(sandboxDir))
+		return filepath.Join(os.TempDir(), "interop-"+name+"-"+hex.EncodeToString(sum[:6])), nil
+	}
+
+	appDir, err := GetAppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, name), nil
+}
+
+// GetAppDir returns the root interop configuration directory. It honors
+// XDG_CONFIG_HOME and the platform config directory, using an existing
+// ~/.config/interop directory until MigrateAppDir moves it.
+func GetAppDir() (string, error) {
+	return pathutil.AppConfigDir(pathConfig.SettingsDir, pathConfig.AppDir)
+}
+
+// MigrateAppDir moves an existing ~/.config/interop directory to the
+// configuration directory when the location has changed, returning the
+// directory it moved or "" when there was nothing to move
+func MigrateAppDir() (string, error) {
+	return pathutil.MigrateAppConfigDir(pathConfig.SettingsDir, pathConfig.AppDir)
+}
+
+// validate() guarantees <config dir>/interop/settings.toml exists and
 // returns its absolute path.
 func validate() (string, error) {
-	root, e := os.UserHomeDir()
+	base, e := GetAppDir()
 	if e != nil {
-		logging.Error("Failed to get user home directory: " + e.Error())
+		return "", e
 	}
-	config := filepath.Join(root, pathConfig.SettingsDir)
-	base := filepath.Join(config, pathConfig.AppDir)
 	path := filepath.Join(base, pathConfig.CfgFile)
 
+	// Sandboxed fixtures are used as provided
+	if Sandboxed() {
+		return path, nil
+	}
+
 	if e := os.MkdirAll(base, 0o755); e != nil {
 		logging.Error("Can't create the directory for settings: " + e.Error())
 	} else {
@@ -604,7 +1232,7 @@ func validate() (string, error) {
 		if e != nil {
 			logging.Error("Failed to create settings file: " + e.Error())
 		} else {
-			if _, writeErr := f.Write([]byte(defaultSettingsTemplate)); writeErr != nil {
+			if _, writeErr := f.Write([]byte(defaultSettings())); writeErr != nil {
 				logging.Error("Failed to write template to settings file: " + writeErr.Error())
 			}
 			if e := f.Close(); e != nil {
@@ -620,6 +1248,16 @@ func validate() (string, error) {
 // - top level mcp_port can't be the same as any MCP server port
 // - can't have MCP servers with the same port or name
 func ValidateMCPConfig(cfg *Settings) error {
+	if _, _, err := ParseQuietHours(cfg.QuietHours); err != nil {
+		return fmt.Errorf("invalid quiet_hours: %w", err)
+	}
+	if err := cfg.EnvPolicy.Validate(); err != nil {
+		return err
+	}
+	if _, err := cfg.ToolTimeoutLimit(); err != nil {
+		return err
+	}
+
 	if cfg.MCPServers == nil {
 		cfg.MCPServers = make(map[string]MCPServer)
 		return nil
@@ -629,7 +1267,7 @@ func ValidateMCPConfig(cfg *Settings) error {
 	usedPorts := make(map[int]string)
 
 	// First put the default port in the map
-	if cfg.MCPPort > 0 {
+	if cfg.MCPPort > 0 && cfg.DefaultMCPServerEnabled() {
 		usedPorts[cfg.MCPPort] = "default MCP server"
 	}
 
@@ -660,14 +1298,25 @@ func ValidateMCPConfig(cfg *Settings) error {
 		if server.Name != name {
 			return fmt.Errorf("MCP server name '%s' doesn't match key '%s'", server.Name, name)
 		}
+
+		if _, _, err := ParseQuietHours(server.QuietHours); err != nil {
+			return fmt.Errorf("MCP server '%s' has invalid quiet_hours: %w", name, err)
+		}
+		if err := server.EnvPolicy.Validate(); err != nil {
+			return fmt.Errorf("MCP server '%s' has an %w", name, err)
+		}
 	}
 
 	// Check command MCP references
 	for cmdName, cmd := range cfg.Commands {
-		if cmd.MCP != "" {
-			if _, exists := cfg.MCPServers[cmd.MCP]; !exists {
+		for _, serverName := range cmd.MCP {
+			server, exists := cfg.MCPServers[serverName]
+			if !exists {
 				return fmt.Errorf("command '%s' references non-existent MCP server '%s'",
-					cmdName, cmd.MCP)
+					cmdName, serverName)
+			}
+			if server.Admin {
+				return fmt.Errorf("command '%s' can't be served by admin MCP server '%s'", cmdName, serverName)
 			}
 		}
 	}
@@ -693,10 +1342,14 @@ func ValidateMCPConfig(cfg *Settings) error {
 		}
 
 		// Check prompt MCP references
-		if prompt.MCP != "" {
-			if _, exists := cfg.MCPServers[prompt.MCP]; !exists {
+		for _, serverName := range prompt.MCP {
+			server, exists := cfg.MCPServers[serverName]
+			if !exists {
 				return fmt.Errorf("prompt '%s' references non-existent MCP server '%s'",
-					promptName, prompt.MCP)
+					promptName, serverName)
+			}
+			if server.Admin {
+				return fmt.Errorf("prompt '%s' can't be served by admin MCP server '%s'", promptName, serverName)
 			}
 		}
 
@@ -725,10 +1378,20 @@ func ValidateMCPConfig(cfg *Settings) error {
 
 // ConfigFromDirectory represents all configuration sections that can be loaded from external files
 type ConfigFromDirectory struct {
-	Commands   map[string]CommandConfig `toml:"commands"`
-	Projects   map[string]Project       `toml:"projects"`
-	Prompts    map[string]PromptConfig  `toml:"prompts"`
-	MCPServers map[string]MCPServer     `toml:"mcp_servers"`
+	Commands         map[string]CommandConfig   `toml:"commands"`
+	Projects         map[string]Project         `toml:"projects"`
+	ProjectTemplates map[string]ProjectTemplate `toml:"project_templates"`
+	Prompts          map[string]PromptConfig    `toml:"prompts"`
+	MCPServers       map[string]MCPServer       `toml:"mcp_servers"`
+	Overrides        []string                   `toml:"overrides,omitempty"` // Commands replacing definitions from higher priority sources
+	ParseErrors      []*ConfigParseError        `toml:"-"`                   // Files skipped because they failed to parse
+	Sources          map[string]string          `toml:"-"`                   // File defining each entry, by sourceKey
+}
+
+// sourceKey identifies a configuration entry, such as a command or prompt,
+// for source attribution
+func sourceKey(kind, name string) string {
+	return kind + ":" + name
 }
 
 // loadConfigFromDirectory loads all configuration definitions from TOML files in a directory
@@ -750,18 +1413,22 @@ func loadConfigFromDirectory(dirPath string) (*ConfigFromDirectory, error) {
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
 		logging.Warning("Config directory does not exist: %s", dirPath)
 		return &ConfigFromDirectory{
-			Commands:   make(map[string]CommandConfig),
-			Projects:   make(map[string]Project),
-			Prompts:    make(map[string]PromptConfig),
-			MCPServers: make(map[string]MCPServer),
+			Commands:         make(map[string]CommandConfig),
+			Projects:         make(map[string]Project),
+			ProjectTemplates: make(map[string]ProjectTemplate),
+			Prompts:          make(map[string]PromptConfig),
+			MCPServers:       make(map[string]MCPServer),
+			Sources:          make(map[string]string),
 		}, nil
 	}
 
 	result := &ConfigFromDirectory{
-		Commands:   make(map[string]CommandConfig),
-		Projects:   make(map[string]Project),
-		Prompts:    make(map[string]PromptConfig),
-		MCPServers: make(map[string]MCPServer),
+		Commands:         make(map[string]CommandConfig),
+		Projects:         make(map[string]Project),
+		ProjectTemplates: make(map[string]ProjectTemplate),
+		Prompts:          make(map[string]PromptConfig),
+		MCPServers:       make(map[string]MCPServer),
+		Sources:          make(map[string]string),
 	}
 
 	// Read all .toml files in the directory
@@ -776,47 +1443,78 @@ func loadConfigFromDirectory(dirPath string) (*ConfigFromDirectory, error) {
 	for _, file := range files {
 		var fileConfig ConfigFromDirectory
 
-		if _, err := toml.DecodeFile(file, &fileConfig); err != nil {
-			logging.Warning("Failed to parse config file %s: %v", file, err)
+		data, err := decodeFile(file, &fileConfig)
+		if err != nil {
+			var parseErr *ConfigParseError
+			if !errors.As(err, &parseErr) {
+				parseErr = &ConfigParseError{File: file, Message: err.Error(), Err: err}
+			}
+			result.ParseErrors = append(result.ParseErrors, parseErr)
 			continue
 		}
 
+		recordSources(data, file, fileConfig.Commands, fileConfig.Projects, fileConfig.Prompts)
+		result.Overrides = append(result.Overrides, fileConfig.Overrides...)
+
 		// Merge commands from this file
 		for name, cmd := range fileConfig.Commands {
-			if _, exists := result.Commands[name]; exists {
-				logging.Warning("Duplicate command '%s' found in %s, keeping first occurrence", name, file)
+			key := sourceKey("command", name)
+			if first, exists := result.Sources[key]; exists {
+				logging.Warning("Duplicate command '%s' in %s, keeping the one from %s", name, file, first)
+				kept := result.Commands[name]
+				kept.Shadowed = append(kept.Shadowed, cmd.Location())
+				result.Commands[name] = kept
 				continue
 			}
+			result.Sources[key] = file
 			result.Commands[name] = cmd
 			logging.Message("Loaded command '%s' from %s", name, file)
 		}
 
 		// Merge projects from this file
 		for name, project := range fileConfig.Projects {
-			if _, exists := result.Projects[name]; exists {
-				logging.Warning("Duplicate project '%s' found in %s, keeping first occurrence", name, file)
+			key := sourceKey("project", name)
+			if first, exists := result.Sources[key]; exists {
+				logging.Warning("Duplicate project '%s' in %s, keeping the one from %s", name, file, first)
 				continue
 			}
+			result.Sources[key] = file
 			result.Projects[name] = project
 			logging.Message("Loaded project '%s' from %s", name, file)
 		}
 
+		// Merge project templates from this file
+		for name, template := range fileConfig.ProjectTemplates {
+			key := sourceKey("project template", name)
+			if first, exists := result.Sources[key]; exists {
+				logging.Warning("Duplicate project template '%s' in %s, keeping the one from %s", name, file, first)
+				continue
+			}
+			result.Sources[key] = file
+			result.ProjectTemplates[name] = template
+			logging.Message("Loaded project template '%s' from %s", name, file)
+		}
+
 		// Merge prompts from this file
 		for name, prompt := range fileConfig.Prompts {
-			if _, exists := result.Prompts[name]; exists {
-				logging.Warning("Duplicate prompt '%s' found in %s, keeping first occurrence", name, file)
+			key := sourceKey("prompt", name)
+			if first, exists := result.Sources[key]; exists {
+				logging.Warning("Duplicate prompt '%s' in %s, keeping the one from %s", name, file, first)
 				continue
 			}
+			result.Sources[key] = file
 			result.Prompts[name] = prompt
 			logging.Message("Loaded prompt '%s' from %s", name, file)
 		}
 
 		// Merge MCP servers from this file
 		for name, server := range fileConfig.MCPServers {
-			if _, exists := result.MCPServers[name]; exists {
-				logging.Warning("Duplicate MCP server '%s' found in %s, keeping first occurrence", name, file)
+			key := sourceKey("MCP server", name)
+			if first, exists := result.Sources[key]; exists {
+				logging.Warning("Duplicate MCP server '%s' in %s, keeping the one from %s", name, file, first)
 				continue
 			}
+			result.Sources[key] = file
 			result.MCPServers[name] = server
 			logging.Message("Loaded MCP server '%s' from %s", name, file)
 		}
@@ -827,11 +1525,15 @@ func loadConfigFromDirectory(dirPath string) (*ConfigFromDirectory, error) {
 
 // mergeConfig merges all configuration types from multiple sources with precedence rules
 // Priority order: main settings.toml > command_dirs (in order) > within dir (alphabetical)
-func mergeConfig(mainSettings *Settings, commandDirs []string) (*Settings, []string) {
+//
+// Files that fail to parse are skipped; their errors are joined into the
+// returned error.
+func mergeConfig(mainSettings *Settings, commandDirs []CommandDir) (*Settings, []string, error) {
 	result := &Settings{
 		LogLevel:              mainSettings.LogLevel,
 		Env:                   mainSettings.Env,
 		Projects:              make(map[string]Project),
+		ProjectTemplates:      make(map[string]ProjectTemplate),
 		Commands:              make(map[string]CommandConfig),
 		Prompts:               make(map[string]PromptConfig),
 		ExecutableSearchPaths: mainSettings.ExecutableSearchPaths,
@@ -842,81 +1544,441 @@ func mergeConfig(mainSettings *Settings, commandDirs []string) (*Settings, []str
 	}
 
 	var conflicts []string
+	var parseErrors []error
+
+	// Where each entry comes from, for the conflict messages. Only commands
+	// record the file they were loaded from.
+	sources := make(map[string]string)
+	mainSource := func(kind, name string) {
+		sources[sourceKey(kind, name)] = "main settings"
+	}
 
 	// Start with main settings (highest priority)
 	for name, cmd := range mainSettings.Commands {
 		result.Commands[name] = cmd
+		if cmd.SourceFile != "" {
+			sources[sourceKey("command", name)] = cmd.SourceFile
+		} else {
+			mainSource("command", name)
+		}
 	}
 	for name, project := range mainSettings.Projects {
 		result.Projects[name] = project
+		mainSource("project", name)
+	}
+	for name, template := range mainSettings.ProjectTemplates {
+		result.ProjectTemplates[name] = template
+		mainSource("project template", name)
 	}
 	for name, prompt := range mainSettings.Prompts {
 		result.Prompts[name] = prompt
+		mainSource("prompt", name)
 	}
 	for name, server := range mainSettings.MCPServers {
 		result.MCPServers[name] = server
+		mainSource("MCP server", name)
 	}
 
 	// Load configuration from each directory in order
 	for _, dir := range commandDirs {
-		dirConfig, err := loadConfigFromDirectory(dir)
+		dirConfig, err := loadConfigFromDirectory(dir.Path)
 		if err != nil {
-			logging.Warning("Failed to load config from directory %s: %v", dir, err)
+			logging.Warning("Failed to load config from directory %s: %v", dir.Path, err)
 			continue
 		}
+		dirConfig.namespace(dir)
+		for _, parseErr := range dirConfig.ParseErrors {
+			parseErrors = append(parseErrors, parseErr)
+		}
+
+		overrides := make(map[string]bool)
+		for _, name := range dirConfig.Overrides {
+			overrides[name] = true
+		}
 
 		// Merge commands
 		for name, cmd := range dirConfig.Commands {
-			if _, exists := result.Commands[name]; exists {
-				conflicts = append(conflicts, fmt.Sprintf("Command '%s' conflicts between main settings and %s", name, dir))
+			key := sourceKey("command", name)
+			if existing, exists := result.Commands[name]; exists && overrides[name] {
+				logging.Message("Command '%s' from %s overrides the one from %s", name, dirConfig.Sources[key], sources[key])
+				cmd.Shadowed = append(append(cmd.Shadowed, existing.Location()), existing.Shadowed...)
+			} else if exists {
+				conflicts = append(conflicts, fmt.Sprintf("Command '%s' in %s conflicts with the one in %s", name, dirConfig.Sources[key], sources[key]))
+				existing.Shadowed = append(append(existing.Shadowed, cmd.Location()), cmd.Shadowed...)
+				result.Commands[name] = existing
 				continue // Keep existing (higher priority)
 			}
 			result.Commands[name] = cmd
+			sources[key] = dirConfig.Sources[key]
 		}
 
 		// Merge projects
 		for name, project := range dirConfig.Projects {
+			key := sourceKey("project", name)
 			if _, exists := result.Projects[name]; exists {
-				conflicts = append(conflicts, fmt.Sprintf("Project '%s' conflicts between main settings and %s", name, dir))
+				conflicts = append(conflicts, fmt.Sprintf("Project '%s' in %s conflicts with the one in %s", name, dirConfig.Sources[key], sources[key]))
 				continue // Keep existing (higher priority)
 			}
 			result.Projects[name] = project
+			sources[key] = dirConfig.Sources[key]
+		}
+
+		// Merge project templates
+		for name, template := range dirConfig.ProjectTemplates {
+			key := sourceKey("project template", name)
+			if _, exists := result.ProjectTemplates[name]; exists {
+				conflicts = append(conflicts, fmt.Sprintf("Project template '%s' in %s conflicts with the one in %s", name, dirConfig.Sources[key], sources[key]))
+				continue // Keep existing (higher priority)
+			}
+			result.ProjectTemplates[name] = template
+			sources[key] = dirConfig.Sources[key]
 		}
 
 		// Merge prompts
 		for name, prompt := range dirConfig.Prompts {
+			key := sourceKey("prompt", name)
 			if _, exists := result.Prompts[name]; exists {
-				conflicts = append(conflicts, fmt.Sprintf("Prompt '%s' conflicts between main settings and %s", name, dir))
+				conflicts = append(conflicts, fmt.Sprintf("Prompt '%s' in %s conflicts with the one in %s", name, dirConfig.Sources[key], sources[key]))
 				continue // Keep existing (higher priority)
 			}
 			result.Prompts[name] = prompt
+			sources[key] = dirConfig.Sources[key]
 		}
 
 		// Merge MCP servers
 		for name, server := range dirConfig.MCPServers {
+			key := sourceKey("MCP server", name)
 			if _, exists := result.MCPServers[name]; exists {
-				conflicts = append(conflicts, fmt.Sprintf("MCP server '%s' conflicts between main settings and %s", name, dir))
+				conflicts = append(conflicts, fmt.Sprintf("MCP server '%s' in %s conflicts with the one in %s", name, dirConfig.Sources[key], sources[key]))
 				continue // Keep existing (higher priority)
 			}
 			result.MCPServers[name] = server
+			sources[key] = dirConfig.Sources[key]
+		}
+	}
+
+	return result, conflicts, errors.Join(parseErrors...)
+}
+
+// resolveCommandExtends returns the commands with their extends chains applied.
+// Commands extending an undefined command or taking part in a cycle are kept
+// as defined; validation reports them.
+func (s *Settings) resolveCommandExtends() map[string]CommandConfig {
+	const (
+		resolving = iota + 1
+		resolved
+		failed
+	)
+	state := make(map[string]int, len(s.Commands))
+	result := make(map[string]CommandConfig, len(s.Commands))
+
+	var resolve func(name string) bool
+	resolve = func(name string) bool {
+		switch state[name] {
+		case resolved:
+			return true
+		case resolving, failed:
+			return false
+		}
+
+		cmd, exists := s.Commands[name]
+		if !exists {
+			return false
+		}
+		if cmd.Extends == "" {
+			state[name] = resolved
+			result[name] = cmd
+			return true
+		}
+
+		state[name] = resolving
+		if !resolve(cmd.Extends) {
+			logging.Warning("command '%s' extends '%s' which is undefined or cyclic", name, cmd.Extends)
+			state[name] = failed
+			result[name] = cmd
+			return false
+		}
+
+		state[name] = resolved
+		result[name] = cmd.inheritFrom(result[cmd.Extends])
+		return true
+	}
+
+	for name := range s.Commands {
+		resolve(name)
+	}
+	return result
+}
+
+// applyProjectTemplates merges each project's extended template underneath it.
+// Project commands replace template bindings for the same command, project env
+// overrides template env, and the template description is used as a fallback.
+func (s *Settings) applyProjectTemplates() map[string]Project {
+	result := make(map[string]Project, len(s.Projects))
+	for name, project := range s.Projects {
+		if project.Extends == "" {
+			result[name] = project
+			continue
+		}
+
+		template, exists := s.ProjectTemplates[project.Extends]
+		if !exists {
+			logging.Warning("project '%s' extends undefined template '%s'", name, project.Extends)
+			result[name] = project
+			continue
+		}
+
+		if project.Description == "" {
+			project.Description = template.Description
+		}
+
+		overridden := make(map[string]bool, len(project.Commands))
+		for _, alias := range project.Commands {
+			overridden[alias.CommandName] = true
+		}
+		var commands []Alias
+		for _, alias := range template.Commands {
+			if !overridden[alias.CommandName] {
+				commands = append(commands, alias)
+			}
+		}
+		project.Commands = append(commands, project.Commands...)
+
+		if len(template.Env) > 0 {
+			env := make(map[string]string, len(template.Env)+len(project.Env))
+			for key, value := range template.Env {
+				env[key] = value
+			}
+			for key, value := range project.Env {
+				env[key] = value
+			}
+			project.Env = env
+		}
+
+		result[name] = project
+	}
+	return result
+}
+
+// projectMCPServers adds an MCP server named after each project with
+// mcp_server set, serving the commands bound to the project. A server of the
+// same name in mcp_servers is kept and serves the project too. Otherwise the
+// server listens on the project's mcp_port, or on the first port after
+// mcp_port that no other server uses, given out in project name order. With
+// the default server disabled and no mcp_port, ports start at DefaultMCPPort.
+func (s *Settings) projectMCPServers() map[string]MCPServer {
+	servers := make(map[string]MCPServer, len(s.MCPServers))
+	used := map[int]bool{}
+	next := s.MCPPort + 1
+	if s.DefaultMCPServerEnabled() {
+		used[s.MCPPort] = true
+	} else if s.MCPPort == 0 {
+		next = DefaultMCPPort
+	}
+	for name, server := range s.MCPServers {
+		servers[name] = server
+		used[server.Port] = true
+	}
+
+	var names []string
+	for name, project := range s.Projects {
+		if project.MCPServer {
+			names = append(names, name)
+			used[project.MCPPort] = true
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		project := s.Projects[name]
+		if server, exists := servers[name]; exists {
+			server.Project = name
+			servers[name] = server
+			continue
+		}
+
+		port := project.MCPPort
+		if port == 0 {
+			for used[next] {
+				next++
+			}
+			port = next
+			used[port] = true
+		}
+		description := project.Description
+		if description == "" {
+			description = fmt.Sprintf("Commands of project '%s'", name)
+		}
+		servers[name] = MCPServer{Name: name, Description: description, Port: port, Project: name}
+	}
+	return servers
+}
+
+// ServedBy reports whether the MCP server called serverName, empty for the
+// default server, serves the command: the commands assigned to it with mcp
+// and, for the server of a project, the commands bound to the project
+func (s *Settings) ServedBy(serverName, cmdName string, cmd CommandConfig) bool {
+	if cmd.MCP.Includes(serverName) {
+		return true
+	}
+	server, exists := s.MCPServers[serverName]
+	if serverName == "" || !exists || server.Project == "" {
+		return false
+	}
+	for _, alias := range s.Projects[server.Project].Commands {
+		if alias.CommandName == cmdName {
+			return true
+		}
+	}
+	return false
+}
+
+// expandProjectGlobs replaces projects whose path is a glob pattern with one
+// sub-project per matching directory. Sub-projects are named after their
+// directory and share the command bindings and env of the glob project.
+func (s *Settings) expandProjectGlobs() map[string]Project {
+	homeDir, _ := os.UserHomeDir()
+	projects := s.Projects
+
+	result := make(map[string]Project, len(projects))
+	var globNames []string
+	for name, project := range projects {
+		if IsGlobPath(project.Path) {
+			globNames = append(globNames, name)
+			continue
+		}
+		result[name] = project
+	}
+
+	// Expand in a stable order so name conflicts resolve deterministically
+	sort.Strings(globNames)
+	for _, name := range globNames {
+		project := projects[name]
+
+		pattern := project.Path
+		if strings.HasPrefix(pattern, "~/") {
+			pattern = filepath.Join(homeDir, pattern[2:])
+		} else if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(homeDir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			logging.Warning("project '%s' has an invalid path pattern %s: %v", name, project.Path, err)
+			continue
+		}
+
+		expanded := 0
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			if !s.IsProjectPathAllowed(match) {
+				logging.Warning("project '%s' match must be inside allowed_project_roots: %s", name, match)
+				continue
+			}
+
+			subName := filepath.Base(match)
+			if _, exists := result[subName]; exists {
+				logging.Warning("project '%s' expanded to '%s' which is already defined, skipping", name, subName)
+				continue
+			}
+
+			result[subName] = Project{
+				Path:        match,
+				Description: project.Description,
+				Commands:    project.Commands,
+				Env:         project.Env,
+				EnvFile:     project.EnvFile,
+				Group:       name,
+				SourceFile:  project.SourceFile,
+				SourceLine:  project.SourceLine,
+				MCPServer:   project.MCPServer,
+			}
+			expanded++
+		}
+
+		if expanded == 0 {
+			logging.Warning("project '%s' path pattern matched no directories: %s", name, project.Path)
+		} else {
+			logging.Message("Expanded project '%s' into %d sub-projects", name, expanded)
+		}
+	}
+
+	return result
+}
+
+// localCommandDirs returns the configured command directories, falling back to
+// the default config.d directory when none are configured
+func localCommandDirs(s *Settings) []CommandDir {
+	if len(s.CommandDirs) > 0 {
+		return s.CommandDirs
+	}
+
+	defaultCommandsPath, err := GetConfigPath()
+	if err == nil {
+		// Only add if the directory exists to avoid warnings
+		if _, err := os.Stat(defaultCommandsPath); err == nil {
+			logging.Message("Using default config directory: %s", defaultCommandsPath)
+			return []CommandDir{{Path: defaultCommandsPath}}
+		}
+	}
+	return nil
+}
+
+// LocalCommandNames returns the names of commands defined in settings.toml and
+// the local command directories, leaving out fetched remote configuration
+func LocalCommandNames() (map[string]bool, error) {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var mainSettings Settings
+	cfgPath := filepath.Join(appDir, pathConfig.CfgFile)
+	if _, err := os.Stat(cfgPath); err == nil {
+		if _, err := toml.DecodeFile(cfgPath, &mainSettings); err != nil {
+			return nil, fmt.Errorf("failed to decode settings file: %w", err)
 		}
 	}
 
-	return result, conflicts
+	names := make(map[string]bool)
+	for name := range mainSettings.Commands {
+		names[name] = true
+	}
+	for _, dir := range localCommandDirs(&mainSettings) {
+		dirConfig, err := loadConfigFromDirectory(dir.Path)
+		if err != nil {
+			logging.Warning("Failed to load config from directory %s: %v", dir.Path, err)
+			continue
+		}
+		dirConfig.namespace(dir)
+		for name := range dirConfig.Commands {
+			names[name] = true
+		}
+	}
+	return names, nil
 }
 
-// Load parses settings.toml once.
+// Load parses settings.toml once. Files that fail to parse are reported as
+// *ConfigParseError, joined when there are several, together with the
+// settings loaded from the remaining files.
 func Load() (*Settings, error) {
 	once.Do(func() {
+		loadPhases = nil
+		start := time.Now()
+
 		path, e := validate()
 		if e != nil {
 			err = e
 			logging.Error("Failed to validate settings: " + e.Error())
 		}
+		// A broken file is reported to the caller, which decides whether the
+		// partially loaded settings are of any use
 		var c Settings
-		if _, e := toml.DecodeFile(path, &c); e != nil {
+		data, e := decodeFile(path, &c)
+		if e != nil && err == nil {
 			err = e
-			logging.Error("Failed to decode settings file: " + e.Error())
 		}
 		logging.SetDefaultLevelFromString(c.LogLevel)
 
@@ -928,6 +1990,11 @@ func Load() (*Settings, error) {
 			}
 
 			for name, project := range c.Projects {
+				// Glob projects are validated after expansion
+				if IsGlobPath(project.Path) {
+					continue
+				}
+
 				// Handle path with tilde expansion
 				projectPath := project.Path
 
@@ -938,8 +2005,8 @@ func Load() (*Settings, error) {
 					projectPath = filepath.Join(homeDir, projectPath)
 				}
 
-				if filepath.IsAbs(project.Path) && !filepath.HasPrefix(project.Path, homeDir) {
-					errMsg := fmt.Sprintf("project '%s' path must be inside $HOME: %s", name, project.Path)
+				if !c.IsProjectPathAllowed(projectPath) {
+					errMsg := fmt.Sprintf("project '%s' path must be inside allowed_project_roots: %s", name, project.Path)
 					logging.Warning(errMsg)
 					continue
 				}
@@ -952,10 +2019,15 @@ func Load() (*Settings, error) {
 			logging.Message("Projects are validated")
 		}
 
+		recordSources(data, path, c.Commands, c.Projects, c.Prompts)
+
 		// Initialize empty collections if nil
 		if c.Projects == nil {
 			c.Projects = make(map[string]Project)
 		}
+		if c.ProjectTemplates == nil {
+			c.ProjectTemplates = make(map[string]ProjectTemplate)
+		}
 		if c.Commands == nil {
 			c.Commands = make(map[string]CommandConfig)
 		}
@@ -966,43 +2038,38 @@ func Load() (*Settings, error) {
 			c.MCPServers = make(map[string]MCPServer)
 		}
 
-		// Set default MCP port if not configured
-		if c.MCPPort == 0 {
-			c.MCPPort = 8081
+		// Set default MCP port if not configured, the default server only
+		// needs one when it exists
+		if c.MCPPort == 0 && c.DefaultMCPServerEnabled() {
+			c.MCPPort = DefaultMCPPort
 		}
 
+		start = endPhase("config.read", start)
+
 		// Handle command directories with backwards compatibility
-		commandDirs := c.CommandDirs
-
-		// If no command_dirs are explicitly configured, add the default commands.d directory
-		if len(commandDirs) == 0 {
-			defaultCommandsPath, err := GetConfigPath()
-			if err == nil {
-				// Only add if the directory exists to avoid warnings
-				if _, err := os.Stat(defaultCommandsPath); err == nil {
-					commandDirs = []string{defaultCommandsPath}
-					logging.Message("Using default config directory: %s", defaultCommandsPath)
-				}
-			}
-		}
+		commandDirs := localCommandDirs(&c)
 
 		// Add remote configuration directories if they exist
-		homeDir, err := os.UserHomeDir()
-		if err == nil {
-			remoteConfigsDir := filepath.Join(homeDir, pathConfig.SettingsDir, pathConfig.AppDir, "config.d.remote")
-			if _, err := os.Stat(remoteConfigsDir); err == nil {
-				commandDirs = append(commandDirs, remoteConfigsDir)
+		appDir, e := GetAppDir()
+		if e == nil {
+			remoteConfigsDir := filepath.Join(appDir, "config.d.remote")
+			if _, e := os.Stat(remoteConfigsDir); e == nil {
+				commandDirs = append(commandDirs, CommandDir{Path: remoteConfigsDir})
 				logging.Message("Including remote config directory: %s", remoteConfigsDir)
 			}
 		}
 
 		// Load configuration from command directories
 		if len(commandDirs) > 0 {
-			mergedConfig, conflicts := mergeConfig(&c, commandDirs)
+			mergedConfig, conflicts, e := mergeConfig(&c, commandDirs)
+			if e != nil && err == nil {
+				err = e
+			}
 
 			// Replace all configuration sections with merged ones
 			c.Commands = mergedConfig.Commands
 			c.Projects = mergedConfig.Projects
+			c.ProjectTemplates = mergedConfig.ProjectTemplates
 			c.Prompts = mergedConfig.Prompts
 			c.MCPServers = mergedConfig.MCPServers
 
@@ -1018,27 +2085,94 @@ func Load() (*Settings, error) {
 			logging.Message("Loaded configuration from %d directories", len(commandDirs))
 		}
 
-		// Validate MCP configuration
-		if err := ValidateMCPConfig(&c); err != nil {
-			err = err
-			logging.Error("Failed to validate MCP configuration: " + err.Error())
+		start = endPhase("config.merge", start)
+
+		// Apply INTEROP_* environment overrides on top of all file sources
+		c.applyEnvOverrides(os.Environ())
+		logging.SetDefaultLevelFromString(c.LogLevel)
+
+		// Resolve command inheritance once all sources are merged
+		c.Commands = c.resolveCommandExtends()
+
+		// Apply project templates before expansion so sub-projects inherit them
+		c.Projects = c.applyProjectTemplates()
+
+		// Expand glob project paths into sub-projects
+		c.Projects = c.expandProjectGlobs()
+
+		// Add the servers of projects with mcp_server once all are known
+		c.MCPServers = c.projectMCPServers()
+		start = endPhase("config.resolve", start)
+
+		// Validate MCP configuration. Problems in a file that parsed are left
+		// to `interop validate` rather than failing every command.
+		if e := ValidateMCPConfig(&c); e != nil {
+			logging.Error("Failed to validate MCP configuration: " + e.Error())
 		}
+		endPhase("config.validate", start)
 
 		cfg = &c
 	})
 	return cfg, err
 }
 
+// ProjectRoots returns the expanded allowed_project_roots, defaulting to $HOME
+func (s *Settings) ProjectRoots() []string {
+	homeDir, _ := os.UserHomeDir()
+
+	if len(s.AllowedProjectRoots) == 0 {
+		if homeDir == "" {
+			return nil
+		}
+		return []string{homeDir}
+	}
+
+	roots := make([]string, 0, len(s.AllowedProjectRoots))
+	for _, root := range s.AllowedProjectRoots {
+		// Handle tilde expansion
+		if root == "~" {
+			root = homeDir
+		} else if strings.HasPrefix(root, "~/") {
+			root = filepath.Join(homeDir, root[2:])
+		} else if !filepath.IsAbs(root) {
+			root = filepath.Join(homeDir, root)
+		}
+		roots = append(roots, filepath.Clean(root))
+	}
+	return roots
+}
+
+// IsProjectPathAllowed reports whether an expanded project path lives under one of
+// the allowed project roots
+func (s *Settings) IsProjectPathAllowed(projectPath string) bool {
+	return underRoots(projectPath, s.ProjectRoots())
+}
+
+// underRoots reports whether path is one of roots or lives under one of them
+func underRoots(path string, roots []string) bool {
+	path = filepath.Clean(path)
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
 func GetMCPPort() int {
 	cfg, err := Load()
 	if err != nil {
 		logging.Error("Failed to load settings: " + err.Error())
-		return 8081 // Return default port if settings can't be loaded
+		return DefaultMCPPort // Return default port if settings can't be loaded
 	}
 
 	// If MCPPort is not configured (0), return the default port
 	if cfg.MCPPort == 0 {
-		return 8081
+		return DefaultMCPPort
 	}
 
 	return cfg.MCPPort
@@ -1046,41 +2180,37 @@ func GetMCPPort() int {
 
 // GetExecutablesPath returns the path to the executables directory
 func GetExecutablesPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	appDir, err := GetAppDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
 
-	return filepath.Join(
-		homeDir,
-		DefaultPathConfig.SettingsDir,
-		DefaultPathConfig.AppDir,
-		DefaultPathConfig.ExecutablesDir,
-	), nil
+	return filepath.Join(appDir, DefaultPathConfig.ExecutablesDir), nil
 }
 
 // GetExecutableSearchPaths returns the executable search paths including the main executables directory
 func GetExecutableSearchPaths(cfg *Settings) ([]string, error) {
+	return executableSearchPaths(cfg, true)
+}
+
+// executableSearchPaths returns the executable search paths, warning about
+// configured paths that don't exist when warn is set
+func executableSearchPaths(cfg *Settings, warn bool) ([]string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user home directory: %w", err)
 	}
 
+	appDir, err := GetAppDir()
+	if err != nil {
+		return nil, err
+	}
+
 	// Start with the main executables directory
-	executablesPath := filepath.Join(
-		homeDir,
-		pathConfig.SettingsDir,
-		pathConfig.AppDir,
-		pathConfig.ExecutablesDir,
-	)
+	executablesPath := filepath.Join(appDir, pathConfig.ExecutablesDir)
 
 	// Add remote executables directory (always include it by default)
-	remoteExecutablesPath := filepath.Join(
-		homeDir,
-		pathConfig.SettingsDir,
-		pathConfig.AppDir,
-		"executables.remote",
-	)
+	remoteExecutablesPath := filepath.Join(appDir, "executables.remote")
 
 	searchPaths := []string{executablesPath, remoteExecutablesPath}
 
@@ -1096,7 +2226,7 @@ func GetExecutableSearchPaths(cfg *Settings) ([]string, error) {
 		// Check if path exists and add it
 		if _, err := os.Stat(path); err == nil {
 			searchPaths = append(searchPaths, path)
-		} else {
+		} else if warn {
 			logging.Warning("Configured executable search path does not exist: %s", path)
 		}
 	}
@@ -1104,6 +2234,30 @@ func GetExecutableSearchPaths(cfg *Settings) ([]string, error) {
 	return searchPaths, nil
 }
 
+// PrependSearchPaths returns the PATH value path with the existing executable
+// search paths of cfg in front, so shell commands can call the scripts in
+// executables and executables.remote by name. Directories already on path are
+// not added again.
+func PrependSearchPaths(cfg *Settings, path string) string {
+	searchPaths, err := executableSearchPaths(cfg, false)
+	if err != nil {
+		return path
+	}
+
+	current := filepath.SplitList(path)
+	var dirs []string
+	for _, dir := range searchPaths {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() || slices.Contains(current, dir) {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	if path != "" {
+		dirs = append(dirs, path)
+	}
+	return strings.Join(dirs, string(os.PathListSeparator))
+}
+
 // ParseStringSlice parses a TOML value into a string slice
 func ParseStringSlice(value interface{}) []string {
 	if value == nil {
@@ -1127,31 +2281,53 @@ func ParseStringSlice(value interface{}) []string {
 	return []string{}
 }
 
-// GetProjectCommands returns the list of commands associated with a project
-func GetProjectCommands(cfg *Settings, projectName string) (map[string]CommandConfig, error) {
+// ProjectCommand is a command listed by a project, resolved to its definition
+type ProjectCommand struct {
+	Alias
+	Config  CommandConfig
+	Missing bool // The referenced command isn't defined
+}
+
+// Name returns the name the project runs the command by, its alias if it has one
+func (c ProjectCommand) Name() string {
+	if c.Alias.Alias != "" {
+		return c.Alias.Alias
+	}
+	return c.CommandName
+}
+
+// ResolveProjectCommands returns the commands a project lists, in order,
+// marking references to commands that aren't defined as missing
+func ResolveProjectCommands(cfg *Settings, projectName string) ([]ProjectCommand, error) {
 	project, exists := cfg.Projects[projectName]
 	if !exists {
 		return nil, fmt.Errorf("project '%s' not found", projectName)
 	}
 
-	result := make(map[string]CommandConfig)
+	commands := make([]ProjectCommand, 0, len(project.Commands))
+	for _, alias := range project.Commands {
+		cmd, exists := cfg.Commands[alias.CommandName]
+		commands = append(commands, ProjectCommand{Alias: alias, Config: cmd, Missing: !exists})
+	}
+	return commands, nil
+}
 
-	// If no commands are defined for the project, return empty map
-	if len(project.Commands) == 0 {
-		return result, nil
+// GetProjectCommands returns the list of commands associated with a project,
+// keyed by their alias if they have one. References to commands that aren't
+// defined are left out with a warning.
+func GetProjectCommands(cfg *Settings, projectName string) (map[string]CommandConfig, error) {
+	commands, err := ResolveProjectCommands(cfg, projectName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Collect all commands that are listed in the project
-	for _, alias := range project.Commands {
-		cmd, exists := cfg.Commands[alias.CommandName]
-		if exists {
-			// Use the alias if provided, otherwise use the original command name
-			cmdKey := alias.CommandName
-			if alias.Alias != "" {
-				cmdKey = alias.Alias
-			}
-			result[cmdKey] = cmd
+	result := make(map[string]CommandConfig)
+	for _, cmd := range commands {
+		if cmd.Missing {
+			logging.Warning("Project '%s' references command '%s', which is not defined", projectName, cmd.CommandName)
+			continue
 		}
+		result[cmd.Name()] = cmd.Config
 	}
 
 	return result, nil
@@ -1186,65 +2362,178 @@ func From(ctx context.Context) *Settings {
 }
 
 // MergeEnvironmentVariables merges environment variables with the specified precedence:
-// 1. Command-level env (highest priority)
-// 2. Project-level env (if executed in a project context)
-// 3. Global-level env
+// 1. Command-level env, then its env_file (highest priority)
+// 2. Project-level env, then its env_file (if executed in a project context)
+// 3. Global-level env, then its env_file
 // 4. The shell's existing environment variables (lowest priority)
-func MergeEnvironmentVariables(cfg *Settings, commandName string, projectName string) []string {
+//
+// Project- and command-level values may reference ${PROJECT_PATH} and ${PROJECT_NAME}.
+// Values set by the settings that are secret:// references are read from the
+// keychain; a secret that can't be read is an error.
+// The executable search paths are put in front of the resulting PATH.
+func MergeEnvironmentVariables(cfg *Settings, commandName string, projectName string) ([]string, error) {
+	return mergeEnvironment(os.Environ(), cfg, commandName, projectName, true)
+}
+
+// PreviewEnvironmentVariables is MergeEnvironmentVariables leaving secret://
+// values as they are written, for dry runs that print the environment
+func PreviewEnvironmentVariables(cfg *Settings, commandName string, projectName string) []string {
+	env, _ := mergeEnvironment(os.Environ(), cfg, commandName, projectName, false)
+	return env
+}
+
+// resolveSecret reads the value a secret:// reference refers to
+var resolveSecret = secrets.Resolve
+
+// mergeEnvironment is MergeEnvironmentVariables starting from environ instead
+// of the current environment, resolving secret references when resolve is set
+func mergeEnvironment(environ []string, cfg *Settings, commandName string, projectName string, resolve bool) ([]string, error) {
 	// Start with the current environment
 	envMap := make(map[string]string)
 
 	// Copy all existing environment variables (lowest priority)
-	for _, env := range os.Environ() {
+	for _, env := range environ {
 		parts := strings.SplitN(env, "=", 2)
 		if len(parts) == 2 {
 			envMap[parts[0]] = parts[1]
 		}
 	}
 
-	// Apply global environment variables (3rd priority)
-	if cfg.Env != nil {
-		for key, value := range cfg.Env {
-			envMap[key] = value
+	// Variables set by the settings, the only ones whose secrets are resolved
+	configured := make(map[string]bool)
+	setAll := func(env []string, expand func(string) string) {
+		for _, assignment := range env {
+			key, value, _ := strings.Cut(assignment, "=")
+			envMap[key] = expand(value)
+			configured[key] = true
 		}
 	}
+	setMap := func(env map[string]string, expand func(string) string) {
+		for key, value := range env {
+			envMap[key] = expand(value)
+			configured[key] = true
+		}
+	}
+
+	// Apply global environment variables (3rd priority)
+	appDir, _ := GetAppDir()
+	unchanged := func(value string) string { return value }
+	setAll(loadEnvFile(cfg.EnvFile, appDir), unchanged)
+	setMap(cfg.Env, unchanged)
+
+	// Resolve the project so its path and name can be referenced
+	var projectPath string
+	project, inProject := cfg.Projects[projectName]
+	inProject = inProject && projectName != ""
+	if inProject {
+		projectPath, _ = pathutil.Expand(project.Path)
+	}
+	expandProject := func(value string) string {
+		return ExpandProjectVariables(value, projectName, projectPath)
+	}
 
 	// Apply project-level environment variables if in project context (2nd priority)
-	if projectName != "" {
-		if project, exists := cfg.Projects[projectName]; exists && project.Env != nil {
-			for key, value := range project.Env {
-				envMap[key] = value
+	if inProject {
+		setAll(loadEnvFile(project.EnvFile, projectPath), expandProject)
+		setMap(project.Env, expandProject)
+	}
+
+	// Apply command-level environment variables (highest priority). Outside a
+	// project the env_file is relative to the file defining the command.
+	if command, exists := cfg.Commands[commandName]; exists {
+		dir := projectPath
+		if !inProject {
+			dir = appDir
+			if command.SourceFile != "" {
+				dir = filepath.Dir(command.SourceFile)
 			}
 		}
+		setAll(loadEnvFile(command.EnvFile, dir), expandProject)
+		setMap(command.Env, expandProject)
 	}
 
-	// Apply command-level environment variables (highest priority)
-	if command, exists := cfg.Commands[commandName]; exists && command.Env != nil {
-		for key, value := range command.Env {
+	// Read the secrets the settings refer to, once the values are final
+	if resolve {
+		for _, key := range sortedKeys(configured) {
+			if !secrets.IsReference(envMap[key]) {
+				continue
+			}
+			value, err := resolveSecret(envMap[key])
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %s: %w", key, err)
+			}
 			envMap[key] = value
 		}
 	}
 
+	// Scripts in the executables directories can be called by name
+	envMap["PATH"] = PrependSearchPaths(cfg, envMap["PATH"])
+
 	// Convert map back to slice format expected by exec.Cmd
 	env := make([]string, 0, len(envMap))
 	for key, value := range envMap {
 		env = append(env, fmt.Sprintf("%s=%s", key, value))
 	}
 
-	return env
+	return env, nil
+}
+
+// ParseQuietHours parses an "HH:MM-HH:MM" local time window into minutes after
+// midnight. The window may wrap past midnight; an empty value means none.
+func ParseQuietHours(value string) (start, end int, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("'%s' is not an HH:MM-HH:MM window", value)
+	}
+	parse := func(clock string) (int, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return 0, fmt.Errorf("'%s' is not an HH:MM time", clock)
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if start, err = parse(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parse(to); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// InQuietHours reports whether now falls inside the quiet hours window
+func InQuietHours(value string, now time.Time) bool {
+	start, end, err := ParseQuietHours(value)
+	if err != nil || start == end {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// ExpandProjectVariables replaces ${PROJECT_PATH} and ${PROJECT_NAME} in value
+// with the resolved project's path and name. Outside a project context the
+// value is returned unchanged.
+func ExpandProjectVariables(value, projectName, projectPath string) string {
+	if projectName == "" && projectPath == "" {
+		return value
+	}
+	return strings.NewReplacer("${PROJECT_PATH}", projectPath, "${PROJECT_NAME}", projectName).Replace(value)
 }
 
 // GetConfigPath returns the path to the default config directory
 func GetConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	appDir, err := GetAppDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
 
-	return filepath.Join(
-		homeDir,
-		DefaultPathConfig.SettingsDir,
-		DefaultPathConfig.AppDir,
-		DefaultPathConfig.ConfigDir,
-	), nil
+	return filepath.Join(appDir, DefaultPathConfig.ConfigDir), nil
 }
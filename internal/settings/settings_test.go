@@ -2,11 +2,18 @@ package settings
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	pathutil "interop/internal/path"
+	"interop/internal/testutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // testEnv provides a way to create test settings
@@ -155,6 +162,157 @@ description = "Test project"
 	}
 }
 
+func TestLoadParseErrors(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("log_level = \"debug\"\n\n[commands.build]\ncmd = \"go build\"\n")
+	env.WriteFile("config.d/broken.toml", "[commands.lint]\ncmd = \"echo lint\n")
+	env.WriteFile("config.d/types.toml", "[prompts.review]\nname = 42\n")
+	env.WriteFile("config.d/team.toml", "[commands.deploy]\ncmd = \"echo deploy\"\n")
+
+	cfg, err := Reload()
+	if err == nil {
+		t.Fatal("Load() expected an error for broken config.d files")
+	}
+
+	var parseErr *ConfigParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Load() error = %v, want a *ConfigParseError", err)
+	}
+	if parseErr.File != filepath.Join(env.ConfigDir, "config.d", "broken.toml") || parseErr.Line != 2 {
+		t.Errorf("first parse error = %s:%d, want broken.toml:2", parseErr.File, parseErr.Line)
+	}
+	if !strings.Contains(err.Error(), "types.toml:2: incompatible types") {
+		t.Errorf("Load() error = %v, want the type error in types.toml too", err)
+	}
+
+	// The files that parsed are still loaded
+	for _, name := range []string{"build", "deploy"} {
+		if _, ok := cfg.Commands[name]; !ok {
+			t.Errorf("Expected command %q to be loaded", name)
+		}
+	}
+
+	// A broken settings.toml is reported the same way
+	env.WriteSettings("[commands.build\ncmd = \"go build\"\n")
+	if _, err := Reload(); !errors.As(err, &parseErr) || parseErr.File != filepath.Join(env.ConfigDir, "settings.toml") || parseErr.Line != 2 {
+		t.Errorf("Load() error = %v, want a parse error on line 2 of settings.toml", err)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("[commands.build]\ncmd = \"go build\"\n")
+	env.WriteFile("config.d/a.toml", "[commands.lint]\ncmd = \"golint\"\n\n[commands.lint]\ncmd = \"staticcheck\"\n")
+	env.WriteFile("config.d/b.toml", "[commands.test]\ncmd = \"go test\"\n")
+	env.WriteFile("config.d/c.toml", "[commands.test]\ncmd = \"go test -race\"\n\n[commands.build]\ncmd = \"make\"\n")
+
+	cfg, err := Reload()
+	var parseErr *ConfigParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Load() error = %v, want a *ConfigParseError", err)
+	}
+	if parseErr.Line != 4 || parseErr.Message != "duplicate key 'commands.lint', first defined on line 1" {
+		t.Errorf("parse error = line %d %q", parseErr.Line, parseErr.Message)
+	}
+
+	// Across files the first definition wins and both files are named
+	dir, err := loadConfigFromDirectory(filepath.Join(env.ConfigDir, "config.d"))
+	if err != nil {
+		t.Fatalf("loadConfigFromDirectory() error = %v", err)
+	}
+	if got := dir.Sources[sourceKey("command", "test")]; got != filepath.Join(env.ConfigDir, "config.d", "b.toml") {
+		t.Errorf("source of 'test' = %q, want b.toml", got)
+	}
+	if cfg.Commands["test"].Cmd != "go test" {
+		t.Errorf("Expected the first 'test' command to be kept, got %q", cfg.Commands["test"].Cmd)
+	}
+
+	_, conflicts, _ := mergeConfig(&Settings{Commands: map[string]CommandConfig{
+		"build": {Cmd: "go build", SourceFile: filepath.Join(env.ConfigDir, "settings.toml")},
+	}}, []CommandDir{{Path: filepath.Join(env.ConfigDir, "config.d")}})
+	want := fmt.Sprintf("Command 'build' in %s conflicts with the one in %s",
+		filepath.Join(env.ConfigDir, "config.d", "c.toml"), filepath.Join(env.ConfigDir, "settings.toml"))
+	if len(conflicts) != 1 || conflicts[0] != want {
+		t.Errorf("conflicts = %q, want [%q]", conflicts, want)
+	}
+}
+
+func TestFirstDefinition(t *testing.T) {
+	data := []byte(`# Tools
+[commands]
+lint = { cmd = "golint" }
+"test".cmd = "go test"
+
+[commands.build]
+cmd = "go build"
+cmd = "make"
+
+[[projects.app.commands]]
+command_name = "build"
+`)
+
+	tests := []struct {
+		key  string
+		line int
+		want int
+	}{
+		{"commands.lint", 12, 3},
+		{"commands.test", 12, 4},
+		{"commands.build", 12, 6},
+		{"commands.build.cmd", 8, 7},
+		{"commands.build.cmd", 7, 0},
+		{"commands.deploy", 12, 0},
+	}
+	for _, tt := range tests {
+		if got := firstDefinition(data, tt.key, tt.line); got != tt.want {
+			t.Errorf("firstDefinition(%q, %d) = %d, want %d", tt.key, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSourceLocations(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("[commands.build]\ncmd = \"go build\"\n\n[projects.app]\npath = \"~\"\n")
+	env.WriteFile("config.d/a.toml", "[commands.lint]\ncmd = \"golint\"\n\n[prompts.review]\nname = \"review\"\ncontent = \"Review\"\n")
+	env.WriteFile("config.d/b.toml", "\n[commands.lint]\ncmd = \"staticcheck\"\n\n[commands.build]\ncmd = \"make\"\n")
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		entity string
+		got    string
+		want   string
+	}{
+		{"command build", cfg.Commands["build"].Location(), "settings.toml:1"},
+		{"project app", cfg.Projects["app"].Location(), "settings.toml:4"},
+		{"command lint", cfg.Commands["lint"].Location(), "config.d/a.toml:1"},
+		{"prompt review", cfg.Prompts["review"].Location(), "config.d/a.toml:4"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("location of %s = %q, want %q", tt.entity, tt.got, tt.want)
+		}
+	}
+
+	// The definitions that lost are kept on the winner
+	if got := cfg.Commands["lint"].Shadowed; len(got) != 1 || got[0] != "config.d/b.toml:2" {
+		t.Errorf("lint shadows %q, want [config.d/b.toml:2]", got)
+	}
+	if got := cfg.Commands["build"].Shadowed; len(got) != 1 || got[0] != "config.d/b.toml:5" {
+		t.Errorf("build shadows %q, want [config.d/b.toml:5]", got)
+	}
+
+	if got := SourceLocation("/elsewhere/team.toml", 0); got != "/elsewhere/team.toml" {
+		t.Errorf("SourceLocation() outside the config directory = %q", got)
+	}
+	if got := SourceLocation("", 3); got != "" {
+		t.Errorf("SourceLocation() without a file = %q, want empty", got)
+	}
+}
+
 func TestGet(t *testing.T) {
 	env := setupTestEnv(t)
 	defer env.teardown(t)
@@ -246,6 +404,16 @@ description = "Path outside home"
 	}
 }
 
+// mergeForTest returns MergeEnvironmentVariables, failing the test on an error
+func mergeForTest(t *testing.T, cfg *Settings, commandName, projectName string) []string {
+	t.Helper()
+	env, err := MergeEnvironmentVariables(cfg, commandName, projectName)
+	if err != nil {
+		t.Fatalf("MergeEnvironmentVariables() error = %v", err)
+	}
+	return env
+}
+
 func TestMergeEnvironmentVariables(t *testing.T) {
 	// Save original environment and restore after test
 	originalEnv := os.Environ()
@@ -289,7 +457,7 @@ func TestMergeEnvironmentVariables(t *testing.T) {
 	}
 
 	// Test merging with all levels
-	env := MergeEnvironmentVariables(cfg, "test-command", "test-project")
+	env := mergeForTest(t, cfg, "test-command", "test-project")
 
 	// Convert to map for easier testing
 	envMap := make(map[string]string)
@@ -344,7 +512,7 @@ env = { CMD_VAR = "command" }
 	}
 
 	// Test with no project context
-	envVars := MergeEnvironmentVariables(settings, "test-cmd", "")
+	envVars := mergeForTest(t, settings, "test-cmd", "")
 
 	// Check that both global and command-level variables are present
 	found := make(map[string]bool)
@@ -365,6 +533,163 @@ env = { CMD_VAR = "command" }
 	}
 }
 
+func TestMergeEnvironmentVariablesProjectReferences(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := &Settings{
+		Env: map[string]string{"GLOBAL_REF": "${PROJECT_NAME}"},
+		Projects: map[string]Project{
+			"api": {
+				Path: "/work/api",
+				Env:  map[string]string{"GOFLAGS": "-modfile=${PROJECT_PATH}/go.local.mod"},
+			},
+		},
+		Commands: map[string]CommandConfig{
+			"build": {Env: map[string]string{"BUILD_TAG": "${PROJECT_NAME}-build"}},
+		},
+	}
+
+	envMap := make(map[string]string)
+	for _, e := range mergeForTest(t, cfg, "build", "api") {
+		key, value, _ := strings.Cut(e, "=")
+		envMap[key] = value
+	}
+
+	if envMap["GOFLAGS"] != "-modfile=/work/api/go.local.mod" {
+		t.Errorf("Expected project env to reference the project path, got %s", envMap["GOFLAGS"])
+	}
+	if envMap["BUILD_TAG"] != "api-build" {
+		t.Errorf("Expected command env to reference the project name, got %s", envMap["BUILD_TAG"])
+	}
+	if envMap["GLOBAL_REF"] != "${PROJECT_NAME}" {
+		t.Errorf("Expected global env to be left unexpanded, got %s", envMap["GLOBAL_REF"])
+	}
+
+	// Without a project context references are left for the shell
+	for _, e := range mergeForTest(t, cfg, "build", "") {
+		if e == "BUILD_TAG=${PROJECT_NAME}-build" {
+			return
+		}
+	}
+	t.Error("Expected command env to be unexpanded outside a project")
+}
+
+func TestMergeEnvironmentVariablesSearchPaths(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("[commands.report]\ncmd = \"my-helper.sh | jq .\"\n")
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	executables := filepath.Join(env.ConfigDir, "executables")
+	remoteExecutables := filepath.Join(env.ConfigDir, "executables.remote")
+	for _, dir := range []string{executables, remoteExecutables} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", "/usr/bin")
+
+	path := ""
+	for _, e := range mergeForTest(t, cfg, "report", "") {
+		if value, ok := strings.CutPrefix(e, "PATH="); ok {
+			path = value
+		}
+	}
+	want := strings.Join([]string{executables, remoteExecutables, "/usr/bin"}, string(os.PathListSeparator))
+	if path != want {
+		t.Errorf("PATH = %q, want %q", path, want)
+	}
+
+	// Nested runs don't add the directories again
+	if again := PrependSearchPaths(cfg, path); again != path {
+		t.Errorf("PrependSearchPaths() = %q, want %q unchanged", again, path)
+	}
+}
+
+func TestMergeEnvironmentVariablesEnvFilesAndSecrets(t *testing.T) {
+	env := testutil.New(t)
+	projectDir := env.Dir("api")
+	env.WriteFile("global.env", "STAGE=dev\nREGION=eu\n")
+	for name, content := range map[string]string{
+		".env":       "STAGE=staging\nDB_URL=postgres://${PROJECT_NAME}\n",
+		"deploy.env": "TOKEN=secret://keychain/DEPLOY_TOKEN\n",
+	} {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env.WriteSettings(fmt.Sprintf(`env_file = "global.env"
+
+[env]
+REGION = "us"
+
+[projects.api]
+path = %q
+env_file = ".env"
+
+[commands.deploy]
+cmd = "deploy"
+env_file = "deploy.env"
+env = { MISSING = "secret://keychain/NOT_SET", PLAIN = "value" }
+`, projectDir))
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	original := resolveSecret
+	defer func() { resolveSecret = original }()
+	stored := map[string]string{"secret://keychain/DEPLOY_TOKEN": "s3cr3t"}
+	resolveSecret = func(value string) (string, error) {
+		if secret, ok := stored[value]; ok {
+			return secret, nil
+		}
+		return "", fmt.Errorf("secret not found")
+	}
+	t.Setenv("TOKEN", "from-shell")
+
+	envMap := func(env []string) map[string]string {
+		m := make(map[string]string)
+		for _, e := range env {
+			key, value, _ := strings.Cut(e, "=")
+			m[key] = value
+		}
+		return m
+	}
+
+	if _, err := MergeEnvironmentVariables(cfg, "deploy", "api"); err == nil || !strings.Contains(err.Error(), "MISSING: secret not found") {
+		t.Errorf("Expected a secret that can't be read to fail the merge, got %v", err)
+	}
+
+	stored["secret://keychain/NOT_SET"] = "stored later"
+	got := envMap(mergeForTest(t, cfg, "deploy", "api"))
+	want := map[string]string{"STAGE": "staging", "REGION": "us", "DB_URL": "postgres://api", "TOKEN": "s3cr3t", "PLAIN": "value", "MISSING": "stored later"}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+
+	// Dry runs show the references rather than the secrets
+	preview := envMap(PreviewEnvironmentVariables(cfg, "deploy", "api"))
+	if preview["TOKEN"] != "secret://keychain/DEPLOY_TOKEN" || preview["MISSING"] != "secret://keychain/NOT_SET" {
+		t.Errorf("Expected unresolved references in the preview, got TOKEN=%q MISSING=%q", preview["TOKEN"], preview["MISSING"])
+	}
+
+	// Outside the project the command's env_file is taken from the config
+	// directory, where there is none, and the shell's TOKEN is kept
+	got = envMap(mergeForTest(t, cfg, "deploy", ""))
+	if got["TOKEN"] != "from-shell" || got["STAGE"] != "dev" {
+		t.Errorf("Expected TOKEN from the shell and the global STAGE, got TOKEN=%q STAGE=%q", got["TOKEN"], got["STAGE"])
+	}
+
+	if uses := SecretReferences(cfg); len(uses) != 1 || uses[0].Variable != "MISSING" || uses[0].Owner != "command 'deploy'" || uses[0].Reference != "secret://keychain/NOT_SET" {
+		t.Errorf("SecretReferences() = %+v", uses)
+	}
+}
+
 func TestCommandConfigHooksParsing(t *testing.T) {
 	env := setupTestEnv(t)
 	defer env.teardown(t)
@@ -414,12 +739,12 @@ pre_exec = ["echo 'single pre-hook'"]
 		t.Errorf("Expected 2 post-exec hooks, got %d", len(cmdWithHooks.PostExec))
 	}
 
-	if cmdWithHooks.PreExec[0] != "echo 'pre-hook 1'" {
-		t.Errorf("Expected first pre-exec hook to be 'echo 'pre-hook 1'', got '%s'", cmdWithHooks.PreExec[0])
+	if cmdWithHooks.PreExec[0].Cmd != "echo 'pre-hook 1'" {
+		t.Errorf("Expected first pre-exec hook to be 'echo 'pre-hook 1'', got '%s'", cmdWithHooks.PreExec[0].Cmd)
 	}
 
-	if cmdWithHooks.PostExec[1] != "echo 'post-hook 2'" {
-		t.Errorf("Expected second post-exec hook to be 'echo 'post-hook 2'', got '%s'", cmdWithHooks.PostExec[1])
+	if cmdWithHooks.PostExec[1].Cmd != "echo 'post-hook 2'" {
+		t.Errorf("Expected second post-exec hook to be 'echo 'post-hook 2'', got '%s'", cmdWithHooks.PostExec[1].Cmd)
 	}
 
 	// Test command without hooks
@@ -450,7 +775,1644 @@ pre_exec = ["echo 'single pre-hook'"]
 		t.Errorf("Expected 0 post-exec hooks, got %d", len(cmdWithSingleHook.PostExec))
 	}
 
-	if cmdWithSingleHook.PreExec[0] != "echo 'single pre-hook'" {
-		t.Errorf("Expected pre-exec hook to be 'echo 'single pre-hook'', got '%s'", cmdWithSingleHook.PreExec[0])
+	if cmdWithSingleHook.PreExec[0].Cmd != "echo 'single pre-hook'" {
+		t.Errorf("Expected pre-exec hook to be 'echo 'single pre-hook'', got '%s'", cmdWithSingleHook.PreExec[0].Cmd)
+	}
+}
+
+func TestIsProjectPathAllowed(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	tests := []struct {
+		name        string
+		roots       []string
+		projectPath string
+		want        bool
+	}{
+		{"default root allows home", nil, filepath.Join(homeDir, "code", "app"), true},
+		{"default root rejects outside home", nil, "/mnt/data/app", false},
+		{"configured root allows mounted volume", []string{"~", "/mnt/data"}, "/mnt/data/app", true},
+		{"configured root still allows home", []string{"~", "/mnt/data"}, filepath.Join(homeDir, "app"), true},
+		{"root itself is allowed", []string{"/mnt/data"}, "/mnt/data", true},
+		{"sibling with common prefix is rejected", []string{"/mnt/data"}, "/mnt/database/app", false},
+		{"home not allowed when not listed", []string{"/mnt/data"}, filepath.Join(homeDir, "app"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Settings{AllowedProjectRoots: tt.roots}
+			if got := cfg.IsProjectPathAllowed(tt.projectPath); got != tt.want {
+				t.Errorf("IsProjectPathAllowed(%q) = %v, want %v", tt.projectPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckProjectPath(t *testing.T) {
+	homeDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", homeDir)
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := filepath.Join(homeDir, "code", "app")
+	other := filepath.Join(homeDir, "code", "other")
+	for _, dir := range []string{filepath.Join(app, "pkg"), other} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(homeDir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Settings{Projects: map[string]Project{"app": {Path: "~/code/app"}}}
+
+	tests := []struct {
+		name           string
+		path           string
+		registeredOnly bool
+		want           string
+		reason         string
+	}{
+		{"directory under home", other, false, other, ""},
+		{"tilde path", "~/code/app", false, app, ""},
+		{"relative path", "code/app", false, "", ProjectPathRelative},
+		{"missing directory", filepath.Join(homeDir, "missing"), false, "", ProjectPathNotFound},
+		{"file", filepath.Join(homeDir, "notes.txt"), false, "", ProjectPathNotDirectory},
+		{"outside allowed roots", outside, false, "", ProjectPathOutsideRoots},
+		{"symlink leaving allowed roots", filepath.Join(homeDir, "escape"), false, "", ProjectPathOutsideRoots},
+		{"registered project", app, true, app, ""},
+		{"directory inside registered project", filepath.Join(app, "pkg"), true, filepath.Join(app, "pkg"), ""},
+		{"unregistered directory", other, true, "", ProjectPathUnregistered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.CheckProjectPath(tt.path, tt.registeredOnly)
+			if tt.reason == "" {
+				if err != nil || got != tt.want {
+					t.Errorf("CheckProjectPath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+				}
+				return
+			}
+			pathErr, ok := err.(*ProjectPathError)
+			if !ok || pathErr.Reason != tt.reason {
+				t.Errorf("CheckProjectPath(%q) error = %v, want reason %s", tt.path, err, tt.reason)
+			}
+		})
+	}
+
+	// Commands can turn project_path off or limit it to some projects
+	off := false
+	commandTests := []struct {
+		path   string
+		cmd    CommandConfig
+		reason string
+	}{
+		{filepath.Join(app, "pkg"), CommandConfig{AllowedProjects: []string{"app"}}, ""},
+		{other, CommandConfig{AllowedProjects: []string{"app"}}, ProjectPathNotAllowed},
+		{app, CommandConfig{AllowProjectPath: &off}, ProjectPathDisabled},
+		{outside, CommandConfig{AllowedProjects: []string{"app"}}, ProjectPathOutsideRoots},
+	}
+	for _, tt := range commandTests {
+		_, err := cfg.CheckCommandProjectPath(tt.path, tt.cmd, false)
+		if pathErr, ok := err.(*ProjectPathError); (tt.reason == "" && err != nil) || (tt.reason != "" && (!ok || pathErr.Reason != tt.reason)) {
+			t.Errorf("CheckCommandProjectPath(%q, %+v) error = %v, want reason %q", tt.path, tt.cmd, err, tt.reason)
+		}
+	}
+}
+
+func TestCheckProjectPathSymlinkedRoot(t *testing.T) {
+	realRoot, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(realRoot, "projx")
+	if err := os.Mkdir(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	linkRoot := filepath.Join(t.TempDir(), "linkroot")
+	if err := os.Symlink(realRoot, linkRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	// An allowed root given through a symlink, and a $HOME that is one
+	for name, cfg := range map[string]*Settings{
+		"allowed_project_roots": {AllowedProjectRoots: []string{linkRoot}},
+		"home":                  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("HOME", linkRoot)
+			for _, path := range []string{filepath.Join(linkRoot, "projx"), project} {
+				if got, err := cfg.CheckProjectPath(path, false); err != nil || got != project {
+					t.Errorf("CheckProjectPath(%q) = %q, %v, want %q", path, got, err, project)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandProjectGlobs(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	servicesDir := filepath.Join(homeDir, "mono", "services")
+	for _, dir := range []string{"api", "worker", "existing"} {
+		if err := os.MkdirAll(filepath.Join(servicesDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create service dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(servicesDir, "README.md"), []byte("docs"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	cfg := &Settings{
+		Projects: map[string]Project{
+			"services": {
+				Path:     "~/mono/services/*",
+				Commands: []Alias{{CommandName: "build", Alias: "b"}},
+				Env:      map[string]string{"STAGE": "dev"},
+			},
+			"existing": {Path: "~/elsewhere"},
+		},
+	}
+
+	projects := cfg.expandProjectGlobs()
+
+	if _, exists := projects["services"]; exists {
+		t.Error("Expected glob project to be replaced by its sub-projects")
+	}
+	if _, exists := projects["README.md"]; exists {
+		t.Error("Expected files matched by the glob to be ignored")
+	}
+	if projects["existing"].Path != "~/elsewhere" {
+		t.Error("Expected explicitly defined project to take precedence over expanded sub-project")
+	}
+
+	for _, name := range []string{"api", "worker"} {
+		project, exists := projects[name]
+		if !exists {
+			t.Fatalf("Expected sub-project '%s' to be expanded", name)
+		}
+		if project.Path != filepath.Join(servicesDir, name) {
+			t.Errorf("Expected path %s, got %s", filepath.Join(servicesDir, name), project.Path)
+		}
+		if project.Group != "services" {
+			t.Errorf("Expected group 'services', got '%s'", project.Group)
+		}
+		if len(project.Commands) != 1 || project.Commands[0].Alias != "b" {
+			t.Errorf("Expected sub-project '%s' to share command bindings", name)
+		}
+		if project.Env["STAGE"] != "dev" {
+			t.Errorf("Expected sub-project '%s' to share env", name)
+		}
+	}
+}
+
+func TestProjectMCPServers(t *testing.T) {
+	cfg := &Settings{
+		MCPPort: 8081,
+		MCPServers: map[string]MCPServer{
+			"docs": {Name: "docs", Description: "Docs", Port: 8082},
+			"web":  {Name: "web", Description: "Web tools", Port: 9000},
+		},
+		Projects: map[string]Project{
+			"api":    {Path: "~/api", MCPServer: true, Commands: []Alias{{CommandName: "build"}}},
+			"cli":    {Path: "~/cli", Description: "CLI repo", MCPServer: true},
+			"pinned": {Path: "~/pinned", MCPServer: true, MCPPort: 8083},
+			"web":    {Path: "~/web", MCPServer: true},
+			"plain":  {Path: "~/plain"},
+		},
+	}
+
+	servers := cfg.projectMCPServers()
+
+	wantPorts := map[string]int{"docs": 8082, "web": 9000, "api": 8084, "cli": 8085, "pinned": 8083}
+	if len(servers) != len(wantPorts) {
+		t.Errorf("Expected %d servers, got %v", len(wantPorts), servers)
+	}
+	for name, port := range wantPorts {
+		if servers[name].Port != port {
+			t.Errorf("Expected server '%s' on port %d, got %d", name, port, servers[name].Port)
+		}
+	}
+	if servers["api"].Project != "api" || servers["api"].Description != "Commands of project 'api'" {
+		t.Errorf("Unexpected generated server: %+v", servers["api"])
+	}
+	if servers["cli"].Description != "CLI repo" {
+		t.Errorf("Expected the project description, got '%s'", servers["cli"].Description)
+	}
+	if servers["web"].Project != "web" || servers["web"].Description != "Web tools" {
+		t.Errorf("Expected the configured server to serve the project, got %+v", servers["web"])
+	}
+	if servers["docs"].Project != "" {
+		t.Error("Expected servers without a project to stay unchanged")
+	}
+
+	cfg.MCPServers = servers
+	if err := ValidateMCPConfig(cfg); err != nil {
+		t.Errorf("Expected generated servers to validate, got %v", err)
+	}
+
+	tests := []struct {
+		server  string
+		command string
+		cmd     CommandConfig
+		want    bool
+	}{
+		{"api", "build", CommandConfig{}, true},
+		{"api", "lint", CommandConfig{}, false},
+		{"api", "lint", CommandConfig{MCP: ServerList{"api"}}, true},
+		{"", "build", CommandConfig{}, true},
+		{"docs", "build", CommandConfig{}, false},
+		{"", "lint", CommandConfig{MCP: ServerList{"docs"}}, false},
+		{"docs", "lint", CommandConfig{MCP: ServerList{"api", "docs"}}, true},
+	}
+	for _, tt := range tests {
+		if got := cfg.ServedBy(tt.server, tt.command, tt.cmd); got != tt.want {
+			t.Errorf("ServedBy(%q, %q, %+v) = %v, want %v", tt.server, tt.command, tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultMCPDisabled(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+default_mcp_enabled = false
+
+[mcp_servers.docs]
+name = "docs"
+description = "Docs"
+port = 8082
+
+[projects.api]
+path = "~/api"
+mcp_server = true
+`)
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	if cfg.DefaultMCPServerEnabled() {
+		t.Error("Expected the default MCP server to be disabled")
+	}
+	if cfg.MCPPort != 0 {
+		t.Errorf("Expected no default mcp_port, got %d", cfg.MCPPort)
+	}
+	// Generated servers start at the default port the default server leaves free
+	if port := cfg.MCPServers["api"].Port; port != DefaultMCPPort {
+		t.Errorf("Expected project server on port %d, got %d", DefaultMCPPort, port)
+	}
+	if err := ValidateMCPConfig(cfg); err != nil {
+		t.Errorf("ValidateMCPConfig() error = %v", err)
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	cfg := &Settings{Commands: map[string]CommandConfig{
+		"release":  {DependsOn: []string{"build", "lint"}},
+		"build":    {DependsOn: []string{"generate"}},
+		"lint":     {DependsOn: []string{"generate"}},
+		"generate": {},
+		"loop":     {DependsOn: []string{"again"}},
+		"again":    {DependsOn: []string{"loop"}},
+		"broken":   {DependsOn: []string{"build", "missing"}},
+	}}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{"release", "generate build lint", ""},
+		{"build", "generate", ""},
+		{"generate", "", ""},
+		{"loop", "", "cyclic depends_on: loop -> again -> loop"},
+		{"broken", "", "'broken' depends on undefined command 'missing'"},
+		{"missing", "", "command 'missing' not found"},
+	}
+	for _, tt := range tests {
+		order, err := cfg.DependencyOrder(tt.name)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("DependencyOrder(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || strings.Join(order, " ") != tt.want {
+			t.Errorf("DependencyOrder(%q) = %v, %v, want %q", tt.name, order, err, tt.want)
+		}
+	}
+}
+
+func TestApplyProjectTemplates(t *testing.T) {
+	cfg := &Settings{
+		ProjectTemplates: map[string]ProjectTemplate{
+			"go-service": {
+				Description: "Go service",
+				Commands: []Alias{
+					{CommandName: "build", Alias: "b"},
+					{CommandName: "test"},
+				},
+				Env: map[string]string{"GOFLAGS": "-mod=mod", "STAGE": "dev"},
+			},
+		},
+		Projects: map[string]Project{
+			"payments": {
+				Path:     "~/payments",
+				Extends:  "go-service",
+				Commands: []Alias{{CommandName: "build", Alias: "pb"}, {CommandName: "deploy"}},
+				Env:      map[string]string{"STAGE": "prod"},
+			},
+			"standalone": {Path: "~/standalone"},
+		},
+	}
+
+	projects := cfg.applyProjectTemplates()
+
+	payments := projects["payments"]
+	if payments.Description != "Go service" {
+		t.Errorf("Expected template description, got '%s'", payments.Description)
+	}
+
+	bindings := make(map[string]string)
+	for _, alias := range payments.Commands {
+		bindings[alias.CommandName] = alias.Alias
+	}
+	if len(payments.Commands) != 3 {
+		t.Errorf("Expected 3 command bindings, got %d", len(payments.Commands))
+	}
+	if bindings["build"] != "pb" {
+		t.Errorf("Expected project binding for 'build' to override template, got alias '%s'", bindings["build"])
+	}
+	if _, ok := bindings["test"]; !ok {
+		t.Error("Expected 'test' to be inherited from template")
+	}
+	if _, ok := bindings["deploy"]; !ok {
+		t.Error("Expected project-only 'deploy' binding to be kept")
+	}
+
+	if payments.Env["GOFLAGS"] != "-mod=mod" {
+		t.Errorf("Expected GOFLAGS to be inherited, got '%s'", payments.Env["GOFLAGS"])
+	}
+	if payments.Env["STAGE"] != "prod" {
+		t.Errorf("Expected project env to override template, got '%s'", payments.Env["STAGE"])
+	}
+
+	if len(projects["standalone"].Commands) != 0 {
+		t.Error("Expected project without extends to be unchanged")
+	}
+}
+
+func TestCommandExtends(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.teardown(t)
+
+	testContent := `
+[commands.k8s-deploy]
+cmd = "kubectl apply -f k8s/"
+description = "Deploy to kubernetes"
+tags = ["deploy", "k8s"]
+pre_exec = ["kubectl config current-context"]
+env = { KUBE_NAMESPACE = "default", KUBE_TIMEOUT = "60s" }
+arguments = [
+  { name = "replicas", type = "number", default = 1 },
+  { name = "image", type = "string", required = true }
+]
+
+[commands.k8s-deploy-staging]
+extends = "k8s-deploy"
+description = "Deploy to staging"
+env = { KUBE_NAMESPACE = "staging" }
+arguments = [
+  { name = "replicas", type = "number", default = 3 }
+]
+
+[commands.loop-a]
+extends = "loop-b"
+
+[commands.loop-b]
+extends = "loop-a"
+`
+	env.createTestSettings(t, testContent)
+
+	settings, err := Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+
+	staging := settings.Commands["k8s-deploy-staging"]
+	if staging.Cmd != "kubectl apply -f k8s/" {
+		t.Errorf("Expected cmd to be inherited, got '%s'", staging.Cmd)
+	}
+	if staging.Description != "Deploy to staging" {
+		t.Errorf("Expected description override, got '%s'", staging.Description)
+	}
+	if len(staging.PreExec) != 1 {
+		t.Errorf("Expected pre_exec hooks to be inherited, got %d", len(staging.PreExec))
+	}
+	if strings.Join(staging.Tags, ",") != "deploy,k8s" {
+		t.Errorf("Expected tags to be inherited, got %v", staging.Tags)
+	}
+	if staging.Env["KUBE_NAMESPACE"] != "staging" || staging.Env["KUBE_TIMEOUT"] != "60s" {
+		t.Errorf("Expected env to be merged, got %v", staging.Env)
+	}
+	if len(staging.Arguments) != 2 {
+		t.Fatalf("Expected 2 arguments after merge, got %d", len(staging.Arguments))
+	}
+	if staging.Arguments[0].Name != "replicas" || staging.Arguments[0].Default != int64(3) {
+		t.Errorf("Expected 'replicas' default to be overridden to 3, got %v", staging.Arguments[0].Default)
+	}
+	if staging.Arguments[1].Name != "image" || !staging.Arguments[1].Required {
+		t.Errorf("Expected 'image' argument to be inherited")
+	}
+
+	if settings.Commands["loop-a"].Cmd != "" || settings.Commands["loop-b"].Cmd != "" {
+		t.Error("Expected cyclic commands to be left unresolved")
+	}
+}
+
+func TestArgumentValues(t *testing.T) {
+	cmd := CommandConfig{
+		Arguments: []CommandArgument{
+			{Name: "env", Type: ArgumentTypeString, Required: true},
+			{Name: "replicas", Type: ArgumentTypeNumber, Default: int64(2)},
+			{Name: "ratio", Type: ArgumentTypeNumber},
+			{Name: "dry_run", Type: ArgumentTypeBool, Default: false},
+			{Name: "region", Type: ArgumentTypeString, Required: true, Default: "eu"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		provided map[string]interface{}
+		want     map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "defaults",
+			provided: map[string]interface{}{"env": "prod"},
+			want:     map[string]string{"env": "prod", "replicas": "2", "dry_run": "false", "region": "eu"},
+		},
+		{
+			name:     "values given as text",
+			provided: map[string]interface{}{"env": "prod", "replicas": " 3 ", "ratio": "0.5", "dry_run": "1", "region": "us"},
+			want:     map[string]string{"env": "prod", "replicas": "3", "ratio": "0.5", "dry_run": "true", "region": "us"},
+		},
+		{
+			name:     "typed values",
+			provided: map[string]interface{}{"env": "prod", "replicas": float64(4), "ratio": 1.25, "dry_run": true, "undefined": "ignored"},
+			want:     map[string]string{"env": "prod", "replicas": "4", "ratio": "1.25", "dry_run": "true", "region": "eu"},
+		},
+		{
+			name:     "missing required",
+			provided: map[string]interface{}{"replicas": "3"},
+			wantErr:  "required argument 'env' is missing",
+		},
+		{
+			name:     "not a number",
+			provided: map[string]interface{}{"env": "prod", "replicas": "many"},
+			wantErr:  "argument 'replicas' must be a number, got 'many'",
+		},
+		{
+			name:     "not a bool",
+			provided: map[string]interface{}{"env": "prod", "dry_run": "maybe"},
+			wantErr:  "argument 'dry_run' must be true or false, got 'maybe'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cmd.ArgumentValues(tt.provided)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ArgumentValues() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ArgumentValues() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ArgumentValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandNotifications(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.release]
+cmd = "make release"
+notify_after = "30s"
+notify_bell = true
+
+[commands.release-staging]
+extends = "release"
+cmd = "make release STAGE=staging"
+
+[commands.deploy]
+cmd = "make deploy"
+notify = true
+
+[commands.build]
+cmd = "make"
+`)
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		after   time.Duration
+		enabled bool
+		bell    bool
+	}{
+		{"release", 30 * time.Second, true, true},
+		{"release-staging", 30 * time.Second, true, true},
+		{"deploy", 0, true, false},
+		{"build", 0, false, false},
+	}
+	for _, tt := range tests {
+		cmd := cfg.Commands[tt.name]
+		after, enabled, err := cmd.NotifyThreshold()
+		if err != nil || after != tt.after || enabled != tt.enabled || cmd.NotifyBell != tt.bell {
+			t.Errorf("%s: threshold = %v, %v, %v, bell %v; want %v, %v, bell %v",
+				tt.name, after, enabled, err, cmd.NotifyBell, tt.after, tt.enabled, tt.bell)
+		}
+	}
+
+	for _, value := range []string{"soon", "-5s"} {
+		if _, _, err := (CommandConfig{NotifyAfter: value}).NotifyThreshold(); err == nil {
+			t.Errorf("Expected an error for notify_after %q", value)
+		}
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.test]
+cmd = "go test ./..."
+timeout = "5m"
+
+[commands.test-race]
+extends = "test"
+cmd = "go test -race ./..."
+
+[commands.build]
+cmd = "make"
+`)
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := map[string]time.Duration{
+		"test":      5 * time.Minute,
+		"test-race": 5 * time.Minute,
+		"build":     0,
+	}
+	for name, want := range tests {
+		if got, err := cfg.Commands[name].RunTimeout(); err != nil || got != want {
+			t.Errorf("%s: RunTimeout() = %v, %v; want %v", name, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"soon", "0s", "-5s"} {
+		if _, err := (CommandConfig{Timeout: value}).RunTimeout(); err == nil {
+			t.Errorf("Expected an error for timeout %q", value)
+		}
+	}
+}
+
+func TestCommandWatch(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.test]
+cmd = "go test ./..."
+watch = ["**/*.go", "go.mod"]
+
+[commands.test-race]
+extends = "test"
+cmd = "go test -race ./..."
+
+[commands.lint]
+cmd = "golangci-lint run"
+watch = "*.go"
+`)
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := map[string]string{
+		"test":      "**/*.go go.mod",
+		"test-race": "**/*.go go.mod",
+		"lint":      "*.go",
+	}
+	for name, want := range tests {
+		if got := strings.Join(cfg.Commands[name].Watch, " "); got != want {
+			t.Errorf("%s: watch = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCommandServerList(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[mcp_servers.build]
+name = "build"
+description = "Build tools"
+port = 8091
+
+[mcp_servers.ci]
+name = "ci"
+description = "Ci tools"
+port = 8092
+
+[commands.test]
+cmd = "go test ./..."
+mcp = ["build", "ci"]
+
+[commands.lint]
+cmd = "golangci-lint run"
+mcp = "ci"
+
+[prompts.review]
+name = "review"
+description = "Review a change"
+content = "Review it"
+mcp = ["build", "ci"]
+`)
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Commands["test"].MCP; len(got) != 2 || !got.Includes("build") || !got.Includes("ci") {
+		t.Errorf("test: mcp = %v, want build and ci", got)
+	}
+	if got := cfg.Commands["lint"].MCP; got.String() != "ci" || got.Includes("") {
+		t.Errorf("lint: mcp = %v, want ci", got)
+	}
+	if got := cfg.Prompts["review"].MCP; got.String() != "build, ci" {
+		t.Errorf("review: mcp = %v, want build and ci", got)
+	}
+
+	// A single server is written back as a string, a list as an array
+	for list, want := range map[string]string{"ci": `"ci"`, "build,ci": `["build","ci"]`} {
+		data, err := ServerList(strings.Split(list, ",")).MarshalTOML()
+		if err != nil || string(data) != want {
+			t.Errorf("MarshalTOML(%s) = %s, %v, want %s", list, data, err, want)
+		}
+	}
+
+	cfg.Commands["lint"] = CommandConfig{MCP: ServerList{"ci", "missing"}}
+	if err := ValidateMCPConfig(cfg); err == nil || !strings.Contains(err.Error(), "non-existent MCP server 'missing'") {
+		t.Errorf("Expected an unknown server in the list to fail validation, got %v", err)
+	}
+}
+
+func TestCommandScript(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.report]
+interpreter = "python3"
+script = '''
+import sys
+print(sys.argv[1:])
+'''
+
+[commands.report-verbose]
+extends = "report"
+description = "Report with details"
+
+[commands.report-cmd]
+extends = "report"
+cmd = "make report"
+`)
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := cfg.Commands["report"].Script; got != "import sys\nprint(sys.argv[1:])\n" {
+		t.Errorf("report: script = %q", got)
+	}
+	if got := cfg.Commands["report-verbose"]; got.Script != cfg.Commands["report"].Script || got.Interpreter != "python3" {
+		t.Errorf("report-verbose: script = %q, interpreter = %q; want them inherited", got.Script, got.Interpreter)
+	}
+	if got := cfg.Commands["report-cmd"]; got.Script != "" || got.Cmd != "make report" {
+		t.Errorf("report-cmd: script = %q, cmd = %q; want only its own cmd", got.Script, got.Cmd)
+	}
+}
+
+func TestCommandCaching(t *testing.T) {
+	tests := []struct {
+		cmd     CommandConfig
+		ttl     time.Duration
+		enabled bool
+	}{
+		{CommandConfig{}, DefaultCacheTTL, false},
+		{CommandConfig{Cache: true}, DefaultCacheTTL, true},
+		{CommandConfig{CacheTTL: "30m"}, 30 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		ttl, enabled, err := tt.cmd.CacheDuration()
+		if err != nil || ttl != tt.ttl || enabled != tt.enabled {
+			t.Errorf("%+v: CacheDuration() = %v, %v, %v; want %v, %v", tt.cmd, ttl, enabled, err, tt.ttl, tt.enabled)
+		}
+	}
+
+	for _, value := range []string{"later", "0s", "-1m"} {
+		if _, _, err := (CommandConfig{CacheTTL: value}).CacheDuration(); err == nil {
+			t.Errorf("Expected an error for cache_ttl %q", value)
+		}
+	}
+}
+
+func TestCommandConfigHookCaptureParsing(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.teardown(t)
+
+	testContent := `
+[commands.tag]
+cmd = "docker tag app app:${hook:sha}"
+pre_exec = [
+  "echo 'plain hook'",
+  { cmd = "git rev-parse --short HEAD", capture = "sha" }
+]
+`
+	env.createTestSettings(t, testContent)
+
+	settings, err := Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+
+	cmd := settings.Commands["tag"]
+	if len(cmd.PreExec) != 2 {
+		t.Fatalf("Expected 2 pre-exec hooks, got %d", len(cmd.PreExec))
+	}
+	if cmd.PreExec[0].Cmd != "echo 'plain hook'" || cmd.PreExec[0].Capture != "" {
+		t.Errorf("Expected plain string hook without capture, got %+v", cmd.PreExec[0])
+	}
+	if cmd.PreExec[1].Cmd != "git rev-parse --short HEAD" || cmd.PreExec[1].Capture != "sha" {
+		t.Errorf("Expected table hook capturing 'sha', got %+v", cmd.PreExec[1])
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	cfg := &Settings{
+		LogLevel: "error",
+		MCPPort:  8081,
+		Commands: map[string]CommandConfig{
+			"build": {Cmd: "go build ./...", IsEnabled: true},
+		},
+		Projects: map[string]Project{
+			"app": {Path: "~/app"},
+		},
+	}
+
+	cfg.applyEnvOverrides([]string{
+		"INTEROP_LOG_LEVEL=verbose",
+		"INTEROP_MCP_PORT=9090",
+		"INTEROP_SETTINGS__commands__build__is_enabled=false",
+		"INTEROP_SETTINGS__projects__app__env__STAGE=ci",
+		"INTEROP_SETTINGS__EXECUTABLE_SEARCH_PATHS=/opt/bin, /usr/local/bin",
+		"INTEROP_SETTINGS__commands__missing__cmd=echo",
+		"INTEROP_SETTINGS__mcp_port=not-a-number",
+		"INTEROP_SETTINGS__unknown=value",
+		"UNRELATED=value",
+	})
+
+	if cfg.LogLevel != "verbose" {
+		t.Errorf("Expected log level 'verbose', got '%s'", cfg.LogLevel)
+	}
+	if cfg.MCPPort != 9090 {
+		t.Errorf("Expected INTEROP_MCP_PORT to win over the generic override, got %d", cfg.MCPPort)
+	}
+	if cfg.Commands["build"].IsEnabled {
+		t.Error("Expected 'build' to be disabled by override")
+	}
+	if cfg.Commands["build"].Cmd != "go build ./..." {
+		t.Errorf("Expected other command fields to be kept, got cmd '%s'", cfg.Commands["build"].Cmd)
+	}
+	if cfg.Projects["app"].Env["STAGE"] != "ci" {
+		t.Errorf("Expected project env override, got %v", cfg.Projects["app"].Env)
+	}
+	if len(cfg.ExecutableSearchPaths) != 2 || cfg.ExecutableSearchPaths[1] != "/usr/local/bin" {
+		t.Errorf("Expected search paths from comma separated list, got %v", cfg.ExecutableSearchPaths)
+	}
+	if _, exists := cfg.Commands["missing"]; exists {
+		t.Error("Expected override of an undefined command to be ignored")
+	}
+}
+
+func TestEnvOverridesOfExtendingCommand(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.teardown(t)
+
+	env.createTestSettings(t, `
+[commands.base]
+cmd = "make"
+description = "base desc"
+is_enabled = true
+
+[commands.build]
+extends = "base"
+`)
+	t.Setenv("INTEROP_SETTINGS__commands__build__description", "overridden")
+	t.Setenv("INTEROP_SETTINGS__commands__build__is_enabled", "false")
+	t.Setenv("INTEROP_SETTINGS__commands__base__cmd", "make all")
+
+	settings, err := Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	build := settings.Commands["build"]
+	if build.Description != "overridden" || build.IsEnabled {
+		t.Errorf("Expected the overrides of 'build' to win over 'base', got description '%s', enabled %v", build.Description, build.IsEnabled)
+	}
+	if build.Cmd != "make all" {
+		t.Errorf("Expected 'build' to inherit the overridden cmd of 'base', got '%s'", build.Cmd)
+	}
+}
+
+func TestMergeConfigOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	content := `overrides = ["deploy"]
+
+[commands.deploy]
+cmd = "echo remote deploy"
+
+[commands.lint]
+cmd = "echo remote lint"
+`
+	if err := os.WriteFile(filepath.Join(dir, "remote.toml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	main := &Settings{
+		Commands: map[string]CommandConfig{
+			"deploy": {Cmd: "echo local deploy"},
+			"lint":   {Cmd: "echo local lint"},
+		},
+	}
+
+	merged, conflicts, err := mergeConfig(main, []CommandDir{{Path: dir}})
+	if err != nil {
+		t.Fatalf("mergeConfig() error = %v", err)
+	}
+
+	if merged.Commands["deploy"].Cmd != "echo remote deploy" {
+		t.Errorf("Expected overridden command from directory, got '%s'", merged.Commands["deploy"].Cmd)
+	}
+	if merged.Commands["lint"].Cmd != "echo local lint" {
+		t.Errorf("Expected main settings to win without override, got '%s'", merged.Commands["lint"].Cmd)
+	}
+	if len(conflicts) != 1 || !strings.Contains(conflicts[0], "lint") {
+		t.Errorf("Expected a single conflict for 'lint', got %v", conflicts)
+	}
+	if source := merged.Commands["deploy"].SourceFile; source != filepath.Join(dir, "remote.toml") {
+		t.Errorf("Expected overridden command to record its source file, got '%s'", source)
+	}
+}
+
+func TestPromptRender(t *testing.T) {
+	prompt := PromptConfig{
+		Name:    "review",
+		Content: "Review {file} with depth {depth}, strict: {strict}",
+		Arguments: []CommandArgument{
+			{Name: "file", Required: true},
+			{Name: "depth", Type: ArgumentTypeNumber, Default: 2},
+			{Name: "strict", Type: ArgumentTypeBool},
+		},
+	}
+
+	content, err := prompt.Render(map[string]string{"file": "main.go", "strict": "true"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if content != "Review main.go with depth 2, strict: true" {
+		t.Errorf("Render() = %q", content)
+	}
+
+	content, err = prompt.Render(map[string]string{"file": "main.go", "depth": "3.5"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if content != "Review main.go with depth 3.5, strict: {strict}" {
+		t.Errorf("Expected unset optional placeholder to be kept, got %q", content)
+	}
+
+	if _, err := prompt.Render(nil); err == nil {
+		t.Error("Render() expected an error for a missing required argument")
+	}
+}
+
+func TestPromptChain(t *testing.T) {
+	prompts := map[string]PromptConfig{
+		"context": {Name: "context", Content: "You work on {project}.", Arguments: []CommandArgument{{Name: "project", Required: true}}},
+		"style":   {Name: "style", Content: "Write in {tone} tone.", Includes: []string{"context"}, Arguments: []CommandArgument{{Name: "tone", Default: "neutral"}}},
+		"mr": {
+			Name:      "mr",
+			Content:   "Open a merge request for {branch}.",
+			Includes:  []string{"context", "style"},
+			Arguments: []CommandArgument{{Name: "branch", Required: true}, {Name: "tone", Default: "friendly"}},
+		},
+		"loop-a":  {Name: "loop-a", Includes: []string{"loop-b"}},
+		"loop-b":  {Name: "loop-b", Includes: []string{"loop-a"}},
+		"dangles": {Name: "dangles", Includes: []string{"missing"}},
+	}
+
+	texts, err := RenderPromptChain(prompts, "mr", map[string]string{"project": "interop", "branch": "fix-cache"})
+	if err != nil {
+		t.Fatalf("RenderPromptChain() error = %v", err)
+	}
+	want := "You work on interop.|Write in neutral tone.|Open a merge request for fix-cache."
+	if got := strings.Join(texts, "|"); got != want {
+		t.Errorf("RenderPromptChain() = %q, want %q", got, want)
+	}
+
+	args, err := PromptChainArguments(prompts, "mr")
+	if err != nil {
+		t.Fatalf("PromptChainArguments() error = %v", err)
+	}
+	var names []string
+	for _, arg := range args {
+		names = append(names, fmt.Sprintf("%s=%v", arg.Name, arg.Default))
+	}
+	if got := strings.Join(names, " "); got != "branch=<nil> tone=friendly project=<nil>" {
+		t.Errorf("PromptChainArguments() = %s, want the prompt's own arguments first", got)
+	}
+
+	if _, err := RenderPromptChain(prompts, "mr", map[string]string{"branch": "main"}); err == nil || !strings.Contains(err.Error(), "included prompt 'context'") {
+		t.Errorf("RenderPromptChain() without an included prompt's argument error = %v", err)
+	}
+	if _, err := PromptChain(prompts, "loop-a"); err == nil || !strings.Contains(err.Error(), "loop-a -> loop-b -> loop-a") {
+		t.Errorf("PromptChain() for a cycle error = %v", err)
+	}
+	if _, err := PromptChain(prompts, "dangles"); err == nil || !strings.Contains(err.Error(), "non-existent prompt 'missing'") {
+		t.Errorf("PromptChain() for a missing include error = %v", err)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return parsed
+	}
+
+	tests := []struct {
+		window string
+		now    string
+		want   bool
+	}{
+		{"", "03:00", false},
+		{"09:00-17:00", "08:59", false},
+		{"09:00-17:00", "09:00", true},
+		{"09:00-17:00", "17:00", false},
+		{"22:00-07:00", "23:30", true},
+		{"22:00-07:00", "06:59", true},
+		{"22:00-07:00", "12:00", false},
+		{"bogus", "12:00", false},
+	}
+	for _, tt := range tests {
+		if got := InQuietHours(tt.window, at(tt.now)); got != tt.want {
+			t.Errorf("InQuietHours(%q, %s) = %v, want %v", tt.window, tt.now, got, tt.want)
+		}
+	}
+
+	if _, _, err := ParseQuietHours("22:00"); err == nil {
+		t.Error("ParseQuietHours() expected an error for a missing end time")
+	}
+	if _, _, err := ParseQuietHours("25:00-07:00"); err == nil {
+		t.Error("ParseQuietHours() expected an error for an invalid time")
+	}
+}
+
+func TestToolTimeoutLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultMaxToolTimeout, false},
+		{"1h", time.Hour, false},
+		{"0s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		cfg := &Settings{MaxToolTimeout: tt.value}
+		got, err := cfg.ToolTimeoutLimit()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ToolTimeoutLimit(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSetCommandEnabledInFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.toml")
+	content := `# My commands
+[commands.build]
+cmd = "make"  # keep this comment
+is_enabled = true
+
+[[commands.build.arguments]]
+name = "target"
+is_enabled = "not the command"
+
+[commands.deploy]
+cmd = "deploy.sh"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+
+	if err := setCommandEnabledInFile(path, "build", false); err != nil {
+		t.Fatalf("setCommandEnabledInFile() error = %v", err)
+	}
+	if err := setCommandEnabledInFile(path, "deploy", false); err != nil {
+		t.Fatalf("setCommandEnabledInFile() error = %v", err)
+	}
+
+	updated, _ := os.ReadFile(path)
+	want := strings.Replace(content, "is_enabled = true", "is_enabled = false", 1)
+	want = strings.Replace(want, "[commands.deploy]\n", "[commands.deploy]\nis_enabled = false\n", 1)
+	if string(updated) != want {
+		t.Errorf("Unexpected file content:\n%s", updated)
+	}
+
+	if err := setCommandEnabledInFile(path, "missing", true); err == nil {
+		t.Error("setCommandEnabledInFile() expected an error for an undefined command")
+	}
+}
+
+func TestSetConfigDir(t *testing.T) {
+	t.Setenv(ConfigDirEnvVar, "")
+	dir := t.TempDir()
+	t.Cleanup(func() { pathutil.SetAppDirOverride("") })
+
+	if err := SetConfigDir(dir); err != nil {
+		t.Fatalf("SetConfigDir() error = %v", err)
+	}
+	if appDir, err := GetAppDir(); err != nil || appDir != dir {
+		t.Errorf("GetAppDir() = %q, %v; want %q", appDir, err, dir)
+	}
+	if ConfigDir() != dir {
+		t.Errorf("ConfigDir() = %q, want %q", ConfigDir(), dir)
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	dir := t.TempDir()
+	pathutil.SetAppDirOverride(dir)
+	t.Cleanup(func() { pathutil.SetAppDirOverride("") })
+
+	if stateDir, err := StateDir("jobs"); err != nil || stateDir != filepath.Join(dir, "jobs") {
+		t.Errorf("StateDir() = %q, %v; want %q", stateDir, err, filepath.Join(dir, "jobs"))
+	}
+
+	// Sandboxes get their own temporary directory, the same for every run
+	sandboxDir = dir
+	t.Cleanup(func() { sandboxDir = "" })
+	first, err := StateDir("jobs")
+	if err != nil || !strings.HasPrefix(first, filepath.Join(os.TempDir(), "interop-jobs-")) {
+		t.Errorf("sandboxed StateDir() = %q, %v; want a temporary directory", first, err)
+	}
+	if second, _ := StateDir("jobs"); second != first {
+		t.Errorf("sandboxed StateDir() = %q, then %q", first, second)
+	}
+	sandboxDir = filepath.Join(dir, "other")
+	if other, _ := StateDir("jobs"); other == first {
+		t.Errorf("sandboxed StateDir() = %q for two sandboxes", other)
+	}
+}
+
+func TestResolveProjectCommands(t *testing.T) {
+	cfg := &Settings{
+		Projects: map[string]Project{
+			"app": {Commands: []Alias{{CommandName: "build"}, {CommandName: "gone", Alias: "g"}, {CommandName: "test", Alias: "t"}}},
+		},
+		Commands: map[string]CommandConfig{
+			"build": {Cmd: "make", IsEnabled: true},
+			"test":  {Cmd: "make test"},
+		},
+	}
+
+	commands, err := ResolveProjectCommands(cfg, "app")
+	if err != nil {
+		t.Fatalf("ResolveProjectCommands() error = %v", err)
+	}
+	var got []string
+	for _, cmd := range commands {
+		got = append(got, fmt.Sprintf("%s:%v", cmd.Name(), cmd.Missing))
+	}
+	if want := "build:false g:true t:false"; strings.Join(got, " ") != want {
+		t.Errorf("ResolveProjectCommands() = %v, want %s", got, want)
+	}
+
+	byName, err := GetProjectCommands(cfg, "app")
+	if err != nil || len(byName) != 2 || byName["t"].Cmd != "make test" {
+		t.Errorf("GetProjectCommands() = %v, %v; want build and t", byName, err)
+	}
+	if _, err := ResolveProjectCommands(cfg, "other"); err == nil {
+		t.Error("Expected an error for an unknown project")
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("[commands.build]\ncmd = \"go build\"\n")
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	fingerprint := ConfigFingerprint(cfg)
+	if fingerprint == "" {
+		t.Fatal("ConfigFingerprint() is empty")
+	}
+	if again := ConfigFingerprint(cfg); again != fingerprint {
+		t.Errorf("ConfigFingerprint() changed without a change to the files")
+	}
+
+	env.WriteFile("config.d/team.toml", "[commands.deploy]\ncmd = \"echo deploy\"\n")
+	added := ConfigFingerprint(cfg)
+	if added == fingerprint {
+		t.Error("ConfigFingerprint() didn't change when a config.d file was added")
+	}
+	if !strings.Contains(added, filepath.Join(env.ConfigDir, "config.d", "team.toml")) {
+		t.Errorf("ConfigFingerprint() = %q, want it to include team.toml", added)
+	}
+
+	env.WriteSettings("[commands.build]\ncmd = \"go build ./...\"\n")
+	if ConfigFingerprint(cfg) == added {
+		t.Error("ConfigFingerprint() didn't change when settings.toml was saved")
+	}
+}
+
+func TestEnterInvocation(t *testing.T) {
+	t.Setenv(DepthEnvVar, "")
+	t.Setenv(BinEnvVar, "")
+
+	if err := EnterInvocation(); err != nil {
+		t.Fatalf("EnterInvocation() error = %v", err)
+	}
+	if Depth() != 1 {
+		t.Errorf("Depth() = %d after the first invocation, want 1", Depth())
+	}
+	if bin := os.Getenv(BinEnvVar); !filepath.IsAbs(bin) {
+		t.Errorf("%s = %q, want the absolute path of the binary", BinEnvVar, bin)
+	}
+
+	t.Setenv(DepthEnvVar, strconv.Itoa(MaxDepth-1))
+	if err := EnterInvocation(); err != nil {
+		t.Errorf("EnterInvocation() at depth %d error = %v", MaxDepth-1, err)
+	}
+	if err := EnterInvocation(); err == nil || !strings.Contains(err.Error(), "calls itself") {
+		t.Errorf("EnterInvocation() at depth %d error = %v, want the recursion reported", MaxDepth, err)
+	}
+}
+
+func TestCommandDirPrefix(t *testing.T) {
+	env := testutil.New(t)
+	personal := env.Dir("cmds")
+	team := env.Dir("team/cmds")
+	teamFile := filepath.Join(team, "deploy.toml")
+	files := map[string]string{
+		filepath.Join(personal, "mine.toml"): "[commands.lint]\ncmd = \"echo mine\"\n",
+		teamFile: `[commands.deploy]
+cmd = "echo team"
+
+[commands.deploy-staging]
+extends = "deploy"
+
+[commands.lint]
+cmd = "echo team lint"
+
+[projects.api]
+path = "~/api"
+commands = [{ command_name = "deploy" }, { command_name = "build" }]
+`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env.WriteSettings(fmt.Sprintf("command_dirs = [%q, { path = %q, prefix = \"team\" }]\n\n[commands.build]\ncmd = \"make\"\n", personal, team))
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	want := map[string]string{
+		"build":               "make",
+		"lint":                "echo mine",
+		"team:lint":           "echo team lint",
+		"team:deploy":         "echo team",
+		"team:deploy-staging": "echo team",
+	}
+	for name, cmd := range want {
+		if got := cfg.Commands[name].Cmd; got != cmd {
+			t.Errorf("Command %q cmd = %q, want %q", name, got, cmd)
+		}
+	}
+	if _, ok := cfg.Commands["deploy"]; ok {
+		t.Error("Expected the team's deploy command to be loaded under its prefix only")
+	}
+	if source := cfg.Commands["team:deploy"].SourceFile; source != teamFile {
+		t.Errorf("team:deploy source = %q, want %q", source, teamFile)
+	}
+
+	// The directory's own references follow the rename, others don't
+	var refs []string
+	for _, alias := range cfg.Projects["api"].Commands {
+		refs = append(refs, alias.CommandName)
+	}
+	if strings.Join(refs, ",") != "team:deploy,build" {
+		t.Errorf("Project api commands = %v, want team:deploy and build", refs)
+	}
+
+	// Toggling a namespaced command edits its table in the directory
+	if path, err := SetCommandEnabled("team:lint", false); err != nil || path != teamFile {
+		t.Fatalf("SetCommandEnabled() = %q, %v; want %q", path, err, teamFile)
+	}
+	content, _ := os.ReadFile(teamFile)
+	if !strings.Contains(string(content), "[commands.lint]\nis_enabled = false\n") {
+		t.Errorf("Expected [commands.lint] to be disabled, got:\n%s", content)
+	}
+
+	env.WriteSettings("command_dirs = [{ prefix = \"team\" }]\n")
+	if _, err := Reload(); err == nil {
+		t.Error("Expected an error for a command_dirs entry without a path")
+	}
+}
+
+func TestAppendStarter(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("# My settings\n[commands.build]\ncmd = \"make\"")
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	invalid := []Starter{
+		{},
+		{Command: "build", Cmd: "make"},
+		{Command: "my command", Cmd: "ls"},
+		{Command: "test"},
+		{Project: "app"},
+	}
+	for _, starter := range invalid {
+		if err := starter.Validate(cfg); err == nil {
+			t.Errorf("Expected %+v to be rejected", starter)
+		}
+	}
+
+	starter := Starter{
+		Project:            "app",
+		ProjectPath:        "~/app",
+		Command:            "test",
+		Cmd:                `go test "./..."`,
+		CommandDescription: "Run the tests",
+		Alias:              "t",
+	}
+	if err := starter.Validate(cfg); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	path, err := AppendStarter(starter)
+	if err != nil {
+		t.Fatalf("AppendStarter() error = %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(content), "# My settings\n[commands.build]\ncmd = \"make\"\n\n[commands.test]\n") {
+		t.Errorf("Expected the starter after the existing content, got:\n%s", content)
+	}
+
+	cfg, err = Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := cfg.Commands["test"].Cmd; got != `go test "./..."` {
+		t.Errorf("test cmd = %q", got)
+	}
+	commands := cfg.Projects["app"].Commands
+	if len(commands) != 1 || commands[0] != (Alias{CommandName: "test", Alias: "t"}) {
+		t.Errorf("Project app commands = %+v", commands)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	env := testutil.New(t)
+
+	// First use writes the full template
+	if _, err := Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	path := filepath.Join(env.ConfigDir, "settings.toml")
+	content, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(content), "# Interop Settings Template\n") || !strings.Contains(string(content), "END OF TEMPLATE") {
+		t.Errorf("Expected the full template on first use, got:\n%s", content)
+	}
+
+	if _, err := RenderTemplate(TemplateOptions{Kind: "huge"}); err == nil {
+		t.Error("Expected an error for an unknown template")
+	}
+	if _, err := RenderTemplate(TemplateOptions{Kind: TemplateTeam}); err == nil {
+		t.Error("Expected the team template to need a remote")
+	}
+
+	// The untouched default is replaced without force
+	team := TemplateOptions{Kind: TemplateTeam, Remote: "acme", RemoteURL: "https://github.com/acme/interop.git"}
+	if _, backup, err := WriteTemplate(team, false); err != nil || backup != "" {
+		t.Fatalf("WriteTemplate() = %q, %v; want no backup", backup, err)
+	}
+	content, _ = os.ReadFile(path)
+	if !strings.Contains(string(content), "'acme' remote:\n#   https://github.com/acme/interop.git") {
+		t.Errorf("Expected the team template to name the remote, got:\n%s", content)
+	}
+
+	for _, kind := range TemplateKinds {
+		env.WriteSettings("[commands.mine]\ncmd = \"ls\"\n")
+		if _, _, err := WriteTemplate(TemplateOptions{Kind: kind, Remote: "acme", RemoteURL: "https://github.com/acme/interop.git"}, false); err == nil {
+			t.Errorf("Expected %s to refuse replacing changed settings", kind)
+		}
+		_, backup, err := WriteTemplate(TemplateOptions{Kind: kind, Remote: "acme", RemoteURL: "https://github.com/acme/interop.git"}, true)
+		if err != nil {
+			t.Fatalf("WriteTemplate(%s) error = %v", kind, err)
+		}
+		if saved, _ := os.ReadFile(backup); string(saved) != "[commands.mine]\ncmd = \"ls\"\n" {
+			t.Errorf("Expected the changed settings in %s, got %q", backup, saved)
+		}
+
+		// Every template loads and defines nothing
+		cfg, err := Reload()
+		if err != nil {
+			t.Fatalf("Reload() of the %s template error = %v", kind, err)
+		}
+		if len(cfg.Commands) != 0 || len(cfg.Projects) != 0 {
+			t.Errorf("Expected the %s template to define nothing, got %d commands and %d projects", kind, len(cfg.Commands), len(cfg.Projects))
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`# My settings
+[env]
+DEBUG = "1"
+
+[projects.api]
+path = "~"
+commands = [{ command_name = "build", alias = "b" }, { command_name = "old" }]
+env = { PORT = "80" }
+
+# Builds everything
+[commands.build]
+cmd = "make"
+script_note = """
+[not a header]
+"""
+
+[commands.old]
+cmd = "old.sh"
+
+[[commands.old.arguments]]
+name = "target"
+
+# Runs the tests
+[commands.test]
+cmd = "go test ./..."
+`)
+	before, err := os.ReadFile(filepath.Join(env.ConfigDir, "settings.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := ParseChanges(`
+remove_commands = ["old"]
+unset_env = ["DEBUG"]
+
+[commands.build]
+cmd = "make all"
+pre_exec = ["go generate ./..."]
+
+[commands.lint]
+cmd = "golangci-lint run"
+description = "Lint the code"
+arguments = [{ name = "fix", type = "bool" }]
+
+[[commands.lint.examples]]
+description = "Fix what can be fixed"
+command = "interop run lint fix=true"
+
+[[bind]]
+project = "api"
+command = "lint"
+alias = "l"
+
+[env]
+GOFLAGS = "-mod=mod"
+
+[project_env.api]
+PORT = "8080"
+`)
+	if err != nil {
+		t.Fatalf("ParseChanges() error = %v", err)
+	}
+
+	result, err := Apply(changes, nil, true)
+	if err != nil {
+		t.Fatalf("Apply(dry run) error = %v", err)
+	}
+	if len(result.Files) != 1 {
+		t.Errorf("Apply(dry run) files = %v", result.Files)
+	}
+	if after, _ := os.ReadFile(filepath.Join(env.ConfigDir, "settings.toml")); string(after) != string(before) {
+		t.Fatalf("A dry run changed settings.toml:\n%s", after)
+	}
+
+	// A failing check rolls everything back
+	if _, err := Apply(changes, func(*Settings) error { return fmt.Errorf("rejected") }, false); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Apply() error = %v, want a rollback", err)
+	}
+	if after, _ := os.ReadFile(filepath.Join(env.ConfigDir, "settings.toml")); string(after) != string(before) {
+		t.Fatalf("settings.toml wasn't restored:\n%s", after)
+	}
+
+	if _, err := Apply(changes, nil, false); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(env.ConfigDir, "settings.toml"))
+	want := `# My settings
+[env]
+GOFLAGS = "-mod=mod"
+
+[projects.api]
+path = "~"
+commands = [
+  { command_name = "build", alias = "b" },
+  { command_name = "lint", alias = "l" },
+]
+env = { PORT = "8080" }
+
+# Builds everything
+[commands.build]
+cmd = "make all"
+pre_exec = ["go generate ./..."]
+
+# Runs the tests
+[commands.test]
+cmd = "go test ./..."
+
+[commands.lint]
+description = "Lint the code"
+cmd = "golangci-lint run"
+arguments = [
+  { name = "fix", type = "bool" },
+]
+examples = [
+  { command = "interop run lint fix=true", description = "Fix what can be fixed" },
+]
+`
+	if string(after) != want {
+		t.Errorf("settings.toml after Apply():\n%s\nwant:\n%s", after, want)
+	}
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, ok := cfg.Commands["old"]; ok {
+		t.Error("Command old wasn't removed")
+	}
+	if lint := cfg.Commands["lint"]; len(lint.Arguments) != 1 || len(lint.Examples) != 1 {
+		t.Errorf("lint arguments = %+v, examples = %+v", lint.Arguments, lint.Examples)
+	}
+	if cfg.Commands["build"].Cmd != "make all" || cfg.Projects["api"].Env["PORT"] != "8080" || cfg.Env["GOFLAGS"] != "-mod=mod" {
+		t.Errorf("Unexpected settings after Apply(): %+v", cfg)
+	}
+
+	invalid := []string{
+		"",
+		"unknown = 1",
+		"[[bind]]\nproject = \"api\"",
+		"unset_env = [\"A\"]\n[env]\nA = \"1\"",
+	}
+	for _, document := range invalid {
+		if _, err := ParseChanges(document); err == nil {
+			t.Errorf("Expected ParseChanges(%q) to fail", document)
+		}
+	}
+	for _, document := range []string{"remove_commands = [\"missing\"]", "[[bind]]\nproject = \"web\"\ncommand = \"build\""} {
+		changes, err := ParseChanges(document)
+		if err != nil {
+			t.Fatalf("ParseChanges(%q) error = %v", document, err)
+		}
+		if _, err := Apply(changes, nil, false); err == nil {
+			t.Errorf("Expected Apply(%q) to fail", document)
+		}
+	}
+}
+
+func TestApplyRollBack(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`[env]
+DEBUG = "1"
+`)
+	cfgPath := filepath.Join(env.ConfigDir, "settings.toml")
+	if err := os.Chmod(cfgPath, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := ParseChanges(`unset_env = ["DEBUG"]`)
+	if err != nil {
+		t.Fatalf("ParseChanges() error = %v", err)
+	}
+
+	// A rollback keeps the mode of the file
+	if _, err := Apply(changes, func(*Settings) error { return fmt.Errorf("rejected") }, false); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Apply() error = %v, want a rollback", err)
+	}
+	if after, _ := os.ReadFile(cfgPath); string(after) != string(before) {
+		t.Fatalf("settings.toml wasn't restored:\n%s", after)
+	}
+	if info, err := os.Stat(cfgPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("settings.toml mode after rollback = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	// A file that can't be restored is reported rather than ignored
+	replaceWithDir := func(*Settings) error {
+		if err := os.Remove(cfgPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Mkdir(cfgPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Errorf("rejected")
+	}
+	_, err = Apply(changes, replaceWithDir, false)
+	if err == nil || !strings.Contains(err.Error(), "rolling back the changes failed") || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("Apply() error = %v, want a failed rollback", err)
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	content := `# deployment overrides
+STAGE=staging
+export REGION="eu-west-1"
+TOKEN='a=b'
+
+EMPTY=
+`
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+
+	env, err := ParseEnvFile(envFile)
+	if err != nil {
+		t.Fatalf("ParseEnvFile() error = %v", err)
+	}
+
+	expected := []string{"STAGE=staging", "REGION=eu-west-1", "TOKEN=a=b", "EMPTY="}
+	if len(env) != len(expected) {
+		t.Fatalf("ParseEnvFile() = %v, want %v", env, expected)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Errorf("ParseEnvFile()[%d] = %q, want %q", i, env[i], expected[i])
+		}
+	}
+
+	if err := os.WriteFile(envFile, []byte("NOT AN ASSIGNMENT\n"), 0644); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	if _, err := ParseEnvFile(envFile); err == nil {
+		t.Error("ParseEnvFile() expected an error for an invalid line")
 	}
 }
@@ -0,0 +1,81 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceLocation formats where a configuration entry was defined as
+// "file:line", with the file relative to the config directory when it's
+// inside it. It returns "" when the file isn't known.
+func SourceLocation(file string, line int) string {
+	if file == "" {
+		return ""
+	}
+	if appDir, err := GetAppDir(); err == nil {
+		if rel, err := filepath.Rel(appDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+	if line > 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return file
+}
+
+// Location returns where the command was defined, see SourceLocation
+func (c CommandConfig) Location() string {
+	return SourceLocation(c.SourceFile, c.SourceLine)
+}
+
+// Location returns where the project was defined, see SourceLocation
+func (p Project) Location() string {
+	return SourceLocation(p.SourceFile, p.SourceLine)
+}
+
+// Location returns where the prompt was defined, see SourceLocation
+func (p PromptConfig) Location() string {
+	return SourceLocation(p.SourceFile, p.SourceLine)
+}
+
+// recordSources sets the source file and line of the commands, projects and
+// prompts decoded from data, the content of file
+func recordSources(data []byte, file string, commands map[string]CommandConfig, projects map[string]Project, prompts map[string]PromptConfig) {
+	line := func(table, name string) int {
+		return firstDefinition(data, table+"."+name, math.MaxInt)
+	}
+
+	for name, cmd := range commands {
+		cmd.SourceFile = file
+		cmd.SourceLine = line("commands", name)
+		commands[name] = cmd
+	}
+	for name, project := range projects {
+		project.SourceFile = file
+		project.SourceLine = line("projects", name)
+		projects[name] = project
+	}
+	for name, prompt := range prompts {
+		prompt.SourceFile = file
+		prompt.SourceLine = line("prompts", name)
+		prompts[name] = prompt
+	}
+}
+
+// EnvLocation returns where the global env is defined in settings.toml, see
+// SourceLocation
+func EnvLocation() string {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(appDir, pathConfig.CfgFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return SourceLocation(path, firstDefinition(data, "env", math.MaxInt))
+}
@@ -0,0 +1,124 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// bareKeyPattern matches names usable as bare TOML keys, which is what
+// interop init accepts for the names it writes
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Starter is a first project and command, as created by interop init. Either
+// half may be left out by leaving its name empty.
+type Starter struct {
+	Project            string // Name of the project, empty to only add a command
+	ProjectPath        string
+	ProjectDescription string
+	Command            string // Name of the command, empty to only add a project
+	Cmd                string
+	CommandDescription string
+	Alias              string // Alias of the command in the project
+}
+
+// Validate checks the names of s against the settings already loaded in cfg,
+// so interop init never shadows or redefines an existing entry
+func (s Starter) Validate(cfg *Settings) error {
+	if s.Project == "" && s.Command == "" {
+		return fmt.Errorf("nothing to add, name a project or a command")
+	}
+	for kind, name := range map[string]string{"project": s.Project, "command": s.Command, "alias": s.Alias} {
+		if name != "" && !bareKeyPattern.MatchString(name) {
+			return fmt.Errorf("%s name '%s' may only contain letters, digits, '-' and '_'", kind, name)
+		}
+	}
+	if s.Project != "" {
+		if _, ok := cfg.Projects[s.Project]; ok {
+			return fmt.Errorf("project '%s' is already defined", s.Project)
+		}
+		if s.ProjectPath == "" {
+			return fmt.Errorf("project '%s' needs a path", s.Project)
+		}
+	}
+	if s.Command != "" {
+		if _, ok := cfg.Commands[s.Command]; ok {
+			return fmt.Errorf("command '%s' is already defined", s.Command)
+		}
+		if s.Cmd == "" {
+			return fmt.Errorf("command '%s' needs something to run", s.Command)
+		}
+	}
+	return nil
+}
+
+// TOML returns the tables defining the project and command of s
+func (s Starter) TOML() string {
+	var b strings.Builder
+	if s.Command != "" {
+		fmt.Fprintf(&b, "[commands.%s]\n", s.Command)
+		if s.CommandDescription != "" {
+			fmt.Fprintf(&b, "description = %s\n", strconv.Quote(s.CommandDescription))
+		}
+		fmt.Fprintf(&b, "cmd = %s\n", strconv.Quote(s.Cmd))
+		b.WriteString("is_enabled = true\n")
+	}
+	if s.Project != "" {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[projects.%s]\n", s.Project)
+		fmt.Fprintf(&b, "path = %s\n", strconv.Quote(s.ProjectPath))
+		if s.ProjectDescription != "" {
+			fmt.Fprintf(&b, "description = %s\n", strconv.Quote(s.ProjectDescription))
+		}
+		if s.Command != "" {
+			if s.Alias != "" {
+				fmt.Fprintf(&b, "commands = [{ command_name = %s, alias = %s }]\n", strconv.Quote(s.Command), strconv.Quote(s.Alias))
+			} else {
+				fmt.Fprintf(&b, "commands = [{ command_name = %s }]\n", strconv.Quote(s.Command))
+			}
+		}
+	}
+	return b.String()
+}
+
+// AppendStarter adds the tables of s to the end of settings.toml, keeping the
+// rest of the file as is, and returns the file's path
+func AppendStarter(s Starter) (string, error) {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(appDir, pathConfig.CfgFile)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	result := string(content)
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	if result != "" {
+		result += "\n"
+	}
+	result += s.TOML()
+
+	// Refuse to write a file the loader could no longer parse
+	var check map[string]interface{}
+	if _, err := toml.Decode(result, &check); err != nil {
+		return "", fmt.Errorf("failed to update %s: %w", path, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(result), info.Mode().Perm())
+}
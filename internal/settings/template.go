@@ -0,0 +1,99 @@
+package settings
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// templateFiles holds the settings files interop writes on first use and
+// with interop init
+//
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var settingsTemplates = template.Must(template.ParseFS(templateFiles, "templates/*.tmpl"))
+
+// TemplateKind selects the settings file generated from the templates
+type TemplateKind string
+
+const (
+	TemplateFull    TemplateKind = "full"    // Every option, commented out and documented; written on first use
+	TemplateMinimal TemplateKind = "minimal" // A few commented examples to start from
+	TemplateTeam    TemplateKind = "team"    // A starter for commands shared through a remote
+)
+
+// TemplateKinds lists the kinds of settings templates
+var TemplateKinds = []TemplateKind{TemplateMinimal, TemplateFull, TemplateTeam}
+
+// TemplateOptions parameterize a settings template
+type TemplateOptions struct {
+	Kind      TemplateKind
+	Remote    string // Name of the remote holding the team's commands, for the team template
+	RemoteURL string
+}
+
+// RenderTemplate returns the settings file generated for opts
+func RenderTemplate(opts TemplateOptions) (string, error) {
+	if opts.Kind == TemplateTeam && (opts.Remote == "" || opts.RemoteURL == "") {
+		return "", fmt.Errorf("the team template needs a remote name and URL")
+	}
+	tmpl := settingsTemplates.Lookup(string(opts.Kind) + ".toml.tmpl")
+	if tmpl == nil {
+		return "", fmt.Errorf("unknown settings template '%s'", opts.Kind)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, opts); err != nil {
+		return "", fmt.Errorf("failed to render the %s settings template: %w", opts.Kind, err)
+	}
+	return out.String(), nil
+}
+
+// defaultSettings returns the settings file written on first use
+func defaultSettings() string {
+	content, err := RenderTemplate(TemplateOptions{Kind: TemplateFull})
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// WriteTemplate replaces settings.toml with the file generated for opts and
+// returns its path. A settings.toml with changes of its own is only replaced
+// with force, after copying it to backup.
+func WriteTemplate(opts TemplateOptions, force bool) (path, backup string, err error) {
+	content, err := RenderTemplate(opts)
+	if err != nil {
+		return "", "", err
+	}
+	appDir, err := GetAppDir()
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(appDir, 0o755); err != nil {
+		return "", "", err
+	}
+	path = filepath.Join(appDir, pathConfig.CfgFile)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+	if len(bytes.TrimSpace(existing)) > 0 && string(existing) != defaultSettings() {
+		if !force {
+			return "", "", fmt.Errorf("%s has changes of its own, pass --force to replace it (a backup is kept)", path)
+		}
+		backup = path + ".bak"
+		if err := os.WriteFile(backup, existing, 0o644); err != nil {
+			return "", "", fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", "", err
+	}
+	return path, backup, nil
+}
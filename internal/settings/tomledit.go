@@ -0,0 +1,353 @@
+package settings
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tomlDocument is a TOML file edited line by line, keeping the comments and
+// layout of everything an edit doesn't touch
+type tomlDocument struct {
+	lines []string
+}
+
+func parseTOMLDocument(content string) *tomlDocument {
+	return &tomlDocument{lines: strings.Split(content, "\n")}
+}
+
+func (d *tomlDocument) String() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// starts reports for each line whether it starts outside of any value, where
+// a header or key can begin, rather than inside a multi-line string or array
+func (d *tomlDocument) starts() []bool {
+	starts := make([]bool, len(d.lines))
+	multiline := ""
+	depth := 0
+	for n, line := range d.lines {
+		starts[n] = multiline == "" && depth == 0
+		for i := 0; i < len(line); {
+			if multiline != "" {
+				end := strings.Index(line[i:], multiline)
+				if end < 0 {
+					break
+				}
+				i += end + len(multiline)
+				multiline = ""
+				continue
+			}
+			switch c := line[i]; {
+			case strings.HasPrefix(line[i:], `"""`), strings.HasPrefix(line[i:], `'''`):
+				multiline = line[i : i+3]
+				i += 3
+			case c == '"':
+				i++
+				for i < len(line) && line[i] != '"' {
+					if line[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				i++
+			case c == '\'':
+				if end := strings.IndexByte(line[i+1:], '\''); end >= 0 {
+					i += end + 2
+				} else {
+					i = len(line)
+				}
+			case c == '#':
+				i = len(line)
+			case c == '[' || c == '{':
+				depth++
+				i++
+			case c == ']' || c == '}':
+				depth = max(depth-1, 0)
+				i++
+			default:
+				i++
+			}
+		}
+	}
+	return starts
+}
+
+// tomlHeader is a table header of a tomlDocument
+type tomlHeader struct {
+	path  []string // Dotted key of the table, unquoted
+	array bool     // An [[array]] of tables
+	line  int
+}
+
+// headers returns the table headers of the document in order
+func (d *tomlDocument) headers() []tomlHeader {
+	var headers []tomlHeader
+	for i, start := range d.starts() {
+		line := strings.TrimSpace(d.lines[i])
+		if !start || !strings.HasPrefix(line, "[") {
+			continue
+		}
+		header := tomlHeader{array: strings.HasPrefix(line, "[["), line: i}
+		line = strings.TrimLeft(line, "[")
+		header.path = splitTOMLKey(line, ']')
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// splitTOMLKey returns the parts of the dotted key text starts with, up to
+// end or '=' outside of quotes
+func splitTOMLKey(text string, end byte) []string {
+	var parts []string
+	var part strings.Builder
+	flush := func() {
+		parts = append(parts, strings.TrimSpace(part.String()))
+		part.Reset()
+	}
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'':
+			close := strings.IndexByte(text[i+1:], c)
+			if close < 0 {
+				close = len(text) - i - 1
+			}
+			quoted := text[i : i+close+2]
+			if c == '"' {
+				if unquoted, err := strconv.Unquote(quoted); err == nil {
+					quoted = unquoted
+				}
+			} else {
+				quoted = strings.Trim(quoted, "'")
+			}
+			part.WriteString(quoted)
+			i += close + 1
+		case '.':
+			flush()
+		case end, '=':
+			flush()
+			return parts
+		default:
+			if c != ' ' && c != '\t' {
+				part.WriteByte(c)
+			}
+		}
+	}
+	flush()
+	return parts
+}
+
+// table returns the lines of the table at path: the line of its header, -1
+// for the keys before the first header, and the line after its last one
+func (d *tomlDocument) table(path []string) (header, end int, ok bool) {
+	headers := d.headers()
+	if len(path) == 0 {
+		if len(headers) > 0 {
+			return -1, headers[0].line, true
+		}
+		return -1, len(d.lines), true
+	}
+	for i, h := range headers {
+		if h.array || !equalPath(h.path, path) {
+			continue
+		}
+		if i+1 < len(headers) {
+			return h.line, headers[i+1].line, true
+		}
+		return h.line, len(d.lines), true
+	}
+	return 0, 0, false
+}
+
+// hasTable reports whether the document has a header for path, or for a
+// table or array of tables inside it
+func (d *tomlDocument) hasTable(path []string) bool {
+	for _, h := range d.headers() {
+		if len(h.path) >= len(path) && equalPath(h.path[:len(path)], path) {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the lines holding the assignment of key in the table at path
+func (d *tomlDocument) key(path []string, key string) (from, to int, ok bool) {
+	header, end, ok := d.table(path)
+	if !ok {
+		return 0, 0, false
+	}
+	starts := d.starts()
+	for i := header + 1; i < end; i++ {
+		line := strings.TrimSpace(d.lines[i])
+		if !starts[i] || line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if parts := splitTOMLKey(line, '='); len(parts) != 1 || parts[0] != key {
+			continue
+		}
+		to = i + 1
+		for to < end && !starts[to] {
+			to++
+		}
+		return i, to, true
+	}
+	return 0, 0, false
+}
+
+// lastKeyEnd returns the line after the last assignment of the table with
+// the given header and end, or the line after its header when it has none
+func (d *tomlDocument) lastKeyEnd(header, end int) int {
+	starts := d.starts()
+	last := header + 1
+	for i := header + 1; i < end; i++ {
+		line := strings.TrimSpace(d.lines[i])
+		if starts[i] && line != "" && !strings.HasPrefix(line, "#") {
+			last = i + 1
+			for last < end && !starts[last] {
+				last++
+			}
+		}
+	}
+	return last
+}
+
+// setKey sets key to the TOML value in the table at path, replacing its
+// assignment or adding one after the table's last key. A missing table is
+// added at the end of the document.
+func (d *tomlDocument) setKey(path []string, key, value string) {
+	assignment := tomlKey(key) + " = " + value
+	if from, to, ok := d.key(path, key); ok {
+		d.replace(from, to, assignment)
+		return
+	}
+	header, end, ok := d.table(path)
+	if !ok {
+		d.appendTable("[" + tomlPath(path) + "]\n" + assignment + "\n")
+		return
+	}
+	at := d.lastKeyEnd(header, end)
+	d.replace(at, at, assignment)
+}
+
+// removeKey removes the assignment of key from the table at path
+func (d *tomlDocument) removeKey(path []string, key string) bool {
+	from, to, ok := d.key(path, key)
+	if ok {
+		d.replace(from, to)
+	}
+	return ok
+}
+
+// removeTables removes the tables and arrays of tables at path or inside it,
+// keeping the comments that precede the next table. It returns how many were
+// removed and the line the first one was at.
+func (d *tomlDocument) removeTables(path []string) (removed, at int) {
+	for {
+		headers := d.headers()
+		index := -1
+		for i, h := range headers {
+			if len(h.path) >= len(path) && equalPath(h.path[:len(path)], path) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return removed, at
+		}
+
+		from := headers[index].line
+		end := len(d.lines)
+		if index+1 < len(headers) {
+			end = headers[index+1].line
+		}
+		// Blank lines and comments before the next header belong to it
+		starts := d.starts()
+		for end > from+1 && starts[end-1] && isBlankOrComment(d.lines[end-1]) {
+			end--
+		}
+		// Drop the blank line separating the table from the previous one
+		if from > 0 && strings.TrimSpace(d.lines[from-1]) == "" {
+			from--
+		}
+		d.replace(from, end)
+		if removed == 0 {
+			at = from
+		}
+		removed++
+	}
+}
+
+// insertTable adds table at line, separated by blank lines from the rest
+// unless it follows a comment
+func (d *tomlDocument) insertTable(line int, table string) {
+	lines := strings.Split(strings.TrimRight(table, "\n"), "\n")
+	if line > 0 && !isBlankOrComment(d.lines[line-1]) {
+		lines = append([]string{""}, lines...)
+	}
+	if line < len(d.lines) && strings.TrimSpace(d.lines[line]) != "" {
+		lines = append(lines, "")
+	}
+	d.replace(line, line, lines...)
+}
+
+// appendTable adds table at the end of the document, after a blank line
+func (d *tomlDocument) appendTable(table string) {
+	content := strings.TrimRight(d.String(), "\n")
+	if content != "" {
+		content += "\n\n"
+	}
+	d.lines = strings.Split(content+table, "\n")
+}
+
+// replace replaces lines from to to with lines
+func (d *tomlDocument) replace(from, to int, lines ...string) {
+	d.lines = append(d.lines[:from], append(lines, d.lines[to:]...)...)
+}
+
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlKey returns name as a bare key when it can be one, quoted otherwise
+func tomlKey(name string) string {
+	if bareKeyPattern.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// tomlPath returns the dotted key of path
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, part := range path {
+		keys[i] = tomlKey(part)
+	}
+	return strings.Join(keys, ".")
+}
+
+// inlineTable renders values as an inline table with sorted keys
+func inlineTable(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]string, len(keys))
+	for i, key := range keys {
+		entries[i] = tomlKey(key) + " = " + strconv.Quote(values[key])
+	}
+	return "{ " + strings.Join(entries, ", ") + " }"
+}
@@ -0,0 +1,312 @@
+// Package setup walks through creating a first project and command with
+// interop init
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	pathutil "interop/internal/path"
+	"interop/internal/remote"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errInputEnded is returned when the input ends before an answer
+var errInputEnded = errors.New("input ended")
+
+// wizard asks questions on out and reads the answers from in
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints question and returns the answer, or fallback when the answer is
+// empty. It fails when the input ends before an answer.
+func (w *wizard) ask(question, fallback string) (string, error) {
+	if fallback != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, fallback)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+
+	answer, err := w.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if err != nil && answer == "" {
+		fmt.Fprintln(w.out)
+		return "", fmt.Errorf("%w before an answer to '%s'", errInputEnded, question)
+	}
+	if answer == "" {
+		return fallback, nil
+	}
+	return answer, nil
+}
+
+// askValid asks question until check accepts the answer
+func (w *wizard) askValid(question, fallback string, check func(string) error) (string, error) {
+	for {
+		answer, err := w.ask(question, fallback)
+		if err != nil {
+			return "", err
+		}
+		if err := check(answer); err != nil {
+			fmt.Fprintf(w.out, "  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// confirm asks a yes or no question, an empty answer picks fallback
+func (w *wizard) confirm(question string, fallback bool) (bool, error) {
+	choices := "y/N"
+	if fallback {
+		choices = "Y/n"
+	}
+	for {
+		answer, err := w.ask(fmt.Sprintf("%s [%s]", question, choices), "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return fallback, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// Options select the settings template Init writes before the wizard
+type Options struct {
+	Template    settings.TemplateOptions // Kind is empty to keep settings.toml as it is
+	Force       bool                     // Replace a settings.toml with changes of its own
+	Interactive bool                     // Ask for a first project and command after writing a template
+}
+
+// Init writes the settings template selected by opts, adding the team's
+// remote for the team template, and then asks for a first project and
+// command. Without a template it only asks, like Run.
+func Init(cfg *settings.Settings, opts Options, in io.Reader, out io.Writer) error {
+	w := &wizard{in: bufio.NewReader(in), out: out}
+	if opts.Template.Kind == "" {
+		_, err := w.run(cfg)
+		return err
+	}
+
+	template := opts.Template
+	remotes := remote.NewManager()
+	if template.Kind == settings.TemplateTeam {
+		if template.RemoteURL == "" {
+			url, err := w.askValid("Git URL of the team's configuration", "", func(url string) error {
+				if url == "" {
+					return fmt.Errorf("enter the URL of the repository, like https://github.com/acme/interop-config.git")
+				}
+				return remotes.ValidateURL(url)
+			})
+			if err != nil {
+				return err
+			}
+			template.RemoteURL = url
+		} else if err := remotes.ValidateURL(template.RemoteURL); err != nil {
+			return fmt.Errorf("invalid remote URL: %w", err)
+		}
+	}
+
+	path, backup, err := settings.WriteTemplate(template, opts.Force)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote the %s settings template to %s\n", template.Kind, path)
+	if backup != "" {
+		fmt.Fprintf(out, "The previous settings were saved to %s\n", backup)
+	}
+
+	if template.Kind == settings.TemplateTeam {
+		// The settings are written already, so a remote that can't be added,
+		// likely because it exists, is left to 'interop config remote'
+		if err := remotes.Add(template.Remote, template.RemoteURL, remote.TransportOptions{}); err != nil {
+			fmt.Fprintf(out, "Didn't add the '%s' remote: %v\n", template.Remote, err)
+		} else {
+			fmt.Fprintf(out, "Added the '%s' remote, fetch the team's commands with: interop config remote fetch\n", template.Remote)
+		}
+	}
+
+	if cfg, err = settings.Reload(); err != nil {
+		return fmt.Errorf("the new settings don't load: %w", err)
+	}
+	if !opts.Interactive {
+		return nil
+	}
+	fmt.Fprintln(out)
+	if _, err = w.run(cfg); errors.Is(err, errInputEnded) {
+		// The template is written, the first project and command can wait
+		return nil
+	}
+	return err
+}
+
+// Run asks for a project and a command bound to it, checks them against cfg
+// and, once confirmed, appends them to settings.toml. It returns what was
+// written, an empty Starter when the user chose not to write anything.
+func Run(cfg *settings.Settings, in io.Reader, out io.Writer) (settings.Starter, error) {
+	w := &wizard{in: bufio.NewReader(in), out: out}
+	return w.run(cfg)
+}
+
+func (w *wizard) run(cfg *settings.Settings) (settings.Starter, error) {
+	out := w.out
+	var starter settings.Starter
+
+	fmt.Fprintln(out, "This adds a project and a command to your settings.toml.")
+	fmt.Fprintln(out, "Press enter to accept the suggestion in brackets.")
+	fmt.Fprintln(out)
+
+	addProject, err := w.confirm("Add a project?", true)
+	if err != nil {
+		return settings.Starter{}, err
+	}
+	if addProject {
+		dir, _ := os.Getwd()
+		starter.Project, err = w.askValid("Project name", filepath.Base(dir), func(name string) error {
+			return settings.Starter{Project: name, ProjectPath: "."}.Validate(cfg)
+		})
+		if err != nil {
+			return settings.Starter{}, err
+		}
+		starter.ProjectPath, err = w.askValid("Project path", homeRelative(dir), func(path string) error {
+			expanded, err := pathutil.Expand(path)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(expanded); err != nil {
+				return fmt.Errorf("%s doesn't exist", expanded)
+			}
+			if !cfg.IsProjectPathAllowed(expanded) {
+				return fmt.Errorf("%s is outside allowed_project_roots", expanded)
+			}
+			return nil
+		})
+		if err != nil {
+			return settings.Starter{}, err
+		}
+		if starter.ProjectDescription, err = w.ask("Project description (optional)", ""); err != nil {
+			return settings.Starter{}, err
+		}
+		fmt.Fprintln(out)
+	}
+
+	addCommand, err := w.confirm("Add a command?", true)
+	if err != nil {
+		return settings.Starter{}, err
+	}
+	if addCommand {
+		starter.Command, err = w.askValid("Command name", "", func(name string) error {
+			if name == "" {
+				return fmt.Errorf("a command needs a name")
+			}
+			return settings.Starter{Command: name, Cmd: "-"}.Validate(cfg)
+		})
+		if err != nil {
+			return settings.Starter{}, err
+		}
+		starter.Cmd, err = w.askValid("Shell command to run", "", func(cmd string) error {
+			if cmd == "" {
+				return fmt.Errorf("enter the shell command, like 'make build'")
+			}
+			return nil
+		})
+		if err != nil {
+			return settings.Starter{}, err
+		}
+		if starter.CommandDescription, err = w.ask("Command description (optional)", ""); err != nil {
+			return settings.Starter{}, err
+		}
+		if starter.Project != "" {
+			starter.Alias, err = w.askValid("Alias in the project (optional)", "", func(alias string) error {
+				if alias == "" {
+					return nil
+				}
+				return settings.Starter{Command: starter.Command, Cmd: "-", Alias: alias}.Validate(cfg)
+			})
+			if err != nil {
+				return settings.Starter{}, err
+			}
+		}
+		fmt.Fprintln(out)
+	}
+
+	if starter.Project == "" && starter.Command == "" {
+		fmt.Fprintln(out, "Nothing to add.")
+		return settings.Starter{}, nil
+	}
+	if err := starter.Validate(cfg); err != nil {
+		return settings.Starter{}, err
+	}
+
+	fmt.Fprintln(out, "This will be added to settings.toml:")
+	fmt.Fprintln(out)
+	fmt.Fprint(out, indent(starter.TOML()))
+	fmt.Fprintln(out)
+	write, err := w.confirm("Write it?", true)
+	if err != nil {
+		return settings.Starter{}, err
+	}
+	if !write {
+		fmt.Fprintln(out, "Nothing was written.")
+		return settings.Starter{}, nil
+	}
+
+	path, err := settings.AppendStarter(starter)
+	if err != nil {
+		return settings.Starter{}, err
+	}
+	fmt.Fprintf(out, "Added to %s\n\n", path)
+	printNextSteps(out, starter)
+	return starter, nil
+}
+
+// printNextSteps suggests commands to try what was added
+func printNextSteps(out io.Writer, starter settings.Starter) {
+	fmt.Fprintln(out, "Next steps:")
+	if starter.Command != "" {
+		fmt.Fprintf(out, "  interop run %s\n", starter.Command)
+		fmt.Fprintln(out, "  interop commands")
+	}
+	if starter.Project != "" {
+		fmt.Fprintln(out, "  interop projects")
+	}
+	fmt.Fprintln(out, "  interop config edit")
+}
+
+// homeRelative shortens paths inside the home directory to start with ~
+func homeRelative(path string) string {
+	home, err := pathutil.HomeDir()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(home, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	if rel == "." {
+		return path
+	}
+	return filepath.Join("~", rel)
+}
+
+// indent indents every line of text by two spaces
+func indent(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "")
+}
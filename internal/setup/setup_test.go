@@ -0,0 +1,128 @@
+package setup
+
+import (
+	"bytes"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings("[commands.build]\ncmd = \"make\"\n")
+	project := filepath.Join(env.Home, "app")
+	if err := os.Mkdir(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// Accept the suggested project, retry a taken command name
+	input := strings.Join([]string{
+		"", "", "", "Main app",
+		"", "build", "test", "go test ./...", "", "t",
+		"",
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	starter, err := Run(cfg, strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("Run() error = %v\n%s", err, out.String())
+	}
+
+	want := settings.Starter{
+		Project:            "app",
+		ProjectPath:        "~/app",
+		ProjectDescription: "Main app",
+		Command:            "test",
+		Cmd:                "go test ./...",
+		Alias:              "t",
+	}
+	if starter != want {
+		t.Errorf("Run() = %+v, want %+v", starter, want)
+	}
+	for _, text := range []string{"command 'build' is already defined", "interop run test"} {
+		if !strings.Contains(out.String(), text) {
+			t.Errorf("Expected output to contain %q, got:\n%s", text, out.String())
+		}
+	}
+
+	cfg, err = settings.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if cfg.Projects["app"].Path != "~/app" || cfg.Commands["test"].Cmd != "go test ./..." {
+		t.Errorf("Expected the project and command to be written, got %+v and %+v", cfg.Projects["app"], cfg.Commands["test"])
+	}
+}
+
+func TestRunDeclined(t *testing.T) {
+	env := testutil.New(t)
+	path := env.WriteSettings("")
+
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	input := "n\ny\nlint\ngolangci-lint run\n\nn\n"
+	if _, err := Run(cfg, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if content, _ := os.ReadFile(path); len(content) != 0 {
+		t.Errorf("Expected settings.toml to stay empty, got:\n%s", content)
+	}
+
+	// Input ending early is an error rather than a loop
+	if _, err := Run(cfg, strings.NewReader("y\n"), &out); err == nil {
+		t.Error("Expected an error when the input ends")
+	}
+}
+
+func TestInitTeam(t *testing.T) {
+	env := testutil.New(t)
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	opts := Options{Template: settings.TemplateOptions{Kind: settings.TemplateTeam, Remote: "acme"}}
+	var out bytes.Buffer
+	// An invalid URL is asked again
+	input := "ftp://example.com/config\nhttps://github.com/acme/interop-config.git\n"
+	if err := Init(cfg, opts, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Init() error = %v\n%s", err, out.String())
+	}
+
+	content, _ := os.ReadFile(filepath.Join(env.ConfigDir, "settings.toml"))
+	if !strings.Contains(string(content), "https://github.com/acme/interop-config.git") {
+		t.Errorf("Expected the team template, got:\n%s", content)
+	}
+	remotes, _ := os.ReadFile(filepath.Join(env.ConfigDir, "remote", "remote.toml"))
+	if !strings.Contains(string(remotes), `name = "acme"`) {
+		t.Errorf("Expected the acme remote to be added, got:\n%s", remotes)
+	}
+	if !strings.Contains(out.String(), "URL must use http, https") {
+		t.Errorf("Expected the invalid URL to be reported, got:\n%s", out.String())
+	}
+
+	// Input ending in the wizard after the template is written is fine
+	opts = Options{Template: settings.TemplateOptions{Kind: settings.TemplateMinimal}, Force: true, Interactive: true}
+	if err := Init(cfg, opts, strings.NewReader(""), &out); err != nil {
+		t.Errorf("Init() error = %v", err)
+	}
+}
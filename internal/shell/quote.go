@@ -0,0 +1,101 @@
+package shell
+
+import (
+	"regexp"
+	"strings"
+)
+
+// safeWord matches values every supported shell reads as one literal word
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// doubleQuoteEscaper escapes the characters sh still interprets inside double quotes
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+// Quote returns value as a single literal word for sh-compatible shells.
+// Values made of safe characters only are returned unchanged.
+func Quote(value string) string {
+	if value == "" {
+		return "''"
+	}
+	if safeWord.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Quote returns value as a single literal argument for this shell
+func (i *Info) Quote(value string) string {
+	if value != "" && safeWord.MatchString(value) {
+		return value
+	}
+
+	switch strings.ToLower(i.Name) {
+	case "fish":
+		// fish reads \\ and \' as escapes inside single quotes
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+	case "powershell", "powershell.exe", "pwsh", "pwsh.exe":
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case "cmd", "cmd.exe":
+		// cmd.exe has no full quoting; this keeps spaces and operators in one argument
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	default:
+		return Quote(value)
+	}
+}
+
+// ExpandPlaceholders replaces ${name} placeholders in a sh command line with
+// values, quoted so each value stays literal wherever the placeholder appears:
+// unquoted, inside single quotes or inside double quotes. Placeholders without
+// a value, such as environment variables, are left for the shell.
+func ExpandPlaceholders(command string, values map[string]string) string {
+	return expandPlaceholders(command, values, Quote)
+}
+
+// ExpandPlaceholders is ExpandPlaceholders quoting unquoted placeholders for
+// this shell
+func (i *Info) ExpandPlaceholders(command string, values map[string]string) string {
+	return expandPlaceholders(command, values, i.Quote)
+}
+
+// expandPlaceholders implements ExpandPlaceholders with quote used for
+// placeholders outside of quotes
+func expandPlaceholders(command string, values map[string]string, quote func(string) string) string {
+	var b strings.Builder
+	inSingle, inDouble := false, false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && !inSingle && i+1 < len(command):
+			b.WriteByte(c)
+			b.WriteByte(command[i+1])
+			i++
+			continue
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '$' && strings.HasPrefix(command[i:], "${"):
+			end := strings.IndexByte(command[i:], '}')
+			if end < 0 {
+				break
+			}
+			value, ok := values[command[i+2:i+end]]
+			if !ok {
+				break
+			}
+			switch {
+			case inSingle:
+				b.WriteString(strings.ReplaceAll(value, "'", `'\''`))
+			case inDouble:
+				b.WriteString(doubleQuoteEscaper.Replace(value))
+			default:
+				b.WriteString(quote(value))
+			}
+			i += end
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -1,6 +1,9 @@
 package shell
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -187,3 +190,87 @@ func TestShellExecuteAlias(t *testing.T) {
 		t.Errorf("ExecuteAlias() args = %v, should contain 'my-alias'", cmd.Args)
 	}
 }
+
+// injectionValues try to break out of the quoting of an argument
+var injectionValues = []string{
+	"plain",
+	"two words",
+	"; touch pwned",
+	"$(touch pwned)",
+	"`touch pwned`",
+	"it's; touch pwned",
+	`"; touch pwned; echo "`,
+	`\'; touch pwned; echo '\`,
+	"${HOME}",
+	"line\nbreak",
+	"",
+}
+
+// runSh runs a command line with sh in a temporary directory and fails the
+// test if the command created a file named pwned there
+func runSh(t *testing.T, command string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sh -c %q failed: %v", command, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatalf("sh -c %q ran an injected command", command)
+	}
+	return string(output)
+}
+
+func TestQuote(t *testing.T) {
+	for _, value := range injectionValues {
+		if got := runSh(t, "printf '%s|' "+Quote(value)); got != value+"|" {
+			t.Errorf("Quote(%q) read back as %q", value, got)
+		}
+	}
+
+	if got := Quote("feature/login-2"); got != "feature/login-2" {
+		t.Errorf("Quote() of a safe value = %q, want it unchanged", got)
+	}
+}
+
+func TestInfoQuote(t *testing.T) {
+	tests := []struct {
+		shell string
+		value string
+		want  string
+	}{
+		{"bash", "it's", `'it'\''s'`},
+		{"fish", `it's \o/`, `'it\'s \\o/'`},
+		{"powershell", "it's", "'it''s'"},
+		{"cmd", `say "hi"`, `"say ""hi"""`},
+		{"fish", "main", "main"},
+	}
+
+	for _, tt := range tests {
+		info := &Info{Name: tt.shell}
+		if got := info.Quote(tt.value); got != tt.want {
+			t.Errorf("%s Quote(%q) = %s, want %s", tt.shell, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	for _, value := range injectionValues {
+		values := map[string]string{"v": value}
+		command := ExpandPlaceholders(`printf '%s|' ${v} "${v}" '${v}' "pre-${v}"`, values)
+		want := value + "|" + value + "|" + value + "|pre-" + value + "|"
+		if got := runSh(t, command); got != want {
+			t.Errorf("value %q: %s printed %q, want %q", value, command, got, want)
+		}
+	}
+
+	if got := ExpandPlaceholders(`echo ${HOME} \${v} ${v`, map[string]string{"v": "x"}); got != `echo ${HOME} \${v} ${v` {
+		t.Errorf("Expected unknown, escaped and unterminated placeholders to be kept, got %q", got)
+	}
+}
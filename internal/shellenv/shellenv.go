@@ -0,0 +1,55 @@
+// Package shellenv writes shell functions running interop aliases and
+// commands, for bash and zsh to load with eval
+package shellenv
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// functionName matches the names bash and zsh both accept for functions
+var functionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Generate returns a script defining a function for every project alias and
+// for each of commands, the names of other commands to include. Each function
+// runs `interop run` with its name and arguments, so env, hooks and the
+// project of the working directory apply as usual. Names that aren't valid
+// function names are left out with a comment saying so.
+func Generate(cfg *settings.Settings, commands []string) (string, error) {
+	names := make(map[string]bool)
+	for _, project := range cfg.Projects {
+		for _, alias := range project.Commands {
+			if alias.Alias != "" {
+				names[alias.Alias] = true
+			}
+		}
+	}
+	for _, name := range commands {
+		if _, exists := cfg.Commands[name]; !exists {
+			return "", fmt.Errorf("command '%s' not found", name)
+		}
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	b.WriteString("# Shell functions for interop aliases, load with: eval \"$(interop alias shellenv)\"\n")
+	for _, name := range sorted {
+		if !functionName.MatchString(name) {
+			fmt.Fprintf(&b, "# Skipped %q, not a valid function name\n", name)
+			continue
+		}
+		// An alias of the same name would be expanded in the definition
+		fmt.Fprintf(&b, "unalias %s 2>/dev/null\n", name)
+		fmt.Fprintf(&b, "%s() { command interop run %s \"$@\"; }\n", name, name)
+	}
+	return b.String(), nil
+}
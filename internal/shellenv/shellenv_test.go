@@ -0,0 +1,43 @@
+package shellenv
+
+import (
+	"interop/internal/settings"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"build":       {IsEnabled: true},
+			"test":        {IsEnabled: true},
+			"team:deploy": {IsEnabled: true},
+		},
+		Projects: map[string]settings.Project{
+			"api": {Commands: []settings.Alias{{CommandName: "build", Alias: "b"}, {CommandName: "test"}}},
+			"web": {Commands: []settings.Alias{{CommandName: "build", Alias: "b"}, {CommandName: "team:deploy", Alias: "ship-it"}}},
+		},
+	}
+
+	script, err := Generate(cfg, []string{"test", "team:deploy"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	want := `# Shell functions for interop aliases, load with: eval "$(interop alias shellenv)"
+unalias b 2>/dev/null
+b() { command interop run b "$@"; }
+unalias ship-it 2>/dev/null
+ship-it() { command interop run ship-it "$@"; }
+# Skipped "team:deploy", not a valid function name
+unalias test 2>/dev/null
+test() { command interop run test "$@"; }
+`
+	if script != want {
+		t.Errorf("Generate() =\n%s\nwant\n%s", script, want)
+	}
+
+	if _, err := Generate(cfg, []string{"missing"}); err == nil || !strings.Contains(err.Error(), "'missing' not found") {
+		t.Errorf("Generate() with an unknown command error = %v", err)
+	}
+}
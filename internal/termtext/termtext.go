@@ -0,0 +1,142 @@
+package termtext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	esc = '\x1b'
+	bel = '\a'
+	// csi8 is the single character form of ESC [ some terminals emit
+	csi8 = '\u009b'
+	// osc8 is the single character form of ESC ]
+	osc8 = '\u009d'
+	// st8 is the single character form of the ESC \ string terminator
+	st8 = '\u009c'
+)
+
+// Strip removes terminal escape sequences from s: colors and other CSI
+// sequences like cursor movement, OSC sequences like window titles and
+// hyperlinks, DCS and similar strings, and single character escapes. Control
+// characters other than tab, newline and carriage return are removed too, so
+// the result is safe to embed in JSON and logs.
+func Strip(s string) string {
+	if !strings.ContainsFunc(s, isControl) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == esc && i+1 < len(s):
+			i += size + escapeLength(s[i+size:])
+		case r == csi8:
+			i += size + csiLength(s[i+size:])
+		case r == osc8 || r == '\u0090' || r == '\u0098' || r == '\u009e' || r == '\u009f':
+			i += size + stringLength(s[i+size:])
+		case isControl(r):
+			i += size
+		default:
+			b.WriteString(s[i : i+size])
+			i += size
+		}
+	}
+	return b.String()
+}
+
+// isControl reports whether r is a control character Strip removes
+func isControl(r rune) bool {
+	if r == '\t' || r == '\n' || r == '\r' {
+		return false
+	}
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0)
+}
+
+// escapeLength returns the length of the escape sequence following an ESC
+// at the start of s
+func escapeLength(s string) int {
+	switch s[0] {
+	case '[':
+		return 1 + csiLength(s[1:])
+	case ']', 'P', 'X', '^', '_':
+		// OSC, DCS, SOS, PM and APC strings
+		return 1 + stringLength(s[1:])
+	}
+	// Intermediate bytes, e.g. the ( of a character set selection, then a
+	// final byte
+	n := 0
+	for n < len(s) && s[n] >= 0x20 && s[n] <= 0x2f {
+		n++
+	}
+	if n < len(s) && s[n] >= 0x30 && s[n] <= 0x7e {
+		n++
+	}
+	return n
+}
+
+// csiLength returns the length of the parameters and final byte of the CSI
+// sequence starting s
+func csiLength(s string) int {
+	for n := 0; n < len(s); n++ {
+		if s[n] >= 0x40 && s[n] <= 0x7e {
+			return n + 1
+		}
+		if s[n] < 0x20 || s[n] > 0x3f {
+			// Not a CSI byte: a malformed sequence ends here
+			return n
+		}
+	}
+	return len(s)
+}
+
+// stringLength returns the length of the control string starting s, up to
+// and including its BEL or ST terminator. An unterminated string runs to the
+// end of s.
+func stringLength(s string) int {
+	for n := 0; n < len(s); {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case r == bel || r == st8:
+			return n + size
+		case r == esc && n+1 < len(s) && s[n+1] == '\\':
+			return n + 2
+		}
+		n += size
+	}
+	return len(s)
+}
+
+// Truncate returns the longest prefix of s of at most maxBytes bytes that
+// doesn't end in the middle of a UTF-8 encoded character
+func Truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	if maxBytes <= 0 {
+		return ""
+	}
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+// Tail returns the longest suffix of s of at most maxBytes bytes that doesn't
+// start in the middle of a UTF-8 encoded character
+func Tail(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	if maxBytes <= 0 {
+		return ""
+	}
+	start := len(s) - maxBytes
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
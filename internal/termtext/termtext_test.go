@@ -0,0 +1,60 @@
+package termtext
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "hello\n\tworld\r\n", "hello\n\tworld\r\n"},
+		{"colors", "\x1b[1;31merror\x1b[0m: failed", "error: failed"},
+		{"cursor movement", "50%\x1b[2K\x1b[1G100%\x1b[?25h", "50%100%"},
+		{"window title", "\x1b]0;build\x07done", "done"},
+		{"hyperlink", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"character set", "\x1b(Bbox\x1b=", "box"},
+		{"device control string", "\x1bPq#0;2;0;0;0\x1b\\after", "after"},
+		{"8-bit csi", "\u009b31mred", "red"},
+		{"other controls", "bell\a back\b", "bell back"},
+		{"trailing escape", "text\x1b", "text"},
+		{"unterminated osc", "text\x1b]0;title", "text"},
+		{"multibyte", "\x1b[32m✓\x1b[0m 日本語 ünïcode", "✓ 日本語 ünïcode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Strip(tt.input); got != tt.want {
+				t.Errorf("Strip(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	s := "ab日本"
+	tests := []struct {
+		max  int
+		head string
+		tail string
+	}{
+		{10, "ab日本", "ab日本"},
+		{8, "ab日本", "ab日本"},
+		{7, "ab日", "b日本"},
+		{5, "ab日", "本"},
+		{4, "ab", "本"},
+		{2, "ab", ""},
+		{0, "", ""},
+	}
+	for _, tt := range tests {
+		head, tail := Truncate(s, tt.max), Tail(s, tt.max)
+		if head != tt.head || !utf8.ValidString(head) {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", s, tt.max, head, tt.head)
+		}
+		if tail != tt.tail || !utf8.ValidString(tail) {
+			t.Errorf("Tail(%q, %d) = %q, want %q", s, tt.max, tail, tt.tail)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package testutil provides isolated interop environments for end-to-end
+// tests: a temporary home and config directory with fixture files, local git
+// repositories standing in for configuration remotes, and golden file
+// assertions for listings and exports.
+//
+// It only depends on the standard library and go-git, so the tests of every
+// interop package can use it without import cycles.
+package testutil
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Env is an isolated interop environment. HOME and XDG_CONFIG_HOME point into
+// a temporary directory for the duration of the test, so the interop config
+// directory is ConfigDir and the real one is never read or written.
+//
+// Env sets environment variables, so tests using it can't run in parallel.
+// Settings are cached by the settings package: tests that loaded them before
+// writing fixtures must reload them with settings.Reload.
+type Env struct {
+	t         testing.TB
+	Home      string // Temporary home directory
+	ConfigDir string // Interop config directory, where settings.toml lives
+}
+
+// New creates an isolated environment removed at the end of the test
+func New(t testing.TB) *Env {
+	t.Helper()
+
+	// Resolve symlinks so paths match the ones interop resolves, like
+	// /private/var for /var on macOS
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+	env := &Env{
+		t:         t,
+		Home:      home,
+		ConfigDir: filepath.Join(home, ".config", "interop"),
+	}
+	if err := os.MkdirAll(env.ConfigDir, 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	// Keep selections made by the environment running the tests out
+	t.Setenv("INTEROP_CONFIG_DIR", "")
+	t.Setenv("INTEROP_SANDBOX_CONFIG", "")
+	return env
+}
+
+// WriteFile writes content to a file in the config directory, creating its
+// parent directories, and returns its path
+func (e *Env) WriteFile(name, content string) string {
+	e.t.Helper()
+	return writeFile(e.t, filepath.Join(e.ConfigDir, name), content)
+}
+
+// WriteSettings writes the main settings.toml
+func (e *Env) WriteSettings(content string) string {
+	e.t.Helper()
+	return e.WriteFile("settings.toml", content)
+}
+
+// CopyFixtures copies the files of a fixture directory, usually under
+// testdata, into the config directory, keeping their layout and modes
+func (e *Env) CopyFixtures(dir string) {
+	e.t.Helper()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(e.ConfigDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+	if err != nil {
+		e.t.Fatalf("Failed to copy fixtures from %s: %v", dir, err)
+	}
+}
+
+// Dir creates a directory inside the home directory, such as a project
+// directory, and returns its path
+func (e *Env) Dir(name string) string {
+	e.t.Helper()
+
+	path := filepath.Join(e.Home, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		e.t.Fatalf("Failed to create directory %s: %v", name, err)
+	}
+	return path
+}
+
+// Normalize replaces the temporary directories in output with $HOME, so
+// output mentioning paths can be compared with golden files
+func (e *Env) Normalize(output string) string {
+	return strings.ReplaceAll(output, e.Home, "$HOME")
+}
+
+// Tree lists the files below a directory of the config directory, one
+// "path mode" line per file sorted by path, for asserting what an operation
+// wrote
+func (e *Env) Tree(name string) string {
+	e.t.Helper()
+
+	root := filepath.Join(e.ConfigDir, name)
+	var lines []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, filepath.ToSlash(rel)+" "+info.Mode().Perm().String())
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		e.t.Fatalf("Failed to list %s: %v", name, err)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// writeFile writes content to path, creating its parent directories
+func writeFile(t testing.TB, path, content string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
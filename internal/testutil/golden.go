@@ -0,0 +1,66 @@
+package testutil
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites golden files instead of comparing with them:
+//
+//	go test ./internal/remote -update
+var update = flag.Bool("update", false, "Rewrite golden files with the current output")
+
+// AssertGolden compares got with the golden file testdata/<name>.golden of
+// the package under test. Run the tests with -update to create or rewrite the
+// golden files after an intended change, and review the diff.
+func AssertGolden(t testing.TB, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	got = strings.TrimRight(got, "\n") + "\n"
+	if *update {
+		writeFile(t, path, got)
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s (run with -update to create it): %v", path, err)
+	}
+	if got == string(want) {
+		return
+	}
+
+	line, gotLine, wantLine := firstDifference(got, string(want))
+	t.Errorf("Output differs from %s at line %d:\n got: %q\nwant: %q\n\nFull output:\n%s\n(run with -update to accept it)",
+		path, line, gotLine, wantLine, got)
+}
+
+// AssertGolden is AssertGolden with the environment's temporary directories
+// replaced by $HOME in got
+func (e *Env) AssertGolden(name, got string) {
+	e.t.Helper()
+	AssertGolden(e.t, name, e.Normalize(got))
+}
+
+// firstDifference returns the first line, counted from 1, where got and want
+// differ, and that line of each
+func firstDifference(got, want string) (int, string, string) {
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+	for i := 0; ; i++ {
+		var gotLine, wantLine string
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if gotLine != wantLine || i >= len(gotLines) || i >= len(wantLines) {
+			return i + 1, gotLine, wantLine
+		}
+	}
+}
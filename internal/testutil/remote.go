@@ -0,0 +1,110 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// Remote is a local bare git repository standing in for a remote
+// configuration repository. Commits are written with go-git, so creating one
+// doesn't need a git binary; cloning it from a local path does.
+type Remote struct {
+	t        testing.TB
+	URL      string // Path of the bare repository, used as the remote URL
+	repo     *git.Repository
+	worktree billy.Filesystem
+}
+
+// NewRemote creates an empty bare repository removed at the end of the test
+func NewRemote(t testing.TB) *Remote {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+	dir = filepath.Join(dir, "remote.git")
+
+	// Objects go straight into the bare repository while files are staged in
+	// an in-memory worktree
+	storage := filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+	worktree := memfs.New()
+	repo, err := git.Init(storage, worktree)
+	if err != nil {
+		t.Fatalf("Failed to init remote repository: %v", err)
+	}
+	// git.Init with a worktree records a non-bare repository
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatalf("Failed to read remote repository config: %v", err)
+	}
+	cfg.Core.IsBare = true
+	if err := storage.SetConfig(cfg); err != nil {
+		t.Fatalf("Failed to write remote repository config: %v", err)
+	}
+
+	return &Remote{t: t, URL: dir, repo: repo, worktree: worktree}
+}
+
+// Commit writes files, given by slash separated path, and commits them with
+// the files of earlier commits. Files under executables/ are made executable.
+// It returns the commit hash.
+func (r *Remote) Commit(message string, files map[string]string) string {
+	r.t.Helper()
+
+	for name, content := range files {
+		mode := os.FileMode(0644)
+		if strings.HasPrefix(name, "executables/") {
+			mode = 0755
+		}
+		if err := util.WriteFile(r.worktree, name, []byte(content), mode); err != nil {
+			r.t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return r.commit(message)
+}
+
+// Remove deletes files from the repository in a new commit and returns its hash
+func (r *Remote) Remove(message string, names ...string) string {
+	r.t.Helper()
+
+	for _, name := range names {
+		if err := r.worktree.Remove(name); err != nil {
+			r.t.Fatalf("Failed to remove %s: %v", name, err)
+		}
+	}
+	return r.commit(message)
+}
+
+// commit stages every change in the worktree and commits it
+func (r *Remote) commit(message string) string {
+	r.t.Helper()
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatalf("Failed to get remote worktree: %v", err)
+	}
+	if err := worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		r.t.Fatalf("Failed to stage remote changes: %v", err)
+	}
+	hash, err := worktree.Commit(message, &git.CommitOptions{
+		Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		r.t.Fatalf("Failed to commit to remote: %v", err)
+	}
+	return hash.String()
+}
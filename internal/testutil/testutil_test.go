@@ -0,0 +1,99 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+func TestEnv(t *testing.T) {
+	env := New(t)
+
+	if home := os.Getenv("HOME"); home != env.Home {
+		t.Errorf("HOME = %q, want %q", home, env.Home)
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.Join(xdg, "interop") != env.ConfigDir {
+		t.Errorf("XDG_CONFIG_HOME = %q, want the parent of %q", xdg, env.ConfigDir)
+	}
+
+	env.WriteSettings("log_level = \"error\"\n")
+	env.WriteFile("config.d/team.toml", "[commands.hello]\ncmd = \"echo hello\"\n")
+	env.CopyFixtures(filepath.Join("testdata", "fixtures"))
+
+	want := "config.d/fixture.toml -rw-r--r--\nconfig.d/team.toml -rw-r--r--\nsettings.toml -rw-r--r--"
+	if got := env.Tree(""); got != want {
+		t.Errorf("Tree() = %q, want %q", got, want)
+	}
+	if got := env.Tree("missing"); got != "" {
+		t.Errorf("Tree() of a missing directory = %q, want empty", got)
+	}
+
+	project := env.Dir("projects/app")
+	if got := env.Normalize("cd " + project); got != "cd $HOME/projects/app" {
+		t.Errorf("Normalize() = %q", got)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	AssertGolden(t, "listing", "first line\nsecond line")
+
+	line, got, want := firstDifference("a\nb\nc\n", "a\nx\nc\n")
+	if line != 2 || got != "b" || want != "x" {
+		t.Errorf("firstDifference() = %d, %q, %q; want 2, \"b\", \"x\"", line, got, want)
+	}
+	line, got, want = firstDifference("a\n", "a\nb\n")
+	if line != 2 || got != "" || want != "b" {
+		t.Errorf("firstDifference() with a missing line = %d, %q, %q; want 2, \"\", \"b\"", line, got, want)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	remote := NewRemote(t)
+	remote.Commit("initial", map[string]string{
+		"config.d/team.toml":   "[commands.hello]\ncmd = \"hello.sh\"\n",
+		"executables/hello.sh": "#!/bin/sh\necho hello\n",
+	})
+	head := remote.Remove("remove commands", "config.d/team.toml")
+
+	repo, err := git.PlainOpen(remote.URL)
+	if err != nil {
+		t.Fatalf("Failed to open remote: %v", err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatalf("Failed to read remote config: %v", err)
+	}
+	if !cfg.Core.IsBare {
+		t.Error("Expected the remote to be a bare repository")
+	}
+
+	ref, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to read remote HEAD: %v", err)
+	}
+	if ref.Hash().String() != head {
+		t.Errorf("HEAD = %s, want %s", ref.Hash(), head)
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatalf("Failed to read HEAD commit: %v", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD tree: %v", err)
+	}
+
+	if _, err := tree.File("config.d/team.toml"); err == nil {
+		t.Error("Expected config.d/team.toml to be removed")
+	}
+	file, err := tree.File("executables/hello.sh")
+	if err != nil {
+		t.Fatalf("Expected executables/hello.sh in HEAD: %v", err)
+	}
+	if file.Mode != filemode.Executable {
+		t.Errorf("executables/hello.sh mode = %v, want executable", file.Mode)
+	}
+}
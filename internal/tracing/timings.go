@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// commandAttribute names the command of spans that run one
+const commandAttribute = "interop.command"
+
+// timingCollector keeps the ended spans for a breakdown of where the time of
+// an invocation went, see --timings
+type timingCollector struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (c *timingCollector) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {}
+
+func (c *timingCollector) OnEnd(span sdktrace.ReadOnlySpan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans = append(c.spans, span)
+}
+
+func (c *timingCollector) Shutdown(ctx context.Context) error { return nil }
+
+func (c *timingCollector) ForceFlush(ctx context.Context) error { return nil }
+
+// write writes the spans as a tree, children under their parent in the order
+// they began, each with its duration
+func (c *timingCollector) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.Slice(c.spans, func(i, j int) bool { return c.spans[i].StartTime().Before(c.spans[j].StartTime()) })
+	ended := make(map[trace.SpanID]bool, len(c.spans))
+	for _, span := range c.spans {
+		ended[span.SpanContext().SpanID()] = true
+	}
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	var roots []sdktrace.ReadOnlySpan
+	for _, span := range c.spans {
+		if parent := span.Parent().SpanID(); ended[parent] {
+			children[parent] = append(children[parent], span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+
+	var lines [][2]string
+	var add func(span sdktrace.ReadOnlySpan, depth int)
+	add = func(span sdktrace.ReadOnlySpan, depth int) {
+		label := strings.Repeat("  ", depth) + span.Name()
+		for _, attr := range span.Attributes() {
+			if string(attr.Key) == commandAttribute {
+				label += fmt.Sprintf(" (%s)", attr.Value.AsString())
+			}
+		}
+		duration := span.EndTime().Sub(span.StartTime()).Round(10 * time.Microsecond)
+		lines = append(lines, [2]string{label, duration.String()})
+		for _, child := range children[span.SpanContext().SpanID()] {
+			add(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		add(root, 0)
+	}
+
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line[0]))
+	}
+	fmt.Fprintln(w, "Timings:")
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %-*s  %10s\n", width, line[0], line[1])
+	}
+}
@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"interop/internal/logging"
+	"interop/internal/settings"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName identifies the instrumentation emitting interop spans
+	tracerName = "interop"
+	// defaultServiceName is reported when tracing.service_name is not set
+	defaultServiceName = "interop"
+	// shutdownTimeout bounds how long exiting waits for spans to be exported
+	shutdownTimeout = 5 * time.Second
+)
+
+// Enabled reports whether spans should be exported, either because the
+// settings name an endpoint or the standard OTLP variables are set
+func Enabled(cfg settings.TracingConfig) bool {
+	return cfg.Endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs a tracer provider exporting spans over OTLP/HTTP when
+// tracing is enabled, and writing a breakdown of their timings to timings
+// when it isn't nil. The returned function flushes pending spans, writes the
+// breakdown, and must be called before the program exits. Without either
+// spans are no-ops.
+func Setup(cfg settings.TracingConfig, timings io.Writer) (func(), error) {
+	if !Enabled(cfg) && timings == nil {
+		return func() {}, nil
+	}
+
+	var providerOpts []sdktrace.TracerProviderOption
+	var collector *timingCollector
+	if timings != nil {
+		collector = &timingCollector{}
+		providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(collector))
+	}
+	if Enabled(cfg) {
+		exportOpts, err := exporterOptions(cfg)
+		if err != nil {
+			return func() {}, err
+		}
+		providerOpts = append(providerOpts, exportOpts...)
+	}
+
+	provider := sdktrace.NewTracerProvider(providerOpts...)
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logging.Warning("Failed to export traces: %v", err)
+		}
+		if collector != nil {
+			collector.write(timings)
+		}
+	}, nil
+}
+
+// exporterOptions returns the provider options exporting spans over OTLP/HTTP
+func exporterOptions(cfg settings.TracingConfig) ([]sdktrace.TracerProviderOption, error) {
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	logging.Message("Exporting traces as '%s'", serviceName)
+	return []sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)}, nil
+}
+
+// Start starts a span as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartAt starts a span that began at an earlier time, for work done before
+// tracing was set up
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+}
+
+// Record records a span for work that began at start and ended at end, for
+// work done before tracing was set up
+func Record(ctx context.Context, name string, start, end time.Time) {
+	_, span := StartAt(ctx, name, start)
+	span.End(trace.WithTimestamp(end))
+}
+
+// End ends the span, marking it failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"interop/internal/settings"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEnabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	if Enabled(settings.TracingConfig{}) {
+		t.Error("expected tracing to be disabled without an endpoint")
+	}
+	if !Enabled(settings.TracingConfig{Endpoint: "http://localhost:4318"}) {
+		t.Error("expected tracing to be enabled with a configured endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/v1/traces")
+	if !Enabled(settings.TracingConfig{}) {
+		t.Error("expected tracing to be enabled through the environment")
+	}
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, parent := Start(context.Background(), "command.run")
+	_, child := Start(ctx, "command.exec")
+	End(child, errors.New("exit status 1"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "command.exec" || spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Errorf("expected command.exec to be a child of command.run")
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected failed span to have error status, got %v", spans[0].Status().Code)
+	}
+	if spans[1].Status().Code != codes.Unset {
+		t.Errorf("expected successful span to have unset status, got %v", spans[1].Status().Code)
+	}
+}
+
+func TestTimings(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var timings bytes.Buffer
+	shutdown, err := Setup(settings.TracingConfig{}, &timings)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	start := time.Now()
+	ctx, root := StartAt(context.Background(), "interop", start)
+	loadCtx, load := StartAt(ctx, "config.load", start)
+	Record(loadCtx, "config.read", start, start.Add(2*time.Millisecond))
+	load.End()
+	_, run := Start(ctx, "command.run", attribute.String("interop.command", "build"))
+	run.End()
+	root.End()
+	shutdown()
+
+	lines := strings.Split(strings.TrimSpace(timings.String()), "\n")
+	want := []string{"Timings:", "interop", "config.load", "config.read", "command.run (build)"}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got:\n%s", len(want), timings.String())
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), prefix) {
+			t.Errorf("Line %d = %q, want it to start with %q", i, lines[i], prefix)
+		}
+	}
+	if !strings.HasPrefix(lines[3], "      config.read") || !strings.HasSuffix(lines[3], "2ms") {
+		t.Errorf("Expected config.read nested under config.load taking 2ms, got %q", lines[3])
+	}
+}
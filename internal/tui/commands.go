@@ -2,8 +2,9 @@ package tui
 
 import (
 	"fmt"
+	"interop/internal/execution"
+	"interop/internal/history"
 	"interop/internal/settings"
-	"os/exec"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -45,12 +46,20 @@ type CommandItem struct {
 	name         string
 	description  string
 	cmd          string
+	script       string
+	interpreter  string
+	scriptDeps   []string
 	isEnabled    bool
 	isExecutable bool
 	arguments    []settings.CommandArgument
 	examples     []settings.CommandExample
-	preExec      []string
-	postExec     []string
+	preExec      []settings.Hook
+	postExec     []settings.Hook
+	projects     []string // Projects binding the command, sorted
+	aliasOf      string   // Command the item is an alias of, in a project's list
+	remote       bool     // Loaded from fetched remote configuration
+	mcp          string
+	tags         []string
 }
 
 func (i CommandItem) FilterValue() string { return i.name }
@@ -72,6 +81,11 @@ type KeyMap struct {
 	Search key.Binding
 	Quit   key.Binding
 	Help   key.Binding
+	Tab    key.Binding
+	Rerun  key.Binding
+	Back   key.Binding
+	Reload key.Binding
+	Group  key.Binding
 }
 
 var keys = KeyMap{
@@ -107,6 +121,26 @@ var keys = KeyMap{
 		key.WithKeys("?"),
 		key.WithHelp("?", "toggle help"),
 	),
+	Tab: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "switch tab"),
+	),
+	Rerun: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "re-run"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "back to details"),
+	),
+	Reload: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "reload configuration"),
+	),
+	Group: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "change grouping"),
+	),
 }
 
 // Model represents the state of the TUI
@@ -123,29 +157,30 @@ type Model struct {
 	showHelp         bool
 	originalCommands []list.Item
 	filteredCommands []list.Item
+	tab              int // commandsTab, projectsTab, promptsTab or historyTab
+	projects         list.Model
+	selectedProject  *ProjectItem
+	project          string // Project the commands are listed and run in, empty for all
+	prompts          list.Model
+	selectedPrompt   *PromptItem
+	history          list.Model
+	selectedRun      *history.Entry
+	showOutput       bool           // Whether the details show the selected job's output
+	status           string         // Result of the last action, shown in place of the help line
+	fingerprint      string         // Settings files the commands were loaded from, see settings.ConfigFingerprint
+	configWatcher    *configWatcher // Reports changes to the settings files, nil when they're polled
+	grouping         grouping
+	version          string        // Version of interop shown in the help, see Run
+	form             *argumentForm // Arguments of the command about to run, nil when closed
 }
 
 // NewCommandsModel creates a new TUI model for commands
 func NewCommandsModel(cfg *settings.Settings) Model {
 	// Create command items
-	var items []list.Item
-	for name, cmd := range cfg.Commands {
-		item := CommandItem{
-			name:         name,
-			description:  cmd.Description,
-			cmd:          cmd.Cmd,
-			isEnabled:    cmd.IsEnabled,
-			isExecutable: cmd.IsExecutable,
-			arguments:    cmd.Arguments,
-			examples:     cmd.Examples,
-			preExec:      cmd.PreExec,
-			postExec:     cmd.PostExec,
-		}
-		items = append(items, item)
-	}
+	items := commandItems(cfg, "")
 
 	// Create list
-	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
+	l := list.New(items, newGroupDelegate(), 0, 0)
 	l.Title = "Commands"
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false) // We'll handle filtering manually
@@ -170,6 +205,12 @@ func NewCommandsModel(cfg *settings.Settings) Model {
 		showHelp:         false,
 		originalCommands: items,
 		filteredCommands: items,
+		projects:         newProjectList(cfg),
+		prompts:          newPromptList(cfg),
+		history:          newHistoryList(),
+
+		fingerprint:   settings.ConfigFingerprint(cfg),
+		configWatcher: newConfigWatcher(cfg),
 	}
 
 	// Set initial selection
@@ -182,9 +223,19 @@ func NewCommandsModel(cfg *settings.Settings) Model {
 	return m
 }
 
+// Run opens the TUI for the commands of cfg and returns when it's closed.
+// version is shown in the help.
+func Run(cfg *settings.Settings, version string) error {
+	model := NewCommandsModel(cfg)
+	model.version = version
+	defer model.configWatcher.close()
+	_, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
+	return err
+}
+
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(historyTick(), m.configWatcher.configTick())
 }
 
 // Update handles messages
@@ -200,25 +251,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		m.updateSizes()
 
+	case commandFinishedMsg:
+		m.status = fmt.Sprintf("'%s' finished", msg.name)
+		if msg.err != nil {
+			m.status = fmt.Sprintf("'%s' failed: %v", msg.name, msg.err)
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		m.status = ""
+		if m.form != nil {
+			return m.updateForm(msg)
+		}
 		if m.searchMode {
 			return m.updateSearchMode(msg)
 		}
 		return m.updateNormalMode(msg)
 	}
 
+	if updated, cmd, ok := m.updateHistory(msg); ok {
+		return updated, cmd
+	}
+	if updated, cmd, ok := m.updateConfig(msg); ok {
+		return updated, cmd
+	}
+
 	// Update components
-	if !m.searchMode {
-		m.list, cmd = m.list.Update(msg)
-		cmds = append(cmds, cmd)
-
-		// Update selected command when list selection changes
-		if selected := m.list.SelectedItem(); selected != nil {
-			if cmdItem, ok := selected.(CommandItem); ok {
-				m.selectedCommand = &cmdItem
-				m.updateDetailView()
-			}
-		}
+	if !m.searchMode && m.tab == commandsTab {
+		cmds = append(cmds, m.updateList(msg))
 	}
 
 	m.detailViewport, cmd = m.detailViewport.Update(msg)
@@ -264,17 +324,59 @@ func (m Model) updateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.showHelp = !m.showHelp
 		return m, nil
 
+	case key.Matches(msg, keys.Reload):
+		return m, reloadConfig
+
+	case key.Matches(msg, keys.Tab):
+		m.focusedPanel = 0
+		switch m.tab {
+		case commandsTab:
+			m.tab = projectsTab
+			m.selectProjectItem()
+			return m, nil
+		case projectsTab:
+			m.tab = promptsTab
+			m.selectPromptItem()
+			return m, nil
+		case promptsTab:
+			m.tab = historyTab
+			m.updateHistoryDetail()
+			return m, loadHistory
+		}
+		m.tab = commandsTab
+		m.updateDetailView()
+		return m, nil
+	}
+
+	switch m.tab {
+	case projectsTab:
+		return m.updateProjectsMode(msg)
+	case promptsTab:
+		return m.updatePromptsMode(msg)
+	case historyTab:
+		return m.updateHistoryMode(msg)
+	}
+
+	switch {
 	case key.Matches(msg, keys.Search):
 		m.searchMode = true
 		m.searchInput.Focus()
 		m.focusedPanel = 1
 		return m, nil
 
+	case key.Matches(msg, keys.Group):
+		m.setGrouping(m.grouping.next())
+		return m, nil
+
 	case key.Matches(msg, keys.Enter):
-		if m.selectedCommand != nil {
-			return m, m.executeCommand(*m.selectedCommand)
+		if m.selectedCommand == nil {
+			return m, nil
 		}
-		return m, nil
+		if len(m.selectedCommand.arguments) > 0 {
+			m.form = newArgumentForm(*m.selectedCommand, m.detailViewport.Width-4)
+			return m, textinput.Blink
+		}
+		return m, m.executeCommand(*m.selectedCommand, nil)
 
 	case key.Matches(msg, keys.Left):
 		if m.focusedPanel > 0 {
@@ -291,14 +393,7 @@ func (m Model) updateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case key.Matches(msg, keys.Up), key.Matches(msg, keys.Down):
 		// Forward up/down keys to the list when in command list panel
 		if m.focusedPanel == 0 {
-			m.list, cmd = m.list.Update(msg)
-			// Update selected command when list selection changes
-			if selected := m.list.SelectedItem(); selected != nil {
-				if cmdItem, ok := selected.(CommandItem); ok {
-					m.selectedCommand = &cmdItem
-					m.updateDetailView()
-				}
-			}
+			cmd = m.updateList(msg)
 			return m, cmd
 		}
 		return m, nil
@@ -306,14 +401,7 @@ func (m Model) updateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	default:
 		// Forward other keys to the list for navigation (j, k, page up/down, etc.)
 		if m.focusedPanel == 0 {
-			m.list, cmd = m.list.Update(msg)
-			// Update selected command when list selection changes
-			if selected := m.list.SelectedItem(); selected != nil {
-				if cmdItem, ok := selected.(CommandItem); ok {
-					m.selectedCommand = &cmdItem
-					m.updateDetailView()
-				}
-			}
+			cmd = m.updateList(msg)
 			return m, cmd
 		}
 	}
@@ -339,15 +427,30 @@ func (m *Model) filterCommands(query string) {
 		m.filteredCommands = filtered
 	}
 
-	m.list.SetItems(m.filteredCommands)
-	if len(m.filteredCommands) > 0 {
-		m.list.Select(0)
-		cmdItem := m.filteredCommands[0].(CommandItem)
-		m.selectedCommand = &cmdItem
+	m.list.SetItems(groupedItems(m.filteredCommands, m.grouping))
+	if m.selectCommand("") {
 		m.updateDetailView()
 	}
 }
 
+// updateList passes msg to the command list and follows its selection,
+// stepping over section headers
+func (m *Model) updateList(msg tea.Msg) tea.Cmd {
+	previous := m.list.Index()
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	m.skipHeader(m.list.Index() < previous)
+
+	// Update selected command when list selection changes
+	if selected := m.list.SelectedItem(); selected != nil {
+		if cmdItem, ok := selected.(CommandItem); ok {
+			m.selectedCommand = &cmdItem
+			m.updateDetailView()
+		}
+	}
+	return cmd
+}
+
 // updateDetailView updates the content of the detail viewport
 func (m *Model) updateDetailView() {
 	if m.selectedCommand == nil {
@@ -388,6 +491,12 @@ func (m *Model) updateDetailView() {
 	execTypeFormatted := typeStyle.Render(execType)
 
 	content.WriteString(fmt.Sprintf("Status: %s  |  Type: %s\n\n", status, execTypeFormatted))
+	if cmd.aliasOf != "" {
+		content.WriteString(fmt.Sprintf("Alias of: %s\n\n", cmd.aliasOf))
+	}
+	if len(cmd.tags) > 0 {
+		content.WriteString(fmt.Sprintf("Tags: %s\n\n", strings.Join(cmd.tags, ", ")))
+	}
 
 	// Description
 	if cmd.description != "" {
@@ -453,7 +562,7 @@ func (m *Model) updateDetailView() {
 			Padding(0, 1)
 		for i, hook := range cmd.preExec {
 			content.WriteString(fmt.Sprintf("  %d. ", i+1))
-			content.WriteString(hookStyle.Render(hook))
+			content.WriteString(hookStyle.Render(hook.String()))
 			content.WriteString("\n")
 		}
 		content.WriteString("\n")
@@ -470,7 +579,7 @@ func (m *Model) updateDetailView() {
 			Padding(0, 1)
 		for i, hook := range cmd.postExec {
 			content.WriteString(fmt.Sprintf("  %d. ", i+1))
-			content.WriteString(hookStyle.Render(hook))
+			content.WriteString(hookStyle.Render(hook.String()))
 			content.WriteString("\n")
 		}
 		content.WriteString("\n")
@@ -486,11 +595,18 @@ func (m *Model) updateDetailView() {
 		Background(lipgloss.Color("236")).
 		Padding(0, 1)
 
-	if cmd.isExecutable {
+	source := cmd.cmd
+	if cmd.script != "" {
+		interpreter := strings.Join(execution.ScriptInterpreter(cmd.script, cmd.interpreter), " ")
+		content.WriteString(codeStyle.Render("Interpreter: " + interpreter))
+		content.WriteString("\n")
+		source = cmd.script
+	}
+	if cmd.isExecutable && cmd.script == "" {
 		content.WriteString(codeStyle.Render(cmd.cmd))
 	} else {
 		// For shell scripts, format each line
-		lines := strings.Split(cmd.cmd, "\n")
+		lines := strings.Split(source, "\n")
 		for _, line := range lines {
 			if strings.TrimSpace(line) != "" {
 				content.WriteString(codeStyle.Render(line))
@@ -502,13 +618,12 @@ func (m *Model) updateDetailView() {
 	m.detailViewport.SetContent(content.String())
 }
 
-// executeCommand executes the selected command
-func (m Model) executeCommand(cmd CommandItem) tea.Cmd {
-	return tea.ExecProcess(exec.Command("bash", "-c", cmd.cmd), func(err error) tea.Msg {
-		if err != nil {
-			return fmt.Sprintf("Error executing command: %v", err)
-		}
-		return "Command executed successfully"
+// executeCommand runs cmd with args the same way 'interop run' does, with its
+// project, environment, hooks and argument handling
+func (m Model) executeCommand(cmd CommandItem, args []string) tea.Cmd {
+	run := &commandRun{cfg: m.cfg, name: cmd.name, args: args, project: m.project}
+	return tea.Exec(run, func(err error) tea.Msg {
+		return commandFinishedMsg{name: cmd.name, err: err}
 	})
 }
 
@@ -523,13 +638,18 @@ func (m *Model) updateSizes() {
 	rightWidth := availableWidth - leftWidth - 2     // Rest for right column (minus gap)
 	contentHeight := availableHeight - 2             // Account for margins
 
-	// List height should account for search bar (3 lines: search + border + spacing)
-	listHeight := contentHeight - 6
+	// List height should account for the tabs (2 lines) and search bar (3 lines:
+	// search + border + spacing)
+	listHeight := contentHeight - 8
 	if listHeight < 5 {
 		listHeight = 5 // Minimum height
 	}
 
+	// The projects, prompts and history tabs have no search bar
 	m.list.SetSize(leftWidth-6, listHeight)
+	m.projects.SetSize(leftWidth-6, listHeight+5)
+	m.prompts.SetSize(leftWidth-6, listHeight+5)
+	m.history.SetSize(leftWidth-6, listHeight+5)
 	m.detailViewport.Width = rightWidth - 4
 	m.detailViewport.Height = contentHeight - 4
 	m.searchInput.Width = leftWidth - 16 // Account for "Search: " label and padding
@@ -560,7 +680,28 @@ func (m Model) View() string {
 		view.WriteString("\n")
 		view.WriteString(m.renderHelp())
 	} else {
-		helpText := "Press ? for help, / to search, Enter to execute, q to quit"
+		helpText := "Press ? for help, tab for projects, / to search, Enter to execute, q to quit"
+		if m.tab == projectsTab {
+			helpText = "Press ? for help, tab for prompts, Enter to switch to the project, q to quit"
+		}
+		if m.tab == promptsTab {
+			helpText = "Press ? for help, tab for history, → to scroll the prompt, q to quit"
+		}
+		if m.tab == historyTab {
+			helpText = "Press ? for help, tab for commands, Enter to view output, r to re-run, q to quit"
+		}
+		if m.form != nil {
+			helpText = "tab/shift+tab to move between arguments, enter on the last one to run, esc to cancel"
+		}
+		if m.status != "" {
+			helpText = m.status
+		}
+		if m.tab == commandsTab && m.project != "" {
+			helpText += "  |  Project: " + m.project
+		}
+		if m.tab == commandsTab && m.grouping != groupNone {
+			helpText += "  |  Grouped by " + m.grouping.String()
+		}
 		view.WriteString("\n")
 		view.WriteString(helpStyle.Width(m.width).Align(lipgloss.Center).Render(helpText))
 	}
@@ -613,8 +754,17 @@ func (m Model) renderLeftColumn() string {
 		Width(leftWidth - 8).
 		Render(searchLabel + searchContent)
 
-	// Combine search bar and list
-	content := searchBar + "\n\n" + m.list.View()
+	// Combine tabs, search bar and list
+	content := m.renderTabs() + "\n\n" + searchBar + "\n\n" + m.list.View()
+	if m.tab == projectsTab {
+		content = m.renderTabs() + "\n\n" + m.projects.View()
+	}
+	if m.tab == promptsTab {
+		content = m.renderTabs() + "\n\n" + m.prompts.View()
+	}
+	if m.tab == historyTab {
+		content = m.renderTabs() + "\n\n" + m.history.View()
+	}
 
 	return style.Width(leftWidth).Height(contentHeight).Render(content)
 }
@@ -631,6 +781,9 @@ func (m Model) renderRightColumn() string {
 	rightWidth := availableWidth - leftWidth - 2 // Rest minus gap
 	contentHeight := m.height - 4
 
+	if m.form != nil {
+		return style.Width(rightWidth).Height(contentHeight).Render(m.form.view())
+	}
 	return style.Width(rightWidth).Height(contentHeight).Render(m.detailViewport.View())
 }
 
@@ -640,8 +793,11 @@ func (m Model) renderHelp() string {
 		"Navigation:",
 		"  ↑/k, ↓/j    Navigate list",
 		"  ←/h, →/l    Switch panels",
-		"  enter       Execute command",
+		"  enter       Execute command, asking for its arguments first",
 		"  /           Search commands",
+		"  tab         Switch between commands, projects, prompts and history",
+		"  v           Group by project, source, MCP server, tag or not at all",
+		"  ctrl+r      Reload the configuration (saved changes reload on their own)",
 		"  ?           Toggle this help",
 		"  q, ctrl+c   Quit",
 		"",
@@ -649,7 +805,19 @@ func (m Model) renderHelp() string {
 		"  Type to filter commands",
 		"  enter       Apply filter",
 		"  esc         Exit search",
+		"",
+		"Projects:",
+		"  enter       List and run only the project's commands, with its path and env",
+		"              (All projects lists every command again)",
+		"",
+		"History:",
+		"  enter       View the output of a run",
+		"  r           Run it again in the background",
+		"  esc         Back to the run's details",
 	}
 
+	if m.version != "" {
+		help = append([]string{"interop " + m.version, ""}, help...)
+	}
 	return helpStyle.Render(strings.Join(help, "\n"))
 }
@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"interop/internal/settings"
+	"interop/internal/validation"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// argumentForm asks for the arguments of a command before it runs
+type argumentForm struct {
+	command CommandItem
+	inputs  []textinput.Model
+	focus   int
+	err     string // Why the last submit was rejected
+}
+
+// commandFinishedMsg reports the end of a command run from the TUI
+type commandFinishedMsg struct {
+	name string
+	err  error
+}
+
+// commandRun runs a command like 'interop run' while the TUI has released
+// the terminal. The command writes to the terminal itself, so the program's
+// streams are ignored.
+type commandRun struct {
+	cfg     *settings.Settings
+	name    string
+	args    []string
+	project string // Project the command runs in, empty to resolve it from the name
+}
+
+func (r *commandRun) Run() error {
+	return validation.ExecuteCommandWithOptions(context.Background(), r.cfg, r.name, r.args, validation.RunOptions{Project: r.project})
+}
+
+func (r *commandRun) SetStdin(io.Reader)  {}
+func (r *commandRun) SetStdout(io.Writer) {}
+func (r *commandRun) SetStderr(io.Writer) {}
+
+// newArgumentForm creates a form with an input for each argument of cmd
+func newArgumentForm(cmd CommandItem, width int) *argumentForm {
+	form := &argumentForm{command: cmd}
+	for _, arg := range cmd.arguments {
+		input := textinput.New()
+		input.Prompt = "> "
+		input.Width = width
+		if arg.Default != nil && arg.Default != "" {
+			input.Placeholder = fmt.Sprintf("%v", arg.Default)
+		} else if arg.Type != "" {
+			input.Placeholder = string(arg.Type)
+		}
+		form.inputs = append(form.inputs, input)
+	}
+	form.inputs[0].Focus()
+	return form
+}
+
+// move focuses the input offset places away, staying within the form
+func (f *argumentForm) move(offset int) {
+	f.inputs[f.focus].Blur()
+	f.focus = max(0, min(len(f.inputs)-1, f.focus+offset))
+	f.inputs[f.focus].Focus()
+}
+
+// args returns the filled in arguments as name=value pairs, as 'interop run'
+// takes them. Empty inputs are left out so the command's defaults apply.
+func (f *argumentForm) args() ([]string, error) {
+	var args []string
+	for i, arg := range f.command.arguments {
+		value := f.inputs[i].Value()
+		if value == "" {
+			if arg.Required && (arg.Default == nil || arg.Default == "") {
+				return nil, fmt.Errorf("argument '%s' is required", arg.Name)
+			}
+			continue
+		}
+		args = append(args, arg.Name+"="+value)
+	}
+	return args, nil
+}
+
+// updateForm handles input while the argument form is open
+func (m Model) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	form := m.form
+	switch {
+	case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))):
+		m.form = nil
+		return m, nil
+
+	case key.Matches(msg, key.NewBinding(key.WithKeys("tab", "down"))):
+		form.move(1)
+		return m, nil
+
+	case key.Matches(msg, key.NewBinding(key.WithKeys("shift+tab", "up"))):
+		form.move(-1)
+		return m, nil
+
+	case key.Matches(msg, keys.Enter):
+		if form.focus < len(form.inputs)-1 {
+			form.move(1)
+			return m, nil
+		}
+		args, err := form.args()
+		if err != nil {
+			form.err = err.Error()
+			return m, nil
+		}
+		m.form = nil
+		return m, m.executeCommand(form.command, args)
+	}
+
+	var cmd tea.Cmd
+	form.inputs[form.focus], cmd = form.inputs[form.focus].Update(msg)
+	form.err = ""
+	return m, cmd
+}
+
+// view renders the form in place of the command details
+func (f *argumentForm) view() string {
+	var content strings.Builder
+
+	nameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true).
+		Underline(true)
+	content.WriteString(nameStyle.Render("Run " + f.command.name))
+	content.WriteString("\n\n")
+
+	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true)
+	noteStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+	for i, arg := range f.command.arguments {
+		label := arg.Name
+		if arg.Required {
+			label += " (required)"
+		}
+		content.WriteString(labelStyle.Render(label))
+		if arg.Description != "" {
+			content.WriteString(noteStyle.Render("  " + arg.Description))
+		}
+		content.WriteString("\n")
+		content.WriteString(f.inputs[i].View())
+		content.WriteString("\n\n")
+	}
+
+	if f.err != "" {
+		content.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(f.err))
+		content.WriteString("\n\n")
+	}
+	content.WriteString(noteStyle.Render("Empty arguments use their defaults."))
+	return content.String()
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// grouping is how the command list is divided into sections
+type grouping int
+
+const (
+	groupNone grouping = iota
+	groupProject
+	groupSource
+	groupMCP
+	groupTag
+	groupingCount
+)
+
+var (
+	groupTitleStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208")).
+			Bold(true)
+
+	groupCountStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("243"))
+)
+
+// String returns the name of the grouping shown in the status line
+func (g grouping) String() string {
+	switch g {
+	case groupProject:
+		return "project"
+	case groupSource:
+		return "source"
+	case groupMCP:
+		return "MCP server"
+	case groupTag:
+		return "tag"
+	}
+	return "none"
+}
+
+// next returns the grouping after g, going back to no grouping after the last
+func (g grouping) next() grouping {
+	return (g + 1) % groupingCount
+}
+
+// groups returns the sections cmd is listed in, a command bound to several
+// projects or with several tags is listed in each of them
+func (g grouping) groups(cmd CommandItem) []string {
+	var groups []string
+	switch g {
+	case groupProject:
+		groups = cmd.projects
+	case groupSource:
+		groups = []string{"Local"}
+		if cmd.remote {
+			groups = []string{"Remote"}
+		}
+	case groupMCP:
+		if cmd.mcp != "" {
+			groups = []string{cmd.mcp}
+		}
+	case groupTag:
+		groups = cmd.tags
+	}
+	if len(groups) == 0 {
+		return []string{g.fallback()}
+	}
+	return groups
+}
+
+// fallback returns the section of the commands without a value to group by,
+// which is listed last
+func (g grouping) fallback() string {
+	switch g {
+	case groupProject:
+		return "No project"
+	case groupMCP:
+		return "Default server"
+	case groupTag:
+		return "Untagged"
+	}
+	return ""
+}
+
+// groupHeader is the section header preceding the commands of a group. It
+// can't be selected, see Model.skipHeader.
+type groupHeader struct {
+	title string
+	count int
+}
+
+func (h groupHeader) FilterValue() string { return "" }
+
+// groupedItems returns the list items of the commands in items divided into
+// sections with headers. Sections are sorted by name, the commands keep their
+// order.
+func groupedItems(items []list.Item, g grouping) []list.Item {
+	if g == groupNone {
+		return items
+	}
+
+	sections := make(map[string][]list.Item)
+	for _, item := range items {
+		cmd := item.(CommandItem)
+		for _, group := range g.groups(cmd) {
+			sections[group] = append(sections[group], item)
+		}
+	}
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	fallback := g.fallback()
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == fallback) != (names[j] == fallback) {
+			return names[j] == fallback
+		}
+		return names[i] < names[j]
+	})
+
+	grouped := make([]list.Item, 0, len(items)+len(names))
+	for _, name := range names {
+		grouped = append(grouped, groupHeader{title: name, count: len(sections[name])})
+		grouped = append(grouped, sections[name]...)
+	}
+	return grouped
+}
+
+// groupDelegate renders section headers and leaves the commands to the
+// default delegate
+type groupDelegate struct {
+	list.DefaultDelegate
+}
+
+func newGroupDelegate() groupDelegate {
+	return groupDelegate{DefaultDelegate: list.NewDefaultDelegate()}
+}
+
+// Render renders a header in place of the title and description lines of a
+// command
+func (d groupDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	header, ok := item.(groupHeader)
+	if !ok {
+		d.DefaultDelegate.Render(w, m, index, item)
+		return
+	}
+
+	count := fmt.Sprintf("%d commands", header.count)
+	if header.count == 1 {
+		count = "1 command"
+	}
+	fmt.Fprintf(w, "%s\n%s", groupTitleStyle.Render(header.title), groupCountStyle.Render(count))
+}
+
+// skipHeader moves the selection off a section header, in the direction the
+// cursor was moving unless the header is the first item
+func (m *Model) skipHeader(up bool) {
+	if _, ok := m.list.SelectedItem().(groupHeader); !ok {
+		return
+	}
+	if up && m.list.Index() > 0 {
+		m.list.CursorUp()
+	} else {
+		m.list.CursorDown()
+	}
+}
+
+// selectCommand selects the command named name, or the first command when it
+// isn't listed. It reports whether a command was selected.
+func (m *Model) selectCommand(name string) bool {
+	index := -1
+	for i, item := range m.list.Items() {
+		cmd, ok := item.(CommandItem)
+		if !ok {
+			continue
+		}
+		if index < 0 {
+			index = i
+		}
+		if cmd.name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return false
+	}
+
+	m.list.Select(index)
+	cmd := m.list.Items()[index].(CommandItem)
+	m.selectedCommand = &cmd
+	return true
+}
+
+// setGrouping divides the command list by g, keeping the selected command
+func (m *Model) setGrouping(g grouping) {
+	selected := ""
+	if m.selectedCommand != nil {
+		selected = m.selectedCommand.name
+	}
+
+	m.grouping = g
+	m.list.SetItems(groupedItems(m.filteredCommands, g))
+	if m.selectCommand(selected) {
+		m.updateDetailView()
+	}
+}
@@ -0,0 +1,334 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"interop/internal/history"
+	"interop/internal/termtext"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Tabs of the TUI
+const (
+	commandsTab = iota
+	projectsTab
+	promptsTab
+	historyTab
+)
+
+const (
+	// historyRefresh is how often the history tab reloads the recorded runs
+	historyRefresh = 2 * time.Second
+	// maxOutputBytes is how much of the end of a run's output the output view shows
+	maxOutputBytes = 64 * 1024
+)
+
+// HistoryItem represents a run in the history list
+type HistoryItem struct {
+	entry *history.Entry
+}
+
+func (i HistoryItem) FilterValue() string { return i.entry.Command }
+func (i HistoryItem) Title() string {
+	return fmt.Sprintf("%s %s", statusIcon(i.entry.ExitCode), commandLine(i.entry))
+}
+func (i HistoryItem) Description() string {
+	project := i.entry.Project
+	if project == "" {
+		project = "no project"
+	}
+	return fmt.Sprintf("%s  |  %s  |  %s", i.entry.Started.Format("Jan 02 15:04"), i.entry.Duration().Round(time.Second), project)
+}
+
+// historyLoadedMsg carries the runs read by loadHistory
+type historyLoadedMsg struct {
+	entries []*history.Entry
+	err     error
+}
+
+// historyTickMsg triggers a reload of the history tab
+type historyTickMsg time.Time
+
+// rerunMsg reports the end of a run started by rerunEntry
+type rerunMsg struct {
+	entry *history.Entry
+	err   error
+}
+
+// historyRerun runs a recorded run again like 'interop history rerun' while
+// the TUI has released the terminal
+type historyRerun struct {
+	entry *history.Entry
+}
+
+func (r *historyRerun) Run() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	return r.entry.Rerun(context.Background(), executable)
+}
+
+func (r *historyRerun) SetStdin(io.Reader)  {}
+func (r *historyRerun) SetStdout(io.Writer) {}
+func (r *historyRerun) SetStderr(io.Writer) {}
+
+// newHistoryList creates the list of the history tab
+func newHistoryList() list.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	l.Title = "History"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+	return l
+}
+
+// loadHistory reads the recorded runs, the most recent first
+func loadHistory() tea.Msg {
+	entries, err := history.List()
+	return historyLoadedMsg{entries: entries, err: err}
+}
+
+// historyTick schedules the next reload of the history tab
+func historyTick() tea.Cmd {
+	return tea.Tick(historyRefresh, func(t time.Time) tea.Msg {
+		return historyTickMsg(t)
+	})
+}
+
+// rerunEntry runs the entry's command again in the terminal
+func rerunEntry(entry *history.Entry) tea.Cmd {
+	return tea.Exec(&historyRerun{entry: entry}, func(err error) tea.Msg {
+		return rerunMsg{entry: entry, err: err}
+	})
+}
+
+// updateHistory handles the messages of the history tab, ok is false for
+// other messages
+func (m Model) updateHistory(msg tea.Msg) (Model, tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case historyLoadedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed to load history: %v", msg.err)
+			return m, nil, true
+		}
+		m.setHistory(msg.entries)
+		return m, nil, true
+
+	case historyTickMsg:
+		if m.tab == historyTab {
+			return m, tea.Batch(loadHistory, historyTick()), true
+		}
+		return m, historyTick(), true
+
+	case rerunMsg:
+		m.status = fmt.Sprintf("'%s' finished", commandLine(msg.entry))
+		if msg.err != nil {
+			m.status = fmt.Sprintf("'%s' failed: %v", commandLine(msg.entry), msg.err)
+		}
+		return m, loadHistory, true
+	}
+	return m, nil, false
+}
+
+// updateHistoryMode handles keys on the history tab
+func (m Model) updateHistoryMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, keys.Enter):
+		if m.selectedRun != nil {
+			m.showOutput = true
+			m.focusedPanel = 2
+			m.updateHistoryDetail()
+			m.detailViewport.GotoBottom()
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.Rerun):
+		if m.selectedRun != nil {
+			return m, rerunEntry(m.selectedRun)
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.Back):
+		m.showOutput = false
+		m.focusedPanel = 0
+		m.updateHistoryDetail()
+		return m, nil
+
+	case key.Matches(msg, keys.Left):
+		if m.focusedPanel > 0 {
+			m.focusedPanel = 0
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.Right):
+		m.focusedPanel = 2
+		return m, nil
+	}
+
+	// Scroll the details when they're focused, otherwise move in the list
+	if m.focusedPanel == 2 {
+		m.detailViewport, cmd = m.detailViewport.Update(msg)
+		return m, cmd
+	}
+	m.history, cmd = m.history.Update(msg)
+	m.selectHistoryItem()
+	return m, cmd
+}
+
+// setHistory replaces the runs of the history list, keeping the selection
+func (m *Model) setHistory(entries []*history.Entry) {
+	selected := ""
+	if m.selectedRun != nil {
+		selected = m.selectedRun.ID
+	}
+
+	items := make([]list.Item, len(entries))
+	index := 0
+	for i, entry := range entries {
+		items[i] = HistoryItem{entry: entry}
+		if entry.ID == selected {
+			index = i
+		}
+	}
+	m.history.SetItems(items)
+	m.history.Select(index)
+
+	// Keep the output view where it's scrolled unless it's following the end
+	following := m.detailViewport.AtBottom()
+	offset := m.detailViewport.YOffset
+	m.selectHistoryItem()
+	if m.showOutput && !following {
+		m.detailViewport.SetYOffset(offset)
+	} else if m.showOutput {
+		m.detailViewport.GotoBottom()
+	}
+}
+
+// selectHistoryItem shows the run selected in the history list
+func (m *Model) selectHistoryItem() {
+	item, ok := m.history.SelectedItem().(HistoryItem)
+	if !ok {
+		m.selectedRun = nil
+		m.updateHistoryDetail()
+		return
+	}
+	if m.selectedRun == nil || m.selectedRun.ID != item.entry.ID {
+		m.showOutput = false
+	}
+	m.selectedRun = item.entry
+	m.updateHistoryDetail()
+}
+
+// updateHistoryDetail shows the selected run, or its output, in the detail
+// viewport while the history tab is open
+func (m *Model) updateHistoryDetail() {
+	if m.tab != historyTab {
+		return
+	}
+	if m.selectedRun == nil {
+		m.detailViewport.SetContent("No runs yet. Commands run with 'interop run' show up here once they finish.")
+		return
+	}
+
+	entry := m.selectedRun
+	sectionStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true)
+	nameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true).
+		Underline(true)
+
+	var content strings.Builder
+	content.WriteString(nameStyle.Render(commandLine(entry)))
+	content.WriteString("\n\n")
+
+	if m.showOutput {
+		content.WriteString(sectionStyle.Render(fmt.Sprintf("Output of run %s:", entry.ID)))
+		content.WriteString("\n")
+		content.WriteString(readOutput(entry))
+		m.detailViewport.SetContent(content.String())
+		return
+	}
+
+	status := "succeeded"
+	if entry.ExitCode != 0 {
+		status = fmt.Sprintf("failed (exit %d)", entry.ExitCode)
+	}
+	content.WriteString(fmt.Sprintf("Status: %s %s  |  Duration: %s\n\n", statusIcon(entry.ExitCode), status, entry.Duration().Round(time.Second)))
+
+	content.WriteString(sectionStyle.Render("Run:"))
+	content.WriteString("\n")
+	content.WriteString(fmt.Sprintf("  ID:        %s\n", entry.ID))
+	if entry.Project != "" {
+		content.WriteString(fmt.Sprintf("  Project:   %s\n", entry.Project))
+	}
+	content.WriteString(fmt.Sprintf("  Directory: %s\n", entry.Dir))
+	content.WriteString(fmt.Sprintf("  Started:   %s\n", entry.Started.Format("2006-01-02 15:04:05")))
+	content.WriteString(fmt.Sprintf("  Finished:  %s\n\n", entry.Finished.Format("2006-01-02 15:04:05")))
+
+	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+	content.WriteString(helpStyle.Render("Press enter to view the output, r to run it again"))
+	m.detailViewport.SetContent(content.String())
+}
+
+// readOutput returns the end of the run's captured output
+func readOutput(entry *history.Entry) string {
+	if entry.OutputFile == "" {
+		return "The output wasn't captured, set capture_output in [history] to keep it."
+	}
+	data, err := os.ReadFile(entry.OutputFile)
+	if err != nil {
+		return fmt.Sprintf("Failed to read the output: %v", err)
+	}
+	if len(data) == 0 {
+		return "(no output)"
+	}
+	// Escape sequences would move the cursor around the output view
+	if len(data) > maxOutputBytes {
+		return "... (earlier output in the file)\n" + termtext.Strip(termtext.Tail(string(data), maxOutputBytes))
+	}
+	return termtext.Strip(string(data))
+}
+
+// commandLine returns the command of a run with its arguments
+func commandLine(entry *history.Entry) string {
+	if len(entry.Args) == 0 {
+		return entry.Command
+	}
+	return entry.Command + " " + strings.Join(entry.Args, " ")
+}
+
+// statusIcon returns the icon of a run that exited with exitCode
+func statusIcon(exitCode int) string {
+	if exitCode == 0 {
+		return "✅"
+	}
+	return "❌"
+}
+
+// renderTabs renders the tab bar above the lists
+func (m Model) renderTabs() string {
+	active := lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true).Underline(true)
+	inactive := lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+
+	tabs := []string{"Commands", "Projects", "Prompts", "History"}
+	rendered := make([]string, len(tabs))
+	for i, tab := range tabs {
+		if i == m.tab {
+			rendered[i] = active.Render(tab)
+		} else {
+			rendered[i] = inactive.Render(tab)
+		}
+	}
+	return strings.Join(rendered, "   ") + inactive.Render("   (tab to switch)")
+}
@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"fmt"
+	"interop/internal/path"
+	"interop/internal/settings"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ProjectItem represents a project in the projects list. The item without a
+// name stands for all projects.
+type ProjectItem struct {
+	name    string
+	project settings.Project
+}
+
+func (i ProjectItem) FilterValue() string { return i.name }
+func (i ProjectItem) Title() string {
+	if i.name == "" {
+		return "All projects"
+	}
+	return i.name
+}
+func (i ProjectItem) Description() string {
+	if i.name == "" {
+		return "Every command, resolved like 'interop run'"
+	}
+	if i.project.Description != "" {
+		return i.project.Description
+	}
+	return i.project.Path
+}
+
+// newProjectList creates the list of the projects tab
+func newProjectList(cfg *settings.Settings) list.Model {
+	l := list.New(projectItems(cfg), list.NewDefaultDelegate(), 0, 0)
+	l.Title = "Projects"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+	return l
+}
+
+// projectItems returns the list items of the projects of cfg sorted by name,
+// after the item for all projects
+func projectItems(cfg *settings.Settings) []list.Item {
+	names := make([]string, 0, len(cfg.Projects))
+	for name := range cfg.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]list.Item, 0, len(names)+1)
+	items = append(items, ProjectItem{})
+	for _, name := range names {
+		items = append(items, ProjectItem{name: name, project: cfg.Projects[name]})
+	}
+	return items
+}
+
+// setProjects replaces the projects of the list, keeping the selection when
+// the project still exists
+func (m *Model) setProjects(items []list.Item) {
+	selected := ""
+	if m.selectedProject != nil {
+		selected = m.selectedProject.name
+	}
+
+	index := 0
+	for i, item := range items {
+		if item.(ProjectItem).name == selected {
+			index = i
+		}
+	}
+	m.projects.SetItems(items)
+	m.projects.Select(index)
+	m.selectProjectItem()
+}
+
+// updateProjectsMode handles keys on the projects tab
+func (m Model) updateProjectsMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, keys.Enter):
+		if m.selectedProject != nil {
+			m.setProject(m.selectedProject.name)
+			m.tab = commandsTab
+			m.focusedPanel = 0
+			m.updateDetailView()
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.Left):
+		m.focusedPanel = 0
+		return m, nil
+
+	case key.Matches(msg, keys.Right):
+		m.focusedPanel = 2
+		return m, nil
+	}
+
+	// Scroll the details when they're focused, otherwise move in the list
+	if m.focusedPanel == 2 {
+		m.detailViewport, cmd = m.detailViewport.Update(msg)
+		return m, cmd
+	}
+	m.projects, cmd = m.projects.Update(msg)
+	m.selectProjectItem()
+	return m, cmd
+}
+
+// setProject lists only the commands of the project named name, under their
+// aliases, and runs them with its path and env. An empty name lists all
+// commands again.
+func (m *Model) setProject(name string) {
+	m.project = name
+	m.list.Title = "Commands"
+	if name != "" {
+		m.list.Title = "Commands of " + name
+	}
+	m.originalCommands = commandItems(m.cfg, name)
+	m.selectedCommand = nil
+	m.filterCommands(m.searchInput.Value())
+}
+
+// selectProjectItem shows the project selected in the projects list
+func (m *Model) selectProjectItem() {
+	m.selectedProject = nil
+	if item, ok := m.projects.SelectedItem().(ProjectItem); ok {
+		m.selectedProject = &item
+	}
+	m.updateProjectDetail()
+}
+
+// updateProjectDetail shows the selected project in the detail viewport while
+// the projects tab is open
+func (m *Model) updateProjectDetail() {
+	if m.tab != projectsTab {
+		return
+	}
+	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+	sectionStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true)
+	nameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true).
+		Underline(true)
+
+	if m.selectedProject == nil || m.selectedProject.name == "" {
+		content := nameStyle.Render("All projects") + "\n\n" +
+			"Lists every command. Commands bound to a project run in its directory, like 'interop run' resolves them.\n\n" +
+			helpStyle.Render("Press enter to list all commands")
+		if len(m.cfg.Projects) == 0 {
+			content += "\n\nNo projects configured. Define them under [projects] in settings.toml."
+		}
+		m.detailViewport.SetContent(content)
+		return
+	}
+
+	item := *m.selectedProject
+	var content strings.Builder
+	content.WriteString(nameStyle.Render(item.name))
+	if item.name == m.project {
+		content.WriteString(helpStyle.Render("  (current)"))
+	}
+	content.WriteString("\n\n")
+
+	valid := "✓"
+	if info, err := path.ExpandAndValidate(item.project.Path); err != nil || !info.Exists {
+		valid = "✗ (doesn't exist)"
+	}
+	content.WriteString(fmt.Sprintf("Path: %s %s\n\n", item.project.Path, valid))
+
+	if item.project.Description != "" {
+		content.WriteString(sectionStyle.Render("Description:"))
+		content.WriteString("\n")
+		content.WriteString(item.project.Description)
+		content.WriteString("\n\n")
+	}
+
+	commands, _ := settings.ResolveProjectCommands(m.cfg, item.name)
+	content.WriteString(sectionStyle.Render("Commands:"))
+	content.WriteString("\n")
+	if len(commands) == 0 {
+		content.WriteString("  None\n")
+	}
+	for _, cmd := range commands {
+		content.WriteString("  • " + cmd.Name())
+		if cmd.Alias.Alias != "" {
+			content.WriteString(helpStyle.Render(" (" + cmd.CommandName + ")"))
+		}
+		if cmd.Missing {
+			content.WriteString(helpStyle.Render(" (referenced command not found)"))
+		}
+		content.WriteString("\n")
+	}
+	content.WriteString("\n")
+
+	if len(item.project.Env) > 0 {
+		names := make([]string, 0, len(item.project.Env))
+		for name := range item.project.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		content.WriteString(sectionStyle.Render("Environment:"))
+		content.WriteString("\n")
+		for _, name := range names {
+			content.WriteString(fmt.Sprintf("  %s=%s\n", name, item.project.Env[name]))
+		}
+		content.WriteString("\n")
+	}
+
+	content.WriteString(helpStyle.Render("Press enter to list and run only this project's commands"))
+	m.detailViewport.SetContent(content.String())
+}
@@ -0,0 +1,176 @@
+package tui
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PromptItem represents a prompt in the prompts list
+type PromptItem struct {
+	name   string
+	prompt settings.PromptConfig
+}
+
+func (i PromptItem) FilterValue() string { return i.name }
+func (i PromptItem) Title() string       { return i.name }
+func (i PromptItem) Description() string {
+	if i.prompt.Description != "" {
+		return i.prompt.Description
+	}
+	return "No description"
+}
+
+// servers returns the MCP servers serving the prompt
+func (i PromptItem) servers() string {
+	if len(i.prompt.MCP) == 0 {
+		return "default"
+	}
+	return i.prompt.MCP.String()
+}
+
+// newPromptList creates the list of the prompts tab
+func newPromptList(cfg *settings.Settings) list.Model {
+	l := list.New(promptItems(cfg), list.NewDefaultDelegate(), 0, 0)
+	l.Title = "Prompts"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+	return l
+}
+
+// promptItems returns the list items of the prompts of cfg, sorted by name
+func promptItems(cfg *settings.Settings) []list.Item {
+	names := make([]string, 0, len(cfg.Prompts))
+	for name := range cfg.Prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]list.Item, len(names))
+	for i, name := range names {
+		items[i] = PromptItem{name: name, prompt: cfg.Prompts[name]}
+	}
+	return items
+}
+
+// setPrompts replaces the prompts of the list, keeping the selection when the
+// prompt still exists
+func (m *Model) setPrompts(items []list.Item) {
+	selected := ""
+	if m.selectedPrompt != nil {
+		selected = m.selectedPrompt.name
+	}
+
+	index := 0
+	for i, item := range items {
+		if item.(PromptItem).name == selected {
+			index = i
+		}
+	}
+	m.prompts.SetItems(items)
+	m.prompts.Select(index)
+	m.selectPromptItem()
+}
+
+// updatePromptsMode handles keys on the prompts tab
+func (m Model) updatePromptsMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, keys.Left):
+		m.focusedPanel = 0
+		return m, nil
+
+	case key.Matches(msg, keys.Right):
+		m.focusedPanel = 2
+		return m, nil
+	}
+
+	// Scroll the details when they're focused, otherwise move in the list
+	if m.focusedPanel == 2 {
+		m.detailViewport, cmd = m.detailViewport.Update(msg)
+		return m, cmd
+	}
+	m.prompts, cmd = m.prompts.Update(msg)
+	m.selectPromptItem()
+	return m, cmd
+}
+
+// selectPromptItem shows the prompt selected in the prompts list
+func (m *Model) selectPromptItem() {
+	m.selectedPrompt = nil
+	if item, ok := m.prompts.SelectedItem().(PromptItem); ok {
+		m.selectedPrompt = &item
+	}
+	m.updatePromptDetail()
+}
+
+// updatePromptDetail shows the selected prompt in the detail viewport while
+// the prompts tab is open
+func (m *Model) updatePromptDetail() {
+	if m.tab != promptsTab {
+		return
+	}
+	if m.selectedPrompt == nil {
+		m.detailViewport.SetContent("No prompts configured. Define them under [prompts] in settings.toml to serve them over MCP.")
+		return
+	}
+
+	item := *m.selectedPrompt
+	sectionStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true)
+	nameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true).
+		Underline(true)
+
+	var content strings.Builder
+	content.WriteString(nameStyle.Render(item.name))
+	content.WriteString("\n\n")
+	content.WriteString(fmt.Sprintf("MCP servers: %s\n\n", item.servers()))
+
+	if item.prompt.Description != "" {
+		content.WriteString(sectionStyle.Render("Description:"))
+		content.WriteString("\n")
+		content.WriteString(item.prompt.Description)
+		content.WriteString("\n\n")
+	}
+
+	if len(item.prompt.Arguments) > 0 {
+		content.WriteString(sectionStyle.Render("Arguments:"))
+		content.WriteString("\n")
+		for _, arg := range item.prompt.Arguments {
+			required := "optional"
+			if arg.Required {
+				required = "required"
+			}
+			content.WriteString(fmt.Sprintf("  • %s (%s)", arg.Name, required))
+			if arg.Description != "" {
+				content.WriteString(": " + arg.Description)
+			}
+			content.WriteString("\n")
+		}
+		content.WriteString("\n")
+	}
+
+	if len(item.prompt.Includes) > 0 {
+		content.WriteString(fmt.Sprintf("Includes: %s\n", strings.Join(item.prompt.Includes, ", ")))
+	}
+	if len(item.prompt.Suggested) > 0 {
+		content.WriteString(fmt.Sprintf("Suggested tools: %s\n", strings.Join(item.prompt.Suggested, ", ")))
+	}
+	if len(item.prompt.Includes) > 0 || len(item.prompt.Suggested) > 0 {
+		content.WriteString("\n")
+	}
+
+	content.WriteString(sectionStyle.Render("Content:"))
+	content.WriteString("\n")
+	content.WriteString(item.prompt.Content)
+	m.detailViewport.SetContent(content.String())
+}
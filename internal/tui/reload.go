@@ -0,0 +1,250 @@
+package tui
+
+import (
+	"fmt"
+	"interop/internal/settings"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// configCheckInterval is how often the settings files are checked for changes
+// when the file system can't report them
+const configCheckInterval = time.Second
+
+// configTickMsg triggers a check of the settings files
+type configTickMsg time.Time
+
+// configReloadedMsg carries the settings read by checkConfig or reloadConfig,
+// cfg is nil when the files didn't change
+type configReloadedMsg struct {
+	cfg         *settings.Settings
+	fingerprint string
+	err         error
+	manual      bool // Requested with the reload key rather than a file change
+}
+
+// configWatcher reports changes to the settings files through fsnotify. It
+// watches their directories, as editors often save by replacing a file.
+type configWatcher struct {
+	events *fsnotify.Watcher
+}
+
+// newConfigWatcher watches the directories of the settings files of cfg. It
+// returns nil when the file system can't report changes, and the files are
+// checked every configCheckInterval instead.
+func newConfigWatcher(cfg *settings.Settings) *configWatcher {
+	events, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil
+	}
+	w := &configWatcher{events: events}
+	w.watch(cfg)
+	return w
+}
+
+// watch adds the directories of the settings files of cfg that aren't
+// watched yet, such as a command directory added by a reload. Directories
+// that don't exist are skipped.
+func (w *configWatcher) watch(cfg *settings.Settings) {
+	watched := w.events.WatchList()
+	for _, dir := range settings.ConfigDirs(cfg) {
+		if !slices.Contains(watched, dir) {
+			w.events.Add(dir)
+		}
+	}
+}
+
+// close stops watching
+func (w *configWatcher) close() {
+	if w != nil {
+		w.events.Close()
+	}
+}
+
+// configTick waits for the next change of the settings files, or schedules
+// the next check of them without a watcher
+func (w *configWatcher) configTick() tea.Cmd {
+	if w == nil {
+		return tea.Tick(configCheckInterval, func(t time.Time) tea.Msg {
+			return configTickMsg(t)
+		})
+	}
+	return func() tea.Msg {
+		for {
+			select {
+			case event, ok := <-w.events.Events:
+				if !ok {
+					return nil
+				}
+				if event.Op != fsnotify.Chmod && strings.HasSuffix(event.Name, ".toml") {
+					return configTickMsg(time.Now())
+				}
+			case _, ok := <-w.events.Errors:
+				if !ok {
+					return nil
+				}
+				// Changes may have been missed, check the files
+				return configTickMsg(time.Now())
+			}
+		}
+	}
+}
+
+// checkConfig reloads the settings when their files differ from fingerprint
+func checkConfig(cfg *settings.Settings, fingerprint string) tea.Cmd {
+	return func() tea.Msg {
+		current := settings.ConfigFingerprint(cfg)
+		if current == fingerprint {
+			return configReloadedMsg{fingerprint: current}
+		}
+		return loadConfig(false)
+	}
+}
+
+// reloadConfig reloads the settings on request
+func reloadConfig() tea.Msg {
+	return loadConfig(true)
+}
+
+// loadConfig reads the settings files again
+func loadConfig(manual bool) configReloadedMsg {
+	cfg, err := settings.Reload()
+	msg := configReloadedMsg{cfg: cfg, err: err, manual: manual}
+	if cfg != nil {
+		msg.fingerprint = settings.ConfigFingerprint(cfg)
+	}
+	return msg
+}
+
+// updateConfig handles the messages of live reloading, ok is false for other
+// messages
+func (m Model) updateConfig(msg tea.Msg) (Model, tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case configTickMsg:
+		return m, checkConfig(m.cfg, m.fingerprint), true
+
+	case configReloadedMsg:
+		next := m.configWatcher.configTick()
+		if msg.manual {
+			// The automatic checks keep their own schedule
+			next = nil
+		}
+		if msg.fingerprint != "" {
+			m.fingerprint = msg.fingerprint
+		}
+		switch {
+		case msg.err != nil:
+			// Keep the commands that loaded last, the next save is checked again
+			m.status = fmt.Sprintf("Failed to reload the configuration: %v", msg.err)
+		case msg.cfg != nil:
+			m.setConfig(msg.cfg)
+			if m.configWatcher != nil {
+				m.configWatcher.watch(msg.cfg)
+			}
+			m.status = fmt.Sprintf("Reloaded the configuration: %d commands", len(msg.cfg.Commands))
+		}
+		return m, next, true
+	}
+	return m, nil, false
+}
+
+// setConfig replaces the commands with those of cfg, keeping the search and,
+// when the command still exists, the selection
+func (m *Model) setConfig(cfg *settings.Settings) {
+	selected := ""
+	if m.selectedCommand != nil {
+		selected = m.selectedCommand.name
+	}
+
+	m.cfg = cfg
+	if _, exists := cfg.Projects[m.project]; !exists {
+		m.project = ""
+	}
+	m.setProject(m.project)
+	m.selectedCommand = nil
+	m.selectCommand(selected)
+	m.setProjects(projectItems(cfg))
+	m.setPrompts(promptItems(cfg))
+
+	switch m.tab {
+	case commandsTab:
+		m.updateDetailView()
+	case projectsTab:
+		m.updateProjectDetail()
+	case historyTab:
+		m.updateHistoryDetail()
+	}
+}
+
+// commandItems returns the list items of the commands of cfg, sorted by name.
+// With a project, only the commands bound to it are listed, under their
+// aliases.
+func commandItems(cfg *settings.Settings, project string) []list.Item {
+	commands := make(map[string]string) // Listed name -> command name
+	if project != "" {
+		bound, _ := settings.ResolveProjectCommands(cfg, project)
+		for _, cmd := range bound {
+			if !cmd.Missing {
+				commands[cmd.Name()] = cmd.CommandName
+			}
+		}
+	} else {
+		for name := range cfg.Commands {
+			commands[name] = name
+		}
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	projects := make(map[string][]string)
+	for project, config := range cfg.Projects {
+		for _, alias := range config.Commands {
+			projects[alias.CommandName] = append(projects[alias.CommandName], project)
+		}
+	}
+	remoteDir := ""
+	if appDir, err := settings.GetAppDir(); err == nil {
+		remoteDir = filepath.Join(appDir, "config.d.remote")
+	}
+
+	items := make([]list.Item, 0, len(names))
+	for _, name := range names {
+		cmdName := commands[name]
+		cmd := cfg.Commands[cmdName]
+		sort.Strings(projects[cmdName])
+		projects[cmdName] = slices.Compact(projects[cmdName]) // Commands bound more than once under different aliases
+		item := CommandItem{
+			name:         name,
+			description:  cmd.Description,
+			cmd:          cmd.Cmd,
+			script:       cmd.Script,
+			interpreter:  cmd.Interpreter,
+			scriptDeps:   cmd.Dependencies,
+			isEnabled:    cmd.IsEnabled,
+			isExecutable: cmd.IsExecutable,
+			arguments:    cmd.Arguments,
+			examples:     cmd.Examples,
+			preExec:      cmd.PreExec,
+			postExec:     cmd.PostExec,
+			projects:     projects[cmdName],
+			remote:       remoteDir != "" && filepath.Dir(cmd.SourceFile) == remoteDir,
+			mcp:          cmd.MCP.String(),
+			tags:         cmd.Tags,
+		}
+		if cmdName != name {
+			item.aliasOf = cmdName
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -113,11 +113,9 @@ func EnableColors() {
 	defaultLogger.useColors = true
 }
 
-// Error makes the program exit with a non-zero status when an error occurs
+// Error prints a red "Error: …" message to stderr. Exiting is left to the caller.
 func Error(format string, args ...interface{}) {
 	defaultLogger.Error(format, args...)
-	// Make the program exit with a non-zero status when an error occurs
-	os.Exit(1)
 }
 
 // Warning prints a yellow "Warning: …" message to stdout.
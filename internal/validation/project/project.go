@@ -51,20 +51,27 @@ func (v *Validator) ValidateAll() ValidationResult {
 			projectPath = filepath.Join(homeDir, projectPath)
 		}
 
-		if filepath.IsAbs(project.Path) && !filepath.HasPrefix(project.Path, homeDir) {
-			message := fmt.Sprintf("Project '%s' path must be inside $HOME: %s", name, project.Path)
+		if !v.settings.IsProjectPathAllowed(projectPath) {
+			message := withLocation(fmt.Sprintf("Project '%s' path must be inside allowed_project_roots: %s", name, project.Path), project)
 			validationErrors = append(validationErrors, *errors.NewProjectError(message, nil, false))
 		}
 
 		if _, err := os.Stat(projectPath); os.IsNotExist(err) {
-			message := fmt.Sprintf("Project '%s' path does not exist: %s", name, projectPath)
+			message := withLocation(fmt.Sprintf("Project '%s' path does not exist: %s", name, projectPath), project)
 			validationErrors = append(validationErrors, *errors.NewProjectError(message, err, true))
 		}
 
+		if project.Extends != "" {
+			if _, ok := v.settings.ProjectTemplates[project.Extends]; !ok {
+				message := withLocation(fmt.Sprintf("Project '%s' extends undefined template: %s", name, project.Extends), project)
+				validationErrors = append(validationErrors, *errors.NewProjectError(message, nil, true))
+			}
+		}
+
 		// Validate project commands
 		for _, alias := range project.Commands {
 			if _, ok := v.settings.Commands[alias.CommandName]; !ok {
-				message := fmt.Sprintf("Project '%s' references undefined command: %s", name, alias.CommandName)
+				message := withLocation(fmt.Sprintf("Project '%s' references undefined command: %s", name, alias.CommandName), project)
 				validationErrors = append(validationErrors, *errors.NewProjectError(message, nil, true))
 			}
 		}
@@ -106,20 +113,27 @@ func (v *Validator) ValidateProject(projectName string) ValidationResult {
 		projectPath = filepath.Join(homeDir, projectPath)
 	}
 
-	if filepath.IsAbs(project.Path) && !filepath.HasPrefix(project.Path, homeDir) {
-		message := fmt.Sprintf("Project '%s' path must be inside $HOME: %s", projectName, project.Path)
+	if !v.settings.IsProjectPathAllowed(projectPath) {
+		message := withLocation(fmt.Sprintf("Project '%s' path must be inside allowed_project_roots: %s", projectName, project.Path), project)
 		validationErrors = append(validationErrors, *errors.NewProjectError(message, nil, false))
 	}
 
 	if _, err := os.Stat(projectPath); os.IsNotExist(err) {
-		message := fmt.Sprintf("Project '%s' path does not exist: %s", projectName, projectPath)
+		message := withLocation(fmt.Sprintf("Project '%s' path does not exist: %s", projectName, projectPath), project)
 		validationErrors = append(validationErrors, *errors.NewProjectError(message, err, true))
 	}
 
+	if project.Extends != "" {
+		if _, ok := v.settings.ProjectTemplates[project.Extends]; !ok {
+			message := withLocation(fmt.Sprintf("Project '%s' extends undefined template: %s", projectName, project.Extends), project)
+			validationErrors = append(validationErrors, *errors.NewProjectError(message, nil, true))
+		}
+	}
+
 	// Validate project commands
 	for _, alias := range project.Commands {
 		if _, ok := v.settings.Commands[alias.CommandName]; !ok {
-			message := fmt.Sprintf("Project '%s' references undefined command: %s", projectName, alias.CommandName)
+			message := withLocation(fmt.Sprintf("Project '%s' references undefined command: %s", projectName, alias.CommandName), project)
 			validationErrors = append(validationErrors, *errors.NewProjectError(message, nil, true))
 		}
 	}
@@ -145,3 +159,11 @@ func LogValidationErrors(result ValidationResult) {
 		}
 	}
 }
+
+// withLocation appends where the project was defined to a message
+func withLocation(message string, project settings.Project) string {
+	if location := project.Location(); location != "" {
+		return fmt.Sprintf("%s (%s)", message, location)
+	}
+	return message
+}
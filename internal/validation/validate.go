@@ -1,21 +1,34 @@
 package validation
 
 import (
+	"context"
 	"fmt"
 	"interop/internal/command/factory"
+	"interop/internal/condition"
 	"interop/internal/errors"
 	"interop/internal/execution"
 	"interop/internal/logging"
+	pathutil "interop/internal/path"
+	"interop/internal/remote"
+	"interop/internal/secrets"
 	"interop/internal/settings"
 	"interop/internal/shell"
+	"interop/internal/tracing"
 	"interop/internal/validation/project"
+	"interop/internal/watch"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/BurntSushi/toml"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CommandType represents the type of a command
@@ -41,6 +54,32 @@ type ValidationError struct {
 	Severe  bool // If true, this error should prevent operation
 }
 
+// Report is the outcome of validating a configuration, for structured output
+type Report struct {
+	Valid  bool    `json:"valid"` // None of the issues is severe
+	Issues []Issue `json:"issues"`
+}
+
+// Issue is a validation error in a Report
+type Issue struct {
+	Severity string `json:"severity"` // "error" for severe issues, "warning" otherwise
+	Message  string `json:"message"`
+}
+
+// NewReport builds the report of the issues validation found
+func NewReport(errs []ValidationError) Report {
+	report := Report{Valid: true, Issues: make([]Issue, 0, len(errs))}
+	for _, err := range errs {
+		severity := "warning"
+		if err.Severe {
+			severity = "error"
+			report.Valid = false
+		}
+		report.Issues = append(report.Issues, Issue{Severity: severity, Message: err.Message})
+	}
+	return report
+}
+
 // isFileExecutable checks if a file exists and has executable permissions
 func isFileExecutable(path string) (bool, error) {
 	fileInfo, err := os.Stat(path)
@@ -61,6 +100,51 @@ func isFileExecutable(path string) (bool, error) {
 	return fileInfo.Mode()&0100 != 0, nil
 }
 
+// LoadErrors converts the error returned by settings.Load into severe
+// validation errors, one per configuration file that failed to parse
+func LoadErrors(err error) []ValidationError {
+	if err == nil {
+		return nil
+	}
+
+	loadErrors := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		loadErrors = joined.Unwrap()
+	}
+
+	result := make([]ValidationError, 0, len(loadErrors))
+	for _, loadErr := range loadErrors {
+		result = append(result, ValidationError{Message: loadErr.Error(), Severe: true})
+	}
+	return result
+}
+
+// ValidateAll validates the commands and projects in the settings. Project
+// errors already reported by command validation are left out.
+func ValidateAll(cfg *settings.Settings) []ValidationError {
+	cmdErrors := ValidateCommands(cfg)
+	projectResult := project.NewValidator(cfg).ValidateAll()
+
+	allErrors := cmdErrors
+	for _, err := range projectResult.Errors {
+		isDuplicate := false
+		for _, cmdErr := range cmdErrors {
+			if cmdErr.Message == err.Error() {
+				isDuplicate = true
+				break
+			}
+		}
+
+		if !isDuplicate {
+			allErrors = append(allErrors, ValidationError{
+				Message: err.Error(),
+				Severe:  err.Severe,
+			})
+		}
+	}
+	return allErrors
+}
+
 // ValidateCommands validates all commands in the settings
 // Returns a list of validation errors
 func ValidateCommands(cfg *settings.Settings) []ValidationError {
@@ -91,7 +175,7 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 
 			// Check if command is bound to multiple projects without alias
 			if aliasConfig.Alias == "" {
-				if prevProject, used := usedCommands[aliasConfig.CommandName]; used {
+				if prevProject, used := usedCommands[aliasConfig.CommandName]; used && !sameProjectGroup(cfg, prevProject, projectName) {
 					errors = append(errors, ValidationError{
 						Message: fmt.Sprintf("Command '%s' is bound to multiple projects ('%s' and '%s') without alias",
 							aliasConfig.CommandName, prevProject, projectName),
@@ -101,7 +185,7 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 				usedCommands[aliasConfig.CommandName] = projectName
 			} else {
 				// Check if alias is unique across projects
-				if prevProject, used := usedAliases[aliasConfig.Alias]; used {
+				if prevProject, used := usedAliases[aliasConfig.Alias]; used && !sameProjectGroup(cfg, prevProject, projectName) {
 					errors = append(errors, ValidationError{
 						Message: fmt.Sprintf("Alias '%s' is used in multiple projects ('%s' and '%s')",
 							aliasConfig.Alias, prevProject, projectName),
@@ -113,6 +197,38 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 		}
 	}
 
+	// Validate command inheritance chains
+	errors = append(errors, validateCommandExtends(cfg)...)
+	errors = append(errors, validateDependencies(cfg)...)
+
+	// Validate references to captured hook output
+	errors = append(errors, validateHookCaptures(cfg)...)
+
+	// Validate command and hook conditions
+	errors = append(errors, validateConditions(cfg)...)
+	errors = append(errors, validateNotifications(cfg)...)
+	errors = append(errors, validateTimeouts(cfg)...)
+	errors = append(errors, validateCaching(cfg)...)
+	errors = append(errors, validateLimits(cfg)...)
+	errors = append(errors, validateWatch(cfg)...)
+	errors = append(errors, validateProjectPathScopes(cfg)...)
+	errors = append(errors, validateDefaultServer(cfg)...)
+	errors = append(errors, validateFileArguments(cfg)...)
+	errors = append(errors, validateScripts(cfg)...)
+	errors = append(errors, validatePrompts(cfg)...)
+	errors = append(errors, validateEnv(cfg)...)
+	errors = append(errors, validateExecutables(cfg)...)
+
+	// Validate the git backend used for remotes
+	errors = append(errors, validateGitBackend(cfg)...)
+
+	if cfg.MaxConcurrentExecutions < 0 {
+		errors = append(errors, ValidationError{
+			Message: fmt.Sprintf("max_concurrent_executions is %d, it must be 0 (no limit) or more", cfg.MaxConcurrentExecutions),
+			Severe:  true,
+		})
+	}
+
 	// Validate command directory conflicts
 	if len(cfg.CommandDirs) > 0 {
 		errors = append(errors, validateCommandDirectoryConflicts(cfg)...)
@@ -122,7 +238,7 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 	usedPorts := make(map[int]string) // track port -> server name mapping
 
 	// Add default MCP port to used ports
-	if cfg.MCPPort > 0 {
+	if cfg.MCPPort > 0 && cfg.DefaultMCPServerEnabled() {
 		usedPorts[cfg.MCPPort] = "default MCP server"
 	}
 
@@ -169,11 +285,11 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 
 	// Validate command MCP references
 	for cmdName, cmd := range cfg.Commands {
-		if cmd.MCP != "" {
-			if _, exists := cfg.MCPServers[cmd.MCP]; !exists {
+		for _, serverName := range cmd.MCP {
+			if _, exists := cfg.MCPServers[serverName]; !exists {
 				errors = append(errors, ValidationError{
-					Message: fmt.Sprintf("Command '%s' references a non-existent MCP server '%s'",
-						cmdName, cmd.MCP),
+					Message: withLocation(fmt.Sprintf("Command '%s' references a non-existent MCP server '%s'",
+						cmdName, serverName), cmd.Location()),
 					Severe: true,
 				})
 			}
@@ -193,7 +309,7 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 
 	// Check executable commands for proper permissions
 	for cmdName, cmd := range cfg.Commands {
-		if cmd.IsExecutable {
+		if cmd.IsExecutable && cmd.Script == "" {
 			// Extract just the command name (first part before whitespace)
 			execName := strings.Fields(cmd.Cmd)[0]
 
@@ -217,13 +333,16 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 					if isExec, err := isFileExecutable(systemPath); err == nil && isExec {
 						execPath = systemPath
 						found = true
+						errors = append(errors, ValidationError{
+							Message: withLocation(fmt.Sprintf("Executable command '%s' runs '%s' from the system PATH only, it may not be portable; copy it to executables/ or document the dependency", cmdName, execName), cmd.Location()),
+						})
 					}
 				}
 			}
 
 			if !found {
 				errors = append(errors, ValidationError{
-					Message: fmt.Sprintf("Executable command '%s' not found in configured search paths or system PATH", cmdName),
+					Message: withLocation(fmt.Sprintf("Executable command '%s' not found in configured search paths or system PATH", cmdName), cmd.Location()),
 					Severe:  false,
 				})
 				continue
@@ -233,12 +352,12 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 			isExec, err := isFileExecutable(execPath)
 			if err != nil {
 				errors = append(errors, ValidationError{
-					Message: fmt.Sprintf("Error checking executable permissions for '%s': %v", cmdName, err),
+					Message: withLocation(fmt.Sprintf("Error checking executable permissions for '%s': %v", cmdName, err), cmd.Location()),
 					Severe:  false,
 				})
 			} else if !isExec {
 				errors = append(errors, ValidationError{
-					Message: fmt.Sprintf("Command '%s' is marked as executable but doesn't have executable permissions. Use 'chmod +x %s' to fix.", cmdName, execPath),
+					Message: withLocation(fmt.Sprintf("Command '%s' is marked as executable but doesn't have executable permissions. Use 'chmod +x %s' to fix.", cmdName, execPath), cmd.Location()),
 					Severe:  false,
 				})
 			}
@@ -248,6 +367,768 @@ func ValidateCommands(cfg *settings.Settings) []ValidationError {
 	return errors
 }
 
+// sameProjectGroup reports whether two projects were expanded from the same glob project.
+// Such sub-projects intentionally share command bindings and aliases.
+func sameProjectGroup(cfg *settings.Settings, a, b string) bool {
+	group := cfg.Projects[a].Group
+	return group != "" && group == cfg.Projects[b].Group
+}
+
+// projectContainsDir reports whether dir is inside the project's directory
+func projectContainsDir(projectData settings.Project, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(projectData.Path, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// locationLine matches the line number in a location added by withLocation
+var locationLine = regexp.MustCompile(`:[0-9]+\)`)
+
+// NewSevereErrors returns the severe errors in after that aren't in before,
+// so a change to the settings is only blamed for the errors it introduced.
+// Line numbers in locations are ignored, edits shift them.
+func NewSevereErrors(before, after []ValidationError) []ValidationError {
+	known := make(map[string]bool)
+	for _, err := range before {
+		known[locationLine.ReplaceAllString(err.Message, ")")] = true
+	}
+	var introduced []ValidationError
+	for _, err := range after {
+		if err.Severe && !known[locationLine.ReplaceAllString(err.Message, ")")] {
+			introduced = append(introduced, err)
+		}
+	}
+	return introduced
+}
+
+// withLocation appends where the entry was defined to a message, see
+// settings.SourceLocation
+func withLocation(message, location string) string {
+	if location == "" {
+		return message
+	}
+	return fmt.Sprintf("%s (%s)", message, location)
+}
+
+// validateCommandExtends checks that every extends chain ends at a defined
+// command without looping back on itself
+func validateCommandExtends(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	names := make([]string, 0, len(cfg.Commands))
+	for name := range cfg.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		chain := []string{name}
+		visited := map[string]bool{name: true}
+		for current := cfg.Commands[name].Extends; current != ""; current = cfg.Commands[current].Extends {
+			chain = append(chain, current)
+			if visited[current] {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' has a cyclic extends chain: %s", name, strings.Join(chain, " -> ")), cfg.Commands[name].Location()),
+					Severe:  true,
+				})
+				break
+			}
+			visited[current] = true
+
+			if _, exists := cfg.Commands[current]; !exists {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' extends undefined command '%s'", name, current), cfg.Commands[name].Location()),
+					Severe:  true,
+				})
+				break
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateDependencies checks that the depends_on of every command lead to
+// defined commands without a cycle, and that enabled commands only depend on
+// enabled ones
+func validateDependencies(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	names := make([]string, 0, len(cfg.Commands))
+	for name, cmd := range cfg.Commands {
+		if len(cmd.DependsOn) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmd := cfg.Commands[name]
+		order, err := cfg.DependencyOrder(name)
+		if err != nil {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' has invalid dependencies: %v", name, err), cmd.Location()),
+				Severe:  true,
+			})
+			continue
+		}
+		if !cmd.IsEnabled {
+			continue
+		}
+		for _, dependency := range order {
+			if !cfg.Commands[dependency].IsEnabled {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' depends on disabled command '%s'", name, dependency), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateHookCaptures checks that every ${hook:<name>} reference in a command
+// is captured by one of its pre_exec hooks
+func validateHookCaptures(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		captured := make(map[string]bool)
+		for _, hook := range cmd.PreExec {
+			if hook.Capture != "" {
+				captured[hook.Capture] = true
+			}
+		}
+		for _, hook := range cmd.PostExec {
+			if hook.Capture != "" {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' post_exec hook captures '%s', but only pre_exec output can be used", cmdName, hook.Capture), cmd.Location()),
+					Severe:  false,
+				})
+			}
+		}
+
+		sources := []string{cmd.Cmd, cmd.Script}
+		for _, value := range cmd.Env {
+			sources = append(sources, value)
+		}
+		for _, hook := range cmd.PostExec {
+			sources = append(sources, hook.Cmd)
+		}
+
+		reported := make(map[string]bool)
+		for _, source := range sources {
+			for _, match := range settings.HookCapturePattern.FindAllStringSubmatch(source, -1) {
+				name := match[1]
+				if captured[name] || reported[name] {
+					continue
+				}
+				reported[name] = true
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' references ${hook:%s}, which no pre_exec hook captures", cmdName, name), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateNotifications checks that notify_after durations parse
+func validateNotifications(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		if _, _, err := cmd.NotifyThreshold(); err != nil {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' has an %v", cmdName, err), cmd.Location()),
+				Severe:  true,
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateTimeouts checks that timeout durations parse. Commands with an
+// invalid timeout still run, without one.
+func validateTimeouts(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		if _, err := cmd.RunTimeout(); err != nil {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' runs without a timeout, it has an %v", cmdName, err), cmd.Location()),
+				Severe:  false,
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateCaching checks the cache_ttl option of commands
+func validateCaching(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		if _, _, err := cmd.CacheDuration(); err != nil {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' has an %v", cmdName, err), cmd.Location()),
+				Severe:  true,
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateLimits checks the nice, max_memory and max_cpu_seconds options
+func validateLimits(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		if err := cmd.ValidateLimits(); err != nil {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' has an %v", cmdName, err), cmd.Location()),
+				Severe:  true,
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateWatch checks the watch globs of commands. A bad glob only breaks
+// run --watch, so it's reported as a warning.
+func validateWatch(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		for _, pattern := range cmd.Watch {
+			if err := watch.ValidatePattern(pattern); err != nil {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' has an %v", cmdName, err), cmd.Location()),
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateProjectPathScopes checks that the allowed_projects of commands are
+// configured projects, and aren't set along with allow_project_path = false
+func validateProjectPathScopes(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		if len(cmd.AllowedProjects) > 0 && !cmd.ProjectPathAllowed() {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' sets allowed_projects but allow_project_path = false, so the projects are never used", cmdName), cmd.Location()),
+			})
+		}
+		for _, project := range cmd.AllowedProjects {
+			if _, exists := cfg.Projects[project]; !exists {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' allows project_path in project '%s', which is not configured", cmdName, project), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateDefaultServer checks that with default_mcp_enabled = false every
+// enabled command and prompt names a server, as nothing serves the rest
+func validateDefaultServer(cfg *settings.Settings) []ValidationError {
+	if cfg.DefaultMCPServerEnabled() {
+		return nil
+	}
+	var errors []ValidationError
+
+	if cfg.MCPPort != 0 {
+		errors = append(errors, ValidationError{
+			Message: fmt.Sprintf("mcp_port %d is not used, the default MCP server is disabled", cfg.MCPPort),
+		})
+	}
+
+	for cmdName, cmd := range cfg.Commands {
+		if !cmd.IsEnabled || servedByNamedServer(cfg, cmdName, cmd) {
+			continue
+		}
+		errors = append(errors, ValidationError{
+			Message: withLocation(fmt.Sprintf("Command '%s' is not served by any MCP server, the default MCP server is disabled; set mcp to one of mcp_servers", cmdName), cmd.Location()),
+			Severe:  true,
+		})
+	}
+
+	for promptName, prompt := range cfg.Prompts {
+		if len(prompt.MCP) == 0 {
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' is not served by any MCP server, the default MCP server is disabled; set mcp to one of mcp_servers", promptName),
+				Severe:  true,
+			})
+		}
+	}
+
+	return errors
+}
+
+// servedByNamedServer reports whether a server of mcp_servers, or of a
+// project, serves the command
+func servedByNamedServer(cfg *settings.Settings, cmdName string, cmd settings.CommandConfig) bool {
+	for serverName := range cfg.MCPServers {
+		if cfg.ServedBy(serverName, cmdName, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateScripts checks that script commands don't also set cmd or
+// is_executable, that interpreter and dependencies are only set along with a
+// script, and that dependencies are only listed for python and node scripts
+func validateScripts(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		switch {
+		case cmd.Script != "" && cmd.Cmd != "":
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' sets both cmd and script, only one of them can be used", cmdName), cmd.Location()),
+				Severe:  true,
+			})
+		case cmd.Script != "" && cmd.IsExecutable:
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' sets script and is_executable, scripts always run with their interpreter", cmdName), cmd.Location()),
+				Severe:  true,
+			})
+		case cmd.Script == "" && (cmd.Interpreter != "" || len(cmd.Dependencies) > 0):
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' sets an interpreter or dependencies but no script, they are ignored", cmdName), cmd.Location()),
+			})
+		case len(cmd.Dependencies) > 0:
+			if runtime := execution.ScriptRuntime(cmd.Script, cmd.Interpreter); runtime != execution.RuntimePython && runtime != execution.RuntimeNode {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' lists dependencies, which are only installed for python and node scripts", cmdName), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateExecutables reports files in executables and executables.remote
+// that no command refers to, by name or path, in its cmd, script or hooks
+func validateExecutables(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	appDir, err := settings.GetAppDir()
+	if err != nil {
+		return nil
+	}
+
+	var texts []string
+	for _, cmd := range cfg.Commands {
+		texts = append(texts, cmd.Cmd, cmd.Script)
+		for _, hook := range append(cmd.PreExec, cmd.PostExec...) {
+			texts = append(texts, hook.Cmd)
+		}
+	}
+	referenced := make(map[string]bool)
+	for _, text := range texts {
+		for _, token := range strings.FieldsFunc(text, isCommandSeparator) {
+			referenced[token] = true
+			referenced[filepath.Base(token)] = true
+		}
+	}
+
+	dirs := []struct {
+		name    string
+		cleanup string
+	}{
+		{"executables", "remove it or add a command that runs it"},
+		{"executables.remote", "remove it from the remote repository or add a command that runs it"},
+	}
+	for _, dir := range dirs {
+		root := filepath.Join(appDir, dir.name)
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") && path != root {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil || referenced[d.Name()] || referenced[rel] {
+				return nil
+			}
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("%s/%s isn't used by any command, %s", dir.name, filepath.ToSlash(rel), dir.cleanup),
+			})
+			return nil
+		})
+	}
+
+	return errors
+}
+
+// isCommandSeparator reports whether r separates the words of a command line
+// when looking for the executables it runs
+func isCommandSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"'`+"`"+`;|&()<>=$`, r)
+}
+
+// validateEnv checks the global env and the env of projects for common
+// mistakes, and the env_policy of commands. Sub-projects of a glob project
+// share its env, so they are reported once for the glob.
+func validateEnv(cfg *settings.Settings) []ValidationError {
+	errors := envIssues("Global env", cfg.Env, nil, settings.EnvLocation())
+	if appDir, err := settings.GetAppDir(); err == nil {
+		errors = append(errors, envFileIssues("Global", cfg.EnvFile, appDir, settings.EnvLocation())...)
+	}
+
+	names := make([]string, 0, len(cfg.Projects))
+	for name := range cfg.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make(map[string]bool)
+	for _, name := range names {
+		project := cfg.Projects[name]
+		owner := fmt.Sprintf("Project '%s' env", name)
+		if project.Group != "" {
+			if groups[project.Group] {
+				continue
+			}
+			groups[project.Group] = true
+			owner = fmt.Sprintf("Project '%s' env", project.Group)
+		}
+		errors = append(errors, envIssues(owner, project.Env, cfg.Env, project.Location())...)
+		if projectPath, err := pathutil.Expand(project.Path); err == nil {
+			errors = append(errors, envFileIssues(strings.TrimSuffix(owner, " env"), project.EnvFile, projectPath, project.Location())...)
+		}
+	}
+
+	for cmdName, cmd := range cfg.Commands {
+		if err := cmd.EnvPolicy.Validate(); err != nil {
+			errors = append(errors, ValidationError{
+				Message: withLocation(fmt.Sprintf("Command '%s' has an %v", cmdName, err), cmd.Location()),
+				Severe:  true,
+			})
+		}
+		keys := make([]string, 0, len(cmd.Env))
+		for key := range cmd.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := secretIssue(cmd.Env[key]); err != nil {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' env can't read %s: %v", cmdName, key, err), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// envIssues returns the problems of the env of owner: keys the environment
+// can't hold, ~ and $PATH in values, which are used as they are, a PATH
+// without the system directories, keys differing only by case and, for a
+// project, values repeating the global ones
+func envIssues(owner string, env, global map[string]string, location string) []ValidationError {
+	var errors []ValidationError
+	report := func(severe bool, format string, args ...interface{}) {
+		errors = append(errors, ValidationError{
+			Message: withLocation(owner+" "+fmt.Sprintf(format, args...), location),
+			Severe:  severe,
+		})
+	}
+
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	byFold := make(map[string]string)
+	for _, key := range keys {
+		value := env[key]
+		if key == "" || strings.ContainsAny(key, "= \t\n") {
+			report(true, "has the key %q, environment variable names can't contain spaces or '='", key)
+			continue
+		}
+
+		if err := secretIssue(value); err != nil {
+			report(true, "can't read %s: %v", key, err)
+		}
+		if strings.HasPrefix(value, "~") || strings.Contains(value, ":~") {
+			report(false, "sets %s to %q, '~' isn't expanded in env values, use an absolute path", key, value)
+		}
+		if key == "PATH" {
+			if strings.Contains(value, "$PATH") || strings.Contains(value, "${PATH}") {
+				report(false, "sets PATH to %q, '$PATH' isn't expanded in env values, list extra directories in executable_search_paths instead", value)
+			} else if !keepsSystemPath(value) {
+				report(false, "replaces PATH with %q, which drops /usr/bin and /bin, list extra directories in executable_search_paths instead", value)
+			}
+		}
+
+		folded := strings.ToUpper(key)
+		if other, ok := byFold[folded]; ok {
+			report(false, "has the keys '%s' and '%s', which differ only by case", other, key)
+		} else {
+			byFold[folded] = key
+		}
+		if global == nil {
+			continue
+		}
+		if globalValue, ok := global[key]; ok && globalValue == value {
+			report(false, "sets %s to the same value as the global env, the entry can be removed", key)
+			continue
+		}
+		for globalKey := range global {
+			if globalKey != key && strings.EqualFold(globalKey, key) {
+				report(false, "sets '%s', which differs only by case from '%s' in the global env and doesn't override it", key, globalKey)
+				break
+			}
+		}
+	}
+
+	return errors
+}
+
+// secretIssue returns why a secret:// env value can't be read, nil for other
+// values. Whether the secret is stored is checked by `interop secrets list`.
+func secretIssue(value string) error {
+	if !secrets.IsReference(value) {
+		return nil
+	}
+	_, err := secrets.ParseReference(value)
+	return err
+}
+
+// envFileIssues returns a warning when the env_file of owner, relative to
+// dir, can't be loaded. Commands run without its variables then.
+func envFileIssues(owner, envFile, dir, location string) []ValidationError {
+	if envFile == "" {
+		return nil
+	}
+	if _, err := settings.ParseEnvFile(settings.EnvFilePath(envFile, dir)); err != nil {
+		return []ValidationError{{
+			Message: withLocation(fmt.Sprintf("%s env_file '%s' is skipped: %v", owner, envFile, err), location),
+			Severe:  false,
+		}}
+	}
+	return nil
+}
+
+// keepsSystemPath reports whether a PATH value lists /usr/bin or /bin
+func keepsSystemPath(value string) bool {
+	for _, dir := range filepath.SplitList(value) {
+		if dir := filepath.Clean(dir); dir == "/usr/bin" || dir == "/bin" {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePrompts checks the placeholders and servers of all prompts
+func validatePrompts(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for promptName, prompt := range cfg.Prompts {
+		for _, issue := range PromptIssues(cfg, promptName, prompt) {
+			issue.Message = withLocation(issue.Message, prompt.Location())
+			errors = append(errors, issue)
+		}
+	}
+
+	return errors
+}
+
+// PromptIssues returns the problems of a prompt: {name} placeholders without
+// a declared argument, declared arguments the content doesn't use, an MCP
+// server that doesn't exist or can't offer prompts, includes that don't
+// resolve, and suggested tools its server doesn't offer
+func PromptIssues(cfg *settings.Settings, promptName string, prompt settings.PromptConfig) []ValidationError {
+	var errors []ValidationError
+
+	declared := make(map[string]bool, len(prompt.Arguments))
+	for _, arg := range prompt.Arguments {
+		declared[arg.Name] = true
+	}
+	used := make(map[string]bool)
+	for _, name := range prompt.Placeholders() {
+		used[name] = true
+		if !declared[name] {
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' uses placeholder '{%s}' but declares no argument '%s', it is left as is", promptName, name, name),
+			})
+		}
+	}
+	for _, arg := range prompt.Arguments {
+		if !used[arg.Name] {
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' declares argument '%s' but its content has no '{%s}' placeholder", promptName, arg.Name, arg.Name),
+			})
+		}
+	}
+
+	for _, serverName := range prompt.MCP {
+		if server, exists := cfg.MCPServers[serverName]; !exists {
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' references a non-existent MCP server '%s'", promptName, serverName),
+				Severe:  true,
+			})
+		} else if server.Admin {
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' is assigned to admin MCP server '%s', which doesn't offer prompts", promptName, serverName),
+				Severe:  true,
+			})
+		}
+	}
+
+	if len(prompt.Includes) > 0 {
+		prompts := make(map[string]settings.PromptConfig, len(cfg.Prompts)+1)
+		for name, other := range cfg.Prompts {
+			prompts[name] = other
+		}
+		prompts[promptName] = prompt
+		if _, err := settings.PromptChain(prompts, promptName); err != nil {
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' has invalid includes: %v", promptName, err),
+				Severe:  true,
+			})
+		}
+	}
+
+	// Suggested tools should be commands served next to the prompt, on each
+	// of its servers
+	for _, tool := range prompt.Suggested {
+		cmd, exists := cfg.Commands[tool]
+		switch {
+		case !exists:
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' suggests tool '%s', which is not a configured command", promptName, tool),
+			})
+		case !cmd.IsEnabled:
+			errors = append(errors, ValidationError{
+				Message: fmt.Sprintf("Prompt '%s' suggests tool '%s', which is disabled", promptName, tool),
+			})
+		default:
+			for _, serverName := range prompt.MCP.Names() {
+				if cmd.MCP.Includes(serverName) {
+					continue
+				}
+				server := "the default MCP server"
+				if serverName != "" {
+					server = fmt.Sprintf("MCP server '%s'", serverName)
+				}
+				errors = append(errors, ValidationError{
+					Message: fmt.Sprintf("Prompt '%s' suggests tool '%s', which is not offered by %s", promptName, tool, server),
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateFileArguments checks that only string arguments set from_file
+func validateFileArguments(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		for _, arg := range cmd.Arguments {
+			if arg.FromFile && arg.Type != "" && arg.Type != settings.ArgumentTypeString {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' argument '%s' sets from_file but has type %s; file arguments must be strings", cmdName, arg.Name, arg.Type), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateConditions checks that command and hook 'when' conditions parse
+func validateConditions(cfg *settings.Settings) []ValidationError {
+	var errors []ValidationError
+
+	for cmdName, cmd := range cfg.Commands {
+		if cmd.When != "" {
+			if err := condition.Validate(cmd.When); err != nil {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' has an invalid condition: %v", cmdName, err), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+
+		hooks := append(append([]settings.Hook{}, cmd.PreExec...), cmd.PostExec...)
+		for _, hook := range hooks {
+			if hook.When == "" {
+				continue
+			}
+			if err := condition.Validate(hook.When); err != nil {
+				errors = append(errors, ValidationError{
+					Message: withLocation(fmt.Sprintf("Command '%s' hook '%s' has an invalid condition: %v", cmdName, hook.Cmd, err), cmd.Location()),
+					Severe:  true,
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateGitBackend checks the git_backend setting and whether the selected
+// backend can run on this machine
+func validateGitBackend(cfg *settings.Settings) []ValidationError {
+	backend, err := remote.ParseGitBackend(cfg.GitBackend)
+	if err != nil {
+		return []ValidationError{{Message: err.Error(), Severe: true}}
+	}
+
+	if remote.SystemGitAvailable() {
+		return nil
+	}
+
+	switch backend {
+	case remote.GitBackendSystem:
+		return []ValidationError{{
+			Message: "git_backend is 'system' but no git binary was found on PATH; remote fetches will fail",
+			Severe:  true,
+		}}
+	case remote.GitBackendAuto:
+		return []ValidationError{{
+			Message: "No git binary found on PATH; remotes will use the built-in git implementation",
+			Severe:  false,
+		}}
+	}
+	return nil
+}
+
 // validateCommandDirectoryConflicts checks for command name conflicts between
 // main settings.toml and command directories, and between command directories
 func validateCommandDirectoryConflicts(cfg *settings.Settings) []ValidationError {
@@ -262,7 +1143,9 @@ func validateCommandDirectoryConflicts(cfg *settings.Settings) []ValidationError
 	// Track commands from each directory to detect conflicts
 	dirCommands := make(map[string]map[string]string) // dir -> command name -> file
 
-	for _, dir := range cfg.CommandDirs {
+	for _, commandDir := range cfg.CommandDirs {
+		dir := commandDir.Path
+
 		// Expand tilde and relative paths
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
@@ -309,16 +1192,14 @@ func validateCommandDirectoryConflicts(cfg *settings.Settings) []ValidationError
 				Commands map[string]settings.CommandConfig `toml:"commands"`
 			}
 
+			// Files that fail to parse are reported by settings.Load, see LoadErrors
 			if _, err := toml.DecodeFile(file, &fileCommands); err != nil {
-				errors = append(errors, ValidationError{
-					Message: fmt.Sprintf("Failed to parse command file %s: %v", file, err),
-					Severe:  false,
-				})
 				continue
 			}
 
 			// Check for conflicts with main settings
-			for cmdName := range fileCommands.Commands {
+			for name := range fileCommands.Commands {
+				cmdName := commandDir.Namespaced(name)
 				if mainCommands[cmdName] {
 					errors = append(errors, ValidationError{
 						Message: fmt.Sprintf("Command '%s' in %s conflicts with main settings.toml", cmdName, file),
@@ -341,7 +1222,8 @@ func validateCommandDirectoryConflicts(cfg *settings.Settings) []ValidationError
 
 	// Check for conflicts between different directories
 	allDirCommands := make(map[string]string) // command name -> first directory that defined it
-	for _, dir := range cfg.CommandDirs {
+	for _, commandDir := range cfg.CommandDirs {
+		dir := commandDir.Path
 		if cmds, exists := dirCommands[dir]; exists {
 			for cmdName := range cmds {
 				if firstDir, conflict := allDirCommands[cmdName]; conflict {
@@ -372,42 +1254,66 @@ func validateCommandDirectoryConflicts(cfg *settings.Settings) []ValidationError
 // ResolveCommand finds a command by name or alias
 // Returns the command reference and a potential error
 func ResolveCommand(cfg *settings.Settings, nameOrAlias string) (*CommandReference, error) {
+	cwd, _ := os.Getwd()
+
 	// Check if command exists in global commands
 	cmd, cmdExists := cfg.Commands[nameOrAlias]
 	if !cmdExists {
-		// If command doesn't exist at all, check aliases and return error if not found
+		// If command doesn't exist at all, check aliases and return error if not found.
+		// Sub-projects of a glob project share aliases, so prefer the one containing
+		// the current directory.
+		var fallback *CommandReference
 		for projectName, projectData := range cfg.Projects {
 			for _, alias := range projectData.Commands {
 				// Check if it matches an alias
 				if alias.Alias == nameOrAlias {
 					if cmd, ok := cfg.Commands[alias.CommandName]; ok {
-						return &CommandReference{
+						ref := &CommandReference{
 							Type:        AliasCommand,
 							Command:     cmd,
 							ProjectName: projectName,
 							Name:        nameOrAlias,
-						}, nil
+						}
+						if projectData.Group == "" || projectContainsDir(projectData, cwd) {
+							return ref, nil
+						}
+						if fallback == nil || projectName < fallback.ProjectName {
+							fallback = ref
+						}
 					}
 				}
 			}
 		}
+		if fallback != nil {
+			return fallback, nil
+		}
 		return nil, errors.NewCommandError(fmt.Sprintf("Command or alias '%s' not found", nameOrAlias), nil, true)
 	}
 
 	// Check if command is bound to any project with its original name (no alias)
+	var fallback *CommandReference
 	for projectName, projectData := range cfg.Projects {
 		for _, alias := range projectData.Commands {
 			if alias.CommandName == nameOrAlias && alias.Alias == "" {
 				// Found the command in a project with its original name, so it's a project command
-				return &CommandReference{
+				ref := &CommandReference{
 					Type:        ProjectCommand,
 					Command:     cmd,
 					ProjectName: projectName,
 					Name:        nameOrAlias,
-				}, nil
+				}
+				if projectData.Group == "" || projectContainsDir(projectData, cwd) {
+					return ref, nil
+				}
+				if fallback == nil || projectName < fallback.ProjectName {
+					fallback = ref
+				}
 			}
 		}
 	}
+	if fallback != nil {
+		return fallback, nil
+	}
 
 	// If command exists in global commands and wasn't found in any project with original name,
 	// it's a global command
@@ -423,51 +1329,251 @@ func ExecuteCommand(cfg *settings.Settings, nameOrAlias string) error {
 	return ExecuteCommandWithArgs(cfg, nameOrAlias, nil)
 }
 
+// RunOptions holds overrides for a single command run
+type RunOptions struct {
+	Env []string // KEY=VALUE pairs applied above all configured environment variables
+	Dir string   // Working directory replacing the command's default
+	// Timeout stops the main command once it runs this long, in place of its
+	// configured timeout, 0 keeps that one
+	Timeout time.Duration
+	// Output receives a copy of the main command's output when set
+	Output io.Writer
+	// Project runs the command or alias as bound to this project, with its
+	// path and env, rather than resolving the project from the name
+	Project string
+	// Record, when set, is called before each run of ExecuteCommandsInParallel
+	// and WatchCommandWithOptions with the command or alias and its arguments.
+	// It returns a writer receiving a copy of the run's output, nil for none,
+	// and the function recording how the run ended.
+	Record func(nameOrAlias string, args []string) (io.Writer, func(error))
+}
+
+// NewRunOptions builds run options from --env assignments, --env-file paths and
+// a --cwd directory. Env files are applied in order, then assignments, so an
+// explicit --env wins over a file.
+func NewRunOptions(envAssignments, envFiles []string, cwd string) (RunOptions, error) {
+	var opts RunOptions
+
+	for _, envFile := range envFiles {
+		env, err := settings.ParseEnvFile(envFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Env = append(opts.Env, env...)
+	}
+
+	for _, assignment := range envAssignments {
+		env, err := settings.ParseEnvAssignment(assignment)
+		if err != nil {
+			return opts, err
+		}
+		opts.Env = append(opts.Env, env)
+	}
+
+	if cwd != "" {
+		if strings.HasPrefix(cwd, "~/") {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return opts, fmt.Errorf("failed to get user home directory: %w", err)
+			}
+			cwd = filepath.Join(homeDir, cwd[2:])
+		}
+		dir, err := filepath.Abs(cwd)
+		if err != nil {
+			return opts, fmt.Errorf("invalid working directory '%s': %w", cwd, err)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return opts, fmt.Errorf("working directory does not exist: %s", dir)
+		}
+		opts.Dir = dir
+	}
+
+	return opts, nil
+}
+
 // ExecuteCommandWithArgs validates the configuration, resolves and executes a command by name or alias with arguments
 func ExecuteCommandWithArgs(cfg *settings.Settings, nameOrAlias string, args []string) error {
+	return ExecuteCommandWithOptions(context.Background(), cfg, nameOrAlias, args, RunOptions{})
+}
+
+// ExecuteCommandWithOptions is ExecuteCommandWithArgs with per-run environment and working directory
+// overrides. Resolution and execution are traced as children of the span in ctx.
+func ExecuteCommandWithOptions(ctx context.Context, cfg *settings.Settings, nameOrAlias string, args []string, opts RunOptions) error {
+	cmd, err := resolveRunnableCommand(ctx, cfg, nameOrAlias, opts.Project)
+	if err != nil {
+		return err
+	}
+
+	applyRunOptions(cmd, opts)
+
+	// Execute the command with arguments
+	return cmd.RunWithContext(ctx, args)
+}
+
+// DryRunCommand resolves a command by name or alias like
+// ExecuteCommandWithOptions and returns what running it with args would do,
+// without running anything
+func DryRunCommand(ctx context.Context, cfg *settings.Settings, nameOrAlias string, args []string, opts RunOptions) (*factory.Plan, error) {
+	cmd, err := resolveRunnableCommand(ctx, cfg, nameOrAlias, opts.Project)
+	if err != nil {
+		return nil, err
+	}
+	applyRunOptions(cmd, opts)
+	return cmd.DryRun(args)
+}
+
+// ExecuteCommandsInParallel resolves commands by name or alias like
+// ExecuteCommandWithOptions and runs them concurrently without arguments, at
+// most limit at a time, see execution.RunParallel. Nothing runs when any of
+// them can't be resolved. The output of each is written to output with its
+// name as the prefix of every line.
+func ExecuteCommandsInParallel(ctx context.Context, cfg *settings.Settings, namesOrAliases []string, opts RunOptions, limit int, output io.Writer) ([]execution.JobResult, error) {
+	jobs := make([]execution.Job, 0, len(namesOrAliases))
+	for _, nameOrAlias := range namesOrAliases {
+		cmd, err := resolveRunnableCommand(ctx, cfg, nameOrAlias, opts.Project)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve '%s': %w", nameOrAlias, err)
+		}
+		applyRunOptions(cmd, opts)
+		jobs = append(jobs, execution.Job{
+			Name: nameOrAlias,
+			Run: func(ctx context.Context, terminal io.Writer) error {
+				cmd.Terminal = terminal
+				return opts.record(cmd, nameOrAlias, nil, func() error {
+					return cmd.RunWithContext(ctx, nil)
+				})
+			},
+		})
+	}
+	return execution.RunParallel(ctx, jobs, limit, output), nil
+}
+
+// record runs run, a run of cmd, as one run for opts.Record when it's set,
+// adding the copy of the output Record asks for to that of opts
+func (opts RunOptions) record(cmd *factory.Command, nameOrAlias string, args []string, run func() error) error {
+	if opts.Record == nil {
+		return run()
+	}
+	output, finish := opts.Record(nameOrAlias, args)
+	cmd.Output = opts.Output
+	if output != nil && cmd.Output != nil {
+		cmd.Output = io.MultiWriter(cmd.Output, output)
+	} else if output != nil {
+		cmd.Output = output
+	}
+	err := run()
+	finish(err)
+	return err
+}
+
+// applyRunOptions applies the env, timeout and working directory overrides
+// and the output copy of opts to the command, and the directory to its
+// dependencies, which run in the same directory
+func applyRunOptions(cmd *factory.Command, opts RunOptions) {
+	cmd.EnvOverrides = opts.Env
+	cmd.Output = opts.Output
+	if opts.Timeout > 0 {
+		cmd.Timeout = opts.Timeout
+	}
+	if opts.Dir == "" {
+		return
+	}
+	cmd.Dir = opts.Dir
+	for _, dependency := range cmd.Dependencies {
+		dependency.Dir = opts.Dir
+	}
+}
+
+// restartGrace is how long a command stopped by a change in watch mode with
+// restart gets to exit after SIGTERM before it's killed
+const restartGrace = 5 * time.Second
+
+// WatchCommandWithOptions runs a command like ExecuteCommandWithOptions, then
+// again whenever files matching patterns change, until ctx is done. Without
+// patterns the command's watch globs are used. Patterns are relative to the
+// directory the command runs in. With restart, a run still going when files
+// change is stopped, with SIGTERM and restartGrace to exit, and replaced.
+func WatchCommandWithOptions(ctx context.Context, cfg *settings.Settings, nameOrAlias string, args []string, opts RunOptions, patterns []string, restart bool) error {
+	if len(patterns) == 0 {
+		cmdRef, err := ResolveCommand(cfg, nameOrAlias)
+		if err != nil {
+			return err
+		}
+		patterns = cmdRef.Command.Watch
+		if len(patterns) == 0 {
+			return errors.NewCommandError(fmt.Sprintf("Command '%s' has no watch globs, pass them with --watch", nameOrAlias), nil, true)
+		}
+	}
+
+	cmd, err := resolveRunnableCommand(ctx, cfg, nameOrAlias, opts.Project)
+	if err != nil {
+		return err
+	}
+	applyRunOptions(cmd, opts)
+
+	root := cmd.Dir
+	if root == "" {
+		if root, err = os.Getwd(); err != nil {
+			return errors.NewExecutionError("Failed to get the current directory", err)
+		}
+	}
+	watcher := watch.New(root, patterns)
+	if restart {
+		watcher.Restart = true
+		cmd.StopGrace = restartGrace
+	}
+	return watcher.Run(ctx, func(ctx context.Context) error {
+		return opts.record(cmd, nameOrAlias, args, func() error {
+			return cmd.RunWithContext(ctx, args)
+		})
+	})
+}
+
+// resolveRunnableCommand validates the configuration and builds the command
+// for a name or alias inside a command.resolve span. When project is set, the
+// name or alias is looked up in that project only.
+func resolveRunnableCommand(ctx context.Context, cfg *settings.Settings, nameOrAlias, project string) (cmd *factory.Command, err error) {
+	_, span := tracing.Start(ctx, "command.resolve", attribute.String("interop.command", nameOrAlias))
+	defer func() { tracing.End(span, err) }()
+
 	// First validate all commands
 	validationErrors := ValidateCommands(cfg)
 	for _, err := range validationErrors {
 		if err.Severe {
-			return errors.NewValidationError(fmt.Sprintf("Configuration error: %s", err.Message), nil, true)
+			return nil, errors.NewValidationError(fmt.Sprintf("Configuration error: %s", err.Message), nil, true)
 		}
 	}
 
 	// Resolve the command using existing resolver to maintain compatibility
-	cmdRef, err := ResolveCommand(cfg, nameOrAlias)
-	if err != nil {
-		return err
+	cmdRef := &CommandReference{Type: ProjectCommand, ProjectName: project, Name: nameOrAlias}
+	if project == "" {
+		cmdRef, err = ResolveCommand(cfg, nameOrAlias)
+		if err != nil {
+			return nil, err
+		}
 	}
 	logging.Message("Command reference: %v", cmdRef)
+	span.SetAttributes(attribute.String("interop.project", cmdRef.ProjectName))
 
 	// Get shell info
 	shellInfo, err := shell.DetectShell()
 	if err != nil {
-		return errors.NewExecutionError("Failed to detect shell", err)
+		return nil, errors.NewExecutionError("Failed to detect shell", err)
 	}
 
 	// Create a command factory
 	executor := execution.NewExecutor()
 	commandFactory, err := factory.NewFactory(cfg, executor, shellInfo)
 	if err != nil {
-		return errors.NewExecutionError("Failed to create command factory", err)
+		return nil, errors.NewExecutionError("Failed to create command factory", err)
 	}
 
 	// If it's a project command or alias, we need to create it with the project path
-	var cmd *factory.Command
 	logging.Message("Project name: %s", cmdRef.ProjectName)
 	if cmdRef.ProjectName != "" {
 		// For project commands, use CreateFromAlias
-		cmd, err = commandFactory.CreateFromAlias(cmdRef.ProjectName, nameOrAlias)
-	} else {
-		// For global commands, use Create with empty project path
-		cmd, err = commandFactory.Create(nameOrAlias, "")
+		return commandFactory.CreateFromAlias(cmdRef.ProjectName, nameOrAlias)
 	}
-
-	if err != nil {
-		return err
-	}
-
-	// Execute the command with arguments
-	return cmd.RunWithArgs(args)
+	// For global commands, use Create with empty project path
+	return commandFactory.Create(nameOrAlias, "")
 }
@@ -0,0 +1,309 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interop/internal/execution"
+	"interop/internal/settings"
+	"interop/internal/testutil"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateAllFixture(t *testing.T) {
+	env := testutil.New(t)
+	env.CopyFixtures(filepath.Join("testdata", "invalid"))
+	env.Dir("projects/app")
+
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	// Errors are reported in map order, sort them for a stable listing
+	var lines []string
+	for _, err := range ValidateAll(cfg) {
+		severity := "Warning"
+		if err.Severe {
+			severity = "Error"
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s", severity, err.Message))
+	}
+	sort.Strings(lines)
+	env.AssertGolden("validate_invalid", strings.Join(lines, "\n"))
+}
+
+func TestPromptIssues(t *testing.T) {
+	cfg := &settings.Settings{
+		MCPServers: map[string]settings.MCPServer{
+			"admin": {Name: "admin", Admin: true},
+		},
+	}
+	prompt := settings.PromptConfig{
+		Content: `Review {file} for {focus}. Reply as {"summary": "..."} and repeat {file}.`,
+		Arguments: []settings.CommandArgument{
+			{Name: "file"},
+			{Name: "language"},
+		},
+	}
+
+	var got []string
+	for _, issue := range PromptIssues(cfg, "review", prompt) {
+		got = append(got, fmt.Sprintf("%v %s", issue.Severe, issue.Message))
+	}
+	want := []string{
+		"false Prompt 'review' uses placeholder '{focus}' but declares no argument 'focus', it is left as is",
+		"false Prompt 'review' declares argument 'language' but its content has no '{language}' placeholder",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("PromptIssues() = %q, want %q", got, want)
+	}
+
+	for server, want := range map[string]string{
+		"missing": "Prompt 'review' references a non-existent MCP server 'missing'",
+		"admin":   "Prompt 'review' is assigned to admin MCP server 'admin', which doesn't offer prompts",
+	} {
+		issues := PromptIssues(cfg, "review", settings.PromptConfig{Content: "Hi", MCP: settings.ServerList{server}})
+		if len(issues) != 1 || !issues[0].Severe || issues[0].Message != want {
+			t.Errorf("PromptIssues() for server %s = %+v, want %q", server, issues, want)
+		}
+	}
+}
+
+func TestValidateDefaultServer(t *testing.T) {
+	off := false
+	cfg := &settings.Settings{
+		DefaultMCPEnabled: &off,
+		MCPPort:           8081,
+		Commands: map[string]settings.CommandConfig{
+			"build":  {IsEnabled: true, MCP: settings.ServerList{"docs"}},
+			"test":   {IsEnabled: true},
+			"lint":   {IsEnabled: true},
+			"unused": {IsEnabled: false},
+		},
+		Prompts: map[string]settings.PromptConfig{
+			"review": {Content: "Hi", MCP: settings.ServerList{"docs"}},
+			"plan":   {Content: "Hi"},
+		},
+		Projects: map[string]settings.Project{
+			"api": {Path: "~/api", Commands: []settings.Alias{{CommandName: "lint"}}},
+		},
+		MCPServers: map[string]settings.MCPServer{
+			"docs": {Name: "docs", Port: 8082},
+			"api":  {Name: "api", Port: 8083, Project: "api"},
+		},
+	}
+
+	var got []string
+	for _, issue := range validateDefaultServer(cfg) {
+		got = append(got, fmt.Sprintf("%v %s", issue.Severe, issue.Message))
+	}
+	sort.Strings(got)
+	want := []string{
+		"false mcp_port 8081 is not used, the default MCP server is disabled",
+		"true Command 'test' is not served by any MCP server, the default MCP server is disabled; set mcp to one of mcp_servers",
+		"true Prompt 'plan' is not served by any MCP server, the default MCP server is disabled; set mcp to one of mcp_servers",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("validateDefaultServer() = %q, want %q", got, want)
+	}
+
+	cfg.DefaultMCPEnabled = nil
+	if issues := validateDefaultServer(cfg); len(issues) != 0 {
+		t.Errorf("Expected no issues with the default server enabled, got %+v", issues)
+	}
+}
+
+func TestPromptIssuesChaining(t *testing.T) {
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"create-mr": {IsEnabled: true},
+			"old-mr":    {IsEnabled: false},
+			"deploy":    {IsEnabled: true, MCP: settings.ServerList{"ops"}},
+		},
+		Prompts: map[string]settings.PromptConfig{
+			"context": {Content: "Hi", Includes: []string{"mr"}},
+		},
+	}
+	prompt := settings.PromptConfig{
+		Content:   "Open an MR",
+		Includes:  []string{"context"},
+		Suggested: []string{"create-mr", "old-mr", "deploy", "publish"},
+	}
+
+	var got []string
+	for _, issue := range PromptIssues(cfg, "mr", prompt) {
+		got = append(got, fmt.Sprintf("%v %s", issue.Severe, issue.Message))
+	}
+	want := []string{
+		"true Prompt 'mr' has invalid includes: prompt includes form a cycle: mr -> context -> mr",
+		"false Prompt 'mr' suggests tool 'old-mr', which is disabled",
+		"false Prompt 'mr' suggests tool 'deploy', which is not offered by the default MCP server",
+		"false Prompt 'mr' suggests tool 'publish', which is not a configured command",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("PromptIssues() = %q, want %q", got, want)
+	}
+
+	// A prompt on several servers needs its suggested tools on each
+	cfg.MCPServers = map[string]settings.MCPServer{"ops": {}, "ci": {}}
+	prompt = settings.PromptConfig{Content: "Deploy", MCP: settings.ServerList{"ops", "ci"}, Suggested: []string{"deploy"}}
+	issues := PromptIssues(cfg, "ship", prompt)
+	if len(issues) != 1 || issues[0].Message != "Prompt 'ship' suggests tool 'deploy', which is not offered by MCP server 'ci'" {
+		t.Errorf("PromptIssues() for a prompt on two servers = %+v", issues)
+	}
+}
+
+func TestNewSevereErrors(t *testing.T) {
+	before := []ValidationError{
+		{Message: "Project 'app' path does not exist (settings.toml:4)", Severe: true},
+		{Message: "Command 'lint' is slow (settings.toml:9)"},
+	}
+	after := []ValidationError{
+		{Message: "Project 'app' path does not exist (settings.toml:7)", Severe: true},
+		{Message: "Command 'lint' is slow (settings.toml:12)"},
+		{Message: "Command 'build' references a non-existent MCP server 'ci' (settings.toml:20)", Severe: true},
+		{Message: "Command 'test' has no description"},
+	}
+
+	got := NewSevereErrors(before, after)
+	if len(got) != 1 || !strings.Contains(got[0].Message, "'build'") {
+		t.Errorf("NewSevereErrors() = %+v, want only the error about 'build'", got)
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	report := NewReport([]ValidationError{{Message: "Command 'lint' is slow"}})
+	if !report.Valid || len(report.Issues) != 1 || report.Issues[0].Severity != "warning" {
+		t.Errorf("NewReport(warning) = %+v, want a valid report with one warning", report)
+	}
+
+	report = NewReport([]ValidationError{{Message: "Project 'app' path does not exist", Severe: true}})
+	if report.Valid || report.Issues[0].Severity != "error" {
+		t.Errorf("NewReport(severe) = %+v, want an invalid report with one error", report)
+	}
+
+	if report := NewReport(nil); !report.Valid || report.Issues == nil {
+		t.Errorf("NewReport(nil) = %+v, want a valid report with an empty list of issues", report)
+	}
+}
+
+func TestDryRunCommandInProject(t *testing.T) {
+	testutil.New(t)
+	api, web := t.TempDir(), t.TempDir()
+	cfg := &settings.Settings{
+		Commands: map[string]settings.CommandConfig{
+			"build": {Cmd: "echo build", IsEnabled: true},
+		},
+		Projects: map[string]settings.Project{
+			"api": {Path: api, Commands: []settings.Alias{{CommandName: "build"}}},
+			"web": {
+				Path:     web,
+				Commands: []settings.Alias{{CommandName: "build", Alias: "b"}},
+			},
+		},
+	}
+
+	for _, name := range []string{"build", "b"} {
+		plan, err := DryRunCommand(context.Background(), cfg, name, nil, RunOptions{Project: "web"})
+		if err != nil {
+			t.Fatalf("DryRunCommand(%s) error = %v", name, err)
+		}
+		if plan.Dir != web {
+			t.Errorf("DryRunCommand(%s) runs in %s, want the web project's %s", name, plan.Dir, web)
+		}
+	}
+
+	if _, err := DryRunCommand(context.Background(), cfg, "b", nil, RunOptions{Project: "api"}); err == nil {
+		t.Error("Expected an error for an alias of another project")
+	}
+}
+
+func TestRunTimeoutOverridesCommandTimeout(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.short]
+cmd = "sleep 0.4"
+timeout = "100ms"
+
+[commands.long]
+cmd = "exec sleep 5"
+timeout = "1m"
+`)
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	// A longer --timeout lets the command run past its own
+	if err := ExecuteCommandWithOptions(context.Background(), cfg, "short", nil, RunOptions{Timeout: 5 * time.Second}); err != nil {
+		t.Errorf("Expected --timeout to replace the command's timeout, got %v", err)
+	}
+
+	started := time.Now()
+	err = ExecuteCommandWithOptions(context.Background(), cfg, "long", nil, RunOptions{Timeout: 200 * time.Millisecond})
+	if !errors.Is(err, execution.ErrTimedOut) || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Errorf("Expected a timeout after 200ms, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("Expected the command to stop at --timeout, it ran for %s", elapsed)
+	}
+
+	// Without --timeout the command's own applies
+	if err := ExecuteCommandWithOptions(context.Background(), cfg, "short", nil, RunOptions{}); !errors.Is(err, execution.ErrTimedOut) {
+		t.Errorf("Expected the command's timeout to apply, got %v", err)
+	}
+}
+
+func TestRecordEachRun(t *testing.T) {
+	env := testutil.New(t)
+	env.WriteSettings(`
+[commands.ok]
+cmd = "echo ok"
+
+[commands.fail]
+cmd = "exit 3"
+`)
+	cfg, err := settings.Reload()
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	var mu sync.Mutex
+	var recorded []string
+	opts := RunOptions{Record: func(nameOrAlias string, args []string) (io.Writer, func(error)) {
+		return nil, func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			recorded = append(recorded, fmt.Sprintf("%s %v exit %d", nameOrAlias, args, execution.ExitCode(err)))
+		}
+	}}
+
+	// Each job of a parallel run is recorded
+	if _, err := ExecuteCommandsInParallel(context.Background(), cfg, []string{"ok", "fail"}, opts, 0, io.Discard); err != nil {
+		t.Fatalf("ExecuteCommandsInParallel() error = %v", err)
+	}
+	sort.Strings(recorded)
+	if want := "fail [] exit 3,ok [] exit 0"; strings.Join(recorded, ",") != want {
+		t.Errorf("parallel runs recorded as %q, want %q", recorded, want)
+	}
+
+	// Each run of a watch is recorded
+	recorded = nil
+	dir := env.Dir("watched")
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	opts.Dir = dir
+	if err := WatchCommandWithOptions(ctx, cfg, "ok", []string{"a"}, opts, []string{"*.txt"}, false); err != nil {
+		t.Fatalf("WatchCommandWithOptions() error = %v", err)
+	}
+	if want := "ok [a] exit 0"; strings.Join(recorded, ",") != want {
+		t.Errorf("watch runs recorded as %q, want %q", recorded, want)
+	}
+}
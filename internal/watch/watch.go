@@ -0,0 +1,335 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long files must stay unchanged before a re-run, so
+// an editor saving several files triggers a single run
+const DefaultDebounce = 300 * time.Millisecond
+
+// skippedDirs are not watched unless a pattern names them: version control
+// data and dependency trees hold many files that change without being edited
+var skippedDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+	"__pycache__":  true,
+}
+
+// Watcher re-runs a function when files under a directory change. Changes
+// are reported by the file system through fsnotify, in the directories a
+// pattern can match files in.
+type Watcher struct {
+	Root     string        // Directory the patterns are relative to
+	Patterns []string      // Slash separated globs, '**' matching any number of directories
+	Debounce time.Duration // Quiet period after a change before running
+	Output   io.Writer     // Where run separators are written
+	// Restart stops a run still going when files change, by canceling its
+	// context, and starts the next one once it returned
+	Restart bool
+}
+
+// New creates a watcher for the files under root matching patterns
+func New(root string, patterns []string) *Watcher {
+	return &Watcher{
+		Root:     root,
+		Patterns: patterns,
+		Debounce: DefaultDebounce,
+		Output:   os.Stderr,
+	}
+}
+
+// Run calls run, then again after every change to the watched files, until
+// ctx is done. Changes made while run is running trigger another run once it
+// returns, or once it's stopped with Restart. Errors from run are reported
+// and don't stop the watch.
+func (w *Watcher) Run(ctx context.Context, run func(ctx context.Context) error) error {
+	if len(w.Patterns) == 0 {
+		return fmt.Errorf("no files to watch")
+	}
+	for _, pattern := range w.Patterns {
+		if err := ValidatePattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	events, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch files: %w", err)
+	}
+	defer events.Close()
+	if _, err := w.add(events, w.Root); err != nil {
+		return err
+	}
+
+	if w.Restart {
+		return w.runRestarting(ctx, events, run)
+	}
+	w.run(ctx, 1, nil, run)
+
+	for count := 2; ; count++ {
+		paths, err := w.wait(ctx, events)
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		w.run(ctx, count, paths, run)
+	}
+}
+
+// runRestarting is Run with Restart: changes are watched for while run is
+// running, and stop it
+func (w *Watcher) runRestarting(ctx context.Context, events *fsnotify.Watcher, run func(ctx context.Context) error) error {
+	var paths []string
+	for count := 1; ; count++ {
+		runCtx, stop := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			w.run(runCtx, count, paths, run)
+		}()
+
+		changes, err := w.wait(ctx, events)
+		stop()
+		<-done
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		paths = changes
+	}
+}
+
+// wait returns the watched files added, modified or removed, sorted, once a
+// change is followed by the debounce period without any. It returns a nil
+// error and no files when ctx is done.
+func (w *Watcher) wait(ctx context.Context, events *fsnotify.Watcher) ([]string, error) {
+	changed := make(map[string]bool)
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case err := <-events.Errors:
+			return nil, fmt.Errorf("failed to watch files: %w", err)
+		case event, ok := <-events.Events:
+			if !ok {
+				return nil, nil
+			}
+			paths := w.handle(events, event)
+			if len(paths) == 0 {
+				continue
+			}
+			for _, file := range paths {
+				changed[file] = true
+			}
+			// Let the burst of changes settle
+			settled = time.After(w.Debounce)
+		case <-settled:
+			paths := make([]string, 0, len(changed))
+			for file := range changed {
+				paths = append(paths, file)
+			}
+			sort.Strings(paths)
+			return paths, nil
+		}
+	}
+}
+
+// handle returns the watched files an event changed, by slash separated path
+// relative to the root. New directories are watched, and the files already
+// in them count as changed.
+func (w *Watcher) handle(events *fsnotify.Watcher, event fsnotify.Event) []string {
+	// Permission and time changes leave the content as is
+	if event.Op == fsnotify.Chmod {
+		return nil
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			files, _ := w.add(events, event.Name)
+			return files
+		}
+	}
+	rel, err := filepath.Rel(w.Root, event.Name)
+	if err != nil {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	if !w.matches(rel) {
+		return nil
+	}
+	return []string{rel}
+}
+
+// run calls run between separators naming the run and its result
+func (w *Watcher) run(ctx context.Context, count int, paths []string, run func(ctx context.Context) error) {
+	header := fmt.Sprintf("run %d · %s", count, time.Now().Format("15:04:05"))
+	if len(paths) > 0 {
+		header += " · changed: " + describe(paths)
+	}
+	fmt.Fprintf(w.Output, "\n━━━ %s ━━━\n", header)
+
+	started := time.Now()
+	err := run(ctx)
+	duration := time.Since(started).Round(time.Millisecond)
+
+	result := fmt.Sprintf("run %d succeeded in %s", count, duration)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		result = fmt.Sprintf("run %d stopped after %s", count, duration)
+	case err != nil:
+		result = fmt.Sprintf("run %d failed in %s: %v", count, duration, err)
+	}
+	fmt.Fprintf(w.Output, "━━━ %s · watching %s ━━━\n", result, strings.Join(w.Patterns, ", "))
+}
+
+// describe names the first changed file and how many others changed
+func describe(paths []string) string {
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	return fmt.Sprintf("%s (+%d more)", paths[0], len(paths)-1)
+}
+
+// add watches dir and the directories under it that a pattern can match
+// files in, and returns the watched files they hold. .git and the other
+// skippedDirs are left out unless a pattern names them.
+func (w *Watcher) add(events *fsnotify.Watcher, dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if file == w.Root {
+				return err
+			}
+			// Files removed during the walk or unreadable directories
+			return nil
+		}
+		rel, err := filepath.Rel(w.Root, file)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !entry.IsDir() {
+			if w.matches(rel) {
+				files = append(files, rel)
+			}
+			return nil
+		}
+		if rel != "." && ((skippedDirs[entry.Name()] && !w.names(entry.Name())) || !w.mayContain(rel)) {
+			return filepath.SkipDir
+		}
+		if err := events.Add(file); err != nil {
+			if file == w.Root {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch files: %w", err)
+	}
+	return files, nil
+}
+
+// matches reports whether a file matches any of the patterns
+func (w *Watcher) matches(rel string) bool {
+	for _, pattern := range w.Patterns {
+		if Match(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayContain reports whether a directory may hold files matching a pattern
+func (w *Watcher) mayContain(dir string) bool {
+	for _, pattern := range w.Patterns {
+		if matchPrefix(strings.Split(pattern, "/"), strings.Split(dir, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// names reports whether a pattern has a segment naming dir
+func (w *Watcher) names(dir string) bool {
+	for _, pattern := range w.Patterns {
+		for _, segment := range strings.Split(pattern, "/") {
+			if segment == dir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidatePattern checks that a glob is well formed
+func ValidatePattern(pattern string) error {
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return fmt.Errorf("invalid watch pattern '%s': %w", pattern, err)
+	}
+	return nil
+}
+
+// Match reports whether a slash separated path matches a glob. Segments
+// match as in path.Match, and a '**' segment matches any number of segments,
+// including none.
+func Match(pattern, name string) bool {
+	return match(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// match matches path segments against pattern segments
+func match(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if match(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchPrefix reports whether the directory segments of dir can be the start
+// of a path matching pattern
+func matchPrefix(pattern, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], dir[0]); !ok {
+			return false
+		}
+		pattern, dir = pattern[1:], dir[1:]
+	}
+	// The directory itself must leave room for a file name
+	return len(pattern) > 0
+}
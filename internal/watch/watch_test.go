@@ -0,0 +1,207 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "lib/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**", "a/b/c", true},
+		{"go.mod", "go.mod", true},
+		{"src/**", "src", true},
+		{"src/*/test_*.py", "src/pkg/test_api.py", true},
+		{"src/*/test_*.py", "src/pkg/api.py", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddSkipsUnmatchedDirectories(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"src/a/main.go", "src/notes.txt", "src/node_modules/dep/index.go", "docs/guide.go", ".git/config.go"} {
+		writeFile(t, filepath.Join(root, file), "x")
+	}
+
+	w := New(root, []string{"src/**/*.go"})
+	if w.mayContain("docs") || !w.mayContain("src/a") {
+		t.Error("mayContain() should only allow directories under src")
+	}
+	events, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	files, err := w.add(events, root)
+	if err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	if len(files) != 1 || files[0] != "src/a/main.go" {
+		t.Errorf("add() = %v, want only src/a/main.go", files)
+	}
+	watched := events.WatchList()
+	sort.Strings(watched)
+	want := []string{root, filepath.Join(root, "src"), filepath.Join(root, "src", "a")}
+	if strings.Join(watched, ",") != strings.Join(want, ",") {
+		t.Errorf("watched directories = %v, want %v", watched, want)
+	}
+
+	// A pattern naming a skipped directory watches it
+	w = New(root, []string{"src/node_modules/**/*.go"})
+	if files, err := w.add(events, root); err != nil || len(files) != 1 || files[0] != "src/node_modules/dep/index.go" {
+		t.Errorf("add() = %v, %v; want src/node_modules/dep/index.go", files, err)
+	}
+}
+
+func TestRunRerunsOnChange(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "main.go")
+	writeFile(t, file, "package main")
+
+	var output bytes.Buffer
+	w := New(root, []string{"*.go"})
+	w.Debounce = 20 * time.Millisecond
+	w.Output = &output
+
+	var mu sync.Mutex
+	runs := 0
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			runs++
+			if runs == 2 {
+				return errors.New("build failed")
+			}
+			return nil
+		})
+	}()
+
+	waitUntil(t, func() bool { return count() == 1 })
+	writeFile(t, file, "package main // changed")
+	waitUntil(t, func() bool { return count() == 2 })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if count() != 2 {
+		t.Errorf("runs = %d, want 2", count())
+	}
+	for _, want := range []string{"run 1 succeeded", "run 2 ·", "changed: main.go", "run 2 failed", "build failed"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("output = %q, want it to contain %q", output.String(), want)
+		}
+	}
+}
+
+func TestRunRestartsOnChange(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "main.go")
+	writeFile(t, file, "package main")
+
+	var output bytes.Buffer
+	w := New(root, []string{"*.go"})
+	w.Debounce = 20 * time.Millisecond
+	w.Output = &output
+	w.Restart = true
+
+	// Each run serves until its context is canceled
+	started := make(chan int, 3)
+	runs := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(ctx context.Context) error {
+			runs++
+			started <- runs
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	if run := <-started; run != 1 {
+		t.Fatalf("started run %d, want 1", run)
+	}
+	writeFile(t, file, "package main // changed")
+	select {
+	case run := <-started:
+		if run != 2 {
+			t.Fatalf("started run %d, want 2", run)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change didn't restart the run")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	for _, want := range []string{"run 1 stopped after", "run 2 ·", "changed: main.go", "run 2 stopped after"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("output = %q, want it to contain %q", output.String(), want)
+		}
+	}
+}
+
+func TestRunRequiresPatterns(t *testing.T) {
+	if err := New(t.TempDir(), nil).Run(context.Background(), nil); err == nil {
+		t.Error("Expected an error without patterns")
+	}
+	if err := New(t.TempDir(), []string{"[a-"}).Run(context.Background(), nil); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+// writeFile creates a file and its directory
+func writeFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitUntil polls cond until it holds or a few seconds pass
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met in time")
+}